@@ -0,0 +1,40 @@
+// Package api embeds the OpenAPI description of the HTTP API so the
+// server can publish the same document that lives in the repository.
+package api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed openapi.yaml
+var specYAML []byte
+
+var (
+	specJSON     []byte
+	specJSONErr  error
+	specJSONOnce sync.Once
+)
+
+// YAML returns the raw OpenAPI document
+func YAML() []byte {
+	return specYAML
+}
+
+// JSON returns the OpenAPI document converted to JSON. The conversion is
+// done once and cached.
+func JSON() ([]byte, error) {
+	specJSONOnce.Do(func() {
+		var doc map[string]interface{}
+		if err := yaml.Unmarshal(specYAML, &doc); err != nil {
+			specJSONErr = fmt.Errorf("failed to parse openapi spec: %w", err)
+			return
+		}
+		specJSON, specJSONErr = json.Marshal(doc)
+	})
+	return specJSON, specJSONErr
+}
@@ -0,0 +1,25 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSON(t *testing.T) {
+	data, err := JSON()
+	require.NoError(t, err)
+
+	var doc struct {
+		OpenAPI string                 `json:"openapi"`
+		Paths   map[string]interface{} `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(data, &doc))
+
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+	assert.Contains(t, doc.Paths, "/v1/links")
+	assert.Contains(t, doc.Paths, "/v1/links/{code}")
+	assert.Contains(t, doc.Paths, "/v1/openapi.json")
+}
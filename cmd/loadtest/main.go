@@ -0,0 +1,84 @@
+// Command loadtest runs the load test scenarios against a deployment,
+// prints their latency percentiles and exits with status 1 if any exceeds
+// its budget. See pkg/loadtest.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/loadtest"
+)
+
+func main() {
+	apiURL := flag.String("api", "http://localhost:8080", "URL of the API server")
+	token := flag.String("token", os.Getenv("LOADTEST_TOKEN"), "bearer token with the links:read, links:write and admin scopes, if the API requires one (default $LOADTEST_TOKEN)")
+	rate := flag.Int("rate", 100, "requests per second of each scenario")
+	duration := flag.Duration("duration", time.Minute, "duration of each scenario")
+	workers := flag.Int("workers", 100, "most requests in flight")
+	scenarios := flag.String("scenarios", "", "comma-separated scenarios to run (default all)")
+	budgetsPath := flag.String("budgets", "", "budget file (default the built-in budgets)")
+	reportPath := flag.String("report", "", "file to write the report to as JSON")
+	flag.Parse()
+
+	budgets := loadtest.DefaultBudgets()
+	if *budgetsPath != "" {
+		var err error
+		if budgets, err = loadtest.LoadBudgets(*budgetsPath); err != nil {
+			log.Fatal("Invalid -budgets: ", err)
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	cfg := loadtest.Config{Rate: *rate, Duration: *duration, Workers: *workers}
+	target, err := loadtest.NewTarget(ctx, *apiURL, *token, *workers)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var selected []string
+	if *scenarios != "" {
+		selected = strings.Split(*scenarios, ",")
+	}
+	var results []*loadtest.Result
+	for _, scenario := range loadtest.Scenarios(target, cfg) {
+		if selected != nil && !slices.Contains(selected, scenario.Name) {
+			continue
+		}
+		log.Printf("Running %s: %d requests/s for %s", scenario.Name, cfg.Rate, cfg.Duration)
+		result, err := loadtest.Run(ctx, cfg, scenario)
+		if err != nil {
+			log.Fatal(err)
+		}
+		results = append(results, result)
+	}
+
+	report := loadtest.NewReport(results, budgets)
+	if err := report.WriteText(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+	if *reportPath != "" {
+		f, err := os.Create(*reportPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := report.WriteJSON(f); err != nil {
+			log.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if !report.OK() {
+		fmt.Fprintf(os.Stderr, "%d budget violations\n", len(report.Violations))
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,104 @@
+// Command seed loads demo data into the database and Redis the servers are
+// configured with, for trying the dashboard and stats locally. See pkg/seed.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/config"
+	"url-shortener/pkg/security"
+	"url-shortener/pkg/seed"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+func main() {
+	owner := flag.String("owner", "", "ID of another user to seed data for, such as your own subject")
+	domain := flag.String("domain", "", "short domain to create custom-domain links on (default the first of SHORT_DOMAINS)")
+	days := flag.Int("days", 30, "days of click history")
+	randomSeed := flag.Int64("seed", 1, "seed of the click histories")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+	owners := seed.DemoOwners
+	if *owner != "" {
+		id, err := uuid.Parse(*owner)
+		if err != nil {
+			log.Fatal("Invalid -owner:", err)
+		}
+		owners = append(owners, seed.Owner{ID: id, Name: "owner-" + id.String()[:8]})
+	}
+	if *domain == "" && len(cfg.ShortDomains) > 0 {
+		*domain = cfg.ShortDomains[0]
+	}
+	if *days < 1 {
+		log.Fatal("-days must be at least 1")
+	}
+
+	ctx := context.Background()
+	if _, err := storage.GuardSchema(ctx, cfg.DatabaseURL, false); err != nil {
+		log.Fatal("Schema check failed: ", err)
+	}
+	pool, err := storage.NewPool(ctx, cfg.DatabaseURL, storage.PoolConfig{})
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer pool.Close()
+
+	opt, err := redis.ParseURL(cfg.RedisURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	redisClient := redis.NewClient(opt)
+	defer redisClient.Close()
+	linkCache := cache.NewLinkCache(redisClient)
+	if len(cfg.RedisShardURLs) > 0 {
+		shards, err := cache.DialShards(cfg.RedisShardURLs)
+		if err != nil {
+			log.Fatal("Invalid REDIS_SHARD_URLS:", err)
+		}
+		defer shards.Close()
+		linkCache.UseShards(shards)
+	}
+
+	passwords, err := security.NewPasswordHasher(cfg.PasswordHashAlgorithm)
+	if err != nil {
+		log.Fatal("Invalid PASSWORD_HASH_ALGORITHM:", err)
+	}
+
+	stores := seed.Stores{
+		Links:       storage.NewPostgresLinkStorage(pool),
+		Campaigns:   storage.NewPostgresCampaignStorage(pool),
+		Bundles:     storage.NewPostgresBundleStorage(pool),
+		Preferences: storage.NewPostgresPreferencesStorage(pool),
+		Cache:       linkCache,
+	}
+	summary, err := seed.Run(ctx, stores, seed.Options{
+		Owners:    owners,
+		Domain:    *domain,
+		Days:      *days,
+		Seed:      *randomSeed,
+		Now:       time.Now(),
+		Passwords: passwords,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Created %d links with %d clicks, %d campaigns and %d bundles; %d links already existed\n",
+		summary.Links, summary.Clicks, summary.Campaigns, summary.Bundles, summary.Existing)
+	for _, o := range owners {
+		fmt.Printf("  %s: %s\n", o.Name, o.ID)
+	}
+	fmt.Printf("Password-protected links open with %q\n", seed.DemoPassword)
+}
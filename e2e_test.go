@@ -0,0 +1,111 @@
+//go:build integration
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"url-shortener/internal/testutil"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createLink posts a link to s with client and returns the response
+func createLink(t *testing.T, s *testutil.Server, client *http.Client, longURL string) *http.Response {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"long_url": longURL})
+	resp, err := client.Post(s.API.URL+"/v1/links", "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// do sends a request without a body to s with client and returns the status
+func do(t *testing.T, client *http.Client, method, url string) int {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// TestOAuthWithKeycloak authenticates API requests with tokens Keycloak
+// issues
+func TestOAuthWithKeycloak(t *testing.T) {
+	keycloak := testutil.StartKeycloak(t)
+	s := testutil.NewServer(t, keycloak)
+
+	t.Run("MissingToken", func(t *testing.T) {
+		resp := createLink(t, s, s.Client(t, ""), "https://example.com")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("InvalidToken", func(t *testing.T) {
+		resp := createLink(t, s, s.Client(t, "invalid.jwt.token"), "https://example.com")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	})
+
+	t.Run("InsufficientScope", func(t *testing.T) {
+		reader := s.Client(t, keycloak.Token(t, testutil.Alice, "links:read"))
+		resp := createLink(t, s, reader, "https://example.com")
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("Ownership", func(t *testing.T) {
+		alice := s.Client(t, keycloak.Token(t, testutil.Alice, "links:read", "links:write"))
+		bob := s.Client(t, keycloak.Token(t, testutil.Bob, "links:read", "links:write"))
+
+		resp := createLink(t, s, alice, "https://example.com/alice")
+		require.Equal(t, http.StatusCreated, resp.StatusCode)
+		var created struct {
+			Code string `json:"code"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+
+		link, err := s.Links.GetLink(context.Background(), created.Code)
+		require.NoError(t, err)
+		require.NotNil(t, link.OwnerID)
+		assert.Equal(t, testutil.Alice.ID, *link.OwnerID, "links are owned by the token's subject")
+
+		url := s.API.URL + "/v1/links/" + created.Code
+		assert.Equal(t, http.StatusNotFound, do(t, bob, http.MethodDelete, url), "only the owner may delete a link")
+		assert.Equal(t, http.StatusNoContent, do(t, alice, http.MethodDelete, url))
+		assert.Equal(t, http.StatusNotFound, do(t, alice, http.MethodGet, url))
+	})
+}
+
+// TestRedirectCountsClicks follows a link created through the API on the
+// redirect server and syncs its click to Postgres
+func TestRedirectCountsClicks(t *testing.T) {
+	s := testutil.NewServer(t, nil)
+	resp := createLink(t, s, s.Client(t, ""), "https://example.com/landing")
+	require.Equal(t, http.StatusCreated, resp.StatusCode)
+	var created struct {
+		Code     string `json:"code"`
+		ShortURL string `json:"short_url"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&created))
+	assert.Equal(t, s.Redirect.URL+"/r/"+created.Code, created.ShortURL)
+
+	visitor := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	redirect, err := visitor.Get(created.ShortURL)
+	require.NoError(t, err)
+	redirect.Body.Close()
+	assert.Equal(t, http.StatusFound, redirect.StatusCode)
+	assert.Equal(t, "https://example.com/landing", redirect.Header.Get("Location"))
+
+	ctx := context.Background()
+	require.NoError(t, s.Resolver.FlushClicks(ctx))
+	var clicks int
+	require.NoError(t, s.Pool.QueryRow(ctx, `SELECT click_count FROM links WHERE code = $1`, created.Code).Scan(&clicks))
+	assert.Equal(t, 1, clicks)
+}
@@ -17,6 +17,7 @@ import (
 	"url-shortener/pkg/storage"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/assert"
 )
@@ -50,6 +51,16 @@ func (m *mockLinkStorage) GetByCode(ctx context.Context, code string) (*storage.
 	return m.GetByCodeTx(ctx, nil, code)
 }
 
+func (m *mockLinkStorage) GetByCodes(ctx context.Context, codes []string) ([]*storage.Link, error) {
+	var links []*storage.Link
+	for _, code := range codes {
+		if link, exists := m.links[code]; exists {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
 func (m *mockLinkStorage) Update(ctx context.Context, link *storage.Link) error {
 	m.links[link.Code] = link
 	return nil
@@ -60,13 +71,77 @@ func (m *mockLinkStorage) Delete(ctx context.Context, code string) error {
 	return nil
 }
 
-func (m *mockLinkStorage) IncrementClickCount(ctx context.Context, code string) error {
+func (m *mockLinkStorage) UpdateTx(ctx context.Context, tx pgx.Tx, link *storage.Link) error {
+	return m.Update(ctx, link)
+}
+
+func (m *mockLinkStorage) DeleteTx(ctx context.Context, tx pgx.Tx, code string) error {
+	return m.Delete(ctx, code)
+}
+
+func (m *mockLinkStorage) LastRetiredTx(ctx context.Context, tx pgx.Tx, code string) (*time.Time, error) {
+	return nil, nil
+}
+
+func (m *mockLinkStorage) GetCreation(ctx context.Context, code string) (*storage.CreationContext, error) {
+	return nil, nil
+}
+
+func (m *mockLinkStorage) RetireExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockLinkStorage) AddClickCount(ctx context.Context, code string, n int64) error {
+	if link, exists := m.links[code]; exists {
+		link.ClickCount += int(n)
+		now := time.Now()
+		link.LastClickedAt = &now
+	}
+	return nil
+}
+
+func (m *mockLinkStorage) ReplacePasswordHash(ctx context.Context, code, old, new string) error {
+	if link, exists := m.links[code]; exists && link.PasswordHash != nil && *link.PasswordHash == old {
+		link.PasswordHash = &new
+	}
+	return nil
+}
+
+func (m *mockLinkStorage) ListByOwner(ctx context.Context, ownerID uuid.UUID, query storage.LinkQuery, limit, offset int) ([]*storage.Link, error) {
+	var links []*storage.Link
+	for _, link := range m.links {
+		if link.OwnerID != nil && *link.OwnerID == ownerID {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+func (m *mockLinkStorage) GetTags(ctx context.Context, codes []string) (map[string][]string, error) {
+	tags := make(map[string][]string)
+	for _, code := range codes {
+		if link, exists := m.links[code]; exists {
+			tags[code] = link.Tags
+		}
+	}
+	return tags, nil
+}
+
+func (m *mockLinkStorage) SetTags(ctx context.Context, code string, tags []string) error {
 	if link, exists := m.links[code]; exists {
-		link.ClickCount++
+		link.Tags = tags
 	}
 	return nil
 }
 
+func (m *mockLinkStorage) SetTagsTx(ctx context.Context, tx pgx.Tx, code string, tags []string) error {
+	return m.SetTags(ctx, code, tags)
+}
+
+func (m *mockLinkStorage) ListTagsByOwner(ctx context.Context, ownerID uuid.UUID) ([]string, error) {
+	return nil, nil
+}
+
 type mockLinkCache struct{}
 
 func (m *mockLinkCache) Get(ctx context.Context, code string) (*cache.CachedLink, error) {
@@ -81,22 +156,66 @@ func (m *mockLinkCache) Delete(ctx context.Context, code string) error {
 	return nil
 }
 
-func (m *mockLinkCache) IncrementClick(ctx context.Context, code string) (int64, error) {
-	return 1, nil
+func (m *mockLinkCache) IncrementClick(ctx context.Context, code string) error {
+	return nil
+}
+
+func (m *mockLinkCache) TakeClickDeltas(ctx context.Context, limit int) (map[string]int64, error) {
+	return map[string]int64{}, nil
+}
+
+func (m *mockLinkCache) ReturnClickDeltas(ctx context.Context, deltas map[string]int64) error {
+	return nil
 }
 
-func (m *mockLinkCache) GetClickCount(ctx context.Context, code string) (int64, error) {
+func (m *mockLinkCache) IncrementDailyClick(ctx context.Context, code string, at time.Time) error {
+	return nil
+}
+
+func (m *mockLinkCache) GetDailyClicks(ctx context.Context, codes []string, day time.Time) (map[string]int64, error) {
+	return map[string]int64{}, nil
+}
+
+func (m *mockLinkCache) Purge(ctx context.Context, code string, prefix bool) (int64, error) {
 	return 0, nil
 }
 
-func (m *mockLinkCache) SetClickCount(ctx context.Context, code string, count int64, ttl time.Duration) error {
+func (m *mockLinkCache) GetMany(ctx context.Context, codes []string) (map[string]*cache.CachedLink, error) {
+	return map[string]*cache.CachedLink{}, nil
+}
+
+func (m *mockLinkCache) RecordTopClick(ctx context.Context, code string, ownerID *uuid.UUID, at time.Time) error {
 	return nil
 }
 
-func (m *mockLinkCache) ExpireClickCount(ctx context.Context, code string, ttl time.Duration) error {
+func (m *mockLinkCache) RecordClicks(ctx context.Context, clicks []*cache.StreamedClick) error {
 	return nil
 }
 
+func (m *mockLinkCache) TopLinks(ctx context.Context, ownerID *uuid.UUID, period string, now time.Time, limit int) ([]cache.TopLink, error) {
+	return nil, nil
+}
+
+func (m *mockLinkCache) MarkVisit(ctx context.Context, code string, keys []string, window time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (m *mockLinkCache) CountRedirects(ctx context.Context, code string, window time.Duration, now time.Time, n int64) (int64, error) {
+	return 1, nil
+}
+
+func (m *mockLinkCache) ForgetLink(ctx context.Context, code string, ownerID *uuid.UUID, now time.Time) error {
+	return nil
+}
+
+func (m *mockLinkCache) ForgetLinks(ctx context.Context, codes []string, now time.Time) error {
+	return nil
+}
+
+func (m *mockLinkCache) ClickedLinks(ctx context.Context, now time.Time) ([]string, error) {
+	return nil, nil
+}
+
 func TestCreateLinkEndpoint(t *testing.T) {
 	// Setup
 	mockStorage := newMockLinkStorage()
@@ -108,7 +227,7 @@ func TestCreateLinkEndpoint(t *testing.T) {
 
 	r := chi.NewRouter()
 	noopCSRF := func(next http.Handler) http.Handler { return next } // No CSRF for tests
-	httpHandlers.SetupRoutes(r, handler, nil, noopCSRF)
+	httpHandlers.SetupAPIRoutes(r, handler, nil, noopCSRF)
 
 	// Test data
 	reqBody := map[string]interface{}{
@@ -145,7 +264,7 @@ func TestHealthCheck(t *testing.T) {
 
 	r := chi.NewRouter()
 	noopCSRF := func(next http.Handler) http.Handler { return next }
-	httpHandlers.SetupRoutes(r, handler, nil, noopCSRF)
+	httpHandlers.SetupAPIRoutes(r, handler, nil, noopCSRF)
 
 	req := httptest.NewRequest("GET", "/health", nil)
 	w := httptest.NewRecorder()
@@ -177,7 +296,7 @@ func TestGetLinkEndpoint(t *testing.T) {
 
 	r := chi.NewRouter()
 	noopCSRF := func(next http.Handler) http.Handler { return next }
-	httpHandlers.SetupRoutes(r, handler, nil, noopCSRF)
+	httpHandlers.SetupAPIRoutes(r, handler, nil, noopCSRF)
 
 	// Test GET request
 	req := httptest.NewRequest("GET", "/v1/links/test123", nil)
@@ -216,7 +335,7 @@ func TestDeleteLinkEndpoint(t *testing.T) {
 
 	r := chi.NewRouter()
 	noopCSRF := func(next http.Handler) http.Handler { return next }
-	httpHandlers.SetupRoutes(r, handler, nil, noopCSRF)
+	httpHandlers.SetupAPIRoutes(r, handler, nil, noopCSRF)
 
 	// Test DELETE request
 	req := httptest.NewRequest("DELETE", "/v1/links/test123", nil)
@@ -244,7 +363,7 @@ func TestInvalidURLError(t *testing.T) {
 
 	r := chi.NewRouter()
 	noopCSRF := func(next http.Handler) http.Handler { return next }
-	httpHandlers.SetupRoutes(r, handler, nil, noopCSRF)
+	httpHandlers.SetupAPIRoutes(r, handler, nil, noopCSRF)
 
 	// Test with invalid URL
 	reqBody := map[string]interface{}{
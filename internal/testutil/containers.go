@@ -0,0 +1,176 @@
+// Package testutil runs the services the servers depend on, Postgres, Redis
+// and Keycloak, in containers for integration tests, and wires servers to
+// them as cmd/api and cmd/redirect do. It needs a Docker daemon; tests using
+// it are built with the integration tag:
+//
+//	go test -tags integration .
+//
+// Postgres and Keycloak are started once per test binary and shared, as
+// Keycloak alone takes most of a minute to start, and are removed by
+// testcontainers when the binary exits. Each test still gets a database and
+// a Redis of its own, so tests don't see each other's links.
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	tcredis "github.com/testcontainers/testcontainers-go/modules/redis"
+)
+
+// Images of the services, the versions the compose files run
+const (
+	PostgresImage = "postgres:15"
+	RedisImage    = "redis:7"
+	KeycloakImage = "quay.io/keycloak/keycloak:22.0"
+)
+
+// templateDatabase holds the migrated schema each test's database is
+// copied from
+const templateDatabase = "migrated"
+
+var (
+	postgresOnce sync.Once
+	postgresURL  string
+	postgresErr  error
+
+	// databasesMu serializes copies of the template, which Postgres
+	// refuses to make concurrently, and guards databases, the number made
+	databasesMu sync.Mutex
+	databases   int
+)
+
+// Postgres returns the URL of a database of its own for t, with every
+// migration in migrations/ applied. It is dropped when t ends.
+func Postgres(t testing.TB) string {
+	t.Helper()
+	postgresOnce.Do(func() {
+		postgresURL, postgresErr = startPostgres(context.Background())
+	})
+	if postgresErr != nil {
+		t.Fatalf("starting postgres: %v", postgresErr)
+	}
+
+	ctx := context.Background()
+	databasesMu.Lock()
+	databases++
+	name := fmt.Sprintf("test_%d", databases)
+	err := execIn(ctx, postgresURL, "CREATE DATABASE "+name+" TEMPLATE "+templateDatabase)
+	databasesMu.Unlock()
+	if err != nil {
+		t.Fatalf("creating database: %v", err)
+	}
+	t.Cleanup(func() {
+		if err := execIn(ctx, postgresURL, "DROP DATABASE "+name+" WITH (FORCE)"); err != nil {
+			t.Errorf("dropping database: %v", err)
+		}
+	})
+	return withDatabase(postgresURL, name)
+}
+
+// startPostgres runs the shared Postgres container and migrates its
+// template database, returning the URL of the container's own database
+func startPostgres(ctx context.Context) (string, error) {
+	container, err := postgres.Run(ctx, PostgresImage,
+		postgres.WithDatabase("postgres"),
+		postgres.WithUsername("user"),
+		postgres.WithPassword("password"),
+		postgres.BasicWaitStrategies(),
+	)
+	if err != nil {
+		return "", err
+	}
+	databaseURL, err := container.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		return "", err
+	}
+	if err := execIn(ctx, databaseURL, "CREATE DATABASE "+templateDatabase); err != nil {
+		return "", err
+	}
+	if err := migrate(ctx, withDatabase(databaseURL, templateDatabase)); err != nil {
+		return "", err
+	}
+	return databaseURL, nil
+}
+
+// migrate applies the migrations in migrations/ in order, as an operator
+// would with psql
+func migrate(ctx context.Context, databaseURL string) error {
+	_, file, _, _ := runtime.Caller(0)
+	dir := filepath.Join(filepath.Dir(file), "..", "..", "migrations")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	conn, err := pgx.Connect(ctx, databaseURL)
+	if err != nil {
+		return err
+	}
+	// The template can't be copied while anyone is connected to it
+	defer conn.Close(ctx)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+		sql, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		// Without arguments pgx sends the file as one simple query, so
+		// files of several statements run as psql would run them
+		if _, err := conn.Exec(ctx, string(sql)); err != nil {
+			return fmt.Errorf("migration %s: %w", entry.Name(), err)
+		}
+	}
+	return nil
+}
+
+// execIn runs sql on a connection of its own to databaseURL
+func execIn(ctx context.Context, databaseURL, sql string) error {
+	conn, err := pgx.Connect(ctx, databaseURL)
+	if err != nil {
+		return err
+	}
+	defer conn.Close(ctx)
+	_, err = conn.Exec(ctx, sql)
+	return err
+}
+
+// withDatabase returns databaseURL with its database replaced by name
+func withDatabase(databaseURL, name string) string {
+	u, err := url.Parse(databaseURL)
+	if err != nil {
+		// ConnectionString only returns URLs
+		panic(err)
+	}
+	u.Path = "/" + name
+	return u.String()
+}
+
+// Redis returns the URL of an empty Redis of its own for t, which is
+// removed when t ends
+func Redis(t testing.TB) string {
+	t.Helper()
+	ctx := context.Background()
+	container, err := tcredis.Run(ctx, RedisImage)
+	testcontainers.CleanupContainer(t, container)
+	if err != nil {
+		t.Fatalf("starting redis: %v", err)
+	}
+	redisURL, err := container.ConnectionString(ctx)
+	if err != nil {
+		t.Fatalf("redis URL: %v", err)
+	}
+	return redisURL
+}
@@ -0,0 +1,129 @@
+package testutil
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// realm is imported into Keycloak at startup: the url-shortener client,
+// whose tokens carry it as their audience, the links:read, links:write and
+// admin scopes, and the users below
+//
+//go:embed testdata/realm.json
+var realm []byte
+
+// User is a user of the test realm
+type User struct {
+	Username string
+	Password string
+	// ID is the user's subject, and so the owner of the links they create
+	ID uuid.UUID
+}
+
+// Users of the test realm
+var (
+	Alice = User{Username: "alice", Password: "alice-password", ID: uuid.MustParse("6f1c2a9e-3b4d-4e5f-8a7b-1c2d3e4f5a6b")}
+	Bob   = User{Username: "bob", Password: "bob-password", ID: uuid.MustParse("0b9a8c7d-6e5f-4a3b-9c2d-7e6f5a4b3c2d")}
+)
+
+// Client of the test realm, the audience the servers check
+const (
+	ClientID     = "url-shortener"
+	ClientSecret = "url-shortener-secret"
+)
+
+// Keycloak is an identity provider serving the test realm
+type Keycloak struct {
+	// IssuerURL is the realm's issuer, the servers' OIDC_ISSUER
+	IssuerURL string
+}
+
+var (
+	keycloakOnce sync.Once
+	keycloak     *Keycloak
+	keycloakErr  error
+)
+
+// StartKeycloak returns the shared Keycloak, starting it on first use
+func StartKeycloak(t testing.TB) *Keycloak {
+	t.Helper()
+	keycloakOnce.Do(func() {
+		keycloak, keycloakErr = startKeycloak(context.Background())
+	})
+	if keycloakErr != nil {
+		t.Fatalf("starting keycloak: %v", keycloakErr)
+	}
+	return keycloak
+}
+
+func startKeycloak(ctx context.Context) (*Keycloak, error) {
+	container, err := testcontainers.Run(ctx, KeycloakImage,
+		testcontainers.WithCmd("start-dev", "--import-realm"),
+		testcontainers.WithExposedPorts("8080/tcp"),
+		testcontainers.WithFiles(testcontainers.ContainerFile{
+			Reader:            bytes.NewReader(realm),
+			ContainerFilePath: "/opt/keycloak/data/import/url-shortener-realm.json",
+			FileMode:          0o644,
+		}),
+		// The realm is served once it is imported
+		testcontainers.WithWaitStrategy(
+			wait.ForHTTP("/realms/url-shortener/.well-known/openid-configuration").
+				WithPort("8080/tcp").
+				WithStartupTimeout(3*time.Minute),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+	// Without a configured hostname, Keycloak issues tokens for the host
+	// they are requested from, so tokens and the servers must both use
+	// this one
+	base, err := container.PortEndpoint(ctx, "8080/tcp", "http")
+	if err != nil {
+		return nil, err
+	}
+	return &Keycloak{IssuerURL: base + "/realms/url-shortener"}, nil
+}
+
+// Token returns an access token of user with scopes, from the password
+// grant of the test client
+func (k *Keycloak) Token(t testing.TB, user User, scopes ...string) string {
+	t.Helper()
+	form := url.Values{
+		"grant_type":    {"password"},
+		"client_id":     {ClientID},
+		"client_secret": {ClientSecret},
+		"username":      {user.Username},
+		"password":      {user.Password},
+		"scope":         {strings.Join(append([]string{"openid"}, scopes...), " ")},
+	}
+	resp, err := http.PostForm(k.IssuerURL+"/protocol/openid-connect/token", form)
+	if err != nil {
+		t.Fatalf("requesting token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken      string `json:"access_token"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding token: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("requesting token: %s: %s", resp.Status, body.ErrorDescription)
+	}
+	return body.AccessToken
+}
@@ -0,0 +1,154 @@
+package testutil
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"testing"
+
+	"url-shortener/pkg/cache"
+	httpHandlers "url-shortener/pkg/http"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/security"
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/session"
+	"url-shortener/pkg/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+)
+
+// Server is an API server and a redirect server sharing a database and a
+// Redis of their own
+type Server struct {
+	API      *httptest.Server
+	Redirect *httptest.Server
+
+	// Links serves the API, Resolver the redirects, which count clicks
+	Links    *service.LinkService
+	Resolver *service.Resolver
+	Pool     *pgxpool.Pool
+	Redis    *redis.Client
+}
+
+// NewServer starts a Server for t, wired as cmd/api and cmd/redirect wire
+// theirs for the link API and redirects. With keycloak set the API
+// authenticates with its tokens; without, its routes are open, and links are
+// created without an owner. Background workers aren't started; tests call
+// what they need, such as Resolver.FlushClicks, themselves.
+func NewServer(t testing.TB, keycloak *Keycloak) *Server {
+	t.Helper()
+	ctx := context.Background()
+	pool, err := storage.NewPool(ctx, Postgres(t), storage.PoolConfig{})
+	if err != nil {
+		t.Fatalf("connecting to postgres: %v", err)
+	}
+	t.Cleanup(pool.Close)
+	opt, err := redis.ParseURL(Redis(t))
+	if err != nil {
+		t.Fatalf("redis URL: %v", err)
+	}
+	redisClient := redis.NewClient(opt)
+	t.Cleanup(func() { redisClient.Close() })
+
+	logger := logging.NewLogger(logging.LevelError)
+	links := storage.NewPostgresLinkStorage(pool)
+	linkCache := cache.NewLinkCache(redisClient)
+	linkService := service.NewLinkService(links, linkCache, pool, logger)
+	linkService.UsePreferences(storage.NewPostgresPreferencesStorage(pool))
+	linkService.UseOutbox(storage.NewPostgresOutboxStorage(pool))
+	linkService.UseCampaigns(storage.NewPostgresCampaignStorage(pool))
+	linkService.UseAliasReservations(storage.NewPostgresAliasReservationStorage(pool))
+	linkService.UseHealth(storage.NewPostgresHealthStorage(pool))
+	linkService.UseSuspensions(storage.NewPostgresUserStorage(pool))
+
+	// Short URLs point at the redirect server, so it is started before
+	// the services are configured
+	redirectRoutes := chi.NewRouter()
+	redirect := httptest.NewServer(redirectRoutes)
+	t.Cleanup(redirect.Close)
+	settings := service.DefaultSettings()
+	settings.ShortURLBase = redirect.URL + "/r/"
+	linkService.ApplySettings(settings)
+	resolver := service.NewResolver(links, linkCache, logger)
+	resolver.ApplySettings(settings)
+	httpHandlers.SetupRedirectRoutes(redirectRoutes, httpHandlers.NewRedirectHandler(resolver), nil)
+
+	var oauthMiddleware *middleware.OAuthMiddleware
+	if keycloak != nil {
+		oauthMiddleware, err = middleware.NewOAuthMiddleware(middleware.OAuthConfig{
+			IssuerURL: keycloak.IssuerURL,
+			Audience:  ClientID,
+		})
+		if err != nil {
+			t.Fatalf("creating OAuth middleware: %v", err)
+		}
+		oauthMiddleware.UseSessions(session.NewRedisStore(redisClient), logger)
+	}
+
+	csrfManager := security.NewCSRFTokenManager()
+	handler := httpHandlers.NewHandler(linkService, csrfManager)
+	r := chi.NewRouter()
+	r.Use(httpHandlers.HeadAndOptions)
+	r.Use(handler.LinkDomain)
+	httpHandlers.SetupAPIRoutes(r, handler, oauthMiddleware, security.CSRFMiddleware(csrfManager))
+	api := httptest.NewServer(r)
+	t.Cleanup(api.Close)
+
+	return &Server{
+		API:      api,
+		Redirect: redirect,
+		Links:    linkService,
+		Resolver: resolver,
+		Pool:     pool,
+		Redis:    redisClient,
+	}
+}
+
+// Client returns a client of s.API that sends token as a bearer token, if
+// set, and the CSRF token state-changing requests need
+func (s *Server) Client(t testing.TB, token string) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatalf("cookie jar: %v", err)
+	}
+	client := &http.Client{Jar: jar}
+	// The CSRF token is bound to the session_id cookie set with it
+	resp, err := client.Get(s.API.URL + "/v1/csrf-token")
+	if err != nil {
+		t.Fatalf("requesting CSRF token: %v", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		CSRFToken string `json:"csrf_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("decoding CSRF token: %v", err)
+	}
+
+	headers := http.Header{"X-CSRF-Token": {body.CSRFToken}}
+	if token != "" {
+		headers.Set("Authorization", "Bearer "+token)
+	}
+	client.Transport = headerTransport{headers: headers, next: http.DefaultTransport}
+	return client
+}
+
+// headerTransport adds headers to every request
+type headerTransport struct {
+	headers http.Header
+	next    http.RoundTripper
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range t.headers {
+		req.Header[name] = values
+	}
+	return t.next.RoundTrip(req)
+}
@@ -0,0 +1,69 @@
+//go:build loadtest
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"url-shortener/internal/testutil"
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/loadtest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestLoad runs the load test scenarios against servers wired by testutil
+// and fails if any exceeds its budget in pkg/loadtest/budgets.json.
+// LOADTEST_RATE (default 50 requests/s) and LOADTEST_DURATION (default 30s)
+// set the load of each scenario, and LOADTEST_REPORT a file to write the
+// report to as JSON.
+func TestLoad(t *testing.T) {
+	cfg := loadtest.Config{Rate: 50, Duration: 30 * time.Second}
+	if v := os.Getenv("LOADTEST_RATE"); v != "" {
+		rate, err := strconv.Atoi(v)
+		require.NoError(t, err, "LOADTEST_RATE")
+		cfg.Rate = rate
+	}
+	if v := os.Getenv("LOADTEST_DURATION"); v != "" {
+		duration, err := time.ParseDuration(v)
+		require.NoError(t, err, "LOADTEST_DURATION")
+		cfg.Duration = duration
+	}
+
+	ctx := context.Background()
+	s := testutil.NewServer(t, nil)
+	target, err := loadtest.NewTarget(ctx, s.API.URL, "", cfg.Workers)
+	require.NoError(t, err)
+	// The test server has no admin routes, so the cache is purged directly
+	linkCache := cache.NewLinkCache(s.Redis)
+	target.PurgeCache = func(ctx context.Context) error {
+		_, err := linkCache.Purge(ctx, "", true)
+		return err
+	}
+
+	var results []*loadtest.Result
+	for _, scenario := range loadtest.Scenarios(target, cfg) {
+		result, err := loadtest.Run(ctx, cfg, scenario)
+		require.NoError(t, err)
+		results = append(results, result)
+	}
+
+	report := loadtest.NewReport(results, loadtest.DefaultBudgets())
+	var text bytes.Buffer
+	require.NoError(t, report.WriteText(&text))
+	t.Log("\n" + text.String())
+	if path := os.Getenv("LOADTEST_REPORT"); path != "" {
+		f, err := os.Create(path)
+		require.NoError(t, err)
+		require.NoError(t, report.WriteJSON(f))
+		require.NoError(t, f.Close())
+	}
+	for _, violation := range report.Violations {
+		t.Error(violation)
+	}
+}
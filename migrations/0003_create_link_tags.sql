@@ -0,0 +1,9 @@
+-- Free-form tags attached to links
+CREATE TABLE link_tags (
+    code VARCHAR(50) NOT NULL REFERENCES links(code) ON DELETE CASCADE,
+    tag VARCHAR(50) NOT NULL,
+    PRIMARY KEY (code, tag)
+);
+
+CREATE INDEX idx_link_tags_tag ON link_tags(tag);
+CREATE INDEX idx_links_owner_id ON links(owner_id);
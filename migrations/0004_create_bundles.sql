@@ -0,0 +1,21 @@
+-- Link-in-bio pages served at /b/{slug}
+CREATE TABLE bundles (
+    slug VARCHAR(50) PRIMARY KEY,
+    title VARCHAR(200) NOT NULL,
+    owner_id UUID NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_bundles_owner_id ON bundles(owner_id);
+
+CREATE TABLE bundle_entries (
+    id BIGSERIAL PRIMARY KEY,
+    slug VARCHAR(50) NOT NULL REFERENCES bundles(slug) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    title VARCHAR(200) NOT NULL,
+    url TEXT NOT NULL,
+    click_count BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX idx_bundle_entries_slug ON bundle_entries(slug, position);
@@ -0,0 +1,13 @@
+-- Per-link redirect status and optional custom short domain
+ALTER TABLE links ADD COLUMN redirect_type SMALLINT NOT NULL DEFAULT 302;
+ALTER TABLE links ADD COLUMN domain VARCHAR(255);
+
+-- Owner defaults applied when a create request omits a field
+CREATE TABLE owner_preferences (
+    owner_id UUID PRIMARY KEY,
+    default_expiry_seconds BIGINT,
+    default_redirect_type SMALLINT,
+    default_tags TEXT[] NOT NULL DEFAULT '{}',
+    default_domain VARCHAR(255),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
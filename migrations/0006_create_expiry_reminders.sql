@@ -0,0 +1,18 @@
+-- Opt-in expiry reminder settings per owner
+CREATE TABLE notification_settings (
+    owner_id UUID PRIMARY KEY,
+    email VARCHAR(320),
+    email_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    webhook_url TEXT,
+    days_before INTEGER NOT NULL DEFAULT 3,
+    clicks_percent INTEGER NOT NULL DEFAULT 90,
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- One row per reminder sent, so each link is only reminded once per kind
+CREATE TABLE link_reminders (
+    code VARCHAR(50) NOT NULL REFERENCES links(code) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,
+    sent_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (code, kind)
+);
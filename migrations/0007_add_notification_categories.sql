@@ -0,0 +1,4 @@
+-- Per-category notification opt-outs
+ALTER TABLE notification_settings ADD COLUMN expiry_reminders BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE notification_settings ADD COLUMN abuse_notices BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE notification_settings ADD COLUMN digest VARCHAR(10) NOT NULL DEFAULT 'off';
@@ -0,0 +1,2 @@
+-- End of the last period a digest was sent for, so each period is sent once
+ALTER TABLE notification_settings ADD COLUMN digest_sent_until TIMESTAMPTZ;
@@ -0,0 +1,2 @@
+-- Owners can opt a link into a public stats page at /r/{code}/stats
+ALTER TABLE links ADD COLUMN public_stats BOOLEAN NOT NULL DEFAULT FALSE;
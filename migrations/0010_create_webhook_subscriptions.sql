@@ -0,0 +1,14 @@
+-- Click webhooks: each subscription receives clicks on all of its owner's
+-- links, sampled and batched per subscription
+CREATE TABLE webhook_subscriptions (
+    id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL,
+    url TEXT NOT NULL,
+    secret VARCHAR(64) NOT NULL,
+    sample_rate DOUBLE PRECISION NOT NULL DEFAULT 1,
+    batch_size INTEGER NOT NULL DEFAULT 1,
+    batch_interval_seconds INTEGER NOT NULL DEFAULT 10,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_subscriptions_owner_id ON webhook_subscriptions(owner_id);
@@ -0,0 +1,17 @@
+-- Events written in the same transaction as the link mutation they describe
+-- and published by the outbox relay. locked_until leases a row to one relay
+-- and delays retries after a failed publish.
+CREATE TABLE outbox (
+    id BIGSERIAL PRIMARY KEY,
+    event_id UUID NOT NULL,
+    event_type VARCHAR(50) NOT NULL,
+    owner_id UUID,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    published_at TIMESTAMPTZ,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    locked_until TIMESTAMPTZ,
+    last_error TEXT
+);
+
+CREATE INDEX idx_outbox_unpublished ON outbox(id) WHERE published_at IS NULL;
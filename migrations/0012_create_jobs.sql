@@ -0,0 +1,22 @@
+-- Background jobs run by pkg/jobs. A job is pending until a worker leases it
+-- (running, until locked_until), then done, or pending again with a later
+-- run_at after a failure, or dead once it has used up its attempts. Dead
+-- jobs stay until an operator requeues them. unique_key lets periodic jobs
+-- be enqueued by every replica but run once.
+CREATE TABLE jobs (
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL DEFAULT '{}',
+    status VARCHAR(10) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL,
+    run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_until TIMESTAMPTZ,
+    last_error TEXT,
+    unique_key VARCHAR(200) UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_jobs_runnable ON jobs(run_at) WHERE status IN ('pending', 'running');
+CREATE INDEX idx_jobs_status ON jobs(status, updated_at);
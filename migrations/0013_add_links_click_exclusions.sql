@@ -0,0 +1,4 @@
+-- Visits from these CIDR ranges or user agents (case-insensitive substrings)
+-- redirect as usual but aren't counted, on top of the global exclusions
+ALTER TABLE links ADD COLUMN exclude_cidrs TEXT[];
+ALTER TABLE links ADD COLUMN exclude_user_agents TEXT[];
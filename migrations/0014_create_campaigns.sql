@@ -0,0 +1,15 @@
+-- Campaigns group an owner's links for reporting. Deleting a campaign keeps
+-- its links and just detaches them.
+CREATE TABLE campaigns (
+    id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL,
+    name VARCHAR(200) NOT NULL,
+    description TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_campaigns_owner_id ON campaigns(owner_id);
+
+ALTER TABLE links ADD COLUMN campaign_id UUID REFERENCES campaigns(id) ON DELETE SET NULL;
+CREATE INDEX idx_links_campaign_id ON links(campaign_id);
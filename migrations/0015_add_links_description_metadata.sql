@@ -0,0 +1,3 @@
+-- Free-form notes and integrator-defined key/value data on links
+ALTER TABLE links ADD COLUMN description TEXT;
+ALTER TABLE links ADD COLUMN metadata JSONB;
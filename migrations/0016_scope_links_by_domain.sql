@@ -0,0 +1,27 @@
+-- Codes and aliases are unique per short domain rather than globally. The
+-- default domain is stored as '' so that (domain, code) can be a key.
+ALTER TABLE links ADD COLUMN IF NOT EXISTS domain VARCHAR(255);
+UPDATE links SET domain = COALESCE(LOWER(domain), '');
+ALTER TABLE links ALTER COLUMN domain SET DEFAULT '';
+ALTER TABLE links ALTER COLUMN domain SET NOT NULL;
+
+ALTER TABLE link_tags ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE link_reminders ADD COLUMN domain VARCHAR(255) NOT NULL DEFAULT '';
+UPDATE link_tags t SET domain = l.domain FROM links l WHERE l.code = t.code;
+UPDATE link_reminders r SET domain = l.domain FROM links l WHERE l.code = r.code;
+
+ALTER TABLE link_tags DROP CONSTRAINT link_tags_code_fkey;
+ALTER TABLE link_tags DROP CONSTRAINT link_tags_pkey;
+ALTER TABLE link_reminders DROP CONSTRAINT link_reminders_code_fkey;
+ALTER TABLE link_reminders DROP CONSTRAINT link_reminders_pkey;
+
+ALTER TABLE links DROP CONSTRAINT links_pkey;
+ALTER TABLE links DROP CONSTRAINT links_alias_key;
+ALTER TABLE links ADD PRIMARY KEY (domain, code);
+CREATE UNIQUE INDEX links_domain_alias_key ON links(domain, alias);
+DROP INDEX idx_links_alias;
+
+ALTER TABLE link_tags ADD PRIMARY KEY (domain, code, tag);
+ALTER TABLE link_tags ADD FOREIGN KEY (domain, code) REFERENCES links(domain, code) ON DELETE CASCADE;
+ALTER TABLE link_reminders ADD PRIMARY KEY (domain, code, kind);
+ALTER TABLE link_reminders ADD FOREIGN KEY (domain, code) REFERENCES links(domain, code) ON DELETE CASCADE;
@@ -0,0 +1,2 @@
+-- Rules for the {name} placeholders of parameterized destinations, by name
+ALTER TABLE links ADD COLUMN param_rules JSONB;
@@ -0,0 +1,15 @@
+-- Results of the destination liveness checker, one row per checked link.
+-- notified is cleared whenever the status changes so that an owner hears
+-- about each newly dead destination once.
+CREATE TABLE link_health (
+    domain VARCHAR(255) NOT NULL DEFAULT '',
+    code VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    http_status INTEGER,
+    checked_at TIMESTAMPTZ NOT NULL,
+    notified BOOLEAN NOT NULL DEFAULT FALSE,
+    PRIMARY KEY (domain, code),
+    FOREIGN KEY (domain, code) REFERENCES links(domain, code) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_link_health_checked_at ON link_health(checked_at);
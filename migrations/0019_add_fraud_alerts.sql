@@ -0,0 +1,14 @@
+-- Links flagged by the click fraud detector. Alerts outlive their link so
+-- the record of what happened isn't lost when an abused link is deleted.
+CREATE TABLE fraud_alerts (
+    id BIGSERIAL PRIMARY KEY,
+    domain VARCHAR(255) NOT NULL DEFAULT '',
+    code VARCHAR(255) NOT NULL,
+    owner_id UUID,
+    kind VARCHAR(50) NOT NULL,
+    detail JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_fraud_alerts_created_at ON fraud_alerts(created_at);
+CREATE INDEX idx_fraud_alerts_link ON fraud_alerts(domain, code);
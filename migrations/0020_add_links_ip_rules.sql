@@ -0,0 +1,4 @@
+-- Requester IP rules: a link with allow_cidrs only redirects for addresses in
+-- them, and never for addresses in deny_cidrs
+ALTER TABLE links ADD COLUMN allow_cidrs TEXT[];
+ALTER TABLE links ADD COLUMN deny_cidrs TEXT[];
@@ -0,0 +1,13 @@
+-- Accounts suspended by an operator. Their links are disabled in the same
+-- transaction and enabled again when the account is reinstated.
+CREATE TABLE user_suspensions (
+    owner_id UUID PRIMARY KEY,
+    reason TEXT NOT NULL,
+    suspended_by VARCHAR(255) NOT NULL,
+    suspended_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE links ADD COLUMN disabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Operators look accounts up by their notification email
+CREATE INDEX idx_notification_settings_email ON notification_settings(LOWER(email));
@@ -0,0 +1,10 @@
+-- Terms of service versions each owner accepted. Owners who haven't
+-- accepted the current TOS_VERSION can't create links.
+CREATE TABLE terms_acceptances (
+    owner_id UUID NOT NULL,
+    version VARCHAR(100) NOT NULL,
+    accepted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (owner_id, version)
+);
+
+CREATE INDEX idx_terms_acceptances_latest ON terms_acceptances(owner_id, accepted_at DESC);
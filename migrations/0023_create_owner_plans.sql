@@ -0,0 +1,8 @@
+-- The plan an operator assigned to an owner. Owners without a row are on the
+-- configured default plan.
+CREATE TABLE owner_plans (
+    owner_id UUID PRIMARY KEY,
+    plan VARCHAR(32) NOT NULL,
+    updated_by VARCHAR(255) NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,15 @@
+-- The Stripe subscription paying for each owner's plan, as of the newest
+-- webhook event received for it
+CREATE TABLE billing_subscriptions (
+    owner_id UUID PRIMARY KEY,
+    customer_id VARCHAR(255) NOT NULL,
+    subscription_id VARCHAR(255) NOT NULL,
+    status VARCHAR(32) NOT NULL,
+    price_id VARCHAR(255) NOT NULL,
+    current_period_end TIMESTAMPTZ,
+    cancel_at_period_end BOOLEAN NOT NULL DEFAULT FALSE,
+    event_created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_billing_subscriptions_customer ON billing_subscriptions(customer_id);
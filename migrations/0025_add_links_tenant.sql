@@ -0,0 +1,4 @@
+-- The tenant each link was created in. Existing links belong to the default
+-- tenant ''.
+ALTER TABLE links ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT '';
+CREATE INDEX idx_links_tenant ON links(tenant_id) WHERE tenant_id <> '';
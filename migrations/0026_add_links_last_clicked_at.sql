@@ -0,0 +1,4 @@
+-- When each link was last clicked, as of the click sync that saved the
+-- click; NULL for links never clicked since this column was added.
+ALTER TABLE links ADD COLUMN last_clicked_at TIMESTAMPTZ;
+CREATE INDEX idx_links_owner_last_clicked ON links(owner_id, last_clicked_at NULLS FIRST);
@@ -0,0 +1,6 @@
+-- Indexes for listing an owner's links in each order GET /v1/links offers.
+-- Listing by last click uses idx_links_owner_last_clicked from 0026.
+CREATE INDEX idx_links_owner_created ON links(owner_id, created_at);
+CREATE INDEX idx_links_owner_clicks ON links(owner_id, click_count);
+CREATE INDEX idx_links_owner_expires ON links(owner_id, expires_at);
+DROP INDEX idx_links_owner_id;
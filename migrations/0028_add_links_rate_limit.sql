@@ -0,0 +1,4 @@
+-- Most redirects a link serves per rate_limit_window (minute, hour or
+-- day); NULL for no limit. Counted in Redis, not stored here.
+ALTER TABLE links ADD COLUMN rate_limit INTEGER;
+ALTER TABLE links ADD COLUMN rate_limit_window VARCHAR(10) NOT NULL DEFAULT '';
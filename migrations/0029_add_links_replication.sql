@@ -0,0 +1,6 @@
+-- When and in which region each link was last written, so that link writes
+-- replicated between regions apply last-writer-wins: a write only replaces
+-- the stored link if (updated_at, region) is later. Click counts aren't
+-- writes; each region counts its own.
+ALTER TABLE links ADD COLUMN updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+ALTER TABLE links ADD COLUMN region VARCHAR(50) NOT NULL DEFAULT '';
@@ -0,0 +1,22 @@
+-- REST hooks automation platforms subscribe to link triggers with
+CREATE TABLE integration_hooks (
+    id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL,
+    trigger VARCHAR(50) NOT NULL,
+    target_url TEXT NOT NULL,
+    threshold INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_integration_hooks_owner_id ON integration_hooks(owner_id);
+
+-- One row per link a link_clicks hook fired for, so each link reaching the
+-- threshold is sent once
+CREATE TABLE integration_hook_firings (
+    hook_id UUID NOT NULL REFERENCES integration_hooks(id) ON DELETE CASCADE,
+    domain VARCHAR(255) NOT NULL DEFAULT '',
+    code VARCHAR(255) NOT NULL,
+    fired_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (hook_id, domain, code),
+    FOREIGN KEY (domain, code) REFERENCES links(domain, code) ON DELETE CASCADE
+);
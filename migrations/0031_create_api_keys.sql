@@ -0,0 +1,13 @@
+-- API keys owners create for clients that can't sign in, such as browser
+-- extensions. Only a SHA-256 hash of each key is kept.
+CREATE TABLE api_keys (
+    id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(20) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    last_used_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_api_keys_owner_id ON api_keys(owner_id);
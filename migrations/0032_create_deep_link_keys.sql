@@ -0,0 +1,11 @@
+-- Keys owners sign deep link tokens with. The secret is kept as is, since
+-- it is needed to sign; it is only shown to the owner when created.
+CREATE TABLE deep_link_keys (
+    id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    secret VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_deep_link_keys_owner_id ON deep_link_keys(owner_id);
@@ -0,0 +1,10 @@
+-- Alias patterns reserved for one owner: an exact alias, or a prefix when
+-- the pattern ends in "*". Patterns are stored lower case.
+CREATE TABLE alias_reservations (
+    id UUID PRIMARY KEY,
+    owner_id UUID NOT NULL,
+    pattern VARCHAR(51) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX idx_alias_reservations_owner_id ON alias_reservations(owner_id);
@@ -0,0 +1,15 @@
+-- A row per link that was deleted or retired after expiring, kept for good.
+-- Codes with a tombstone are only used again as the recycling policy allows
+-- (CODE_RECYCLING), and the lifetimes here tell the clicks of a recycled
+-- code's earlier links from its current one's.
+CREATE TABLE code_tombstones (
+    id BIGSERIAL PRIMARY KEY,
+    domain VARCHAR(255) NOT NULL DEFAULT '',
+    code VARCHAR(255) NOT NULL,
+    owner_id UUID,
+    created_at TIMESTAMPTZ,
+    retired_at TIMESTAMPTZ NOT NULL,
+    reason VARCHAR(20) NOT NULL
+);
+
+CREATE INDEX idx_code_tombstones_code ON code_tombstones(domain, code, retired_at);
@@ -0,0 +1,2 @@
+-- Owners can ask search engines not to index a link (X-Robots-Tag: noindex)
+ALTER TABLE links ADD COLUMN noindex BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,3 @@
+-- Sitemaps are refreshed with the links of a domain written since the last
+-- refresh
+CREATE INDEX links_domain_updated_at ON links (domain, updated_at);
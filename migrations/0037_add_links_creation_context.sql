@@ -0,0 +1,7 @@
+-- Who created each link and from where, for abuse investigations: the
+-- client's address and user agent, and the API key or OAuth client used.
+-- Links created before this, or by internal callers, have none.
+ALTER TABLE links ADD COLUMN created_ip TEXT;
+ALTER TABLE links ADD COLUMN created_user_agent TEXT;
+ALTER TABLE links ADD COLUMN created_api_key_id UUID;
+ALTER TABLE links ADD COLUMN created_client_id TEXT;
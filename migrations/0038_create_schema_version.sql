@@ -0,0 +1,10 @@
+-- The number of the newest migration applied, which the servers compare
+-- with the one they were built for before starting. Every migration from
+-- this one on ends by setting it to its own number.
+CREATE TABLE schema_version (
+    id BOOLEAN PRIMARY KEY DEFAULT TRUE CHECK (id),
+    version INTEGER NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO schema_version (version) VALUES (38);
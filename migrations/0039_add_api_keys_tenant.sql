@@ -0,0 +1,5 @@
+-- The tenant each API key was created in, so it only works on that tenant's
+-- domains. Existing keys belong to the default tenant ''.
+ALTER TABLE api_keys ADD COLUMN tenant_id VARCHAR(64) NOT NULL DEFAULT '';
+
+UPDATE schema_version SET version = 39, updated_at = NOW();
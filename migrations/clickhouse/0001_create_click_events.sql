@@ -0,0 +1,13 @@
+-- Click events written by the clickhouse analytics backend
+-- (ANALYTICS_BACKEND=clickhouse). Run against ClickHouse, not Postgres.
+CREATE TABLE IF NOT EXISTS click_events (
+    code       String,
+    domain     LowCardinality(String),
+    tenant_id  LowCardinality(String),
+    owner_id   String,
+    clicked_at DateTime64(3, 'UTC'),
+    referrer   String,
+    user_agent String
+) ENGINE = MergeTree
+PARTITION BY toYYYYMM(clicked_at)
+ORDER BY (domain, code, clicked_at);
@@ -3,18 +3,15 @@ package main
 import (
 	"bytes"
 	"context"
-	"crypto/tls"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
-	"os"
 	"testing"
 	"time"
 
 	"url-shortener/pkg/cache"
 	httpHandlers "url-shortener/pkg/http"
 	"url-shortener/pkg/logging"
-	"url-shortener/pkg/middleware"
 	"url-shortener/pkg/security"
 	"url-shortener/pkg/service"
 	"url-shortener/pkg/storage"
@@ -23,163 +20,8 @@ import (
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/require"
 )
 
-// TestOAuthIntegrationWithKeycloak tests the complete OAuth flow with Keycloak
-func TestOAuthIntegrationWithKeycloak(t *testing.T) {
-	if testing.Short() {
-		t.Skip("Skipping integration test in short mode")
-	}
-
-	// Skip if Keycloak is not running
-	if os.Getenv("SKIP_KEYCLOAK_TESTS") == "true" {
-		t.Skip("Keycloak integration tests skipped")
-	}
-
-	// Setup test infrastructure
-	mockStorage := newOAuthMockLinkStorage()
-	mockCache := &oauthMockLinkCache{}
-	logger := logging.NewLogger(logging.LevelInfo)
-	linkService := service.NewLinkService(mockStorage, mockCache, nil, logger)
-	csrfManager := security.NewCSRFTokenManager()
-
-	handler := httpHandlers.NewHandler(linkService, csrfManager)
-	// Create OAuth middleware with test configuration
-	oauthConfig := middleware.OAuthConfig{
-		IssuerURL: "http://localhost:8080/realms/url-shortener",
-		Audience:  "url-shortener",
-	}
-
-	oauthMiddleware, err := middleware.NewOAuthMiddleware(oauthConfig)
-	require.NoError(t, err)
-
-	// Setup router with OAuth middleware
-	r := chi.NewRouter()
-	noopCSRF := func(next http.Handler) http.Handler { return next }
-	httpHandlers.SetupRoutes(r, handler, oauthMiddleware, noopCSRF)
-
-	// Test 1: Unauthenticated request should return 401
-	t.Run("UnauthenticatedRequest", func(t *testing.T) {
-		req := httptest.NewRequest("POST", "/v1/links", bytes.NewBufferString(`{"long_url":"https://example.com"}`))
-		req.Header.Set("Content-Type", "application/json")
-		w := httptest.NewRecorder()
-
-		r.ServeHTTP(w, req)
-		assert.Equal(t, http.StatusUnauthorized, w.Code)
-	})
-
-	// Test 2: Request with invalid token should return 401
-	t.Run("InvalidToken", func(t *testing.T) {
-		req := httptest.NewRequest("POST", "/v1/links", bytes.NewBufferString(`{"long_url":"https://example.com"}`))
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Authorization", "Bearer invalid.jwt.token")
-		w := httptest.NewRecorder()
-
-		r.ServeHTTP(w, req)
-		assert.Equal(t, http.StatusUnauthorized, w.Code)
-	})
-
-	// Test 3: Request with valid mock token should work
-	t.Run("ValidMockToken", func(t *testing.T) {
-		// Create a mock handler that bypasses OAuth for testing
-		logger2 := logging.NewLogger(logging.LevelInfo)
-		linkService2 := service.NewLinkService(mockStorage, mockCache, nil, logger2)
-		csrfManager2 := security.NewCSRFTokenManager()
-		mockHandler := httpHandlers.NewHandler(linkService2, csrfManager2)
-
-		mockRouter := chi.NewRouter()
-		noopCSRF := func(next http.Handler) http.Handler { return next }
-		httpHandlers.SetupRoutes(mockRouter, mockHandler, nil, noopCSRF)
-
-		req := httptest.NewRequest("POST", "/v1/links", bytes.NewBufferString(`{"long_url":"https://example.com"}`))
-		req.Header.Set("Content-Type", "application/json")
-		w := httptest.NewRecorder()
-
-		mockRouter.ServeHTTP(w, req)
-		assert.Equal(t, http.StatusCreated, w.Code)
-
-		var response map[string]interface{}
-		err := json.Unmarshal(w.Body.Bytes(), &response)
-		assert.NoError(t, err)
-		assert.Contains(t, response, "code")
-		assert.Contains(t, response, "short_url")
-	})
-}
-
-// TestKeycloakConnection tests if Keycloak is accessible
-func TestKeycloakConnection(t *testing.T) {
-	if testing.Short() {
-		t.Skip("Skipping integration test in short mode")
-	}
-
-	if os.Getenv("SKIP_KEYCLOAK_TESTS") == "true" {
-		t.Skip("Keycloak integration tests skipped")
-	}
-
-	// Create HTTP client that skips TLS verification for local testing
-	client := &http.Client{
-		Timeout: 10 * time.Second,
-		Transport: &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		},
-	}
-
-	// Test Keycloak health endpoint
-	resp, err := client.Get("http://localhost:8080/health/ready")
-	if err != nil {
-		t.Skipf("Keycloak not available: %v", err)
-	}
-	defer resp.Body.Close()
-
-	assert.Equal(t, http.StatusOK, resp.StatusCode, "Keycloak should be healthy")
-}
-
-// TestOAuthTokenValidation tests token validation with mock tokens
-func TestOAuthTokenValidation(t *testing.T) {
-	// This test validates the OAuth middleware logic without requiring a real IdP
-
-	config := middleware.OAuthConfig{
-		IssuerURL: "http://localhost:8080/realms/url-shortener",
-		Audience:  "url-shortener",
-	}
-
-	// Skip if we can't create the middleware (e.g., network issues)
-	oauthMiddleware, err := middleware.NewOAuthMiddleware(config)
-	if err != nil {
-		t.Skipf("Cannot create OAuth middleware: %v", err)
-	}
-
-	authFunc := oauthMiddleware.Authenticate("links:write")
-
-	// Test with missing authorization header
-	t.Run("MissingAuthHeader", func(t *testing.T) {
-		handler := authFunc(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-		}))
-
-		req := httptest.NewRequest("POST", "/v1/links", nil)
-		w := httptest.NewRecorder()
-
-		handler.ServeHTTP(w, req)
-		assert.Equal(t, http.StatusUnauthorized, w.Code)
-	})
-
-	// Test with malformed authorization header
-	t.Run("MalformedAuthHeader", func(t *testing.T) {
-		handler := authFunc(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-		}))
-
-		req := httptest.NewRequest("POST", "/v1/links", nil)
-		req.Header.Set("Authorization", "InvalidFormat token")
-		w := httptest.NewRecorder()
-
-		handler.ServeHTTP(w, req)
-		assert.Equal(t, http.StatusUnauthorized, w.Code)
-	})
-}
-
 // TestOwnershipEnforcement tests that users can only access their own links
 func TestOwnershipEnforcement(t *testing.T) {
 	// Setup test infrastructure
@@ -193,7 +35,7 @@ func TestOwnershipEnforcement(t *testing.T) {
 
 	r := chi.NewRouter()
 	noopCSRF := func(next http.Handler) http.Handler { return next }
-	httpHandlers.SetupRoutes(r, handler, nil, noopCSRF)
+	httpHandlers.SetupAPIRoutes(r, handler, nil, noopCSRF)
 	// Create a link with owner
 	ownerID := uuid.New()
 	link := &storage.Link{
@@ -253,8 +95,7 @@ func TestRedirectUnprotected(t *testing.T) {
 
 	// Create router without OAuth middleware
 	r := chi.NewRouter()
-	noopCSRF := func(next http.Handler) http.Handler { return next }
-	httpHandlers.SetupRoutes(r, handler, nil, noopCSRF)
+	httpHandlers.SetupRedirectRoutes(r, handler, nil)
 
 	// Create a test link
 	link := &storage.Link{
@@ -303,6 +144,16 @@ func (m *oauthMockLinkStorage) GetByCode(ctx context.Context, code string) (*sto
 	return m.GetByCodeTx(ctx, nil, code)
 }
 
+func (m *oauthMockLinkStorage) GetByCodes(ctx context.Context, codes []string) ([]*storage.Link, error) {
+	var links []*storage.Link
+	for _, code := range codes {
+		if link, exists := m.links[code]; exists {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
 func (m *oauthMockLinkStorage) Update(ctx context.Context, link *storage.Link) error {
 	m.links[link.Code] = link
 	return nil
@@ -313,13 +164,75 @@ func (m *oauthMockLinkStorage) Delete(ctx context.Context, code string) error {
 	return nil
 }
 
-func (m *oauthMockLinkStorage) IncrementClickCount(ctx context.Context, code string) error {
+func (m *oauthMockLinkStorage) UpdateTx(ctx context.Context, tx pgx.Tx, link *storage.Link) error {
+	return m.Update(ctx, link)
+}
+
+func (m *oauthMockLinkStorage) DeleteTx(ctx context.Context, tx pgx.Tx, code string) error {
+	return m.Delete(ctx, code)
+}
+
+func (m *oauthMockLinkStorage) LastRetiredTx(ctx context.Context, tx pgx.Tx, code string) (*time.Time, error) {
+	return nil, nil
+}
+
+func (m *oauthMockLinkStorage) GetCreation(ctx context.Context, code string) (*storage.CreationContext, error) {
+	return nil, nil
+}
+
+func (m *oauthMockLinkStorage) RetireExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	return 0, nil
+}
+
+func (m *oauthMockLinkStorage) AddClickCount(ctx context.Context, code string, n int64) error {
+	if link, exists := m.links[code]; exists {
+		link.ClickCount += int(n)
+	}
+	return nil
+}
+
+func (m *oauthMockLinkStorage) ReplacePasswordHash(ctx context.Context, code, old, new string) error {
+	if link, exists := m.links[code]; exists && link.PasswordHash != nil && *link.PasswordHash == old {
+		link.PasswordHash = &new
+	}
+	return nil
+}
+
+func (m *oauthMockLinkStorage) ListByOwner(ctx context.Context, ownerID uuid.UUID, query storage.LinkQuery, limit, offset int) ([]*storage.Link, error) {
+	var links []*storage.Link
+	for _, link := range m.links {
+		if link.OwnerID != nil && *link.OwnerID == ownerID {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+func (m *oauthMockLinkStorage) GetTags(ctx context.Context, codes []string) (map[string][]string, error) {
+	tags := make(map[string][]string)
+	for _, code := range codes {
+		if link, exists := m.links[code]; exists {
+			tags[code] = link.Tags
+		}
+	}
+	return tags, nil
+}
+
+func (m *oauthMockLinkStorage) SetTags(ctx context.Context, code string, tags []string) error {
 	if link, exists := m.links[code]; exists {
-		link.ClickCount++
+		link.Tags = tags
 	}
 	return nil
 }
 
+func (m *oauthMockLinkStorage) SetTagsTx(ctx context.Context, tx pgx.Tx, code string, tags []string) error {
+	return m.SetTags(ctx, code, tags)
+}
+
+func (m *oauthMockLinkStorage) ListTagsByOwner(ctx context.Context, ownerID uuid.UUID) ([]string, error) {
+	return nil, nil
+}
+
 type oauthMockLinkCache struct{}
 
 func (m *oauthMockLinkCache) Get(ctx context.Context, code string) (*cache.CachedLink, error) {
@@ -334,22 +247,66 @@ func (m *oauthMockLinkCache) Delete(ctx context.Context, code string) error {
 	return nil
 }
 
-func (m *oauthMockLinkCache) IncrementClick(ctx context.Context, code string) (int64, error) {
-	return 1, nil
+func (m *oauthMockLinkCache) IncrementClick(ctx context.Context, code string) error {
+	return nil
+}
+
+func (m *oauthMockLinkCache) TakeClickDeltas(ctx context.Context, limit int) (map[string]int64, error) {
+	return map[string]int64{}, nil
+}
+
+func (m *oauthMockLinkCache) ReturnClickDeltas(ctx context.Context, deltas map[string]int64) error {
+	return nil
+}
+
+func (m *oauthMockLinkCache) IncrementDailyClick(ctx context.Context, code string, at time.Time) error {
+	return nil
 }
 
-func (m *oauthMockLinkCache) GetClickCount(ctx context.Context, code string) (int64, error) {
+func (m *oauthMockLinkCache) GetDailyClicks(ctx context.Context, codes []string, day time.Time) (map[string]int64, error) {
+	return map[string]int64{}, nil
+}
+
+func (m *oauthMockLinkCache) Purge(ctx context.Context, code string, prefix bool) (int64, error) {
 	return 0, nil
 }
 
-func (m *oauthMockLinkCache) SetClickCount(ctx context.Context, code string, count int64, ttl time.Duration) error {
+func (m *oauthMockLinkCache) GetMany(ctx context.Context, codes []string) (map[string]*cache.CachedLink, error) {
+	return map[string]*cache.CachedLink{}, nil
+}
+
+func (m *oauthMockLinkCache) RecordTopClick(ctx context.Context, code string, ownerID *uuid.UUID, at time.Time) error {
 	return nil
 }
 
-func (m *oauthMockLinkCache) ExpireClickCount(ctx context.Context, code string, ttl time.Duration) error {
+func (m *oauthMockLinkCache) RecordClicks(ctx context.Context, clicks []*cache.StreamedClick) error {
 	return nil
 }
 
+func (m *oauthMockLinkCache) TopLinks(ctx context.Context, ownerID *uuid.UUID, period string, now time.Time, limit int) ([]cache.TopLink, error) {
+	return nil, nil
+}
+
+func (m *oauthMockLinkCache) MarkVisit(ctx context.Context, code string, keys []string, window time.Duration) (bool, error) {
+	return true, nil
+}
+
+func (m *oauthMockLinkCache) CountRedirects(ctx context.Context, code string, window time.Duration, now time.Time, n int64) (int64, error) {
+	return 1, nil
+}
+
+func (m *oauthMockLinkCache) ForgetLink(ctx context.Context, code string, ownerID *uuid.UUID, now time.Time) error {
+	return nil
+}
+
+func (m *oauthMockLinkCache) ForgetLinks(ctx context.Context, codes []string, now time.Time) error {
+	return nil
+}
+
+func (m *oauthMockLinkCache) ClickedLinks(ctx context.Context, now time.Time) ([]string, error) {
+	return nil, nil
+}
+
 // Helper types for testing
 type mockOAuthMiddleware struct{}
 
@@ -0,0 +1,55 @@
+// Package analytics sends counted clicks to an analytics backend. The
+// default backend, postgres, is the per-link click counts the click sync
+// keeps in the links table (see service/clicks.go), so it needs no sink and
+// records no individual clicks. The clickhouse backend also writes every
+// click as a row of a ClickHouse table, for deployments whose click volume
+// needs per-click analysis that Postgres can't take.
+package analytics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"url-shortener/pkg/logging"
+)
+
+// Event is one counted click
+type Event struct {
+	Code      string    `json:"code"`
+	Domain    string    `json:"domain"`
+	TenantID  string    `json:"tenant_id"`
+	OwnerID   string    `json:"owner_id"`
+	ClickedAt time.Time `json:"-"`
+	Referrer  string    `json:"referrer"`
+	UserAgent string    `json:"user_agent"`
+}
+
+// Sink receives counted clicks from the redirect path
+type Sink interface {
+	// Record queues event without blocking; a sink that can't keep up
+	// drops events rather than slow down redirects
+	Record(event *Event)
+	// Run sends queued events until ctx is done, then sends what is left
+	// before returning
+	Run(ctx context.Context)
+}
+
+// Config selects and configures a Sink
+type Config struct {
+	// Backend is "postgres" (the default, no sink) or "clickhouse"
+	Backend    string
+	ClickHouse ClickHouseConfig
+}
+
+// NewSink returns the configured sink, or nil for the postgres backend
+func NewSink(cfg Config, logger *logging.Logger) (Sink, error) {
+	switch cfg.Backend {
+	case "", "postgres":
+		return nil, nil
+	case "clickhouse":
+		return NewClickHouse(cfg.ClickHouse, logger)
+	default:
+		return nil, fmt.Errorf("analytics: unknown backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,186 @@
+package analytics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"url-shortener/pkg/logging"
+)
+
+const (
+	clickHouseQueueSize   = 100000
+	clickHouseMaxAttempts = 3
+	clickHouseRetryDelay  = time.Second
+	// clickHouseFinalTimeout bounds the last insert when Run stops
+	clickHouseFinalTimeout = 10 * time.Second
+)
+
+// tableName keeps the configured table safe to put in the INSERT
+var tableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// ClickHouseConfig points the clickhouse backend at a server's HTTP
+// interface. The table is created with the statement in
+// migrations/clickhouse/0001_create_click_events.sql.
+type ClickHouseConfig struct {
+	// URL is the HTTP interface, e.g. http://clickhouse:8123
+	URL      string
+	Table    string
+	User     string
+	Password string
+	// Events are inserted BatchSize at a time, or every FlushInterval when
+	// fewer arrive
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// ClickHouse inserts click events into ClickHouse in batches. ClickHouse
+// is built for few large inserts, so events are buffered in memory and
+// events queued when the process dies are lost; a batch that fails every
+// attempt is dropped and logged.
+type ClickHouse struct {
+	cfg    ClickHouseConfig
+	insert string
+	client *http.Client
+	logger *logging.Logger
+	// retryDelay doubles after each failed attempt
+	retryDelay time.Duration
+
+	events  chan *Event
+	dropped atomic.Int64
+}
+
+func NewClickHouse(cfg ClickHouseConfig, logger *logging.Logger) (*ClickHouse, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("analytics: clickhouse needs a URL")
+	}
+	if _, err := url.Parse(cfg.URL); err != nil {
+		return nil, fmt.Errorf("analytics: clickhouse URL: %w", err)
+	}
+	if !tableName.MatchString(cfg.Table) {
+		return nil, fmt.Errorf("analytics: invalid clickhouse table %q", cfg.Table)
+	}
+	if cfg.BatchSize < 1 || cfg.FlushInterval <= 0 {
+		return nil, errors.New("analytics: clickhouse batch size and flush interval must be positive")
+	}
+	return &ClickHouse{
+		cfg:        cfg,
+		insert:     "INSERT INTO " + cfg.Table + " FORMAT JSONEachRow",
+		client:     &http.Client{Timeout: 30 * time.Second},
+		logger:     logger,
+		retryDelay: clickHouseRetryDelay,
+		events:     make(chan *Event, clickHouseQueueSize),
+	}, nil
+}
+
+func (c *ClickHouse) Record(event *Event) {
+	select {
+	case c.events <- event:
+	default:
+		c.dropped.Add(1)
+	}
+}
+
+func (c *ClickHouse) Run(ctx context.Context) {
+	flush := time.NewTicker(c.cfg.FlushInterval)
+	defer flush.Stop()
+	batch := make([]*Event, 0, c.cfg.BatchSize)
+	send := func(ctx context.Context) {
+		if len(batch) > 0 {
+			c.send(ctx, batch)
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Send what was queued before shutdown, with a fresh deadline
+			final, cancel := context.WithTimeout(context.Background(), clickHouseFinalTimeout)
+			defer cancel()
+			for len(c.events) > 0 {
+				batch = append(batch, <-c.events)
+				if len(batch) == c.cfg.BatchSize {
+					send(final)
+				}
+			}
+			send(final)
+			return
+		case event := <-c.events:
+			batch = append(batch, event)
+			if len(batch) == c.cfg.BatchSize {
+				send(ctx)
+			}
+		case <-flush.C:
+			send(ctx)
+			if n := c.dropped.Swap(0); n > 0 {
+				c.logger.Warn(ctx, "analytics queue full, click events dropped", "count", n)
+			}
+		}
+	}
+}
+
+// send inserts batch, retrying a few times before dropping it
+func (c *ClickHouse) send(ctx context.Context, batch []*Event) {
+	var body bytes.Buffer
+	enc := json.NewEncoder(&body)
+	for _, event := range batch {
+		enc.Encode(clickHouseRow{Event: event, ClickedAt: event.ClickedAt.UTC().Format(clickHouseTime)})
+	}
+
+	delay := c.retryDelay
+	for attempt := 1; ; attempt++ {
+		err := c.post(ctx, body.Bytes())
+		if err == nil {
+			return
+		}
+		if attempt == clickHouseMaxAttempts || ctx.Err() != nil {
+			c.logger.Warn(ctx, "clickhouse insert failed, click events dropped", "events", len(batch), "error", err)
+			return
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// clickHouseTime is how DateTime64(3) columns read times by default
+const clickHouseTime = "2006-01-02 15:04:05.000"
+
+// clickHouseRow is an Event as a JSONEachRow line
+type clickHouseRow struct {
+	*Event
+	ClickedAt string `json:"clicked_at"`
+}
+
+func (c *ClickHouse) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.URL+"/?query="+url.QueryEscape(c.insert), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	if c.cfg.User != "" {
+		req.Header.Set("X-ClickHouse-User", c.cfg.User)
+		req.Header.Set("X-ClickHouse-Key", c.cfg.Password)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("clickhouse: %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package analytics
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/logging"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSink(t *testing.T) {
+	logger := logging.NewLogger(logging.LevelError)
+
+	sink, err := NewSink(Config{Backend: "postgres"}, logger)
+	require.NoError(t, err)
+	assert.Nil(t, sink)
+
+	_, err = NewSink(Config{Backend: "clickhouse"}, logger)
+	assert.ErrorContains(t, err, "URL")
+
+	_, err = NewSink(Config{Backend: "clickhouse", ClickHouse: ClickHouseConfig{
+		URL: "http://clickhouse:8123", Table: "events; DROP TABLE x", BatchSize: 1, FlushInterval: time.Second,
+	}}, logger)
+	assert.ErrorContains(t, err, "table")
+}
+
+func TestClickHouseInsertsBatches(t *testing.T) {
+	var mu sync.Mutex
+	var batches [][]map[string]any
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "INSERT INTO analytics.click_events FORMAT JSONEachRow", r.URL.Query().Get("query"))
+		assert.Equal(t, "writer", r.Header.Get("X-ClickHouse-User"))
+		assert.Equal(t, "secret", r.Header.Get("X-ClickHouse-Key"))
+
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var rows []map[string]any
+		lines := bufio.NewScanner(r.Body)
+		for lines.Scan() {
+			var row map[string]any
+			require.NoError(t, json.Unmarshal(lines.Bytes(), &row))
+			rows = append(rows, row)
+		}
+		batches = append(batches, rows)
+	}))
+	defer server.Close()
+
+	sink, err := NewClickHouse(ClickHouseConfig{
+		URL:           server.URL,
+		Table:         "analytics.click_events",
+		User:          "writer",
+		Password:      "secret",
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+	}, logging.NewLogger(logging.LevelError))
+	require.NoError(t, err)
+	sink.retryDelay = time.Millisecond
+
+	at := time.Date(2026, 3, 1, 12, 30, 0, 250e6, time.FixedZone("CET", 3600))
+	for _, code := range []string{"a", "b", "c"} {
+		sink.Record(&Event{Code: code, Domain: "sho.rt", ClickedAt: at})
+	}
+
+	// The first two go as a full batch, the last when Run stops
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		sink.Run(ctx)
+		close(done)
+	}()
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(batches) == 1
+	}, time.Second, time.Millisecond)
+	cancel()
+	<-done
+
+	require.Len(t, batches, 2)
+	require.Len(t, batches[0], 2)
+	assert.Equal(t, "a", batches[0][0]["code"])
+	assert.Equal(t, "sho.rt", batches[0][0]["domain"])
+	assert.Equal(t, "2026-03-01 11:30:00.250", batches[0][0]["clicked_at"])
+	require.Len(t, batches[1], 1)
+	assert.Equal(t, "c", batches[1][0]["code"])
+}
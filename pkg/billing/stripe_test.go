@@ -0,0 +1,62 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifySignature(t *testing.T) {
+	payload := []byte(`{"id":"evt_1"}`)
+	now := time.Now()
+	header := Sign(payload, "whsec_test", now)
+
+	assert.NoError(t, VerifySignature(payload, header, "whsec_test", now))
+	assert.NoError(t, VerifySignature(payload, header, "whsec_test", now.Add(time.Minute)))
+	assert.ErrorIs(t, VerifySignature(payload, header, "whsec_other", now), ErrSignature)
+	assert.ErrorIs(t, VerifySignature([]byte(`{"id":"evt_2"}`), header, "whsec_test", now), ErrSignature)
+	assert.ErrorIs(t, VerifySignature(payload, header, "whsec_test", now.Add(SignatureTolerance+time.Minute)), ErrSignature)
+	assert.ErrorIs(t, VerifySignature(payload, "", "whsec_test", now), ErrSignature)
+
+	// Stripe sends several v1 signatures while a secret is being rolled
+	assert.NoError(t, VerifySignature(payload, header+",v1=00ff", "whsec_test", now))
+}
+
+func TestParseEvent(t *testing.T) {
+	ownerID := uuid.New()
+	event, err := ParseEvent([]byte(`{
+		"id": "evt_1",
+		"type": "customer.subscription.updated",
+		"created": 1760000000,
+		"data": {"object": {
+			"id": "sub_1",
+			"customer": "cus_1",
+			"status": "active",
+			"cancel_at_period_end": true,
+			"metadata": {"owner_id": "` + ownerID.String() + `"},
+			"items": {"data": [{"current_period_end": 1762592000, "price": {"id": "price_pro"}}]}
+		}}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, SubscriptionUpdated, event.Type)
+	assert.Equal(t, time.Unix(1760000000, 0).UTC(), event.Created)
+	sub := event.Subscription
+	require.NotNil(t, sub)
+	assert.Equal(t, ownerID, sub.OwnerID)
+	assert.Equal(t, "cus_1", sub.CustomerID)
+	assert.Equal(t, "price_pro", sub.PriceID)
+	require.NotNil(t, sub.CurrentPeriodEnd)
+	assert.Equal(t, time.Unix(1762592000, 0).UTC(), *sub.CurrentPeriodEnd)
+	assert.True(t, sub.CancelAtPeriodEnd)
+	assert.True(t, sub.Active())
+
+	event, err = ParseEvent([]byte(`{"id": "evt_2", "type": "invoice.paid", "created": 1760000000, "data": {"object": {}}}`))
+	require.NoError(t, err)
+	assert.Nil(t, event.Subscription)
+
+	_, err = ParseEvent([]byte(`{"type": "invoice.paid"}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,306 @@
+// Package cachetest is the contract every cache.LinkCacheInterface
+// implementation must meet, so that a new backend, or LinkCache over a new
+// Redis topology, can be checked against what the services rely on. A
+// backend's tests call TestLinkCache with a function opening it:
+//
+//	func TestMyLinkCache(t *testing.T) {
+//		cachetest.TestLinkCache(t, func(t *testing.T) cache.LinkCacheInterface {
+//			return openMyCache(t)
+//		})
+//	}
+//
+// The suite covers misses, expiry, purges, and click counting under
+// concurrent writers. newCache must return an empty cache, as pending
+// click deltas and leaderboards are shared by every link.
+package cachetest
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/cache"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLinkCache runs the contract against caches newCache opens, one per
+// subtest
+func TestLinkCache(t *testing.T, newCache func(t *testing.T) cache.LinkCacheInterface) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, c cache.LinkCacheInterface)
+	}{
+		{"Miss", testMiss},
+		{"SetAndGet", testSetAndGet},
+		{"Expiry", testExpiry},
+		{"DeleteAndPurge", testDeleteAndPurge},
+		{"ConcurrentClicks", testConcurrentClicks},
+		{"ReturnClickDeltas", testReturnClickDeltas},
+		{"DailyClicks", testDailyClicks},
+		{"TopLinks", testTopLinks},
+		{"MarkVisit", testMarkVisit},
+		{"CountRedirects", testCountRedirects},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newCache(t))
+		})
+	}
+}
+
+// now is when clicks are counted, other than redirects
+var now = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+// testMiss checks that missing entries are nil rather than errors
+func testMiss(t *testing.T, c cache.LinkCacheInterface) {
+	ctx := context.Background()
+	link, err := c.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.Nil(t, link)
+
+	many, err := c.GetMany(ctx, []string{"missing"})
+	require.NoError(t, err)
+	assert.Empty(t, many)
+	many, err = c.GetMany(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, many)
+
+	assert.NoError(t, c.Delete(ctx, "missing"))
+	n, err := c.Purge(ctx, "missing", false)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+
+	deltas, err := c.TakeClickDeltas(ctx, 100)
+	require.NoError(t, err)
+	assert.Empty(t, deltas)
+	daily, err := c.GetDailyClicks(ctx, []string{"missing"}, now)
+	require.NoError(t, err)
+	assert.Empty(t, daily)
+}
+
+func testSetAndGet(t *testing.T, c cache.LinkCacheInterface) {
+	ctx := context.Background()
+	expires := now.Add(time.Hour)
+	owner := uuid.New()
+	rateLimit := 100
+	link := &cache.CachedLink{
+		LongURL:         "https://example.com",
+		HasPassword:     true,
+		ExpiresAt:       &expires,
+		RedirectType:    301,
+		OwnerID:         &owner,
+		DenyCIDRs:       []string{"192.0.2.0/24"},
+		Metadata:        map[string]any{"ticket": "OPS-1"},
+		RateLimit:       &rateLimit,
+		RateLimitWindow: "minute",
+	}
+	require.NoError(t, c.Set(ctx, "abc", link, time.Minute))
+	require.NoError(t, c.Set(ctx, "go.example.com/abc", &cache.CachedLink{LongURL: "https://example.org"}, time.Minute))
+
+	got, err := c.Get(ctx, "abc")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, link.LongURL, got.LongURL)
+	assert.True(t, got.HasPassword)
+	assert.True(t, expires.Equal(*got.ExpiresAt))
+	assert.Equal(t, 301, got.RedirectType)
+	assert.Equal(t, owner, *got.OwnerID)
+	assert.Equal(t, link.DenyCIDRs, got.DenyCIDRs)
+	assert.Equal(t, link.Metadata, got.Metadata)
+	assert.Equal(t, &rateLimit, got.RateLimit)
+
+	many, err := c.GetMany(ctx, []string{"abc", "go.example.com/abc", "missing"})
+	require.NoError(t, err)
+	require.Len(t, many, 2, "misses are omitted")
+	assert.Equal(t, "https://example.com", many["abc"].LongURL)
+	assert.Equal(t, "https://example.org", many["go.example.com/abc"].LongURL, "keys on other domains are separate")
+
+	// Set replaces the entry
+	require.NoError(t, c.Set(ctx, "abc", &cache.CachedLink{LongURL: "https://example.net"}, time.Minute))
+	got, err = c.Get(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.net", got.LongURL)
+	assert.False(t, got.HasPassword)
+}
+
+func testExpiry(t *testing.T, c cache.LinkCacheInterface) {
+	ctx := context.Background()
+	require.NoError(t, c.Set(ctx, "short", &cache.CachedLink{LongURL: "https://example.com"}, 100*time.Millisecond))
+	got, err := c.Get(ctx, "short")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+
+	assert.Eventually(t, func() bool {
+		got, err := c.Get(ctx, "short")
+		return err == nil && got == nil
+	}, 2*time.Second, 50*time.Millisecond, "entries expire after their TTL")
+}
+
+func testDeleteAndPurge(t *testing.T, c cache.LinkCacheInterface) {
+	ctx := context.Background()
+	for _, code := range []string{"promo1", "promo2", "promo*", "other"} {
+		require.NoError(t, c.Set(ctx, code, &cache.CachedLink{LongURL: "https://example.com/" + code}, time.Minute))
+	}
+
+	require.NoError(t, c.Delete(ctx, "other"))
+	got, err := c.Get(ctx, "other")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	n, err := c.Purge(ctx, "promo*", false)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, n, "without prefix, only the exact code is purged")
+	n, err = c.Purge(ctx, "promo", true)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, n)
+	many, err := c.GetMany(ctx, []string{"promo1", "promo2"})
+	require.NoError(t, err)
+	assert.Empty(t, many)
+}
+
+func testConcurrentClicks(t *testing.T, c cache.LinkCacheInterface) {
+	ctx := context.Background()
+	const writers, clicks = 10, 20
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range clicks {
+				assert.NoError(t, c.IncrementClick(ctx, "hot"))
+			}
+			assert.NoError(t, c.IncrementClick(ctx, "cold"+strconv.Itoa(i)))
+		}()
+	}
+	wg.Wait()
+
+	deltas, err := c.TakeClickDeltas(ctx, 100)
+	require.NoError(t, err)
+	assert.EqualValues(t, writers*clicks, deltas["hot"], "no concurrent click is lost")
+	assert.EqualValues(t, 1, deltas["cold0"])
+	assert.Len(t, deltas, writers+1)
+
+	deltas, err = c.TakeClickDeltas(ctx, 100)
+	require.NoError(t, err)
+	assert.Empty(t, deltas, "taking deltas resets them")
+}
+
+func testReturnClickDeltas(t *testing.T, c cache.LinkCacheInterface) {
+	ctx := context.Background()
+	for range 3 {
+		require.NoError(t, c.IncrementClick(ctx, "abc"))
+	}
+	for i := range 5 {
+		require.NoError(t, c.IncrementClick(ctx, "code"+strconv.Itoa(i)))
+	}
+
+	deltas, err := c.TakeClickDeltas(ctx, 2)
+	require.NoError(t, err)
+	assert.Len(t, deltas, 2, "at most limit codes are taken")
+
+	// Deltas that couldn't be saved are added to those counted since
+	require.NoError(t, c.ReturnClickDeltas(ctx, map[string]int64{"abc": 3}))
+	require.NoError(t, c.IncrementClick(ctx, "abc"))
+	all := make(map[string]int64)
+	for code, n := range deltas {
+		if code != "abc" {
+			all[code] += n
+		}
+	}
+	for {
+		taken, err := c.TakeClickDeltas(ctx, 100)
+		require.NoError(t, err)
+		if len(taken) == 0 {
+			break
+		}
+		for code, n := range taken {
+			all[code] += n
+		}
+	}
+	wantABC := int64(4)
+	if _, took := deltas["abc"]; !took {
+		wantABC = 7
+	}
+	assert.Equal(t, wantABC, all["abc"])
+	assert.Len(t, all, 6)
+}
+
+func testDailyClicks(t *testing.T, c cache.LinkCacheInterface) {
+	ctx := context.Background()
+	require.NoError(t, c.IncrementDailyClick(ctx, "abc", now))
+	require.NoError(t, c.IncrementDailyClick(ctx, "abc", now.Add(time.Hour)))
+	require.NoError(t, c.IncrementDailyClick(ctx, "abc", now.AddDate(0, 0, -1)))
+
+	daily, err := c.GetDailyClicks(ctx, []string{"abc", "missing"}, now)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"abc": 2}, daily, "codes without clicks are omitted")
+	daily, err = c.GetDailyClicks(ctx, []string{"abc"}, now.AddDate(0, 0, -1))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, daily["abc"])
+}
+
+func testTopLinks(t *testing.T, c cache.LinkCacheInterface) {
+	ctx := context.Background()
+	owner := uuid.New()
+	for range 3 {
+		require.NoError(t, c.RecordTopClick(ctx, "popular", &owner, now))
+	}
+	require.NoError(t, c.RecordTopClick(ctx, "go.example.com/niche", nil, now))
+
+	top, err := c.TopLinks(ctx, nil, "24h", now, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []cache.TopLink{
+		{Code: "popular", Clicks: 3},
+		{Code: "go.example.com/niche", Clicks: 1},
+	}, top, "most clicked first")
+
+	top, err = c.TopLinks(ctx, &owner, "7d", now, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []cache.TopLink{{Code: "popular", Clicks: 3}}, top, "owners only see their links")
+	top, err = c.TopLinks(ctx, nil, "30d", now.AddDate(0, 0, 31), 10)
+	require.NoError(t, err)
+	assert.Empty(t, top, "clicks leave the period")
+
+	_, err = c.TopLinks(ctx, nil, "1y", now, 10)
+	assert.Error(t, err, "unknown periods are refused")
+}
+
+func testMarkVisit(t *testing.T, c cache.LinkCacheInterface) {
+	ctx := context.Background()
+	first, err := c.MarkVisit(ctx, "abc", []string{"ip:203.0.113.7", "cookie:v1"}, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, first)
+
+	first, err = c.MarkVisit(ctx, "abc", []string{"ip:198.51.100.1", "cookie:v1"}, time.Minute)
+	require.NoError(t, err)
+	assert.False(t, first, "any key seen before makes it a repeat visit")
+
+	first, err = c.MarkVisit(ctx, "def", []string{"cookie:v1"}, time.Minute)
+	require.NoError(t, err)
+	assert.True(t, first, "visits are per link")
+}
+
+func testCountRedirects(t *testing.T, c cache.LinkCacheInterface) {
+	ctx := context.Background()
+	// Windows may expire by the clock, so they are taken around the
+	// current time
+	now := time.Now().Truncate(time.Hour)
+	count, err := c.CountRedirects(ctx, "abc", time.Hour, now, 1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+	count, err = c.CountRedirects(ctx, "abc", time.Hour, now.Add(30*time.Minute), 5)
+	require.NoError(t, err)
+	assert.EqualValues(t, 6, count, "counts add up within a window")
+
+	count, err = c.CountRedirects(ctx, "abc", time.Hour, now.Add(time.Hour), 1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count, "each window starts over")
+	count, err = c.CountRedirects(ctx, "def", time.Hour, now, 1)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count, "counts are per link")
+}
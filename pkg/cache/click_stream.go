@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	clickStreamKey   = "clicks:stream"
+	clickStreamGroup = "click-workers"
+	// clickClaimIdle is how long a click may stay unacknowledged by the
+	// worker that read it before another worker takes it over
+	clickClaimIdle = time.Minute
+)
+
+// StreamedClick is a counted click on its way through the click stream
+type StreamedClick struct {
+	// ID is the stream entry, set on clicks read from the stream
+	ID string
+	// Key is the link's key, see storage.LinkKey
+	Key       string
+	OwnerID   *uuid.UUID
+	TenantID  string
+	At        time.Time
+	Referrer  string
+	UserAgent string
+}
+
+// ClickStream is the Redis Stream clicks.stream, which carries counted
+// clicks from the servers that handle redirects to the click workers. The
+// workers share the consumer group click-workers, so each click is read by
+// one of them; one that isn't acknowledged within a minute, because its
+// worker died, is handed to another.
+type ClickStream struct {
+	client *redis.Client
+	// maxLen caps the stream; the oldest clicks are trimmed first, read or
+	// not
+	maxLen int64
+}
+
+func NewClickStream(client *redis.Client, maxLen int64) *ClickStream {
+	return &ClickStream{client: client, maxLen: maxLen}
+}
+
+// Publish appends clicks to the stream in one round trip
+func (s *ClickStream) Publish(ctx context.Context, clicks ...*StreamedClick) error {
+	pipe := s.client.Pipeline()
+	for _, click := range clicks {
+		values := map[string]any{
+			"key": click.Key,
+			"at":  click.At.UnixMilli(),
+		}
+		if click.OwnerID != nil {
+			values["owner"] = click.OwnerID.String()
+		}
+		for field, v := range map[string]string{"tenant": click.TenantID, "referrer": click.Referrer, "ua": click.UserAgent} {
+			if v != "" {
+				values[field] = v
+			}
+		}
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: clickStreamKey,
+			MaxLen: s.maxLen,
+			Approx: true,
+			Values: values,
+		})
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Read returns up to count clicks for consumer: first any abandoned by
+// another worker, else new ones, waiting up to block for them. Entries
+// that aren't clicks are acknowledged and skipped.
+func (s *ClickStream) Read(ctx context.Context, consumer string, count int, block time.Duration) ([]*StreamedClick, error) {
+	claimed, _, err := s.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   clickStreamKey,
+		Group:    clickStreamGroup,
+		MinIdle:  clickClaimIdle,
+		Start:    "0",
+		Count:    int64(count),
+		Consumer: consumer,
+	}).Result()
+	if isNoGroup(err) {
+		if err := s.createGroup(ctx); err != nil {
+			return nil, err
+		}
+		claimed, err = nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	messages := claimed
+	if len(messages) == 0 {
+		streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    clickStreamGroup,
+			Consumer: consumer,
+			Streams:  []string{clickStreamKey, ">"},
+			Count:    int64(count),
+			Block:    block,
+		}).Result()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return nil, err
+		}
+		for _, stream := range streams {
+			messages = append(messages, stream.Messages...)
+		}
+	}
+
+	clicks := make([]*StreamedClick, 0, len(messages))
+	var malformed []string
+	for _, m := range messages {
+		click, ok := parseStreamedClick(m)
+		if !ok {
+			malformed = append(malformed, m.ID)
+			continue
+		}
+		clicks = append(clicks, click)
+	}
+	if len(malformed) > 0 {
+		if err := s.Ack(ctx, malformed...); err != nil {
+			return nil, err
+		}
+	}
+	return clicks, nil
+}
+
+// Ack marks clicks as applied, so that they aren't read again
+func (s *ClickStream) Ack(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.client.XAck(ctx, clickStreamKey, clickStreamGroup, ids...).Err()
+}
+
+// createGroup creates the consumer group, and the stream with it. The group
+// starts at the beginning of the stream, so clicks published before the
+// first worker started are applied too.
+func (s *ClickStream) createGroup(ctx context.Context) error {
+	err := s.client.XGroupCreateMkStream(ctx, clickStreamKey, clickStreamGroup, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+func isNoGroup(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "NOGROUP")
+}
+
+func parseStreamedClick(m redis.XMessage) (*StreamedClick, bool) {
+	str := func(field string) string {
+		v, _ := m.Values[field].(string)
+		return v
+	}
+	ms, err := strconv.ParseInt(str("at"), 10, 64)
+	if str("key") == "" || err != nil {
+		return nil, false
+	}
+	click := &StreamedClick{
+		ID:        m.ID,
+		Key:       str("key"),
+		TenantID:  str("tenant"),
+		At:        time.UnixMilli(ms),
+		Referrer:  str("referrer"),
+		UserAgent: str("ua"),
+	}
+	if owner, err := uuid.Parse(str("owner")); err == nil {
+		click.OwnerID = &owner
+	}
+	return click, true
+}
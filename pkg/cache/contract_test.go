@@ -0,0 +1,31 @@
+package cache_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/cache/cachetest"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRedisLinkCache runs the contract against the Redis at TEST_REDIS_URL,
+// whose database is flushed before each subtest
+func TestRedisLinkCache(t *testing.T) {
+	redisURL := os.Getenv("TEST_REDIS_URL")
+	if redisURL == "" {
+		t.Skip("TEST_REDIS_URL not set")
+	}
+	opt, err := redis.ParseURL(redisURL)
+	require.NoError(t, err)
+	client := redis.NewClient(opt)
+	t.Cleanup(func() { client.Close() })
+
+	cachetest.TestLinkCache(t, func(t *testing.T) cache.LinkCacheInterface {
+		require.NoError(t, client.FlushDB(context.Background()).Err())
+		return cache.NewLinkCache(client)
+	})
+}
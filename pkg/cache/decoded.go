@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// maxDecodedLinks bounds the decoded entries a LinkCache keeps; when full
+// it starts over
+const maxDecodedLinks = 10000
+
+// decodedLinks remembers the CachedLink decoded from each link entry read,
+// so that an entry read again unchanged, as a popular link is on every
+// redirect, isn't decoded again. The same CachedLink is then returned to
+// every reader, so readers must not modify it.
+type decodedLinks struct {
+	mu    sync.RWMutex
+	links map[string]decodedLink
+}
+
+type decodedLink struct {
+	raw  string
+	link *CachedLink
+}
+
+func (d *decodedLinks) decode(key, raw string) (*CachedLink, error) {
+	d.mu.RLock()
+	hit, ok := d.links[key]
+	d.mu.RUnlock()
+	if ok && hit.raw == raw {
+		return hit.link, nil
+	}
+
+	var link CachedLink
+	if err := json.Unmarshal([]byte(raw), &link); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	if d.links == nil || len(d.links) >= maxDecodedLinks {
+		d.links = make(map[string]decodedLink)
+	}
+	d.links[key] = decodedLink{raw: raw, link: &link}
+	d.mu.Unlock()
+	return &link, nil
+}
@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DomainCache keeps when destination domains were registered, as Unix
+// seconds in domain_registered:{domain}, 0 when that is unknown
+type DomainCache struct {
+	client *redis.Client
+}
+
+func NewDomainCache(client *redis.Client) *DomainCache {
+	return &DomainCache{client: client}
+}
+
+func (c *DomainCache) GetRegistration(ctx context.Context, domain string) (time.Time, bool, error) {
+	val, err := c.client.Get(ctx, "domain_registered:"+domain).Result()
+	if err == redis.Nil {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	unix, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if unix == 0 {
+		return time.Time{}, true, nil
+	}
+	return time.Unix(unix, 0).UTC(), true, nil
+}
+
+func (c *DomainCache) SetRegistration(ctx context.Context, domain string, registered time.Time, ttl time.Duration) error {
+	var unix int64
+	if !registered.IsZero() {
+		unix = registered.Unix()
+	}
+	return c.client.Set(ctx, "domain_registered:"+domain, unix, ttl).Err()
+}
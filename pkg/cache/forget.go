@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// forgetBatch bounds the keys or fields sent in one command when forgetting
+// or listing links
+const forgetBatch = 1000
+
+// ForgetLink removes code's pending click delta, its daily counts and its
+// entries in the global leaderboards and ownerID's
+func (c *LinkCache) ForgetLink(ctx context.Context, code string, ownerID *uuid.UUID, now time.Time) error {
+	scopes := []string{"all"}
+	if ownerID != nil {
+		scopes = append(scopes, topScope(ownerID))
+	}
+	var top []string
+	for _, scope := range scopes {
+		top = append(top, topBuckets(scope, "24h", now)...)
+		top = append(top, topBuckets(scope, "30d", now)...)
+		for _, period := range TopPeriods {
+			top = append(top, "top:"+scope+":"+period)
+		}
+	}
+	return c.forget(ctx, []string{code}, top, now)
+}
+
+// ForgetLinks is ForgetLink for links whose owners aren't known, such as
+// ones already deleted. It removes them from every leaderboard, which takes
+// a scan of the keyspace.
+func (c *LinkCache) ForgetLinks(ctx context.Context, codes []string, now time.Time) error {
+	if len(codes) == 0 {
+		return nil
+	}
+	var top []string
+	iter := c.client.Scan(ctx, 0, "top:*", forgetBatch).Iterator()
+	for iter.Next(ctx) {
+		top = append(top, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	return c.forget(ctx, codes, top, now)
+}
+
+func (c *LinkCache) forget(ctx context.Context, codes, top []string, now time.Time) error {
+	for client, codes := range c.byShard(codes) {
+		pending := make([]string, len(codes))
+		dirty := make([]any, len(codes))
+		for i, code := range codes {
+			pending[i] = pendingClicksKey(code)
+			dirty[i] = code
+		}
+		pipe := client.Pipeline()
+		pipe.Del(ctx, pending...)
+		pipe.SRem(ctx, clicksDirty, dirty...)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+	}
+
+	pipe := c.client.Pipeline()
+	members := make([]any, len(codes))
+	for i, code := range codes {
+		members[i] = code
+	}
+	for _, day := range retainedDays(now) {
+		pipe.HDel(ctx, dailyClicksKey(day), codes...)
+	}
+	for _, key := range top {
+		pipe.ZRem(ctx, key, members...)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// retainedDays are the UTC days whose counts are still kept at now, most
+// recent first
+func retainedDays(now time.Time) []time.Time {
+	days := make([]time.Time, 0, int(dailyClicksTTL/(24*time.Hour))+1)
+	for d := 0; d <= int(dailyClicksTTL/(24*time.Hour)); d++ {
+		days = append(days, now.AddDate(0, 0, -d))
+	}
+	return days
+}
+
+// ClickedLinks returns the codes with daily counts still kept at now, which
+// include every code with a leaderboard entry
+func (c *LinkCache) ClickedLinks(ctx context.Context, now time.Time) ([]string, error) {
+	seen := make(map[string]bool)
+	var codes []string
+	for _, day := range retainedDays(now) {
+		iter := c.client.HScan(ctx, dailyClicksKey(day), 0, "", forgetBatch).Iterator()
+		for i := 0; iter.Next(ctx); i++ {
+			// HSCAN alternates fields and values
+			if i%2 == 1 {
+				continue
+			}
+			if code := iter.Val(); !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
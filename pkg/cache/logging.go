@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"url-shortener/pkg/logging"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// LogHook logs Redis commands that fail or take slow or longer (0 logs only
+// failures), with the correlation ID of the request they ran for. A missing
+// key isn't a failure, and command arguments other than the key aren't
+// logged.
+type LogHook struct {
+	logger *logging.Logger
+	slow   time.Duration
+}
+
+func NewLogHook(logger *logging.Logger, slow time.Duration) *LogHook {
+	return &LogHook{logger: logger, slow: slow}
+}
+
+func (h *LogHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			h.logger.Warn(ctx, "redis connection failed", "addr", addr, "error", err)
+		}
+		return conn, err
+	}
+}
+
+func (h *LogHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		h.log(ctx, []redis.Cmder{cmd}, err, time.Since(start))
+		return err
+	}
+}
+
+func (h *LogHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		h.log(ctx, cmds, err, time.Since(start))
+		return err
+	}
+}
+
+func (h *LogHook) log(ctx context.Context, cmds []redis.Cmder, err error, elapsed time.Duration) {
+	if errors.Is(err, redis.Nil) {
+		err = nil
+	}
+	// Name the first command that failed, if it is known which
+	failed := cmds[0]
+	for _, cmd := range cmds {
+		if cmdErr := cmd.Err(); cmdErr != nil && !errors.Is(cmdErr, redis.Nil) {
+			failed, err = cmd, cmdErr
+			break
+		}
+	}
+	args := []any{"command", failed.Name(), "duration_ms", elapsed.Milliseconds()}
+	if key, ok := commandKey(failed); ok {
+		args = append(args, "key", key)
+	}
+	if len(cmds) > 1 {
+		args = append(args, "commands", len(cmds))
+	}
+	switch {
+	case err != nil && !errors.Is(err, context.Canceled):
+		h.logger.Warn(ctx, "redis command failed", append(args, "error", err)...)
+	case err == nil && h.slow > 0 && elapsed >= h.slow:
+		h.logger.Warn(ctx, "slow redis command", args...)
+	}
+}
+
+// commandKey is the first argument of cmd, which is the key of most commands
+func commandKey(cmd redis.Cmder) (string, bool) {
+	args := cmd.Args()
+	if len(args) < 2 {
+		return "", false
+	}
+	key, ok := args[1].(string)
+	return key, ok
+}
@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/logging"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogHook(t *testing.T) {
+	var buf bytes.Buffer
+	hook := NewLogHook(logging.NewLoggerTo(logging.LevelInfo, &buf), 100*time.Millisecond)
+	ctx := logging.WithCorrelationID(context.Background())
+	run := func(elapsed time.Duration, err error, cmds ...redis.Cmder) string {
+		buf.Reset()
+		hook.ProcessPipelineHook(func(ctx context.Context, cmds []redis.Cmder) error {
+			time.Sleep(elapsed)
+			return err
+		})(ctx, cmds)
+		return buf.String()
+	}
+
+	get := redis.NewStringCmd(ctx, "get", "link:abc")
+	assert.Empty(t, run(0, nil, get))
+
+	get.SetErr(redis.Nil)
+	assert.Empty(t, run(0, redis.Nil, get), "a missing key isn't a failure")
+
+	incr := redis.NewIntCmd(ctx, "incrby", "ratelimit:abc:0", 5)
+	incr.SetErr(errors.New("READONLY You can't write against a read only replica"))
+	out := run(0, incr.Err(), get, incr)
+	assert.Contains(t, out, `"msg":"redis command failed"`)
+	assert.Contains(t, out, `"command":"incrby"`)
+	assert.Contains(t, out, `"key":"ratelimit:abc:0"`)
+	assert.Contains(t, out, `"commands":2`)
+	assert.Contains(t, out, `"correlation_id":"`+logging.GetCorrelationID(ctx)+`"`)
+
+	out = run(120*time.Millisecond, nil, redis.NewStringCmd(ctx, "get", "link:slow"))
+	assert.Contains(t, out, `"msg":"slow redis command"`)
+	assert.NotContains(t, out, "error")
+}
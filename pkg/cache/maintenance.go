@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// MaintenanceCache keeps maintenance mode for every server sharing Redis, as
+// the message and since (Unix seconds) fields of the maintenance hash, which
+// exists only while it is on
+type MaintenanceCache struct {
+	client *redis.Client
+}
+
+func NewMaintenanceCache(client *redis.Client) *MaintenanceCache {
+	return &MaintenanceCache{client: client}
+}
+
+func (c *MaintenanceCache) GetMaintenance(ctx context.Context) (string, time.Time, bool, error) {
+	fields, err := c.client.HGetAll(ctx, "maintenance").Result()
+	if err != nil || len(fields) == 0 {
+		return "", time.Time{}, false, err
+	}
+	since, err := strconv.ParseInt(fields["since"], 10, 64)
+	if err != nil {
+		return "", time.Time{}, false, err
+	}
+	return fields["message"], time.Unix(since, 0).UTC(), true, nil
+}
+
+func (c *MaintenanceCache) SetMaintenance(ctx context.Context, message string, since time.Time) error {
+	return c.client.HSet(ctx, "maintenance", "message", message, "since", since.Unix()).Err()
+}
+
+func (c *MaintenanceCache) ClearMaintenance(ctx context.Context) error {
+	return c.client.Del(ctx, "maintenance").Err()
+}
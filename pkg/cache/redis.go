@@ -3,23 +3,73 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
 type LinkCacheInterface interface {
 	Get(ctx context.Context, code string) (*CachedLink, error)
+	// GetMany looks up codes in one round trip; misses are omitted
+	GetMany(ctx context.Context, codes []string) (map[string]*CachedLink, error)
 	Set(ctx context.Context, code string, link *CachedLink, ttl time.Duration) error
 	Delete(ctx context.Context, code string) error
-	IncrementClick(ctx context.Context, code string) (int64, error)
-	GetClickCount(ctx context.Context, code string) (int64, error)
-	SetClickCount(ctx context.Context, code string, count int64, ttl time.Duration) error
-	ExpireClickCount(ctx context.Context, code string, ttl time.Duration) error
+	// Purge drops the cached link for code, or with prefix set every link
+	// whose code starts with code, and returns how many entries it removed
+	Purge(ctx context.Context, code string, prefix bool) (int64, error)
+	// IncrementClick adds a click to code's pending delta, which
+	// TakeClickDeltas later moves to Postgres
+	IncrementClick(ctx context.Context, code string) error
+	// TakeClickDeltas removes and returns the pending deltas of up to limit
+	// codes; each delta is read and reset in one step. Deltas taken before
+	// an error are returned with it.
+	TakeClickDeltas(ctx context.Context, limit int) (map[string]int64, error)
+	// ReturnClickDeltas adds deltas to pending, such as ones that could not
+	// be saved
+	ReturnClickDeltas(ctx context.Context, deltas map[string]int64) error
+	// IncrementDailyClick counts a click towards the UTC day containing at
+	IncrementDailyClick(ctx context.Context, code string, at time.Time) error
+	// GetDailyClicks returns the clicks per code for the UTC day containing
+	// day; codes without clicks are omitted
+	GetDailyClicks(ctx context.Context, codes []string, day time.Time) (map[string]int64, error)
+	// RecordTopClick counts a click on code towards the global leaderboard
+	// and, when ownerID is set, the owner's
+	RecordTopClick(ctx context.Context, code string, ownerID *uuid.UUID, at time.Time) error
+	// RecordClicks counts a batch of clicks as IncrementDailyClick and
+	// RecordTopClick would, in one round trip
+	RecordClicks(ctx context.Context, clicks []*StreamedClick) error
+	// TopLinks returns the limit codes with the most clicks over period (one
+	// of TopPeriods) ending at now, from ownerID's leaderboard or the global
+	// one when it is nil
+	TopLinks(ctx context.Context, ownerID *uuid.UUID, period string, now time.Time, limit int) ([]TopLink, error)
+	// MarkVisit records that the visitor identified by keys opened code and
+	// reports whether none of the keys had been seen within window
+	MarkVisit(ctx context.Context, code string, keys []string, window time.Duration) (bool, error)
+	// CountRedirects counts n redirects of code in the window of length
+	// window containing now and returns the window's count so far
+	CountRedirects(ctx context.Context, code string, window time.Duration, now time.Time, n int64) (int64, error)
+	// ForgetLink drops the click state kept for a deleted link, other than
+	// its cached entry; see forget.go
+	ForgetLink(ctx context.Context, code string, ownerID *uuid.UUID, now time.Time) error
+	// ForgetLinks is ForgetLink for links whose owner isn't known
+	ForgetLinks(ctx context.Context, codes []string, now time.Time) error
+	// ClickedLinks returns the codes that have click state kept at now
+	ClickedLinks(ctx context.Context, now time.Time) ([]string, error)
 }
 
+// dailyClicksTTL keeps per-day counters long enough for monthly digests
+const dailyClicksTTL = 35 * 24 * time.Hour
+
 type LinkCache struct {
 	client *redis.Client
+	// shards, when set, hold cached links and pending click deltas
+	shards  *Shards
+	decoded decodedLinks
 }
 
 type CachedLink struct {
@@ -27,28 +77,114 @@ type CachedLink struct {
 	HasPassword bool       `json:"has_password"`
 	ExpiresAt   *time.Time `json:"expires_at"`
 	MaxClicks   *int       `json:"max_clicks"`
+	// RedirectType is the HTTP status used for the redirect; 0 means 302
+	RedirectType int `json:"redirect_type,omitempty"`
+	// OwnerID routes click events to the owner's webhooks
+	OwnerID *uuid.UUID `json:"owner_id,omitempty"`
+	// Per-link click counting exclusions
+	ExcludeCIDRs      []string `json:"exclude_cidrs,omitempty"`
+	ExcludeUserAgents []string `json:"exclude_user_agents,omitempty"`
+	// Requester IP rules, enforced on redirect
+	AllowCIDRs []string `json:"allow_cidrs,omitempty"`
+	DenyCIDRs  []string `json:"deny_cidrs,omitempty"`
+	// Disabled links of suspended accounts don't redirect
+	Disabled bool   `json:"disabled,omitempty"`
+	TenantID string `json:"tenant_id,omitempty"`
+	// Integrator fields, so GET /v1/links/{code} is the same on a cache hit
+	Description *string        `json:"description,omitempty"`
+	Metadata    map[string]any `json:"metadata,omitempty"`
+	// ParamRules are needed to redirect parameterized links
+	ParamRules map[string]string `json:"param_rules,omitempty"`
+	// Redirect rate limit, enforced with CountRedirects
+	RateLimit       *int   `json:"rate_limit,omitempty"`
+	RateLimitWindow string `json:"rate_limit_window,omitempty"`
+	// NoIndex sets X-Robots-Tag on the redirect
+	NoIndex bool `json:"noindex,omitempty"`
 }
 
 func NewLinkCache(client *redis.Client) *LinkCache {
 	return &LinkCache{client: client}
 }
 
+// UseShards moves cached links and pending click deltas, the keys that grow
+// with the number of hot links, from the main client to shards. Both keys
+// of a code go to the same shard, along with that shard's own clicksDirty
+// set. Daily counts, leaderboards and everything else stay on the main
+// client.
+func (c *LinkCache) UseShards(shards *Shards) {
+	c.shards = shards
+}
+
+// linkClient returns the client holding code's cached link and pending
+// clicks
+func (c *LinkCache) linkClient(code string) *redis.Client {
+	if c.shards == nil {
+		return c.client
+	}
+	return c.shards.For(code)
+}
+
+// linkClients returns every client holding cached links
+func (c *LinkCache) linkClients() []*redis.Client {
+	if c.shards == nil {
+		return []*redis.Client{c.client}
+	}
+	return c.shards.Clients()
+}
+
+// byShard groups codes by the client holding them
+func (c *LinkCache) byShard(codes []string) map[*redis.Client][]string {
+	groups := make(map[*redis.Client][]string)
+	for _, code := range codes {
+		client := c.linkClient(code)
+		groups[client] = append(groups[client], code)
+	}
+	return groups
+}
+
+// Get returns the link cached under code. An entry that hasn't changed since
+// it was last read comes back as the same CachedLink, which must not be
+// modified; the same goes for GetMany.
 func (c *LinkCache) Get(ctx context.Context, code string) (*CachedLink, error) {
 	key := "link:" + code
-	val, err := c.client.Get(ctx, key).Result()
+	val, err := c.linkClient(code).Get(ctx, key).Result()
 	if err == redis.Nil {
 		return nil, nil
 	}
 	if err != nil {
 		return nil, err
 	}
+	return c.decoded.decode(key, val)
+}
 
-	var cached CachedLink
-	if err := json.Unmarshal([]byte(val), &cached); err != nil {
-		return nil, err
+func (c *LinkCache) GetMany(ctx context.Context, codes []string) (map[string]*CachedLink, error) {
+	found := make(map[string]*CachedLink)
+	if len(codes) == 0 {
+		return found, nil
 	}
-
-	return &cached, nil
+	for client, codes := range c.byShard(codes) {
+		keys := make([]string, len(codes))
+		for i, code := range codes {
+			keys[i] = "link:" + code
+		}
+		vals, err := client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, err
+		}
+		for i, v := range vals {
+			s, ok := v.(string)
+			if !ok {
+				continue
+			}
+			cached, err := c.decoded.decode(keys[i], s)
+			if err != nil {
+				// Treated as a miss; the entry is rewritten from the DB
+				continue
+			}
+			found[codes[i]] = cached
+		}
+	}
+	return found, nil
 }
 
 func (c *LinkCache) Set(ctx context.Context, code string, link *CachedLink, ttl time.Duration) error {
@@ -58,30 +194,392 @@ func (c *LinkCache) Set(ctx context.Context, code string, link *CachedLink, ttl
 		return err
 	}
 
-	return c.client.Set(ctx, key, data, ttl).Err()
+	return c.linkClient(code).Set(ctx, key, data, ttl).Err()
 }
 
 func (c *LinkCache) Delete(ctx context.Context, code string) error {
 	key := "link:" + code
-	return c.client.Del(ctx, key).Err()
+	return c.linkClient(code).Del(ctx, key).Err()
+}
+
+// purgeBatch is the SCAN page size used by Purge
+const purgeBatch = 500
+
+func (c *LinkCache) Purge(ctx context.Context, code string, prefix bool) (int64, error) {
+	if !prefix {
+		return c.linkClient(code).Del(ctx, "link:"+code).Result()
+	}
+	var purged int64
+	for _, client := range c.linkClients() {
+		n, err := purgeMatching(ctx, client, "link:"+escapeGlob(code)+"*")
+		purged += n
+		if err != nil {
+			return purged, err
+		}
+	}
+	return purged, nil
+}
+
+// purgeMatching unlinks the keys of client matching pattern
+func purgeMatching(ctx context.Context, client *redis.Client, pattern string) (int64, error) {
+	var purged int64
+	iter := client.Scan(ctx, 0, pattern, purgeBatch).Iterator()
+	keys := make([]string, 0, purgeBatch)
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == purgeBatch {
+			n, err := client.Unlink(ctx, keys...).Result()
+			if err != nil {
+				return purged, err
+			}
+			purged += n
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return purged, err
+	}
+	if len(keys) > 0 {
+		n, err := client.Unlink(ctx, keys...).Result()
+		if err != nil {
+			return purged, err
+		}
+		purged += n
+	}
+	return purged, nil
+}
+
+// escapeGlob quotes the characters SCAN MATCH treats as wildcards
+func escapeGlob(s string) string {
+	return globChars.Replace(s)
+}
+
+var globChars = strings.NewReplacer(`\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`, "]", `\]`)
+
+// Pending click deltas live in clicks_pending:{code}; codes with a delta are
+// members of clicksDirty. A click increments the delta and marks the code in
+// one transaction, and a sync pops codes from the set before reading their
+// deltas with GETDEL, so a click racing a sync is either in the delta it
+// reads or re-marks the code for the next one. With shards, each shard has
+// its own set, marking the codes whose deltas it holds.
+const clicksDirty = "clicks_dirty"
+
+func pendingClicksKey(code string) string {
+	return "clicks_pending:" + code
+}
+
+func (c *LinkCache) IncrementClick(ctx context.Context, code string) error {
+	pipe := c.linkClient(code).TxPipeline()
+	pipe.Incr(ctx, pendingClicksKey(code))
+	pipe.SAdd(ctx, clicksDirty, code)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *LinkCache) TakeClickDeltas(ctx context.Context, limit int) (map[string]int64, error) {
+	deltas := make(map[string]int64)
+	var errs []error
+	// A shard that fails doesn't hold up the deltas of the others
+	for _, client := range c.linkClients() {
+		if len(deltas) >= limit {
+			break
+		}
+		if err := takeClickDeltas(ctx, client, limit-len(deltas), deltas); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return deltas, errors.Join(errs...)
+}
+
+// takeClickDeltas adds up to limit deltas held by client to deltas
+func takeClickDeltas(ctx context.Context, client *redis.Client, limit int, deltas map[string]int64) error {
+	codes, err := client.SPopN(ctx, clicksDirty, int64(limit)).Result()
+	if err != nil {
+		return err
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	pipe := client.Pipeline()
+	results := make([]*redis.StringCmd, len(codes))
+	for i, code := range codes {
+		results[i] = pipe.GetDel(ctx, pendingClicksKey(code))
+	}
+	pipe.Exec(ctx)
+
+	var failed []string
+	var lastErr error
+	for i, r := range results {
+		n, err := r.Int64()
+		switch {
+		case err == redis.Nil:
+		case err != nil:
+			failed = append(failed, codes[i])
+			lastErr = err
+		case n > 0:
+			deltas[codes[i]] = n
+		}
+	}
+	if len(failed) > 0 {
+		// Those deltas are still in place; mark them for the next sync
+		if err := client.SAdd(ctx, clicksDirty, failed).Err(); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+func (c *LinkCache) ReturnClickDeltas(ctx context.Context, deltas map[string]int64) error {
+	codes := make([]string, 0, len(deltas))
+	for code := range deltas {
+		codes = append(codes, code)
+	}
+	for client, codes := range c.byShard(codes) {
+		pipe := client.TxPipeline()
+		for _, code := range codes {
+			pipe.IncrBy(ctx, pendingClicksKey(code), deltas[code])
+			pipe.SAdd(ctx, clicksDirty, code)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dailyClicksKey(day time.Time) string {
+	return "clicks_daily:" + day.UTC().Format("2006-01-02")
 }
 
-func (c *LinkCache) IncrementClick(ctx context.Context, code string) (int64, error) {
-	key := "clicks:" + code
-	return c.client.Incr(ctx, key).Result()
+func (c *LinkCache) IncrementDailyClick(ctx context.Context, code string, at time.Time) error {
+	key := dailyClicksKey(at)
+	pipe := c.client.Pipeline()
+	pipe.HIncrBy(ctx, key, code, 1)
+	pipe.Expire(ctx, key, dailyClicksTTL)
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
-func (c *LinkCache) GetClickCount(ctx context.Context, code string) (int64, error) {
-	key := "clicks:" + code
-	return c.client.Get(ctx, key).Int64()
+func (c *LinkCache) GetDailyClicks(ctx context.Context, codes []string, day time.Time) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	if len(codes) == 0 {
+		return counts, nil
+	}
+	vals, err := c.client.HMGet(ctx, dailyClicksKey(day), codes...).Result()
+	if err != nil {
+		return nil, err
+	}
+	for i, v := range vals {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil && n > 0 {
+			counts[codes[i]] = n
+		}
+	}
+	return counts, nil
+}
+
+// AllDailyClicks returns the clicks per code for the UTC day containing day,
+// for every code clicked that day
+func (c *LinkCache) AllDailyClicks(ctx context.Context, day time.Time) (map[string]int64, error) {
+	counts := make(map[string]int64)
+	iter := c.client.HScan(ctx, dailyClicksKey(day), 0, "", 1000).Iterator()
+	for iter.Next(ctx) {
+		code := iter.Val()
+		if !iter.Next(ctx) {
+			break
+		}
+		if n, err := strconv.ParseInt(iter.Val(), 10, 64); err == nil && n > 0 {
+			counts[code] = n
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+	return counts, nil
 }
 
-func (c *LinkCache) SetClickCount(ctx context.Context, code string, count int64, ttl time.Duration) error {
-	key := "clicks:" + code
-	return c.client.Set(ctx, key, count, ttl).Err()
+// TopLink is a leaderboard entry
+type TopLink struct {
+	Code string `json:"code"`
+	// Domain is set for links on a custom short domain
+	Domain string `json:"domain,omitempty"`
+	Clicks int64  `json:"clicks"`
+}
+
+// Leaderboards are sorted sets of clicks per code in hourly and daily
+// buckets, top:{scope}:h:{hour} and top:{scope}:d:{day}, where scope is an
+// owner ID or "all". A period is the union of its buckets, kept for a
+// minute in top:{scope}:{period} so dashboards polling it stay cheap.
+const (
+	topHourTTL  = 26 * time.Hour
+	topDayTTL   = 31 * 24 * time.Hour
+	topCacheTTL = time.Minute
+)
+
+// TopPeriods are the periods TopLinks accepts
+var TopPeriods = []string{"24h", "7d", "30d"}
+
+func topScope(ownerID *uuid.UUID) string {
+	if ownerID == nil {
+		return "all"
+	}
+	return ownerID.String()
+}
+
+func topHourKey(scope string, at time.Time) string {
+	return "top:" + scope + ":h:" + at.UTC().Format("2006010215")
+}
+
+func topDayKey(scope string, at time.Time) string {
+	return "top:" + scope + ":d:" + at.UTC().Format("20060102")
+}
+
+// topBuckets lists the bucket keys making up period, or nil for an unknown
+// period
+func topBuckets(scope, period string, now time.Time) []string {
+	var keys []string
+	switch period {
+	case "24h":
+		for i := 0; i < 24; i++ {
+			keys = append(keys, topHourKey(scope, now.Add(-time.Duration(i)*time.Hour)))
+		}
+	case "7d", "30d":
+		days := 7
+		if period == "30d" {
+			days = 30
+		}
+		for i := 0; i < days; i++ {
+			keys = append(keys, topDayKey(scope, now.AddDate(0, 0, -i)))
+		}
+	}
+	return keys
+}
+
+func (c *LinkCache) RecordTopClick(ctx context.Context, code string, ownerID *uuid.UUID, at time.Time) error {
+	scopes := []string{"all"}
+	if ownerID != nil {
+		scopes = append(scopes, topScope(ownerID))
+	}
+	pipe := c.client.Pipeline()
+	for _, scope := range scopes {
+		hour, day := topHourKey(scope, at), topDayKey(scope, at)
+		pipe.ZIncrBy(ctx, hour, 1, code)
+		pipe.Expire(ctx, hour, topHourTTL)
+		pipe.ZIncrBy(ctx, day, 1, code)
+		pipe.Expire(ctx, day, topDayTTL)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
 }
 
-func (c *LinkCache) ExpireClickCount(ctx context.Context, code string, ttl time.Duration) error {
-	key := "clicks:" + code
-	return c.client.Expire(ctx, key, ttl).Err()
+func (c *LinkCache) RecordClicks(ctx context.Context, clicks []*StreamedClick) error {
+	type bucket struct {
+		key string
+		ttl time.Duration
+	}
+	daily := make(map[string]map[string]int64)
+	top := make(map[bucket]map[string]int64)
+	add := func(counts map[string]int64, code string) map[string]int64 {
+		if counts == nil {
+			counts = make(map[string]int64)
+		}
+		counts[code]++
+		return counts
+	}
+	for _, click := range clicks {
+		day := dailyClicksKey(click.At)
+		daily[day] = add(daily[day], click.Key)
+		scopes := []string{"all"}
+		if click.OwnerID != nil {
+			scopes = append(scopes, topScope(click.OwnerID))
+		}
+		for _, scope := range scopes {
+			hour, day := bucket{topHourKey(scope, click.At), topHourTTL}, bucket{topDayKey(scope, click.At), topDayTTL}
+			top[hour] = add(top[hour], click.Key)
+			top[day] = add(top[day], click.Key)
+		}
+	}
+
+	pipe := c.client.Pipeline()
+	for key, counts := range daily {
+		for code, n := range counts {
+			pipe.HIncrBy(ctx, key, code, n)
+		}
+		pipe.Expire(ctx, key, dailyClicksTTL)
+	}
+	for b, counts := range top {
+		for code, n := range counts {
+			pipe.ZIncrBy(ctx, b.key, float64(n), code)
+		}
+		pipe.Expire(ctx, b.key, b.ttl)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (c *LinkCache) TopLinks(ctx context.Context, ownerID *uuid.UUID, period string, now time.Time, limit int) ([]TopLink, error) {
+	scope := topScope(ownerID)
+	buckets := topBuckets(scope, period, now)
+	if buckets == nil {
+		return nil, fmt.Errorf("unknown leaderboard period %q", period)
+	}
+	key := "top:" + scope + ":" + period
+	n, err := c.client.Exists(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		pipe := c.client.TxPipeline()
+		pipe.ZUnionStore(ctx, key, &redis.ZStore{Keys: buckets})
+		pipe.Expire(ctx, key, topCacheTTL)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	entries, err := c.client.ZRevRangeWithScores(ctx, key, 0, int64(limit-1)).Result()
+	if err != nil {
+		return nil, err
+	}
+	top := make([]TopLink, 0, len(entries))
+	for _, e := range entries {
+		code, _ := e.Member.(string)
+		top = append(top, TopLink{Code: code, Clicks: int64(e.Score)})
+	}
+	return top, nil
+}
+
+func (c *LinkCache) MarkVisit(ctx context.Context, code string, keys []string, window time.Duration) (bool, error) {
+	pipe := c.client.Pipeline()
+	results := make([]*redis.BoolCmd, len(keys))
+	for i, key := range keys {
+		results[i] = pipe.SetNX(ctx, "visit:"+code+":"+key, 1, window)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	first := true
+	for _, r := range results {
+		if !r.Val() {
+			first = false
+		}
+	}
+	return first, nil
+}
+
+// CountRedirects uses fixed windows aligned to the Unix epoch, each counted
+// under its own key that expires with the window
+func (c *LinkCache) CountRedirects(ctx context.Context, code string, window time.Duration, now time.Time, n int64) (int64, error) {
+	start := now.Truncate(window)
+	key := "ratelimit:" + code + ":" + strconv.FormatInt(start.Unix(), 10)
+	pipe := c.client.Pipeline()
+	count := pipe.IncrBy(ctx, key, n)
+	pipe.ExpireAt(ctx, key, start.Add(window))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return count.Val(), nil
 }
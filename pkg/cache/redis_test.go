@@ -0,0 +1,68 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTopBuckets(t *testing.T) {
+	now := time.Date(2026, 3, 1, 5, 30, 0, 0, time.UTC)
+
+	hours := topBuckets("all", "24h", now)
+	assert.Len(t, hours, 24)
+	assert.Equal(t, "top:all:h:2026030105", hours[0])
+	assert.Equal(t, "top:all:h:2026022806", hours[23])
+
+	days := topBuckets("all", "7d", now)
+	assert.Len(t, days, 7)
+	assert.Equal(t, "top:all:d:20260301", days[0])
+	assert.Equal(t, "top:all:d:20260223", days[6])
+
+	assert.Len(t, topBuckets("all", "30d", now), 30)
+	assert.Nil(t, topBuckets("all", "1y", now))
+}
+
+func TestRetainedDays(t *testing.T) {
+	now := time.Date(2026, 3, 1, 5, 30, 0, 0, time.UTC)
+	days := retainedDays(now)
+	require.Len(t, days, 36)
+	assert.Equal(t, "clicks_daily:2026-03-01", dailyClicksKey(days[0]))
+	assert.Equal(t, "clicks_daily:2026-01-25", dailyClicksKey(days[35]))
+}
+
+func TestEscapeGlob(t *testing.T) {
+	assert.Equal(t, `promo\*\?\[x\]`, escapeGlob("promo*?[x]"))
+}
+
+func TestDecodedLinks(t *testing.T) {
+	var d decodedLinks
+	first, err := d.decode("link:abc", `{"long_url":"https://example.com/a"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/a", first.LongURL)
+
+	again, err := d.decode("link:abc", `{"long_url":"https://example.com/a"}`)
+	require.NoError(t, err)
+	assert.Same(t, first, again)
+
+	changed, err := d.decode("link:abc", `{"long_url":"https://example.com/b"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/b", changed.LongURL)
+
+	_, err = d.decode("link:xyz", `{`)
+	assert.Error(t, err)
+}
+
+func BenchmarkDecodeLink(b *testing.B) {
+	raw := `{"long_url":"https://example.com/landing?utm_source=newsletter","has_password":false,"expires_at":null,"max_clicks":null,"owner_id":"9b2f6a8e-1c1f-4c55-9a57-0e3f0f5b1a2d","exclude_user_agents":["UptimeRobot"]}`
+	var d decodedLinks
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := d.decode("link:abc", raw); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
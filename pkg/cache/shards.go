@@ -0,0 +1,112 @@
+package cache
+
+import (
+	"errors"
+	"hash/fnv"
+	"slices"
+	"sort"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// shardPoints is how many points each shard owns on the hash ring; more
+// points spread keys more evenly
+const shardPoints = 160
+
+// Shards spreads keys over several Redis instances with consistent hashing.
+// Each instance owns shardPoints points on a hash ring, named after its
+// address, and a key belongs to the instance owning the first point at or
+// after the key's hash. Adding an instance only moves the keys that now land
+// on its points, about 1/n of them, and the order the instances are listed
+// in doesn't matter.
+type Shards struct {
+	clients []*redis.Client
+	points  []uint32
+	owners  []int
+}
+
+// NewShards builds a ring over clients, which must be at distinct addresses
+func NewShards(clients []*redis.Client) *Shards {
+	s := &Shards{clients: clients}
+	type point struct {
+		hash  uint32
+		owner int
+	}
+	points := make([]point, 0, len(clients)*shardPoints)
+	for i, client := range clients {
+		name := shardName(client)
+		for p := 0; p < shardPoints; p++ {
+			points = append(points, point{ringHash(name + "#" + strconv.Itoa(p)), i})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash != points[j].hash {
+			return points[i].hash < points[j].hash
+		}
+		return points[i].owner < points[j].owner
+	})
+	for _, p := range points {
+		s.points = append(s.points, p.hash)
+		s.owners = append(s.owners, p.owner)
+	}
+	return s
+}
+
+// DialShards connects to the Redis instances at urls, such as
+// redis://cache-1:6379/0, and builds a ring over them
+func DialShards(urls []string) (*Shards, error) {
+	clients := make([]*redis.Client, 0, len(urls))
+	for _, url := range urls {
+		opt, err := redis.ParseURL(url)
+		if err != nil {
+			for _, client := range clients {
+				client.Close()
+			}
+			return nil, err
+		}
+		clients = append(clients, redis.NewClient(opt))
+	}
+	return NewShards(clients), nil
+}
+
+// AddHook adds hook to every shard's client
+func (s *Shards) AddHook(hook redis.Hook) {
+	for _, client := range s.clients {
+		client.AddHook(hook)
+	}
+}
+
+func shardName(client *redis.Client) string {
+	opt := client.Options()
+	return opt.Addr + "/" + strconv.Itoa(opt.DB)
+}
+
+func ringHash(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
+
+// For returns the client of the shard key belongs to
+func (s *Shards) For(key string) *redis.Client {
+	h := ringHash(key)
+	i := sort.Search(len(s.points), func(i int) bool { return s.points[i] >= h })
+	if i == len(s.points) {
+		i = 0
+	}
+	return s.clients[s.owners[i]]
+}
+
+// Clients returns the client of every shard
+func (s *Shards) Clients() []*redis.Client {
+	return slices.Clone(s.clients)
+}
+
+func (s *Shards) Close() error {
+	var errs []error
+	for _, client := range s.clients {
+		errs = append(errs, client.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,51 @@
+package cache
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func shardClients(addrs ...string) []*redis.Client {
+	clients := make([]*redis.Client, len(addrs))
+	for i, addr := range addrs {
+		clients[i] = redis.NewClient(&redis.Options{Addr: addr})
+	}
+	return clients
+}
+
+func TestShards(t *testing.T) {
+	clients := shardClients("cache-1:6379", "cache-2:6379", "cache-3:6379")
+	shards := NewShards(clients)
+	reordered := NewShards([]*redis.Client{clients[2], clients[0], clients[1]})
+
+	counts := make(map[*redis.Client]int)
+	for i := 0; i < 3000; i++ {
+		key := "code" + strconv.Itoa(i)
+		client := shards.For(key)
+		counts[client]++
+		assert.Same(t, client, reordered.For(key), key)
+	}
+	for _, client := range clients {
+		assert.Greater(t, counts[client], 600, client.Options().Addr)
+	}
+}
+
+func TestShardsGrow(t *testing.T) {
+	clients := shardClients("cache-1:6379", "cache-2:6379", "cache-3:6379", "cache-4:6379")
+	before := NewShards(clients[:3])
+	after := NewShards(clients)
+
+	moved := 0
+	for i := 0; i < 4000; i++ {
+		key := "code" + strconv.Itoa(i)
+		if from, to := before.For(key), after.For(key); from != to {
+			// Keys only move to the new shard
+			assert.Same(t, clients[3], to, key)
+			moved++
+		}
+	}
+	assert.InDelta(t, 1000, moved, 300)
+}
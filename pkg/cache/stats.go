@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StatsEntry is a computed stats response and when it was computed
+type StatsEntry struct {
+	Data       json.RawMessage `json:"data"`
+	ComputedAt time.Time       `json:"computed_at"`
+}
+
+type StatsCacheInterface interface {
+	// GetStats returns the entry stored under key, or nil if there is none
+	GetStats(ctx context.Context, key string) (*StatsEntry, error)
+	// SetStats stores entry under key for ttl
+	SetStats(ctx context.Context, key string, entry *StatsEntry, ttl time.Duration) error
+	// ClaimStatsRefresh reports whether the caller may recompute the entry
+	// under key, which no one else may for ttl or until it is stored
+	ClaimStatsRefresh(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// StatsCache keeps computed stats responses, such as public stats pages and
+// digests, in stats:{key}
+type StatsCache struct {
+	client *redis.Client
+}
+
+func NewStatsCache(client *redis.Client) *StatsCache {
+	return &StatsCache{client: client}
+}
+
+func (c *StatsCache) GetStats(ctx context.Context, key string) (*StatsEntry, error) {
+	val, err := c.client.Get(ctx, "stats:"+key).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entry StatsEntry
+	if err := json.Unmarshal(val, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (c *StatsCache) SetStats(ctx context.Context, key string, entry *StatsEntry, ttl time.Duration) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	pipe := c.client.TxPipeline()
+	pipe.Set(ctx, "stats:"+key, data, ttl)
+	pipe.Del(ctx, "stats_refresh:"+key)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (c *StatsCache) ClaimStatsRefresh(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return c.client.SetNX(ctx, "stats_refresh:"+key, 1, ttl).Result()
+}
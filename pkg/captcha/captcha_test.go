@@ -0,0 +1,49 @@
+package captcha
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewProvider(t *testing.T) {
+	p, err := NewProvider(Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+
+	_, err = NewProvider(Config{Provider: "turnstile", SiteKey: "site"})
+	assert.Error(t, err)
+	_, err = NewProvider(Config{Provider: "recaptcha", SiteKey: "site", SecretKey: "secret"})
+	assert.Error(t, err)
+
+	p, err = NewProvider(Config{Provider: "hcaptcha", SiteKey: `si"te`, SecretKey: "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, "h-captcha-response", p.ResponseField())
+	assert.Contains(t, string(p.Widget()), `class="h-captcha" data-sitekey="si&#34;te"`)
+}
+
+func TestVerify(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "secret", r.FormValue("secret"))
+		assert.Equal(t, "203.0.113.9", r.FormValue("remoteip"))
+		if r.FormValue("response") == "solved" {
+			w.Write([]byte(`{"success":true}`))
+		} else {
+			w.Write([]byte(`{"success":false,"error-codes":["invalid-input-response"]}`))
+		}
+	}))
+	defer srv.Close()
+
+	p, err := NewProvider(Config{Provider: "turnstile", SiteKey: "site", SecretKey: "secret"})
+	require.NoError(t, err)
+	p.(*siteVerify).verifyURL = srv.URL
+
+	ctx := context.Background()
+	assert.NoError(t, p.Verify(ctx, "solved", "203.0.113.9"))
+	assert.ErrorIs(t, p.Verify(ctx, "guessed", "203.0.113.9"), ErrFailed)
+	assert.ErrorIs(t, p.Verify(ctx, "", "203.0.113.9"), ErrFailed)
+}
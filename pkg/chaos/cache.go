@@ -0,0 +1,146 @@
+package chaos
+
+import (
+	"context"
+	"time"
+
+	"url-shortener/pkg/cache"
+
+	"github.com/google/uuid"
+)
+
+// LinkCache injects faults into every call to the link cache it wraps
+type LinkCache struct {
+	cache    cache.LinkCacheInterface
+	injector *Injector
+}
+
+func NewLinkCache(linkCache cache.LinkCacheInterface, injector *Injector) *LinkCache {
+	return &LinkCache{cache: linkCache, injector: injector}
+}
+
+func (c *LinkCache) Get(ctx context.Context, code string) (*cache.CachedLink, error) {
+	if err := c.injector.inject(ctx); err != nil {
+		return nil, err
+	}
+	return c.cache.Get(ctx, code)
+}
+
+func (c *LinkCache) GetMany(ctx context.Context, codes []string) (map[string]*cache.CachedLink, error) {
+	if err := c.injector.inject(ctx); err != nil {
+		return nil, err
+	}
+	return c.cache.GetMany(ctx, codes)
+}
+
+func (c *LinkCache) Set(ctx context.Context, code string, link *cache.CachedLink, ttl time.Duration) error {
+	if err := c.injector.inject(ctx); err != nil {
+		return err
+	}
+	return c.cache.Set(ctx, code, link, ttl)
+}
+
+func (c *LinkCache) Delete(ctx context.Context, code string) error {
+	if err := c.injector.inject(ctx); err != nil {
+		return err
+	}
+	return c.cache.Delete(ctx, code)
+}
+
+func (c *LinkCache) Purge(ctx context.Context, code string, prefix bool) (int64, error) {
+	if err := c.injector.inject(ctx); err != nil {
+		return 0, err
+	}
+	return c.cache.Purge(ctx, code, prefix)
+}
+
+func (c *LinkCache) IncrementClick(ctx context.Context, code string) error {
+	if err := c.injector.inject(ctx); err != nil {
+		return err
+	}
+	return c.cache.IncrementClick(ctx, code)
+}
+
+func (c *LinkCache) TakeClickDeltas(ctx context.Context, limit int) (map[string]int64, error) {
+	if err := c.injector.inject(ctx); err != nil {
+		return nil, err
+	}
+	return c.cache.TakeClickDeltas(ctx, limit)
+}
+
+func (c *LinkCache) ReturnClickDeltas(ctx context.Context, deltas map[string]int64) error {
+	if err := c.injector.inject(ctx); err != nil {
+		return err
+	}
+	return c.cache.ReturnClickDeltas(ctx, deltas)
+}
+
+func (c *LinkCache) IncrementDailyClick(ctx context.Context, code string, at time.Time) error {
+	if err := c.injector.inject(ctx); err != nil {
+		return err
+	}
+	return c.cache.IncrementDailyClick(ctx, code, at)
+}
+
+func (c *LinkCache) GetDailyClicks(ctx context.Context, codes []string, day time.Time) (map[string]int64, error) {
+	if err := c.injector.inject(ctx); err != nil {
+		return nil, err
+	}
+	return c.cache.GetDailyClicks(ctx, codes, day)
+}
+
+func (c *LinkCache) RecordTopClick(ctx context.Context, code string, ownerID *uuid.UUID, at time.Time) error {
+	if err := c.injector.inject(ctx); err != nil {
+		return err
+	}
+	return c.cache.RecordTopClick(ctx, code, ownerID, at)
+}
+
+func (c *LinkCache) RecordClicks(ctx context.Context, clicks []*cache.StreamedClick) error {
+	if err := c.injector.inject(ctx); err != nil {
+		return err
+	}
+	return c.cache.RecordClicks(ctx, clicks)
+}
+
+func (c *LinkCache) TopLinks(ctx context.Context, ownerID *uuid.UUID, period string, now time.Time, limit int) ([]cache.TopLink, error) {
+	if err := c.injector.inject(ctx); err != nil {
+		return nil, err
+	}
+	return c.cache.TopLinks(ctx, ownerID, period, now, limit)
+}
+
+func (c *LinkCache) MarkVisit(ctx context.Context, code string, keys []string, window time.Duration) (bool, error) {
+	if err := c.injector.inject(ctx); err != nil {
+		return false, err
+	}
+	return c.cache.MarkVisit(ctx, code, keys, window)
+}
+
+func (c *LinkCache) CountRedirects(ctx context.Context, code string, window time.Duration, now time.Time, n int64) (int64, error) {
+	if err := c.injector.inject(ctx); err != nil {
+		return 0, err
+	}
+	return c.cache.CountRedirects(ctx, code, window, now, n)
+}
+
+func (c *LinkCache) ForgetLink(ctx context.Context, code string, ownerID *uuid.UUID, now time.Time) error {
+	if err := c.injector.inject(ctx); err != nil {
+		return err
+	}
+	return c.cache.ForgetLink(ctx, code, ownerID, now)
+}
+
+func (c *LinkCache) ForgetLinks(ctx context.Context, codes []string, now time.Time) error {
+	if err := c.injector.inject(ctx); err != nil {
+		return err
+	}
+	return c.cache.ForgetLinks(ctx, codes, now)
+}
+
+func (c *LinkCache) ClickedLinks(ctx context.Context, now time.Time) ([]string, error) {
+	if err := c.injector.inject(ctx); err != nil {
+		return nil, err
+	}
+	return c.cache.ClickedLinks(ctx, now)
+}
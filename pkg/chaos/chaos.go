@@ -0,0 +1,67 @@
+// Package chaos injects faults into link storage and the link cache, so
+// that staging environments can check that degraded modes, circuit
+// breakers and alerts work before an outage tests them. LinkStorage and
+// LinkCache wrap the real ones and, on every call, wait for the configured
+// latency and then fail the configured share of calls with ErrInjected,
+// without making them. The servers only wrap them with FAULT_INJECTION set.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// ErrInjected fails the calls chosen to fail
+var ErrInjected = errors.New("chaos: injected fault")
+
+// Faults are what an Injector does to each call
+type Faults struct {
+	// Latency delays every call
+	Latency time.Duration
+	// ErrorPercent is the percentage of calls failed
+	ErrorPercent int
+}
+
+// Injector injects faults, which can be changed while calls are made
+type Injector struct {
+	faults atomic.Pointer[Faults]
+}
+
+// NewInjector injects no faults until Set
+func NewInjector() *Injector {
+	i := &Injector{}
+	i.faults.Store(&Faults{})
+	return i
+}
+
+// Set changes the faults injected into the calls made from now on
+func (i *Injector) Set(faults Faults) {
+	i.faults.Store(&faults)
+}
+
+// Faults are the faults being injected
+func (i *Injector) Faults() Faults {
+	return *i.faults.Load()
+}
+
+// inject delays a call and returns ErrInjected if it should fail, or
+// ctx's error if it is done while waiting
+func (i *Injector) inject(ctx context.Context) error {
+	faults := i.faults.Load()
+	if faults.Latency > 0 {
+		timer := time.NewTimer(faults.Latency)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if faults.ErrorPercent > 0 && rand.IntN(100) < faults.ErrorPercent {
+		return ErrInjected
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package chaos
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingLinks struct {
+	storage.LinkStorage
+	calls int
+}
+
+func (c *countingLinks) GetByCode(ctx context.Context, key string) (*storage.Link, error) {
+	c.calls++
+	return &storage.Link{Code: key}, nil
+}
+
+type countingCache struct {
+	cache.LinkCacheInterface
+	calls int
+}
+
+func (c *countingCache) IncrementClick(ctx context.Context, code string) error {
+	c.calls++
+	return nil
+}
+
+func TestInjectsErrors(t *testing.T) {
+	ctx := context.Background()
+	injector := NewInjector()
+	links := &countingLinks{}
+	wrapped := NewLinkStorage(links, injector)
+
+	link, err := wrapped.GetByCode(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", link.Code)
+
+	injector.Set(Faults{ErrorPercent: 100})
+	_, err = wrapped.GetByCode(ctx, "abc")
+	assert.ErrorIs(t, err, ErrInjected)
+	assert.Equal(t, 1, links.calls, "failed calls aren't made")
+
+	clicks := &countingCache{}
+	assert.ErrorIs(t, NewLinkCache(clicks, injector).IncrementClick(ctx, "abc"), ErrInjected)
+	assert.Zero(t, clicks.calls)
+}
+
+func TestInjectsLatency(t *testing.T) {
+	injector := NewInjector()
+	injector.Set(Faults{Latency: 20 * time.Millisecond})
+	wrapped := NewLinkCache(&countingCache{}, injector)
+
+	start := time.Now()
+	require.NoError(t, wrapped.IncrementClick(context.Background(), "abc"))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	// Callers giving up aren't kept waiting
+	injector.Set(Faults{Latency: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, wrapped.IncrementClick(ctx, "abc"), context.DeadlineExceeded)
+}
@@ -0,0 +1,154 @@
+package chaos
+
+import (
+	"context"
+	"time"
+
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// LinkStorage injects faults into every call to the link storage it wraps
+type LinkStorage struct {
+	links    storage.LinkStorage
+	injector *Injector
+}
+
+func NewLinkStorage(links storage.LinkStorage, injector *Injector) *LinkStorage {
+	return &LinkStorage{links: links, injector: injector}
+}
+
+func (s *LinkStorage) Create(ctx context.Context, link *storage.Link) error {
+	if err := s.injector.inject(ctx); err != nil {
+		return err
+	}
+	return s.links.Create(ctx, link)
+}
+
+func (s *LinkStorage) CreateTx(ctx context.Context, tx pgx.Tx, link *storage.Link) error {
+	if err := s.injector.inject(ctx); err != nil {
+		return err
+	}
+	return s.links.CreateTx(ctx, tx, link)
+}
+
+func (s *LinkStorage) GetByCode(ctx context.Context, key string) (*storage.Link, error) {
+	if err := s.injector.inject(ctx); err != nil {
+		return nil, err
+	}
+	return s.links.GetByCode(ctx, key)
+}
+
+func (s *LinkStorage) GetByCodeTx(ctx context.Context, tx pgx.Tx, key string) (*storage.Link, error) {
+	if err := s.injector.inject(ctx); err != nil {
+		return nil, err
+	}
+	return s.links.GetByCodeTx(ctx, tx, key)
+}
+
+func (s *LinkStorage) GetByCodes(ctx context.Context, keys []string) ([]*storage.Link, error) {
+	if err := s.injector.inject(ctx); err != nil {
+		return nil, err
+	}
+	return s.links.GetByCodes(ctx, keys)
+}
+
+func (s *LinkStorage) Update(ctx context.Context, link *storage.Link) error {
+	if err := s.injector.inject(ctx); err != nil {
+		return err
+	}
+	return s.links.Update(ctx, link)
+}
+
+func (s *LinkStorage) UpdateTx(ctx context.Context, tx pgx.Tx, link *storage.Link) error {
+	if err := s.injector.inject(ctx); err != nil {
+		return err
+	}
+	return s.links.UpdateTx(ctx, tx, link)
+}
+
+func (s *LinkStorage) Delete(ctx context.Context, key string) error {
+	if err := s.injector.inject(ctx); err != nil {
+		return err
+	}
+	return s.links.Delete(ctx, key)
+}
+
+func (s *LinkStorage) DeleteTx(ctx context.Context, tx pgx.Tx, key string) error {
+	if err := s.injector.inject(ctx); err != nil {
+		return err
+	}
+	return s.links.DeleteTx(ctx, tx, key)
+}
+
+func (s *LinkStorage) LastRetiredTx(ctx context.Context, tx pgx.Tx, key string) (*time.Time, error) {
+	if err := s.injector.inject(ctx); err != nil {
+		return nil, err
+	}
+	return s.links.LastRetiredTx(ctx, tx, key)
+}
+
+func (s *LinkStorage) RetireExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	if err := s.injector.inject(ctx); err != nil {
+		return 0, err
+	}
+	return s.links.RetireExpired(ctx, before, limit)
+}
+
+func (s *LinkStorage) GetCreation(ctx context.Context, key string) (*storage.CreationContext, error) {
+	if err := s.injector.inject(ctx); err != nil {
+		return nil, err
+	}
+	return s.links.GetCreation(ctx, key)
+}
+
+func (s *LinkStorage) AddClickCount(ctx context.Context, key string, n int64) error {
+	if err := s.injector.inject(ctx); err != nil {
+		return err
+	}
+	return s.links.AddClickCount(ctx, key, n)
+}
+
+func (s *LinkStorage) ReplacePasswordHash(ctx context.Context, key, old, new string) error {
+	if err := s.injector.inject(ctx); err != nil {
+		return err
+	}
+	return s.links.ReplacePasswordHash(ctx, key, old, new)
+}
+
+func (s *LinkStorage) ListByOwner(ctx context.Context, ownerID uuid.UUID, query storage.LinkQuery, limit, offset int) ([]*storage.Link, error) {
+	if err := s.injector.inject(ctx); err != nil {
+		return nil, err
+	}
+	return s.links.ListByOwner(ctx, ownerID, query, limit, offset)
+}
+
+func (s *LinkStorage) GetTags(ctx context.Context, keys []string) (map[string][]string, error) {
+	if err := s.injector.inject(ctx); err != nil {
+		return nil, err
+	}
+	return s.links.GetTags(ctx, keys)
+}
+
+func (s *LinkStorage) SetTags(ctx context.Context, key string, tags []string) error {
+	if err := s.injector.inject(ctx); err != nil {
+		return err
+	}
+	return s.links.SetTags(ctx, key, tags)
+}
+
+func (s *LinkStorage) SetTagsTx(ctx context.Context, tx pgx.Tx, key string, tags []string) error {
+	if err := s.injector.inject(ctx); err != nil {
+		return err
+	}
+	return s.links.SetTagsTx(ctx, tx, key, tags)
+}
+
+func (s *LinkStorage) ListTagsByOwner(ctx context.Context, ownerID uuid.UUID) ([]string, error) {
+	if err := s.injector.inject(ctx); err != nil {
+		return nil, err
+	}
+	return s.links.ListTagsByOwner(ctx, ownerID)
+}
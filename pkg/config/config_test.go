@@ -0,0 +1,625 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("LINK_CACHE_TTL", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, "info", cfg.LogLevel)
+	assert.Equal(t, 24*time.Hour, cfg.LinkCacheTTL)
+	assert.Equal(t, 5*time.Minute, cfg.NegativeCacheTTL)
+	assert.Equal(t, ":8080", cfg.APIAddr)
+}
+
+func TestLoadConfigFileOverridesEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.env")
+	writeConfigFile(t, path, "# comment\nLOG_LEVEL=debug\nBLOCKED_DOMAINS=evil.com, bad.org\n")
+	t.Setenv("CONFIG_FILE", path)
+	t.Setenv("LOG_LEVEL", "warn")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+
+	assert.Equal(t, "debug", cfg.LogLevel)
+	assert.Equal(t, []string{"evil.com", "bad.org"}, cfg.BlockedDomains)
+}
+
+func TestLoadInvalidDuration(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("LINK_CACHE_TTL", "soon")
+
+	_, err := Load()
+	assert.Error(t, err)
+}
+
+func TestWatcherReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.env")
+	writeConfigFile(t, path, "LOG_LEVEL=info\nAPI_ADDR=:9000\n")
+	t.Setenv("CONFIG_FILE", path)
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	watcher := NewWatcher(cfg)
+
+	var seen []string
+	watcher.Subscribe(func(c *Config) { seen = append(seen, c.LogLevel) })
+
+	writeConfigFile(t, path, "LOG_LEVEL=error\nAPI_ADDR=:9999\n")
+	require.NoError(t, watcher.Reload())
+
+	assert.Equal(t, []string{"info", "error"}, seen)
+	assert.Equal(t, "error", watcher.Current().LogLevel)
+	// Static settings are not changed by a reload
+	assert.Equal(t, ":9000", watcher.Current().APIAddr)
+}
+
+func TestWatcherReloadKeepsConfigOnError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.env")
+	writeConfigFile(t, path, "LOG_LEVEL=warn\n")
+	t.Setenv("CONFIG_FILE", path)
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	watcher := NewWatcher(cfg)
+
+	writeConfigFile(t, path, "not a setting\n")
+	assert.Error(t, watcher.Reload())
+	assert.Equal(t, "warn", watcher.Current().LogLevel)
+}
+
+func TestLoadGRPCClients(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("GRPC_CLIENTS", "billing=links:read links:write; crm=links:read")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"billing": {"links:read", "links:write"},
+		"crm":     {"links:read"},
+	}, cfg.GRPCClients)
+
+	t.Setenv("GRPC_CLIENTS", "no-scopes")
+	_, err = Load()
+	assert.Error(t, err)
+}
+
+func TestLoadClickExclusions(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("CLICK_EXCLUDE_CIDRS", "10.1.2.3/16, 203.0.113.7, 2001:db8::/32")
+	t.Setenv("CLICK_EXCLUDE_USER_AGENTS", "UptimeRobot,qa-bot")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	require.Len(t, cfg.ClickExcludeCIDRs, 3)
+	assert.Equal(t, "10.1.0.0/16", cfg.ClickExcludeCIDRs[0].String())
+	assert.Equal(t, "203.0.113.7/32", cfg.ClickExcludeCIDRs[1].String())
+	assert.Equal(t, []string{"UptimeRobot", "qa-bot"}, cfg.ClickExcludeUserAgents)
+	assert.Equal(t, "preview", cfg.ClickPreviewParam)
+
+	t.Setenv("CLICK_EXCLUDE_CIDRS", "office")
+	_, err = Load()
+	assert.ErrorContains(t, err, "CLICK_EXCLUDE_CIDRS")
+}
+
+func TestLoadShortenerChains(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("SHORTENER_DOMAINS", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Contains(t, cfg.ShortenerDomains, "bit.ly")
+	assert.Equal(t, 1, cfg.ShortenerChainDepth)
+	assert.Equal(t, "reject", cfg.ShortenerChainAction)
+	assert.False(t, cfg.ShortenerExpand)
+
+	t.Setenv("SHORTENER_DOMAINS", "sho.rt")
+	t.Setenv("SHORTENER_CHAIN_ACTION", "flag")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sho.rt"}, cfg.ShortenerDomains)
+	assert.Equal(t, "flag", cfg.ShortenerChainAction)
+
+	t.Setenv("SHORTENER_CHAIN_ACTION", "ignore")
+	_, err = Load()
+	assert.ErrorContains(t, err, "SHORTENER_CHAIN_ACTION")
+}
+
+func TestLoadRedirectServer(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("REDIRECT_TLS_CERT", "")
+	t.Setenv("REDIRECT_TLS_KEY", "")
+	t.Setenv("REDIRECT_HTTP3", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.RedirectHTTP3)
+	assert.Equal(t, 250, cfg.RedirectHTTP2MaxStreams)
+	assert.Equal(t, 2*time.Minute, cfg.RedirectIdleTimeout)
+
+	t.Setenv("REDIRECT_HTTP3", "true")
+	_, err = Load()
+	assert.ErrorContains(t, err, "REDIRECT_HTTP3")
+
+	t.Setenv("REDIRECT_TLS_CERT", "tls.crt")
+	_, err = Load()
+	assert.ErrorContains(t, err, "REDIRECT_TLS_KEY")
+
+	t.Setenv("REDIRECT_TLS_KEY", "tls.key")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.RedirectHTTP3)
+}
+
+func TestLoadAnalytics(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("ANALYTICS_BACKEND", "")
+	t.Setenv("CLICKHOUSE_URL", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "postgres", cfg.AnalyticsBackend)
+	assert.Equal(t, "click_events", cfg.ClickHouseTable)
+	assert.Equal(t, 1000, cfg.ClickHouseBatchSize)
+
+	t.Setenv("ANALYTICS_BACKEND", "clickhouse")
+	_, err = Load()
+	assert.ErrorContains(t, err, "CLICKHOUSE_URL")
+
+	t.Setenv("CLICKHOUSE_URL", "http://clickhouse:8123")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "http://clickhouse:8123", cfg.ClickHouseURL)
+
+	t.Setenv("ANALYTICS_BACKEND", "bigquery")
+	_, err = Load()
+	assert.ErrorContains(t, err, "ANALYTICS_BACKEND")
+}
+
+func TestLoadExport(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("EXPORT_S3_BUCKET", "clicks")
+	t.Setenv("EXPORT_S3_ACCESS_KEY", "")
+	t.Setenv("EXPORT_S3_SECRET_KEY", "")
+	t.Setenv("EXPORT_S3_PREFIX", "warehouse")
+
+	_, err := Load()
+	assert.ErrorContains(t, err, "EXPORT_S3_ACCESS_KEY")
+
+	t.Setenv("EXPORT_S3_ACCESS_KEY", "key")
+	t.Setenv("EXPORT_S3_SECRET_KEY", "secret")
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "warehouse/", cfg.ExportS3Prefix)
+	assert.Equal(t, time.Hour, cfg.ExportInterval)
+}
+
+func TestLoadDatabasePool(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DB_MAX_CONNS", "")
+	t.Setenv("DB_EXEC_MODE", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Zero(t, cfg.DBMaxConns)
+	assert.Empty(t, cfg.DBExecMode)
+	assert.True(t, cfg.DBPrepareStatements)
+
+	t.Setenv("DB_MAX_CONNS", "20")
+	t.Setenv("DB_MAX_CONN_IDLE_TIME", "5m")
+	t.Setenv("DB_EXEC_MODE", "exec")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 20, cfg.DBMaxConns)
+	assert.Equal(t, 5*time.Minute, cfg.DBMaxConnIdleTime)
+	assert.Equal(t, "exec", cfg.DBExecMode)
+
+	t.Setenv("DB_MIN_CONNS", "30")
+	_, err = Load()
+	assert.ErrorContains(t, err, "DB_MIN_CONNS")
+
+	t.Setenv("DB_MIN_CONNS", "")
+	t.Setenv("DB_EXEC_MODE", "prepared")
+	_, err = Load()
+	assert.ErrorContains(t, err, "DB_EXEC_MODE")
+}
+
+func TestLoadMetrics(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("METRICS_ADDR", "")
+	t.Setenv("METRICS_INTERVAL", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.MetricsAddr)
+	assert.Equal(t, 15*time.Second, cfg.MetricsInterval)
+
+	t.Setenv("METRICS_INTERVAL", "0s")
+	_, err = Load()
+	assert.ErrorContains(t, err, "METRICS_INTERVAL")
+}
+
+func TestLoadRegion(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("REGION", "")
+	t.Setenv("REGION_INDEX", "")
+	t.Setenv("REGION_COUNT", "")
+	t.Setenv("REPLICATION_ADDR", "")
+	t.Setenv("REPLICATION_PEERS", "")
+	t.Setenv("REPLICATION_SECRET", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 0, cfg.RegionIndex)
+	assert.Equal(t, 1, cfg.RegionCount)
+
+	t.Setenv("REGION_INDEX", "2")
+	t.Setenv("REGION_COUNT", "2")
+	_, err = Load()
+	assert.ErrorContains(t, err, "REGION_INDEX")
+
+	t.Setenv("REGION_INDEX", "1")
+	t.Setenv("REPLICATION_PEERS", "http://eu.internal:9091")
+	_, err = Load()
+	assert.ErrorContains(t, err, "REPLICATION_SECRET")
+
+	t.Setenv("REGION", "us")
+	t.Setenv("REPLICATION_SECRET", "s3cret")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"http://eu.internal:9091"}, cfg.ReplicationPeers)
+
+	t.Setenv("REGION_COUNT", "1")
+	t.Setenv("REGION_INDEX", "0")
+	_, err = Load()
+	assert.ErrorContains(t, err, "REGION_COUNT")
+}
+
+func TestLoadLoadShedding(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("LOAD_SHED_LIMITS", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.LoadShedLimits)
+	assert.Equal(t, 100*time.Millisecond, cfg.LoadShedWait)
+
+	t.Setenv("LOAD_SHED_LIMITS", "redirect=2000, write=50")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"redirect": 2000, "write": 50}, cfg.LoadShedLimits)
+
+	t.Setenv("LOAD_SHED_LIMITS", "admin=10")
+	_, err = Load()
+	assert.ErrorContains(t, err, "LOAD_SHED_LIMITS")
+
+	t.Setenv("LOAD_SHED_LIMITS", "read=0")
+	_, err = Load()
+	assert.ErrorContains(t, err, "LOAD_SHED_LIMITS")
+}
+
+func TestLoadClickIngest(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("CLICK_INGEST", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "direct", cfg.ClickIngest)
+	assert.Equal(t, 2, cfg.ClickStreamWorkers)
+	assert.Equal(t, 10000, cfg.ClickQueueSize)
+	assert.Equal(t, "count", cfg.ClickQueueFull)
+
+	t.Setenv("CLICK_INGEST", "kafka")
+	_, err = Load()
+	assert.ErrorContains(t, err, "CLICK_INGEST")
+
+	t.Setenv("CLICK_INGEST", "")
+	t.Setenv("CLICK_QUEUE_FULL", "block")
+	_, err = Load()
+	assert.ErrorContains(t, err, "CLICK_QUEUE_FULL")
+}
+
+func TestLoadStatsCache(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("STATS_CACHE_TTL", "")
+	t.Setenv("STATS_STALE_TTL", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, cfg.StatsCacheTTL)
+	assert.Equal(t, 10*time.Minute, cfg.StatsStaleTTL)
+
+	t.Setenv("STATS_STALE_TTL", "-1s")
+	_, err = Load()
+	assert.ErrorContains(t, err, "STATS_STALE_TTL")
+}
+
+func TestLoadSlack(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	t.Setenv("SLACK_BOT_TOKEN", "")
+	t.Setenv("SLACK_TEAM_OWNERS", "T0123=5f8b7d1c-6c1e-4a8e-9f3a-2b1d0c9e8f7a")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "secret", cfg.SlackSigningSecret)
+	assert.Equal(t, "5f8b7d1c-6c1e-4a8e-9f3a-2b1d0c9e8f7a", cfg.SlackTeamOwners["T0123"].String())
+
+	t.Setenv("SLACK_TEAM_OWNERS", "T0123=nobody")
+	_, err = Load()
+	assert.ErrorContains(t, err, "SLACK_TEAM_OWNERS")
+
+	t.Setenv("SLACK_TEAM_OWNERS", "")
+	_, err = Load()
+	assert.ErrorContains(t, err, "SLACK_TEAM_OWNERS")
+}
+
+func TestLoadDestinationWarnings(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DESTINATION_WARNINGS", "true")
+	t.Setenv("DESTINATION_WARNING_MIN_AGE", "")
+	t.Setenv("DESTINATION_WARNING_TLDS", "zip, mov")
+	t.Setenv("DESTINATION_TRUSTED_DOMAINS", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.DestinationWarnings)
+	assert.Equal(t, 30*24*time.Hour, cfg.DestinationWarningMinAge)
+	assert.Equal(t, []string{"zip", "mov"}, cfg.DestinationWarningTLDs)
+
+	t.Setenv("DESTINATION_WARNING_MIN_AGE", "-1h")
+	_, err = Load()
+	assert.ErrorContains(t, err, "DESTINATION_WARNING_MIN_AGE")
+}
+
+func TestLoadCodeRecycling(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("CODE_RECYCLING", "")
+	t.Setenv("CODE_QUARANTINE", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "never", cfg.CodeRecycling)
+	assert.Equal(t, 365*24*time.Hour, cfg.CodeQuarantine)
+
+	t.Setenv("CODE_RECYCLING", "always")
+	_, err = Load()
+	assert.ErrorContains(t, err, "CODE_RECYCLING")
+
+	t.Setenv("CODE_RECYCLING", "quarantine")
+	t.Setenv("CODE_QUARANTINE", "0s")
+	_, err = Load()
+	assert.ErrorContains(t, err, "CODE_QUARANTINE")
+}
+
+func TestLoadRedirectLeases(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("REDIRECT_RATE_LIMIT_LEASE", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Zero(t, cfg.RedirectLeaseSize)
+
+	t.Setenv("REDIRECT_RATE_LIMIT_LEASE", "100")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 100, cfg.RedirectLeaseSize)
+
+	t.Setenv("REDIRECT_RATE_LIMIT_LEASE", "-1")
+	_, err = Load()
+	assert.ErrorContains(t, err, "REDIRECT_RATE_LIMIT_LEASE")
+}
+
+func TestLoadLogOutputs(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("LOG_OUTPUTS", "")
+	t.Setenv("LOG_FILE_MAX_SIZE", "")
+	t.Setenv("LOG_FILE_MAX_AGE", "")
+	t.Setenv("LOG_FILE_MAX_BACKUPS", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"stdout"}, cfg.LogOutputs)
+	assert.Equal(t, 100, cfg.LogFileMaxSize)
+	assert.Equal(t, 7*24*time.Hour, cfg.LogFileMaxAge)
+	assert.Equal(t, 5, cfg.LogFileMaxBackups)
+
+	t.Setenv("LOG_OUTPUTS", "stdout,file:/var/log/url-shortener.log")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"stdout", "file:/var/log/url-shortener.log"}, cfg.LogOutputs)
+
+	t.Setenv("LOG_FILE_MAX_SIZE", "0")
+	_, err = Load()
+	assert.ErrorContains(t, err, "LOG_FILE_MAX_SIZE")
+}
+
+func TestLoadSlowLogging(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DB_SLOW_QUERY", "")
+	t.Setenv("REDIS_SLOW_COMMAND", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, 500*time.Millisecond, cfg.DBSlowQuery)
+	assert.Equal(t, 100*time.Millisecond, cfg.RedisSlowCommand)
+
+	t.Setenv("REDIS_SLOW_COMMAND", "0")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Zero(t, cfg.RedisSlowCommand)
+
+	t.Setenv("DB_SLOW_QUERY", "-1s")
+	_, err = Load()
+	assert.ErrorContains(t, err, "DB_SLOW_QUERY")
+}
+
+func TestLoadSchemaNewer(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("SCHEMA_NEWER", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "refuse", cfg.SchemaNewer)
+
+	t.Setenv("SCHEMA_NEWER", "read_only")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "read_only", cfg.SchemaNewer)
+
+	t.Setenv("SCHEMA_NEWER", "ignore")
+	_, err = Load()
+	assert.ErrorContains(t, err, "SCHEMA_NEWER")
+}
+
+func TestLoadMaintenance(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("MAINTENANCE_MODE", "")
+	t.Setenv("MAINTENANCE_MESSAGE", "")
+	t.Setenv("MAINTENANCE_REFRESH", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.MaintenanceMode)
+	assert.NotEmpty(t, cfg.MaintenanceMessage)
+	assert.Equal(t, 5*time.Second, cfg.MaintenanceRefresh)
+
+	t.Setenv("MAINTENANCE_MODE", "true")
+	t.Setenv("MAINTENANCE_MESSAGE", "Migrating, back at 10:00 UTC")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.True(t, cfg.MaintenanceMode)
+	assert.Equal(t, "Migrating, back at 10:00 UTC", cfg.MaintenanceMessage)
+
+	t.Setenv("MAINTENANCE_REFRESH", "0s")
+	_, err = Load()
+	assert.ErrorContains(t, err, "MAINTENANCE_REFRESH")
+}
+
+func TestLoadShadow(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("DATABASE_URL", "postgres://localhost/links")
+	t.Setenv("SHADOW_DATABASE_URL", "")
+	t.Setenv("SHADOW_READ_PERCENT", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.ShadowDatabaseURL)
+	assert.Equal(t, 10, cfg.ShadowReadPercent)
+
+	t.Setenv("SHADOW_DATABASE_URL", "postgres://new-cluster/links")
+	t.Setenv("SHADOW_READ_PERCENT", "100")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, "postgres://new-cluster/links", cfg.ShadowDatabaseURL)
+	assert.Equal(t, 100, cfg.ShadowReadPercent)
+
+	t.Setenv("SHADOW_READ_PERCENT", "101")
+	_, err = Load()
+	assert.ErrorContains(t, err, "SHADOW_READ_PERCENT")
+
+	t.Setenv("SHADOW_READ_PERCENT", "")
+	t.Setenv("SHADOW_DATABASE_URL", "postgres://localhost/links")
+	_, err = Load()
+	assert.ErrorContains(t, err, "SHADOW_DATABASE_URL")
+}
+
+func TestLoadFaults(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("FAULT_INJECTION", "")
+	t.Setenv("FAULT_STORAGE_LATENCY", "")
+	t.Setenv("FAULT_STORAGE_ERROR_PERCENT", "")
+	t.Setenv("FAULT_CACHE_LATENCY", "")
+	t.Setenv("FAULT_CACHE_ERROR_PERCENT", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.FaultInjection)
+	assert.Zero(t, cfg.FaultStorageLatency)
+
+	t.Setenv("FAULT_CACHE_ERROR_PERCENT", "20")
+	_, err = Load()
+	assert.ErrorContains(t, err, "FAULT_INJECTION")
+
+	t.Setenv("FAULT_INJECTION", "true")
+	t.Setenv("FAULT_STORAGE_LATENCY", "250ms")
+	cfg, err = Load()
+	require.NoError(t, err)
+	assert.Equal(t, 250*time.Millisecond, cfg.FaultStorageLatency)
+	assert.Equal(t, 20, cfg.FaultCacheErrorPercent)
+
+	t.Setenv("FAULT_STORAGE_ERROR_PERCENT", "150")
+	_, err = Load()
+	assert.ErrorContains(t, err, "FAULT_STORAGE_ERROR_PERCENT")
+}
+
+func TestLoadSitemaps(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("SHORT_DOMAINS", "go.example.com,links.example.org")
+	t.Setenv("SITEMAP_DOMAINS", "Go.example.com")
+	t.Setenv("SITEMAP_TTL", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Go.example.com"}, cfg.SitemapDomains)
+	assert.Equal(t, time.Hour, cfg.SitemapTTL)
+
+	t.Setenv("SITEMAP_DOMAINS", "other.example.net")
+	_, err = Load()
+	assert.ErrorContains(t, err, "SITEMAP_DOMAINS")
+
+	t.Setenv("SITEMAP_DOMAINS", "")
+	t.Setenv("SITEMAP_TTL", "0s")
+	_, err = Load()
+	assert.ErrorContains(t, err, "SITEMAP_TTL")
+}
+
+func TestLoadEmailLinks(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("EMAIL_LINK_ADDRESS", "Links@sho.rt")
+	t.Setenv("EMAIL_LINK_TOKEN", "secret")
+	t.Setenv("EMAIL_LINK_SENDERS", "Alice@example.com=5f8b7d1c-6c1e-4a8e-9f3a-2b1d0c9e8f7a,@example.org=5f8b7d1c-6c1e-4a8e-9f3a-2b1d0c9e8f7a")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "links@sho.rt", cfg.EmailLinkAddress)
+	assert.Contains(t, cfg.EmailLinkSenders, "alice@example.com")
+	assert.Contains(t, cfg.EmailLinkSenders, "@example.org")
+
+	t.Setenv("EMAIL_LINK_TOKEN", "")
+	_, err = Load()
+	assert.ErrorContains(t, err, "EMAIL_LINK_TOKEN")
+}
+
+func TestLoadLiveUpdates(t *testing.T) {
+	t.Setenv("CONFIG_FILE", "")
+	t.Setenv("LIVE_UPDATES", "")
+	t.Setenv("LIVE_MESSAGE_RATE", "")
+
+	cfg, err := Load()
+	require.NoError(t, err)
+	assert.False(t, cfg.LiveUpdates)
+	assert.Equal(t, 20, cfg.LiveMessageRate)
+
+	t.Setenv("LIVE_UPDATES", "true")
+	t.Setenv("LIVE_MESSAGE_RATE", "-1")
+	_, err = Load()
+	assert.ErrorContains(t, err, "LIVE_MESSAGE_RATE")
+}
@@ -0,0 +1,85 @@
+package config
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Watcher owns the active Config and notifies subscribers when the
+// reloadable settings change.
+type Watcher struct {
+	mu          sync.RWMutex
+	current     *Config
+	subscribers []func(*Config)
+	load        func() (*Config, error)
+}
+
+func NewWatcher(cfg *Config) *Watcher {
+	return &Watcher{
+		current: cfg,
+		load:    Load,
+	}
+}
+
+// Current returns the active configuration. Callers must not modify it.
+func (w *Watcher) Current() *Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current
+}
+
+// Subscribe registers fn to be called with the new Config after every
+// successful reload. fn is also called once immediately with the current one.
+func (w *Watcher) Subscribe(fn func(*Config)) {
+	w.mu.Lock()
+	w.subscribers = append(w.subscribers, fn)
+	cfg := w.current
+	w.mu.Unlock()
+
+	fn(cfg)
+}
+
+// Reload re-reads the configuration sources and applies the reloadable
+// settings. Static settings keep their startup values.
+func (w *Watcher) Reload() error {
+	next, err := w.load()
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	updated := *w.current
+	updated.Reloadable = next.Reloadable
+	w.current = &updated
+	subscribers := append([]func(*Config){}, w.subscribers...)
+	w.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(&updated)
+	}
+	return nil
+}
+
+// WatchSignals reloads the configuration on every SIGHUP until ctx is done.
+// Reload errors are passed to onError and the previous config stays active.
+func (w *Watcher) WatchSignals(ctx context.Context, onError func(error)) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(sigs)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-sigs:
+				if err := w.Reload(); err != nil && onError != nil {
+					onError(err)
+				}
+			}
+		}
+	}()
+}
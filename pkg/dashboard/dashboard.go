@@ -0,0 +1,54 @@
+// Package dashboard serves the embedded single-page web UI at /dashboard.
+// The page signs users in with the OIDC authorization-code + PKCE flow and
+// then talks to the regular /v1 and /graphql APIs with the access token, or,
+// when the server-side login flow is enabled, with the session cookie.
+package dashboard
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+//go:embed static
+var staticFiles embed.FS
+
+// Config is published to the page at /dashboard/config.json
+type Config struct {
+	Issuer   string `json:"issuer"`
+	ClientID string `json:"client_id"`
+	Scope    string `json:"scope"`
+	// LoginURL switches the page to the server-side cookie session flow
+	LoginURL string `json:"login_url,omitempty"`
+}
+
+// Routes returns a router to be mounted at /dashboard
+func Routes(cfg Config) http.Handler {
+	static, err := fs.Sub(staticFiles, "static")
+	if err != nil {
+		panic(err) // the embedded directory always exists
+	}
+	files := http.FileServer(http.FS(static))
+
+	r := chi.NewRouter()
+	r.Use(securityHeaders)
+	r.Get("/config.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-cache")
+		json.NewEncoder(w).Encode(cfg)
+	})
+	r.Handle("/*", http.StripPrefix("/dashboard", files))
+	return r
+}
+
+func securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Referrer-Policy", "no-referrer")
+		next.ServeHTTP(w, r)
+	})
+}
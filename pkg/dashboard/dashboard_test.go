@@ -0,0 +1,48 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRouter() *chi.Mux {
+	r := chi.NewRouter()
+	r.Mount("/dashboard", Routes(Config{Issuer: "https://idp.example.com", ClientID: "dash", Scope: "openid"}))
+	return r
+}
+
+func TestServesIndex(t *testing.T) {
+	req := httptest.NewRequest("GET", "/dashboard/", nil)
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "URL Shortener Dashboard")
+	assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+}
+
+func TestServesAssets(t *testing.T) {
+	for _, path := range []string{"/dashboard/app.js", "/dashboard/style.css"} {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		newRouter().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code, path)
+	}
+}
+
+func TestServesConfig(t *testing.T) {
+	req := httptest.NewRequest("GET", "/dashboard/config.json", nil)
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var cfg Config
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &cfg))
+	assert.Equal(t, "dash", cfg.ClientID)
+	assert.Equal(t, "https://idp.example.com", cfg.Issuer)
+}
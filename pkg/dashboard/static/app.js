@@ -0,0 +1,229 @@
+"use strict";
+
+// Minimal dashboard client. When the server publishes a login_url it relies
+// on the server-side login flow and the HttpOnly session cookie. Otherwise it
+// signs in with the OIDC authorization-code flow using PKCE (public client,
+// no secret) and keeps the access token in sessionStorage for the tab.
+
+const PAGE_SIZE = 20;
+const redirectURI = window.location.origin + "/dashboard/";
+let config;
+let offset = 0;
+
+function base64url(bytes) {
+	return btoa(String.fromCharCode(...new Uint8Array(bytes)))
+		.replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+function randomString() {
+	return base64url(crypto.getRandomValues(new Uint8Array(32)));
+}
+
+async function discovery() {
+	const res = await fetch(config.issuer.replace(/\/$/, "") + "/.well-known/openid-configuration");
+	if (!res.ok) throw new Error("OIDC discovery failed");
+	return res.json();
+}
+
+function usesSession() {
+	return Boolean(config.login_url);
+}
+
+async function login() {
+	if (usesSession()) {
+		const params = new URLSearchParams({ return_to: "/dashboard/" });
+		return window.location.assign(config.login_url + "?" + params);
+	}
+	const oidc = await discovery();
+	const verifier = randomString();
+	const state = randomString();
+	const challenge = base64url(await crypto.subtle.digest("SHA-256", new TextEncoder().encode(verifier)));
+	sessionStorage.setItem("pkce_verifier", verifier);
+	sessionStorage.setItem("pkce_state", state);
+
+	const params = new URLSearchParams({
+		response_type: "code",
+		client_id: config.client_id,
+		redirect_uri: redirectURI,
+		scope: config.scope,
+		state: state,
+		code_challenge: challenge,
+		code_challenge_method: "S256",
+	});
+	window.location.assign(oidc.authorization_endpoint + "?" + params);
+}
+
+async function handleCallback(params) {
+	if (params.get("state") !== sessionStorage.getItem("pkce_state")) {
+		throw new Error("login state mismatch");
+	}
+	const oidc = await discovery();
+	const res = await fetch(oidc.token_endpoint, {
+		method: "POST",
+		headers: { "Content-Type": "application/x-www-form-urlencoded" },
+		body: new URLSearchParams({
+			grant_type: "authorization_code",
+			code: params.get("code"),
+			redirect_uri: redirectURI,
+			client_id: config.client_id,
+			code_verifier: sessionStorage.getItem("pkce_verifier"),
+		}),
+	});
+	if (!res.ok) throw new Error("token exchange failed");
+	const token = await res.json();
+	sessionStorage.setItem("access_token", token.access_token);
+	sessionStorage.removeItem("pkce_verifier");
+	sessionStorage.removeItem("pkce_state");
+	window.history.replaceState({}, "", redirectURI);
+}
+
+async function api(path, options = {}) {
+	const auth = usesSession() ? {} : { Authorization: "Bearer " + sessionStorage.getItem("access_token") };
+	const headers = Object.assign(auth, options.headers);
+	const res = await fetch(path, Object.assign({}, options, { headers, credentials: "same-origin" }));
+	if (res.status === 401) {
+		sessionStorage.removeItem("access_token");
+		await login();
+	}
+	return res;
+}
+
+async function csrfToken() {
+	const res = await fetch("/v1/csrf-token", { credentials: "same-origin" });
+	return (await res.json()).csrf_token;
+}
+
+async function loadLinks(reset) {
+	if (reset) {
+		offset = 0;
+		document.getElementById("links").replaceChildren();
+	}
+	const res = await api("/graphql", {
+		method: "POST",
+		headers: { "Content-Type": "application/json" },
+		body: JSON.stringify({
+			query: "query($first: Int, $offset: Int) { links(first: $first, offset: $offset) { code longUrl tags stats { totalClicks expired } } }",
+			variables: { first: PAGE_SIZE, offset: offset },
+		}),
+	});
+	const body = await res.json();
+	if (body.errors) throw new Error(body.errors[0].message);
+
+	const rows = body.data.links.map(renderLink);
+	document.getElementById("links").append(...rows);
+	offset += rows.length;
+	document.getElementById("more").hidden = rows.length < PAGE_SIZE;
+}
+
+function renderLink(link) {
+	const row = document.createElement("tr");
+	const cell = (text, className) => {
+		const td = document.createElement("td");
+		td.textContent = text;
+		if (className) td.className = className;
+		return td;
+	};
+
+	const tags = document.createElement("td");
+	for (const tag of link.tags) {
+		const span = document.createElement("span");
+		span.className = "tag";
+		span.textContent = tag;
+		tags.append(span);
+	}
+
+	const actions = document.createElement("td");
+	const qr = document.createElement("button");
+	qr.textContent = "QR";
+	qr.onclick = () => showQR(link.code);
+	const del = document.createElement("button");
+	del.textContent = "Delete";
+	del.onclick = () => deleteLink(link.code, row);
+	actions.append(qr, del);
+
+	const clicks = link.stats.totalClicks + (link.stats.expired ? " (expired)" : "");
+	row.append(cell(link.code), cell(link.longUrl, "url"), tags, cell(clicks), actions);
+	return row;
+}
+
+async function showQR(code) {
+	const res = await api("/v1/links/" + encodeURIComponent(code) + "/qr");
+	if (!res.ok) return setStatus("could not load QR code", true);
+	document.getElementById("qr-image").src = URL.createObjectURL(await res.blob());
+	document.getElementById("qr-dialog").showModal();
+}
+
+async function deleteLink(code, row) {
+	if (!confirm("Delete " + code + "?")) return;
+	const res = await api("/v1/links/" + encodeURIComponent(code), {
+		method: "DELETE",
+		headers: { "X-CSRF-Token": await csrfToken() },
+	});
+	if (res.ok) row.remove(); else setStatus("delete failed", true);
+}
+
+async function createLink(event) {
+	event.preventDefault();
+	const form = event.target;
+	const payload = { long_url: form.long_url.value };
+	if (form.alias.value) payload.alias = form.alias.value;
+	const tags = form.tags.value.split(",").map((t) => t.trim()).filter(Boolean);
+	if (tags.length) payload.tags = tags;
+
+	const res = await api("/v1/links", {
+		method: "POST",
+		headers: { "Content-Type": "application/json", "X-CSRF-Token": await csrfToken() },
+		body: JSON.stringify(payload),
+	});
+	const result = document.getElementById("create-result");
+	if (!res.ok) {
+		result.className = "result error";
+		result.textContent = await res.text();
+		return;
+	}
+	const created = await res.json();
+	result.className = "result";
+	result.textContent = created.short_url;
+	form.reset();
+	await loadLinks(true);
+}
+
+async function logout() {
+	if (usesSession()) {
+		await fetch("/auth/logout", {
+			method: "POST",
+			headers: { "X-CSRF-Token": await csrfToken() },
+			credentials: "same-origin",
+		});
+	}
+	sessionStorage.clear();
+	window.location.assign(redirectURI);
+}
+
+function setStatus(message, isError) {
+	const status = document.getElementById("status");
+	status.className = isError ? "error" : "";
+	status.textContent = message;
+}
+
+async function main() {
+	config = await (await fetch("/dashboard/config.json")).json();
+
+	if (usesSession()) {
+		const res = await fetch("/auth/session", { credentials: "same-origin" });
+		if (!(await res.json()).authenticated) return login();
+	} else {
+		const params = new URLSearchParams(window.location.search);
+		if (params.has("code")) await handleCallback(params);
+		if (!sessionStorage.getItem("access_token")) return login();
+	}
+
+	document.getElementById("app").hidden = false;
+	document.getElementById("logout").hidden = false;
+	document.getElementById("logout").onclick = logout;
+	document.getElementById("create-form").onsubmit = createLink;
+	document.getElementById("more").onclick = () => loadLinks(false);
+	await loadLinks(true);
+}
+
+main().catch((err) => setStatus(err.message, true));
@@ -0,0 +1,170 @@
+// Package digest emails owners a weekly or monthly summary of their click
+// activity. A Job periodically looks for owners whose next digest is due,
+// builds it with the DigestService and sends it through the notify package.
+// With a job queue, sending is handed to a job so that failures are retried
+// with backoff and dead-lettered rather than retried on every run.
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"url-shortener/pkg/jobs"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/notify"
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// batchSize bounds the number of digests sent per run
+	batchSize = 200
+
+	// SendKind is the job that builds and emails one digest
+	SendKind = "digest.send"
+)
+
+var sendPolicy = jobs.Policy{
+	MaxAttempts: 6,
+	Backoff:     jobs.Exponential(time.Minute, time.Hour),
+	Timeout:     time.Minute,
+}
+
+// Builder aggregates an owner's activity for the period ending at now
+type Builder interface {
+	BuildDigest(ctx context.Context, ownerID uuid.UUID, period string, now time.Time) (*service.Digest, error)
+}
+
+// Sender delivers a rendered template; *notify.Mailer implements it
+type Sender interface {
+	Send(ctx context.Context, to, template string, data any) error
+}
+
+type Job struct {
+	store   storage.DigestStorage
+	builder Builder
+	sender  Sender
+	logger  *logging.Logger
+	queue   *jobs.Queue
+}
+
+func NewJob(store storage.DigestStorage, builder Builder, sender Sender, logger *logging.Logger) *Job {
+	return &Job{
+		store:   store,
+		builder: builder,
+		sender:  sender,
+		logger:  logger,
+	}
+}
+
+// UseQueue sends digests from jobs on q
+func (j *Job) UseQueue(q *jobs.Queue) {
+	j.queue = q
+	q.Register(SendKind, sendPolicy, j.deliver)
+}
+
+// Run checks for due digests every interval until ctx is done
+func (j *Job) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if sent, err := j.RunOnce(ctx, time.Now()); err != nil {
+			j.logger.Error(ctx, "digest run failed", "error", err)
+		} else if sent > 0 {
+			j.logger.Info(ctx, "click digests sent", "count", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce sends every digest due at now and returns how many were emailed,
+// or queued when a job queue is in use. Periods end at UTC midnight, so a
+// digest covers whole days.
+func (j *Job) RunOnce(ctx context.Context, now time.Time) (int, error) {
+	periodEnd := now.UTC().Truncate(24 * time.Hour)
+	recipients, err := j.store.FindDigestRecipients(ctx, periodEnd, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, r := range recipients {
+		ns := r.Settings
+		to, ok := notify.Recipient(ns, notify.CategoryDigest)
+		if !ok {
+			continue
+		}
+
+		// Claim first so that concurrent API replicas don't both send
+		claimed, err := j.store.ClaimDigest(ctx, ns.OwnerID, periodEnd, service.PeriodDays(ns.Digest))
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+
+		var delivered bool
+		if j.queue != nil {
+			err = j.queue.Enqueue(ctx, SendKind, &sendJob{OwnerID: ns.OwnerID, To: to, Period: ns.Digest, PeriodEnd: periodEnd})
+			delivered = err == nil
+		} else {
+			delivered, err = j.send(ctx, to, ns.OwnerID, ns.Digest, now)
+		}
+		if err != nil {
+			j.logger.Warn(ctx, "digest delivery failed", "owner_id", ns.OwnerID, "error", err)
+			// Let the next run retry
+			if err := j.store.ReleaseDigest(ctx, ns.OwnerID, r.SentUntil); err != nil {
+				j.logger.Warn(ctx, "failed to release digest", "owner_id", ns.OwnerID, "error", err)
+			}
+			continue
+		}
+		if delivered {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// send reports false without error for a period with no activity, which
+// still counts as done so that quiet owners aren't emailed an empty digest
+func (j *Job) send(ctx context.Context, to string, ownerID uuid.UUID, period string, now time.Time) (bool, error) {
+	d, err := j.builder.BuildDigest(ctx, ownerID, period, now)
+	if err != nil {
+		return false, err
+	}
+	if d.TotalClicks == 0 && d.NewLinks == 0 {
+		return false, nil
+	}
+	if err := j.sender.Send(ctx, to, "digest", d); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+type sendJob struct {
+	OwnerID   uuid.UUID `json:"owner_id"`
+	To        string    `json:"to"`
+	Period    string    `json:"period"`
+	PeriodEnd time.Time `json:"period_end"`
+}
+
+// deliver sends a queued digest. The period was already claimed, so a digest
+// that exhausts its attempts is skipped until it is requeued.
+func (j *Job) deliver(ctx context.Context, payload json.RawMessage) error {
+	var job sendJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return jobs.Permanent(err)
+	}
+	_, err := j.send(ctx, job.To, job.OwnerID, job.Period, job.PeriodEnd)
+	return err
+}
@@ -0,0 +1,107 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStore struct {
+	recipients []*storage.DigestRecipient
+	sentUntil  map[uuid.UUID]*time.Time
+}
+
+func (f *fakeStore) FindDigestRecipients(ctx context.Context, periodEnd time.Time, limit int) ([]*storage.DigestRecipient, error) {
+	return f.recipients, nil
+}
+
+func (f *fakeStore) ClaimDigest(ctx context.Context, ownerID uuid.UUID, periodEnd time.Time, days int) (bool, error) {
+	if prev := f.sentUntil[ownerID]; prev != nil && prev.After(periodEnd.AddDate(0, 0, -days)) {
+		return false, nil
+	}
+	f.sentUntil[ownerID] = &periodEnd
+	return true, nil
+}
+
+func (f *fakeStore) ReleaseDigest(ctx context.Context, ownerID uuid.UUID, previous *time.Time) error {
+	f.sentUntil[ownerID] = previous
+	return nil
+}
+
+type fakeBuilder struct {
+	clicks map[uuid.UUID]int64
+}
+
+func (f *fakeBuilder) BuildDigest(ctx context.Context, ownerID uuid.UUID, period string, now time.Time) (*service.Digest, error) {
+	return &service.Digest{Period: period, TotalClicks: f.clicks[ownerID]}, nil
+}
+
+type fakeSender struct {
+	err  error
+	sent []string
+}
+
+func (f *fakeSender) Send(ctx context.Context, to, template string, data any) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, to)
+	return nil
+}
+
+func newRecipient(email, period string) *storage.DigestRecipient {
+	return &storage.DigestRecipient{Settings: &storage.NotificationSettings{
+		OwnerID:      uuid.New(),
+		Email:        &email,
+		EmailEnabled: true,
+		Digest:       period,
+	}}
+}
+
+func TestRunOnceSendsEachPeriodOnce(t *testing.T) {
+	active := newRecipient("active@example.com", "week")
+	quiet := newRecipient("quiet@example.com", "month")
+	store := &fakeStore{recipients: []*storage.DigestRecipient{active, quiet}, sentUntil: map[uuid.UUID]*time.Time{}}
+	builder := &fakeBuilder{clicks: map[uuid.UUID]int64{active.Settings.OwnerID: 12}}
+	sender := &fakeSender{}
+	job := NewJob(store, builder, sender, logging.NewLogger(logging.LevelError))
+
+	now := time.Date(2026, 3, 11, 15, 0, 0, 0, time.UTC)
+	sent, err := job.RunOnce(context.Background(), now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, []string{"active@example.com"}, sender.sent)
+	// Quiet periods are marked done without an email
+	assert.NotNil(t, store.sentUntil[quiet.Settings.OwnerID])
+
+	// Later the same day nothing is due again
+	sent, err = job.RunOnce(context.Background(), now.Add(5*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	// A week later the weekly digest goes out again
+	sent, err = job.RunOnce(context.Background(), now.AddDate(0, 0, 7))
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+}
+
+func TestRunOnceReleasesFailedDigests(t *testing.T) {
+	r := newRecipient("owner@example.com", "week")
+	store := &fakeStore{recipients: []*storage.DigestRecipient{r}, sentUntil: map[uuid.UUID]*time.Time{}}
+	builder := &fakeBuilder{clicks: map[uuid.UUID]int64{r.Settings.OwnerID: 1}}
+	job := NewJob(store, builder, &fakeSender{err: errors.New("smtp down")}, logging.NewLogger(logging.LevelError))
+
+	sent, err := job.RunOnce(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	assert.Nil(t, store.sentUntil[r.Settings.OwnerID])
+}
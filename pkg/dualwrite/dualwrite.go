@@ -0,0 +1,368 @@
+// Package dualwrite moves links to a new storage backend without downtime.
+// LinkStorage wraps the current backend, the primary, and the new one, the
+// shadow: every write is made on the primary and then mirrored to the
+// shadow, and a sample of reads is repeated on the shadow in the background
+// and compared with what the primary returned, counting mismatches. The
+// primary stays the source of truth throughout: callers only ever see its
+// results, and the shadow failing or lagging never fails a request.
+//
+// A migration starts dual-writing, copies the existing links to the shadow,
+// and watches the mismatch metrics until they stay at zero; then the
+// backends are swapped, blue/green, so that the new one serves while the
+// old one keeps being written for a rollback, and finally the old one is
+// dropped.
+package dualwrite
+
+import (
+	"context"
+	"math/rand/v2"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/metrics"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// shadowTimeout bounds each mirrored write and compared read, which run
+// apart from the request they were made for
+const shadowTimeout = 5 * time.Second
+
+// maxShadowReads is how many compared reads run at once; reads beyond it
+// aren't compared, so that a slow shadow can't pile them up
+const maxShadowReads = 16
+
+// ignoredFields are the link fields reads don't compare: those each backend
+// sets itself, click counts, which keep changing while links are copied,
+// and those not loaded with the link
+var ignoredFields = map[string]bool{
+	"CreatedAt": true, "ClickCount": true, "LastClickedAt": true,
+	"Tags": true, "Health": true, "Creation": true,
+}
+
+// LinkStorage writes links to the primary and the shadow and compares
+// reads of them. Writes made in a transaction are mirrored as soon as they
+// are made, outside it, so a transaction that rolls back leaves them on the
+// shadow. Reads in a transaction, of creation contexts, and of owners'
+// link and tag lists are served by the primary alone.
+type LinkStorage struct {
+	primary     storage.LinkStorage
+	shadow      storage.LinkStorage
+	readPercent int
+	logger      *logging.Logger
+	slots       chan struct{}
+	pending     sync.WaitGroup
+
+	writeErrors atomic.Int64
+	compared    atomic.Int64
+	skipped     atomic.Int64
+	readErrors  atomic.Int64
+	missing     atomic.Int64
+	extra       atomic.Int64
+	differing   atomic.Int64
+}
+
+// New mirrors writes to shadow and compares readPercent percent of reads
+func New(primary, shadow storage.LinkStorage, readPercent int, logger *logging.Logger) *LinkStorage {
+	return &LinkStorage{
+		primary:     primary,
+		shadow:      shadow,
+		readPercent: readPercent,
+		logger:      logger,
+		slots:       make(chan struct{}, maxShadowReads),
+	}
+}
+
+// Metrics samples the shadow's failed writes and the reads compared on it
+func (s *LinkStorage) Metrics(r *metrics.Registry) {
+	r.Set("dual_write_shadow_write_errors_total", metrics.Counter, "Link writes the shadow backend failed to mirror.", float64(s.writeErrors.Load()))
+	r.Set("dual_write_reads_compared_total", metrics.Counter, "Link reads repeated on the shadow backend and compared.", float64(s.compared.Load()))
+	r.Set("dual_write_reads_skipped_total", metrics.Counter, "Sampled link reads not compared because too many comparisons were running.", float64(s.skipped.Load()))
+	r.Set("dual_write_shadow_read_errors_total", metrics.Counter, "Link reads the shadow backend failed.", float64(s.readErrors.Load()))
+	r.Set("dual_write_missing_total", metrics.Counter, "Links the primary backend had and the shadow lacked.", float64(s.missing.Load()))
+	r.Set("dual_write_extra_total", metrics.Counter, "Links the shadow backend had and the primary lacked.", float64(s.extra.Load()))
+	r.Set("dual_write_differing_total", metrics.Counter, "Links or tags the backends had with different values.", float64(s.differing.Load()))
+}
+
+// mirror makes write on the shadow, once the primary has made it. Failures
+// are counted and logged, not returned.
+func (s *LinkStorage) mirror(ctx context.Context, op, key string, write func(ctx context.Context) error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shadowTimeout)
+	defer cancel()
+	if err := write(ctx); err != nil {
+		s.writeErrors.Add(1)
+		s.logger.Warn(ctx, "shadow write failed", "operation", op, "key", key, "error", err)
+	}
+}
+
+// compare runs read on the shadow in the background for a sample of reads.
+// read compares what the shadow returns with what the primary did.
+func (s *LinkStorage) compare(ctx context.Context, op string, read func(ctx context.Context) error) {
+	if s.readPercent < 100 && rand.IntN(100) >= s.readPercent {
+		return
+	}
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		s.skipped.Add(1)
+		return
+	}
+	s.pending.Add(1)
+	go func() {
+		defer func() {
+			<-s.slots
+			s.pending.Done()
+		}()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shadowTimeout)
+		defer cancel()
+		if err := read(ctx); err != nil {
+			s.readErrors.Add(1)
+			s.logger.Warn(ctx, "shadow read failed", "operation", op, "error", err)
+			return
+		}
+		s.compared.Add(1)
+	}()
+}
+
+// match counts and logs how shadow, the link under key on the shadow,
+// differs from primary, the one on the primary; either may be nil
+func (s *LinkStorage) match(ctx context.Context, key string, primary, shadow *storage.Link) {
+	switch {
+	case primary == nil && shadow == nil:
+	case shadow == nil:
+		s.missing.Add(1)
+		s.logger.Debug(ctx, "link missing from shadow", "key", key)
+	case primary == nil:
+		s.extra.Add(1)
+		s.logger.Debug(ctx, "link only on shadow", "key", key)
+	default:
+		if fields := linkDiff(primary, shadow); len(fields) > 0 {
+			s.differing.Add(1)
+			s.logger.Warn(ctx, "shadow link differs", "key", key, "fields", fields)
+		}
+	}
+}
+
+// linkDiff returns the names of the compared fields in which a and b differ
+func linkDiff(a, b *storage.Link) []string {
+	va, vb := reflect.ValueOf(a).Elem(), reflect.ValueOf(b).Elem()
+	var fields []string
+	for i := 0; i < va.NumField(); i++ {
+		name := va.Type().Field(i).Name
+		if ignoredFields[name] {
+			continue
+		}
+		if !fieldEqual(va.Field(i).Interface(), vb.Field(i).Interface()) {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// fieldEqual compares times as instants, as backends may return them in
+// different locations
+func fieldEqual(a, b any) bool {
+	switch a := a.(type) {
+	case time.Time:
+		return a.Equal(b.(time.Time))
+	case *time.Time:
+		b := b.(*time.Time)
+		if a == nil || b == nil {
+			return a == b
+		}
+		return a.Equal(*b)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func (s *LinkStorage) Create(ctx context.Context, link *storage.Link) error {
+	if err := s.primary.Create(ctx, link); err != nil {
+		return err
+	}
+	s.mirror(ctx, "create", link.Key(), func(ctx context.Context) error { return s.shadow.Create(ctx, link) })
+	return nil
+}
+
+func (s *LinkStorage) CreateTx(ctx context.Context, tx pgx.Tx, link *storage.Link) error {
+	if err := s.primary.CreateTx(ctx, tx, link); err != nil {
+		return err
+	}
+	s.mirror(ctx, "create", link.Key(), func(ctx context.Context) error { return s.shadow.Create(ctx, link) })
+	return nil
+}
+
+func (s *LinkStorage) GetByCode(ctx context.Context, key string) (*storage.Link, error) {
+	link, err := s.primary.GetByCode(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	s.compare(ctx, "get", func(ctx context.Context) error {
+		shadow, err := s.shadow.GetByCode(ctx, key)
+		if err != nil {
+			return err
+		}
+		s.match(ctx, key, link, shadow)
+		return nil
+	})
+	return link, nil
+}
+
+func (s *LinkStorage) GetByCodeTx(ctx context.Context, tx pgx.Tx, key string) (*storage.Link, error) {
+	return s.primary.GetByCodeTx(ctx, tx, key)
+}
+
+func (s *LinkStorage) GetByCodes(ctx context.Context, keys []string) ([]*storage.Link, error) {
+	links, err := s.primary.GetByCodes(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	s.compare(ctx, "get_many", func(ctx context.Context) error {
+		shadow, err := s.shadow.GetByCodes(ctx, keys)
+		if err != nil {
+			return err
+		}
+		primaryByKey, shadowByKey := linksByKey(links), linksByKey(shadow)
+		for _, key := range keys {
+			s.match(ctx, key, primaryByKey[key], shadowByKey[key])
+		}
+		return nil
+	})
+	return links, nil
+}
+
+func linksByKey(links []*storage.Link) map[string]*storage.Link {
+	byKey := make(map[string]*storage.Link, len(links))
+	for _, link := range links {
+		byKey[link.Key()] = link
+	}
+	return byKey
+}
+
+func (s *LinkStorage) Update(ctx context.Context, link *storage.Link) error {
+	if err := s.primary.Update(ctx, link); err != nil {
+		return err
+	}
+	s.mirror(ctx, "update", link.Key(), func(ctx context.Context) error { return s.shadow.Update(ctx, link) })
+	return nil
+}
+
+func (s *LinkStorage) UpdateTx(ctx context.Context, tx pgx.Tx, link *storage.Link) error {
+	if err := s.primary.UpdateTx(ctx, tx, link); err != nil {
+		return err
+	}
+	s.mirror(ctx, "update", link.Key(), func(ctx context.Context) error { return s.shadow.Update(ctx, link) })
+	return nil
+}
+
+func (s *LinkStorage) Delete(ctx context.Context, key string) error {
+	if err := s.primary.Delete(ctx, key); err != nil {
+		return err
+	}
+	s.mirror(ctx, "delete", key, func(ctx context.Context) error { return s.shadow.Delete(ctx, key) })
+	return nil
+}
+
+func (s *LinkStorage) DeleteTx(ctx context.Context, tx pgx.Tx, key string) error {
+	if err := s.primary.DeleteTx(ctx, tx, key); err != nil {
+		return err
+	}
+	s.mirror(ctx, "delete", key, func(ctx context.Context) error { return s.shadow.Delete(ctx, key) })
+	return nil
+}
+
+func (s *LinkStorage) LastRetiredTx(ctx context.Context, tx pgx.Tx, key string) (*time.Time, error) {
+	return s.primary.LastRetiredTx(ctx, tx, key)
+}
+
+// RetireExpired retires the same expired links on the shadow, up to the
+// same limit
+func (s *LinkStorage) RetireExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	n, err := s.primary.RetireExpired(ctx, before, limit)
+	if err != nil {
+		return n, err
+	}
+	s.mirror(ctx, "retire_expired", "", func(ctx context.Context) error {
+		_, err := s.shadow.RetireExpired(ctx, before, limit)
+		return err
+	})
+	return n, nil
+}
+
+func (s *LinkStorage) GetCreation(ctx context.Context, key string) (*storage.CreationContext, error) {
+	return s.primary.GetCreation(ctx, key)
+}
+
+func (s *LinkStorage) AddClickCount(ctx context.Context, key string, n int64) error {
+	if err := s.primary.AddClickCount(ctx, key, n); err != nil {
+		return err
+	}
+	s.mirror(ctx, "add_click_count", key, func(ctx context.Context) error { return s.shadow.AddClickCount(ctx, key, n) })
+	return nil
+}
+
+func (s *LinkStorage) ReplacePasswordHash(ctx context.Context, key, old, new string) error {
+	if err := s.primary.ReplacePasswordHash(ctx, key, old, new); err != nil {
+		return err
+	}
+	s.mirror(ctx, "replace_password_hash", key, func(ctx context.Context) error { return s.shadow.ReplacePasswordHash(ctx, key, old, new) })
+	return nil
+}
+
+func (s *LinkStorage) ListByOwner(ctx context.Context, ownerID uuid.UUID, query storage.LinkQuery, limit, offset int) ([]*storage.Link, error) {
+	return s.primary.ListByOwner(ctx, ownerID, query, limit, offset)
+}
+
+func (s *LinkStorage) GetTags(ctx context.Context, keys []string) (map[string][]string, error) {
+	tags, err := s.primary.GetTags(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	s.compare(ctx, "get_tags", func(ctx context.Context) error {
+		shadow, err := s.shadow.GetTags(ctx, keys)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if !sameTags(tags[key], shadow[key]) {
+				s.differing.Add(1)
+				s.logger.Warn(ctx, "shadow tags differ", "key", key)
+			}
+		}
+		return nil
+	})
+	return tags, nil
+}
+
+// sameTags compares tags in any order
+func sameTags(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+func (s *LinkStorage) SetTags(ctx context.Context, key string, tags []string) error {
+	if err := s.primary.SetTags(ctx, key, tags); err != nil {
+		return err
+	}
+	s.mirror(ctx, "set_tags", key, func(ctx context.Context) error { return s.shadow.SetTags(ctx, key, tags) })
+	return nil
+}
+
+func (s *LinkStorage) SetTagsTx(ctx context.Context, tx pgx.Tx, key string, tags []string) error {
+	if err := s.primary.SetTagsTx(ctx, tx, key, tags); err != nil {
+		return err
+	}
+	s.mirror(ctx, "set_tags", key, func(ctx context.Context) error { return s.shadow.SetTags(ctx, key, tags) })
+	return nil
+}
+
+func (s *LinkStorage) ListTagsByOwner(ctx context.Context, ownerID uuid.UUID) ([]string, error) {
+	return s.primary.ListTagsByOwner(ctx, ownerID)
+}
@@ -0,0 +1,113 @@
+package dualwrite
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryLinks struct {
+	storage.LinkStorage
+	links map[string]storage.Link
+	err   error
+}
+
+func newMemoryLinks() *memoryLinks {
+	return &memoryLinks{links: make(map[string]storage.Link)}
+}
+
+func (m *memoryLinks) Create(ctx context.Context, link *storage.Link) error {
+	if m.err != nil {
+		return m.err
+	}
+	stored := *link
+	stored.CreatedAt = time.Now()
+	m.links[link.Key()] = stored
+	return nil
+}
+
+func (m *memoryLinks) Update(ctx context.Context, link *storage.Link) error {
+	if m.err != nil {
+		return m.err
+	}
+	if _, ok := m.links[link.Key()]; ok {
+		m.links[link.Key()] = *link
+	}
+	return nil
+}
+
+func (m *memoryLinks) Delete(ctx context.Context, key string) error {
+	delete(m.links, key)
+	return m.err
+}
+
+func (m *memoryLinks) GetByCode(ctx context.Context, key string) (*storage.Link, error) {
+	link, ok := m.links[key]
+	if !ok || m.err != nil {
+		return nil, m.err
+	}
+	return &link, nil
+}
+
+func TestMirrorsWrites(t *testing.T) {
+	ctx := context.Background()
+	primary, shadow := newMemoryLinks(), newMemoryLinks()
+	links := New(primary, shadow, 100, logging.NewLogger(logging.LevelError))
+
+	require.NoError(t, links.Create(ctx, &storage.Link{Code: "abc", LongURL: "https://example.com"}))
+	require.NoError(t, links.Update(ctx, &storage.Link{Code: "abc", LongURL: "https://example.org"}))
+	assert.Equal(t, "https://example.org", shadow.links["abc"].LongURL)
+
+	// The shadow failing doesn't fail the write
+	shadow.err = errors.New("shadow down")
+	require.NoError(t, links.Create(ctx, &storage.Link{Code: "def", LongURL: "https://example.com"}))
+	assert.Contains(t, primary.links, "def")
+	assert.EqualValues(t, 1, links.writeErrors.Load())
+
+	// Nor is anything mirrored when the primary fails
+	shadow.err, primary.err = nil, errors.New("primary down")
+	assert.Error(t, links.Delete(ctx, "abc"))
+	assert.Contains(t, shadow.links, "abc")
+}
+
+func TestComparesReads(t *testing.T) {
+	ctx := context.Background()
+	primary, shadow := newMemoryLinks(), newMemoryLinks()
+	links := New(primary, shadow, 100, logging.NewLogger(logging.LevelError))
+	require.NoError(t, links.Create(ctx, &storage.Link{Code: "same", LongURL: "https://example.com"}))
+	require.NoError(t, primary.Create(ctx, &storage.Link{Code: "missing", LongURL: "https://example.com"}))
+	require.NoError(t, links.Create(ctx, &storage.Link{Code: "differs", LongURL: "https://example.com"}))
+	require.NoError(t, shadow.Update(ctx, &storage.Link{Code: "differs", LongURL: "https://example.org", ClickCount: 3}))
+
+	for _, key := range []string{"same", "missing", "differs", "absent"} {
+		link, err := links.GetByCode(ctx, key)
+		require.NoError(t, err)
+		if key != "absent" {
+			assert.Equal(t, "https://example.com", link.LongURL, "reads are served by the primary")
+		}
+	}
+	links.pending.Wait()
+
+	assert.EqualValues(t, 4, links.compared.Load())
+	assert.EqualValues(t, 1, links.missing.Load())
+	assert.EqualValues(t, 1, links.differing.Load())
+	assert.Zero(t, links.extra.Load())
+}
+
+func TestLinkDiff(t *testing.T) {
+	expires := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	inLocal := expires.In(time.FixedZone("CET", 3600))
+	a := &storage.Link{Code: "abc", LongURL: "https://example.com", ExpiresAt: &expires, ClickCount: 1, CreatedAt: time.Now()}
+	b := &storage.Link{Code: "abc", LongURL: "https://example.com", ExpiresAt: &inLocal, ClickCount: 2}
+	assert.Empty(t, linkDiff(a, b))
+
+	b.LongURL, b.ExpiresAt = "https://example.org", nil
+	assert.Equal(t, []string{"LongURL", "ExpiresAt"}, linkDiff(a, b))
+}
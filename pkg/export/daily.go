@@ -0,0 +1,96 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"url-shortener/pkg/jobs"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+)
+
+// DailyKind is the job that exports the previous UTC day's rollup. It is
+// scheduled at every UTC midnight.
+const DailyKind = "export.daily_clicks"
+
+var dailyPolicy = jobs.Policy{
+	MaxAttempts: 6,
+	Backoff:     jobs.Exponential(time.Minute, time.Hour),
+	Timeout:     5 * time.Minute,
+}
+
+// DailyClickSource has the per-link click counts of each UTC day;
+// *cache.LinkCache implements it
+type DailyClickSource interface {
+	// AllDailyClicks returns the clicks per link key on the UTC day
+	// containing day
+	AllDailyClicks(ctx context.Context, day time.Time) (map[string]int64, error)
+}
+
+// dailyRow is one link's clicks on one day as a Parquet row
+type dailyRow struct {
+	// Date is days since the Unix epoch, as Parquet stores dates
+	Date   int32  `parquet:"date,date"`
+	Domain string `parquet:"domain"`
+	Code   string `parquet:"code"`
+	Clicks int64  `parquet:"clicks"`
+}
+
+// DailyClicks exports each day's per-link click counts
+type DailyClicks struct {
+	store  Store
+	prefix string
+	source DailyClickSource
+	logger *logging.Logger
+}
+
+func NewDailyClicks(store Store, prefix string, source DailyClickSource, logger *logging.Logger) *DailyClicks {
+	return &DailyClicks{
+		store:  store,
+		prefix: prefix,
+		source: source,
+		logger: logger,
+	}
+}
+
+// UseQueue exports every day's rollup from a job on q the day after
+func (d *DailyClicks) UseQueue(q *jobs.Queue) {
+	q.Register(DailyKind, dailyPolicy, d.run)
+	q.Every(DailyKind, 24*time.Hour)
+}
+
+func (d *DailyClicks) run(ctx context.Context, _ json.RawMessage) error {
+	yesterday := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	file, err := d.Export(ctx, yesterday)
+	if err != nil {
+		return err
+	}
+	d.logger.Info(ctx, "daily clicks exported", "key", file.Key, "links", file.Rows)
+	return nil
+}
+
+// Export writes the rollup of the UTC day containing day, replacing any
+// earlier export of it. Links without clicks that day are left out. Counts
+// are kept for 35 days, so older days can't be exported.
+func (d *DailyClicks) Export(ctx context.Context, day time.Time) (*File, error) {
+	day = day.UTC().Truncate(24 * time.Hour)
+	counts, err := d.source.AllDailyClicks(ctx, day)
+	if err != nil {
+		return nil, err
+	}
+	date := int32(day.Unix() / 86400)
+	rows := make([]dailyRow, 0, len(counts))
+	for key, clicks := range counts {
+		domain, code := storage.SplitLinkKey(key)
+		rows = append(rows, dailyRow{Date: date, Domain: domain, Code: code, Clicks: clicks})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Domain != rows[j].Domain {
+			return rows[i].Domain < rows[j].Domain
+		}
+		return rows[i].Code < rows[j].Code
+	})
+	return writeParquet(ctx, d.store, d.prefix+"daily_clicks/"+datePartition(day)+"/daily_clicks.parquet", rows)
+}
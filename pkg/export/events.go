@@ -0,0 +1,153 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"url-shortener/pkg/analytics"
+	"url-shortener/pkg/logging"
+)
+
+const (
+	eventQueueSize = 100000
+	// A file is tried writeAttempts times, waiting retryDelay, then twice
+	// that, between attempts
+	writeAttempts = 3
+	retryDelay    = time.Second
+	// finalWriteTimeout bounds the last write when Run stops
+	finalWriteTimeout = 30 * time.Second
+)
+
+// eventRow is a click event as a Parquet row
+type eventRow struct {
+	Code      string    `parquet:"code"`
+	Domain    string    `parquet:"domain"`
+	TenantID  string    `parquet:"tenant_id"`
+	OwnerID   string    `parquet:"owner_id"`
+	ClickedAt time.Time `parquet:"clicked_at,timestamp(millisecond)"`
+	Referrer  string    `parquet:"referrer"`
+	UserAgent string    `parquet:"user_agent"`
+}
+
+// ClickEvents is an analytics.Sink that writes the clicks it records to
+// Parquet files every interval, or sooner once maxRows are buffered. Clicks
+// still buffered when the process dies, or whose write fails, are lost.
+type ClickEvents struct {
+	store    Store
+	prefix   string
+	interval time.Duration
+	maxRows  int
+	logger   *logging.Logger
+	// retryDelay doubles after each failed attempt
+	retryDelay time.Duration
+
+	events  chan *analytics.Event
+	dropped atomic.Int64
+}
+
+func NewClickEvents(store Store, prefix string, interval time.Duration, maxRows int, logger *logging.Logger) *ClickEvents {
+	return &ClickEvents{
+		store:      store,
+		prefix:     prefix,
+		interval:   interval,
+		maxRows:    maxRows,
+		logger:     logger,
+		retryDelay: retryDelay,
+		events:     make(chan *analytics.Event, eventQueueSize),
+	}
+}
+
+func (e *ClickEvents) Record(event *analytics.Event) {
+	select {
+	case e.events <- event:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+func (e *ClickEvents) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	var rows []eventRow
+	write := func(ctx context.Context) {
+		if len(rows) > 0 {
+			e.write(ctx, rows, time.Now())
+			rows = nil
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.Background(), finalWriteTimeout)
+			defer cancel()
+			for len(e.events) > 0 {
+				rows = append(rows, toRow(<-e.events))
+			}
+			write(final)
+			return
+		case event := <-e.events:
+			rows = append(rows, toRow(event))
+			if len(rows) >= e.maxRows {
+				write(ctx)
+			}
+		case <-ticker.C:
+			write(ctx)
+			if n := e.dropped.Swap(0); n > 0 {
+				e.logger.Warn(ctx, "export queue full, click events dropped", "count", n)
+			}
+		}
+	}
+}
+
+func toRow(event *analytics.Event) eventRow {
+	return eventRow{
+		Code:      event.Code,
+		Domain:    event.Domain,
+		TenantID:  event.TenantID,
+		OwnerID:   event.OwnerID,
+		ClickedAt: event.ClickedAt.UTC(),
+		Referrer:  event.Referrer,
+		UserAgent: event.UserAgent,
+	}
+}
+
+// write splits rows by the hour they were clicked in and writes a file to
+// each hour's partition
+func (e *ClickEvents) write(ctx context.Context, rows []eventRow, now time.Time) []*File {
+	hours := make(map[time.Time][]eventRow)
+	for _, row := range rows {
+		hour := row.ClickedAt.Truncate(time.Hour)
+		hours[hour] = append(hours[hour], row)
+	}
+	var files []*File
+	for hour, rows := range hours {
+		sort.Slice(rows, func(i, j int) bool { return rows[i].ClickedAt.Before(rows[j].ClickedAt) })
+		key := fmt.Sprintf("%sclick_events/%s/hour=%02d/%s", e.prefix, datePartition(hour), hour.Hour(), uniqueName(now))
+		file, err := e.writeFile(ctx, key, rows)
+		if err != nil {
+			e.logger.Warn(ctx, "click event export failed, events dropped", "key", key, "events", len(rows), "error", err)
+			continue
+		}
+		files = append(files, file)
+	}
+	return files
+}
+
+func (e *ClickEvents) writeFile(ctx context.Context, key string, rows []eventRow) (*File, error) {
+	delay := e.retryDelay
+	for attempt := 1; ; attempt++ {
+		file, err := writeParquet(ctx, e.store, key, rows)
+		if err == nil || attempt == writeAttempts || ctx.Err() != nil {
+			return file, err
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
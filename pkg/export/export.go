@@ -0,0 +1,57 @@
+// Package export writes click analytics as Parquet files to S3-compatible
+// storage for data warehouses to load. Two datasets are written, under
+// Hive-style partitions so that warehouses can prune by date:
+//
+//	{prefix}click_events/date=2026-03-01/hour=13/{written}-{random}.parquet
+//	{prefix}daily_clicks/date=2026-03-01/daily_clicks.parquet
+//
+// Click events are buffered by every server that counts clicks and written
+// on a schedule, one file per hour of clicks per write; files are never
+// rewritten. Daily rollups are the per-link counts of a UTC day, exported
+// the day after by a job, and replaced when a day is exported again.
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+const parquetContentType = "application/vnd.apache.parquet"
+
+// Store is where files are written; *S3 implements it
+type Store interface {
+	Put(ctx context.Context, key, contentType string, body []byte) error
+}
+
+// File describes a written Parquet file
+type File struct {
+	Key  string `json:"key"`
+	Rows int    `json:"rows"`
+}
+
+func writeParquet[T any](ctx context.Context, store Store, key string, rows []T) (*File, error) {
+	var buf bytes.Buffer
+	if err := parquet.Write(&buf, rows, parquet.Compression(&parquet.Snappy)); err != nil {
+		return nil, err
+	}
+	if err := store.Put(ctx, key, parquetContentType, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return &File{Key: key, Rows: len(rows)}, nil
+}
+
+func datePartition(day time.Time) string {
+	return "date=" + day.UTC().Format("2006-01-02")
+}
+
+// uniqueName keeps files written by several servers at once apart
+func uniqueName(now time.Time) string {
+	var b [4]byte
+	rand.Read(b[:])
+	return now.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b[:]) + ".parquet"
+}
@@ -0,0 +1,136 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/analytics"
+	"url-shortener/pkg/logging"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memStore struct {
+	mu    sync.Mutex
+	files map[string][]byte
+	fails int
+}
+
+func (m *memStore) Put(ctx context.Context, key, contentType string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.fails > 0 {
+		m.fails--
+		return errors.New("unavailable")
+	}
+	if m.files == nil {
+		m.files = map[string][]byte{}
+	}
+	m.files[key] = body
+	return nil
+}
+
+func (m *memStore) keys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []string
+	for key := range m.files {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func readRows[T any](t *testing.T, body []byte) []T {
+	t.Helper()
+	rows, err := parquet.Read[T](bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+	return rows
+}
+
+func TestClickEventsPartitionByHour(t *testing.T) {
+	store := &memStore{fails: 1}
+	events := NewClickEvents(store, "exp/", time.Hour, 10, logging.NewLogger(logging.LevelError))
+	events.retryDelay = time.Millisecond
+
+	at := time.Date(2026, 3, 1, 13, 59, 0, 0, time.UTC)
+	for i, code := range []string{"a", "b", "c"} {
+		events.Record(&analytics.Event{Code: code, Domain: "sho.rt", ClickedAt: at.Add(time.Duration(i) * time.Minute)})
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	events.Run(ctx)
+
+	keys := store.keys()
+	require.Len(t, keys, 2)
+	assert.True(t, strings.HasPrefix(keys[0], "exp/click_events/date=2026-03-01/hour=13/"), keys[0])
+	assert.True(t, strings.HasPrefix(keys[1], "exp/click_events/date=2026-03-01/hour=14/"), keys[1])
+
+	rows := readRows[eventRow](t, store.files[keys[1]])
+	require.Len(t, rows, 2)
+	assert.Equal(t, "b", rows[0].Code)
+	assert.Equal(t, "sho.rt", rows[0].Domain)
+	assert.True(t, at.Add(time.Minute).Equal(rows[0].ClickedAt))
+}
+
+type fakeDailyClicks map[string]int64
+
+func (f fakeDailyClicks) AllDailyClicks(ctx context.Context, day time.Time) (map[string]int64, error) {
+	return f, nil
+}
+
+func TestDailyClicksExport(t *testing.T) {
+	store := &memStore{}
+	daily := NewDailyClicks(store, "", fakeDailyClicks{"abc": 3, "go.example/xyz": 5}, logging.NewLogger(logging.LevelError))
+
+	file, err := daily.Export(context.Background(), time.Date(2026, 3, 1, 18, 0, 0, 0, time.UTC))
+	require.NoError(t, err)
+	assert.Equal(t, "daily_clicks/date=2026-03-01/daily_clicks.parquet", file.Key)
+	assert.Equal(t, 2, file.Rows)
+
+	rows := readRows[dailyRow](t, store.files[file.Key])
+	days := int32(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+	assert.Equal(t, []dailyRow{
+		{Date: days, Domain: "", Code: "abc", Clicks: 3},
+		{Date: days, Domain: "go.example", Code: "xyz", Clicks: 5},
+	}, rows)
+}
+
+func TestS3PutSigns(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	s3, err := NewS3(S3Config{Endpoint: server.URL, Region: "eu-west-1", Bucket: "bucket", AccessKey: "AK", SecretKey: "SK"})
+	require.NoError(t, err)
+	s3.now = func() time.Time { return time.Date(2026, 3, 2, 1, 2, 3, 0, time.UTC) }
+	require.NoError(t, s3.Put(context.Background(), "exp/date=2026-03-01/x.parquet", parquetContentType, []byte("hello")))
+
+	require.NotNil(t, got)
+	assert.Equal(t, "/bucket/exp/date%3D2026-03-01/x.parquet", got.URL.EscapedPath())
+	assert.Equal(t, "hello", string(body))
+	assert.Equal(t, "20260302T010203Z", got.Header.Get("X-Amz-Date"))
+	assert.Contains(t, got.Header.Get("Authorization"), "Credential=AK/20260302/eu-west-1/s3/aws4_request, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=")
+
+	// The signature of the same request for host minio:9000, as computed by
+	// the AWS SDK
+	req, err := http.NewRequest(http.MethodPut, "http://minio:9000/bucket/exp/daily_clicks/date%3D2026-03-01/daily_clicks.parquet", nil)
+	require.NoError(t, err)
+	s3.sign(req, []byte("hello"))
+	assert.True(t, strings.HasSuffix(req.Header.Get("Authorization"), "Signature=06e4e2d90160cee82e0d987b13136104a496d751ace3cc33efb7d26b3770b60b"))
+}
@@ -0,0 +1,285 @@
+// Package fraud flags links whose clicks look manufactured. A Detector is
+// fed every counted click and raises an alert when
+//
+//   - a link gets a sudden burst of clicks, well above its recent rate, most
+//     of which come from one network (an ASN when the edge reports one,
+//     else a /24 or /48 address range), or
+//   - a visitor clicks from two countries closer together in time than
+//     travel allows.
+//
+// Alerts are stored for the admin API and announced to the owner's webhooks
+// as link.fraud_alert events. Clicks are tallied in memory by the server
+// that handled them, so with several replicas each one applies the
+// thresholds to its own share of the traffic.
+package fraud
+
+import (
+	"context"
+	"math"
+	"net/netip"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+)
+
+const (
+	queueSize = 10000
+	// history is how many past windows make up a link's normal rate
+	history = 12
+	// cooldown is the minimum time between alerts of one kind on a link
+	cooldown = time.Hour
+	// pruneInterval is how often idle links and visitors are forgotten
+	pruneInterval = time.Minute
+)
+
+// Alert kinds
+const (
+	KindClickSpike          = "click_spike"
+	KindImpossibleGeography = "impossible_geography"
+)
+
+// Thresholds tune the detector
+type Thresholds struct {
+	// Window is the period clicks are counted over
+	Window time.Duration
+	// A window is a spike with at least MinClicks clicks, SpikeFactor times
+	// the average of the previous windows, RangeShare (0-1) of them from one
+	// network
+	MinClicks   int
+	SpikeFactor float64
+	RangeShare  float64
+	// GeoWindow is how soon after a click from one country a click by the
+	// same visitor from another is impossible
+	GeoWindow time.Duration
+}
+
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		Window:      5 * time.Minute,
+		MinClicks:   100,
+		SpikeFactor: 10,
+		RangeShare:  0.5,
+		GeoWindow:   time.Hour,
+	}
+}
+
+// Click is a counted visit. ASN, Country and Visitor are empty when unknown.
+type Click struct {
+	Key     string
+	OwnerID *uuid.UUID
+	IP      netip.Addr
+	ASN     string
+	Country string
+	Visitor string
+	At      time.Time
+}
+
+// network is where a click came from, for grouping
+func (c *Click) network() string {
+	if c.ASN != "" {
+		return "AS" + c.ASN
+	}
+	if !c.IP.IsValid() {
+		return ""
+	}
+	bits := 48
+	if c.IP.Unmap().Is4() {
+		bits = 24
+	}
+	prefix, _ := c.IP.Unmap().Prefix(bits)
+	return prefix.String()
+}
+
+// linkState counts a link's clicks in the current window and remembers the
+// totals of the previous ones
+type linkState struct {
+	windowStart time.Time
+	total       int
+	networks    map[string]int
+	past        []int
+	lastAlert   map[string]time.Time
+}
+
+type sighting struct {
+	country string
+	at      time.Time
+}
+
+type Detector struct {
+	store      storage.FraudStorage
+	logger     *logging.Logger
+	thresholds Thresholds
+	clicks     chan *Click
+
+	// Owned by the Run goroutine
+	links    map[string]*linkState
+	visitors map[string]sighting
+}
+
+func NewDetector(store storage.FraudStorage, thresholds Thresholds, logger *logging.Logger) *Detector {
+	return &Detector{
+		store:      store,
+		logger:     logger,
+		thresholds: thresholds,
+		clicks:     make(chan *Click, queueSize),
+		links:      make(map[string]*linkState),
+		visitors:   make(map[string]sighting),
+	}
+}
+
+// Observe queues a click without blocking; if the queue is full the click is
+// skipped so that redirects never wait on detection
+func (d *Detector) Observe(click *Click) {
+	select {
+	case d.clicks <- click:
+	default:
+	}
+}
+
+// Run examines clicks until ctx is done
+func (d *Detector) Run(ctx context.Context) {
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case click := <-d.clicks:
+			for _, alert := range d.examine(click) {
+				if err := d.store.CreateFraudAlert(ctx, alert); err != nil {
+					d.logger.Error(ctx, "failed to save fraud alert", "code", alert.Code, "kind", alert.Kind, "error", err)
+					continue
+				}
+				d.logger.Warn(ctx, "suspicious clicks", "code", alert.Code, "kind", alert.Kind)
+			}
+		case now := <-prune.C:
+			d.prune(now)
+		}
+	}
+}
+
+// examine records click and returns the alerts it triggers
+func (d *Detector) examine(click *Click) []*storage.FraudAlert {
+	state := d.links[click.Key]
+	if state == nil {
+		state = &linkState{windowStart: click.At, networks: make(map[string]int), lastAlert: make(map[string]time.Time)}
+		d.links[click.Key] = state
+	}
+	d.advance(state, click.At)
+
+	var alerts []*storage.FraudAlert
+	state.total++
+	network := click.network()
+	if network != "" {
+		state.networks[network]++
+		if detail := d.spike(state, network); detail != nil {
+			alerts = d.raise(alerts, click, state, KindClickSpike, detail)
+		}
+	}
+
+	if click.Visitor != "" && click.Country != "" {
+		last, seen := d.visitors[click.Visitor]
+		d.visitors[click.Visitor] = sighting{country: click.Country, at: click.At}
+		if seen && last.country != click.Country && click.At.Sub(last.at) < d.thresholds.GeoWindow {
+			alerts = d.raise(alerts, click, state, KindImpossibleGeography, map[string]any{
+				"countries": []string{last.country, click.Country},
+				"seconds":   int(click.At.Sub(last.at).Seconds()),
+			})
+		}
+	}
+	return alerts
+}
+
+// advance moves state's window forward to the one containing now
+func (d *Detector) advance(state *linkState, now time.Time) {
+	for now.Sub(state.windowStart) >= d.thresholds.Window {
+		state.past = append(state.past, state.total)
+		if len(state.past) > history {
+			state.past = state.past[1:]
+		}
+		state.windowStart = state.windowStart.Add(d.thresholds.Window)
+		state.total = 0
+		clear(state.networks)
+		// Skip the rest of a long idle period in one step
+		if now.Sub(state.windowStart) >= history*d.thresholds.Window {
+			state.past = state.past[:0]
+			state.windowStart = now
+		}
+	}
+}
+
+// spike returns the details of a spike from network, or nil
+func (d *Detector) spike(state *linkState, network string) map[string]any {
+	t := d.thresholds
+	if state.total < t.MinClicks || float64(state.networks[network]) < t.RangeShare*float64(state.total) {
+		return nil
+	}
+	baseline := 0.0
+	for _, n := range state.past {
+		baseline += float64(n)
+	}
+	if len(state.past) > 0 {
+		baseline /= float64(len(state.past))
+	}
+	// A link with no history spikes as soon as it reaches MinClicks
+	if float64(state.total) < t.SpikeFactor*max(baseline, 1) {
+		return nil
+	}
+	return map[string]any{
+		"network":        network,
+		"clicks":         state.total,
+		"network_clicks": state.networks[network],
+		"baseline":       math.Round(baseline*10) / 10,
+		"window":         t.Window.String(),
+	}
+}
+
+// raise appends an alert unless one of kind was raised on the link recently
+func (d *Detector) raise(alerts []*storage.FraudAlert, click *Click, state *linkState, kind string, detail map[string]any) []*storage.FraudAlert {
+	if last, ok := state.lastAlert[kind]; ok && click.At.Sub(last) < cooldown {
+		return alerts
+	}
+	state.lastAlert[kind] = click.At
+	domain, code := storage.SplitLinkKey(click.Key)
+	var alertDomain *string
+	if domain != "" {
+		alertDomain = &domain
+	}
+	return append(alerts, &storage.FraudAlert{
+		Code:      code,
+		Domain:    alertDomain,
+		OwnerID:   click.OwnerID,
+		Kind:      kind,
+		Detail:    detail,
+		CreatedAt: click.At,
+	})
+}
+
+// prune forgets links without clicks for the whole history and visitors
+// last seen longer than GeoWindow ago
+func (d *Detector) prune(now time.Time) {
+	for key, state := range d.links {
+		if now.Sub(state.windowStart) >= history*d.thresholds.Window && now.Sub(latest(state.lastAlert)) >= cooldown {
+			delete(d.links, key)
+		}
+	}
+	for visitor, s := range d.visitors {
+		if now.Sub(s.at) >= d.thresholds.GeoWindow {
+			delete(d.visitors, visitor)
+		}
+	}
+}
+
+func latest(times map[string]time.Time) time.Time {
+	var t time.Time
+	for _, at := range times {
+		if at.After(t) {
+			t = at
+		}
+	}
+	return t
+}
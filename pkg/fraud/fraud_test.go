@@ -0,0 +1,106 @@
+package fraud
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDetector() *Detector {
+	t := DefaultThresholds()
+	t.MinClicks = 20
+	t.SpikeFactor = 5
+	return NewDetector(nil, t, nil)
+}
+
+// clicks sends n clicks on key from ip, one per second from start, and
+// returns the alerts raised
+func clicks(d *Detector, key, ip string, n int, start time.Time) []*storage.FraudAlert {
+	var alerts []*storage.FraudAlert
+	for i := 0; i < n; i++ {
+		alerts = append(alerts, d.examine(&Click{Key: key, IP: netip.MustParseAddr(ip), At: start.Add(time.Duration(i) * time.Second)})...)
+	}
+	return alerts
+}
+
+func TestClickSpikeFromOneRange(t *testing.T) {
+	d := newTestDetector()
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// A steady hour of 4 clicks per window from all over
+	for w := 0; w < 12; w++ {
+		for i, ip := range []string{"198.51.100.1", "203.0.113.9", "192.0.2.4", "2001:db8::1"} {
+			assert.Empty(t, clicks(d, "go.acme.io/promo", ip, 1, start.Add(time.Duration(w)*5*time.Minute+time.Duration(i)*time.Second)))
+		}
+	}
+
+	// Then a burst from one /24
+	burst := start.Add(time.Hour)
+	alerts := clicks(d, "go.acme.io/promo", "203.0.113.77", 30, burst)
+	require.Len(t, alerts, 1, "one alert per cooldown")
+	alert := alerts[0]
+	assert.Equal(t, KindClickSpike, alert.Kind)
+	assert.Equal(t, "promo", alert.Code)
+	assert.Equal(t, "go.acme.io", *alert.Domain)
+	assert.Equal(t, "203.0.113.0/24", alert.Detail["network"])
+	assert.Equal(t, 20, alert.Detail["clicks"])
+	assert.Equal(t, 4.0, alert.Detail["baseline"])
+}
+
+func TestBusyLinkIsNotASpike(t *testing.T) {
+	d := newTestDetector()
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// 30 clicks per window, all from one network, is this link's normal
+	for w := 0; w < 12; w++ {
+		clicks(d, "busy", "10.0.0.1", 30, start.Add(time.Duration(w)*5*time.Minute))
+	}
+	d.links["busy"].lastAlert = map[string]time.Time{}
+	assert.Empty(t, clicks(d, "busy", "10.0.0.1", 30, start.Add(time.Hour)))
+
+	// A burst from many networks isn't attributed to one
+	d = newTestDetector()
+	var alerts []*storage.FraudAlert
+	for i := 0; i < 40; i++ {
+		ip := netip.AddrFrom4([4]byte{byte(i + 1), 0, 0, 1})
+		alerts = append(alerts, d.examine(&Click{Key: "viral", IP: ip, At: start})...)
+	}
+	assert.Empty(t, alerts)
+}
+
+func TestImpossibleGeography(t *testing.T) {
+	d := newTestDetector()
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	assert.Empty(t, d.examine(&Click{Key: "abc", Visitor: "v1", Country: "DE", At: start}))
+	assert.Empty(t, d.examine(&Click{Key: "abc", Visitor: "v1", Country: "DE", At: start.Add(time.Minute)}))
+	assert.Empty(t, d.examine(&Click{Key: "abc", Visitor: "v2", Country: "BR", At: start.Add(time.Minute)}))
+
+	alerts := d.examine(&Click{Key: "abc", Visitor: "v1", Country: "JP", At: start.Add(10 * time.Minute)})
+	require.Len(t, alerts, 1)
+	assert.Equal(t, KindImpossibleGeography, alerts[0].Kind)
+	assert.Equal(t, []string{"DE", "JP"}, alerts[0].Detail["countries"])
+	assert.Nil(t, alerts[0].Domain)
+
+	// Slow enough to travel
+	assert.Empty(t, d.examine(&Click{Key: "xyz", Visitor: "v2", Country: "AR", At: start.Add(3 * time.Hour)}))
+}
+
+func TestPrune(t *testing.T) {
+	d := newTestDetector()
+	start := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	d.examine(&Click{Key: "abc", Visitor: "v1", Country: "DE", At: start})
+
+	d.prune(start.Add(time.Minute))
+	assert.Len(t, d.links, 1)
+	assert.Len(t, d.visitors, 1)
+
+	d.prune(start.Add(2 * time.Hour))
+	assert.Empty(t, d.links)
+	assert.Empty(t, d.visitors)
+}
@@ -0,0 +1,160 @@
+// Package graphql serves a read-only GraphQL API for dashboard clients so
+// a link, its tags and stats can be fetched in a single request. Every
+// resolver is scoped to the authenticated owner.
+package graphql
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"net/http"
+
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/storage"
+
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+const maxPageSize = 100
+
+// NewHandler parses the schema against the resolvers and returns an
+// http.Handler accepting standard GraphQL POST requests.
+func NewHandler(linkService *service.LinkService) (http.Handler, error) {
+	schema, err := graphql.ParseSchema(schemaSDL, &Resolver{linkService: linkService}, graphql.MaxDepth(5))
+	if err != nil {
+		return nil, err
+	}
+	return &relay.Handler{Schema: schema}, nil
+}
+
+type Resolver struct {
+	linkService *service.LinkService
+}
+
+func (r *Resolver) Link(ctx context.Context, args struct{ Code string }) (*linkResolver, error) {
+	link, err := r.linkService.GetOwnedLink(ctx, args.Code)
+	if errors.Is(err, service.ErrLinkNotFound) || errors.Is(err, service.ErrNotOwner) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &linkResolver{link: link, linkService: r.linkService}, nil
+}
+
+// linkOrders maps the LinkSort enum to storage orders
+var linkOrders = map[string]string{
+	"NEWEST":  storage.LinkOrderNewest,
+	"STALEST": storage.LinkOrderStalest,
+}
+
+func (r *Resolver) Links(ctx context.Context, args struct {
+	First  int32
+	Offset int32
+	Sort   string
+}) ([]*linkResolver, error) {
+	first := int(args.First)
+	if first <= 0 || first > maxPageSize {
+		first = maxPageSize
+	}
+	offset := int(args.Offset)
+	if offset < 0 {
+		offset = 0
+	}
+
+	links, err := r.linkService.ListLinks(ctx, storage.LinkQuery{Order: linkOrders[args.Sort]}, first, offset)
+	if err != nil {
+		return nil, err
+	}
+	resolvers := make([]*linkResolver, len(links))
+	for i, link := range links {
+		resolvers[i] = &linkResolver{link: link, linkService: r.linkService}
+	}
+	return resolvers, nil
+}
+
+func (r *Resolver) Tags(ctx context.Context) ([]string, error) {
+	tags, err := r.linkService.ListTags(ctx)
+	if tags == nil {
+		tags = []string{}
+	}
+	return tags, err
+}
+
+type linkResolver struct {
+	link        *storage.Link
+	linkService *service.LinkService
+}
+
+func (l *linkResolver) Code() string    { return l.link.Code }
+func (l *linkResolver) LongURL() string { return l.link.LongURL }
+func (l *linkResolver) Alias() *string  { return l.link.Alias }
+
+func (l *linkResolver) Description() *string { return l.link.Description }
+
+func (l *linkResolver) HasPassword() bool { return l.link.PasswordHash != nil }
+
+func (l *linkResolver) ExpiresAt() *graphql.Time {
+	if l.link.ExpiresAt == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *l.link.ExpiresAt}
+}
+
+func (l *linkResolver) MaxClicks() *int32 { return toInt32Ptr(l.link.MaxClicks) }
+
+func (l *linkResolver) CreatedAt() graphql.Time { return graphql.Time{Time: l.link.CreatedAt} }
+
+func (l *linkResolver) Tags() []string {
+	if l.link.Tags == nil {
+		return []string{}
+	}
+	return l.link.Tags
+}
+
+func (l *linkResolver) Stats() *statsResolver {
+	return &statsResolver{stats: l.linkService.Stats(l.link)}
+}
+
+func (l *linkResolver) Health() *healthResolver {
+	if l.link.Health == nil {
+		return nil
+	}
+	return &healthResolver{health: l.link.Health}
+}
+
+type healthResolver struct {
+	health *storage.LinkHealth
+}
+
+func (h *healthResolver) Status() string          { return h.health.Status }
+func (h *healthResolver) HTTPStatus() *int32      { return toInt32Ptr(h.health.HTTPStatus) }
+func (h *healthResolver) CheckedAt() graphql.Time { return graphql.Time{Time: h.health.CheckedAt} }
+
+type statsResolver struct {
+	stats *service.LinkStats
+}
+
+func (s *statsResolver) TotalClicks() int32      { return int32(s.stats.TotalClicks) }
+func (s *statsResolver) MaxClicks() *int32       { return toInt32Ptr(s.stats.MaxClicks) }
+func (s *statsResolver) RemainingClicks() *int32 { return toInt32Ptr(s.stats.RemainingClicks) }
+func (s *statsResolver) Expired() bool           { return s.stats.Expired }
+
+func (s *statsResolver) LastClickedAt() *graphql.Time {
+	if s.stats.LastClickedAt == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *s.stats.LastClickedAt}
+}
+
+func toInt32Ptr(n *int) *int32 {
+	if n == nil {
+		return nil
+	}
+	v := int32(*n)
+	return &v
+}
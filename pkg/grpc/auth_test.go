@@ -0,0 +1,54 @@
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"testing"
+
+	"url-shortener/pkg/grpc/linkspb"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+func peerContext(commonName string) context.Context {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+	return peer.NewContext(context.Background(), &peer.Peer{
+		AuthInfo: credentials.TLSInfo{
+			State: tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}},
+		},
+	})
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	auth := NewAuthInterceptor(map[string][]string{
+		"billing": {"links:read", "links:write"},
+		"crm":     {"links:read"},
+	})
+
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		method string
+		code   codes.Code
+	}{
+		{"no certificate", context.Background(), linkspb.LinkService_GetLink_FullMethodName, codes.Unauthenticated},
+		{"read allowed", peerContext("crm"), linkspb.LinkService_ResolveLink_FullMethodName, codes.OK},
+		{"write denied", peerContext("crm"), linkspb.LinkService_CreateLink_FullMethodName, codes.PermissionDenied},
+		{"write allowed", peerContext("billing"), linkspb.LinkService_CreateLink_FullMethodName, codes.OK},
+		{"unknown client", peerContext("other"), linkspb.LinkService_GetLink_FullMethodName, codes.PermissionDenied},
+		{"unknown method", peerContext("billing"), "/links.v1.LinkService/Drop", codes.PermissionDenied},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := auth.authorize(tt.ctx, tt.method)
+			assert.Equal(t, tt.code, status.Code(err))
+		})
+	}
+}
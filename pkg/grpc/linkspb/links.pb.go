@@ -0,0 +1,532 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: links/v1/links.proto
+
+package linkspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Link struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	LongUrl       string                 `protobuf:"bytes,2,opt,name=long_url,json=longUrl,proto3" json:"long_url,omitempty"`
+	Alias         *string                `protobuf:"bytes,3,opt,name=alias,proto3,oneof" json:"alias,omitempty"`
+	ExpiresAt     *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	MaxClicks     *int32                 `protobuf:"varint,5,opt,name=max_clicks,json=maxClicks,proto3,oneof" json:"max_clicks,omitempty"`
+	ClickCount    int64                  `protobuf:"varint,6,opt,name=click_count,json=clickCount,proto3" json:"click_count,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	OwnerId       string                 `protobuf:"bytes,8,opt,name=owner_id,json=ownerId,proto3" json:"owner_id,omitempty"`
+	HasPassword   bool                   `protobuf:"varint,9,opt,name=has_password,json=hasPassword,proto3" json:"has_password,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Link) Reset() {
+	*x = Link{}
+	mi := &file_links_v1_links_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Link) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Link) ProtoMessage() {}
+
+func (x *Link) ProtoReflect() protoreflect.Message {
+	mi := &file_links_v1_links_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Link.ProtoReflect.Descriptor instead.
+func (*Link) Descriptor() ([]byte, []int) {
+	return file_links_v1_links_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Link) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Link) GetLongUrl() string {
+	if x != nil {
+		return x.LongUrl
+	}
+	return ""
+}
+
+func (x *Link) GetAlias() string {
+	if x != nil && x.Alias != nil {
+		return *x.Alias
+	}
+	return ""
+}
+
+func (x *Link) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *Link) GetMaxClicks() int32 {
+	if x != nil && x.MaxClicks != nil {
+		return *x.MaxClicks
+	}
+	return 0
+}
+
+func (x *Link) GetClickCount() int64 {
+	if x != nil {
+		return x.ClickCount
+	}
+	return 0
+}
+
+func (x *Link) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Link) GetOwnerId() string {
+	if x != nil {
+		return x.OwnerId
+	}
+	return ""
+}
+
+func (x *Link) GetHasPassword() bool {
+	if x != nil {
+		return x.HasPassword
+	}
+	return false
+}
+
+type CreateLinkRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	OwnerId       string                 `protobuf:"bytes,1,opt,name=owner_id,json=ownerId,proto3" json:"owner_id,omitempty"`
+	LongUrl       string                 `protobuf:"bytes,2,opt,name=long_url,json=longUrl,proto3" json:"long_url,omitempty"`
+	Alias         *string                `protobuf:"bytes,3,opt,name=alias,proto3,oneof" json:"alias,omitempty"`
+	Password      *string                `protobuf:"bytes,4,opt,name=password,proto3,oneof" json:"password,omitempty"`
+	ExpiresAt     *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	MaxClicks     *int32                 `protobuf:"varint,6,opt,name=max_clicks,json=maxClicks,proto3,oneof" json:"max_clicks,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateLinkRequest) Reset() {
+	*x = CreateLinkRequest{}
+	mi := &file_links_v1_links_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateLinkRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateLinkRequest) ProtoMessage() {}
+
+func (x *CreateLinkRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_links_v1_links_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateLinkRequest.ProtoReflect.Descriptor instead.
+func (*CreateLinkRequest) Descriptor() ([]byte, []int) {
+	return file_links_v1_links_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CreateLinkRequest) GetOwnerId() string {
+	if x != nil {
+		return x.OwnerId
+	}
+	return ""
+}
+
+func (x *CreateLinkRequest) GetLongUrl() string {
+	if x != nil {
+		return x.LongUrl
+	}
+	return ""
+}
+
+func (x *CreateLinkRequest) GetAlias() string {
+	if x != nil && x.Alias != nil {
+		return *x.Alias
+	}
+	return ""
+}
+
+func (x *CreateLinkRequest) GetPassword() string {
+	if x != nil && x.Password != nil {
+		return *x.Password
+	}
+	return ""
+}
+
+func (x *CreateLinkRequest) GetExpiresAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return nil
+}
+
+func (x *CreateLinkRequest) GetMaxClicks() int32 {
+	if x != nil && x.MaxClicks != nil {
+		return *x.MaxClicks
+	}
+	return 0
+}
+
+type CreateLinkResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	ShortUrl      string                 `protobuf:"bytes,2,opt,name=short_url,json=shortUrl,proto3" json:"short_url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateLinkResponse) Reset() {
+	*x = CreateLinkResponse{}
+	mi := &file_links_v1_links_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateLinkResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateLinkResponse) ProtoMessage() {}
+
+func (x *CreateLinkResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_links_v1_links_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateLinkResponse.ProtoReflect.Descriptor instead.
+func (*CreateLinkResponse) Descriptor() ([]byte, []int) {
+	return file_links_v1_links_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateLinkResponse) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *CreateLinkResponse) GetShortUrl() string {
+	if x != nil {
+		return x.ShortUrl
+	}
+	return ""
+}
+
+type GetLinkRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetLinkRequest) Reset() {
+	*x = GetLinkRequest{}
+	mi := &file_links_v1_links_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetLinkRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetLinkRequest) ProtoMessage() {}
+
+func (x *GetLinkRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_links_v1_links_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetLinkRequest.ProtoReflect.Descriptor instead.
+func (*GetLinkRequest) Descriptor() ([]byte, []int) {
+	return file_links_v1_links_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetLinkRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+type ResolveLinkRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Code  string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	// count_click records the resolution as a click when true.
+	CountClick    bool `protobuf:"varint,2,opt,name=count_click,json=countClick,proto3" json:"count_click,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResolveLinkRequest) Reset() {
+	*x = ResolveLinkRequest{}
+	mi := &file_links_v1_links_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResolveLinkRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolveLinkRequest) ProtoMessage() {}
+
+func (x *ResolveLinkRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_links_v1_links_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolveLinkRequest.ProtoReflect.Descriptor instead.
+func (*ResolveLinkRequest) Descriptor() ([]byte, []int) {
+	return file_links_v1_links_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ResolveLinkRequest) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ResolveLinkRequest) GetCountClick() bool {
+	if x != nil {
+		return x.CountClick
+	}
+	return false
+}
+
+type ResolveLinkResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	LongUrl       string                 `protobuf:"bytes,2,opt,name=long_url,json=longUrl,proto3" json:"long_url,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ResolveLinkResponse) Reset() {
+	*x = ResolveLinkResponse{}
+	mi := &file_links_v1_links_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ResolveLinkResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ResolveLinkResponse) ProtoMessage() {}
+
+func (x *ResolveLinkResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_links_v1_links_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ResolveLinkResponse.ProtoReflect.Descriptor instead.
+func (*ResolveLinkResponse) Descriptor() ([]byte, []int) {
+	return file_links_v1_links_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ResolveLinkResponse) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ResolveLinkResponse) GetLongUrl() string {
+	if x != nil {
+		return x.LongUrl
+	}
+	return ""
+}
+
+var File_links_v1_links_proto protoreflect.FileDescriptor
+
+const file_links_v1_links_proto_rawDesc = "" +
+	"\n" +
+	"\x14links/v1/links.proto\x12\blinks.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xe2\x02\n" +
+	"\x04Link\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x19\n" +
+	"\blong_url\x18\x02 \x01(\tR\alongUrl\x12\x19\n" +
+	"\x05alias\x18\x03 \x01(\tH\x00R\x05alias\x88\x01\x01\x129\n" +
+	"\n" +
+	"expires_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12\"\n" +
+	"\n" +
+	"max_clicks\x18\x05 \x01(\x05H\x01R\tmaxClicks\x88\x01\x01\x12\x1f\n" +
+	"\vclick_count\x18\x06 \x01(\x03R\n" +
+	"clickCount\x129\n" +
+	"\n" +
+	"created_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x12\x19\n" +
+	"\bowner_id\x18\b \x01(\tR\aownerId\x12!\n" +
+	"\fhas_password\x18\t \x01(\bR\vhasPasswordB\b\n" +
+	"\x06_aliasB\r\n" +
+	"\v_max_clicks\"\x8a\x02\n" +
+	"\x11CreateLinkRequest\x12\x19\n" +
+	"\bowner_id\x18\x01 \x01(\tR\aownerId\x12\x19\n" +
+	"\blong_url\x18\x02 \x01(\tR\alongUrl\x12\x19\n" +
+	"\x05alias\x18\x03 \x01(\tH\x00R\x05alias\x88\x01\x01\x12\x1f\n" +
+	"\bpassword\x18\x04 \x01(\tH\x01R\bpassword\x88\x01\x01\x129\n" +
+	"\n" +
+	"expires_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\texpiresAt\x12\"\n" +
+	"\n" +
+	"max_clicks\x18\x06 \x01(\x05H\x02R\tmaxClicks\x88\x01\x01B\b\n" +
+	"\x06_aliasB\v\n" +
+	"\t_passwordB\r\n" +
+	"\v_max_clicks\"E\n" +
+	"\x12CreateLinkResponse\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x1b\n" +
+	"\tshort_url\x18\x02 \x01(\tR\bshortUrl\"$\n" +
+	"\x0eGetLinkRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\"I\n" +
+	"\x12ResolveLinkRequest\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x1f\n" +
+	"\vcount_click\x18\x02 \x01(\bR\n" +
+	"countClick\"D\n" +
+	"\x13ResolveLinkResponse\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x19\n" +
+	"\blong_url\x18\x02 \x01(\tR\alongUrl2\xd7\x01\n" +
+	"\vLinkService\x12G\n" +
+	"\n" +
+	"CreateLink\x12\x1b.links.v1.CreateLinkRequest\x1a\x1c.links.v1.CreateLinkResponse\x123\n" +
+	"\aGetLink\x12\x18.links.v1.GetLinkRequest\x1a\x0e.links.v1.Link\x12J\n" +
+	"\vResolveLink\x12\x1c.links.v1.ResolveLinkRequest\x1a\x1d.links.v1.ResolveLinkResponseB Z\x1eurl-shortener/pkg/grpc/linkspbb\x06proto3"
+
+var (
+	file_links_v1_links_proto_rawDescOnce sync.Once
+	file_links_v1_links_proto_rawDescData []byte
+)
+
+func file_links_v1_links_proto_rawDescGZIP() []byte {
+	file_links_v1_links_proto_rawDescOnce.Do(func() {
+		file_links_v1_links_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_links_v1_links_proto_rawDesc), len(file_links_v1_links_proto_rawDesc)))
+	})
+	return file_links_v1_links_proto_rawDescData
+}
+
+var file_links_v1_links_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_links_v1_links_proto_goTypes = []any{
+	(*Link)(nil),                  // 0: links.v1.Link
+	(*CreateLinkRequest)(nil),     // 1: links.v1.CreateLinkRequest
+	(*CreateLinkResponse)(nil),    // 2: links.v1.CreateLinkResponse
+	(*GetLinkRequest)(nil),        // 3: links.v1.GetLinkRequest
+	(*ResolveLinkRequest)(nil),    // 4: links.v1.ResolveLinkRequest
+	(*ResolveLinkResponse)(nil),   // 5: links.v1.ResolveLinkResponse
+	(*timestamppb.Timestamp)(nil), // 6: google.protobuf.Timestamp
+}
+var file_links_v1_links_proto_depIdxs = []int32{
+	6, // 0: links.v1.Link.expires_at:type_name -> google.protobuf.Timestamp
+	6, // 1: links.v1.Link.created_at:type_name -> google.protobuf.Timestamp
+	6, // 2: links.v1.CreateLinkRequest.expires_at:type_name -> google.protobuf.Timestamp
+	1, // 3: links.v1.LinkService.CreateLink:input_type -> links.v1.CreateLinkRequest
+	3, // 4: links.v1.LinkService.GetLink:input_type -> links.v1.GetLinkRequest
+	4, // 5: links.v1.LinkService.ResolveLink:input_type -> links.v1.ResolveLinkRequest
+	2, // 6: links.v1.LinkService.CreateLink:output_type -> links.v1.CreateLinkResponse
+	0, // 7: links.v1.LinkService.GetLink:output_type -> links.v1.Link
+	5, // 8: links.v1.LinkService.ResolveLink:output_type -> links.v1.ResolveLinkResponse
+	6, // [6:9] is the sub-list for method output_type
+	3, // [3:6] is the sub-list for method input_type
+	3, // [3:3] is the sub-list for extension type_name
+	3, // [3:3] is the sub-list for extension extendee
+	0, // [0:3] is the sub-list for field type_name
+}
+
+func init() { file_links_v1_links_proto_init() }
+func file_links_v1_links_proto_init() {
+	if File_links_v1_links_proto != nil {
+		return
+	}
+	file_links_v1_links_proto_msgTypes[0].OneofWrappers = []any{}
+	file_links_v1_links_proto_msgTypes[1].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_links_v1_links_proto_rawDesc), len(file_links_v1_links_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_links_v1_links_proto_goTypes,
+		DependencyIndexes: file_links_v1_links_proto_depIdxs,
+		MessageInfos:      file_links_v1_links_proto_msgTypes,
+	}.Build()
+	File_links_v1_links_proto = out.File
+	file_links_v1_links_proto_goTypes = nil
+	file_links_v1_links_proto_depIdxs = nil
+}
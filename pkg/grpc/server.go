@@ -0,0 +1,195 @@
+// Package grpc exposes LinkService to internal callers over gRPC. The
+// protobuf definitions live in proto/links/v1; regenerate linkspb with
+// `make proto`.
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"strings"
+
+	"url-shortener/pkg/grpc/linkspb"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type Server struct {
+	linkspb.UnimplementedLinkServiceServer
+	linkService *service.LinkService
+	maintenance *service.Maintenance
+}
+
+func NewServer(linkService *service.LinkService) *Server {
+	return &Server{linkService: linkService}
+}
+
+// UseMaintenance refuses CreateLink with Unavailable during maintenance mode
+func (s *Server) UseMaintenance(maintenance *service.Maintenance) {
+	s.maintenance = maintenance
+}
+
+// NewGRPCServer builds a gRPC server that requires mutual TLS and applies
+// per-method scope checks for the configured client identities.
+func NewGRPCServer(srv *Server, creds credentials.TransportCredentials, auth *AuthInterceptor) *grpc.Server {
+	s := grpc.NewServer(
+		grpc.Creds(creds),
+		grpc.UnaryInterceptor(auth.Unary()),
+	)
+	linkspb.RegisterLinkServiceServer(s, srv)
+	return s
+}
+
+// NewMTLSCredentials loads the server key pair and the CA used to verify
+// client certificates.
+func NewMTLSCredentials(certFile, keyFile, clientCAFile string) (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	caPEM, err := os.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client CA: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New("no certificates found in client CA file")
+	}
+
+	return credentials.NewTLS(&tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+		MinVersion:   tls.VersionTLS12,
+	}), nil
+}
+
+func (s *Server) CreateLink(ctx context.Context, req *linkspb.CreateLinkRequest) (*linkspb.CreateLinkResponse, error) {
+	if s.maintenance != nil {
+		if message, on := s.maintenance.Refusal(); on {
+			return nil, status.Error(codes.Unavailable, message)
+		}
+	}
+	ownerID, err := uuid.Parse(req.GetOwnerId())
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, "owner_id must be a UUID")
+	}
+	ctx = middleware.WithOwnerID(ctx, ownerID)
+	// Links record the calling service as their client
+	ctx = middleware.WithClient(middleware.WithClientID(ctx, clientName(ctx)), peerClient(ctx))
+
+	createReq := &service.CreateLinkRequest{
+		LongURL:  req.GetLongUrl(),
+		Alias:    req.Alias,
+		Password: req.Password,
+	}
+	if req.ExpiresAt != nil {
+		expiresAt := req.ExpiresAt.AsTime()
+		createReq.ExpiresAt = &expiresAt
+	}
+	if req.MaxClicks != nil {
+		maxClicks := int(req.GetMaxClicks())
+		createReq.MaxClicks = &maxClicks
+	}
+
+	resp, err := s.linkService.CreateLink(ctx, createReq)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &linkspb.CreateLinkResponse{Code: resp.Code, ShortUrl: resp.ShortURL}, nil
+}
+
+func (s *Server) GetLink(ctx context.Context, req *linkspb.GetLinkRequest) (*linkspb.Link, error) {
+	link, err := s.linkService.GetLink(ctx, req.GetCode())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	if link == nil || link.LongURL == "" {
+		return nil, status.Error(codes.NotFound, "link not found")
+	}
+	return toProtoLink(link), nil
+}
+
+func (s *Server) ResolveLink(ctx context.Context, req *linkspb.ResolveLinkRequest) (*linkspb.ResolveLinkResponse, error) {
+	link, err := s.linkService.ResolveLink(ctx, req.GetCode(), req.GetCountClick())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &linkspb.ResolveLinkResponse{Code: link.Code, LongUrl: link.LongURL}, nil
+}
+
+func toProtoLink(link *storage.Link) *linkspb.Link {
+	pb := &linkspb.Link{
+		Code:        link.Code,
+		LongUrl:     link.LongURL,
+		Alias:       link.Alias,
+		ClickCount:  int64(link.ClickCount),
+		CreatedAt:   timestamppb.New(link.CreatedAt),
+		HasPassword: link.PasswordHash != nil,
+	}
+	if link.ExpiresAt != nil {
+		pb.ExpiresAt = timestamppb.New(*link.ExpiresAt)
+	}
+	if link.MaxClicks != nil {
+		maxClicks := int32(*link.MaxClicks)
+		pb.MaxClicks = &maxClicks
+	}
+	if link.OwnerID != nil {
+		pb.OwnerId = link.OwnerID.String()
+	}
+	return pb
+}
+
+// toStatus maps service errors onto gRPC status codes
+func toStatus(err error) error {
+	var verrs validation.Errors
+	switch {
+	case errors.As(err, &verrs):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrLinkNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrLinkExpired), errors.Is(err, service.ErrLinkDisabled), errors.Is(err, service.ErrTermsNotAccepted):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrPasswordRequired), errors.Is(err, service.ErrNotOwner), errors.Is(err, service.ErrAccountSuspended):
+		return status.Error(codes.PermissionDenied, err.Error())
+	case errors.Is(err, service.ErrCodeExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrQuotaExceeded):
+		return status.Error(codes.ResourceExhausted, err.Error())
+	case strings.HasPrefix(err.Error(), "invalid"):
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return status.Error(codes.Internal, "internal error")
+}
+
+// peerClient is the address and user agent of the caller of an RPC
+func peerClient(ctx context.Context) middleware.ClientInfo {
+	var info middleware.ClientInfo
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if addrPort, err := netip.ParseAddrPort(p.Addr.String()); err == nil {
+			info.IP = addrPort.Addr().Unmap().String()
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ua := md.Get("user-agent"); len(ua) > 0 {
+			info.UserAgent = ua[0]
+		}
+	}
+	return info
+}
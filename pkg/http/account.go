@@ -0,0 +1,220 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// AccountHandler serves the caller's own settings under /v1/me
+type AccountHandler struct {
+	preferencesService  *service.PreferencesService
+	notificationService *service.NotificationService
+	digestService       *service.DigestService
+	terms               *service.TermsService
+	accounts            *service.AccountService
+	billing             *service.BillingService
+	apiKeys             *service.APIKeyService
+}
+
+func NewAccountHandler(preferencesService *service.PreferencesService, notificationService *service.NotificationService, digestService *service.DigestService) *AccountHandler {
+	return &AccountHandler{
+		preferencesService:  preferencesService,
+		notificationService: notificationService,
+		digestService:       digestService,
+	}
+}
+
+// UseTerms serves the caller's terms of service acceptance at /v1/me/terms
+func (h *AccountHandler) UseTerms(terms *service.TermsService) {
+	h.terms = terms
+}
+
+// UseAccounts serves the caller's account summary at /v1/me
+func (h *AccountHandler) UseAccounts(accounts *service.AccountService) {
+	h.accounts = accounts
+}
+
+// GetMe returns the caller's owner ID, scopes, limits and usage
+func (h *AccountHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	me, err := h.accounts.Me(r.Context())
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(me)
+}
+
+// UseBilling serves the caller's plan and subscription at /v1/me/billing
+func (h *AccountHandler) UseBilling(billing *service.BillingService) {
+	h.billing = billing
+}
+
+func (h *AccountHandler) GetBilling(w http.ResponseWriter, r *http.Request) {
+	billing, err := h.billing.GetBilling(r.Context())
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(billing)
+}
+
+func (h *AccountHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	prefs, err := h.preferencesService.GetPreferences(r.Context())
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
+
+func (h *AccountHandler) UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	var req service.Preferences
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	prefs, err := h.preferencesService.UpdatePreferences(r.Context(), &req)
+	if err != nil {
+		if writeValidationError(w, err) {
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
+
+func (h *AccountHandler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	settings, err := h.notificationService.GetSettings(r.Context())
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+func (h *AccountHandler) UpdateNotifications(w http.ResponseWriter, r *http.Request) {
+	var req service.NotificationSettings
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	settings, err := h.notificationService.UpdateSettings(r.Context(), &req)
+	if err != nil {
+		if writeValidationError(w, err) {
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// GetDigest previews the digest email data for ?period=week (default) or month
+func (h *AccountHandler) GetDigest(w http.ResponseWriter, r *http.Request) {
+	period := r.URL.Query().Get("period")
+	if period == "" {
+		period = "week"
+	}
+	if service.PeriodDays(period) == 0 {
+		http.Error(w, "period must be week or month", http.StatusBadRequest)
+		return
+	}
+
+	digest, err := h.digestService.GetDigest(r.Context(), period)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(digest)
+}
+
+func (h *AccountHandler) GetTerms(w http.ResponseWriter, r *http.Request) {
+	status, err := h.terms.GetStatus(r.Context())
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// AcceptTerms records that the caller accepted the current terms version
+func (h *AccountHandler) AcceptTerms(w http.ResponseWriter, r *http.Request) {
+	var req service.AcceptTermsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	status, err := h.terms.Accept(r.Context(), &req)
+	if err != nil {
+		if errors.Is(err, service.ErrTermsVersion) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func SetupAccountRoutes(r *chi.Mux, handler *AccountHandler, oauthMiddleware *middleware.OAuthMiddleware, csrfMiddleware func(http.Handler) http.Handler) {
+	r.With(csrfMiddleware).Route("/v1/me", func(r chi.Router) {
+		if oauthMiddleware != nil {
+			if handler.accounts != nil {
+				r.With(oauthMiddleware.Authenticate("links:read")).Get("/", handler.GetMe)
+			}
+			r.With(oauthMiddleware.Authenticate("links:read")).Get("/preferences", handler.GetPreferences)
+			r.With(oauthMiddleware.Authenticate("links:write")).Put("/preferences", handler.UpdatePreferences)
+			r.With(oauthMiddleware.Authenticate("links:read")).Get("/notifications", handler.GetNotifications)
+			r.With(oauthMiddleware.Authenticate("links:write")).Put("/notifications", handler.UpdateNotifications)
+			r.With(oauthMiddleware.Authenticate("links:read")).Get("/digest", handler.GetDigest)
+			if handler.terms != nil {
+				r.With(oauthMiddleware.Authenticate("links:read")).Get("/terms", handler.GetTerms)
+				r.With(oauthMiddleware.Authenticate("links:write")).Post("/terms", handler.AcceptTerms)
+			}
+			if handler.billing != nil {
+				r.With(oauthMiddleware.Authenticate("links:read")).Get("/billing", handler.GetBilling)
+			}
+			// API keys only authenticate alongside sign-in, for /v1/quick
+			if handler.apiKeys != nil {
+				r.With(oauthMiddleware.Authenticate("links:write")).Post("/api-keys", handler.CreateAPIKey)
+				r.With(oauthMiddleware.Authenticate("links:read")).Get("/api-keys", handler.ListAPIKeys)
+				r.With(oauthMiddleware.Authenticate("links:write")).Delete("/api-keys/{id}", handler.DeleteAPIKey)
+			}
+		} else {
+			if handler.accounts != nil {
+				r.Get("/", handler.GetMe)
+			}
+			r.Get("/preferences", handler.GetPreferences)
+			r.Put("/preferences", handler.UpdatePreferences)
+			r.Get("/notifications", handler.GetNotifications)
+			r.Put("/notifications", handler.UpdateNotifications)
+			r.Get("/digest", handler.GetDigest)
+			if handler.terms != nil {
+				r.Get("/terms", handler.GetTerms)
+				r.Post("/terms", handler.AcceptTerms)
+			}
+			if handler.billing != nil {
+				r.Get("/billing", handler.GetBilling)
+			}
+		}
+	})
+}
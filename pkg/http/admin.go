@@ -0,0 +1,584 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"url-shortener/pkg/config"
+	"url-shortener/pkg/export"
+	"url-shortener/pkg/jobs"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// AdminHandler serves operator endpoints under /admin
+type AdminHandler struct {
+	configWatcher *config.Watcher
+	jobs          *jobs.Queue
+	linkService   *service.LinkService
+	fraudAlerts   storage.FraudStorage
+	users         *service.UserService
+	plans         *service.PlanService
+	dailyExport   *export.DailyClicks
+	logger        *logging.Logger
+	maintenance   *service.Maintenance
+}
+
+func NewAdminHandler(configWatcher *config.Watcher, jobQueue *jobs.Queue, linkService *service.LinkService) *AdminHandler {
+	return &AdminHandler{
+		configWatcher: configWatcher,
+		jobs:          jobQueue,
+		linkService:   linkService,
+	}
+}
+
+// UseFraudAlerts serves the click fraud detector's alerts
+func (h *AdminHandler) UseFraudAlerts(store storage.FraudStorage) {
+	h.fraudAlerts = store
+}
+
+// UseUsers serves account lookup and suspension under /admin/users
+func (h *AdminHandler) UseUsers(users *service.UserService) {
+	h.users = users
+}
+
+// UsePlans serves the plans and owners' plan assignments
+func (h *AdminHandler) UsePlans(plans *service.PlanService) {
+	h.plans = plans
+}
+
+// UseDailyExport lets operators export a day's click rollup on demand
+func (h *AdminHandler) UseDailyExport(daily *export.DailyClicks) {
+	h.dailyExport = daily
+}
+
+// UseLogger lets operators read and change logger's level
+func (h *AdminHandler) UseLogger(logger *logging.Logger) {
+	h.logger = logger
+}
+
+// UseMaintenance lets operators turn maintenance mode on and off
+func (h *AdminHandler) UseMaintenance(maintenance *service.Maintenance) {
+	h.maintenance = maintenance
+}
+
+type LogLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// GetLogLevel returns the server's current log level
+func (h *AdminHandler) GetLogLevel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"level": string(h.logger.Level())})
+}
+
+// SetLogLevel changes the server's log level until the next configuration
+// reload or restart, which go back to LOG_LEVEL
+func (h *AdminHandler) SetLogLevel(w http.ResponseWriter, r *http.Request) {
+	var req LogLevelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	level := logging.LogLevel(req.Level)
+	if !level.Valid() {
+		http.Error(w, "level must be one of debug, info, warn, error", http.StatusBadRequest)
+		return
+	}
+	from := h.logger.Level()
+	h.logger.SetLevel(level)
+	h.logger.LogAdminAction(r.Context(), "log.set_level", middleware.GetSubFromContext(r.Context()), "log_level", "from", string(from), "to", string(level))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"level": string(level)})
+}
+
+type MaintenanceRequest struct {
+	Enabled bool   `json:"enabled"`
+	Message string `json:"message"`
+}
+
+// GetMaintenance returns maintenance mode as this server last read it
+func (h *AdminHandler) GetMaintenance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.maintenance.State())
+}
+
+// SetMaintenance turns maintenance mode on or off for every server, which
+// other servers notice within MAINTENANCE_REFRESH. A server started with
+// MAINTENANCE_MODE stays in it.
+func (h *AdminHandler) SetMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req MaintenanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	from := h.maintenance.State()
+	state, err := h.maintenance.Set(r.Context(), req.Enabled, req.Message)
+	if err != nil {
+		http.Error(w, "failed to set maintenance mode", http.StatusInternalServerError)
+		return
+	}
+	if h.logger != nil {
+		h.logger.LogAdminAction(r.Context(), "maintenance.set", middleware.GetSubFromContext(r.Context()), "maintenance",
+			"from", strconv.FormatBool(from.Enabled), "to", strconv.FormatBool(state.Enabled), "message", state.Message)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+func (h *AdminHandler) ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	if err := h.configWatcher.Reload(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cfg := h.configWatcher.Current()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"log_level":             cfg.LogLevel,
+		"link_cache_ttl":        cfg.LinkCacheTTL.String(),
+		"negative_cache_ttl":    cfg.NegativeCacheTTL.String(),
+		"rate_limit_per_minute": cfg.RateLimitPerMinute,
+		"blocked_domains":       cfg.BlockedDomains,
+		"short_domains":         cfg.ShortDomains,
+
+		"click_exclude_cidrs":       cfg.ClickExcludeCIDRs,
+		"click_exclude_user_agents": cfg.ClickExcludeUserAgents,
+		"click_preview_param":       cfg.ClickPreviewParam,
+		"click_dedup_window":        cfg.ClickDedupWindow.String(),
+
+		"shortener_domains":      cfg.ShortenerDomains,
+		"shortener_chain_depth":  cfg.ShortenerChainDepth,
+		"shortener_chain_action": cfg.ShortenerChainAction,
+		"shortener_expand":       cfg.ShortenerExpand,
+	})
+}
+
+var jobStatuses = map[string]bool{
+	storage.JobPending: true,
+	storage.JobRunning: true,
+	storage.JobDone:    true,
+	storage.JobDead:    true,
+}
+
+// ListJobs lists background jobs, filtered by ?status= and ?kind=.
+// status=dead is the dead letter list.
+func (h *AdminHandler) ListJobs(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status != "" && !jobStatuses[status] {
+		http.Error(w, "status must be one of pending, running, done, dead", http.StatusBadRequest)
+		return
+	}
+	limit, offset, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	list, err := h.jobs.List(r.Context(), status, r.URL.Query().Get("kind"), limit, offset)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []*storage.Job{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"jobs": list})
+}
+
+// parsePage reads ?limit= (default 20, at most 100) and ?offset=, writing a
+// 400 and reporting false if either is invalid
+func parsePage(w http.ResponseWriter, r *http.Request) (limit, offset int, ok bool) {
+	limit = 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return 0, 0, false
+		}
+		limit = n
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return 0, 0, false
+		}
+		offset = n
+	}
+	return limit, offset, true
+}
+
+func (h *AdminHandler) GetJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	job, err := h.jobs.Get(r.Context(), id)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if job == nil {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// RequeueJob gives a dead or done job a fresh set of attempts
+func (h *AdminHandler) RequeueJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	job, err := h.jobs.Get(r.Context(), id)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if job == nil {
+		http.Error(w, "job not found", http.StatusNotFound)
+		return
+	}
+	requeued, err := h.jobs.Requeue(r.Context(), id)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if !requeued {
+		http.Error(w, "job is still pending or running", http.StatusConflict)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PurgeCacheRequest selects the cached links to drop; exactly one field is set
+type PurgeCacheRequest struct {
+	Code   string `json:"code,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	All    bool   `json:"all,omitempty"`
+}
+
+// PurgeCache drops cached links after the DB was changed by hand. The cache
+// is shared by every replica, so the purge applies to all of them.
+func (h *AdminHandler) PurgeCache(w http.ResponseWriter, r *http.Request) {
+	var req PurgeCacheRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	set := 0
+	for _, ok := range []bool{req.Code != "", req.Prefix != "", req.All} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		http.Error(w, "exactly one of code, prefix or all must be given", http.StatusBadRequest)
+		return
+	}
+
+	var purged int64
+	var err error
+	if req.Code != "" {
+		purged, err = h.linkService.PurgeCache(r.Context(), req.Code, false)
+	} else {
+		// All is the empty prefix
+		purged, err = h.linkService.PurgeCache(r.Context(), req.Prefix, true)
+	}
+	if err != nil {
+		http.Error(w, "cache purge failed: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"purged": purged})
+}
+
+// ListFraudAlerts lists click fraud alerts, newest first, optionally only
+// those of ?kind=
+func (h *AdminHandler) ListFraudAlerts(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+	alerts, err := h.fraudAlerts.ListFraudAlerts(r.Context(), r.URL.Query().Get("kind"), limit, offset)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if alerts == nil {
+		alerts = []*storage.FraudAlert{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"alerts": alerts})
+}
+
+// ExportDailyClicksRequest names the UTC day to export, as YYYY-MM-DD
+type ExportDailyClicksRequest struct {
+	Date string `json:"date"`
+}
+
+// ExportDailyClicks writes a day's click rollup now, replacing the file the
+// scheduled export wrote, e.g. to backfill a day whose export failed
+func (h *AdminHandler) ExportDailyClicks(w http.ResponseWriter, r *http.Request) {
+	var req ExportDailyClicksRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	day, err := time.Parse("2006-01-02", req.Date)
+	if err != nil {
+		http.Error(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+	if day.After(time.Now()) {
+		http.Error(w, "date is in the future", http.StatusBadRequest)
+		return
+	}
+	file, err := h.dailyExport.Export(r.Context(), day)
+	if err != nil {
+		http.Error(w, "export failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(file)
+}
+
+// FindUsers looks accounts up by ?email=, their notification address
+func (h *AdminHandler) FindUsers(w http.ResponseWriter, r *http.Request) {
+	email := r.URL.Query().Get("email")
+	if email == "" {
+		http.Error(w, "email is required", http.StatusBadRequest)
+		return
+	}
+	users, err := h.users.FindUsers(r.Context(), email)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if users == nil {
+		users = []*storage.User{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"users": users})
+}
+
+// GetUser looks an account up by its sub
+func (h *AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	ownerID, err := uuid.Parse(chi.URLParam(r, "sub"))
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	user, err := h.users.GetUser(r.Context(), ownerID)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// ListUserLinks lists an account's links, including disabled ones, newest
+// first or with ?sort=stalest those unclicked longest first
+func (h *AdminHandler) ListUserLinks(w http.ResponseWriter, r *http.Request) {
+	ownerID, err := uuid.Parse(chi.URLParam(r, "sub"))
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	order := r.URL.Query().Get("sort")
+	switch order {
+	case "":
+		order = storage.LinkOrderNewest
+	case storage.LinkOrderNewest, storage.LinkOrderStalest:
+	default:
+		http.Error(w, "sort must be newest or stalest", http.StatusBadRequest)
+		return
+	}
+	limit, offset, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+	links, err := h.users.ListUserLinks(r.Context(), ownerID, order, limit, offset)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if links == nil {
+		links = []*storage.Link{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"links": links})
+}
+
+// GetLink returns a link of any account with how it was created: the
+// creating client's address and user agent, and the API key or OAuth
+// client used
+func (h *AdminHandler) GetLink(w http.ResponseWriter, r *http.Request) {
+	link, creation, err := h.users.GetLink(r.Context(), chi.URLParam(r, "code"))
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"link": link, "creation": creation})
+}
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason"`
+}
+
+// SuspendUser disables all of an account's links in one transaction
+func (h *AdminHandler) SuspendUser(w http.ResponseWriter, r *http.Request) {
+	ownerID, err := uuid.Parse(chi.URLParam(r, "sub"))
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	var req SuspendUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	suspension, err := h.users.Suspend(r.Context(), ownerID, req.Reason)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(suspension)
+}
+
+// ReinstateUser lifts a suspension, enabling the account's links again
+func (h *AdminHandler) ReinstateUser(w http.ResponseWriter, r *http.Request) {
+	ownerID, err := uuid.Parse(chi.URLParam(r, "sub"))
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	if err := h.users.Reinstate(r.Context(), ownerID); err != nil {
+		writeUserError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListPlans lists the plans owners can be put on
+func (h *AdminHandler) ListPlans(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"plans": service.Plans})
+}
+
+// GetUserPlan returns the plan an account is on
+func (h *AdminHandler) GetUserPlan(w http.ResponseWriter, r *http.Request) {
+	ownerID, err := uuid.Parse(chi.URLParam(r, "sub"))
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	plan, err := h.plans.GetOwnerPlan(r.Context(), ownerID)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
+
+type SetPlanRequest struct {
+	Plan string `json:"plan"`
+}
+
+// SetUserPlan puts an account on a plan
+func (h *AdminHandler) SetUserPlan(w http.ResponseWriter, r *http.Request) {
+	ownerID, err := uuid.Parse(chi.URLParam(r, "sub"))
+	if err != nil {
+		http.Error(w, "user not found", http.StatusNotFound)
+		return
+	}
+	var req SetPlanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	plan, err := h.plans.SetPlan(r.Context(), ownerID, req.Plan)
+	if err != nil {
+		if errors.Is(err, service.ErrUnknownPlan) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(plan)
+}
+
+func writeUserError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrLinkNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrAlreadySuspended), errors.Is(err, service.ErrNotSuspended):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrReasonRequired):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+func SetupAdminRoutes(r *chi.Mux, handler *AdminHandler, oauthMiddleware *middleware.OAuthMiddleware) {
+	r.Route("/admin", func(r chi.Router) {
+		if oauthMiddleware != nil {
+			r.Use(oauthMiddleware.Authenticate("admin"))
+		}
+		r.Post("/config/reload", handler.ReloadConfig)
+		r.Post("/cache/purge", handler.PurgeCache)
+		r.Get("/links/top", handler.TopLinks)
+		if handler.logger != nil {
+			r.Get("/log-level", handler.GetLogLevel)
+			r.Put("/log-level", handler.SetLogLevel)
+		}
+		if handler.maintenance != nil {
+			r.Get("/maintenance", handler.GetMaintenance)
+			r.Put("/maintenance", handler.SetMaintenance)
+		}
+		if handler.jobs != nil {
+			r.Get("/jobs", handler.ListJobs)
+			r.Get("/jobs/{id}", handler.GetJob)
+			r.Post("/jobs/{id}/requeue", handler.RequeueJob)
+		}
+		if handler.fraudAlerts != nil {
+			r.Get("/fraud/alerts", handler.ListFraudAlerts)
+		}
+		if handler.users != nil {
+			r.Get("/users", handler.FindUsers)
+			r.Get("/users/{sub}", handler.GetUser)
+			r.Get("/users/{sub}/links", handler.ListUserLinks)
+			r.Get("/links/{code}", handler.GetLink)
+			r.Post("/users/{sub}/suspend", handler.SuspendUser)
+			r.Post("/users/{sub}/reinstate", handler.ReinstateUser)
+		}
+		if handler.dailyExport != nil {
+			r.Post("/exports/daily-clicks", handler.ExportDailyClicks)
+		}
+		if handler.plans != nil {
+			r.Get("/plans", handler.ListPlans)
+			r.Get("/users/{sub}/plan", handler.GetUserPlan)
+			r.Put("/users/{sub}/plan", handler.SetUserPlan)
+		}
+	})
+}
@@ -0,0 +1,227 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/export"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type purgeCache struct {
+	cache.LinkCacheInterface
+	code   string
+	prefix bool
+}
+
+func (p *purgeCache) Purge(ctx context.Context, code string, prefix bool) (int64, error) {
+	p.code, p.prefix = code, prefix
+	return 3, nil
+}
+
+func TestPurgeCache(t *testing.T) {
+	purged := &purgeCache{}
+	handler := NewAdminHandler(nil, nil, service.NewLinkService(nil, purged, nil, nil))
+
+	purge := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.PurgeCache(rec, httptest.NewRequest(http.MethodPost, "/admin/cache/purge", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := purge(`{"code":"abc"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"purged":3}`, rec.Body.String())
+	assert.Equal(t, "abc", purged.code)
+	assert.False(t, purged.prefix)
+
+	purge(`{"prefix":"promo-"}`)
+	assert.Equal(t, "promo-", purged.code)
+	assert.True(t, purged.prefix)
+
+	purge(`{"all":true}`)
+	assert.Equal(t, "", purged.code)
+	assert.True(t, purged.prefix)
+
+	assert.Equal(t, http.StatusBadRequest, purge(`{}`).Code)
+	assert.Equal(t, http.StatusBadRequest, purge(`{"code":"abc","all":true}`).Code)
+}
+
+type fraudAlerts struct {
+	storage.FraudStorage
+	kind          string
+	limit, offset int
+}
+
+func (f *fraudAlerts) ListFraudAlerts(ctx context.Context, kind string, limit, offset int) ([]*storage.FraudAlert, error) {
+	f.kind, f.limit, f.offset = kind, limit, offset
+	return nil, nil
+}
+
+func TestListFraudAlerts(t *testing.T) {
+	alerts := &fraudAlerts{}
+	handler := NewAdminHandler(nil, nil, nil)
+	handler.UseFraudAlerts(alerts)
+
+	rec := httptest.NewRecorder()
+	handler.ListFraudAlerts(rec, httptest.NewRequest(http.MethodGet, "/admin/fraud/alerts?kind=click_spike&offset=40", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"alerts":[]}`, rec.Body.String())
+	assert.Equal(t, "click_spike", alerts.kind)
+	assert.Equal(t, 20, alerts.limit)
+	assert.Equal(t, 40, alerts.offset)
+
+	rec = httptest.NewRecorder()
+	handler.ListFraudAlerts(rec, httptest.NewRequest(http.MethodGet, "/admin/fraud/alerts?limit=500", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+type orderedLinks struct {
+	storage.LinkStorage
+	order string
+}
+
+func (l *orderedLinks) ListByOwner(ctx context.Context, ownerID uuid.UUID, query storage.LinkQuery, limit, offset int) ([]*storage.Link, error) {
+	l.order = query.Order
+	return nil, nil
+}
+
+func TestListUserLinksSort(t *testing.T) {
+	links := &orderedLinks{}
+	logger := logging.NewLogger(logging.LevelError)
+	handler := NewAdminHandler(nil, nil, nil)
+	handler.UseUsers(service.NewUserService(nil, service.NewLinkService(links, nil, nil, logger), logger))
+	r := chi.NewRouter()
+	r.Get("/admin/users/{sub}/links", handler.ListUserLinks)
+
+	list := func(query string) int {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/users/"+uuid.NewString()+"/links"+query, nil))
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusOK, list(""))
+	assert.Equal(t, storage.LinkOrderNewest, links.order)
+	assert.Equal(t, http.StatusOK, list("?sort=stalest"))
+	assert.Equal(t, storage.LinkOrderStalest, links.order)
+	assert.Equal(t, http.StatusBadRequest, list("?sort=clicks"))
+}
+
+type exportStore struct{ keys []string }
+
+func (s *exportStore) Put(ctx context.Context, key, contentType string, body []byte) error {
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+type dailyClicks struct{ day time.Time }
+
+func (d *dailyClicks) AllDailyClicks(ctx context.Context, day time.Time) (map[string]int64, error) {
+	d.day = day
+	return map[string]int64{"abc": 2}, nil
+}
+
+func TestExportDailyClicks(t *testing.T) {
+	store, source := &exportStore{}, &dailyClicks{}
+	handler := NewAdminHandler(nil, nil, nil)
+	handler.UseDailyExport(export.NewDailyClicks(store, "", source, logging.NewLogger(logging.LevelError)))
+
+	post := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ExportDailyClicks(rec, httptest.NewRequest(http.MethodPost, "/admin/exports/daily-clicks", strings.NewReader(body)))
+		return rec
+	}
+	rec := post(`{"date":"2026-03-01"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"key":"daily_clicks/date=2026-03-01/daily_clicks.parquet","rows":1}`, rec.Body.String())
+	assert.Equal(t, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), source.day)
+
+	assert.Equal(t, http.StatusBadRequest, post(`{"date":"03/01/2026"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, post(`{"date":"`+time.Now().AddDate(0, 0, 2).Format("2006-01-02")+`"}`).Code)
+	assert.Len(t, store.keys, 1)
+}
+
+func TestSetLogLevel(t *testing.T) {
+	logger := logging.NewLogger(logging.LevelInfo)
+	handler := NewAdminHandler(nil, nil, nil)
+	handler.UseLogger(logger)
+	r := chi.NewRouter()
+	SetupAdminRoutes(r, handler, nil)
+
+	set := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/admin/log-level", strings.NewReader(body)))
+		return rec
+	}
+
+	rec := set(`{"level":"debug"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, logging.LevelDebug, logger.Level())
+	assert.Equal(t, http.StatusBadRequest, set(`{"level":"verbose"}`).Code)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/log-level", nil))
+	assert.JSONEq(t, `{"level":"debug"}`, rec.Body.String())
+}
+
+type memoryMaintenance struct {
+	message string
+	since   time.Time
+	on      bool
+}
+
+func (m *memoryMaintenance) GetMaintenance(ctx context.Context) (string, time.Time, bool, error) {
+	return m.message, m.since, m.on, nil
+}
+
+func (m *memoryMaintenance) SetMaintenance(ctx context.Context, message string, since time.Time) error {
+	m.message, m.since, m.on = message, since, true
+	return nil
+}
+
+func (m *memoryMaintenance) ClearMaintenance(ctx context.Context) error {
+	m.on = false
+	return nil
+}
+
+func TestSetMaintenance(t *testing.T) {
+	maintenance := service.NewMaintenance(&memoryMaintenance{}, false, "under maintenance", logging.NewLogger(logging.LevelError))
+	handler := NewAdminHandler(nil, nil, nil)
+	handler.UseMaintenance(maintenance)
+	r := chi.NewRouter()
+	r.Use(middleware.Maintenance(MaintenanceWrites, maintenance.Refusal))
+	r.Post("/v1/links", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusCreated) })
+	SetupAdminRoutes(r, handler, nil)
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := serve(http.MethodPut, "/admin/maintenance", `{"enabled":true,"message":"migrating"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"enabled":true`)
+	rec = serve(http.MethodPost, "/v1/links", `{}`)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "migrating\n", rec.Body.String())
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	// Operators can turn it off while it's on
+	rec = serve(http.MethodPut, "/admin/maintenance", `{"enabled":false}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, http.StatusCreated, serve(http.MethodPost, "/v1/links", `{}`).Code)
+	assert.JSONEq(t, `{"enabled":false,"message":"under maintenance","pinned":false}`, serve(http.MethodGet, "/admin/maintenance", "").Body.String())
+}
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// AliasReservationHandler serves /v1/alias-reservations
+type AliasReservationHandler struct {
+	reservationService *service.AliasReservationService
+}
+
+func NewAliasReservationHandler(reservationService *service.AliasReservationService) *AliasReservationHandler {
+	return &AliasReservationHandler{
+		reservationService: reservationService,
+	}
+}
+
+func (h *AliasReservationHandler) CreateReservation(w http.ResponseWriter, r *http.Request) {
+	var req service.CreateAliasReservationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	reservation, err := h.reservationService.CreateReservation(r.Context(), &req)
+	if err != nil {
+		if writeValidationError(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, service.ErrTooManyAliasReservations), errors.Is(err, service.ErrAliasReservationConflict):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, service.ErrQuotaExceeded):
+			writeErrorResponse(w, http.StatusForbidden, ErrorResponse{
+				Error: err.Error(),
+				Code:  quotaExceededCode,
+			})
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(reservation)
+}
+
+func (h *AliasReservationHandler) ListReservations(w http.ResponseWriter, r *http.Request) {
+	reservations, err := h.reservationService.ListReservations(r.Context())
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"reservations": reservations})
+}
+
+func (h *AliasReservationHandler) DeleteReservation(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err := h.reservationService.DeleteReservation(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, service.ErrAliasReservationNotFound):
+			http.Error(w, "not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrNotOwner):
+			http.Error(w, "forbidden", http.StatusForbidden)
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func SetupAliasReservationRoutes(r *chi.Mux, handler *AliasReservationHandler, oauthMiddleware *middleware.OAuthMiddleware, csrfMiddleware func(http.Handler) http.Handler) {
+	r.With(csrfMiddleware).Route("/v1/alias-reservations", func(r chi.Router) {
+		if oauthMiddleware != nil {
+			r.With(oauthMiddleware.Authenticate("links:write")).Post("/", handler.CreateReservation)
+			r.With(oauthMiddleware.Authenticate("links:read")).Get("/", handler.ListReservations)
+			r.With(oauthMiddleware.Authenticate("links:write")).Delete("/{id}", handler.DeleteReservation)
+		} else {
+			r.Post("/", handler.CreateReservation)
+			r.Get("/", handler.ListReservations)
+			r.Delete("/{id}", handler.DeleteReservation)
+		}
+	})
+}
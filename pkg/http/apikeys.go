@@ -0,0 +1,72 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"url-shortener/pkg/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// UseAPIKeys serves the caller's API keys at /v1/me/api-keys
+func (h *AccountHandler) UseAPIKeys(apiKeys *service.APIKeyService) {
+	h.apiKeys = apiKeys
+}
+
+func (h *AccountHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req service.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.apiKeys.CreateAPIKey(r.Context(), &req)
+	if err != nil {
+		if writeValidationError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrTooManyAPIKeys) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+func (h *AccountHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.apiKeys.ListAPIKeys(r.Context())
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"api_keys": keys})
+}
+
+func (h *AccountHandler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err := h.apiKeys.DeleteAPIKey(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, service.ErrAPIKeyNotFound):
+			http.Error(w, "not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrNotOwner):
+			http.Error(w, "forbidden", http.StatusForbidden)
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
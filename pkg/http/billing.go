@@ -0,0 +1,62 @@
+package http
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"url-shortener/pkg/billing"
+	"url-shortener/pkg/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// maxBillingEventSize bounds a webhook delivery; Stripe events are a few KB
+const maxBillingEventSize = 64 << 10
+
+// BillingHandler receives the payment provider's webhooks
+type BillingHandler struct {
+	billingService *service.BillingService
+	secret         string
+}
+
+// NewBillingHandler verifies webhook deliveries with the endpoint's signing
+// secret
+func NewBillingHandler(billingService *service.BillingService, secret string) *BillingHandler {
+	return &BillingHandler{
+		billingService: billingService,
+		secret:         secret,
+	}
+}
+
+// StripeWebhook applies a subscription lifecycle event. Anything but a 2xx
+// makes Stripe redeliver it.
+func (h *BillingHandler) StripeWebhook(w http.ResponseWriter, r *http.Request) {
+	payload, err := io.ReadAll(io.LimitReader(r.Body, maxBillingEventSize+1))
+	if err != nil || len(payload) > maxBillingEventSize {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	if err := billing.VerifySignature(payload, r.Header.Get(billing.SignatureHeader), h.secret, time.Now()); err != nil {
+		http.Error(w, "invalid signature", http.StatusBadRequest)
+		return
+	}
+	event, err := billing.ParseEvent(payload)
+	if err != nil {
+		http.Error(w, "invalid event", http.StatusBadRequest)
+		return
+	}
+	if err := h.billingService.HandleEvent(r.Context(), event); err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"received": true})
+}
+
+// SetupBillingRoutes registers the webhook endpoint. It is authenticated by
+// its signature rather than a token, and isn't CSRF protected.
+func SetupBillingRoutes(r *chi.Mux, handler *BillingHandler) {
+	r.Post("/webhooks/stripe", handler.StripeWebhook)
+}
@@ -0,0 +1,202 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var bundlePage = template.Must(template.New("bundle").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1.0">
+	<title>{{.Title}}</title>
+	<style>
+		body { font-family: system-ui, sans-serif; max-width: 32rem; margin: 3rem auto; padding: 0 1rem; text-align: center; }
+		a.entry { display: block; margin: 0.75rem 0; padding: 0.9rem; border: 1px solid #ccc; border-radius: 0.5rem; color: inherit; text-decoration: none; }
+		a.entry:hover { background: #f3f3f3; }
+	</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{range .Entries}}<a class="entry" href="/b/{{$.Slug}}/{{.ID}}" rel="nofollow noopener">{{.Title}}</a>
+{{end}}</body>
+</html>`))
+
+// BundleHandler serves /v1/bundles and the public /b/{slug} pages
+type BundleHandler struct {
+	bundleService *service.BundleService
+}
+
+func NewBundleHandler(bundleService *service.BundleService) *BundleHandler {
+	return &BundleHandler{
+		bundleService: bundleService,
+	}
+}
+
+func (h *BundleHandler) CreateBundle(w http.ResponseWriter, r *http.Request) {
+	var req service.CreateBundleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	bundle, err := h.bundleService.CreateBundle(r.Context(), &req)
+	if err != nil {
+		if writeValidationError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrSlugExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(bundle)
+}
+
+func (h *BundleHandler) ListBundles(w http.ResponseWriter, r *http.Request) {
+	limit, offset := 20, 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	bundles, err := h.bundleService.ListBundles(r.Context(), limit, offset)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"bundles": bundles})
+}
+
+func (h *BundleHandler) GetBundle(w http.ResponseWriter, r *http.Request) {
+	bundle, err := h.bundleService.GetBundle(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		writeBundleError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bundle)
+}
+
+func (h *BundleHandler) UpdateBundle(w http.ResponseWriter, r *http.Request) {
+	var req service.UpdateBundleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	bundle, err := h.bundleService.UpdateBundle(r.Context(), chi.URLParam(r, "slug"), &req)
+	if err != nil {
+		if writeValidationError(w, err) {
+			return
+		}
+		writeBundleError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(bundle)
+}
+
+func (h *BundleHandler) DeleteBundle(w http.ResponseWriter, r *http.Request) {
+	if err := h.bundleService.DeleteBundle(r.Context(), chi.URLParam(r, "slug")); err != nil {
+		writeBundleError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Page renders the public bundle page
+func (h *BundleHandler) Page(w http.ResponseWriter, r *http.Request) {
+	bundle, err := h.bundleService.GetPublicBundle(r.Context(), chi.URLParam(r, "slug"))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	bundlePage.Execute(w, bundle)
+}
+
+// EntryClick counts a click on a bundle entry and redirects to it. HEAD
+// requests are redirected without counting.
+func (h *BundleHandler) EntryClick(w http.ResponseWriter, r *http.Request) {
+	entryID, err := strconv.ParseInt(chi.URLParam(r, "entryID"), 10, 64)
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	slug := chi.URLParam(r, "slug")
+	var url string
+	if r.Method == http.MethodHead {
+		url, err = h.bundleService.EntryURL(r.Context(), slug, entryID)
+	} else {
+		url, err = h.bundleService.RecordClick(r.Context(), slug, entryID)
+	}
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+func writeBundleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrBundleNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrNotOwner):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	default:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+func SetupBundleRoutes(r *chi.Mux, handler *BundleHandler, oauthMiddleware *middleware.OAuthMiddleware, csrfMiddleware func(http.Handler) http.Handler) {
+	r.With(csrfMiddleware).Route("/v1/bundles", func(r chi.Router) {
+		if oauthMiddleware != nil {
+			r.With(oauthMiddleware.Authenticate("links:write")).Post("/", handler.CreateBundle)
+			r.With(oauthMiddleware.Authenticate("links:read")).Get("/", handler.ListBundles)
+			r.With(oauthMiddleware.Authenticate("links:read")).Get("/{slug}", handler.GetBundle)
+			r.With(oauthMiddleware.Authenticate("links:write")).Put("/{slug}", handler.UpdateBundle)
+			r.With(oauthMiddleware.Authenticate("links:write")).Delete("/{slug}", handler.DeleteBundle)
+		} else {
+			r.Post("/", handler.CreateBundle)
+			r.Get("/", handler.ListBundles)
+			r.Get("/{slug}", handler.GetBundle)
+			r.Put("/{slug}", handler.UpdateBundle)
+			r.Delete("/{slug}", handler.DeleteBundle)
+		}
+	})
+}
+
+// SetupBundlePageRoutes mounts the public bundle pages
+func SetupBundlePageRoutes(r *chi.Mux, handler *BundleHandler) {
+	r.Get("/b/{slug}", handler.Page)
+	r.Get("/b/{slug}/{entryID}", handler.EntryClick)
+}
@@ -0,0 +1,174 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// CampaignHandler serves /v1/campaigns
+type CampaignHandler struct {
+	campaignService *service.CampaignService
+}
+
+func NewCampaignHandler(campaignService *service.CampaignService) *CampaignHandler {
+	return &CampaignHandler{
+		campaignService: campaignService,
+	}
+}
+
+func (h *CampaignHandler) CreateCampaign(w http.ResponseWriter, r *http.Request) {
+	var req service.CampaignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	campaign, err := h.campaignService.CreateCampaign(r.Context(), &req)
+	if err != nil {
+		if writeValidationError(w, err) {
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(campaign)
+}
+
+func (h *CampaignHandler) ListCampaigns(w http.ResponseWriter, r *http.Request) {
+	limit, offset := 20, 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			http.Error(w, "limit must be between 1 and 100", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	if v := r.URL.Query().Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		offset = n
+	}
+
+	campaigns, err := h.campaignService.ListCampaigns(r.Context(), limit, offset)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"campaigns": campaigns})
+}
+
+func (h *CampaignHandler) GetCampaign(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	campaign, err := h.campaignService.GetCampaign(r.Context(), id)
+	if err != nil {
+		writeCampaignError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(campaign)
+}
+
+func (h *CampaignHandler) UpdateCampaign(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	var req service.CampaignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	campaign, err := h.campaignService.UpdateCampaign(r.Context(), id, &req)
+	if err != nil {
+		if writeValidationError(w, err) {
+			return
+		}
+		writeCampaignError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(campaign)
+}
+
+func (h *CampaignHandler) DeleteCampaign(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err := h.campaignService.DeleteCampaign(r.Context(), id); err != nil {
+		writeCampaignError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetStats aggregates clicks across the campaign's links
+func (h *CampaignHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	stats, err := h.campaignService.Stats(r.Context(), id)
+	if err != nil {
+		writeCampaignError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, no-store")
+	json.NewEncoder(w).Encode(stats)
+}
+
+func writeCampaignError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrCampaignNotFound):
+		http.Error(w, "not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrNotOwner):
+		http.Error(w, "forbidden", http.StatusForbidden)
+	default:
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+	}
+}
+
+func SetupCampaignRoutes(r *chi.Mux, handler *CampaignHandler, oauthMiddleware *middleware.OAuthMiddleware, csrfMiddleware func(http.Handler) http.Handler) {
+	r.With(csrfMiddleware).Route("/v1/campaigns", func(r chi.Router) {
+		if oauthMiddleware != nil {
+			r.With(oauthMiddleware.Authenticate("links:write")).Post("/", handler.CreateCampaign)
+			r.With(oauthMiddleware.Authenticate("links:read")).Get("/", handler.ListCampaigns)
+			r.With(oauthMiddleware.Authenticate("links:read")).Get("/{id}", handler.GetCampaign)
+			r.With(oauthMiddleware.Authenticate("links:write")).Put("/{id}", handler.UpdateCampaign)
+			r.With(oauthMiddleware.Authenticate("links:write")).Delete("/{id}", handler.DeleteCampaign)
+			r.With(oauthMiddleware.Authenticate("links:read")).Get("/{id}/stats", handler.GetStats)
+		} else {
+			r.Post("/", handler.CreateCampaign)
+			r.Get("/", handler.ListCampaigns)
+			r.Get("/{id}", handler.GetCampaign)
+			r.Put("/{id}", handler.UpdateCampaign)
+			r.Delete("/{id}", handler.DeleteCampaign)
+			r.Get("/{id}/stats", handler.GetStats)
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package http
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"time"
+
+	"url-shortener/pkg/captcha"
+	"url-shortener/pkg/middleware"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	captchaPassCookie = "captcha_pass"
+	// passwordFailureWindow is how long failed password attempts count
+	// towards CaptchaOptions.PasswordFailures
+	passwordFailureWindow = 15 * time.Minute
+)
+
+// CaptchaOptions decide when visitors are challenged
+type CaptchaOptions struct {
+	// RedirectsPerMinute from one client beyond which its redirects show a
+	// challenge instead; 0 never challenges redirects
+	RedirectsPerMinute int
+	// PasswordFailures from one client within 15 minutes after which the
+	// link password form needs a solved challenge too; 0 never
+	PasswordFailures int
+	// PassTTL is how long a solved challenge lets a client through
+	PassTTL time.Duration
+}
+
+var challengePage = template.Must(template.New("challenge").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1.0">
+	<meta name="robots" content="noindex">
+	<title>Checking your browser</title>
+</head>
+<body>
+<h2>One more step</h2>
+<p>{{if .Failed}}That didn't work, please try again.{{else}}We're seeing a lot of traffic from your network. Please confirm you're human to continue.{{end}}</p>
+<form method="post" action="{{.Action}}">
+{{.Widget}}
+<input type="submit" value="Continue">
+</form>
+</body>
+</html>`))
+
+// UseCaptcha challenges clients that follow too many links too quickly, or
+// keep getting link passwords wrong, with provider's CAPTCHA. Call it before
+// SetupRedirectRoutes. Counts are kept per process, and a solved challenge is only
+// recognised by servers sharing the access cookie keys.
+func (h *Handler) UseCaptcha(provider captcha.Provider, opts CaptchaOptions) {
+	h.captcha = provider
+	h.captchaPassTTL = opts.PassTTL
+	if opts.RedirectsPerMinute > 0 {
+		h.redirectRate = middleware.NewRateLimiter(opts.RedirectsPerMinute)
+	}
+	if opts.PasswordFailures > 0 {
+		h.passwordFailures = middleware.NewWindowLimiter(opts.PasswordFailures, passwordFailureWindow)
+	}
+}
+
+// challengeRedirect records a redirect by r's client and reports whether it
+// has to solve a challenge before being let through
+func (h *Handler) challengeRedirect(r *http.Request) bool {
+	if h.captcha == nil || h.redirectRate == nil {
+		return false
+	}
+	allowed, _ := h.redirectRate.Allow(clientIP(r).String())
+	return !allowed && !h.passedCaptcha(r)
+}
+
+// passwordNeedsCaptcha reports whether r's client got enough passwords wrong
+// to have to solve a challenge with the next one
+func (h *Handler) passwordNeedsCaptcha(r *http.Request) bool {
+	return h.captcha != nil && h.passwordFailures != nil && h.passwordFailures.Exhausted(clientIP(r).String())
+}
+
+// passwordFailed counts a wrong link password from r's client
+func (h *Handler) passwordFailed(r *http.Request) {
+	if h.passwordFailures != nil {
+		h.passwordFailures.Allow(clientIP(r).String())
+	}
+}
+
+func (h *Handler) passedCaptcha(r *http.Request) bool {
+	cookie, err := r.Cookie(captchaPassCookie)
+	return err == nil && h.access.ValidPass(cookie.Value, clientIP(r).String(), time.Now())
+}
+
+// verifyCaptcha checks the challenge solution posted with r
+func (h *Handler) verifyCaptcha(r *http.Request) error {
+	return h.captcha.Verify(r.Context(), r.FormValue(h.captcha.ResponseField()), clientIP(r).String())
+}
+
+func (h *Handler) renderChallenge(w http.ResponseWriter, r *http.Request, status int, failed bool) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	challengePage.Execute(w, map[string]any{
+		"Action": r.URL.RequestURI(),
+		"Widget": h.captcha.Widget(),
+		"Failed": failed,
+	})
+}
+
+// SolveChallenge takes the challenge form shown in place of a redirect. A
+// solved challenge sets a pass cookie for the client and sends it back to
+// the link.
+func (h *Handler) SolveChallenge(w http.ResponseWriter, r *http.Request) {
+	if err := h.verifyCaptcha(r); err != nil {
+		if !errors.Is(err, captcha.ErrFailed) {
+			http.Error(w, "captcha verification unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		h.renderChallenge(w, r, http.StatusForbidden, true)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     captchaPassCookie,
+		Value:    h.access.IssuePass(clientIP(r).String(), time.Now().Add(h.captchaPassTTL)),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   middleware.IsHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(h.captchaPassTTL.Seconds()),
+	})
+	http.Redirect(w, r, r.URL.RequestURI(), http.StatusSeeOther)
+}
+
+// passwordChallenge is the widget to put in the password form for r's
+// client, empty unless it has to solve a challenge
+func (h *Handler) passwordChallenge(r *http.Request) string {
+	if !h.passwordNeedsCaptcha(r) {
+		return ""
+	}
+	return string(h.captcha.Widget())
+}
+
+// SetupChallengeRoutes takes solved challenges at the redirect URLs they
+// were shown on, if challenges are enabled
+func SetupChallengeRoutes(r chi.Router, handler *Handler) {
+	if handler.captcha == nil {
+		return
+	}
+	r.Post("/r/{code}", handler.SolveChallenge)
+	r.Post("/r/{code}/*", handler.SolveChallenge)
+}
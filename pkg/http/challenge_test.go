@@ -0,0 +1,130 @@
+package http
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/captcha"
+	"url-shortener/pkg/security"
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// fakeCaptcha accepts the token "solved"
+type fakeCaptcha struct{}
+
+func (fakeCaptcha) Widget() template.HTML { return `<div class="fake-captcha"></div>` }
+
+func (fakeCaptcha) ResponseField() string { return "captcha-response" }
+
+func (fakeCaptcha) Verify(ctx context.Context, token, remoteIP string) error {
+	if token != "solved" {
+		return captcha.ErrFailed
+	}
+	return nil
+}
+
+func TestRedirectChallengesFastClients(t *testing.T) {
+	linkService := service.NewLinkService(nil, &fakeClickCache{}, nil, nil)
+	handler := NewHandler(linkService, nil)
+	handler.UseCaptcha(fakeCaptcha{}, CaptchaOptions{RedirectsPerMinute: 2, PassTTL: time.Hour})
+	r := chi.NewRouter()
+	r.Get("/r/{code}", handler.Redirect)
+	SetupChallengeRoutes(r, handler)
+
+	do := func(method, remoteAddr string, form url.Values, cookie *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/r/abc", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = remoteAddr
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusFound, do(http.MethodGet, "198.51.100.1:1234", nil, nil).Code)
+	assert.Equal(t, http.StatusFound, do(http.MethodGet, "198.51.100.1:1234", nil, nil).Code)
+	rec := do(http.MethodGet, "198.51.100.1:1234", nil, nil)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "fake-captcha")
+	assert.Contains(t, rec.Body.String(), `action="/r/abc"`)
+
+	// Other clients aren't affected
+	assert.Equal(t, http.StatusFound, do(http.MethodGet, "203.0.113.5:4321", nil, nil).Code)
+
+	assert.Equal(t, http.StatusForbidden, do(http.MethodPost, "198.51.100.1:1234", url.Values{"captcha-response": {"wrong"}}, nil).Code)
+	rec = do(http.MethodPost, "198.51.100.1:1234", url.Values{"captcha-response": {"solved"}}, nil)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/r/abc", rec.Header().Get("Location"))
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "captcha_pass", cookies[0].Name)
+
+	assert.Equal(t, http.StatusFound, do(http.MethodGet, "198.51.100.1:1234", nil, cookies[0]).Code)
+	// The pass is bound to the address that solved the challenge
+	do(http.MethodGet, "198.51.100.2:1234", nil, cookies[0])
+	do(http.MethodGet, "198.51.100.2:1234", nil, cookies[0])
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "198.51.100.2:1234", nil, cookies[0]).Code)
+}
+
+type bcryptLinks struct {
+	storage.LinkStorage
+	hash string
+}
+
+func (l *bcryptLinks) GetByCode(ctx context.Context, code string) (*storage.Link, error) {
+	return &storage.Link{Code: code, LongURL: "https://example.com/" + code, PasswordHash: &l.hash}, nil
+}
+
+func (l *bcryptLinks) ReplacePasswordHash(ctx context.Context, code, old, new string) error {
+	return nil
+}
+
+func TestVerifyPasswordRequiresCaptchaAfterFailures(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	require.NoError(t, err)
+	linkService := service.NewLinkService(&bcryptLinks{hash: string(hash)}, &protectedCache{}, nil, nil)
+	csrf := security.NewCSRFTokenManager()
+	handler := NewHandler(linkService, csrf)
+	handler.UseCaptcha(fakeCaptcha{}, CaptchaOptions{PasswordFailures: 2, PassTTL: time.Hour})
+	r := chi.NewRouter()
+	r.Get("/r/{code}", handler.Redirect)
+	r.Post("/v1/links/{code}/verify", handler.VerifyPassword)
+
+	verify := func(password, captchaToken string) int {
+		token, err := csrf.GenerateToken("anonymous")
+		require.NoError(t, err)
+		form := url.Values{"password": {password}, "csrf_token": {token}, "captcha-response": {captchaToken}}
+		req := httptest.NewRequest(http.MethodPost, "/v1/links/abc/verify", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	form := func() string {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/r/abc", nil))
+		return rec.Body.String()
+	}
+
+	assert.NotContains(t, form(), "fake-captcha")
+	assert.Equal(t, http.StatusUnauthorized, verify("wrong", ""))
+	assert.Equal(t, http.StatusUnauthorized, verify("wrong", ""))
+	assert.Contains(t, form(), "fake-captcha")
+
+	assert.Equal(t, http.StatusUnauthorized, verify("hunter2", ""), "right password without the captcha")
+	assert.Equal(t, http.StatusOK, verify("hunter2", "solved"))
+}
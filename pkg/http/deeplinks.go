@@ -0,0 +1,163 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/security"
+	"url-shortener/pkg/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// DeepLinkHandler serves /v1/deep-links and /v1/deep-link-keys
+type DeepLinkHandler struct {
+	deepLinkService *service.DeepLinkService
+}
+
+func NewDeepLinkHandler(deepLinkService *service.DeepLinkService) *DeepLinkHandler {
+	return &DeepLinkHandler{
+		deepLinkService: deepLinkService,
+	}
+}
+
+func (h *DeepLinkHandler) CreateDeepLink(w http.ResponseWriter, r *http.Request) {
+	var req service.CreateDeepLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	deepLink, err := h.deepLinkService.CreateDeepLink(r.Context(), &req)
+	if err != nil {
+		if writeValidationError(w, err) {
+			return
+		}
+		var taken *service.AliasTakenError
+		switch {
+		case errors.Is(err, service.ErrDeepLinkKeyNotFound), errors.Is(err, service.ErrNotOwner):
+			http.Error(w, "key not found", http.StatusNotFound)
+		case errors.As(err, &taken), errors.Is(err, service.ErrCodeExists):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, service.ErrAccountSuspended), errors.Is(err, service.ErrTermsNotAccepted), errors.Is(err, service.ErrQuotaExceeded):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/v1/links/"+deepLink.Code)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(deepLink)
+}
+
+// VerifyDeepLink answers with a token's claims, or 422 if it doesn't verify
+func (h *DeepLinkHandler) VerifyDeepLink(w http.ResponseWriter, r *http.Request) {
+	var req service.VerifyDeepLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	claims, err := h.deepLinkService.VerifyDeepLink(r.Context(), &req)
+	if err != nil {
+		if writeValidationError(w, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, security.ErrSignatureExpired):
+			http.Error(w, "token expired", http.StatusUnprocessableEntity)
+		case errors.Is(err, security.ErrInvalidSignature), errors.Is(err, security.ErrUnknownDeepLinkKey):
+			http.Error(w, "invalid token", http.StatusUnprocessableEntity)
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(claims)
+}
+
+func (h *DeepLinkHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	var req service.CreateDeepLinkKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.deepLinkService.CreateKey(r.Context(), &req)
+	if err != nil {
+		if writeValidationError(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrTooManyDeepLinkKeys) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
+
+func (h *DeepLinkHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.deepLinkService.ListKeys(r.Context())
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
+
+func (h *DeepLinkHandler) DeleteKey(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err := h.deepLinkService.DeleteKey(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, service.ErrDeepLinkKeyNotFound):
+			http.Error(w, "not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrNotOwner):
+			http.Error(w, "forbidden", http.StatusForbidden)
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func SetupDeepLinkRoutes(r *chi.Mux, handler *DeepLinkHandler, oauthMiddleware *middleware.OAuthMiddleware, csrfMiddleware func(http.Handler) http.Handler) {
+	r.With(csrfMiddleware).Route("/v1/deep-links", func(r chi.Router) {
+		if oauthMiddleware != nil {
+			r.With(oauthMiddleware.Authenticate("links:write")).Post("/", handler.CreateDeepLink)
+			r.With(oauthMiddleware.Authenticate("links:read")).Post("/verify", handler.VerifyDeepLink)
+		} else {
+			r.Post("/", handler.CreateDeepLink)
+			r.Post("/verify", handler.VerifyDeepLink)
+		}
+	})
+	r.With(csrfMiddleware).Route("/v1/deep-link-keys", func(r chi.Router) {
+		if oauthMiddleware != nil {
+			r.With(oauthMiddleware.Authenticate("links:write")).Post("/", handler.CreateKey)
+			r.With(oauthMiddleware.Authenticate("links:read")).Get("/", handler.ListKeys)
+			r.With(oauthMiddleware.Authenticate("links:write")).Delete("/{id}", handler.DeleteKey)
+		} else {
+			r.Post("/", handler.CreateKey)
+			r.Get("/", handler.ListKeys)
+			r.Delete("/{id}", handler.DeleteKey)
+		}
+	})
+}
@@ -0,0 +1,51 @@
+package http
+
+import (
+	"net/http"
+
+	"url-shortener/api"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html>
+<head>
+	<title>URL Shortener API</title>
+	<meta charset="UTF-8">
+	<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>
+window.onload = function() {
+	SwaggerUIBundle({ url: "/v1/openapi.json", dom_id: "#swagger-ui" });
+};
+</script>
+</body>
+</html>`
+
+// OpenAPISpec serves the embedded OpenAPI document as JSON
+func OpenAPISpec(w http.ResponseWriter, r *http.Request) {
+	spec, err := api.JSON()
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Write(spec)
+}
+
+// SwaggerUI serves an interactive explorer for the OpenAPI document
+func SwaggerUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte(swaggerUIPage))
+}
+
+// SetupDocsRoutes mounts the optional Swagger UI. The JSON spec itself is
+// always served by SetupAPIRoutes.
+func SetupDocsRoutes(r *chi.Mux) {
+	r.Get("/v1/docs", SwaggerUI)
+}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/tenant"
+	"url-shortener/pkg/validation"
+)
+
+// LinkDomain scopes the codes in a request to a short domain: the one named
+// by ?domain=, else the host the request was made to if it is a short
+// domain, else the default domain. The request belongs to the tenant that
+// owns the domain. Install it with r.Use before any routes are added.
+func (h *Handler) LinkDomain(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		domain := h.resolver.ShortDomainFor(r.Host)
+		if d := r.URL.Query().Get("domain"); d != "" {
+			if err := h.resolver.ValidateDomain(d); err != nil {
+				err := validation.Errors{{Field: "domain", Rule: "domain", Message: "is not an available short domain"}}
+				if strings.HasPrefix(r.URL.Path, "/v2/") {
+					writeV2Error(w, err, http.StatusBadRequest)
+				} else {
+					writeValidationError(w, err)
+				}
+				return
+			}
+			domain = strings.ToLower(d)
+		}
+		if domain != "" {
+			r = r.WithContext(service.WithDomain(r.Context(), domain))
+		}
+		if id := h.resolver.TenantOf(domain); id != "" {
+			r = r.WithContext(tenant.WithTenant(r.Context(), id))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-shortener/pkg/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkDomain(t *testing.T) {
+	linkService := service.NewLinkService(nil, nil, nil, nil)
+	settings := service.DefaultSettings()
+	settings.ShortDomains = []string{"go.example.com"}
+	linkService.ApplySettings(settings)
+
+	r := chi.NewRouter()
+	r.Use(NewHandler(linkService, nil).LinkDomain)
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(service.DomainFromContext(r.Context())))
+	}
+	r.Get("/r/{code}", echo)
+	r.Get("/v2/links/{code}", echo)
+
+	tests := []struct {
+		name   string
+		host   string
+		target string
+		status int
+		domain string
+	}{
+		{"default domain", "sho.rt", "/r/abc", http.StatusOK, ""},
+		{"short domain host", "GO.example.com:8443", "/r/abc", http.StatusOK, "go.example.com"},
+		{"query parameter", "api.example.com", "/v2/links/abc?domain=Go.Example.com", http.StatusOK, "go.example.com"},
+		{"unknown domain", "api.example.com", "/r/abc?domain=evil.example", http.StatusBadRequest, ""},
+		{"unknown domain on v2", "api.example.com", "/v2/links/abc?domain=evil.example", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			req.Host = tt.host
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.status == http.StatusOK {
+				assert.Equal(t, tt.domain, rec.Body.String())
+			} else {
+				assert.Contains(t, rec.Body.String(), `"domain"`)
+			}
+		})
+	}
+}
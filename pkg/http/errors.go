@@ -0,0 +1,38 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"url-shortener/pkg/validation"
+)
+
+// ErrorResponse is the JSON body returned for failed API requests
+type ErrorResponse struct {
+	Error string `json:"error"`
+	// Code is a machine-readable reason, for errors clients act on
+	Code        string                  `json:"code,omitempty"`
+	Fields      []validation.FieldError `json:"fields,omitempty"`
+	Suggestions []string                `json:"suggestions,omitempty"`
+}
+
+func writeErrorResponse(w http.ResponseWriter, status int, resp ErrorResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// writeValidationError writes field-level errors if err came from the
+// validation package and reports whether it did.
+func writeValidationError(w http.ResponseWriter, err error) bool {
+	var verrs validation.Errors
+	if !errors.As(err, &verrs) {
+		return false
+	}
+	writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
+		Error:  "validation failed",
+		Fields: verrs,
+	})
+	return true
+}
@@ -0,0 +1,140 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"url-shortener/pkg/validation"
+)
+
+// selection is a response shape requested with ?fields= and ?expand=.
+// fields lists the top-level JSON fields to return (all when omitted);
+// expand names related data to embed, which is returned even if fields
+// doesn't list it.
+type selection struct {
+	fields map[string]bool
+	expand map[string]bool
+}
+
+// parseSelection reads ?fields= and ?expand= for a response rendering model.
+// Fields must be JSON fields of model and expansions must be among
+// expansions; anything else is reported as validation.Errors, for writeValidationError.
+func parseSelection(r *http.Request, model any, expansions ...string) (*selection, error) {
+	known := jsonFields(reflect.TypeOf(model))
+	allowed := make(map[string]bool, len(expansions))
+	for _, name := range expansions {
+		allowed[name] = true
+	}
+
+	var errs validation.Errors
+	sel := &selection{expand: map[string]bool{}}
+	for _, name := range splitList(r.URL.Query().Get("expand")) {
+		if !allowed[name] {
+			errs = append(errs, validation.FieldError{Field: "expand", Rule: "oneof", Message: "cannot expand " + name})
+			continue
+		}
+		sel.expand[name] = true
+	}
+	if names := splitList(r.URL.Query().Get("fields")); len(names) > 0 {
+		sel.fields = make(map[string]bool, len(names))
+		for _, name := range names {
+			if !known[name] && !allowed[name] {
+				errs = append(errs, validation.FieldError{Field: "fields", Rule: "oneof", Message: "unknown field " + name})
+				continue
+			}
+			sel.fields[name] = true
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return sel, nil
+}
+
+// expands reports whether the client asked for the named expansion
+func (s *selection) expands(name string) bool {
+	return s.expand[name]
+}
+
+// write renders v as the response body; see render
+func (s *selection) write(w http.ResponseWriter, v any, expanded map[string]any) error {
+	out, err := s.render(v, expanded)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(out)
+}
+
+// render returns v as a JSON object with the expanded values merged in,
+// keeping only the selected fields
+func (s *selection) render(v any, expanded map[string]any) (map[string]json.RawMessage, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &out); err != nil {
+		return nil, err
+	}
+	for name, value := range expanded {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		out[name] = raw
+	}
+	if s.fields != nil {
+		for name := range out {
+			if !s.fields[name] && !s.expand[name] {
+				delete(out, name)
+			}
+		}
+	}
+	return out, nil
+}
+
+// jsonFields returns the JSON names of t's fields, including those promoted
+// from embedded structs
+func jsonFields(t reflect.Type) map[string]bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	names := map[string]bool{}
+	if t.Kind() != reflect.Struct {
+		return names
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			for embedded := range jsonFields(f.Type) {
+				names[embedded] = true
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
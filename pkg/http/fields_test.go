@@ -0,0 +1,83 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type taggedLinks struct {
+	storage.LinkStorage
+}
+
+func (taggedLinks) GetTags(ctx context.Context, codes []string) (map[string][]string, error) {
+	return map[string][]string{codes[0]: {"launch"}}, nil
+}
+
+func TestGetLinkFieldsAndExpand(t *testing.T) {
+	handler := NewHandler(service.NewLinkService(taggedLinks{}, &fakeClickCache{}, nil, nil), nil)
+	r := chi.NewRouter()
+	r.Get("/v1/links/{code}", handler.GetLink)
+
+	get := func(target string) (int, map[string]json.RawMessage) {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		var body map[string]json.RawMessage
+		json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body
+	}
+
+	code, body := get("/v1/links/abc")
+	require.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "redirect_type")
+	assert.NotContains(t, body, "stats")
+
+	code, body = get("/v1/links/abc?fields=code,long_url")
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, body, 2)
+	assert.JSONEq(t, `"https://example.com/abc"`, string(body["long_url"]))
+
+	// Expansions are returned even when fields doesn't list them
+	code, body = get("/v1/links/abc?fields=code&expand=stats,tags")
+	require.Equal(t, http.StatusOK, code)
+	assert.Len(t, body, 3)
+	assert.JSONEq(t, `["launch"]`, string(body["tags"]))
+	assert.JSONEq(t, `{"total_clicks": 0, "expired": false}`, string(body["stats"]))
+
+	code, body = get("/v1/links/abc?fields=code,secret&expand=owner")
+	assert.Equal(t, http.StatusBadRequest, code)
+	var errs []validation.FieldError
+	require.NoError(t, json.Unmarshal(body["fields"], &errs))
+	require.Len(t, errs, 2)
+	assert.Equal(t, "cannot expand owner", errs[0].Message)
+	assert.Equal(t, "unknown field secret", errs[1].Message)
+}
+
+func TestResolveFields(t *testing.T) {
+	clicks := &fakeClickCache{}
+	handler := NewHandler(service.NewLinkService(nil, clicks, nil, nil), nil)
+	r := chi.NewRouter()
+	r.Get("/v1/resolve/{code}", handler.Resolve)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/resolve/abc?fields=long_url", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"long_url": "https://example.com/abc"}`, rec.Body.String())
+	assert.Equal(t, int64(1), clicks.clicks)
+
+	// A bad selection is rejected before the click is counted
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/resolve/abc?fields=nope", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, int64(1), clicks.clicks)
+}
@@ -1,28 +1,109 @@
 package http
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"html/template"
 	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"url-shortener/pkg/captcha"
+	"url-shortener/pkg/fraud"
+	"url-shortener/pkg/live"
 	"url-shortener/pkg/middleware"
 	"url-shortener/pkg/security"
 	"url-shortener/pkg/service"
+	"url-shortener/pkg/session"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/webhook"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/skip2/go-qrcode"
 )
 
 type Handler struct {
-	linkService *service.LinkService
+	linkService LinkServiceInterface
+	// resolver serves the redirect routes; it is linkService on the API
+	// server
+	resolver    ResolverInterface
 	csrfManager *security.CSRFTokenManager
+	shareSigner *security.URLSigner
+	clickEvents *webhook.Dispatcher
+	access      *security.AccessCookies
+	fraud       *fraud.Detector
+	// Request headers set by the edge with the client's country and ASN
+	countryHeader string
+	asnHeader     string
+	// CAPTCHA challenges, see UseCaptcha
+	captcha          captcha.Provider
+	redirectRate     *middleware.RateLimiter
+	passwordFailures *middleware.RateLimiter
+	captchaPassTTL   time.Duration
+	// Dashboard events, see UseLiveEvents
+	live     LiveEvents
+	liveRate int
+	// Warnings before untrusted destinations, see UseDestinationWarnings
+	destinations DestinationChecker
 }
 
-func NewHandler(linkService *service.LinkService, csrfManager *security.CSRFTokenManager) *Handler {
+const (
+	// accessCookieTTL is how long an entered link password is remembered
+	accessCookieTTL = 5 * time.Minute
+	// linkTokenHeader carries the access token from VerifyPassword for API
+	// clients that don't keep cookies
+	linkTokenHeader = "X-Link-Token"
+)
+
+// AccessTokenResponse is returned by VerifyPassword
+type AccessTokenResponse struct {
+	AccessToken string    `json:"access_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// NewHandler serves links from linkService. csrfManager issues the tokens
+// served at /v1/csrf-token.
+func NewHandler(linkService LinkServiceInterface, csrfManager *security.CSRFTokenManager) *Handler {
 	return &Handler{
 		linkService: linkService,
+		resolver:    linkService,
 		csrfManager: csrfManager,
+		access:      security.NewAccessCookies(),
 	}
 }
 
+// NewRedirectHandler serves only the routes of SetupRedirectRoutes, from
+// resolver
+func NewRedirectHandler(resolver ResolverInterface) *Handler {
+	return &Handler{
+		resolver: resolver,
+		access:   security.NewAccessCookies(),
+	}
+}
+
+// UseAccessCookies signs password access cookies with access instead of a
+// per-process key
+func (h *Handler) UseAccessCookies(access *security.AccessCookies) {
+	h.access = access
+}
+
+// aliasTakenCode is the error code for a create whose alias is in use; the
+// response lists free alternatives
+const aliasTakenCode = "alias_taken"
+
+// termsNotAcceptedCode is the error code for a create by an owner who has to
+// accept the terms of service at /v1/me/terms first
+const termsNotAcceptedCode = "terms_not_accepted"
+
+// quotaExceededCode is the error code for a create that would go over a
+// limit of the owner's plan
+const quotaExceededCode = "quota_exceeded"
+
 func (h *Handler) CreateLink(w http.ResponseWriter, r *http.Request) {
 	var req service.CreateLinkRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -32,17 +113,55 @@ func (h *Handler) CreateLink(w http.ResponseWriter, r *http.Request) {
 
 	resp, err := h.linkService.CreateLink(r.Context(), &req)
 	if err != nil {
+		if writeValidationError(w, err) {
+			return
+		}
+		var taken *service.AliasTakenError
+		if errors.As(err, &taken) {
+			writeErrorResponse(w, http.StatusConflict, ErrorResponse{
+				Error:       "alias already taken",
+				Code:        aliasTakenCode,
+				Suggestions: taken.Suggestions,
+			})
+			return
+		}
+		if errors.Is(err, service.ErrCodeExists) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, service.ErrAccountSuspended) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if errors.Is(err, service.ErrTermsNotAccepted) {
+			writeErrorResponse(w, http.StatusForbidden, ErrorResponse{
+				Error: err.Error(),
+				Code:  termsNotAcceptedCode,
+			})
+			return
+		}
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			writeErrorResponse(w, http.StatusForbidden, ErrorResponse{
+				Error: err.Error(),
+				Code:  quotaExceededCode,
+			})
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/v1/links/"+url.PathEscape(resp.Code)+domainQuery(resp.Domain))
+	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(resp)
 }
 
+// Redirect sends the visitor to the link's destination. Path segments after
+// the code are the values of a parameterized link's placeholders.
 func (h *Handler) Redirect(w http.ResponseWriter, r *http.Request) {
 	code := chi.URLParam(r, "code")
-	link, err := h.linkService.GetLink(r.Context(), code)
+	link, err := h.resolver.GetLink(r.Context(), code)
 	if err != nil {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
@@ -51,28 +170,384 @@ func (h *Handler) Redirect(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
+	// Every response for the link, interstitials included, keeps it out of
+	// search results
+	if link.NoIndex {
+		w.Header().Set("X-Robots-Tag", "noindex")
+	}
 
 	// Check expiry
-	if h.linkService.IsExpired(link) {
+	if h.resolver.IsExpired(link) {
+		h.publishLive(live.LinkExpired, link)
 		http.Error(w, "gone", http.StatusGone)
 		return
 	}
+	if link.Disabled {
+		http.Error(w, "link disabled", http.StatusGone)
+		return
+	}
+
+	// Restricted links only redirect for the networks they allow. The
+	// address is the connection's, or the one a trusted proxy forwarded,
+	// as any client can set X-Forwarded-For.
+	if !h.resolver.AllowsRequester(link, clientIP(r)) {
+		http.Error(w, "forbidden", http.StatusForbidden)
+		return
+	}
+	if len(link.AllowCIDRs) > 0 || len(link.DenyCIDRs) > 0 {
+		// Shared caches must not hand the redirect to other networks
+		w.Header().Set("Cache-Control", "private")
+	}
+
+	dest, err := h.resolver.Destination(link, linkParams(r))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidParams) {
+			http.Error(w, "invalid link parameters", http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	// Clients following links suspiciously fast have to prove they're human;
+	// HEAD requests from link checkers are let through
+	if r.Method != http.MethodHead && h.challengeRedirect(r) {
+		h.renderChallenge(w, r, http.StatusOK, false)
+		return
+	}
 
 	// Check password
-	if link.PasswordHash != nil {
-		cookie, err := r.Cookie("verified_" + code)
-		if err != nil || cookie.Value != "true" {
-			// Generate secure CSRF token
-			sessionID := getSessionID(r)
-			csrfToken, err := h.csrfManager.GenerateToken(sessionID)
-			if err != nil {
-				http.Error(w, "internal server error", http.StatusInternalServerError)
-				return
+	if link.PasswordHash != nil && !h.hasAccess(r, link) {
+		h.renderPasswordForm(w, r, link)
+		return
+	}
+
+	// Destinations on new or risky domains get a warning page first; HEAD
+	// requests from link checkers are let through
+	if r.Method != http.MethodHead {
+		if warning := h.destinationWarning(r, link, dest); warning != nil {
+			h.renderWarning(w, r, link, dest, warning)
+			return
+		}
+	}
+
+	// Link checkers and previews use HEAD; only count real visits
+	if r.Method == http.MethodHead {
+		h.redirect(w, r, link, dest)
+		return
+	}
+	// Links with a rate limit protect their destination by turning away
+	// visits over it
+	if allowed, retryAfter := h.resolver.AllowRedirect(r.Context(), link); !allowed {
+		h.renderTryLater(w, retryAfter)
+		return
+	}
+	h.countClick(w, r, link)
+	h.redirect(w, r, link, dest)
+}
+
+// linkParams are the path segments after the code in /r/{code}/*
+func linkParams(r *http.Request) []string {
+	rest := chi.URLParam(r, "*")
+	if rest == "" {
+		return nil
+	}
+	var params []string
+	for _, segment := range strings.Split(rest, "/") {
+		if segment != "" {
+			params = append(params, segment)
+		}
+	}
+	return params
+}
+
+// countClick counts r as a click on link unless it is excluded or a repeat
+// visit, and reports whether it was counted
+func (h *Handler) countClick(w http.ResponseWriter, r *http.Request, link *storage.Link) bool {
+	visit := service.Visit{IP: clientIP(r), UserAgent: r.UserAgent(), Referrer: r.Referer()}
+	if r.URL.RawQuery != "" {
+		visit.Query = r.URL.Query()
+	}
+	if !h.resolver.CountsClick(link, visit) {
+		return false
+	}
+	if h.resolver.DedupsClicks() {
+		identifyVisitor(w, r, &visit)
+		if !h.resolver.FirstVisit(r.Context(), link.Key(), visit) {
+			return false
+		}
+	}
+
+	h.resolver.RecordClick(r.Context(), link, visit)
+	if h.clickEvents != nil && link.OwnerID != nil {
+		h.clickEvents.Publish(&webhook.ClickEvent{
+			ID:        uuid.NewString(),
+			Code:      link.Code,
+			OwnerID:   *link.OwnerID,
+			ClickedAt: time.Now().UTC(),
+			Referrer:  r.Referer(),
+			UserAgent: r.UserAgent(),
+		})
+	}
+	h.publishLive(live.LinkClicked, link)
+	if h.fraud != nil {
+		h.observeClick(r, link, visit)
+	}
+	return true
+}
+
+// observeClick hands a counted click to the fraud detector
+func (h *Handler) observeClick(r *http.Request, link *storage.Link, visit service.Visit) {
+	click := &fraud.Click{
+		Key:     link.Key(),
+		OwnerID: link.OwnerID,
+		IP:      visit.IP,
+		Visitor: visit.VisitorID,
+		At:      time.Now(),
+	}
+	// Without click dedup the visitor cookie isn't read above
+	if cookie, err := r.Cookie(visitorCookie); click.Visitor == "" && err == nil && len(cookie.Value) == 43 {
+		click.Visitor = cookie.Value
+	}
+	// XX is how edges report an unknown country
+	if country := strings.ToUpper(r.Header.Get(h.countryHeader)); h.countryHeader != "" && country != "XX" {
+		click.Country = country
+	}
+	if h.asnHeader != "" {
+		click.ASN = strings.TrimPrefix(strings.ToUpper(r.Header.Get(h.asnHeader)), "AS")
+	}
+	h.fraud.Observe(click)
+}
+
+// ResolveResponse describes where a short link goes. DisplayURL is LongURL
+// with an internationalized host in Unicode, for people to read.
+type ResolveResponse struct {
+	Code         string     `json:"code"`
+	LongURL      string     `json:"long_url"`
+	DisplayURL   string     `json:"display_url"`
+	RedirectType int        `json:"redirect_type"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	MaxClicks    *int       `json:"max_clicks,omitempty"`
+	Protected    bool       `json:"password_protected"`
+	Counted      bool       `json:"counted"`
+}
+
+// Resolve returns a link's destination as JSON instead of redirecting, for
+// clients that can't follow redirects. The redirect's expiry and password
+// rules apply; protected links need an X-Link-Token. The lookup counts as a
+// click unless count=false is given.
+func (h *Handler) Resolve(w http.ResponseWriter, r *http.Request) {
+	sel, err := parseSelection(r, ResolveResponse{})
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
+	resp, err := h.resolve(w, r)
+	var overLimit *overRateLimitError
+	switch {
+	case errors.As(err, &overLimit):
+		setRetryAfter(w, overLimit.retryAfter)
+		writeErrorResponse(w, http.StatusTooManyRequests, ErrorResponse{Error: "rate limit exceeded"})
+	case errors.Is(err, service.ErrLinkExpired):
+		writeErrorResponse(w, http.StatusGone, ErrorResponse{Error: "gone"})
+	case errors.Is(err, service.ErrLinkDisabled):
+		writeErrorResponse(w, http.StatusGone, ErrorResponse{Error: "link disabled"})
+	case errors.Is(err, service.ErrPasswordRequired):
+		writeErrorResponse(w, http.StatusUnauthorized, ErrorResponse{Error: "password required"})
+	case errors.Is(err, service.ErrRequesterDenied):
+		writeErrorResponse(w, http.StatusForbidden, ErrorResponse{Error: "forbidden"})
+	case err != nil:
+		writeErrorResponse(w, http.StatusNotFound, ErrorResponse{Error: "not found"})
+	default:
+		sel.write(w, resp, nil)
+	}
+}
+
+// overRateLimitError is resolve's error for a link over its rate limit
+type overRateLimitError struct {
+	retryAfter time.Duration
+}
+
+func (e *overRateLimitError) Error() string { return "link rate limit exceeded" }
+
+// resolve looks up the link for a resolve request and counts the click.
+// It fails with ErrLinkNotFound, ErrLinkExpired, ErrLinkDisabled,
+// ErrRequesterDenied, ErrPasswordRequired or, over the link's rate limit,
+// an *overRateLimitError.
+func (h *Handler) resolve(w http.ResponseWriter, r *http.Request) (*ResolveResponse, error) {
+	code := chi.URLParam(r, "code")
+	link, err := h.linkService.GetLink(r.Context(), code)
+	if err != nil || link == nil {
+		return nil, service.ErrLinkNotFound
+	}
+	if h.linkService.IsExpired(link) {
+		return nil, service.ErrLinkExpired
+	}
+	if link.Disabled {
+		return nil, service.ErrLinkDisabled
+	}
+	if !h.linkService.AllowsRequester(link, clientIP(r)) {
+		return nil, service.ErrRequesterDenied
+	}
+	if link.PasswordHash != nil && !h.hasAccess(r, link) {
+		return nil, service.ErrPasswordRequired
+	}
+	// The destination is handed out as a redirect would send the visitor to
+	// it, so the link's rate limit applies the same
+	if allowed, retryAfter := h.resolver.AllowRedirect(r.Context(), link); !allowed {
+		return nil, &overRateLimitError{retryAfter: retryAfter}
+	}
+
+	resp := &ResolveResponse{
+		Code:         code,
+		LongURL:      link.LongURL,
+		DisplayURL:   service.DisplayURL(link.LongURL),
+		RedirectType: link.RedirectType,
+		ExpiresAt:    link.ExpiresAt,
+		MaxClicks:    link.MaxClicks,
+		Protected:    link.PasswordHash != nil,
+	}
+	if resp.RedirectType == 0 {
+		resp.RedirectType = http.StatusFound
+	}
+	if r.Method != http.MethodHead && r.URL.Query().Get("count") != "false" {
+		resp.Counted = h.countClick(w, r, link)
+	}
+	return resp, nil
+}
+
+// maxBulkResolve caps the codes in one ResolveMany request
+const maxBulkResolve = 100
+
+// Bulk resolve statuses
+const (
+	ResolveOK               = "ok"
+	ResolveNotFound         = "not_found"
+	ResolveExpired          = "expired"
+	ResolveDisabled         = "disabled"
+	ResolvePasswordRequired = "password_required"
+	ResolveForbidden        = "forbidden"
+)
+
+type BulkResolveRequest struct {
+	Codes []string `json:"codes"`
+}
+
+// BulkResolveResult is one code's entry in a BulkResolveResponse; the link
+// fields are only set when Status is ok
+type BulkResolveResult struct {
+	Code         string     `json:"code"`
+	Status       string     `json:"status"`
+	LongURL      string     `json:"long_url,omitempty"`
+	DisplayURL   string     `json:"display_url,omitempty"`
+	RedirectType int        `json:"redirect_type,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	MaxClicks    *int       `json:"max_clicks,omitempty"`
+}
+
+type BulkResolveResponse struct {
+	Results []BulkResolveResult `json:"results"`
+}
+
+// ResolveMany resolves up to 100 codes in one request, for tools that expand
+// many links at once. Results are in request order. Nothing is counted as a
+// click, and protected links, or restricted links the caller's address isn't
+// allowed for, are reported without their destination.
+func (h *Handler) ResolveMany(w http.ResponseWriter, r *http.Request) {
+	var req BulkResolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{Error: "invalid request"})
+		return
+	}
+	if len(req.Codes) == 0 || len(req.Codes) > maxBulkResolve {
+		writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{
+			Error: "codes must list between 1 and " + strconv.Itoa(maxBulkResolve) + " codes",
+		})
+		return
+	}
+
+	results, err := h.resolveMany(r.Context(), req.Codes, clientIP(r))
+	if err != nil {
+		writeErrorResponse(w, http.StatusInternalServerError, ErrorResponse{Error: "internal server error"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&BulkResolveResponse{Results: results})
+}
+
+// resolveMany returns a result for each code, in order, for a caller at ip
+func (h *Handler) resolveMany(ctx context.Context, codes []string, ip netip.Addr) ([]BulkResolveResult, error) {
+	links, err := h.linkService.GetLinks(ctx, codes)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]BulkResolveResult, len(codes))
+	for i, code := range codes {
+		result := &results[i]
+		result.Code = code
+		link := links[code]
+		switch {
+		case link == nil:
+			result.Status = ResolveNotFound
+		case h.linkService.IsExpired(link):
+			result.Status = ResolveExpired
+		case link.Disabled:
+			result.Status = ResolveDisabled
+		case !h.linkService.AllowsRequester(link, ip):
+			result.Status = ResolveForbidden
+		case link.PasswordHash != nil:
+			result.Status = ResolvePasswordRequired
+		default:
+			result.Status = ResolveOK
+			result.LongURL = link.LongURL
+			result.DisplayURL = service.DisplayURL(link.LongURL)
+			result.RedirectType = link.RedirectType
+			if result.RedirectType == 0 {
+				result.RedirectType = http.StatusFound
 			}
+			result.ExpiresAt = link.ExpiresAt
+			result.MaxClicks = link.MaxClicks
+		}
+	}
+	return results, nil
+}
+
+// hasAccess reports whether r carries an access token or cookie for the
+// password-protected link. Both are signed for the link's key, so that
+// unlocking a code on one short domain doesn't unlock it on another.
+func (h *Handler) hasAccess(r *http.Request, link *storage.Link) bool {
+	key := link.Key()
+	now := time.Now()
+	if token := r.Header.Get(linkTokenHeader); token != "" {
+		return h.access.ValidToken(token, key, now)
+	}
+	cookie, err := r.Cookie(accessCookieName(key))
+	return err == nil && h.access.Valid(cookie.Value, key, getSessionID(r), now)
+}
+
+// accessCookieName names the cookie remembering the password of the link
+// with key; Unicode codes and the slash after a domain are escaped, as
+// cookie names must be ASCII tokens
+func accessCookieName(key string) string {
+	return "verified_" + url.QueryEscape(key)
+}
+
+// formTokenTTL is how long the password form may be left open
+const formTokenTTL = 15 * time.Minute
 
-			w.Header().Set("Content-Type", "text/html")
-			w.WriteHeader(http.StatusOK)
-			html := `<html>
+// renderPasswordForm asks for the password of link. The form posts to
+// VerifyPasswordForm on this server, with a CSRF token signed for the link
+// and the visitor's session, which is started here if need be.
+func (h *Handler) renderPasswordForm(w http.ResponseWriter, r *http.Request, link *storage.Link) {
+	code := chi.URLParam(r, "code")
+	token := h.access.IssueFormToken(link.Key(), security.SessionID(w, r), time.Now().Add(formTokenTTL))
+	action := "/r/" + url.PathEscape(code) + "/verify" + linkDomainQuery(link)
+
+	w.Header().Set("Content-Type", "text/html")
+	w.WriteHeader(http.StatusOK)
+	html := `<html>
 <head>
 	<title>Password Required</title>
 	<meta charset="UTF-8">
@@ -80,26 +555,69 @@ func (h *Handler) Redirect(w http.ResponseWriter, r *http.Request) {
 </head>
 <body>
 <h2>Enter Password to Access Link</h2>
-<form method="post" action="/v1/links/` + code + `/verify">
-<input type="hidden" name="csrf_token" value="` + csrfToken + `">
+<form method="post" action="` + template.HTMLEscapeString(action) + `">
+<input type="hidden" name="csrf_token" value="` + token + `">
 <label>Password: <input type="password" name="password" required></label>
+` + h.passwordChallenge(r) + `
 <input type="submit" value="Submit">
 </form>
 </body>
 </html>`
-			w.Write([]byte(html))
-			return
-		}
+	w.Write([]byte(html))
+}
+
+// linkDomainQuery is the ?domain= query naming link's short domain, or
+// nothing for links on the default domain
+func linkDomainQuery(link *storage.Link) string {
+	if link.Domain == nil {
+		return ""
 	}
+	return domainQuery(*link.Domain)
+}
+
+// domainQuery is the ?domain= query scoping a URL to a short domain, or ""
+// for the default one
+func domainQuery(domain string) string {
+	if domain == "" {
+		return ""
+	}
+	return "?" + url.Values{"domain": {domain}}.Encode()
+}
 
-	// Increment click count
-	h.linkService.IncrementClickCount(r.Context(), code)
+func (h *Handler) redirect(w http.ResponseWriter, r *http.Request, link *storage.Link, dest string) {
+	status := link.RedirectType
+	if status == 0 {
+		status = http.StatusFound
+	}
+	// Destinations are absolute URLs, so unlike http.Redirect this sets
+	// Location as it is, without parsing it or writing a body
+	w.Header()["Location"] = []string{dest}
+	w.WriteHeader(status)
+}
+
+// UseClickEvents publishes every counted redirect to the owner's webhooks
+func (h *Handler) UseClickEvents(dispatcher *webhook.Dispatcher) {
+	h.clickEvents = dispatcher
+}
 
-	// Redirect
-	http.Redirect(w, r, link.LongURL, http.StatusFound)
+// UseFraudDetection feeds every counted redirect to detector. countryHeader
+// and asnHeader name request headers the edge sets with the client's country
+// and ASN, such as CF-IPCountry; empty when there are none.
+func (h *Handler) UseFraudDetection(detector *fraud.Detector, countryHeader, asnHeader string) {
+	h.fraud = detector
+	h.countryHeader = countryHeader
+	h.asnHeader = asnHeader
 }
 
+// GetLink returns link metadata. ?fields= trims the response and
+// ?expand=stats,tags embeds click stats and tags.
 func (h *Handler) GetLink(w http.ResponseWriter, r *http.Request) {
+	sel, err := parseSelection(r, storage.Link{}, "stats", "tags", "creation")
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+
 	code := chi.URLParam(r, "code")
 	link, err := h.linkService.GetLink(r.Context(), code)
 	if err != nil {
@@ -110,8 +628,44 @@ func (h *Handler) GetLink(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "not found", http.StatusNotFound)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(link)
+
+	expanded, err := h.expandLink(r.Context(), sel, link)
+	if errors.Is(err, service.ErrNotOwner) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	sel.write(w, link, expanded)
+}
+
+// expandLink loads the expansions of link that sel asks for
+func (h *Handler) expandLink(ctx context.Context, sel *selection, link *storage.Link) (map[string]any, error) {
+	expanded := map[string]any{}
+	if sel.expands("tags") {
+		if link.Tags == nil {
+			if err := h.linkService.LoadTags(ctx, []*storage.Link{link}); err != nil {
+				return nil, err
+			}
+		}
+		if link.Tags == nil {
+			link.Tags = []string{}
+		}
+		expanded["tags"] = link.Tags
+	}
+	if sel.expands("stats") {
+		expanded["stats"] = h.linkService.Stats(link)
+	}
+	if sel.expands("creation") {
+		creation, err := h.linkService.GetCreation(ctx, link)
+		if err != nil {
+			return nil, err
+		}
+		expanded["creation"] = creation
+	}
+	return expanded, nil
 }
 
 func (h *Handler) DeleteLink(w http.ResponseWriter, r *http.Request) {
@@ -134,6 +688,9 @@ func (h *Handler) UpdateLink(w http.ResponseWriter, r *http.Request) {
 
 	err := h.linkService.UpdateLink(r.Context(), code, &req)
 	if err != nil {
+		if writeValidationError(w, err) {
+			return
+		}
 		if err.Error() == "link not found" {
 			http.Error(w, "not found", http.StatusNotFound)
 		} else {
@@ -145,39 +702,137 @@ func (h *Handler) UpdateLink(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// VerifyPassword checks the password of a protected link for API clients
+// and returns an access token for it, and sets the access cookie
 func (h *Handler) VerifyPassword(w http.ResponseWriter, r *http.Request) {
 	code := chi.URLParam(r, "code")
-	password := r.FormValue("password")
-	csrfToken := r.FormValue("csrf_token")
 
 	// Secure CSRF validation
 	sessionID := getSessionID(r)
-	if !h.csrfManager.ValidateToken(sessionID, csrfToken) {
+	if !h.csrfManager.ValidateToken(sessionID, r.FormValue("csrf_token")) {
+		http.Error(w, "invalid csrf token", http.StatusForbidden)
+		return
+	}
+	link, expires, ok := h.unlock(w, r, code, sessionID)
+	if !ok {
+		return
+	}
+
+	// Invalidate CSRF token after use
+	h.csrfManager.InvalidateToken(sessionID)
+
+	// API clients send the token in X-Link-Token instead of the cookie
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&AccessTokenResponse{
+		AccessToken: h.access.IssueToken(link.Key(), expires),
+		ExpiresAt:   expires.UTC().Truncate(time.Second),
+	})
+}
+
+// VerifyPasswordForm takes the password form of Redirect on the server that
+// rendered it, and sends the browser back to the link once it is unlocked
+func (h *Handler) VerifyPasswordForm(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	link, err := h.resolver.GetLink(r.Context(), code)
+	if err != nil || link == nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	sessionID := getSessionID(r)
+	if !h.access.ValidFormToken(r.FormValue("csrf_token"), link.Key(), sessionID, time.Now()) {
 		http.Error(w, "invalid csrf token", http.StatusForbidden)
 		return
 	}
+	if _, _, ok := h.unlock(w, r, code, sessionID); !ok {
+		return
+	}
+	http.Redirect(w, r, "/r/"+url.PathEscape(code)+linkDomainQuery(link), http.StatusSeeOther)
+}
+
+// unlock checks the password posted for code and sets the access cookie for
+// sessionID, returning the link and when the cookie expires. It answers the
+// request itself when the password isn't accepted.
+func (h *Handler) unlock(w http.ResponseWriter, r *http.Request, code, sessionID string) (*storage.Link, time.Time, bool) {
+	// After repeated wrong passwords the form carries a CAPTCHA
+	if h.passwordNeedsCaptcha(r) {
+		if err := h.verifyCaptcha(r); err != nil {
+			if !errors.Is(err, captcha.ErrFailed) {
+				http.Error(w, "captcha verification unavailable", http.StatusServiceUnavailable)
+				return nil, time.Time{}, false
+			}
+			http.Error(w, "captcha required", http.StatusUnauthorized)
+			return nil, time.Time{}, false
+		}
+	}
 
-	err := h.linkService.VerifyPassword(r.Context(), code, password)
+	link, err := h.resolver.VerifyPassword(r.Context(), code, r.FormValue("password"))
 	if err != nil {
+		if errors.Is(err, service.ErrWrongPassword) {
+			h.passwordFailed(r)
+		}
 		http.Error(w, "unauthorized", http.StatusUnauthorized)
-		return
+		return nil, time.Time{}, false
 	}
 
-	// Set secure cookie
+	// Signed for this link and session so it can't be forged or reused
+	expires := time.Now().Add(accessCookieTTL)
 	http.SetCookie(w, &http.Cookie{
-		Name:     "verified_" + code,
-		Value:    "true",
-		Path:     "/r/" + code,
+		Name:     accessCookieName(link.Key()),
+		Value:    h.access.Issue(link.Key(), sessionID, expires),
+		Path:     "/r/" + url.PathEscape(code),
 		HttpOnly: true,
-		Secure:   r.TLS != nil,
+		Secure:   middleware.IsHTTPS(r),
 		SameSite: http.SameSiteStrictMode,
-		MaxAge:   300,
+		MaxAge:   int(accessCookieTTL.Seconds()),
 	})
+	return link, expires, true
+}
 
-	// Invalidate CSRF token after use
-	h.csrfManager.InvalidateToken(sessionID)
+// GetQRCode renders the short URL of a link the caller owns as a PNG QR code
+func (h *Handler) GetQRCode(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	link, err := h.linkService.GetOwnedLink(r.Context(), code)
+	if err != nil {
+		if errors.Is(err, service.ErrNotOwner) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+		} else {
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+		return
+	}
 
-	w.WriteHeader(http.StatusOK)
+	size := 256
+	if v := r.URL.Query().Get("size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 64 || n > 1024 {
+			http.Error(w, "size must be between 64 and 1024", http.StatusBadRequest)
+			return
+		}
+		size = n
+	}
+
+	png, err := qrcode.Encode(h.linkService.LinkShortURL(link), qrcode.Medium, size)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.Write(png)
+}
+
+// CSRFToken issues a CSRF token bound to the caller's session cookie, for
+// clients that need to call state-changing /v1 endpoints
+func (h *Handler) CSRFToken(w http.ResponseWriter, r *http.Request) {
+	token, err := h.csrfManager.IssueToken(w, r)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{"csrf_token": token})
 }
 
 func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
@@ -185,27 +840,192 @@ func (h *Handler) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
-func SetupRoutes(r *chi.Mux, handler *Handler, oauthMiddleware *middleware.OAuthMiddleware, csrfMiddleware func(http.Handler) http.Handler) {
+// SetupAPIRoutes mounts the link management API. Redirects are served by
+// the redirect server, see SetupRedirectRoutes.
+func SetupAPIRoutes(r *chi.Mux, handler *Handler, oauthMiddleware *middleware.OAuthMiddleware, csrfMiddleware func(http.Handler) http.Handler) {
 	r.Get("/health", handler.HealthCheck)
 
 	// Apply CSRF protection to state-changing operations
 	r.With(csrfMiddleware).Route("/v1", func(r chi.Router) {
+		// Routes with a /v2 successor
+		r.Group(func(r chi.Router) {
+			r.Use(deprecatedV1)
+			if oauthMiddleware != nil {
+				r.With(oauthMiddleware.Authenticate("links:write")).Post("/links", handler.CreateLink)
+				r.With(oauthMiddleware.Authenticate("links:read")).Get("/links/top", handler.TopLinks)
+				r.With(oauthMiddleware.Authenticate("links:read")).Get("/links/{code}", handler.GetLink)
+				r.With(oauthMiddleware.Authenticate("links:write")).Patch("/links/{code}", handler.UpdateLink)
+				r.With(oauthMiddleware.Authenticate("links:write")).Delete("/links/{code}", handler.DeleteLink)
+			} else {
+				r.Post("/links", handler.CreateLink)
+				r.Get("/links/top", handler.TopLinks)
+				r.Get("/links/{code}", handler.GetLink)
+				r.Patch("/links/{code}", handler.UpdateLink)
+				r.Delete("/links/{code}", handler.DeleteLink)
+			}
+			r.Get("/resolve/{code}", handler.Resolve)
+		})
 		if oauthMiddleware != nil {
-			r.With(oauthMiddleware.Authenticate("links:write")).Post("/links", handler.CreateLink)
-			r.With(oauthMiddleware.Authenticate("links:read")).Get("/links/{code}", handler.GetLink)
-			r.With(oauthMiddleware.Authenticate("links:write")).Patch("/links/{code}", handler.UpdateLink)
-			r.With(oauthMiddleware.Authenticate("links:write")).Delete("/links/{code}", handler.DeleteLink)
+			r.With(oauthMiddleware.Authenticate("links:read")).Get("/links", handler.ListLinks)
+			r.With(oauthMiddleware.Authenticate("links:read")).Get("/links/{code}/qr", handler.GetQRCode)
+			r.With(security.SignedURLMiddleware(handler.shareSigner, oauthMiddleware.Authenticate("links:read"))).Get("/links/{code}/stats", handler.GetStats)
+			r.With(oauthMiddleware.Authenticate("links:read")).Post("/links/{code}/stats/share", handler.ShareStats)
 		} else {
-			r.Post("/links", handler.CreateLink)
-			r.Get("/links/{code}", handler.GetLink)
-			r.Patch("/links/{code}", handler.UpdateLink)
-			r.Delete("/links/{code}", handler.DeleteLink)
+			r.Get("/links", handler.ListLinks)
+			r.Get("/links/{code}/qr", handler.GetQRCode)
+			r.With(security.SignedURLMiddleware(handler.shareSigner, nil)).Get("/links/{code}/stats", handler.GetStats)
+			r.Post("/links/{code}/stats/share", handler.ShareStats)
 		}
 		r.Post("/links/{code}/verify", handler.VerifyPassword)
+		r.Get("/links/{code}/widget", handler.Widget)
+		if oauthMiddleware != nil {
+			r.With(oauthMiddleware.Authenticate("links:read")).Get("/ws", handler.LiveUpdates)
+		} else {
+			r.Get("/ws", handler.LiveUpdates)
+		}
+		r.Get("/csrf-token", handler.CSRFToken)
+		r.Get("/openapi.json", OpenAPISpec)
 	})
 
-	// Redirect endpoint doesn't need CSRF protection (GET request)
+	// Bulk resolve only reads, so it is exempt from CSRF like the GETs
+	r.With(deprecatedV1).Post("/v1/resolve", handler.ResolveMany)
+	// Quick create only accepts API keys, which browsers don't send on
+	// their own, so it is exempt from CSRF too
+	if oauthMiddleware != nil {
+		r.With(oauthMiddleware.AuthenticateAPIKey("links:write")).Post("/v1/quick", handler.QuickCreate)
+	}
+
+	setupV2Routes(r, handler, oauthMiddleware, csrfMiddleware)
+}
+
+// SetupRedirectRoutes mounts the public routes of the redirect server: short
+// links, their public stats and challenges, and bundle pages when
+// bundleHandler is set. None of them change state on behalf of a signed-in
+// user, so they need neither authentication nor CSRF protection.
+func SetupRedirectRoutes(r *chi.Mux, handler *Handler, bundleHandler *BundleHandler) {
+	r.Get("/health", handler.HealthCheck)
 	r.Get("/r/{code}", handler.Redirect)
+	r.Get("/r/{code}/stats", handler.PublicStats)
+	r.Post("/r/{code}/verify", handler.VerifyPasswordForm)
+	r.Get("/r/{code}/*", handler.Redirect)
+	SetupChallengeRoutes(r, handler)
+	if bundleHandler != nil {
+		SetupBundlePageRoutes(r, bundleHandler)
+	}
+}
+
+// Route classes of RouteClass, which load shedding limits separately
+const (
+	RouteClassRedirect = "redirect"
+	RouteClassRead     = "read"
+	RouteClassWrite    = "write"
+)
+
+// RouteClass sorts r for load shedding: redirects, including bundle pages
+// and the resolve endpoints; other reads; and writes. Health checks have no
+// class, so that an overloaded server isn't taken for a dead one.
+func RouteClass(r *http.Request) string {
+	path := r.URL.Path
+	switch {
+	case path == "/health", path == "/v1/ws":
+		// WebSockets would hold their slot for as long as they are open
+		return ""
+	case strings.HasPrefix(path, "/r/"), strings.HasPrefix(path, "/b/"), isResolvePath(path):
+		return RouteClassRedirect
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return RouteClassRead
+	}
+	return RouteClassWrite
+}
+
+// isResolvePath matches the resolve endpoints of both API versions, but not
+// links whose codes merely contain "resolve"
+func isResolvePath(path string) bool {
+	for _, base := range []string{"/v1/resolve", "/v2/resolve"} {
+		if path == base || strings.HasPrefix(path, base+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// Writes reports whether r may change stored data: the write class of
+// RouteClass, except GraphQL, which only reads, and login, whose sessions
+// are kept in Redis
+func Writes(r *http.Request) bool {
+	return RouteClass(r) == RouteClassWrite && r.URL.Path != "/graphql" && !strings.HasPrefix(r.URL.Path, "/auth/")
+}
+
+// MaintenanceWrites is the writes maintenance mode refuses: all of them but
+// those under /admin, as operators turn it off and may need to act during it
+func MaintenanceWrites(r *http.Request) bool {
+	return Writes(r) && !strings.HasPrefix(r.URL.Path, "/admin/")
+}
+
+// SetupGraphQLRoutes mounts the dashboard GraphQL endpoint
+func SetupGraphQLRoutes(r *chi.Mux, graphqlHandler http.Handler, oauthMiddleware *middleware.OAuthMiddleware) {
+	if oauthMiddleware != nil {
+		r.With(oauthMiddleware.Authenticate("links:read")).Handle("/graphql", graphqlHandler)
+	} else {
+		r.Handle("/graphql", graphqlHandler)
+	}
+}
+
+// RoutePrefixes returns the first path segment of every route of routers,
+// such as "v1" for /v1/links, leaving out parameters
+func RoutePrefixes(routers ...chi.Routes) []string {
+	seen := make(map[string]bool)
+	var prefixes []string
+	for _, routes := range routers {
+		chi.Walk(routes, func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+			segment, _, _ := strings.Cut(strings.TrimPrefix(route, "/"), "/")
+			if segment == "" || segment == "*" || strings.HasPrefix(segment, "{") || seen[segment] {
+				return nil
+			}
+			seen[segment] = true
+			prefixes = append(prefixes, segment)
+			return nil
+		})
+	}
+	return prefixes
+}
+
+// visitorCookie identifies a browser for click deduplication
+const visitorCookie = "visitor_id"
+
+// identifyVisitor reads the visitor cookie into visit, issuing one if the
+// request has none
+func identifyVisitor(w http.ResponseWriter, r *http.Request, visit *service.Visit) {
+	// Issued IDs are 43-character RandomTokens; anything else is reissued
+	if cookie, err := r.Cookie(visitorCookie); err == nil && len(cookie.Value) == 43 {
+		visit.VisitorID = cookie.Value
+		return
+	}
+	id, err := session.RandomToken()
+	if err != nil {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     visitorCookie,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   365 * 24 * 60 * 60,
+		HttpOnly: true,
+		Secure:   middleware.IsHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+	visit.VisitorID = id
+	visit.NewVisitor = true
+}
+
+// clientIP returns the address of the peer, or the zero Addr if it can't be
+// parsed
+func clientIP(r *http.Request) netip.Addr {
+	if addrPort, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+		return addrPort.Addr()
+	}
+	addr, _ := netip.ParseAddr(r.RemoteAddr)
+	return addr
 }
 
 // Helper function to get session ID from request
@@ -0,0 +1,642 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/security"
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectDedupsRepeatVisits(t *testing.T) {
+	clicks := &fakeClickCache{}
+	linkService := service.NewLinkService(nil, clicks, nil, nil)
+	settings := service.DefaultSettings()
+	settings.ClickDedupWindow = time.Hour
+	linkService.ApplySettings(settings)
+	r := chi.NewRouter()
+	r.Get("/r/{code}", NewHandler(linkService, nil).Redirect)
+
+	visit := func(cookie *http.Cookie, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/r/abc", nil)
+		req.RemoteAddr = remoteAddr
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusFound, rec.Code)
+		return rec
+	}
+
+	rec := visit(nil, "198.51.100.1:1234")
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "visitor_id", cookies[0].Name)
+	assert.Equal(t, int64(1), clicks.clicks)
+
+	// Refreshing, even from a new address, isn't counted again
+	visit(cookies[0], "198.51.100.1:1234")
+	visit(cookies[0], "203.0.113.5:4321")
+	assert.Equal(t, int64(1), clicks.clicks)
+
+	// A different browser is a different visitor
+	visit(nil, "203.0.113.5:4321")
+	assert.Equal(t, int64(2), clicks.clicks)
+}
+
+type protectedLinks struct {
+	storage.LinkStorage
+}
+
+func (protectedLinks) GetByCode(ctx context.Context, code string) (*storage.Link, error) {
+	hash := "$argon2id$unused"
+	return &storage.Link{Code: code, LongURL: "https://example.com/" + code, PasswordHash: &hash}, nil
+}
+
+type protectedCache struct {
+	fakeClickCache
+}
+
+func (*protectedCache) Get(ctx context.Context, code string) (*cache.CachedLink, error) {
+	return &cache.CachedLink{LongURL: "https://example.com/" + code, HasPassword: true}, nil
+}
+
+func (*protectedCache) Set(ctx context.Context, code string, link *cache.CachedLink, ttl time.Duration) error {
+	return nil
+}
+
+func TestRedirectRequiresSignedAccessCookie(t *testing.T) {
+	linkService := service.NewLinkService(protectedLinks{}, &protectedCache{}, nil, nil)
+	handler := NewHandler(linkService, security.NewCSRFTokenManager())
+	access := security.NewAccessCookies([]byte("secret"))
+	handler.UseAccessCookies(access)
+	r := chi.NewRouter()
+	r.Get("/r/{code}", handler.Redirect)
+
+	visit := func(value string) int {
+		req := httptest.NewRequest(http.MethodGet, "/r/abc", nil)
+		if strings.HasPrefix(value, "token:") {
+			req.Header.Set("X-Link-Token", strings.TrimPrefix(value, "token:"))
+		} else if value != "" {
+			req.AddCookie(&http.Cookie{Name: "verified_abc", Value: value})
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	expires := time.Now().Add(time.Minute)
+	assert.Equal(t, http.StatusOK, visit(""), "password form")
+	assert.Equal(t, http.StatusOK, visit("true"), "forged cookie")
+	assert.Equal(t, http.StatusOK, visit(access.Issue("xyz", "anonymous", expires)), "cookie for another link")
+	assert.Equal(t, http.StatusFound, visit(access.Issue("abc", "anonymous", expires)))
+
+	// API clients use the access token from VerifyPassword instead
+	assert.Equal(t, http.StatusFound, visit("token:"+access.IssueToken("abc", expires)))
+	assert.Equal(t, http.StatusOK, visit("token:"+access.IssueToken("xyz", expires)))
+	assert.Equal(t, http.StatusOK, visit("token:"+access.Issue("abc", "anonymous", expires)))
+}
+
+// aliasedLinks creates links with the requested alias and domain
+type aliasedLinks struct {
+	LinkServiceInterface
+}
+
+func (aliasedLinks) CreateLink(ctx context.Context, req *service.CreateLinkRequest) (*service.CreateLinkResponse, error) {
+	resp := &service.CreateLinkResponse{Code: *req.Alias}
+	if req.Domain != nil {
+		resp.Domain = *req.Domain
+	}
+	return resp, nil
+}
+
+func TestCreateLinkLocation(t *testing.T) {
+	handler := NewHandler(aliasedLinks{}, nil)
+	tests := []struct {
+		name     string
+		body     string
+		location string
+	}{
+		{"default domain", `{"long_url": "https://example.com", "alias": "abc"}`, "/links/abc"},
+		{"short domain", `{"long_url": "https://example.com", "alias": "abc", "domain": "go.example"}`, "/links/abc?domain=go.example"},
+		{"escaped code", `{"long_url": "https://example.com", "alias": "café"}`, "/links/caf%C3%A9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.CreateLink(rec, httptest.NewRequest(http.MethodPost, "/v1/links", strings.NewReader(tt.body)))
+			assert.Equal(t, http.StatusCreated, rec.Code)
+			assert.Equal(t, "/v1"+tt.location, rec.Header().Get("Location"))
+
+			rec = httptest.NewRecorder()
+			handler.CreateLinkV2(rec, httptest.NewRequest(http.MethodPost, "/v2/links", strings.NewReader(tt.body)))
+			assert.Equal(t, http.StatusCreated, rec.Code)
+			assert.Equal(t, "/v2"+tt.location, rec.Header().Get("Location"))
+		})
+	}
+}
+
+// hashedLinks serves links protected with the password "hunter2"
+type hashedLinks struct {
+	storage.LinkStorage
+	hash string
+}
+
+func (l *hashedLinks) GetByCode(ctx context.Context, key string) (*storage.Link, error) {
+	domain, code := storage.SplitLinkKey(key)
+	link := &storage.Link{Code: code, LongURL: "https://example.com/" + code, PasswordHash: &l.hash, RedirectType: http.StatusFound}
+	if domain != "" {
+		link.Domain = &domain
+	}
+	return link, nil
+}
+
+func TestRedirectServerUnlocksPasswordForm(t *testing.T) {
+	hasher, err := security.NewPasswordHasher(security.Bcrypt)
+	require.NoError(t, err)
+	hash, err := hasher.Hash("hunter2")
+	require.NoError(t, err)
+	resolver := service.NewResolver(&hashedLinks{hash: hash}, &protectedCache{}, logging.NewLogger(logging.LevelError))
+	resolver.UsePasswordHasher(hasher)
+	handler := NewRedirectHandler(resolver)
+	r := chi.NewRouter()
+	r.Use(handler.LinkDomain)
+	SetupRedirectRoutes(r, handler, nil)
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	browser := &http.Client{Jar: jar, CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		// Follow redirects on the server, but not to the destination
+		if req.URL.Host != strings.TrimPrefix(server.URL, "http://") {
+			return http.ErrUseLastResponse
+		}
+		return nil
+	}}
+
+	resp, err := browser.Get(server.URL + "/r/abc")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	action := regexp.MustCompile(`action="([^"]+)"`).FindStringSubmatch(string(body))
+	require.NotNil(t, action)
+	assert.Equal(t, "/r/abc/verify", action[1])
+	token := regexp.MustCompile(`name="csrf_token" value="([^"]+)"`).FindStringSubmatch(string(body))
+	require.NotNil(t, token, "the form carries a CSRF token")
+
+	submit := func(csrfToken, password string) *http.Response {
+		resp, err := browser.PostForm(server.URL+action[1], url.Values{"csrf_token": {csrfToken}, "password": {password}})
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	assert.Equal(t, http.StatusForbidden, submit("forged", "hunter2").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, submit(token[1], "wrong").StatusCode)
+
+	// The browser is sent back to the link, which now redirects
+	resp = submit(token[1], "hunter2")
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "https://example.com/abc", resp.Header.Get("Location"))
+	assert.Equal(t, "/r/abc", resp.Request.URL.Path)
+}
+
+func TestAccessIsScopedToLinkDomain(t *testing.T) {
+	resolver := service.NewResolver(&hashedLinks{}, &protectedCache{}, logging.NewLogger(logging.LevelError))
+	settings := service.DefaultSettings()
+	settings.ShortDomains = []string{"go.example"}
+	resolver.ApplySettings(settings)
+	handler := NewRedirectHandler(resolver)
+	access := security.NewAccessCookies([]byte("secret"))
+	handler.UseAccessCookies(access)
+	r := chi.NewRouter()
+	r.Use(handler.LinkDomain)
+	SetupRedirectRoutes(r, handler, nil)
+
+	visit := func(target string, cookie *http.Cookie, token string) int {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		if token != "" {
+			req.Header.Set("X-Link-Token", token)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	expires := time.Now().Add(time.Minute)
+	// Unlocking abc on the default domain doesn't unlock go.example/abc
+	defaultCookie := &http.Cookie{Name: "verified_abc", Value: access.Issue("abc", "anonymous", expires)}
+	assert.Equal(t, http.StatusFound, visit("/r/abc", defaultCookie, ""))
+	assert.Equal(t, http.StatusOK, visit("/r/abc?domain=go.example", defaultCookie, ""))
+	assert.Equal(t, http.StatusOK, visit("/r/abc?domain=go.example", nil, access.IssueToken("abc", expires)))
+
+	domainCookie := &http.Cookie{Name: "verified_go.example%2Fabc", Value: access.Issue("go.example/abc", "anonymous", expires)}
+	assert.Equal(t, http.StatusFound, visit("/r/abc?domain=go.example", domainCookie, ""))
+	assert.Equal(t, http.StatusOK, visit("/r/abc", domainCookie, ""))
+	assert.Equal(t, http.StatusFound, visit("/r/abc?domain=go.example", nil, access.IssueToken("go.example/abc", expires)))
+}
+
+// fakeResolver serves one link without a link service
+type fakeResolver struct {
+	ResolverInterface
+	link   *storage.Link
+	clicks int
+	// overLimit turns redirects away as over the link's rate limit
+	overLimit bool
+}
+
+func (f *fakeResolver) GetLink(ctx context.Context, code string) (*storage.Link, error) {
+	if code != f.link.Code {
+		return nil, nil
+	}
+	return f.link, nil
+}
+
+func (f *fakeResolver) IsExpired(link *storage.Link) bool { return false }
+
+func (f *fakeResolver) AllowsRequester(link *storage.Link, ip netip.Addr) bool { return true }
+
+func (f *fakeResolver) Destination(link *storage.Link, values []string) (string, error) {
+	return link.LongURL, nil
+}
+
+func (f *fakeResolver) CountsClick(link *storage.Link, visit service.Visit) bool { return true }
+
+func (f *fakeResolver) DedupsClicks() bool { return false }
+
+func (f *fakeResolver) AllowRedirect(ctx context.Context, link *storage.Link) (bool, time.Duration) {
+	if f.overLimit {
+		return false, 90 * time.Second
+	}
+	return true, 0
+}
+
+func (f *fakeResolver) RecordClick(ctx context.Context, link *storage.Link, visit service.Visit) error {
+	f.clicks++
+	return nil
+}
+
+func TestRedirectHandler(t *testing.T) {
+	resolver := &fakeResolver{link: &storage.Link{Code: "abc", LongURL: "https://example.com/abc", RedirectType: http.StatusMovedPermanently}}
+	r := chi.NewRouter()
+	SetupRedirectRoutes(r, NewRedirectHandler(resolver), nil)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/r/abc", nil))
+	assert.Equal(t, http.StatusMovedPermanently, rec.Code)
+	assert.Equal(t, "https://example.com/abc", rec.Header().Get("Location"))
+	assert.Empty(t, rec.Header().Get("X-Robots-Tag"))
+	assert.Equal(t, 1, resolver.clicks)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/r/xyz", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestRedirectNoIndex(t *testing.T) {
+	resolver := &fakeResolver{link: &storage.Link{Code: "abc", LongURL: "https://example.com/abc", NoIndex: true}, overLimit: true}
+	r := chi.NewRouter()
+	SetupRedirectRoutes(r, NewRedirectHandler(resolver), nil)
+
+	// Responses other than the redirect are covered too
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/r/abc", nil))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "noindex", rec.Header().Get("X-Robots-Tag"))
+
+	resolver.overLimit = false
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/r/abc", nil))
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "noindex", rec.Header().Get("X-Robots-Tag"))
+}
+
+func TestRedirectOverRateLimit(t *testing.T) {
+	resolver := &fakeResolver{link: &storage.Link{Code: "abc", LongURL: "https://example.com/abc"}, overLimit: true}
+	r := chi.NewRouter()
+	SetupRedirectRoutes(r, NewRedirectHandler(resolver), nil)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/r/abc", nil))
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "90", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), "try again in about 1 minute.")
+	assert.Empty(t, rec.Header().Get("Location"))
+	assert.Equal(t, 0, resolver.clicks)
+}
+
+func TestRoutePrefixes(t *testing.T) {
+	handler := NewHandler(service.NewLinkService(nil, nil, nil, nil), nil)
+	api := chi.NewRouter()
+	SetupAPIRoutes(api, handler, nil, func(next http.Handler) http.Handler { return next })
+	redirect := chi.NewRouter()
+	SetupRedirectRoutes(redirect, handler, NewBundleHandler(nil))
+
+	prefixes := RoutePrefixes(api, redirect)
+	assert.ElementsMatch(t, []string{"health", "v1", "v2", "r", "b"}, prefixes)
+}
+
+func TestResolve(t *testing.T) {
+	clicks := &fakeClickCache{}
+	handler := NewHandler(service.NewLinkService(nil, clicks, nil, nil), nil)
+	r := chi.NewRouter()
+	r.Get("/v1/resolve/{code}", handler.Resolve)
+
+	resolve := func(target string) ResolveResponse {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		var resp ResolveResponse
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+		return resp
+	}
+
+	resp := resolve("/v1/resolve/abc")
+	assert.Equal(t, "https://example.com/abc", resp.LongURL)
+	assert.Equal(t, http.StatusFound, resp.RedirectType)
+	assert.True(t, resp.Counted)
+	assert.Equal(t, int64(1), clicks.clicks)
+
+	resp = resolve("/v1/resolve/abc?count=false")
+	assert.False(t, resp.Counted)
+	assert.Equal(t, int64(1), clicks.clicks)
+}
+
+// rateLimitedCache serves links limited to one redirect an hour, which
+// have all been used
+type rateLimitedCache struct {
+	fakeClickCache
+}
+
+func (*rateLimitedCache) Get(ctx context.Context, code string) (*cache.CachedLink, error) {
+	limit := 1
+	return &cache.CachedLink{LongURL: "https://example.com/" + code, RateLimit: &limit, RateLimitWindow: "hour"}, nil
+}
+
+func (*rateLimitedCache) CountRedirects(ctx context.Context, code string, window time.Duration, now time.Time, n int64) (int64, error) {
+	return 2, nil
+}
+
+func TestResolveOverRateLimit(t *testing.T) {
+	clicks := &rateLimitedCache{}
+	r := newV2Router(service.NewLinkService(nil, clicks, nil, nil))
+
+	for _, target := range []string{"/v1/resolve/abc", "/v2/resolve/abc"} {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code, target)
+		assert.NotEmpty(t, rec.Header().Get("Retry-After"), target)
+		assert.NotContains(t, rec.Body.String(), "https://example.com/abc", target)
+	}
+	assert.Equal(t, int64(0), clicks.clicks)
+}
+
+func TestResolveProtectedLink(t *testing.T) {
+	linkService := service.NewLinkService(protectedLinks{}, &protectedCache{}, nil, nil)
+	handler := NewHandler(linkService, nil)
+	r := chi.NewRouter()
+	r.Get("/v1/resolve/{code}", handler.Resolve)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/resolve/abc?count=false", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/resolve/abc?count=false", nil)
+	req.Header.Set("X-Link-Token", handler.access.IssueToken("abc", time.Now().Add(time.Minute)))
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"password_protected":true`)
+}
+
+func TestResolveMany(t *testing.T) {
+	linkService := service.NewLinkService(nil, &fakeClickCache{}, nil, nil)
+	r := chi.NewRouter()
+	// Bulk resolve is a read and must work without a CSRF token
+	SetupAPIRoutes(r, NewHandler(linkService, nil), nil, security.CSRFMiddleware(security.NewCSRFTokenManager()))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/resolve", strings.NewReader(`{"codes":["abc","xyz"]}`)))
+	require.Equal(t, http.StatusOK, rec.Code)
+	var resp BulkResolveResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+	require.Len(t, resp.Results, 2)
+	assert.Equal(t, "abc", resp.Results[0].Code)
+	assert.Equal(t, ResolveOK, resp.Results[0].Status)
+	assert.Equal(t, "https://example.com/xyz", resp.Results[1].LongURL)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/resolve", strings.NewReader(`{"codes":[]}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+type restrictedCache struct {
+	fakeClickCache
+}
+
+func (*restrictedCache) Get(ctx context.Context, code string) (*cache.CachedLink, error) {
+	return &cache.CachedLink{LongURL: "https://intranet.example.com/" + code, AllowCIDRs: []string{"10.0.0.0/8"}}, nil
+}
+
+func (c *restrictedCache) GetMany(ctx context.Context, codes []string) (map[string]*cache.CachedLink, error) {
+	links := make(map[string]*cache.CachedLink)
+	for _, code := range codes {
+		links[code], _ = c.Get(ctx, code)
+	}
+	return links, nil
+}
+
+func TestRedirectEnforcesIPRules(t *testing.T) {
+	clicks := &restrictedCache{}
+	r := chi.NewRouter()
+	handler := NewHandler(service.NewLinkService(nil, clicks, nil, nil), nil)
+	SetupAPIRoutes(r, handler, nil, func(next http.Handler) http.Handler { return next })
+	SetupRedirectRoutes(r, handler, nil)
+
+	visit := func(method, target, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(`{"codes":["abc"]}`))
+		req.RemoteAddr = remoteAddr
+		// Clients can claim any address here; it is ignored
+		req.Header.Set("X-Forwarded-For", "10.0.0.1")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := visit(http.MethodGet, "/r/abc", "10.1.2.3:1234")
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "private", rec.Header().Get("Cache-Control"))
+	assert.Equal(t, http.StatusForbidden, visit(http.MethodGet, "/r/abc", "198.51.100.1:1234").Code)
+	assert.Equal(t, int64(1), clicks.clicks)
+
+	assert.Equal(t, http.StatusForbidden, visit(http.MethodGet, "/v1/resolve/abc", "198.51.100.1:1234").Code)
+	rec = visit(http.MethodPost, "/v1/resolve", "198.51.100.1:1234")
+	assert.Contains(t, rec.Body.String(), `"status":"forbidden"`)
+	assert.NotContains(t, rec.Body.String(), "intranet")
+}
+
+type paramCache struct {
+	fakeClickCache
+}
+
+func (*paramCache) Get(ctx context.Context, code string) (*cache.CachedLink, error) {
+	return &cache.CachedLink{LongURL: "https://example.com/orders/{id}", ParamRules: map[string]string{"id": "int"}}, nil
+}
+
+func TestRedirectFillsParameters(t *testing.T) {
+	clicks := &paramCache{}
+	r := chi.NewRouter()
+	handler := NewHandler(service.NewLinkService(nil, clicks, nil, nil), nil)
+	SetupAPIRoutes(r, handler, nil, func(next http.Handler) http.Handler { return next })
+	SetupRedirectRoutes(r, handler, nil)
+
+	visit := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := visit("/r/orders/1234")
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "https://example.com/orders/1234", rec.Header().Get("Location"))
+	assert.Equal(t, int64(1), clicks.clicks)
+
+	assert.Equal(t, http.StatusBadRequest, visit("/r/orders/12ab").Code)
+	assert.Equal(t, http.StatusBadRequest, visit("/r/orders").Code)
+	assert.Equal(t, int64(1), clicks.clicks)
+}
+
+type disabledCache struct {
+	fakeClickCache
+}
+
+func (*disabledCache) Get(ctx context.Context, code string) (*cache.CachedLink, error) {
+	return &cache.CachedLink{LongURL: "https://example.com/spam", Disabled: true}, nil
+}
+
+func (c *disabledCache) GetMany(ctx context.Context, codes []string) (map[string]*cache.CachedLink, error) {
+	links := make(map[string]*cache.CachedLink)
+	for _, code := range codes {
+		links[code], _ = c.Get(ctx, code)
+	}
+	return links, nil
+}
+
+func TestRedirectRefusesDisabledLinks(t *testing.T) {
+	clicks := &disabledCache{}
+	r := chi.NewRouter()
+	handler := NewHandler(service.NewLinkService(nil, clicks, nil, nil), nil)
+	SetupAPIRoutes(r, handler, nil, func(next http.Handler) http.Handler { return next })
+	SetupRedirectRoutes(r, handler, nil)
+
+	visit := func(method, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(`{"codes":["abc"]}`)))
+		return rec
+	}
+
+	assert.Equal(t, http.StatusGone, visit(http.MethodGet, "/r/abc").Code)
+	assert.Equal(t, http.StatusGone, visit(http.MethodGet, "/v1/resolve/abc").Code)
+	rec := visit(http.MethodPost, "/v1/resolve")
+	assert.Contains(t, rec.Body.String(), `"status":"disabled"`)
+	assert.NotContains(t, rec.Body.String(), "spam")
+	assert.Equal(t, int64(0), clicks.clicks)
+}
+
+// benchCache serves one cached link and takes queued clicks, without the
+// allocations of a Redis client
+type benchCache struct {
+	cache.LinkCacheInterface
+	link *cache.CachedLink
+}
+
+func (c *benchCache) Get(ctx context.Context, code string) (*cache.CachedLink, error) {
+	return c.link, nil
+}
+
+func (c *benchCache) ReturnClickDeltas(ctx context.Context, deltas map[string]int64) error {
+	return nil
+}
+
+func (c *benchCache) RecordClicks(ctx context.Context, clicks []*cache.StreamedClick) error {
+	return nil
+}
+
+func BenchmarkRedirect(b *testing.B) {
+	owner := uuid.New()
+	linkService := service.NewLinkService(nil, &benchCache{link: &cache.CachedLink{
+		LongURL: "https://example.com/landing?utm_source=newsletter",
+		OwnerID: &owner,
+	}}, nil, logging.NewLogger(logging.LevelError))
+	linkService.UseClickQueue(100000, service.ClickQueueDrop)
+	clicks, stop := context.WithCancel(context.Background())
+	defer stop()
+	go linkService.RunClickQueue(clicks)
+
+	r := chi.NewRouter()
+	SetupRedirectRoutes(r, NewRedirectHandler(linkService), nil)
+	req := httptest.NewRequest(http.MethodGet, "/r/abc", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64)")
+	req.Header.Set("Referer", "https://news.example/")
+
+	b.ReportAllocs()
+	for b.Loop() {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if rec.Code != http.StatusFound {
+			b.Fatalf("status %d", rec.Code)
+		}
+	}
+}
+
+func TestRouteClass(t *testing.T) {
+	tests := []struct {
+		method, path, class string
+	}{
+		{http.MethodGet, "/health", ""},
+		{http.MethodGet, "/r/abc", RouteClassRedirect},
+		{http.MethodHead, "/r/abc/42", RouteClassRedirect},
+		{http.MethodGet, "/b/launch", RouteClassRedirect},
+		{http.MethodPost, "/v1/resolve", RouteClassRedirect},
+		{http.MethodGet, "/v1/links", RouteClassRead},
+		{http.MethodPost, "/v1/links", RouteClassWrite},
+		{http.MethodDelete, "/v1/links/abc", RouteClassWrite},
+		{http.MethodDelete, "/v1/links/resolve-x", RouteClassWrite},
+		{http.MethodGet, "/v2/resolve/abc", RouteClassRedirect},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.class, RouteClass(httptest.NewRequest(tt.method, tt.path, nil)), tt.method+" "+tt.path)
+	}
+}
+
+func TestWrites(t *testing.T) {
+	assert.True(t, Writes(httptest.NewRequest(http.MethodPost, "/v1/links", nil)))
+	assert.True(t, Writes(httptest.NewRequest(http.MethodPut, "/admin/users/abc/plan", nil)))
+	assert.False(t, Writes(httptest.NewRequest(http.MethodGet, "/v1/links", nil)))
+	assert.False(t, Writes(httptest.NewRequest(http.MethodPost, "/v1/resolve", nil)))
+	assert.False(t, Writes(httptest.NewRequest(http.MethodPost, "/graphql", nil)))
+	assert.False(t, Writes(httptest.NewRequest(http.MethodPost, "/auth/logout", nil)))
+}
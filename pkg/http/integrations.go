@@ -0,0 +1,132 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"url-shortener/pkg/integrations/triggers"
+	"url-shortener/pkg/middleware"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// IntegrationHandler serves /v1/integrations, the triggers automation
+// platforms poll or subscribe to
+type IntegrationHandler struct {
+	triggers *triggers.Service
+}
+
+func NewIntegrationHandler(triggers *triggers.Service) *IntegrationHandler {
+	return &IntegrationHandler{
+		triggers: triggers,
+	}
+}
+
+// Me is the platforms' connection test: it answers 200 with the account
+// for valid credentials
+func (h *IntegrationHandler) Me(w http.ResponseWriter, r *http.Request) {
+	account, err := h.triggers.Me(r.Context())
+	if err != nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(account)
+}
+
+// Poll returns the trigger's most recent items as a JSON array, newest
+// first
+func (h *IntegrationHandler) Poll(w http.ResponseWriter, r *http.Request) {
+	threshold, _ := strconv.Atoi(r.URL.Query().Get("threshold"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	items, err := h.triggers.Poll(r.Context(), chi.URLParam(r, "trigger"), threshold, limit)
+	if err != nil {
+		if writeValidationError(w, err) {
+			return
+		}
+		if errors.Is(err, triggers.ErrUnknownTrigger) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(items)
+}
+
+func (h *IntegrationHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	var req triggers.SubscribeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	hook, err := h.triggers.Subscribe(r.Context(), &req)
+	if err != nil {
+		if writeValidationError(w, err) {
+			return
+		}
+		if errors.Is(err, triggers.ErrTooManyHooks) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(hook)
+}
+
+func (h *IntegrationHandler) ListHooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.triggers.ListHooks(r.Context())
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"hooks": hooks})
+}
+
+func (h *IntegrationHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if err := h.triggers.Unsubscribe(r.Context(), id); err != nil {
+		switch {
+		case errors.Is(err, triggers.ErrHookNotFound):
+			http.Error(w, "not found", http.StatusNotFound)
+		case errors.Is(err, triggers.ErrNotOwner):
+			http.Error(w, "forbidden", http.StatusForbidden)
+		default:
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func SetupIntegrationRoutes(r *chi.Mux, handler *IntegrationHandler, oauthMiddleware *middleware.OAuthMiddleware, csrfMiddleware func(http.Handler) http.Handler) {
+	r.With(csrfMiddleware).Route("/v1/integrations", func(r chi.Router) {
+		if oauthMiddleware != nil {
+			r.With(oauthMiddleware.Authenticate("links:read")).Get("/me", handler.Me)
+			r.With(oauthMiddleware.Authenticate("links:read")).Get("/triggers/{trigger}", handler.Poll)
+			r.With(oauthMiddleware.Authenticate("links:read")).Get("/hooks", handler.ListHooks)
+			r.With(oauthMiddleware.Authenticate("links:read")).Post("/hooks", handler.Subscribe)
+			r.With(oauthMiddleware.Authenticate("links:read")).Delete("/hooks/{id}", handler.Unsubscribe)
+		} else {
+			r.Get("/me", handler.Me)
+			r.Get("/triggers/{trigger}", handler.Poll)
+			r.Get("/hooks", handler.ListHooks)
+			r.Post("/hooks", handler.Subscribe)
+			r.Delete("/hooks/{id}", handler.Unsubscribe)
+		}
+	})
+}
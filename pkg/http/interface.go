@@ -0,0 +1,48 @@
+package http
+
+import (
+	"context"
+	"net/netip"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/storage"
+)
+
+// ResolverInterface is what the redirect routes need from the link service:
+// finding a link and deciding whether and where a visit goes
+type ResolverInterface interface {
+	GetLink(ctx context.Context, code string) (*storage.Link, error)
+	IsExpired(link *storage.Link) bool
+	AllowsRequester(link *storage.Link, ip netip.Addr) bool
+	Destination(link *storage.Link, values []string) (string, error)
+	CountsClick(link *storage.Link, visit service.Visit) bool
+	DedupsClicks() bool
+	FirstVisit(ctx context.Context, key string, visit service.Visit) bool
+	AllowRedirect(ctx context.Context, link *storage.Link) (bool, time.Duration)
+	RecordClick(ctx context.Context, link *storage.Link, visit service.Visit) error
+	GetPublicStats(ctx context.Context, code string) (*service.PublicStats, error)
+	ShortDomainFor(host string) string
+	ValidateDomain(domain string) error
+	TenantOf(domain string) string
+	VerifyPassword(ctx context.Context, code, password string) (*storage.Link, error)
+}
+
+// LinkServiceInterface is what the API routes need from the link service.
+// *service.LinkService implements it.
+type LinkServiceInterface interface {
+	ResolverInterface
+	CreateLink(ctx context.Context, req *service.CreateLinkRequest) (*service.CreateLinkResponse, error)
+	GetLinks(ctx context.Context, codes []string) (map[string]*storage.Link, error)
+	ListLinks(ctx context.Context, query storage.LinkQuery, limit, offset int) ([]*storage.Link, error)
+	GetOwnedLink(ctx context.Context, code string) (*storage.Link, error)
+	GetSharedLink(ctx context.Context, code string) (*storage.Link, error)
+	UpdateLink(ctx context.Context, code string, req *service.UpdateLinkRequest) error
+	DeleteLink(ctx context.Context, code string) error
+	LoadTags(ctx context.Context, links []*storage.Link) error
+	Stats(link *storage.Link) *service.LinkStats
+	GetCreation(ctx context.Context, link *storage.Link) (*storage.CreationContext, error)
+	LinkShortURL(link *storage.Link) string
+	TopLinks(ctx context.Context, period string, limit int, global bool) ([]cache.TopLink, error)
+}
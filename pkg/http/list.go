@@ -0,0 +1,136 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+)
+
+// linkSorts maps ?sort= of ListLinks to storage orders
+var linkSorts = map[string]string{
+	"created_at":   storage.LinkOrderNewest,
+	"clicks":       storage.LinkOrderClicks,
+	"last_clicked": storage.LinkOrderLastClicked,
+	"expires_at":   storage.LinkOrderExpiring,
+	"stalest":      storage.LinkOrderStalest,
+}
+
+var linkStatuses = map[string]bool{
+	storage.LinkStatusActive:   true,
+	storage.LinkStatusExpired:  true,
+	storage.LinkStatusDisabled: true,
+}
+
+// ListLinks lists the caller's links a page at a time (?limit=, ?offset=),
+// sorted and filtered as parseLinkQuery reads. ?fields= and ?expand= shape
+// each link as for GetLink.
+func (h *Handler) ListLinks(w http.ResponseWriter, r *http.Request) {
+	sel, err := parseSelection(r, storage.Link{}, "stats", "tags")
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	query, err := parseLinkQuery(r)
+	if err != nil {
+		writeValidationError(w, err)
+		return
+	}
+	limit, offset, ok := parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	links, err := h.linkService.ListLinks(r.Context(), query, limit, offset)
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	out := make([]map[string]json.RawMessage, len(links))
+	for i, link := range links {
+		expanded, err := h.expandLink(r.Context(), sel, link)
+		if err == nil {
+			out[i], err = sel.render(link, expanded)
+		}
+		if err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"links": out})
+}
+
+// parseLinkQuery reads the order and filters of ListLinks:
+//
+//	sort            created_at (newest first, the default), clicks (most
+//	                first), last_clicked (most recent first), expires_at
+//	                (soonest first) or stalest (never or longest ago clicked
+//	                first)
+//	status          active, expired or disabled
+//	has_password    true or false
+//	domain          the short domain, empty for the default one
+//	tag             a tag the links have
+//	created_before  RFC 3339 time
+//	created_after   RFC 3339 time
+//
+// Invalid values are reported as validation.Errors.
+func parseLinkQuery(r *http.Request) (storage.LinkQuery, error) {
+	params := r.URL.Query()
+	var query storage.LinkQuery
+	var errs validation.Errors
+
+	if v := params.Get("sort"); v != "" {
+		order, ok := linkSorts[v]
+		if !ok {
+			errs = append(errs, validation.FieldError{Field: "sort", Rule: "oneof", Message: "must be one of created_at, clicks, last_clicked, expires_at, stalest"})
+		}
+		query.Order = order
+	}
+	if v := params.Get("status"); v != "" {
+		if !linkStatuses[v] {
+			errs = append(errs, validation.FieldError{Field: "status", Rule: "oneof", Message: "must be one of active, expired, disabled"})
+		}
+		query.Status = v
+	}
+	if v := params.Get("has_password"); v != "" {
+		hasPassword, err := strconv.ParseBool(v)
+		if err != nil {
+			errs = append(errs, validation.FieldError{Field: "has_password", Rule: "bool", Message: "must be true or false"})
+		}
+		query.HasPassword = &hasPassword
+	}
+	if params.Has("domain") {
+		// Other than "", LinkDomain has checked it is a short domain
+		domain := strings.ToLower(params.Get("domain"))
+		query.Domain = &domain
+	}
+	query.Tag = strings.TrimSpace(params.Get("tag"))
+	for _, bound := range []struct {
+		name string
+		t    **time.Time
+	}{
+		{"created_before", &query.CreatedBefore},
+		{"created_after", &query.CreatedAfter},
+	} {
+		v := params.Get(bound.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			errs = append(errs, validation.FieldError{Field: bound.name, Rule: "time", Message: "must be an RFC 3339 time"})
+			continue
+		}
+		*bound.t = &t
+	}
+
+	if len(errs) > 0 {
+		return storage.LinkQuery{}, errs
+	}
+	return query, nil
+}
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type listedLinks struct {
+	LinkServiceInterface
+	query         storage.LinkQuery
+	limit, offset int
+}
+
+func (l *listedLinks) ListLinks(ctx context.Context, query storage.LinkQuery, limit, offset int) ([]*storage.Link, error) {
+	l.query, l.limit, l.offset = query, limit, offset
+	return []*storage.Link{{Code: "abc", LongURL: "https://example.com/abc", ClickCount: 3}}, nil
+}
+
+func TestListLinks(t *testing.T) {
+	links := &listedLinks{}
+	handler := NewHandler(links, nil)
+
+	list := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ListLinks(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := list("/v1/links?fields=code,click_count")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"links": [{"code": "abc", "click_count": 3}]}`, rec.Body.String())
+	assert.Equal(t, storage.LinkQuery{}, links.query)
+	assert.Equal(t, 20, links.limit)
+
+	rec = list("/v1/links?sort=clicks&status=expired&has_password=false&domain=&tag=launch&created_after=2024-01-01T00:00:00Z&limit=50&offset=100")
+	require.Equal(t, http.StatusOK, rec.Code)
+	noPassword, defaultDomain := false, ""
+	after := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, storage.LinkQuery{
+		Order:        storage.LinkOrderClicks,
+		Status:       storage.LinkStatusExpired,
+		HasPassword:  &noPassword,
+		Domain:       &defaultDomain,
+		Tag:          "launch",
+		CreatedAfter: &after,
+	}, links.query)
+	assert.Equal(t, 50, links.limit)
+	assert.Equal(t, 100, links.offset)
+
+	rec = list("/v1/links?sort=size&status=gone&created_before=yesterday")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"sort"`)
+	assert.Contains(t, rec.Body.String(), `"status"`)
+	assert.Contains(t, rec.Body.String(), `"created_before"`)
+}
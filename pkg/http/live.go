@@ -0,0 +1,274 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"url-shortener/pkg/live"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	// liveFlushInterval is how often a connection sends what it has queued
+	liveFlushInterval = 250 * time.Millisecond
+	livePingInterval  = 30 * time.Second
+	liveWriteTimeout  = 10 * time.Second
+	// liveBacklog bounds the link changes waiting to be sent; past it the
+	// client is told to resync instead
+	liveBacklog = 100
+	// liveMaxMessage and liveMaxCodes bound what clients send, and
+	// liveClientRate how many messages per second
+	liveMaxMessage = 64 << 10
+	liveMaxCodes   = 1000
+	liveClientRate = 5
+)
+
+// LiveEvents publishes events to dashboards and subscribes dashboards to
+// their owner's events; see package live
+type LiveEvents interface {
+	Publish(event *live.Event)
+	Subscribe(ownerID uuid.UUID) *live.Subscription
+}
+
+// UseLiveEvents publishes clicks and links found expired to events, and
+// serves /v1/ws from it, sending each connection at most messagesPerSecond
+// messages
+func (h *Handler) UseLiveEvents(events LiveEvents, messagesPerSecond int) {
+	h.live = events
+	h.liveRate = messagesPerSecond
+}
+
+// publishLive hands an event about link to dashboards
+func (h *Handler) publishLive(eventType string, link *storage.Link) {
+	if h.live == nil || link.OwnerID == nil {
+		return
+	}
+	event := &live.Event{
+		Type:    eventType,
+		Code:    link.Code,
+		At:      time.Now().UTC(),
+		OwnerID: *link.OwnerID,
+	}
+	if link.Domain != nil {
+		event.Domain = *link.Domain
+	}
+	if eventType == live.LinkClicked {
+		event.Clicks = 1
+	}
+	h.live.Publish(event)
+}
+
+// liveFilter is what clients send to narrow their events to some links;
+// no codes means all of them
+type liveFilter struct {
+	Codes []string `json:"codes"`
+}
+
+// LiveUpdates upgrades to a WebSocket that pushes the events of the caller's
+// links: link.created, link.updated, link.deleted, link.expired, and
+// link.clicked with the clicks since the last one. Clicks are coalesced per
+// link while the connection is at its message rate or the client reads
+// slowly; when link changes pile up, or events were dropped, the client is
+// sent {"type":"resync"} and should reload instead.
+func (h *Handler) LiveUpdates(w http.ResponseWriter, r *http.Request) {
+	if h.live == nil {
+		http.Error(w, "live updates are not enabled", http.StatusNotFound)
+		return
+	}
+	ownerID := middleware.GetOwnerIDFromContext(r.Context())
+	if ownerID == uuid.Nil {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	server := websocket.Server{
+		Handshake: sameOrigin,
+		Handler: func(ws *websocket.Conn) {
+			ws.MaxPayloadBytes = liveMaxMessage
+			conn := &liveConn{
+				ws:     ws,
+				sub:    h.live.Subscribe(ownerID),
+				rate:   h.liveRate,
+				clicks: make(map[liveLink]*live.Event),
+			}
+			defer conn.sub.Close()
+			conn.serve()
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// sameOrigin refuses browser connections from other sites, which would
+// otherwise ride on the session cookie
+func sameOrigin(config *websocket.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host != r.Host {
+		return errors.New("cross-origin WebSocket")
+	}
+	config.Origin = u
+	return nil
+}
+
+type liveLink struct {
+	domain, code string
+}
+
+type liveConn struct {
+	ws   *websocket.Conn
+	sub  *live.Subscription
+	rate int
+	// budget is how many messages may be sent now, refilled at rate per
+	// second up to rate
+	budget float64
+	codes  map[string]bool
+	// backlog holds link changes, and clicks the pending clicks per link,
+	// both waiting for budget
+	backlog []*live.Event
+	clicks  map[liveLink]*live.Event
+	resync  bool
+}
+
+func (c *liveConn) serve() {
+	filters := make(chan map[string]bool, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.readFilters(filters)
+	}()
+	defer c.ws.Close()
+
+	c.budget = float64(c.rate)
+	flush := time.NewTicker(liveFlushInterval)
+	defer flush.Stop()
+	ping := time.NewTicker(livePingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case codes := <-filters:
+			c.codes = codes
+		case event := <-c.sub.Events():
+			c.add(event)
+		case <-ping.C:
+			if err := c.send(&live.Event{Type: "ping", At: time.Now().UTC()}); err != nil {
+				return
+			}
+		case <-flush.C:
+			if err := c.flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (c *liveConn) add(event *live.Event) {
+	if c.codes != nil && !c.codes[event.Code] {
+		return
+	}
+	if event.Type != live.LinkClicked {
+		c.backlog = append(c.backlog, event)
+		if len(c.backlog) > liveBacklog {
+			c.resync = true
+		}
+		return
+	}
+	link := liveLink{event.Domain, event.Code}
+	if pending := c.clicks[link]; pending != nil {
+		pending.Clicks += event.Clicks
+		pending.At = event.At
+		return
+	}
+	coalesced := *event
+	c.clicks[link] = &coalesced
+}
+
+// flush sends what the budget allows, link changes first
+func (c *liveConn) flush() error {
+	if c.rate > 0 {
+		c.budget = min(c.budget+float64(c.rate)*liveFlushInterval.Seconds(), float64(c.rate))
+	}
+	if c.sub.Dropped() > 0 || c.resync {
+		c.backlog = nil
+		clear(c.clicks)
+		c.resync = false
+		return c.send(&live.Event{Type: "resync", At: time.Now().UTC()})
+	}
+	for len(c.backlog) > 0 && c.spend() {
+		if err := c.send(c.backlog[0]); err != nil {
+			return err
+		}
+		c.backlog = c.backlog[1:]
+	}
+	for link, event := range c.clicks {
+		if !c.spend() {
+			break
+		}
+		if err := c.send(event); err != nil {
+			return err
+		}
+		delete(c.clicks, link)
+	}
+	return nil
+}
+
+// spend takes a message from the budget, if there is one left
+func (c *liveConn) spend() bool {
+	if c.rate <= 0 {
+		return true
+	}
+	if c.budget < 1 {
+		return false
+	}
+	c.budget--
+	return true
+}
+
+// send writes event, giving up on clients that don't read it in time
+func (c *liveConn) send(event *live.Event) error {
+	c.ws.SetWriteDeadline(time.Now().Add(liveWriteTimeout))
+	return websocket.JSON.Send(c.ws, event)
+}
+
+// readFilters passes on the filters the client sends until it disconnects,
+// sends something invalid or sends too often
+func (c *liveConn) readFilters(filters chan map[string]bool) {
+	window := time.Now()
+	received := 0
+	for {
+		var filter liveFilter
+		if err := websocket.JSON.Receive(c.ws, &filter); err != nil {
+			return
+		}
+		if time.Since(window) >= time.Second {
+			window = time.Now()
+			received = 0
+		}
+		received++
+		if received > liveClientRate || len(filter.Codes) > liveMaxCodes {
+			return
+		}
+		var codes map[string]bool
+		if len(filter.Codes) > 0 {
+			codes = make(map[string]bool, len(filter.Codes))
+			for _, code := range filter.Codes {
+				codes[code] = true
+			}
+		}
+		// Only the latest filter matters
+		select {
+		case <-filters:
+		default:
+		}
+		filters <- codes
+	}
+}
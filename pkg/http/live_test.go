@@ -0,0 +1,89 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/live"
+	"url-shortener/pkg/middleware"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func liveServer(t *testing.T, hub *live.Hub, owner uuid.UUID, rate int) string {
+	h := &Handler{}
+	h.UseLiveEvents(hub, rate)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.LiveUpdates(w, r.WithContext(middleware.WithOwnerID(r.Context(), owner)))
+	}))
+	t.Cleanup(server.Close)
+	return strings.Replace(server.URL, "http://", "ws://", 1)
+}
+
+func receiveLive(t *testing.T, ws *websocket.Conn) *live.Event {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var event live.Event
+	require.NoError(t, websocket.JSON.Receive(ws, &event))
+	return &event
+}
+
+func TestLiveUpdates(t *testing.T) {
+	hub := live.NewHub()
+	owner := uuid.New()
+	url := liveServer(t, hub, owner, 0)
+
+	ws, err := websocket.Dial(url, "", strings.Replace(url, "ws://", "http://", 1))
+	require.NoError(t, err)
+	defer ws.Close()
+	require.NoError(t, websocket.JSON.Send(ws, liveFilter{Codes: []string{"abc"}}))
+	// The filter is applied before the next events arrive
+	time.Sleep(50 * time.Millisecond)
+
+	hub.Publish(&live.Event{Type: live.LinkClicked, Code: "other", Clicks: 1, OwnerID: owner})
+	hub.Publish(&live.Event{Type: live.LinkClicked, Code: "abc", Clicks: 1, OwnerID: owner})
+	hub.Publish(&live.Event{Type: live.LinkClicked, Code: "abc", Clicks: 1, OwnerID: owner})
+	hub.Publish(&live.Event{Type: live.LinkCreated, Code: "abc", OwnerID: owner})
+	hub.Publish(&live.Event{Type: live.LinkClicked, Code: "abc", Clicks: 1, OwnerID: uuid.New()})
+
+	created := receiveLive(t, ws)
+	assert.Equal(t, live.LinkCreated, created.Type)
+	clicked := receiveLive(t, ws)
+	assert.Equal(t, live.LinkClicked, clicked.Type)
+	assert.Equal(t, "abc", clicked.Code)
+	assert.Equal(t, 2, clicked.Clicks, "clicks are coalesced per link")
+}
+
+func TestLiveUpdatesResync(t *testing.T) {
+	hub := live.NewHub()
+	owner := uuid.New()
+	url := liveServer(t, hub, owner, 1)
+
+	ws, err := websocket.Dial(url, "", strings.Replace(url, "ws://", "http://", 1))
+	require.NoError(t, err)
+	defer ws.Close()
+	time.Sleep(50 * time.Millisecond)
+
+	// More link changes than the connection may send pile up
+	for i := 0; i <= liveBacklog+1; i++ {
+		hub.Publish(&live.Event{Type: live.LinkUpdated, Code: "abc", OwnerID: owner})
+	}
+	// A change may have been sent before the rest arrived
+	event := receiveLive(t, ws)
+	if event.Type == live.LinkUpdated {
+		event = receiveLive(t, ws)
+	}
+	assert.Equal(t, "resync", event.Type)
+}
+
+func TestLiveUpdatesCrossOrigin(t *testing.T) {
+	url := liveServer(t, live.NewHub(), uuid.New(), 0)
+	_, err := websocket.Dial(url, "", "https://evil.example")
+	assert.Error(t, err)
+}
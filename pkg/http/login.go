@@ -0,0 +1,241 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/session"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+)
+
+// loginStateTTL bounds how long a user may take at the IdP before the
+// callback is rejected
+const loginStateTTL = 10 * time.Minute
+
+type LoginConfig struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	// Scope granted to browser sessions when the IdP doesn't return one
+	SessionScope string
+	SessionTTL   time.Duration
+}
+
+// LoginHandler runs the OIDC authorization-code + PKCE flow and turns the
+// result into a server-side session referenced by the session_id cookie
+type LoginHandler struct {
+	oauth2Config oauth2.Config
+	verifier     *oidc.IDTokenVerifier
+	sessions     session.Store
+	sessionScope string
+	sessionTTL   time.Duration
+	logger       *logging.Logger
+}
+
+func NewLoginHandler(provider *oidc.Provider, config LoginConfig, sessions session.Store, logger *logging.Logger) *LoginHandler {
+	return &LoginHandler{
+		oauth2Config: oauth2.Config{
+			ClientID:     config.ClientID,
+			ClientSecret: config.ClientSecret,
+			RedirectURL:  config.RedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       append([]string{oidc.ScopeOpenID, "email"}, strings.Fields(config.SessionScope)...),
+		},
+		verifier:     provider.Verifier(&oidc.Config{ClientID: config.ClientID}),
+		sessions:     sessions,
+		sessionScope: config.SessionScope,
+		sessionTTL:   config.SessionTTL,
+		logger:       logger,
+	}
+}
+
+func (h *LoginHandler) Login(w http.ResponseWriter, r *http.Request) {
+	state, err := session.RandomToken()
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	nonce, err := session.RandomToken()
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	ls := &session.LoginState{
+		Verifier: oauth2.GenerateVerifier(),
+		Nonce:    nonce,
+		ReturnTo: safeReturnTo(r.URL.Query().Get("return_to")),
+	}
+	if err := h.sessions.SaveLoginState(r.Context(), state, ls, loginStateTTL); err != nil {
+		h.logger.Error(r.Context(), "failed to save login state", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	authURL := h.oauth2Config.AuthCodeURL(state,
+		oauth2.S256ChallengeOption(ls.Verifier),
+		oidc.Nonce(nonce),
+	)
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (h *LoginHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if errCode := query.Get("error"); errCode != "" {
+		http.Error(w, "login failed: "+errCode, http.StatusUnauthorized)
+		return
+	}
+
+	ls, err := h.sessions.TakeLoginState(r.Context(), query.Get("state"))
+	if err != nil {
+		h.logger.Error(r.Context(), "failed to load login state", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if ls == nil {
+		http.Error(w, "invalid or expired login state", http.StatusBadRequest)
+		return
+	}
+
+	token, err := h.oauth2Config.Exchange(r.Context(), query.Get("code"), oauth2.VerifierOption(ls.Verifier))
+	if err != nil {
+		h.logger.Warn(r.Context(), "authorization code exchange failed", "error", err)
+		http.Error(w, "login failed", http.StatusUnauthorized)
+		return
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		http.Error(w, "login failed: no id_token", http.StatusUnauthorized)
+		return
+	}
+	idToken, err := h.verifier.Verify(r.Context(), rawIDToken)
+	if err != nil || idToken.Nonce != ls.Nonce {
+		http.Error(w, "login failed: invalid id_token", http.StatusUnauthorized)
+		return
+	}
+
+	var claims struct {
+		Email  string `json:"email"`
+		Scope  string `json:"scope"`
+		Tenant string `json:"tenant"`
+	}
+	if err := idToken.Claims(&claims); err != nil {
+		http.Error(w, "login failed: invalid claims", http.StatusUnauthorized)
+		return
+	}
+
+	// Link ownership is keyed by the subject, so it must be a UUID just like
+	// for bearer tokens
+	ownerID, err := uuid.Parse(idToken.Subject)
+	if err != nil {
+		http.Error(w, "login failed: unsupported subject", http.StatusForbidden)
+		return
+	}
+
+	scope := claims.Scope
+	if tokenScope, ok := token.Extra("scope").(string); ok && scope == "" {
+		scope = tokenScope
+	}
+	if scope == "" {
+		scope = h.sessionScope
+	}
+
+	now := time.Now()
+	sess := &session.Session{
+		OwnerID:   ownerID,
+		Sub:       idToken.Subject,
+		Email:     claims.Email,
+		Scope:     scope,
+		Tenant:    claims.Tenant,
+		CreatedAt: now,
+		ExpiresAt: now.Add(h.sessionTTL),
+	}
+	if err := h.sessions.Create(r.Context(), sess); err != nil {
+		h.logger.Error(r.Context(), "failed to create session", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     session.CookieName,
+		Value:    sess.ID,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   middleware.IsHTTPS(r),
+		// Lax so the cookie survives the top-level redirect back from the IdP
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(h.sessionTTL.Seconds()),
+	})
+	http.Redirect(w, r, ls.ReturnTo, http.StatusFound)
+}
+
+func (h *LoginHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(session.CookieName); err == nil && cookie.Value != "" {
+		if err := h.sessions.Delete(r.Context(), cookie.Value); err != nil {
+			h.logger.Error(r.Context(), "failed to delete session", "error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     session.CookieName,
+		Value:    "",
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   middleware.IsHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   -1,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Session reports whether the caller's cookie refers to a live session so
+// browser clients can decide whether to start a login
+func (h *LoginHandler) Session(w http.ResponseWriter, r *http.Request) {
+	var sess *session.Session
+	if cookie, err := r.Cookie(session.CookieName); err == nil && cookie.Value != "" {
+		sess, err = h.sessions.Get(r.Context(), cookie.Value)
+		if err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	resp := map[string]interface{}{"authenticated": sess != nil}
+	if sess != nil {
+		resp["email"] = sess.Email
+		resp["expires_at"] = sess.ExpiresAt
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// safeReturnTo only allows local paths so the login flow can't be used as
+// an open redirect
+func safeReturnTo(returnTo string) string {
+	if !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") || strings.Contains(returnTo, `\`) {
+		return "/"
+	}
+	return returnTo
+}
+
+func SetupLoginRoutes(r *chi.Mux, handler *LoginHandler, csrfMiddleware func(http.Handler) http.Handler) {
+	r.Route("/auth", func(r chi.Router) {
+		r.Get("/login", handler.Login)
+		r.Get("/callback", handler.Callback)
+		r.Get("/session", handler.Session)
+		r.With(csrfMiddleware).Post("/logout", handler.Logout)
+	})
+}
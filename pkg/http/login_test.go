@@ -0,0 +1,22 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSafeReturnTo(t *testing.T) {
+	tests := map[string]string{
+		"":                      "/",
+		"/dashboard/":           "/dashboard/",
+		"/dashboard/?tab=links": "/dashboard/?tab=links",
+		"//evil.example":        "/",
+		"/\\evil.example":       "/",
+		"https://evil.example":  "/",
+		"dashboard":             "/",
+	}
+	for in, want := range tests {
+		assert.Equal(t, want, safeReturnTo(in), in)
+	}
+}
@@ -0,0 +1,67 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+// routedMethods are checked, in this order, to build Allow headers
+var routedMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// HeadAndOptions serves HEAD from a path's GET route, with the body
+// discarded, and answers OPTIONS with 204 and the methods the path allows.
+// Install it with r.Use before any routes are added.
+func HeadAndOptions(next http.Handler) http.Handler {
+	getHead := chimiddleware.GetHead(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodOptions {
+			getHead.ServeHTTP(w, r)
+			return
+		}
+
+		allowed := allowedMethods(chi.RouteContext(r.Context()).Routes, routePath(r))
+		if len(allowed) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func routePath(r *http.Request) string {
+	if path := chi.RouteContext(r.Context()).RoutePath; path != "" {
+		return path
+	}
+	if r.URL.RawPath != "" {
+		return r.URL.RawPath
+	}
+	return r.URL.Path
+}
+
+// allowedMethods returns the methods routed for path, plus HEAD for GET
+// routes and OPTIONS, or nil if nothing is routed there
+func allowedMethods(routes chi.Routes, path string) []string {
+	var allowed []string
+	for _, method := range routedMethods {
+		if routes.Match(chi.NewRouteContext(), method, path) {
+			allowed = append(allowed, method)
+			if method == http.MethodGet {
+				allowed = append(allowed, http.MethodHead)
+			}
+		}
+	}
+	if allowed == nil {
+		return nil
+	}
+	return append(allowed, http.MethodOptions)
+}
@@ -0,0 +1,109 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeClickCache struct {
+	cache.LinkCacheInterface
+	clicks int64
+	visits map[string]bool
+}
+
+func (f *fakeClickCache) Get(ctx context.Context, code string) (*cache.CachedLink, error) {
+	return &cache.CachedLink{LongURL: "https://example.com/" + code}, nil
+}
+
+func (f *fakeClickCache) GetMany(ctx context.Context, codes []string) (map[string]*cache.CachedLink, error) {
+	found := make(map[string]*cache.CachedLink)
+	for _, code := range codes {
+		found[code], _ = f.Get(ctx, code)
+	}
+	return found, nil
+}
+
+func (f *fakeClickCache) IncrementClick(ctx context.Context, code string) error {
+	f.clicks++
+	return nil
+}
+
+func (f *fakeClickCache) IncrementDailyClick(ctx context.Context, code string, at time.Time) error {
+	return nil
+}
+
+func (f *fakeClickCache) RecordTopClick(ctx context.Context, code string, ownerID *uuid.UUID, at time.Time) error {
+	return nil
+}
+
+func (f *fakeClickCache) MarkVisit(ctx context.Context, code string, keys []string, window time.Duration) (bool, error) {
+	if f.visits == nil {
+		f.visits = map[string]bool{}
+	}
+	first := true
+	for _, key := range keys {
+		first = first && !f.visits[key]
+		f.visits[key] = true
+	}
+	return first, nil
+}
+
+func newMethodsRouter(clicks *fakeClickCache) *chi.Mux {
+	linkService := service.NewLinkService(nil, clicks, nil, nil)
+	r := chi.NewRouter()
+	r.Use(HeadAndOptions)
+	passthrough := func(next http.Handler) http.Handler { return next }
+	handler := NewHandler(linkService, nil)
+	SetupAPIRoutes(r, handler, nil, passthrough)
+	SetupRedirectRoutes(r, handler, nil)
+	return r
+}
+
+func TestHeadRedirectsWithoutCounting(t *testing.T) {
+	clicks := &fakeClickCache{}
+	r := newMethodsRouter(clicks)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/r/abc", nil))
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "https://example.com/abc", rec.Header().Get("Location"))
+	assert.Zero(t, clicks.clicks)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/r/abc", nil))
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, int64(1), clicks.clicks)
+}
+
+func TestOptionsListsAllowedMethods(t *testing.T) {
+	r := newMethodsRouter(&fakeClickCache{})
+
+	tests := []struct {
+		path   string
+		status int
+		allow  string
+	}{
+		{"/r/abc", http.StatusNoContent, "GET, HEAD, OPTIONS"},
+		{"/v1/links", http.StatusNoContent, "GET, HEAD, POST, OPTIONS"},
+		{"/v1/links/abc", http.StatusNoContent, "GET, HEAD, PATCH, DELETE, OPTIONS"},
+		{"/nope", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodOptions, tt.path, nil))
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.allow, rec.Header().Get("Allow"))
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"url-shortener/pkg/service"
+)
+
+// maxQuickBody bounds a quick create request, which is only a URL
+const maxQuickBody = 8 << 10
+
+// QuickCreate shortens a URL with the caller's default settings and answers
+// with nothing but the short URL, as text, for browser extensions and share
+// sheets. The URL is sent as the plain text body, as {"url": ...} or as a
+// url form field. Errors are plain text too.
+func (h *Handler) QuickCreate(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxQuickBody)
+	longURL, err := quickURL(r)
+	if err != nil || longURL == "" {
+		http.Error(w, "send the URL to shorten as the request body", http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.linkService.CreateLink(r.Context(), &service.CreateLinkRequest{LongURL: longURL})
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrAccountSuspended), errors.Is(err, service.ErrTermsNotAccepted), errors.Is(err, service.ErrQuotaExceeded):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		default:
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusCreated)
+	io.WriteString(w, resp.ShortURL)
+}
+
+func quickURL(r *http.Request) (string, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		var req struct {
+			URL string `json:"url"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(req.URL), nil
+	case "application/x-www-form-urlencoded", "multipart/form-data":
+		return strings.TrimSpace(r.FormValue("url")), nil
+	default:
+		body, err := io.ReadAll(r.Body)
+		return strings.TrimSpace(string(body)), err
+	}
+}
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"url-shortener/pkg/service"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type quickLinks struct {
+	LinkServiceInterface
+	created *service.CreateLinkRequest
+}
+
+func (q *quickLinks) CreateLink(ctx context.Context, req *service.CreateLinkRequest) (*service.CreateLinkResponse, error) {
+	q.created = req
+	if req.LongURL == "javascript:alert(1)" {
+		return nil, errors.New("invalid URL scheme")
+	}
+	return &service.CreateLinkResponse{Code: "abc", ShortURL: "https://sho.rt/r/abc"}, nil
+}
+
+func TestQuickCreate(t *testing.T) {
+	links := &quickLinks{}
+	handler := NewHandler(links, nil)
+
+	for contentType, body := range map[string]string{
+		"text/plain":                        "https://example.com/page\n",
+		"application/json":                  `{"url": "https://example.com/page"}`,
+		"application/x-www-form-urlencoded": "url=https%3A%2F%2Fexample.com%2Fpage",
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/v1/quick", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		rec := httptest.NewRecorder()
+		handler.QuickCreate(rec, req)
+
+		assert.Equal(t, http.StatusCreated, rec.Code, contentType)
+		assert.Equal(t, "https://sho.rt/r/abc", rec.Body.String(), contentType)
+		assert.Equal(t, &service.CreateLinkRequest{LongURL: "https://example.com/page"}, links.created, "only the URL is set, the rest comes from the caller's defaults")
+	}
+
+	rec := httptest.NewRecorder()
+	handler.QuickCreate(rec, httptest.NewRequest(http.MethodPost, "/v1/quick", strings.NewReader("javascript:alert(1)")))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.QuickCreate(rec, httptest.NewRequest(http.MethodPost, "/v1/quick", nil))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
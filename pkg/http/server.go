@@ -0,0 +1,127 @@
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/quic-go/quic-go/http3"
+)
+
+// http2PingTimeout is how long an HTTP/2 connection may go quiet before it is
+// pinged, and then how long the ping may take, so that connections of
+// clients that dropped off a lossy network are closed well before the idle
+// timeout
+const http2PingTimeout = 15 * time.Second
+
+// ServerOptions are the protocols the redirect server speaks. Without a TLS
+// certificate it serves cleartext HTTP/1.1, and HTTP/2 to clients that start
+// with it, such as load balancers.
+type ServerOptions struct {
+	TLSCert string
+	TLSKey  string
+	// HTTP3 also serves HTTP/3 over QUIC, on the same port over UDP, and
+	// advertises it to HTTP/1.1 and HTTP/2 clients with Alt-Svc. It needs
+	// TLS.
+	HTTP3 bool
+	// MaxConcurrentStreams is how many requests a client may have in flight
+	// on one HTTP/2 connection; 0 is Go's default
+	MaxConcurrentStreams int
+	// IdleTimeout closes keep-alive connections idle for this long
+	IdleTimeout time.Duration
+}
+
+// RedirectServer serves the redirect routes over HTTP/1.1 and HTTP/2, and
+// HTTP/3 when enabled
+type RedirectServer struct {
+	http  *http.Server
+	http3 *http3.Server
+	opts  ServerOptions
+}
+
+func NewRedirectServer(addr string, handler http.Handler, opts ServerOptions) (*RedirectServer, error) {
+	if (opts.TLSCert == "") != (opts.TLSKey == "") {
+		return nil, errors.New("a TLS certificate needs its key and the other way round")
+	}
+	if opts.HTTP3 && opts.TLSCert == "" {
+		return nil, errors.New("HTTP/3 needs a TLS certificate")
+	}
+
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	if opts.TLSCert != "" {
+		protocols.SetHTTP2(true)
+	} else {
+		protocols.SetUnencryptedHTTP2(true)
+	}
+	s := &RedirectServer{
+		http: &http.Server{
+			Addr:        addr,
+			Handler:     handler,
+			IdleTimeout: opts.IdleTimeout,
+			Protocols:   protocols,
+			HTTP2: &http.HTTP2Config{
+				MaxConcurrentStreams: opts.MaxConcurrentStreams,
+				SendPingTimeout:      http2PingTimeout,
+				PingTimeout:          http2PingTimeout,
+			},
+		},
+		opts: opts,
+	}
+
+	if opts.HTTP3 {
+		cert, err := tls.LoadX509KeyPair(opts.TLSCert, opts.TLSKey)
+		if err != nil {
+			return nil, err
+		}
+		s.http3 = &http3.Server{
+			Addr:        addr,
+			Handler:     handler,
+			IdleTimeout: opts.IdleTimeout,
+			TLSConfig: http3.ConfigureTLSConfig(&tls.Config{
+				Certificates: []tls.Certificate{cert},
+				MinVersion:   tls.VersionTLS13,
+			}),
+		}
+		s.http.Handler = s.advertiseHTTP3(handler)
+	}
+	return s, nil
+}
+
+// advertiseHTTP3 tells clients on TCP that they can switch to HTTP/3
+func (s *RedirectServer) advertiseHTTP3(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Only fails before the QUIC listener is up
+		s.http3.SetQUICHeaders(w.Header())
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ListenAndServe serves until Shutdown, when it returns http.ErrServerClosed,
+// or until a listener fails
+func (s *RedirectServer) ListenAndServe() error {
+	errs := make(chan error, 2)
+	if s.http3 != nil {
+		go func() { errs <- s.http3.ListenAndServe() }()
+	}
+	go func() {
+		if s.opts.TLSCert != "" {
+			errs <- s.http.ListenAndServeTLS(s.opts.TLSCert, s.opts.TLSKey)
+		} else {
+			errs <- s.http.ListenAndServe()
+		}
+	}()
+	return <-errs
+}
+
+// Shutdown stops taking connections and waits for requests in flight
+func (s *RedirectServer) Shutdown(ctx context.Context) error {
+	var errs []error
+	if s.http3 != nil {
+		errs = append(errs, s.http3.Shutdown(ctx))
+	}
+	errs = append(errs, s.http.Shutdown(ctx))
+	return errors.Join(errs...)
+}
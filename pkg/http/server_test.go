@@ -0,0 +1,90 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 and its key
+func writeTestCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestNewRedirectServerNeedsTLSForHTTP3(t *testing.T) {
+	_, err := NewRedirectServer(":0", http.NotFoundHandler(), ServerOptions{HTTP3: true})
+	assert.Error(t, err)
+	_, err = NewRedirectServer(":0", http.NotFoundHandler(), ServerOptions{TLSCert: "tls.crt"})
+	assert.Error(t, err)
+}
+
+func TestRedirectServerAdvertisesHTTP3(t *testing.T) {
+	certFile, keyFile := writeTestCert(t)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := lis.Addr().String()
+	lis.Close()
+
+	server, err := NewRedirectServer(addr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), ServerOptions{TLSCert: certFile, TLSKey: keyFile, HTTP3: true})
+	require.NoError(t, err)
+	served := make(chan error, 1)
+	go func() { served <- server.ListenAndServe() }()
+	t.Cleanup(func() {
+		server.Shutdown(context.Background())
+		assert.ErrorIs(t, <-served, http.ErrServerClosed)
+	})
+
+	client := &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+		ForceAttemptHTTP2: true,
+	}}
+	// Alt-Svc is only sent once the QUIC listener is up too
+	var resp *http.Response
+	require.Eventually(t, func() bool {
+		if resp, err = client.Get("https://" + addr + "/"); err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.Header.Get("Alt-Svc") != ""
+	}, 5*time.Second, 20*time.Millisecond)
+
+	assert.Equal(t, 2, resp.ProtoMajor)
+	_, port, _ := net.SplitHostPort(addr)
+	assert.Contains(t, resp.Header.Get("Alt-Svc"), `h3=":`+port+`"`)
+}
@@ -0,0 +1,104 @@
+package http
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// defaultRobotsTxt keeps crawlers off short links, which would otherwise be
+// counted as clicks and index destinations under our domain. Bundle pages
+// are meant to be found.
+const defaultRobotsTxt = "User-agent: *\nDisallow: /r/\n"
+
+// securityTxtValidity is how far ahead security.txt's Expires field is set.
+// The file is built from the running config, so it is never stale.
+const securityTxtValidity = 180 * 24 * time.Hour
+
+// SiteFiles are the files browsers and crawlers ask every host for
+type SiteFiles struct {
+	RobotsTxt string
+	// Favicon is answered with 204 when empty
+	Favicon []byte
+	// SecurityContacts are the Contact lines of security.txt (RFC 9116),
+	// such as mailto: or https: URIs; without any there is no security.txt
+	SecurityContacts []string
+	// Sitemaps, when set, serve /sitemap.xml on the domains that have one,
+	// where robots.txt points crawlers to it and lets them follow short links
+	Sitemaps *service.Sitemaps
+}
+
+// LoadSiteFiles reads robots.txt and the favicon from files, using the
+// defaults when a path is empty
+func LoadSiteFiles(robotsFile, faviconFile string, securityContacts []string) (SiteFiles, error) {
+	files := SiteFiles{RobotsTxt: defaultRobotsTxt, SecurityContacts: securityContacts}
+	if robotsFile != "" {
+		robots, err := os.ReadFile(robotsFile)
+		if err != nil {
+			return SiteFiles{}, err
+		}
+		files.RobotsTxt = string(robots)
+	}
+	if faviconFile != "" {
+		favicon, err := os.ReadFile(faviconFile)
+		if err != nil {
+			return SiteFiles{}, err
+		}
+		files.Favicon = favicon
+	}
+	return files, nil
+}
+
+// robotsTxt is the robots.txt of domain. The default one keeps crawlers off
+// short links, except on domains with a sitemap.
+func (f SiteFiles) robotsTxt(domain string) string {
+	if f.Sitemaps == nil || !f.Sitemaps.Has(domain) {
+		return f.RobotsTxt
+	}
+	robots := f.RobotsTxt
+	if robots == defaultRobotsTxt {
+		robots = "User-agent: *\nDisallow:\n"
+	}
+	return strings.TrimRight(robots, "\n") + "\n\nSitemap: " + f.Sitemaps.URL(domain) + "\n"
+}
+
+// SetupSiteFileRoutes answers /robots.txt, /favicon.ico,
+// /.well-known/security.txt and, with sitemaps, /sitemap.xml
+func SetupSiteFileRoutes(r *chi.Mux, files SiteFiles) {
+	r.Get("/robots.txt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		w.Write([]byte(files.robotsTxt(service.DomainFromContext(r.Context()))))
+	})
+	if files.Sitemaps != nil {
+		r.Get("/sitemap.xml", serveSitemap(files.Sitemaps))
+	}
+	r.Get("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		if len(files.Favicon) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", http.DetectContentType(files.Favicon))
+		w.Write(files.Favicon)
+	})
+	if len(files.SecurityContacts) == 0 {
+		return
+	}
+	r.Get("/.well-known/security.txt", func(w http.ResponseWriter, r *http.Request) {
+		var b strings.Builder
+		for _, contact := range files.SecurityContacts {
+			b.WriteString("Contact: " + contact + "\n")
+		}
+		expires := time.Now().UTC().Add(securityTxtValidity).Truncate(24 * time.Hour)
+		b.WriteString("Expires: " + expires.Format(time.RFC3339) + "\n")
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "public, max-age=86400")
+		w.Write([]byte(b.String()))
+	})
+}
@@ -0,0 +1,86 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSiteFiles(t *testing.T) {
+	files, err := LoadSiteFiles("", "", nil)
+	require.NoError(t, err)
+	r := chi.NewRouter()
+	SetupSiteFileRoutes(r, files)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/robots.txt")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Disallow: /r/")
+	assert.Equal(t, http.StatusNoContent, get("/favicon.ico").Code)
+	assert.Equal(t, http.StatusNotFound, get("/.well-known/security.txt").Code)
+
+	r = chi.NewRouter()
+	SetupSiteFileRoutes(r, SiteFiles{
+		Favicon:          []byte("\x89PNG\r\n\x1a\n"),
+		SecurityContacts: []string{"mailto:security@example.com"},
+	})
+	rec = get("/favicon.ico")
+	assert.Equal(t, "image/png", rec.Header().Get("Content-Type"))
+	rec = get("/.well-known/security.txt")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Contact: mailto:security@example.com\n")
+	assert.Contains(t, rec.Body.String(), "Expires: ")
+
+	_, err = LoadSiteFiles("missing/robots.txt", "", nil)
+	assert.Error(t, err)
+}
+
+type fakeSitemapStorage struct {
+	storage.SitemapStorage
+}
+
+func (f *fakeSitemapStorage) ListSitemapLinks(ctx context.Context, domain string, since time.Time) ([]*storage.SitemapLink, error) {
+	return []*storage.SitemapLink{{Code: "launch", UpdatedAt: time.Now(), Listed: true}}, nil
+}
+
+func TestSiteFilesSitemaps(t *testing.T) {
+	files, err := LoadSiteFiles("", "", nil)
+	require.NoError(t, err)
+	files.Sitemaps = service.NewSitemaps(&fakeSitemapStorage{}, []string{"go.example.com"}, time.Hour, logging.NewLogger(logging.LevelError))
+	r := chi.NewRouter()
+	SetupSiteFileRoutes(r, files)
+
+	get := func(domain, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req.WithContext(service.WithDomain(req.Context(), domain)))
+		return rec
+	}
+
+	rec := get("go.example.com", "/robots.txt")
+	assert.Equal(t, "User-agent: *\nDisallow:\n\nSitemap: https://go.example.com/sitemap.xml\n", rec.Body.String())
+	rec = get("go.example.com", "/sitemap.xml")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/xml; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Contains(t, rec.Body.String(), "<loc>https://go.example.com/r/launch</loc>")
+	assert.Equal(t, http.StatusNotFound, get("go.example.com", "/sitemap.xml?page=x").Code)
+
+	// Other domains keep the default robots.txt and have no sitemap
+	assert.Equal(t, defaultRobotsTxt, get("", "/robots.txt").Body.String())
+	assert.Equal(t, http.StatusNotFound, get("", "/sitemap.xml").Code)
+}
@@ -0,0 +1,37 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"url-shortener/pkg/service"
+)
+
+// serveSitemap answers /sitemap.xml with the sitemap of the request's short
+// domain, or with page n of it for ?page=n
+func serveSitemap(sitemaps *service.Sitemaps) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page := 0
+		if v := r.URL.Query().Get("page"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+			page = n
+		}
+		data, err := sitemaps.Sitemap(r.Context(), service.DomainFromContext(r.Context()), page)
+		if errors.Is(err, service.ErrSitemapNotFound) {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		w.Write(data)
+	}
+}
@@ -0,0 +1,244 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/security"
+	"url-shortener/pkg/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	defaultShareTTL = 7 * 24 * time.Hour
+	maxShareTTL     = 30 * 24 * time.Hour
+)
+
+var publicStatsPage = template.Must(template.New("stats").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1.0">
+	<meta name="robots" content="noindex">
+	<title>Stats for {{.ShortURL}}</title>
+	<style>
+		body { font-family: system-ui, sans-serif; max-width: 40rem; margin: 3rem auto; padding: 0 1rem; }
+		.total { font-size: 2.5rem; font-weight: bold; margin: 0; }
+		.chart { display: flex; align-items: flex-end; gap: 2px; height: 8rem; margin-top: 2rem; border-bottom: 1px solid #ccc; }
+		.chart div { flex: 1; background: #4a7bd0; min-height: 1px; }
+	</style>
+</head>
+<body>
+<h1>{{.ShortURL}}</h1>
+<p class="total">{{.TotalClicks}}</p>
+<p>total clicks since {{.CreatedAt.Format "Jan 2, 2006"}}</p>
+<div class="chart">{{range .Bars}}<div style="height: {{.Percent}}%" title="{{.Date}}: {{.Clicks}} clicks"></div>{{end}}</div>
+<p><small>Clicks per day, last {{len .Bars}} days (UTC)</small></p>
+</body>
+</html>`))
+
+type statsBar struct {
+	Date    string
+	Clicks  int64
+	Percent int
+}
+
+// UseShareSigner enables signed share URLs for link stats. Call it before
+// SetupAPIRoutes; without a signer stats are only available to the owner.
+func (h *Handler) UseShareSigner(signer *security.URLSigner) {
+	h.shareSigner = signer
+}
+
+type linkStatsResponse struct {
+	Code     string `json:"code"`
+	ShortURL string `json:"short_url"`
+	*service.LinkStats
+}
+
+// GetStats returns click stats to the owner, or to anyone holding a valid
+// signed share URL
+func (h *Handler) GetStats(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	getLink := h.linkService.GetOwnedLink
+	if security.IsSignedRequest(r.Context()) {
+		// The signed URL names the link's domain, so a Host header picking
+		// another short domain mustn't swap in that domain's link
+		if !strings.EqualFold(r.URL.Query().Get("domain"), service.DomainFromContext(r.Context())) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		getLink = h.linkService.GetSharedLink
+	}
+
+	link, err := getLink(r.Context(), code)
+	if err != nil {
+		if errors.Is(err, service.ErrNotOwner) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+		} else {
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "private, no-store")
+	json.NewEncoder(w).Encode(&linkStatsResponse{
+		Code:      link.Code,
+		ShortURL:  h.linkService.LinkShortURL(link),
+		LinkStats: h.linkService.Stats(link),
+	})
+}
+
+// ShareStats issues a signed, expiring URL for the read-only stats of a link
+// the caller owns
+func (h *Handler) ShareStats(w http.ResponseWriter, r *http.Request) {
+	if h.shareSigner == nil {
+		http.Error(w, "stats sharing is not enabled", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		ExpiresIn string `json:"expires_in"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	ttl := defaultShareTTL
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 || d > maxShareTTL {
+			http.Error(w, "expires_in must be a duration up to 720h", http.StatusBadRequest)
+			return
+		}
+		ttl = d
+	}
+
+	code := chi.URLParam(r, "code")
+	link, err := h.linkService.GetOwnedLink(r.Context(), code)
+	if err != nil {
+		if errors.Is(err, service.ErrNotOwner) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+		} else {
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+		return
+	}
+
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	scheme := "http"
+	if middleware.IsHTTPS(r) {
+		scheme = "https"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"url":        scheme + "://" + r.Host + h.shareSigner.Sign("/v1/links/"+code+"/stats"+linkDomainQuery(link), expiresAt),
+		"expires_at": expiresAt,
+	})
+}
+
+// PublicStats serves /r/{code}/stats for links with public_stats enabled, as
+// HTML or, with ?format=json or an Accept: application/json header, as JSON
+func (h *Handler) PublicStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.resolver.GetPublicStats(r.Context(), chi.URLParam(r, "code"))
+	if err != nil {
+		if errors.Is(err, service.ErrLinkNotFound) {
+			http.Error(w, "not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Header().Set("Vary", "Accept")
+	if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats)
+		return
+	}
+
+	var peak int64
+	for _, day := range stats.Daily {
+		peak = max(peak, day.Clicks)
+	}
+	bars := make([]statsBar, len(stats.Daily))
+	for i, day := range stats.Daily {
+		bars[i] = statsBar{Date: day.Date, Clicks: day.Clicks}
+		if peak > 0 {
+			bars[i].Percent = int(day.Clicks * 100 / peak)
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	publicStatsPage.Execute(w, struct {
+		*service.PublicStats
+		Bars []statsBar
+	}{stats, bars})
+}
+
+// jsonpCallback matches the callback names Widget accepts: a JavaScript
+// identifier or dotted path to one, such as counters.update
+var jsonpCallback = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+// maxCallbackLength bounds JSONP callback names
+const maxCallbackLength = 64
+
+type widgetResponse struct {
+	Code        string `json:"code"`
+	ShortURL    string `json:"short_url"`
+	TotalClicks int    `json:"total_clicks"`
+}
+
+// Widget serves a link's click count for embedding on other sites, to links
+// with public_stats enabled. Any origin may read it, and with ?callback= it
+// is served as JSONP for pages that can't use CORS.
+func (h *Handler) Widget(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	callback := r.URL.Query().Get("callback")
+	if callback != "" && (len(callback) > maxCallbackLength || !jsonpCallback.MatchString(callback)) {
+		http.Error(w, "callback must be a JavaScript identifier", http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.resolver.GetPublicStats(r.Context(), chi.URLParam(r, "code"))
+	if err != nil {
+		if errors.Is(err, service.ErrLinkNotFound) {
+			http.Error(w, "not found", http.StatusNotFound)
+		} else {
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	body, err := json.Marshal(&widgetResponse{
+		Code:        stats.Code,
+		ShortURL:    stats.ShortURL,
+		TotalClicks: stats.TotalClicks,
+	})
+	if err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if callback == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+		return
+	}
+	// The leading comment keeps the response from being read as anything
+	// but script
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	io.WriteString(w, "/**/"+callback+"(")
+	w.Write(body)
+	io.WriteString(w, ");")
+}
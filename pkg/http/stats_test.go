@@ -0,0 +1,148 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/security"
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeStatsLinks struct {
+	storage.LinkStorage
+	links map[string]*storage.Link
+}
+
+func (f *fakeStatsLinks) GetByCode(ctx context.Context, code string) (*storage.Link, error) {
+	return f.links[code], nil
+}
+
+type fakeStatsCache struct {
+	cache.LinkCacheInterface
+}
+
+func (f *fakeStatsCache) GetDailyClicks(ctx context.Context, codes []string, day time.Time) (map[string]int64, error) {
+	return map[string]int64{codes[0]: 3}, nil
+}
+
+func TestPublicStats(t *testing.T) {
+	owner := uuid.New()
+	links := &fakeStatsLinks{links: map[string]*storage.Link{
+		"public":  {Code: "public", LongURL: "https://example.com/secret", OwnerID: &owner, PublicStats: true, ClickCount: 90},
+		"private": {Code: "private", LongURL: "https://example.com", OwnerID: &owner},
+		"noindex": {Code: "noindex", LongURL: "https://example.com", OwnerID: &owner, PublicStats: true, NoIndex: true},
+	}}
+	linkService := service.NewLinkService(links, &fakeStatsCache{}, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/r/{code}/stats", NewHandler(linkService, nil).PublicStats)
+
+	tests := []struct {
+		name        string
+		target      string
+		status      int
+		contentType string
+		contains    string
+	}{
+		{"html", "/r/public/stats", http.StatusOK, "text/html; charset=utf-8", "90"},
+		{"json", "/r/public/stats?format=json", http.StatusOK, "application/json", `"total_clicks":90`},
+		{"not public", "/r/private/stats", http.StatusNotFound, "", ""},
+		{"noindex", "/r/noindex/stats", http.StatusNotFound, "", ""},
+		{"unknown", "/r/missing/stats", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			assert.Equal(t, tt.status, rec.Code)
+			if tt.status == http.StatusOK {
+				assert.Equal(t, tt.contentType, rec.Header().Get("Content-Type"))
+				assert.Contains(t, rec.Body.String(), tt.contains)
+				assert.NotContains(t, rec.Body.String(), "example.com/secret")
+			}
+		})
+	}
+}
+
+func TestSharedStatsAreScopedToSignedDomain(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+	domain := "go.example.com"
+	links := &fakeStatsLinks{links: map[string]*storage.Link{
+		"abc":                {Code: "abc", LongURL: "https://example.com", OwnerID: &owner, ClickCount: 1},
+		"go.example.com/abc": {Code: "abc", Domain: &domain, LongURL: "https://example.com", OwnerID: &other, ClickCount: 2},
+	}}
+	linkService := service.NewLinkService(links, &fakeStatsCache{}, nil, nil)
+	settings := service.DefaultSettings()
+	settings.ShortDomains = []string{domain}
+	linkService.ApplySettings(settings)
+	handler := NewHandler(linkService, nil)
+	signer := security.NewURLSigner([]byte("secret"))
+	r := chi.NewRouter()
+	r.Use(handler.LinkDomain)
+	r.With(security.SignedURLMiddleware(signer, nil)).Get("/v1/links/{code}/stats", handler.GetStats)
+
+	get := func(host, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req.Host = host
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	expires := time.Now().Add(time.Hour)
+	shared := signer.Sign("/v1/links/abc/stats", expires)
+	rec := get("api.example.com", shared)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"total_clicks":1`)
+	assert.Equal(t, http.StatusForbidden, get("api.example.com", shared+"&domain="+domain).Code, "unsigned domain")
+	assert.Equal(t, http.StatusForbidden, get(domain, shared).Code, "short domain host")
+
+	rec = get("api.example.com", signer.Sign("/v1/links/abc/stats?domain="+domain, expires))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"total_clicks":2`)
+}
+
+func TestWidget(t *testing.T) {
+	owner := uuid.New()
+	links := &fakeStatsLinks{links: map[string]*storage.Link{
+		"public":  {Code: "public", LongURL: "https://example.com/secret", OwnerID: &owner, PublicStats: true, ClickCount: 90},
+		"private": {Code: "private", LongURL: "https://example.com", OwnerID: &owner},
+	}}
+	linkService := service.NewLinkService(links, &fakeStatsCache{}, nil, nil)
+	r := chi.NewRouter()
+	r.Get("/v1/links/{code}/widget", NewHandler(linkService, nil).Widget)
+
+	tests := []struct {
+		name        string
+		target      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"json", "/v1/links/public/widget", http.StatusOK, "application/json", `{"code":"public","short_url":"http://localhost:8081/r/public","total_clicks":90}`},
+		{"jsonp", "/v1/links/public/widget?callback=counters.update", http.StatusOK, "text/javascript; charset=utf-8", `/**/counters.update({"code":"public","short_url":"http://localhost:8081/r/public","total_clicks":90});`},
+		{"bad callback", "/v1/links/public/widget?callback=alert(1)", http.StatusBadRequest, "", ""},
+		{"not public", "/v1/links/private/widget", http.StatusNotFound, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+			if tt.status == http.StatusOK {
+				assert.Equal(t, tt.contentType, rec.Header().Get("Content-Type"))
+				assert.Equal(t, "public, max-age=60", rec.Header().Get("Cache-Control"))
+				assert.Equal(t, tt.body, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/service"
+)
+
+// TopLinksResponse is a click leaderboard
+type TopLinksResponse struct {
+	Period string          `json:"period"`
+	Links  []cache.TopLink `json:"links"`
+}
+
+// TopLinks ranks the caller's links by clicks over ?period= (24h, 7d or
+// 30d; default 24h), returning up to ?limit= (default 10, at most 100)
+func (h *Handler) TopLinks(w http.ResponseWriter, r *http.Request) {
+	writeTopLinks(w, r, h.linkService, false)
+}
+
+// TopLinks is the leaderboard across all owners
+func (h *AdminHandler) TopLinks(w http.ResponseWriter, r *http.Request) {
+	writeTopLinks(w, r, h.linkService, true)
+}
+
+// errTopLimit is returned by parseTopLinks for an out of range ?limit=
+var errTopLimit = errors.New("limit must be between 1 and 100")
+
+// parseTopLinks reads ?period= and ?limit= for a leaderboard request
+func parseTopLinks(r *http.Request) (period string, limit int, err error) {
+	period = r.URL.Query().Get("period")
+	if period == "" {
+		period = "24h"
+	}
+	limit = 10
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 100 {
+			return "", 0, errTopLimit
+		}
+		limit = n
+	}
+	return period, limit, nil
+}
+
+func writeTopLinks(w http.ResponseWriter, r *http.Request, linkService LinkServiceInterface, global bool) {
+	period, limit, err := parseTopLinks(r)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	top, err := linkService.TopLinks(r.Context(), period, limit, global)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPeriod) {
+			writeErrorResponse(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+			return
+		}
+		writeErrorResponse(w, http.StatusServiceUnavailable, ErrorResponse{Error: "leaderboard unavailable"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&TopLinksResponse{Period: period, Links: top})
+}
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type leaderboardCache struct {
+	cache.LinkCacheInterface
+	ownerID *uuid.UUID
+	period  string
+	limit   int
+}
+
+func (l *leaderboardCache) TopLinks(ctx context.Context, ownerID *uuid.UUID, period string, now time.Time, limit int) ([]cache.TopLink, error) {
+	l.ownerID, l.period, l.limit = ownerID, period, limit
+	return []cache.TopLink{{Code: "abc", Clicks: 42}}, nil
+}
+
+func TestTopLinks(t *testing.T) {
+	board := &leaderboardCache{}
+	linkService := service.NewLinkService(nil, board, nil, nil)
+	ownerID := uuid.New()
+
+	get := func(h http.HandlerFunc, target string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		req = req.WithContext(middleware.WithOwnerID(req.Context(), ownerID))
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec
+	}
+
+	rec := get(NewHandler(linkService, nil).TopLinks, "/v1/links/top")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"period":"24h","links":[{"code":"abc","clicks":42}]}`, rec.Body.String())
+	assert.Equal(t, &ownerID, board.ownerID)
+	assert.Equal(t, 10, board.limit)
+
+	rec = get(NewAdminHandler(nil, nil, linkService).TopLinks, "/admin/links/top?period=7d&limit=3")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Nil(t, board.ownerID)
+	assert.Equal(t, "7d", board.period)
+	assert.Equal(t, 3, board.limit)
+
+	assert.Equal(t, http.StatusBadRequest, get(NewHandler(linkService, nil).TopLinks, "/v1/links/top?period=1y").Code)
+	assert.Equal(t, http.StatusBadRequest, get(NewHandler(linkService, nil).TopLinks, "/v1/links/top?limit=0").Code)
+}
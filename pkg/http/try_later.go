@@ -0,0 +1,44 @@
+package http
+
+import (
+	"html/template"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+var tryLaterPage = template.Must(template.New("try-later").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1.0">
+	<meta name="robots" content="noindex">
+	<title>Please try again later</title>
+</head>
+<body>
+<h2>This link is busy</h2>
+<p>It has had more visits than its owner allows for now. Please try again {{if .Minutes}}in about {{.Minutes}} minute{{if gt .Minutes 1}}s{{end}}{{else}}in a few seconds{{end}}.</p>
+</body>
+</html>`))
+
+// renderTryLater answers a redirect over its link's rate limit: 429 with a
+// page asking the visitor to come back once the window ends in retryAfter
+func (h *Handler) renderTryLater(w http.ResponseWriter, retryAfter time.Duration) {
+	seconds := setRetryAfter(w, retryAfter)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusTooManyRequests)
+	tryLaterPage.Execute(w, map[string]any{"Minutes": seconds / 60})
+}
+
+// setRetryAfter sets Retry-After to retryAfter, rounded up to at least a
+// second, and returns the seconds set
+func setRetryAfter(w http.ResponseWriter, retryAfter time.Duration) int {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	return seconds
+}
@@ -0,0 +1,284 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// v1Deprecated is when the /v1 routes with a /v2 successor were deprecated
+var v1Deprecated = time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+
+// Envelope is the body of every /v2 response. Data is null when the request
+// failed, and Errors is only set then.
+type Envelope struct {
+	Data   any            `json:"data"`
+	Meta   map[string]any `json:"meta,omitempty"`
+	Errors []APIError     `json:"errors,omitempty"`
+}
+
+// APIError is one problem with a /v2 request. Code is stable for clients to
+// match on; Field names the offending request field, if any.
+type APIError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Field   string `json:"field,omitempty"`
+}
+
+func writeEnvelope(w http.ResponseWriter, status int, env *Envelope) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(env)
+}
+
+func writeData(w http.ResponseWriter, status int, data any, meta map[string]any) {
+	writeEnvelope(w, status, &Envelope{Data: data, Meta: meta})
+}
+
+func writeErrors(w http.ResponseWriter, status int, errs ...APIError) {
+	writeEnvelope(w, status, &Envelope{Errors: errs})
+}
+
+// writeV2Error maps service errors to status codes. Errors it doesn't know
+// are reported with fallback: 400 where they come from checking the
+// request, 500 otherwise.
+func writeV2Error(w http.ResponseWriter, err error, fallback int) {
+	var fieldErrs validation.Errors
+	switch {
+	case errors.As(err, &fieldErrs):
+		errs := make([]APIError, len(fieldErrs))
+		for i, fe := range fieldErrs {
+			errs[i] = APIError{Code: "invalid_field", Message: fe.Message, Field: fe.Field}
+		}
+		writeErrors(w, http.StatusBadRequest, errs...)
+	case errors.Is(err, service.ErrLinkNotFound):
+		writeErrors(w, http.StatusNotFound, APIError{Code: "not_found", Message: "link not found"})
+	case errors.Is(err, service.ErrCampaignNotFound):
+		writeErrors(w, http.StatusNotFound, APIError{Code: "not_found", Message: err.Error(), Field: "campaign_id"})
+	case errors.Is(err, service.ErrNotOwner):
+		writeErrors(w, http.StatusForbidden, APIError{Code: "forbidden", Message: "not the owner of this link"})
+	case errors.Is(err, service.ErrLinkExpired):
+		writeErrors(w, http.StatusGone, APIError{Code: "expired", Message: "link expired"})
+	case errors.Is(err, service.ErrLinkDisabled):
+		writeErrors(w, http.StatusGone, APIError{Code: "disabled", Message: "link disabled"})
+	case errors.Is(err, service.ErrTermsNotAccepted):
+		writeErrors(w, http.StatusForbidden, APIError{Code: termsNotAcceptedCode, Message: err.Error()})
+	case errors.Is(err, service.ErrQuotaExceeded):
+		writeErrors(w, http.StatusForbidden, APIError{Code: quotaExceededCode, Message: err.Error()})
+	case errors.Is(err, service.ErrAccountSuspended):
+		writeErrors(w, http.StatusForbidden, APIError{Code: "account_suspended", Message: "account suspended"})
+	case errors.Is(err, service.ErrPasswordRequired):
+		writeErrors(w, http.StatusUnauthorized, APIError{Code: "password_required", Message: "password required"})
+	case errors.Is(err, service.ErrRequesterDenied):
+		writeErrors(w, http.StatusForbidden, APIError{Code: "forbidden", Message: "requester not allowed"})
+	case errors.Is(err, service.ErrCodeExists):
+		writeErrors(w, http.StatusConflict, APIError{Code: "conflict", Message: err.Error(), Field: "alias"})
+	case fallback == http.StatusBadRequest:
+		writeErrors(w, http.StatusBadRequest, APIError{Code: "invalid_request", Message: err.Error()})
+	default:
+		writeErrors(w, http.StatusInternalServerError, APIError{Code: "internal_error", Message: "internal server error"})
+	}
+}
+
+func writeInvalidBody(w http.ResponseWriter) {
+	writeErrors(w, http.StatusBadRequest, APIError{Code: "invalid_request", Message: "request body must be a JSON object"})
+}
+
+// CreateLinkV2 creates a link, answering 201 with its location
+func (h *Handler) CreateLinkV2(w http.ResponseWriter, r *http.Request) {
+	var req service.CreateLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidBody(w)
+		return
+	}
+
+	resp, err := h.linkService.CreateLink(r.Context(), &req)
+	if err != nil {
+		var taken *service.AliasTakenError
+		if errors.As(err, &taken) {
+			writeEnvelope(w, http.StatusConflict, &Envelope{
+				Meta:   map[string]any{"suggestions": taken.Suggestions},
+				Errors: []APIError{{Code: aliasTakenCode, Message: "alias already taken", Field: "alias"}},
+			})
+			return
+		}
+		writeV2Error(w, err, http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Location", "/v2/links/"+url.PathEscape(resp.Code)+domainQuery(resp.Domain))
+	writeData(w, http.StatusCreated, resp, nil)
+}
+
+// GetLinkV2 returns one of the caller's links; ?fields= and ?expand= work as
+// on /v1
+func (h *Handler) GetLinkV2(w http.ResponseWriter, r *http.Request) {
+	sel, err := parseSelection(r, storage.Link{}, "stats", "tags", "creation")
+	if err != nil {
+		writeV2Error(w, err, http.StatusBadRequest)
+		return
+	}
+
+	link, err := h.linkService.GetOwnedLink(r.Context(), chi.URLParam(r, "code"))
+	if err != nil {
+		writeV2Error(w, err, http.StatusInternalServerError)
+		return
+	}
+	expanded, err := h.expandLink(r.Context(), sel, link)
+	if err != nil {
+		writeV2Error(w, err, http.StatusInternalServerError)
+		return
+	}
+	data, err := sel.render(link, expanded)
+	if err != nil {
+		writeV2Error(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeData(w, http.StatusOK, data, nil)
+}
+
+// UpdateLinkV2 applies the changes and returns the updated link
+func (h *Handler) UpdateLinkV2(w http.ResponseWriter, r *http.Request) {
+	var req service.UpdateLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidBody(w)
+		return
+	}
+
+	code := chi.URLParam(r, "code")
+	if err := h.linkService.UpdateLink(r.Context(), code, &req); err != nil {
+		writeV2Error(w, err, http.StatusBadRequest)
+		return
+	}
+	link, err := h.linkService.GetOwnedLink(r.Context(), code)
+	if err != nil {
+		writeV2Error(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeData(w, http.StatusOK, link, nil)
+}
+
+func (h *Handler) DeleteLinkV2(w http.ResponseWriter, r *http.Request) {
+	if err := h.linkService.DeleteLink(r.Context(), chi.URLParam(r, "code")); err != nil {
+		writeV2Error(w, err, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TopLinksV2 is the caller's leaderboard, with the period and limit in meta
+func (h *Handler) TopLinksV2(w http.ResponseWriter, r *http.Request) {
+	period, limit, err := parseTopLinks(r)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, APIError{Code: "invalid_field", Message: err.Error(), Field: "limit"})
+		return
+	}
+
+	top, err := h.linkService.TopLinks(r.Context(), period, limit, false)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidPeriod) {
+			writeErrors(w, http.StatusBadRequest, APIError{Code: "invalid_field", Message: err.Error(), Field: "period"})
+			return
+		}
+		writeErrors(w, http.StatusServiceUnavailable, APIError{Code: "unavailable", Message: "leaderboard unavailable"})
+		return
+	}
+	writeData(w, http.StatusOK, top, map[string]any{"period": period, "limit": limit})
+}
+
+// ResolveV2 works like Resolve
+func (h *Handler) ResolveV2(w http.ResponseWriter, r *http.Request) {
+	sel, err := parseSelection(r, ResolveResponse{})
+	if err != nil {
+		writeV2Error(w, err, http.StatusBadRequest)
+		return
+	}
+
+	resp, err := h.resolve(w, r)
+	var overLimit *overRateLimitError
+	if errors.As(err, &overLimit) {
+		setRetryAfter(w, overLimit.retryAfter)
+		writeErrors(w, http.StatusTooManyRequests, APIError{Code: "rate_limited", Message: overLimit.Error()})
+		return
+	}
+	if err != nil {
+		writeV2Error(w, err, http.StatusInternalServerError)
+		return
+	}
+	data, err := sel.render(resp, nil)
+	if err != nil {
+		writeV2Error(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeData(w, http.StatusOK, data, nil)
+}
+
+// ResolveManyV2 works like ResolveMany; data is the list of results
+func (h *Handler) ResolveManyV2(w http.ResponseWriter, r *http.Request) {
+	var req BulkResolveRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeInvalidBody(w)
+		return
+	}
+	if len(req.Codes) == 0 || len(req.Codes) > maxBulkResolve {
+		writeErrors(w, http.StatusBadRequest, APIError{
+			Code:    "invalid_field",
+			Message: "must list between 1 and " + strconv.Itoa(maxBulkResolve) + " codes",
+			Field:   "codes",
+		})
+		return
+	}
+
+	results, err := h.resolveMany(r.Context(), req.Codes, clientIP(r))
+	if err != nil {
+		writeV2Error(w, err, http.StatusInternalServerError)
+		return
+	}
+	writeData(w, http.StatusOK, results, nil)
+}
+
+// deprecatedV1 marks a /v1 response as deprecated (RFC 9745) and links to
+// its /v2 successor
+func deprecatedV1(next http.Handler) http.Handler {
+	deprecation := "@" + strconv.FormatInt(v1Deprecated.Unix(), 10)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", deprecation)
+		w.Header().Add("Link", "</v2"+strings.TrimPrefix(r.URL.Path, "/v1")+`>; rel="successor-version"`)
+		next.ServeHTTP(w, r)
+	})
+}
+
+func setupV2Routes(r *chi.Mux, handler *Handler, oauthMiddleware *middleware.OAuthMiddleware, csrfMiddleware func(http.Handler) http.Handler) {
+	r.With(csrfMiddleware).Route("/v2", func(r chi.Router) {
+		r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+			writeErrors(w, http.StatusNotFound, APIError{Code: "not_found", Message: "no such endpoint"})
+		})
+		if oauthMiddleware != nil {
+			r.With(oauthMiddleware.Authenticate("links:write")).Post("/links", handler.CreateLinkV2)
+			r.With(oauthMiddleware.Authenticate("links:read")).Get("/links/top", handler.TopLinksV2)
+			r.With(oauthMiddleware.Authenticate("links:read")).Get("/links/{code}", handler.GetLinkV2)
+			r.With(oauthMiddleware.Authenticate("links:write")).Patch("/links/{code}", handler.UpdateLinkV2)
+			r.With(oauthMiddleware.Authenticate("links:write")).Delete("/links/{code}", handler.DeleteLinkV2)
+		} else {
+			r.Post("/links", handler.CreateLinkV2)
+			r.Get("/links/top", handler.TopLinksV2)
+			r.Get("/links/{code}", handler.GetLinkV2)
+			r.Patch("/links/{code}", handler.UpdateLinkV2)
+			r.Delete("/links/{code}", handler.DeleteLinkV2)
+		}
+		r.Get("/resolve/{code}", handler.ResolveV2)
+	})
+
+	// Read-only, so exempt from CSRF like /v1/resolve
+	r.Post("/v2/resolve", handler.ResolveManyV2)
+}
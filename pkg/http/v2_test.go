@@ -0,0 +1,109 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ownedLinks struct {
+	taggedLinks
+	owner uuid.UUID
+}
+
+func (o ownedLinks) GetByCode(ctx context.Context, code string) (*storage.Link, error) {
+	if code != "abc" {
+		return nil, nil
+	}
+	return &storage.Link{Code: code, LongURL: "https://example.com/abc", OwnerID: &o.owner, ClickCount: 3}, nil
+}
+
+func newV2Router(linkService *service.LinkService) *chi.Mux {
+	r := chi.NewRouter()
+	noCSRF := func(next http.Handler) http.Handler { return next }
+	SetupAPIRoutes(r, NewHandler(linkService, nil), nil, noCSRF)
+	return r
+}
+
+func decodeEnvelope(t *testing.T, rec *httptest.ResponseRecorder) (Envelope, map[string]any) {
+	t.Helper()
+	assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var env Envelope
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&env))
+	data, _ := env.Data.(map[string]any)
+	return env, data
+}
+
+func TestV2Envelope(t *testing.T) {
+	owner := uuid.New()
+	r := newV2Router(service.NewLinkService(ownedLinks{owner: owner}, &protectedCache{}, nil, nil))
+	as := func(req *http.Request, owner uuid.UUID) *http.Request {
+		return req.WithContext(middleware.WithOwnerID(req.Context(), owner))
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, as(httptest.NewRequest(http.MethodGet, "/v2/links/abc?expand=stats,tags", nil), owner))
+	require.Equal(t, http.StatusOK, rec.Code)
+	env, data := decodeEnvelope(t, rec)
+	assert.Empty(t, env.Errors)
+	assert.Equal(t, "https://example.com/abc", data["long_url"])
+	assert.Equal(t, []any{"launch"}, data["tags"])
+	assert.Equal(t, 3.0, data["stats"].(map[string]any)["total_clicks"])
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, as(httptest.NewRequest(http.MethodGet, "/v2/links/abc", nil), uuid.New()))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	env, _ = decodeEnvelope(t, rec)
+	assert.Nil(t, env.Data)
+	require.Len(t, env.Errors, 1)
+	assert.Equal(t, "forbidden", env.Errors[0].Code)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, as(httptest.NewRequest(http.MethodGet, "/v2/links/nope", nil), owner))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	env, _ = decodeEnvelope(t, rec)
+	assert.Equal(t, "not_found", env.Errors[0].Code)
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v2/resolve", strings.NewReader(`{"codes": []}`)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	env, _ = decodeEnvelope(t, rec)
+	assert.Equal(t, APIError{Code: "invalid_field", Message: "must list between 1 and 100 codes", Field: "codes"}, env.Errors[0])
+}
+
+func TestV2Resolve(t *testing.T) {
+	r := newV2Router(service.NewLinkService(nil, &fakeClickCache{}, nil, nil))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v2/resolve/abc?count=false&fields=long_url", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Deprecation"))
+	assert.JSONEq(t, `{"data": {"long_url": "https://example.com/abc"}}`, rec.Body.String())
+}
+
+func TestV1DeprecationHeaders(t *testing.T) {
+	r := newV2Router(service.NewLinkService(nil, &fakeClickCache{}, nil, nil))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/resolve/abc?count=false", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "@1792108800", rec.Header().Get("Deprecation"))
+	assert.Equal(t, `</v2/resolve/abc>; rel="successor-version"`, rec.Header().Get("Link"))
+
+	// Routes without a successor aren't deprecated
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/openapi.json", nil))
+	assert.Empty(t, rec.Header().Get("Deprecation"))
+}
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"context"
+	"html/template"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+
+	"url-shortener/pkg/reputation"
+	"url-shortener/pkg/storage"
+)
+
+const (
+	// warningPassParam carries the signed pass past a warning page
+	warningPassParam = "continue"
+	// warningPassTTL is how long after seeing a warning its continue link
+	// works
+	warningPassTTL = 10 * time.Minute
+)
+
+// DestinationChecker decides which destinations get a warning page first;
+// *reputation.Checker implements it
+type DestinationChecker interface {
+	Check(ctx context.Context, host string) *reputation.Warning
+}
+
+var warningPage = template.Must(template.New("warning").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+	<meta charset="UTF-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1.0">
+	<meta name="robots" content="noindex">
+	<meta name="referrer" content="no-referrer">
+	<title>You are leaving {{.Host}}</title>
+</head>
+<body>
+<h2>You are leaving {{.Host}}</h2>
+<p>This link goes to <strong>{{.Destination}}</strong>.</p>
+<p>{{if .Days}}{{.Domain}} was registered {{if eq .Days 1}}yesterday{{else}}{{.Days}} days ago{{end}}. New domains are often used for phishing.{{else}}{{.Domain}} is under a top-level domain often used for phishing.{{end}}
+Only continue if you trust whoever sent you the link, and don't enter passwords or payment details on a page you didn't expect.</p>
+<p><a href="{{.Continue}}" rel="noreferrer">Continue to {{.Domain}}</a></p>
+</body>
+</html>`))
+
+// UseDestinationWarnings shows a warning page in place of redirects to
+// destinations checker doesn't trust. Visitors continue through a link back
+// to the redirect, signed for their address and the link.
+func (h *Handler) UseDestinationWarnings(checker DestinationChecker) {
+	h.destinations = checker
+}
+
+// destinationWarning returns why r should be warned before being sent to
+// dest, or nil if it needn't be or already was
+func (h *Handler) destinationWarning(r *http.Request, link *storage.Link, dest string) *reputation.Warning {
+	if h.destinations == nil {
+		return nil
+	}
+	if pass := r.URL.Query().Get(warningPassParam); pass != "" && h.access.ValidWarningPass(pass, link.Key(), clientIP(r).String(), time.Now()) {
+		return nil
+	}
+	u, err := url.Parse(dest)
+	if err != nil {
+		return nil
+	}
+	return h.destinations.Check(r.Context(), u.Hostname())
+}
+
+func (h *Handler) renderWarning(w http.ResponseWriter, r *http.Request, link *storage.Link, dest string, warning *reputation.Warning) {
+	continueURL := *r.URL
+	query := continueURL.Query()
+	query.Set(warningPassParam, h.access.IssueWarningPass(link.Key(), clientIP(r).String(), time.Now().Add(warningPassTTL)))
+	continueURL.RawQuery = query.Encode()
+
+	days := 0
+	if warning.RegisteredAt != nil {
+		days = int(math.Max(1, math.Floor(time.Since(*warning.RegisteredAt).Hours()/24)))
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	warningPage.Execute(w, map[string]any{
+		"Host":        r.Host,
+		"Destination": dest,
+		"Domain":      warning.Domain,
+		"Days":        days,
+		"Continue":    continueURL.RequestURI(),
+	})
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"context"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"url-shortener/pkg/reputation"
+	"url-shortener/pkg/service"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDestinations distrusts example.com
+type fakeDestinations struct{}
+
+func (fakeDestinations) Check(ctx context.Context, host string) *reputation.Warning {
+	if host != "example.com" {
+		return nil
+	}
+	return &reputation.Warning{Domain: "example.com", Reason: reputation.ReasonRiskyTLD}
+}
+
+func TestRedirectWarnsBeforeUntrustedDestinations(t *testing.T) {
+	clicks := &fakeClickCache{}
+	handler := NewHandler(service.NewLinkService(nil, clicks, nil, nil), nil)
+	handler.UseDestinationWarnings(fakeDestinations{})
+	r := chi.NewRouter()
+	r.Get("/r/{code}", handler.Redirect)
+	r.Head("/r/{code}", handler.Redirect)
+
+	do := func(method, target, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodGet, "/r/abc?utm_source=mail", "198.51.100.1:1234")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "no-store", rec.Header().Get("Cache-Control"))
+	assert.Contains(t, rec.Body.String(), "https://example.com/abc")
+	assert.Zero(t, clicks.clicks, "showing the warning isn't a click")
+
+	// Link checkers aren't warned
+	assert.Equal(t, http.StatusFound, do(http.MethodHead, "/r/abc", "198.51.100.1:1234").Code)
+
+	match := regexp.MustCompile(`href="([^"]+)"`).FindStringSubmatch(rec.Body.String())
+	require.Len(t, match, 2)
+	continueURL, err := url.Parse(html.UnescapeString(match[1]))
+	require.NoError(t, err)
+	assert.Equal(t, "mail", continueURL.Query().Get("utm_source"))
+	target := continueURL.String()
+
+	rec = do(http.MethodGet, target, "198.51.100.1:1234")
+	assert.Equal(t, http.StatusFound, rec.Code)
+	assert.Equal(t, "https://example.com/abc", rec.Header().Get("Location"))
+	assert.Equal(t, int64(1), clicks.clicks)
+
+	// The pass only works for the address it was shown to
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, target, "198.51.100.2:1234").Code)
+}
@@ -0,0 +1,254 @@
+// Package email turns emails into short links: staff send URLs to a
+// configured address, and get the short URLs back in a reply. Mail arrives
+// through an inbound parse webhook (SendGrid Inbound Parse, or Amazon SES
+// through SNS). Only senders mapped to an owner, whose mail passed SPF or
+// DKIM for their own domain, are answered; everything else is dropped
+// without a reply, so forged mail can't make the shortener send mail.
+package email
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/validation"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	maxBodySize = 10 << 20
+	// maxLinks bounds the links created from one email
+	maxLinks = 10
+	// replyTemplate is the notify template replies are rendered with
+	replyTemplate = "email_links"
+)
+
+// Links is the part of the link service emails use
+type Links interface {
+	CreateLink(ctx context.Context, req *service.CreateLinkRequest) (*service.CreateLinkResponse, error)
+}
+
+// Sender delivers a rendered template; *notify.Mailer implements it
+type Sender interface {
+	Send(ctx context.Context, to, template string, data any) error
+}
+
+// Config sets up the bridge
+type Config struct {
+	// Address is where links are emailed to; mail to other addresses is
+	// ignored
+	Address string
+	// Token must be sent as ?token= by the webhook, since inbound parse
+	// requests aren't signed
+	Token string
+	// Senders maps sender addresses, or @domain for everyone at a domain, to
+	// the owner whose links they create
+	Senders map[string]uuid.UUID
+}
+
+// Inbound is an email as delivered by a provider
+type Inbound struct {
+	// From is the address in the From header
+	From string
+	// Recipients are the envelope recipients
+	Recipients []string
+	Subject    string
+	Text       string
+	// Authenticated is whether the provider verified that the mail comes
+	// from From's domain
+	Authenticated bool
+	// AutoSubmitted marks out-of-office replies and other automatic mail,
+	// which is never answered
+	AutoSubmitted bool
+}
+
+// Link is one URL found in an email, for the reply
+type Link struct {
+	LongURL  string
+	ShortURL string
+	Error    string
+}
+
+// Reply is the data for the email_links template
+type Reply struct {
+	Subject string
+	Links   []Link
+}
+
+type Handler struct {
+	links  Links
+	sender Sender
+	config Config
+	logger *logging.Logger
+	client *http.Client
+}
+
+func NewHandler(links Links, sender Sender, config Config, logger *logging.Logger) *Handler {
+	return &Handler{
+		links:  links,
+		sender: sender,
+		config: config,
+		logger: logger,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Routes returns a router to be mounted at /integrations/email, with a
+// /sendgrid URL for SendGrid Inbound Parse and a /ses URL for an SNS topic
+// that SES receipt rules publish to
+func (h *Handler) Routes() http.Handler {
+	r := chi.NewRouter()
+	r.Use(h.verify)
+	r.Post("/sendgrid", h.SendGrid)
+	r.Post("/ses", h.SES)
+	return r
+}
+
+// verify refuses requests without the configured token
+func (h *Handler) verify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.URL.Query().Get("token")
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(h.config.Token)) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Handle shortens the URLs in msg for its sender's owner and replies with
+// them. Mail that isn't for the bridge or not from a verified sender is
+// dropped.
+func (h *Handler) Handle(ctx context.Context, msg *Inbound) {
+	if !h.addressedToBridge(msg.Recipients) {
+		return
+	}
+	sender := strings.ToLower(msg.From)
+	if msg.AutoSubmitted || sender == strings.ToLower(h.config.Address) {
+		return
+	}
+	ownerID, ok := h.owner(sender)
+	if !ok || !msg.Authenticated {
+		h.logger.Warn(ctx, "dropped email from unverified sender", "from", sender, "authenticated", msg.Authenticated)
+		return
+	}
+
+	ctx = middleware.WithOwnerID(ctx, ownerID)
+	reply := &Reply{Subject: replySubject(msg.Subject)}
+	for _, longURL := range FindURLs(msg.Subject + "\n" + msg.Text) {
+		link := Link{LongURL: longURL}
+		resp, err := h.links.CreateLink(ctx, &service.CreateLinkRequest{LongURL: longURL})
+		if err != nil {
+			link.Error = errorText(err)
+		} else {
+			link.ShortURL = resp.ShortURL
+		}
+		reply.Links = append(reply.Links, link)
+	}
+	if err := h.sender.Send(ctx, sender, replyTemplate, reply); err != nil {
+		h.logger.Error(ctx, "failed to reply to email", "to", sender, "error", err)
+		return
+	}
+	h.logger.Info(ctx, "shortened links by email", "owner_id", ownerID, "count", len(reply.Links))
+}
+
+func (h *Handler) addressedToBridge(recipients []string) bool {
+	for _, recipient := range recipients {
+		if strings.EqualFold(address(recipient), h.config.Address) {
+			return true
+		}
+	}
+	return false
+}
+
+// owner returns the owner sender acts for, matching the address before its
+// domain
+func (h *Handler) owner(sender string) (uuid.UUID, bool) {
+	if id, ok := h.config.Senders[sender]; ok {
+		return id, true
+	}
+	_, domain, ok := strings.Cut(sender, "@")
+	if !ok {
+		return uuid.Nil, false
+	}
+	id, ok := h.config.Senders["@"+domain]
+	return id, ok
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"'\[\]]+`)
+
+// FindURLs returns the distinct http(s) URLs in text, up to maxLinks, in
+// order. Quoted lines of earlier messages are skipped, and punctuation
+// ending a sentence isn't taken as part of a URL.
+func FindURLs(text string) []string {
+	var urls []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), ">") {
+			continue
+		}
+		for _, match := range urlPattern.FindAllString(line, -1) {
+			match = strings.TrimRight(match, ".,;:!?)")
+			if seen[match] {
+				continue
+			}
+			seen[match] = true
+			urls = append(urls, match)
+			if len(urls) == maxLinks {
+				return urls
+			}
+		}
+	}
+	return urls
+}
+
+// address returns the bare, lower case address of an RFC 5322 address such
+// as "Alice <alice@example.com>"
+func address(s string) string {
+	if addr, err := mail.ParseAddress(s); err == nil {
+		return strings.ToLower(addr.Address)
+	}
+	return strings.ToLower(strings.Trim(strings.TrimSpace(s), "<>"))
+}
+
+// domain returns the domain of address
+func domain(address string) string {
+	_, d, _ := strings.Cut(address, "@")
+	return strings.ToLower(d)
+}
+
+// replySubject keeps the reply in the sender's thread. Line breaks would
+// end the Subject header early, so they are removed.
+func replySubject(subject string) string {
+	subject = strings.Join(strings.Fields(subject), " ")
+	if subject == "" {
+		return "Your short links"
+	}
+	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+		subject = "Re: " + subject
+	}
+	return subject
+}
+
+func errorText(err error) string {
+	var verrs validation.Errors
+	if !errors.As(err, &verrs) {
+		return err.Error()
+	}
+	msgs := make([]string, len(verrs))
+	for i, fe := range verrs {
+		msgs[i] = fe.Field + " " + fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
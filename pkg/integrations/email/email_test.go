@@ -0,0 +1,178 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/service"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLinks struct {
+	created []string
+	owner   uuid.UUID
+}
+
+func (f *fakeLinks) CreateLink(ctx context.Context, req *service.CreateLinkRequest) (*service.CreateLinkResponse, error) {
+	f.owner = middleware.GetOwnerIDFromContext(ctx)
+	if strings.Contains(req.LongURL, "blocked") {
+		return nil, errors.New("destination blocked")
+	}
+	f.created = append(f.created, req.LongURL)
+	return &service.CreateLinkResponse{ShortURL: "https://sho.rt/r/" + string(rune('a'+len(f.created)-1))}, nil
+}
+
+type sentMail struct {
+	to    string
+	reply *Reply
+}
+
+type fakeSender struct {
+	sent []sentMail
+}
+
+func (f *fakeSender) Send(ctx context.Context, to, template string, data any) error {
+	f.sent = append(f.sent, sentMail{to: to, reply: data.(*Reply)})
+	return nil
+}
+
+var owner = uuid.MustParse("5f8b7d1c-6c1e-4a8e-9f3a-2b1d0c9e8f7a")
+
+func newTestHandler() (*Handler, *fakeLinks, *fakeSender) {
+	links, sender := &fakeLinks{}, &fakeSender{}
+	h := NewHandler(links, sender, Config{
+		Address: "links@sho.rt",
+		Token:   "secret",
+		Senders: map[string]uuid.UUID{"@example.com": owner},
+	}, logging.NewLogger(logging.LevelError))
+	return h, links, sender
+}
+
+func TestFindURLs(t *testing.T) {
+	text := `Please shorten https://example.com/launch?utm=1, and (https://example.com/docs).
+Again: https://example.com/launch?utm=1
+> https://example.com/quoted
+ftp://example.com/file`
+	assert.Equal(t, []string{"https://example.com/launch?utm=1", "https://example.com/docs"}, FindURLs(text))
+}
+
+func TestHandle(t *testing.T) {
+	h, links, sender := newTestHandler()
+	msg := &Inbound{
+		From:          "alice@example.com",
+		Recipients:    []string{"Links <links@sho.rt>"},
+		Subject:       "Newsletter\r\nBcc: victim@example.net",
+		Text:          "https://example.com/a\nhttps://example.com/blocked",
+		Authenticated: true,
+	}
+	h.Handle(context.Background(), msg)
+
+	assert.Equal(t, owner, links.owner)
+	require.Len(t, sender.sent, 1)
+	assert.Equal(t, "alice@example.com", sender.sent[0].to)
+	reply := sender.sent[0].reply
+	assert.Equal(t, "Re: Newsletter Bcc: victim@example.net", reply.Subject)
+	assert.Equal(t, []Link{
+		{LongURL: "https://example.com/a", ShortURL: "https://sho.rt/r/a"},
+		{LongURL: "https://example.com/blocked", Error: "destination blocked"},
+	}, reply.Links)
+
+	// Unverified, unknown, automatic and misaddressed mail gets no reply
+	for _, drop := range []Inbound{
+		{From: "alice@example.com", Recipients: []string{"links@sho.rt"}},
+		{From: "mallory@example.net", Recipients: []string{"links@sho.rt"}, Authenticated: true},
+		{From: "alice@example.com", Recipients: []string{"links@sho.rt"}, Authenticated: true, AutoSubmitted: true},
+		{From: "alice@example.com", Recipients: []string{"support@sho.rt"}, Authenticated: true},
+	} {
+		h.Handle(context.Background(), &drop)
+	}
+	assert.Len(t, sender.sent, 1)
+}
+
+func TestSendGrid(t *testing.T) {
+	h, links, sender := newTestHandler()
+
+	post := func(token string, fields map[string]string) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		for name, value := range fields {
+			mw.WriteField(name, value)
+		}
+		mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/sendgrid?token="+token, &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		rec := httptest.NewRecorder()
+		h.Routes().ServeHTTP(rec, req)
+		return rec
+	}
+	fields := map[string]string{
+		"from":     "Alice <alice@example.com>",
+		"envelope": `{"to": ["links@sho.rt"], "from": "bounces@mailer.example.org"}`,
+		"subject":  "Shorten",
+		"text":     "https://example.com/a",
+		"dkim":     "{@example.com : pass}",
+		"SPF":      "softfail",
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, post("wrong", fields).Code)
+	assert.Empty(t, sender.sent)
+
+	assert.Equal(t, http.StatusOK, post("secret", fields).Code)
+	assert.Equal(t, []string{"https://example.com/a"}, links.created)
+	require.Len(t, sender.sent, 1)
+
+	// SPF only counts for the sender's own domain
+	fields["dkim"] = "{@mailer.example.org : pass}"
+	fields["SPF"] = "pass"
+	assert.Equal(t, http.StatusOK, post("secret", fields).Code)
+	assert.Len(t, sender.sent, 1)
+}
+
+func TestSES(t *testing.T) {
+	h, links, sender := newTestHandler()
+
+	raw := "From: Alice <alice@example.com>\r\nTo: links@sho.rt\r\nSubject: Shorten\r\n" +
+		"Content-Type: multipart/alternative; boundary=b\r\n\r\n" +
+		"--b\r\nContent-Type: text/plain\r\nContent-Transfer-Encoding: quoted-printable\r\n\r\n" +
+		"https://example.com/a-very-long-path-that-quoted-printable-breaks-over-sever=\r\nal-lines\r\n" +
+		"--b\r\nContent-Type: text/html\r\n\r\n<a href=\"https://example.com/html\">link</a>\r\n--b--\r\n"
+	notification, _ := json.Marshal(map[string]any{
+		"notificationType": "Received",
+		"mail": map[string]any{
+			"source":        "alice@example.com",
+			"commonHeaders": map[string]any{"from": []string{"Alice <alice@example.com>"}, "subject": "Shorten"},
+		},
+		"receipt": map[string]any{
+			"recipients":   []string{"links@sho.rt"},
+			"spfVerdict":   map[string]string{"status": "PASS"},
+			"dmarcVerdict": map[string]string{"status": "FAIL"},
+		},
+		"content": base64.StdEncoding.EncodeToString([]byte(raw)),
+	})
+	body, _ := json.Marshal(map[string]string{"Type": "Notification", "Message": string(notification)})
+
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ses?token=secret", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"https://example.com/a-very-long-path-that-quoted-printable-breaks-over-several-lines"}, links.created)
+	assert.Len(t, sender.sent, 1)
+
+	// Only AWS subscriptions are confirmed
+	body, _ = json.Marshal(map[string]string{"Type": "SubscriptionConfirmation", "SubscribeURL": "https://attacker.example.com/confirm"})
+	rec = httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ses?token=secret", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,265 @@
+package email
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"strings"
+)
+
+// SendGrid receives mail from SendGrid Inbound Parse, either parsed into
+// fields or, with "POST the raw, full MIME message" on, as the raw message
+func (h *Handler) SendGrid(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(maxBodySize); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	var envelope struct {
+		To   []string `json:"to"`
+		From string   `json:"from"`
+	}
+	if err := json.Unmarshal([]byte(r.FormValue("envelope")), &envelope); err != nil {
+		http.Error(w, "invalid envelope", http.StatusBadRequest)
+		return
+	}
+
+	msg := &Inbound{
+		From:       address(r.FormValue("from")),
+		Recipients: envelope.To,
+		Subject:    r.FormValue("subject"),
+		Text:       r.FormValue("text"),
+	}
+	headers := r.FormValue("headers")
+	if raw := r.FormValue("email"); raw != "" {
+		parsed, err := mail.ReadMessage(strings.NewReader(raw))
+		if err != nil {
+			http.Error(w, "invalid email", http.StatusBadRequest)
+			return
+		}
+		msg.From = address(parsed.Header.Get("From"))
+		msg.Subject = decodeHeader(parsed.Header.Get("Subject"))
+		msg.AutoSubmitted = autoSubmitted(parsed.Header)
+		if msg.Text, err = plainText(parsed.Header, parsed.Body); err != nil {
+			http.Error(w, "invalid email", http.StatusBadRequest)
+			return
+		}
+	} else if parsed, err := mail.ReadMessage(strings.NewReader(headers + "\r\n\r\n")); err == nil {
+		msg.AutoSubmitted = autoSubmitted(parsed.Header)
+	}
+
+	// dkim looks like {@example.com : pass, @sendgrid.net : pass}
+	dkim := strings.ReplaceAll(r.FormValue("dkim"), " ", "")
+	msg.Authenticated = strings.Contains(dkim, "@"+domain(msg.From)+":pass") ||
+		(r.FormValue("SPF") == "pass" && domain(address(envelope.From)) == domain(msg.From))
+
+	h.Handle(r.Context(), msg)
+	w.WriteHeader(http.StatusOK)
+}
+
+// snsMessage is an SNS HTTP delivery
+type snsMessage struct {
+	Type         string `json:"Type"`
+	Message      string `json:"Message"`
+	SubscribeURL string `json:"SubscribeURL"`
+}
+
+// sesNotification is what an SES receipt rule's SNS action publishes
+type sesNotification struct {
+	NotificationType string `json:"notificationType"`
+	Mail             struct {
+		Source  string `json:"source"`
+		Headers []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"headers"`
+		CommonHeaders struct {
+			From    []string `json:"from"`
+			Subject string   `json:"subject"`
+		} `json:"commonHeaders"`
+	} `json:"mail"`
+	Receipt struct {
+		Recipients   []string   `json:"recipients"`
+		SPFVerdict   sesVerdict `json:"spfVerdict"`
+		DMARCVerdict sesVerdict `json:"dmarcVerdict"`
+	} `json:"receipt"`
+	Content string `json:"content"`
+}
+
+type sesVerdict struct {
+	Status string `json:"status"`
+}
+
+// SES receives mail from an SNS topic that an SES receipt rule publishes
+// to, and confirms the topic's subscription
+func (h *Handler) SES(w http.ResponseWriter, r *http.Request) {
+	var sns snsMessage
+	if err := json.NewDecoder(r.Body).Decode(&sns); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	switch sns.Type {
+	case "SubscriptionConfirmation":
+		if err := h.confirmSubscription(r, sns.SubscribeURL); err != nil {
+			h.logger.Error(r.Context(), "failed to confirm SNS subscription", "error", err)
+			http.Error(w, "invalid subscription", http.StatusBadRequest)
+			return
+		}
+	case "Notification":
+		var n sesNotification
+		if err := json.Unmarshal([]byte(sns.Message), &n); err != nil {
+			http.Error(w, "invalid notification", http.StatusBadRequest)
+			return
+		}
+		if n.NotificationType != "Received" {
+			break
+		}
+		msg, err := sesInbound(&n)
+		if err != nil {
+			http.Error(w, "invalid email", http.StatusBadRequest)
+			return
+		}
+		h.Handle(r.Context(), msg)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// confirmSubscription visits the SubscribeURL, which must be an AWS one
+func (h *Handler) confirmSubscription(r *http.Request, subscribeURL string) error {
+	u, err := url.Parse(subscribeURL)
+	if err != nil || u.Scheme != "https" || !strings.HasSuffix(u.Hostname(), ".amazonaws.com") {
+		return errors.New("SubscribeURL is not an AWS URL")
+	}
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("SNS answered " + resp.Status)
+	}
+	return nil
+}
+
+func sesInbound(n *sesNotification) (*Inbound, error) {
+	msg := &Inbound{
+		Recipients: n.Receipt.Recipients,
+		Subject:    n.Mail.CommonHeaders.Subject,
+	}
+	if len(n.Mail.CommonHeaders.From) > 0 {
+		msg.From = address(n.Mail.CommonHeaders.From[0])
+	}
+	for _, header := range n.Mail.Headers {
+		if strings.EqualFold(header.Name, "Auto-Submitted") && !strings.EqualFold(header.Value, "no") {
+			msg.AutoSubmitted = true
+		}
+	}
+	// DMARC passing means SPF or DKIM passed for the From domain
+	msg.Authenticated = n.Receipt.DMARCVerdict.Status == "PASS" ||
+		(n.Receipt.SPFVerdict.Status == "PASS" && domain(address(n.Mail.Source)) == domain(msg.From))
+
+	// The content is only included by SNS actions, base64 encoded if the
+	// action says so
+	if n.Content == "" {
+		return msg, nil
+	}
+	raw := n.Content
+	if decoded, err := base64.StdEncoding.DecodeString(raw); err == nil {
+		raw = string(decoded)
+	}
+	parsed, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	if msg.Text, err = plainText(parsed.Header, parsed.Body); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// plainText returns the text/plain part of a message, or its HTML if it has
+// no plain text
+func plainText(header mail.Header, body io.Reader) (string, error) {
+	text, html, err := textParts(header.Get("Content-Type"), header.Get("Content-Transfer-Encoding"), body)
+	if err != nil {
+		return "", err
+	}
+	if text == "" {
+		return html, nil
+	}
+	return text, nil
+}
+
+func textParts(contentType, encoding string, body io.Reader) (text, html string, err error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return text, html, nil
+			}
+			if err != nil {
+				return "", "", err
+			}
+			// NextPart already decodes quoted-printable parts
+			partText, partHTML, err := textParts(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return "", "", err
+			}
+			if text == "" {
+				text = partText
+			}
+			if html == "" {
+				html = partHTML
+			}
+		}
+	}
+	if mediaType != "text/plain" && mediaType != "text/html" {
+		return "", "", nil
+	}
+
+	switch strings.ToLower(encoding) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", "", err
+	}
+	if mediaType == "text/html" {
+		return "", string(data), nil
+	}
+	return string(data), "", nil
+}
+
+// autoSubmitted reports whether the mail was sent automatically (RFC 3834)
+func autoSubmitted(header mail.Header) bool {
+	value := header.Get("Auto-Submitted")
+	return value != "" && !strings.EqualFold(value, "no")
+}
+
+// decodeHeader decodes RFC 2047 encoded words such as =?UTF-8?Q?...?=
+func decodeHeader(s string) string {
+	decoded, err := new(mime.WordDecoder).DecodeHeader(s)
+	if err != nil {
+		return s
+	}
+	return decoded
+}
@@ -0,0 +1,70 @@
+package slack
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/validation"
+)
+
+const commandUsage = "Usage: `/shorten <url> [alias]`"
+
+// commandResponse is a slash command reply; ephemeral ones are only shown
+// to the user who ran the command
+type commandResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+// Command shortens the URL in a slash command, such as
+// /shorten https://example.com/launch launch, for the workspace's owner
+func (h *Handler) Command(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	ownerID, ok := h.config.TeamOwners[r.PostForm.Get("team_id")]
+	if !ok {
+		reply(w, "This workspace isn't connected to the link shortener.")
+		return
+	}
+
+	args := strings.Fields(r.PostForm.Get("text"))
+	if len(args) == 0 || len(args) > 2 || args[0] == "help" {
+		reply(w, commandUsage)
+		return
+	}
+	req := &service.CreateLinkRequest{LongURL: strings.Trim(args[0], "<>")}
+	if len(args) == 2 {
+		req.Alias = &args[1]
+	}
+
+	ctx := middleware.WithOwnerID(r.Context(), ownerID)
+	resp, err := h.links.CreateLink(ctx, req)
+	if err != nil {
+		reply(w, "Couldn't shorten that: "+errorText(err))
+		return
+	}
+	reply(w, "Shortened to "+resp.ShortURL)
+}
+
+func reply(w http.ResponseWriter, text string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(&commandResponse{ResponseType: "ephemeral", Text: text})
+}
+
+func errorText(err error) string {
+	var verrs validation.Errors
+	if !errors.As(err, &verrs) {
+		return err.Error()
+	}
+	msgs := make([]string, len(verrs))
+	for i, fe := range verrs {
+		msgs[i] = fe.Field + " " + fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,123 @@
+// Package slack connects the shortener to Slack workspaces: a slash command
+// that shortens links, and unfurls that show where a pasted short link goes
+// and how often it was clicked. Requests from Slack are verified with the
+// app's signing secret, and each workspace acts on behalf of the owner it is
+// mapped to.
+package slack
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/storage"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// maxRequestAge is how old a request's timestamp may be, which stops
+	// captured requests from being replayed later
+	maxRequestAge = 5 * time.Minute
+	maxBodySize   = 1 << 20
+	apiURL        = "https://slack.com/api/"
+)
+
+// Links is the part of the link service Slack requests use
+type Links interface {
+	CreateLink(ctx context.Context, req *service.CreateLinkRequest) (*service.CreateLinkResponse, error)
+	GetLink(ctx context.Context, code string) (*storage.Link, error)
+	IsExpired(link *storage.Link) bool
+	LinkShortURL(link *storage.Link) string
+	ShortURL(code string) string
+	ShortDomainFor(host string) string
+}
+
+// Config sets up a Slack app
+type Config struct {
+	// SigningSecret verifies that requests come from Slack
+	SigningSecret string
+	// BotToken calls chat.unfurl; unfurls are off without it
+	BotToken string
+	// TeamOwners maps workspace (team) IDs to the owner whose links they
+	// create and see stats of; other workspaces are refused
+	TeamOwners map[string]uuid.UUID
+}
+
+type Handler struct {
+	links  Links
+	config Config
+	logger *logging.Logger
+	client *http.Client
+	apiURL string
+	now    func() time.Time
+}
+
+func NewHandler(links Links, config Config, logger *logging.Logger) *Handler {
+	return &Handler{
+		links:  links,
+		config: config,
+		logger: logger,
+		client: &http.Client{Timeout: 10 * time.Second},
+		apiURL: apiURL,
+		now:    time.Now,
+	}
+}
+
+// Routes returns a router to be mounted at /integrations/slack, whose
+// /commands and /events URLs are set as the app's slash command and event
+// subscription request URLs
+func (h *Handler) Routes() http.Handler {
+	r := chi.NewRouter()
+	r.Use(h.verify)
+	r.Post("/commands", h.Command)
+	r.Post("/events", h.Event)
+	return r
+}
+
+// verify refuses requests without a valid, recent Slack signature, and
+// leaves the body in place for the handler
+func (h *Handler) verify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+		if err != nil || len(body) > maxBodySize {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+		if !h.validSignature(timestamp, r.Header.Get("X-Slack-Signature"), body) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handler) validSignature(timestamp, signature string, body []byte) bool {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := h.now().Sub(time.Unix(ts, 0)); age > maxRequestAge || age < -maxRequestAge {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(Sign(h.config.SigningSecret, timestamp, body)))
+}
+
+// Sign returns the X-Slack-Signature of body sent at timestamp
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
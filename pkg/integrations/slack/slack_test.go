@@ -0,0 +1,156 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLinks struct {
+	Links
+	created *service.CreateLinkRequest
+	owner   uuid.UUID
+	links   map[string]*storage.Link
+}
+
+func (f *fakeLinks) CreateLink(ctx context.Context, req *service.CreateLinkRequest) (*service.CreateLinkResponse, error) {
+	f.created = req
+	f.owner = middleware.GetOwnerIDFromContext(ctx)
+	return &service.CreateLinkResponse{Code: "abc", ShortURL: "https://sho.rt/r/abc"}, nil
+}
+
+func (f *fakeLinks) GetLink(ctx context.Context, code string) (*storage.Link, error) {
+	if link, ok := f.links[code]; ok {
+		return link, nil
+	}
+	return nil, service.ErrLinkNotFound
+}
+
+func (f *fakeLinks) IsExpired(link *storage.Link) bool      { return false }
+func (f *fakeLinks) LinkShortURL(link *storage.Link) string { return f.ShortURL(link.Code) }
+func (f *fakeLinks) ShortURL(code string) string            { return "https://sho.rt/r/" + code }
+func (f *fakeLinks) ShortDomainFor(host string) string      { return "" }
+
+const secret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+func signed(path, contentType, body string) *http.Request {
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("X-Slack-Request-Timestamp", ts)
+	req.Header.Set("X-Slack-Signature", Sign(secret, ts, []byte(body)))
+	return req
+}
+
+func TestVerify(t *testing.T) {
+	h := NewHandler(&fakeLinks{}, Config{SigningSecret: secret}, logging.NewLogger(logging.LevelError))
+	body := `{"type":"url_verification","challenge":"3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P"}`
+
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, signed("/events", "application/json", body))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "3eZbrw1aBm2rZgRNFdxV2595E9CY3gmdALWMmHkvFXO7tYXAYM8P", rec.Body.String())
+
+	tampered := signed("/events", "application/json", body)
+	tampered.Body = io.NopCloser(strings.NewReader(strings.Replace(body, "3eZ", "xxx", 1)))
+	rec = httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, tampered)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	// A request captured earlier can't be replayed
+	h.now = func() time.Time { return time.Now().Add(10 * time.Minute) }
+	rec = httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, signed("/events", "application/json", body))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestCommand(t *testing.T) {
+	owner := uuid.New()
+	links := &fakeLinks{}
+	h := NewHandler(links, Config{SigningSecret: secret, TeamOwners: map[string]uuid.UUID{"T1": owner}}, logging.NewLogger(logging.LevelError))
+
+	form := url.Values{"team_id": {"T1"}, "command": {"/shorten"}, "text": {"<https://example.com/launch> launch"}}
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, signed("/commands", "application/x-www-form-urlencoded", form.Encode()))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var resp commandResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "ephemeral", resp.ResponseType)
+	assert.Contains(t, resp.Text, "https://sho.rt/r/abc")
+	require.NotNil(t, links.created)
+	assert.Equal(t, "https://example.com/launch", links.created.LongURL)
+	assert.Equal(t, "launch", *links.created.Alias)
+	assert.Equal(t, owner, links.owner)
+
+	// Workspaces that aren't mapped to an owner create nothing
+	links.created = nil
+	form.Set("team_id", "T2")
+	rec = httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, signed("/commands", "application/x-www-form-urlencoded", form.Encode()))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Nil(t, links.created)
+}
+
+func TestUnfurl(t *testing.T) {
+	owner := uuid.New()
+	hidden := "hash"
+	links := &fakeLinks{links: map[string]*storage.Link{
+		"abc":    {Code: "abc", LongURL: "https://example.com/launch", ClickCount: 42, OwnerID: &owner},
+		"secret": {Code: "secret", LongURL: "https://example.com/private", ClickCount: 7, PasswordHash: &hidden},
+	}}
+	posted := make(chan map[string]any, 1)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat.unfurl", r.URL.Path)
+		assert.Equal(t, "Bearer xoxb-token", r.Header.Get("Authorization"))
+		var body map[string]any
+		json.NewDecoder(r.Body).Decode(&body)
+		posted <- body
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer api.Close()
+
+	h := NewHandler(links, Config{SigningSecret: secret, BotToken: "xoxb-token", TeamOwners: map[string]uuid.UUID{"T1": owner}}, logging.NewLogger(logging.LevelError))
+	h.apiURL = api.URL + "/"
+
+	body := `{"type":"event_callback","team_id":"T1","event":{"type":"link_shared","channel":"C1","message_ts":"1.2",` +
+		`"links":[{"url":"https://sho.rt/r/abc"},{"url":"https://sho.rt/r/secret"},{"url":"https://example.com/x"}]}}`
+	rec := httptest.NewRecorder()
+	h.Routes().ServeHTTP(rec, signed("/events", "application/json", body))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var unfurl map[string]any
+	select {
+	case unfurl = <-posted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no unfurl posted")
+	}
+	assert.Equal(t, "C1", unfurl["channel"])
+	unfurls := unfurl["unfurls"].(map[string]any)
+	require.Len(t, unfurls, 2)
+
+	text := func(u string) string {
+		data, _ := json.Marshal(unfurls[u])
+		return string(data)
+	}
+	assert.Contains(t, text("https://sho.rt/r/abc"), "https://example.com/launch")
+	assert.Contains(t, text("https://sho.rt/r/abc"), "42")
+	assert.NotContains(t, text("https://sho.rt/r/secret"), "https://example.com/private")
+	assert.NotContains(t, text("https://sho.rt/r/secret"), "*Clicks:*", "stats of other owners' links aren't shown")
+}
@@ -0,0 +1,193 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+)
+
+// unfurlTimeout bounds looking up the links of one message and posting
+// their unfurls, which happens after Slack has been answered
+const unfurlTimeout = 10 * time.Second
+
+// mrkdwn escapes text for Slack messages, where <...> would be a link
+var mrkdwn = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// eventEnvelope is an Events API request; see
+// https://api.slack.com/apis/events-api
+type eventEnvelope struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	TeamID    string `json:"team_id"`
+	Event     struct {
+		Type      string `json:"type"`
+		Channel   string `json:"channel"`
+		MessageTS string `json:"message_ts"`
+		Links     []struct {
+			URL string `json:"url"`
+		} `json:"links"`
+	} `json:"event"`
+}
+
+// Event answers the Events API: it confirms the request URL when Slack
+// checks it, and unfurls the short links in link_shared events
+func (h *Handler) Event(w http.ResponseWriter, r *http.Request) {
+	var env eventEnvelope
+	if err := json.NewDecoder(r.Body).Decode(&env); err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	switch env.Type {
+	case "url_verification":
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(env.Challenge))
+		return
+	case "event_callback":
+	default:
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// Slack retries events it didn't get an answer to within 3 seconds,
+	// which are already being unfurled by the first delivery
+	ownerID, known := h.config.TeamOwners[env.TeamID]
+	if env.Event.Type != "link_shared" || !known || h.config.BotToken == "" || r.Header.Get("X-Slack-Retry-Num") != "" {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	urls := make([]string, 0, len(env.Event.Links))
+	for _, link := range env.Event.Links {
+		urls = append(urls, link.URL)
+	}
+	w.WriteHeader(http.StatusOK)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), unfurlTimeout)
+		defer cancel()
+		if err := h.unfurl(ctx, ownerID, env.Event.Channel, env.Event.MessageTS, urls); err != nil {
+			h.logger.Warn(ctx, "failed to unfurl short links", "team_id", env.TeamID, "error", err)
+		}
+	}()
+}
+
+// unfurl posts a preview of each short link in urls to the message
+func (h *Handler) unfurl(ctx context.Context, ownerID uuid.UUID, channel, ts string, urls []string) error {
+	unfurls := make(map[string]any)
+	for _, raw := range urls {
+		domain, code, ok := h.parseShortURL(raw)
+		if !ok {
+			continue
+		}
+		link, err := h.links.GetLink(service.WithDomain(ctx, domain), code)
+		if err != nil {
+			continue
+		}
+		unfurls[raw] = map[string]any{"blocks": h.blocks(link, ownerID)}
+	}
+	if len(unfurls) == 0 {
+		return nil
+	}
+	return h.call(ctx, "chat.unfurl", map[string]any{
+		"channel": channel,
+		"ts":      ts,
+		"unfurls": unfurls,
+	})
+}
+
+// parseShortURL returns the domain and code of a short link, on the default
+// base URL or one of the custom domains
+func (h *Handler) parseShortURL(raw string) (domain, code string, ok bool) {
+	if base := h.links.ShortURL(""); strings.HasPrefix(raw, base) {
+		code = raw[len(base):]
+	} else {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return "", "", false
+		}
+		if domain = h.links.ShortDomainFor(u.Host); domain == "" {
+			return "", "", false
+		}
+		code, ok = strings.CutPrefix(u.EscapedPath(), "/r/")
+		if !ok {
+			return "", "", false
+		}
+	}
+	code, _, _ = strings.Cut(code, "?")
+	code, err := url.PathUnescape(code)
+	if err != nil || code == "" || strings.Contains(code, "/") {
+		return "", "", false
+	}
+	return domain, code, true
+}
+
+// blocks describes link in Block Kit. Destinations behind a password aren't
+// shown, and clicks only to the link's owner or when its stats are public.
+func (h *Handler) blocks(link *storage.Link, ownerID uuid.UUID) []any {
+	title := link.Code
+	if link.Description != nil && *link.Description != "" {
+		title = *link.Description
+	}
+	title = strings.ReplaceAll(mrkdwn.Replace(title), "|", "/")
+	var fields []string
+	if link.PasswordHash != nil {
+		fields = append(fields, "*Destination:* hidden, password protected")
+	} else {
+		fields = append(fields, "*Destination:* "+mrkdwn.Replace(link.LongURL))
+	}
+	if (link.OwnerID != nil && *link.OwnerID == ownerID) || (link.PublicStats && !link.NoIndex) {
+		fields = append(fields, fmt.Sprintf("*Clicks:* %d", link.ClickCount))
+	}
+	switch {
+	case link.Disabled:
+		fields = append(fields, "_This link is disabled._")
+	case h.links.IsExpired(link):
+		fields = append(fields, "_This link has expired._")
+	}
+	return []any{
+		map[string]any{
+			"type": "section",
+			"text": map[string]any{"type": "mrkdwn", "text": "*<" + h.links.LinkShortURL(link) + "|" + title + ">*\n" + strings.Join(fields, "\n")},
+		},
+	}
+}
+
+// call posts body to a Web API method with the bot token
+func (h *Handler) call(ctx context.Context, method string, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.apiURL+method, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+h.config.BotToken)
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("%s: %s", method, resp.Status)
+	}
+	if !result.OK {
+		return errors.New(method + ": " + result.Error)
+	}
+	return nil
+}
@@ -0,0 +1,135 @@
+package triggers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"url-shortener/pkg/storage"
+)
+
+// PublishEvent sends link_created items from the outbox to their owner's
+// hooks. It is called by the outbox relay, which retries the whole event if
+// any hook fails, so delivery is at least once.
+func (s *Service) PublishEvent(ctx context.Context, event *storage.OutboxEvent) error {
+	if event.OwnerID == nil || event.Type != "link.created" {
+		return nil
+	}
+	hooks, err := s.store.ListHooks(ctx, LinkCreated, event.OwnerID)
+	if err != nil || len(hooks) == 0 {
+		return err
+	}
+	var link storage.Link
+	if err := json.Unmarshal(event.Payload, &link); err != nil {
+		return err
+	}
+	item := s.item(LinkCreated, &link, 0)
+
+	var errs []error
+	for _, hook := range hooks {
+		if err := s.deliver(ctx, hook, item); err != nil && !errors.Is(err, errHookUnsubscribe) {
+			errs = append(errs, fmt.Errorf("hook %s: %w", hook.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run sends link_clicks items every interval until ctx is done
+func (s *Service) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if sent, err := s.ScanOnce(ctx); err != nil {
+			s.logger.Error(ctx, "trigger scan failed", "error", err)
+		} else if sent > 0 {
+			s.logger.Info(ctx, "click triggers sent", "count", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ScanOnce sends a link_clicks item for each link that reached the
+// threshold of a hook since the last scan, and returns how many were sent.
+// Click counts are the stored ones, so they lag behind redirects by the
+// click sync interval.
+func (s *Service) ScanOnce(ctx context.Context) (int, error) {
+	hooks, err := s.store.ListHooks(ctx, LinkClicks, nil)
+	if err != nil {
+		return 0, err
+	}
+	sent := 0
+	for _, hook := range hooks {
+		links, err := s.store.FindThresholdLinks(ctx, hook, scanBatchSize)
+		if err != nil {
+			return sent, err
+		}
+		for _, link := range links {
+			// Claim first so that concurrent API replicas don't both send
+			claimed, err := s.store.ClaimFiring(ctx, hook.ID, link.Key())
+			if err != nil {
+				return sent, err
+			}
+			if !claimed {
+				continue
+			}
+			err = s.deliver(ctx, hook, s.item(LinkClicks, link, hook.Threshold))
+			if err == nil {
+				sent++
+				continue
+			}
+			if errors.Is(err, errHookUnsubscribe) {
+				break
+			}
+			s.logger.Warn(ctx, "click trigger delivery failed", "hook_id", hook.ID, "code", link.Code, "error", err)
+			// Let the next scan retry
+			if err := s.store.ReleaseFiring(ctx, hook.ID, link.Key()); err != nil {
+				s.logger.Warn(ctx, "failed to release click trigger", "hook_id", hook.ID, "code", link.Code, "error", err)
+			}
+			break
+		}
+	}
+	return sent, nil
+}
+
+// deliver POSTs item to hook. A target answering 410 Gone has been removed
+// on the platform's side, so the hook is deleted and errHookUnsubscribe
+// returned.
+func (s *Service) deliver(ctx context.Context, hook *storage.IntegrationHook, item *Item) error {
+	body, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.TargetURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Hook-ID", hook.ID.String())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusGone:
+		if err := s.store.DeleteHook(ctx, hook.ID); err != nil {
+			return err
+		}
+		s.logger.Info(ctx, "hook target gone, unsubscribed", "hook_id", hook.ID)
+		return errHookUnsubscribe
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("hook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+// Package triggers lets automation platforms such as Zapier start workflows
+// from link events. Each trigger can be polled, returning its most recent
+// items, or subscribed to with a REST hook that is POSTed each new item:
+//
+//   - link_created: a link was created
+//   - link_clicks: a link reached a number of clicks
+//
+// Both return items of the same schema, Item, whose id platforms
+// deduplicate on. Its fields are always present, so workflows can map them
+// before the first real event.
+package triggers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/security"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+
+	"github.com/google/uuid"
+)
+
+// Triggers
+const (
+	LinkCreated = "link_created"
+	LinkClicks  = "link_clicks"
+)
+
+const (
+	maxHooksPerOwner = 20
+	// DefaultPollLimit and MaxPollLimit bound the items a poll returns
+	DefaultPollLimit = 50
+	MaxPollLimit     = 100
+	deliveryTimeout  = 10 * time.Second
+	// scanBatchSize bounds the link_clicks items sent per hook and scan
+	scanBatchSize = 100
+)
+
+var (
+	ErrHookNotFound    = errors.New("hook not found")
+	ErrNotOwner        = errors.New("hook belongs to another owner")
+	ErrTooManyHooks    = fmt.Errorf("at most %d hooks per owner", maxHooksPerOwner)
+	ErrUnknownTrigger  = errors.New("unknown trigger")
+	ErrMissingOwner    = errors.New("owner_id not found in context")
+	errHookUnsubscribe = errors.New("target unsubscribed")
+)
+
+// Links is the part of the link service triggers use
+type Links interface {
+	ListLinks(ctx context.Context, query storage.LinkQuery, limit, offset int) ([]*storage.Link, error)
+	LinkShortURL(link *storage.Link) string
+	ValidateDestination(rawURL string) error
+}
+
+// Item is a link as triggers show it. Threshold is the click count a
+// link_clicks item was reached at, 0 for link_created.
+type Item struct {
+	ID         string    `json:"id"`
+	Trigger    string    `json:"trigger"`
+	Code       string    `json:"code"`
+	Domain     string    `json:"domain"`
+	ShortURL   string    `json:"short_url"`
+	LongURL    string    `json:"long_url"`
+	Title      string    `json:"title"`
+	Tags       []string  `json:"tags"`
+	ClickCount int       `json:"click_count"`
+	Threshold  int       `json:"threshold"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Account identifies the caller to a platform testing its connection
+type Account struct {
+	OwnerID uuid.UUID `json:"owner_id"`
+	Email   string    `json:"email"`
+	// Label names the connection in the platform's account list
+	Label string `json:"label"`
+}
+
+type SubscribeRequest struct {
+	Trigger   string `json:"trigger" validate:"required,oneof=link_created link_clicks"`
+	TargetURL string `json:"target_url" validate:"required,url,max=2048"`
+	// Threshold is the click count link_clicks fires at
+	Threshold int `json:"threshold"`
+}
+
+// Hook is the API representation of a subscription
+type Hook struct {
+	ID        uuid.UUID `json:"id"`
+	Trigger   string    `json:"trigger"`
+	TargetURL string    `json:"target_url"`
+	Threshold int       `json:"threshold,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Service struct {
+	store  storage.IntegrationStorage
+	links  Links
+	logger *logging.Logger
+	client *http.Client
+}
+
+func NewService(store storage.IntegrationStorage, links Links, logger *logging.Logger) *Service {
+	return &Service{
+		store:  store,
+		links:  links,
+		logger: logger,
+		client: security.NewOutboundClient(deliveryTimeout, 0),
+	}
+}
+
+// Me returns the caller's account; platforms call it to check that their
+// credentials work
+func (s *Service) Me(ctx context.Context) (*Account, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, ErrMissingOwner
+	}
+	account := &Account{OwnerID: ownerID, Email: middleware.GetEmailFromContext(ctx)}
+	account.Label = account.Email
+	if account.Label == "" {
+		account.Label = ownerID.String()
+	}
+	return account, nil
+}
+
+// Poll returns up to limit of the caller's most recent items of trigger:
+// the newest links, or the most recently clicked links with at least
+// threshold clicks
+func (s *Service) Poll(ctx context.Context, trigger string, threshold, limit int) ([]*Item, error) {
+	query := storage.LinkQuery{Order: storage.LinkOrderNewest}
+	switch trigger {
+	case LinkCreated:
+		threshold = 0
+	case LinkClicks:
+		if threshold < 1 {
+			return nil, validation.Errors{{Field: "threshold", Rule: "min", Message: "must be at least 1"}}
+		}
+		query = storage.LinkQuery{Order: storage.LinkOrderLastClicked, MinClicks: threshold}
+	default:
+		return nil, ErrUnknownTrigger
+	}
+	if limit <= 0 || limit > MaxPollLimit {
+		limit = DefaultPollLimit
+	}
+	links, err := s.links.ListLinks(ctx, query, limit, 0)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*Item, len(links))
+	for i, link := range links {
+		items[i] = s.item(trigger, link, threshold)
+	}
+	return items, nil
+}
+
+// item describes link. Its ID is the link key, and for link_clicks the
+// threshold too, so that each link is new to a platform once per trigger.
+func (s *Service) item(trigger string, link *storage.Link, threshold int) *Item {
+	item := &Item{
+		ID:         link.Key(),
+		Trigger:    trigger,
+		Code:       link.Code,
+		ShortURL:   s.links.LinkShortURL(link),
+		LongURL:    link.LongURL,
+		Tags:       link.Tags,
+		ClickCount: link.ClickCount,
+		Threshold:  threshold,
+		CreatedAt:  link.CreatedAt,
+	}
+	if trigger == LinkClicks {
+		item.ID = fmt.Sprintf("%s@%d", item.ID, threshold)
+	}
+	if link.Domain != nil {
+		item.Domain = *link.Domain
+	}
+	if link.Description != nil {
+		item.Title = *link.Description
+	}
+	if item.Tags == nil {
+		item.Tags = []string{}
+	}
+	return item
+}
+
+// Subscribe saves a REST hook for the caller. A link_clicks hook only fires
+// for links reaching its threshold from now on.
+func (s *Service) Subscribe(ctx context.Context, req *SubscribeRequest) (*Hook, error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+	if req.Trigger == LinkClicks && req.Threshold < 1 {
+		return nil, validation.Errors{{Field: "threshold", Rule: "min", Message: "must be at least 1"}}
+	}
+	if err := s.links.ValidateDestination(req.TargetURL); err != nil {
+		return nil, validation.Errors{{Field: "target_url", Rule: "destination", Message: strings.TrimPrefix(err.Error(), "invalid URL: ")}}
+	}
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, ErrMissingOwner
+	}
+
+	existing, err := s.store.ListHooksByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxHooksPerOwner {
+		return nil, ErrTooManyHooks
+	}
+
+	hook := &storage.IntegrationHook{
+		ID:        uuid.New(),
+		OwnerID:   ownerID,
+		Trigger:   req.Trigger,
+		TargetURL: req.TargetURL,
+		CreatedAt: time.Now(),
+	}
+	if req.Trigger == LinkClicks {
+		hook.Threshold = req.Threshold
+	}
+	if err := s.store.CreateHook(ctx, hook); err != nil {
+		return nil, err
+	}
+	return toHook(hook), nil
+}
+
+func (s *Service) ListHooks(ctx context.Context) ([]*Hook, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, ErrMissingOwner
+	}
+	stored, err := s.store.ListHooksByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	hooks := make([]*Hook, len(stored))
+	for i, hook := range stored {
+		hooks[i] = toHook(hook)
+	}
+	return hooks, nil
+}
+
+func (s *Service) Unsubscribe(ctx context.Context, id uuid.UUID) error {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return ErrMissingOwner
+	}
+	hook, err := s.store.GetHook(ctx, id)
+	if err != nil {
+		return err
+	}
+	if hook == nil {
+		return ErrHookNotFound
+	}
+	if hook.OwnerID != ownerID {
+		return ErrNotOwner
+	}
+	return s.store.DeleteHook(ctx, id)
+}
+
+func toHook(hook *storage.IntegrationHook) *Hook {
+	return &Hook{
+		ID:        hook.ID,
+		Trigger:   hook.Trigger,
+		TargetURL: hook.TargetURL,
+		Threshold: hook.Threshold,
+		CreatedAt: hook.CreatedAt,
+	}
+}
@@ -0,0 +1,206 @@
+package triggers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStore struct {
+	storage.IntegrationStorage
+	hooks     map[uuid.UUID]*storage.IntegrationHook
+	threshold []*storage.Link
+	fired     map[string]bool
+}
+
+func (f *fakeStore) CreateHook(ctx context.Context, hook *storage.IntegrationHook) error {
+	f.hooks[hook.ID] = hook
+	return nil
+}
+
+func (f *fakeStore) GetHook(ctx context.Context, id uuid.UUID) (*storage.IntegrationHook, error) {
+	return f.hooks[id], nil
+}
+
+func (f *fakeStore) ListHooksByOwner(ctx context.Context, ownerID uuid.UUID) ([]*storage.IntegrationHook, error) {
+	var hooks []*storage.IntegrationHook
+	for _, hook := range f.hooks {
+		if hook.OwnerID == ownerID {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks, nil
+}
+
+func (f *fakeStore) ListHooks(ctx context.Context, trigger string, ownerID *uuid.UUID) ([]*storage.IntegrationHook, error) {
+	var hooks []*storage.IntegrationHook
+	for _, hook := range f.hooks {
+		if hook.Trigger == trigger && (ownerID == nil || hook.OwnerID == *ownerID) {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks, nil
+}
+
+func (f *fakeStore) DeleteHook(ctx context.Context, id uuid.UUID) error {
+	delete(f.hooks, id)
+	return nil
+}
+
+func (f *fakeStore) FindThresholdLinks(ctx context.Context, hook *storage.IntegrationHook, limit int) ([]*storage.Link, error) {
+	return f.threshold, nil
+}
+
+func (f *fakeStore) ClaimFiring(ctx context.Context, hookID uuid.UUID, key string) (bool, error) {
+	if f.fired[hookID.String()+key] {
+		return false, nil
+	}
+	f.fired[hookID.String()+key] = true
+	return true, nil
+}
+
+func (f *fakeStore) ReleaseFiring(ctx context.Context, hookID uuid.UUID, key string) error {
+	delete(f.fired, hookID.String()+key)
+	return nil
+}
+
+type fakeLinks struct {
+	links []*storage.Link
+	query storage.LinkQuery
+}
+
+func (f *fakeLinks) ListLinks(ctx context.Context, query storage.LinkQuery, limit, offset int) ([]*storage.Link, error) {
+	f.query = query
+	return f.links, nil
+}
+
+func (f *fakeLinks) LinkShortURL(link *storage.Link) string  { return "https://sho.rt/r/" + link.Code }
+func (f *fakeLinks) ValidateDestination(rawURL string) error { return nil }
+
+func newTestService(links *fakeLinks) (*Service, *fakeStore) {
+	store := &fakeStore{hooks: make(map[uuid.UUID]*storage.IntegrationHook), fired: make(map[string]bool)}
+	s := NewService(store, links, logging.NewLogger(logging.LevelError))
+	// Test targets listen on loopback
+	s.client = http.DefaultClient
+	return s, store
+}
+
+func TestPoll(t *testing.T) {
+	title := "Launch"
+	links := &fakeLinks{links: []*storage.Link{{Code: "abc", LongURL: "https://example.com", Description: &title, ClickCount: 12}}}
+	s, _ := newTestService(links)
+	ctx := middleware.WithOwnerID(context.Background(), uuid.New())
+
+	items, err := s.Poll(ctx, LinkCreated, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, "abc", items[0].ID)
+	assert.Equal(t, "https://sho.rt/r/abc", items[0].ShortURL)
+	assert.Equal(t, "Launch", items[0].Title)
+	assert.Equal(t, storage.LinkOrderNewest, links.query.Order)
+
+	// Every field is present, even when empty
+	data, err := json.Marshal(items[0])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"domain":""`)
+	assert.Contains(t, string(data), `"tags":[]`)
+
+	items, err = s.Poll(ctx, LinkClicks, 10, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "abc@10", items[0].ID, "a link is new again for every threshold")
+	assert.Equal(t, 10, links.query.MinClicks)
+
+	_, err = s.Poll(ctx, LinkClicks, 0, 0)
+	var verrs validation.Errors
+	assert.ErrorAs(t, err, &verrs)
+	_, err = s.Poll(ctx, "link_deleted", 0, 0)
+	assert.ErrorIs(t, err, ErrUnknownTrigger)
+}
+
+func TestSubscribe(t *testing.T) {
+	s, store := newTestService(&fakeLinks{})
+	owner := uuid.New()
+	ctx := middleware.WithOwnerID(context.Background(), owner)
+
+	_, err := s.Subscribe(ctx, &SubscribeRequest{Trigger: LinkClicks, TargetURL: "https://hooks.example.com/1"})
+	var verrs validation.Errors
+	assert.ErrorAs(t, err, &verrs, "link_clicks needs a threshold")
+
+	hook, err := s.Subscribe(ctx, &SubscribeRequest{Trigger: LinkCreated, TargetURL: "https://hooks.example.com/1", Threshold: 5})
+	require.NoError(t, err)
+	assert.Zero(t, hook.Threshold)
+	require.Contains(t, store.hooks, hook.ID)
+	assert.Equal(t, owner, store.hooks[hook.ID].OwnerID)
+
+	err = s.Unsubscribe(middleware.WithOwnerID(context.Background(), uuid.New()), hook.ID)
+	assert.ErrorIs(t, err, ErrNotOwner)
+}
+
+func TestPublishEvent(t *testing.T) {
+	received := make(chan *Item, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var item Item
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&item))
+		received <- &item
+	}))
+	defer target.Close()
+
+	s, store := newTestService(&fakeLinks{})
+	owner := uuid.New()
+	store.hooks[uuid.New()] = &storage.IntegrationHook{ID: uuid.New(), OwnerID: owner, Trigger: LinkCreated, TargetURL: target.URL}
+
+	payload, _ := json.Marshal(&storage.Link{Code: "abc", LongURL: "https://example.com", OwnerID: &owner})
+	err := s.PublishEvent(context.Background(), &storage.OutboxEvent{Type: "link.created", OwnerID: &owner, Payload: payload, CreatedAt: time.Now()})
+	require.NoError(t, err)
+
+	item := <-received
+	assert.Equal(t, LinkCreated, item.Trigger)
+	assert.Equal(t, "abc", item.Code)
+	assert.Equal(t, "https://sho.rt/r/abc", item.ShortURL)
+}
+
+func TestScanOnce(t *testing.T) {
+	var posts int
+	gone := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		if gone {
+			w.WriteHeader(http.StatusGone)
+		}
+	}))
+	defer target.Close()
+
+	s, store := newTestService(&fakeLinks{})
+	hook := &storage.IntegrationHook{ID: uuid.New(), OwnerID: uuid.New(), Trigger: LinkClicks, TargetURL: target.URL, Threshold: 100}
+	store.hooks[hook.ID] = hook
+	store.threshold = []*storage.Link{{Code: "abc", ClickCount: 120}}
+
+	sent, err := s.ScanOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+
+	// A link fires each hook once
+	sent, err = s.ScanOnce(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, sent)
+	assert.Equal(t, 1, posts)
+
+	// Targets removed on the platform's side unsubscribe
+	store.threshold = []*storage.Link{{Code: "def", ClickCount: 100}}
+	gone = true
+	_, err = s.ScanOnce(context.Background())
+	require.NoError(t, err)
+	assert.NotContains(t, store.hooks, hook.ID)
+}
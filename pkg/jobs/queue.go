@@ -0,0 +1,293 @@
+// Package jobs runs background work stored in the jobs table. Features
+// register a Handler for each kind of job together with a retry Policy, and
+// enqueue jobs from any process that shares the database; the API server
+// runs the Queue.
+//
+// A failed job is retried with backoff until its Policy's attempts run out,
+// then moved to the dead letter list, where operators can inspect and
+// requeue it through /admin/jobs. Handlers must be idempotent: a job whose
+// worker dies mid-run is retried once its lease expires.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+)
+
+const (
+	batchSize = 10
+	// lease must comfortably cover the longest Policy.Timeout
+	lease = 5 * time.Minute
+
+	// CleanupKind prunes finished jobs every hour
+	CleanupKind   = "jobs.cleanup"
+	doneRetention = 7 * 24 * time.Hour
+	deadRetention = 30 * 24 * time.Hour
+)
+
+// Handler runs one job. Returning an error wrapped with Permanent skips the
+// remaining attempts.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+// Policy controls how a kind of job is retried
+type Policy struct {
+	MaxAttempts int
+	// Backoff returns the delay after the given failed attempt
+	Backoff func(attempt int) time.Duration
+	// Timeout bounds a single attempt
+	Timeout time.Duration
+}
+
+// DefaultPolicy tries 5 times over roughly 8 minutes
+var DefaultPolicy = Policy{
+	MaxAttempts: 5,
+	Backoff:     Exponential(30*time.Second, time.Hour),
+	Timeout:     time.Minute,
+}
+
+// Exponential doubles the delay from base per attempt, up to limit
+func Exponential(base, limit time.Duration) func(int) time.Duration {
+	return func(attempt int) time.Duration {
+		delay := base
+		for i := 1; i < attempt && delay < limit; i++ {
+			delay *= 2
+		}
+		return min(delay, limit)
+	}
+}
+
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, such as a malformed payload
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+type registration struct {
+	handler Handler
+	policy  Policy
+}
+
+type schedule struct {
+	kind     string
+	interval time.Duration
+	lastSlot time.Time
+}
+
+type Queue struct {
+	store    storage.JobStorage
+	logger   *logging.Logger
+	handlers map[string]*registration
+	kinds    []string
+	// Owned by the Run goroutine
+	schedules []*schedule
+}
+
+func NewQueue(store storage.JobStorage, logger *logging.Logger) *Queue {
+	q := &Queue{
+		store:    store,
+		logger:   logger,
+		handlers: make(map[string]*registration),
+	}
+	q.Register(CleanupKind, DefaultPolicy, q.cleanup)
+	q.Every(CleanupKind, time.Hour)
+	return q
+}
+
+// Register sets the handler and policy for kind. It must be called before
+// Run, in every process that enqueues jobs of that kind.
+func (q *Queue) Register(kind string, policy Policy, handler Handler) {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	if policy.Backoff == nil {
+		policy.Backoff = DefaultPolicy.Backoff
+	}
+	if policy.Timeout <= 0 || policy.Timeout > lease/2 {
+		policy.Timeout = DefaultPolicy.Timeout
+	}
+	if _, ok := q.handlers[kind]; !ok {
+		q.kinds = append(q.kinds, kind)
+	}
+	q.handlers[kind] = &registration{handler: handler, policy: policy}
+}
+
+// Every enqueues a job of kind once per interval across all processes
+// running the queue
+func (q *Queue) Every(kind string, interval time.Duration) {
+	q.schedules = append(q.schedules, &schedule{kind: kind, interval: interval})
+}
+
+// Enqueue adds a job that runs as soon as a worker is free
+func (q *Queue) Enqueue(ctx context.Context, kind string, payload any) error {
+	job, err := q.newJob(kind, payload)
+	if err != nil {
+		return err
+	}
+	_, err = q.store.EnqueueJob(ctx, job)
+	return err
+}
+
+// EnqueueUnique adds a job unless one with the same key was ever enqueued,
+// and reports whether it was added
+func (q *Queue) EnqueueUnique(ctx context.Context, kind, key string, payload any) (bool, error) {
+	job, err := q.newJob(kind, payload)
+	if err != nil {
+		return false, err
+	}
+	job.UniqueKey = &key
+	return q.store.EnqueueJob(ctx, job)
+}
+
+func (q *Queue) newJob(kind string, payload any) (*storage.Job, error) {
+	reg, ok := q.handlers[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown job kind %q", kind)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return &storage.Job{
+		Kind:        kind,
+		Payload:     body,
+		MaxAttempts: reg.policy.MaxAttempts,
+		RunAt:       time.Now(),
+	}, nil
+}
+
+// Run polls for due jobs every interval until ctx is done. A full batch is
+// followed immediately by the next one.
+func (q *Queue) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := q.RunOnce(ctx)
+		if err != nil {
+			q.logger.Error(ctx, "job queue failed", "error", err)
+		}
+		if n == batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce enqueues due scheduled jobs, then claims and runs one batch
+// concurrently and returns its size
+func (q *Queue) RunOnce(ctx context.Context) (int, error) {
+	now := time.Now()
+	for _, s := range q.schedules {
+		slot := now.UTC().Truncate(s.interval)
+		if slot.Equal(s.lastSlot) {
+			continue
+		}
+		key := s.kind + ":" + slot.Format(time.RFC3339)
+		if _, err := q.EnqueueUnique(ctx, s.kind, key, struct{}{}); err != nil {
+			return 0, err
+		}
+		s.lastSlot = slot
+	}
+
+	jobs, err := q.store.ClaimJobs(ctx, q.kinds, batchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.process(ctx, job)
+		}()
+	}
+	wg.Wait()
+	return len(jobs), nil
+}
+
+func (q *Queue) process(ctx context.Context, job *storage.Job) {
+	reg := q.handlers[job.Kind]
+	if job.Attempts > job.MaxAttempts {
+		// The worker running the last attempt died before recording it
+		q.kill(ctx, job, errors.New("lease expired on final attempt"))
+		return
+	}
+
+	err := q.run(ctx, reg, job)
+	switch {
+	case err == nil:
+		if err := q.store.CompleteJob(ctx, job.ID); err != nil {
+			q.logger.Error(ctx, "failed to complete job", "job_id", job.ID, "error", err)
+		}
+	case job.Attempts >= job.MaxAttempts || errors.As(err, new(*permanentError)):
+		q.kill(ctx, job, err)
+	default:
+		runAt := time.Now().Add(reg.policy.Backoff(job.Attempts))
+		q.logger.Warn(ctx, "job failed, will retry", "job_id", job.ID, "kind", job.Kind, "attempts", job.Attempts, "error", err)
+		if err := q.store.RetryJob(ctx, job.ID, runAt, err.Error()); err != nil {
+			q.logger.Error(ctx, "failed to reschedule job", "job_id", job.ID, "error", err)
+		}
+	}
+}
+
+func (q *Queue) run(ctx context.Context, reg *registration, job *storage.Job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, reg.policy.Timeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return reg.handler(ctx, job.Payload)
+}
+
+func (q *Queue) kill(ctx context.Context, job *storage.Job, cause error) {
+	q.logger.Warn(ctx, "job moved to dead letter", "job_id", job.ID, "kind", job.Kind, "attempts", job.Attempts, "error", cause)
+	if err := q.store.KillJob(ctx, job.ID, cause.Error()); err != nil {
+		q.logger.Error(ctx, "failed to kill job", "job_id", job.ID, "error", err)
+	}
+}
+
+// cleanup prunes finished jobs; dead ones are kept longer for inspection
+func (q *Queue) cleanup(ctx context.Context, _ json.RawMessage) error {
+	now := time.Now()
+	if _, err := q.store.DeleteJobs(ctx, storage.JobDone, now.Add(-doneRetention)); err != nil {
+		return err
+	}
+	_, err := q.store.DeleteJobs(ctx, storage.JobDead, now.Add(-deadRetention))
+	return err
+}
+
+// List returns jobs for the admin API, most recently updated first
+func (q *Queue) List(ctx context.Context, status, kind string, limit, offset int) ([]*storage.Job, error) {
+	return q.store.ListJobs(ctx, status, kind, limit, offset)
+}
+
+// Get returns nil, nil if the job doesn't exist
+func (q *Queue) Get(ctx context.Context, id int64) (*storage.Job, error) {
+	return q.store.GetJob(ctx, id)
+}
+
+// Requeue gives a dead or done job a fresh set of attempts
+func (q *Queue) Requeue(ctx context.Context, id int64) (bool, error) {
+	return q.store.RequeueJob(ctx, id)
+}
@@ -0,0 +1,170 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStore runs every due job on each claim, like a single worker would
+type memStore struct {
+	storage.JobStorage
+	jobs []*storage.Job
+	keys map[string]bool
+}
+
+func newMemStore() *memStore {
+	return &memStore{keys: map[string]bool{}}
+}
+
+func (m *memStore) EnqueueJob(ctx context.Context, job *storage.Job) (bool, error) {
+	if job.UniqueKey != nil {
+		if m.keys[*job.UniqueKey] {
+			return false, nil
+		}
+		m.keys[*job.UniqueKey] = true
+	}
+	job.ID = int64(len(m.jobs) + 1)
+	job.Status = storage.JobPending
+	m.jobs = append(m.jobs, job)
+	return true, nil
+}
+
+func (m *memStore) ClaimJobs(ctx context.Context, kinds []string, limit int, lease time.Duration) ([]*storage.Job, error) {
+	var claimed []*storage.Job
+	for _, job := range m.jobs {
+		if job.Status == storage.JobPending && !job.RunAt.After(time.Now()) && len(claimed) < limit {
+			job.Status = storage.JobRunning
+			job.Attempts++
+			claimed = append(claimed, job)
+		}
+	}
+	return claimed, nil
+}
+
+func (m *memStore) find(id int64) *storage.Job {
+	return m.jobs[id-1]
+}
+
+func (m *memStore) CompleteJob(ctx context.Context, id int64) error {
+	m.find(id).Status = storage.JobDone
+	return nil
+}
+
+func (m *memStore) RetryJob(ctx context.Context, id int64, runAt time.Time, lastErr string) error {
+	job := m.find(id)
+	job.Status, job.RunAt, job.LastError = storage.JobPending, runAt, &lastErr
+	return nil
+}
+
+func (m *memStore) KillJob(ctx context.Context, id int64, lastErr string) error {
+	job := m.find(id)
+	job.Status, job.LastError = storage.JobDead, &lastErr
+	return nil
+}
+
+func newTestQueue(store *memStore) *Queue {
+	q := NewQueue(store, logging.NewLogger(logging.LevelError))
+	q.schedules = nil // keep the hourly cleanup out of the way
+	return q
+}
+
+func TestRunOnceCompletesAndRetries(t *testing.T) {
+	store := newMemStore()
+	q := newTestQueue(store)
+	failures := 1
+	q.Register("flaky", Policy{MaxAttempts: 3, Backoff: func(int) time.Duration { return 0 }}, func(ctx context.Context, payload json.RawMessage) error {
+		if failures > 0 {
+			failures--
+			return errors.New("try again")
+		}
+		return nil
+	})
+	require.NoError(t, q.Enqueue(context.Background(), "flaky", map[string]string{"code": "abc"}))
+	assert.Equal(t, 3, store.jobs[0].MaxAttempts)
+	assert.JSONEq(t, `{"code":"abc"}`, string(store.jobs[0].Payload))
+
+	n, err := q.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, storage.JobPending, store.jobs[0].Status)
+	assert.Equal(t, "try again", *store.jobs[0].LastError)
+
+	_, err = q.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, storage.JobDone, store.jobs[0].Status)
+	assert.Equal(t, 2, store.jobs[0].Attempts)
+}
+
+func TestRunOnceDeadLetters(t *testing.T) {
+	store := newMemStore()
+	q := newTestQueue(store)
+	q.Register("failing", Policy{MaxAttempts: 2, Backoff: func(int) time.Duration { return 0 }}, func(ctx context.Context, payload json.RawMessage) error {
+		return errors.New("endpoint down")
+	})
+	q.Register("malformed", DefaultPolicy, func(ctx context.Context, payload json.RawMessage) error {
+		return Permanent(errors.New("bad payload"))
+	})
+	q.Register("panics", Policy{MaxAttempts: 1}, func(ctx context.Context, payload json.RawMessage) error {
+		panic("boom")
+	})
+	for _, kind := range []string{"failing", "malformed", "panics"} {
+		require.NoError(t, q.Enqueue(context.Background(), kind, nil))
+	}
+
+	for i := 0; i < 3; i++ {
+		_, err := q.RunOnce(context.Background())
+		require.NoError(t, err)
+	}
+	for _, job := range store.jobs {
+		assert.Equal(t, storage.JobDead, job.Status, job.Kind)
+	}
+	assert.Equal(t, 2, store.jobs[0].Attempts)
+	assert.Equal(t, 1, store.jobs[1].Attempts)
+	assert.Equal(t, "panic: boom", *store.jobs[2].LastError)
+}
+
+func TestEnqueueUnknownKind(t *testing.T) {
+	q := newTestQueue(newMemStore())
+	assert.Error(t, q.Enqueue(context.Background(), "nope", nil))
+}
+
+func TestEveryEnqueuesOncePerSlot(t *testing.T) {
+	store := newMemStore()
+	q := newTestQueue(store)
+	ran := 0
+	q.Register("tick", DefaultPolicy, func(ctx context.Context, payload json.RawMessage) error {
+		ran++
+		return nil
+	})
+	q.Every("tick", time.Hour)
+	// Another replica already enqueued this slot
+	other := newTestQueue(store)
+	other.handlers = q.handlers
+	other.Every("tick", time.Hour)
+
+	for i := 0; i < 2; i++ {
+		_, err := q.RunOnce(context.Background())
+		require.NoError(t, err)
+		_, err = other.RunOnce(context.Background())
+		require.NoError(t, err)
+	}
+	assert.Len(t, store.jobs, 1)
+	assert.Equal(t, 1, ran)
+}
+
+func TestExponential(t *testing.T) {
+	backoff := Exponential(30*time.Second, 5*time.Minute)
+	assert.Equal(t, 30*time.Second, backoff(1))
+	assert.Equal(t, 2*time.Minute, backoff(3))
+	assert.Equal(t, 5*time.Minute, backoff(5))
+	assert.Equal(t, 5*time.Minute, backoff(50))
+}
@@ -0,0 +1,275 @@
+// Package live carries link events to the dashboards of their owners as
+// they happen. Servers publish clicks, link changes from the outbox and
+// links found expired to the Redis Pub/Sub channel live:{owner_id}, and
+// every API server subscribes to the channels of the owners connected to it.
+//
+// Delivery is best effort: events published while no one listens, or that
+// don't fit a queue, are dropped, and dashboards catch up by reloading.
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Event types
+const (
+	LinkClicked = "link.clicked"
+	LinkCreated = "link.created"
+	LinkUpdated = "link.updated"
+	LinkDeleted = "link.deleted"
+	LinkExpired = "link.expired"
+)
+
+const (
+	// queueSize bounds the events waiting to be published, and those
+	// waiting for each subscriber
+	queueSize = 1000
+	// expiredOnce is how long a link found expired isn't announced again
+	expiredOnce = 24 * time.Hour
+)
+
+// Event is one update to an owner's links. Clicks counts the clicks a
+// link.clicked event stands for; events carrying a link have it as the API
+// shows it.
+type Event struct {
+	Type    string          `json:"type"`
+	Code    string          `json:"code,omitempty"`
+	Domain  string          `json:"domain,omitempty"`
+	Clicks  int             `json:"clicks,omitempty"`
+	Link    json.RawMessage `json:"link,omitempty"`
+	At      time.Time       `json:"at"`
+	OwnerID uuid.UUID       `json:"-"`
+}
+
+// Subscription receives the events of one owner
+type Subscription struct {
+	events  chan *Event
+	dropped atomic.Int64
+	close   func()
+}
+
+// Events delivers the owner's events until the subscription is closed
+func (s *Subscription) Events() <-chan *Event {
+	return s.events
+}
+
+// Dropped returns how many events didn't fit the subscription's queue
+// since it was last called
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Swap(0)
+}
+
+func (s *Subscription) Close() {
+	s.close()
+}
+
+// Hub hands events to the subscriptions of their owners in this process.
+// It is all a single server needs; Bus extends it to several.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[uuid.UUID]map[*Subscription]bool
+	// watch and unwatch, when set, are told about the first subscription
+	// of an owner and the end of the last one
+	watch   func(ownerID uuid.UUID)
+	unwatch func(ownerID uuid.UUID)
+}
+
+func NewHub() *Hub {
+	return &Hub{subs: make(map[uuid.UUID]map[*Subscription]bool)}
+}
+
+func (h *Hub) Subscribe(ownerID uuid.UUID) *Subscription {
+	sub := &Subscription{events: make(chan *Event, queueSize)}
+	sub.close = sync.OnceFunc(func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.subs[ownerID], sub)
+		if len(h.subs[ownerID]) == 0 {
+			delete(h.subs, ownerID)
+			if h.unwatch != nil {
+				h.unwatch(ownerID)
+			}
+		}
+	})
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[ownerID] == nil {
+		h.subs[ownerID] = make(map[*Subscription]bool)
+		if h.watch != nil {
+			h.watch(ownerID)
+		}
+	}
+	h.subs[ownerID][sub] = true
+	return sub
+}
+
+// Publish hands event to every subscription of its owner without blocking
+func (h *Hub) Publish(event *Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for sub := range h.subs[event.OwnerID] {
+		select {
+		case sub.events <- event:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// Bus publishes events to every API server through Redis and delivers the
+// ones for owners subscribed here
+type Bus struct {
+	*Hub
+	client  *redis.Client
+	logger  *logging.Logger
+	queue   chan *Event
+	dropped atomic.Int64
+
+	pubsub  *redis.PubSub
+	receive sync.Once
+}
+
+func NewBus(client *redis.Client, logger *logging.Logger) *Bus {
+	b := &Bus{
+		Hub:    NewHub(),
+		client: client,
+		logger: logger,
+		queue:  make(chan *Event, queueSize),
+	}
+	b.watch = func(ownerID uuid.UUID) {
+		if err := b.subscriber().Subscribe(context.Background(), channel(ownerID)); err != nil {
+			b.logger.Warn(context.Background(), "failed to subscribe to live events", "owner_id", ownerID, "error", err)
+		}
+	}
+	b.unwatch = func(ownerID uuid.UUID) {
+		if err := b.subscriber().Unsubscribe(context.Background(), channel(ownerID)); err != nil {
+			b.logger.Warn(context.Background(), "failed to unsubscribe from live events", "owner_id", ownerID, "error", err)
+		}
+	}
+	return b
+}
+
+func channel(ownerID uuid.UUID) string {
+	return "live:" + ownerID.String()
+}
+
+// Publish queues event without blocking; if the queue is full the event is
+// dropped so that redirects never wait on dashboards
+func (b *Bus) Publish(event *Event) {
+	select {
+	case b.queue <- event:
+	default:
+		b.dropped.Add(1)
+	}
+}
+
+// PublishEvent announces link changes from the outbox; other events are
+// ignored
+func (b *Bus) PublishEvent(ctx context.Context, event *storage.OutboxEvent) error {
+	if event.OwnerID == nil {
+		return nil
+	}
+	switch event.Type {
+	case LinkCreated, LinkUpdated, LinkDeleted:
+	default:
+		return nil
+	}
+	var link struct {
+		Code   string `json:"code"`
+		Domain string `json:"domain"`
+	}
+	if err := json.Unmarshal(event.Payload, &link); err != nil {
+		return err
+	}
+	return b.send(ctx, &Event{
+		Type:    event.Type,
+		Code:    link.Code,
+		Domain:  link.Domain,
+		Link:    event.Payload,
+		At:      event.CreatedAt,
+		OwnerID: *event.OwnerID,
+	})
+}
+
+// Run publishes queued events until ctx is done
+func (b *Bus) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-b.queue:
+			if err := b.send(ctx, event); err != nil {
+				b.logger.Warn(ctx, "failed to publish live event", "type", event.Type, "error", err)
+			}
+		case <-ticker.C:
+			if n := b.dropped.Swap(0); n > 0 {
+				b.logger.Warn(ctx, "live event queue full, events dropped", "count", n)
+			}
+		}
+	}
+}
+
+func (b *Bus) send(ctx context.Context, event *Event) error {
+	expired := "live_expired:" + storage.LinkKey(event.Domain, event.Code)
+	switch event.Type {
+	case LinkExpired:
+		// Every visit to an expired link finds it expired; announce it once
+		first, err := b.client.SetNX(ctx, expired, 1, expiredOnce).Result()
+		if err != nil || !first {
+			return err
+		}
+	case LinkUpdated:
+		// The link may have been given more time or clicks
+		if err := b.client.Del(ctx, expired).Err(); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, channel(event.OwnerID), data).Err()
+}
+
+// subscriber returns the Pub/Sub connection, starting to deliver what it
+// receives on first use
+func (b *Bus) subscriber() *redis.PubSub {
+	b.receive.Do(func() {
+		b.pubsub = b.client.Subscribe(context.Background())
+		go b.deliver(b.pubsub.Channel())
+	})
+	return b.pubsub
+}
+
+func (b *Bus) deliver(messages <-chan *redis.Message) {
+	for msg := range messages {
+		ownerID, err := uuid.Parse(msg.Channel[len("live:"):])
+		if err != nil {
+			continue
+		}
+		var event Event
+		if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+			continue
+		}
+		event.OwnerID = ownerID
+		b.Hub.Publish(&event)
+	}
+}
+
+// Close stops receiving events
+func (b *Bus) Close() error {
+	return b.subscriber().Close()
+}
@@ -0,0 +1,215 @@
+// Package liveness checks that link destinations still work. A Checker
+// periodically sends a HEAD request to each destination not checked
+// recently, records whether it is alive, gone (404 or 410), parked on a
+// domain parking service or failing, and can email owners when one of their
+// destinations dies.
+package liveness
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/notify"
+	"url-shortener/pkg/security"
+	"url-shortener/pkg/service"
+	"url-shortener/pkg/storage"
+)
+
+const (
+	// batchSize bounds the number of destinations checked per run
+	batchSize = 200
+	// concurrency is how many destinations are checked at once
+	concurrency = 8
+	// requestTimeout bounds each check, redirects included
+	requestTimeout = 10 * time.Second
+	maxRedirects   = 5
+)
+
+// parkingHosts serve the placeholder pages of parked and for-sale domains;
+// a destination that redirects to one has lapsed
+var parkingHosts = []string{
+	"above.com", "afternic.com", "bodis.com", "dan.com", "hugedomains.com",
+	"parkingcrew.net", "parklogic.com", "sedo.com", "sedoparking.com",
+	"undeveloped.com",
+}
+
+// Sender delivers a rendered template; *notify.Mailer implements it
+type Sender interface {
+	Send(ctx context.Context, to, template string, data any) error
+}
+
+// DeadDestination is the data for the dead_destination template
+type DeadDestination struct {
+	ShortURL   string
+	LongURL    string
+	Status     string
+	HTTPStatus *int
+}
+
+type Checker struct {
+	store    storage.HealthStorage
+	client   *http.Client
+	shortURL func(*storage.Link) string
+	logger   *logging.Logger
+	sender   Sender
+}
+
+// NewChecker builds a checker; shortURL renders the public URL of a link
+func NewChecker(store storage.HealthStorage, shortURL func(*storage.Link) string, logger *logging.Logger) *Checker {
+	return &Checker{
+		store:    store,
+		client:   security.NewOutboundClient(requestTimeout, maxRedirects),
+		shortURL: shortURL,
+		logger:   logger,
+	}
+}
+
+// NotifyOwners emails owners who opted in to expiry reminders when one of
+// their destinations is found dead
+func (c *Checker) NotifyOwners(sender Sender) {
+	c.sender = sender
+}
+
+// Run checks destinations last checked more than recheck ago every interval
+// until ctx is done
+func (c *Checker) Run(ctx context.Context, interval, recheck time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if checked, err := c.CheckOnce(ctx, time.Now().Add(-recheck)); err != nil {
+			c.logger.Error(ctx, "liveness check failed", "error", err)
+		} else if checked > 0 {
+			c.logger.Info(ctx, "destinations checked", "count", checked)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CheckOnce checks a batch of destinations not checked since checkedBefore
+// and returns how many were checked
+func (c *Checker) CheckOnce(ctx context.Context, checkedBefore time.Time) (int, error) {
+	candidates, err := c.store.FindHealthCandidates(ctx, checkedBefore, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for _, candidate := range candidates {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			c.check(ctx, candidate)
+		}()
+	}
+	wg.Wait()
+	return len(candidates), nil
+}
+
+func (c *Checker) check(ctx context.Context, candidate *storage.HealthCandidate) {
+	link := candidate.Link
+	health := c.Probe(ctx, destination(link.LongURL))
+	if err := c.store.SaveHealth(ctx, link.Key(), health); err != nil {
+		c.logger.Warn(ctx, "failed to save destination health", "code", link.Code, "error", err)
+		return
+	}
+	if c.sender == nil || !health.Dead() || (candidate.Health != nil && candidate.Health.Status == health.Status) {
+		return
+	}
+	to, ok := notify.Recipient(candidate.Settings, notify.CategoryExpiry)
+	if !ok {
+		return
+	}
+
+	// Claim first so that concurrent API replicas don't both send
+	claimed, err := c.store.ClaimHealthNotice(ctx, link.Key())
+	if err != nil || !claimed {
+		return
+	}
+	notice := &DeadDestination{ShortURL: c.shortURL(link), LongURL: link.LongURL, Status: health.Status, HTTPStatus: health.HTTPStatus}
+	if err := c.sender.Send(ctx, to, "dead_destination", notice); err != nil {
+		c.logger.Warn(ctx, "dead destination notice failed", "code", link.Code, "error", err)
+		if err := c.store.ReleaseHealthNotice(ctx, link.Key()); err != nil {
+			c.logger.Warn(ctx, "failed to release dead destination notice", "code", link.Code, "error", err)
+		}
+	}
+}
+
+// destination is the URL checked for longURL. Parameterized links are
+// checked at their origin, as there are no values to fill in.
+func destination(longURL string) string {
+	if len(service.LinkParams(longURL)) == 0 {
+		return longURL
+	}
+	u, err := url.Parse(longURL)
+	if err != nil {
+		return longURL
+	}
+	return u.Scheme + "://" + u.Host + "/"
+}
+
+// Probe checks rawURL with a HEAD request, falling back to GET for servers
+// that don't allow HEAD
+func (c *Checker) Probe(ctx context.Context, rawURL string) *storage.LinkHealth {
+	health := &storage.LinkHealth{Status: storage.HealthError, CheckedAt: time.Now()}
+	resp, err := c.do(ctx, http.MethodHead, rawURL)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp, err = c.do(ctx, http.MethodGet, rawURL)
+	}
+	if err != nil {
+		return health
+	}
+
+	health.HTTPStatus = &resp.StatusCode
+	switch {
+	case isParkingHost(resp.Request.URL.Hostname()):
+		health.Status = storage.HealthParked
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		health.Status = storage.HealthNotFound
+	case resp.StatusCode >= 500:
+		health.Status = storage.HealthError
+	default:
+		// Other client errors, such as 403 for bots, come from a site that
+		// is still there
+		health.Status = storage.HealthAlive
+	}
+	return health
+}
+
+// do sends one request; the body is never read
+func (c *Checker) do(ctx context.Context, method, rawURL string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "url-shortener-liveness/1.0")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+func isParkingHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, parking := range parkingHosts {
+		if host == parking || strings.HasSuffix(host, "."+parking) {
+			return true
+		}
+	}
+	return false
+}
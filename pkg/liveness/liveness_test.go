@@ -0,0 +1,142 @@
+package liveness
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStore struct {
+	mu         sync.Mutex
+	candidates []*storage.HealthCandidate
+	saved      map[string]*storage.LinkHealth
+	notified   map[string]bool
+}
+
+func (f *fakeStore) FindHealthCandidates(ctx context.Context, checkedBefore time.Time, limit int) ([]*storage.HealthCandidate, error) {
+	return f.candidates, nil
+}
+
+func (f *fakeStore) SaveHealth(ctx context.Context, key string, health *storage.LinkHealth) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.saved[key] = health
+	return nil
+}
+
+func (f *fakeStore) GetHealth(ctx context.Context, keys []string) (map[string]*storage.LinkHealth, error) {
+	return f.saved, nil
+}
+
+func (f *fakeStore) ClaimHealthNotice(ctx context.Context, key string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.notified[key] {
+		return false, nil
+	}
+	f.notified[key] = true
+	return true, nil
+}
+
+func (f *fakeStore) ReleaseHealthNotice(ctx context.Context, key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.notified, key)
+	return nil
+}
+
+type fakeSender struct {
+	mu   sync.Mutex
+	sent []*DeadDestination
+}
+
+func (f *fakeSender) Send(ctx context.Context, to, template string, data any) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = append(f.sent, data.(*DeadDestination))
+	return nil
+}
+
+func newTestChecker(t *testing.T, store storage.HealthStorage) (*Checker, string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/{$}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/gone", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/gone", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	})
+	mux.HandleFunc("/get-only", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/broken", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	checker := NewChecker(store, func(l *storage.Link) string { return "https://sho.rt/r/" + l.Code }, logging.NewLogger(logging.LevelError))
+	// The outbound client won't connect to the test server on loopback
+	checker.client = srv.Client()
+	return checker, srv.URL
+}
+
+func TestProbe(t *testing.T) {
+	checker, base := newTestChecker(t, nil)
+
+	for path, status := range map[string]string{
+		"/ok":       storage.HealthAlive,
+		"/get-only": storage.HealthAlive,
+		"/missing":  storage.HealthNotFound,
+		"/moved":    storage.HealthNotFound,
+		"/broken":   storage.HealthError,
+	} {
+		assert.Equal(t, status, checker.Probe(context.Background(), base+path).Status, path)
+	}
+	assert.Equal(t, storage.HealthError, checker.Probe(context.Background(), "http://127.0.0.1:1/").Status)
+}
+
+func TestCheckOnceNotifiesNewlyDeadDestinations(t *testing.T) {
+	store := &fakeStore{saved: map[string]*storage.LinkHealth{}, notified: map[string]bool{}}
+	checker, base := newTestChecker(t, store)
+	sender := &fakeSender{}
+	checker.NotifyOwners(sender)
+
+	email := "owner@example.com"
+	settings := &storage.NotificationSettings{Email: &email, EmailEnabled: true, ExpiryReminders: true}
+	store.candidates = []*storage.HealthCandidate{
+		{Link: &storage.Link{Code: "ok", LongURL: base + "/ok"}, Settings: settings},
+		{Link: &storage.Link{Code: "gone", LongURL: base + "/gone"}, Settings: settings},
+		{Link: &storage.Link{Code: "quiet", LongURL: base + "/gone"}},
+		{Link: &storage.Link{Code: "known", LongURL: base + "/gone"}, Settings: settings, Health: &storage.LinkHealth{Status: storage.HealthNotFound}},
+		{Link: &storage.Link{Code: "params", LongURL: base + "/missing/{id}"}, Settings: settings},
+	}
+
+	checked, err := checker.CheckOnce(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 5, checked)
+	assert.Equal(t, storage.HealthAlive, store.saved["ok"].Status)
+	assert.Equal(t, storage.HealthNotFound, store.saved["gone"].Status)
+	assert.Equal(t, storage.HealthAlive, store.saved["params"].Status, "checked at the origin")
+
+	require.Len(t, sender.sent, 1)
+	assert.Equal(t, "https://sho.rt/r/gone", sender.sent[0].ShortURL)
+	assert.Equal(t, http.StatusGone, *sender.sent[0].HTTPStatus)
+}
+
+func TestIsParkingHost(t *testing.T) {
+	assert.True(t, isParkingHost("www.SedoParking.com"))
+	assert.False(t, isParkingHost("example.com"))
+}
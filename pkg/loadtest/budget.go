@@ -0,0 +1,130 @@
+package loadtest
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Budget is the latency and error rate a scenario may not exceed. Zero
+// latencies aren't checked.
+type Budget struct {
+	P50          time.Duration
+	P95          time.Duration
+	P99          time.Duration
+	MaxErrorRate float64
+}
+
+// budgetJSON is a Budget as written in budget files, with latencies as
+// durations such as "25ms"
+type budgetJSON struct {
+	P50          string  `json:"p50"`
+	P95          string  `json:"p95"`
+	P99          string  `json:"p99"`
+	MaxErrorRate float64 `json:"max_error_rate"`
+}
+
+func (b *Budget) UnmarshalJSON(data []byte) error {
+	var raw budgetJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	for _, field := range []struct {
+		name  string
+		value string
+		into  *time.Duration
+	}{
+		{"p50", raw.P50, &b.P50},
+		{"p95", raw.P95, &b.P95},
+		{"p99", raw.P99, &b.P99},
+	} {
+		if field.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field.value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", field.name, err)
+		}
+		*field.into = d
+	}
+	b.MaxErrorRate = raw.MaxErrorRate
+	return nil
+}
+
+// Budgets are the budgets of scenarios by name
+type Budgets map[string]Budget
+
+// defaultBudgets are those of the scenarios in this package against a
+// deployment on one machine, as the CI job runs them
+//
+//go:embed budgets.json
+var defaultBudgets []byte
+
+// DefaultBudgets returns the budgets in budgets.json
+func DefaultBudgets() Budgets {
+	budgets, err := ParseBudgets(defaultBudgets)
+	if err != nil {
+		panic(fmt.Sprintf("loadtest: budgets.json: %v", err))
+	}
+	return budgets
+}
+
+// ParseBudgets parses a budget file: an object of budgets by scenario name
+func ParseBudgets(data []byte) (Budgets, error) {
+	var budgets Budgets
+	if err := json.Unmarshal(data, &budgets); err != nil {
+		return nil, err
+	}
+	return budgets, nil
+}
+
+// LoadBudgets reads the budget file at path
+func LoadBudgets(path string) (Budgets, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	budgets, err := ParseBudgets(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return budgets, nil
+}
+
+// Check returns how r exceeds b, if it does
+func (b Budget) Check(r *Result) []string {
+	var violations []string
+	for _, latency := range []struct {
+		name     string
+		measured time.Duration
+		budget   time.Duration
+	}{
+		{"p50", r.P50, b.P50},
+		{"p95", r.P95, b.P95},
+		{"p99", r.P99, b.P99},
+	} {
+		if latency.budget > 0 && latency.measured > latency.budget {
+			violations = append(violations, fmt.Sprintf("%s: %s %s over the budget of %s",
+				r.Scenario, latency.name, latency.measured.Round(time.Microsecond), latency.budget))
+		}
+	}
+	if rate := r.ErrorRate(); rate > b.MaxErrorRate {
+		violations = append(violations, fmt.Sprintf("%s: error rate %.2f%% over the budget of %.2f%%",
+			r.Scenario, rate*100, b.MaxErrorRate*100))
+	}
+	return violations
+}
+
+// Check returns how results exceed their budgets. Scenarios without a
+// budget aren't checked.
+func (b Budgets) Check(results []*Result) []string {
+	var violations []string
+	for _, r := range results {
+		if budget, ok := b[r.Scenario]; ok {
+			violations = append(violations, budget.Check(r)...)
+		}
+	}
+	return violations
+}
@@ -0,0 +1,193 @@
+// Package loadtest sends HTTP requests to the servers at a fixed rate and
+// reports the latency percentiles of each scenario, for checking them
+// against budgets in long-running CI jobs. See cmd/loadtest and the
+// scenarios in scenarios.go.
+//
+// Requests are sent on schedule whether or not earlier ones have answered,
+// up to Config.Workers in flight, and each one's latency is measured from
+// when it was due, so a server that falls behind shows it in the
+// percentiles instead of slowing the test down.
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Scenario is a kind of request to time
+type Scenario struct {
+	Name string
+	// Setup prepares what the requests need, such as links, before the
+	// scenario is timed
+	Setup func(ctx context.Context) error
+	// Request returns the i-th request
+	Request func(ctx context.Context, i int) (*http.Request, error)
+	// Expect is the status of a successful response
+	Expect int
+	// Client sends the requests
+	Client *http.Client
+}
+
+// Config is the load a scenario is run with
+type Config struct {
+	// Rate is requests per second
+	Rate     int
+	Duration time.Duration
+	// Workers caps the requests in flight (default 100)
+	Workers int
+}
+
+// Requests is the number of requests a scenario is run with
+func (c Config) Requests() int {
+	return int(c.Duration.Seconds() * float64(c.Rate))
+}
+
+// Result is what a scenario run measured
+type Result struct {
+	Scenario string
+	Requests int
+	// Errors are requests that failed or answered another status than
+	// the expected one
+	Errors   int
+	Statuses map[int]int
+	Duration time.Duration
+	P50      time.Duration
+	P95      time.Duration
+	P99      time.Duration
+	Max      time.Duration
+}
+
+// ErrorRate is the share of requests that failed
+func (r *Result) ErrorRate() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Errors) / float64(r.Requests)
+}
+
+// Throughput is the requests answered per second
+func (r *Result) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Requests) / r.Duration.Seconds()
+}
+
+// MarshalJSON reports latencies in milliseconds
+func (r *Result) MarshalJSON() ([]byte, error) {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return json.Marshal(map[string]any{
+		"scenario":   r.Scenario,
+		"requests":   r.Requests,
+		"errors":     r.Errors,
+		"error_rate": r.ErrorRate(),
+		"statuses":   r.Statuses,
+		"throughput": r.Throughput(),
+		"p50_ms":     ms(r.P50),
+		"p95_ms":     ms(r.P95),
+		"p99_ms":     ms(r.P99),
+		"max_ms":     ms(r.Max),
+	})
+}
+
+// Run sets s up and sends it cfg's load
+func Run(ctx context.Context, cfg Config, s Scenario) (*Result, error) {
+	if cfg.Rate <= 0 || cfg.Duration <= 0 {
+		return nil, fmt.Errorf("loadtest: rate and duration must be positive")
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 100
+	}
+	if s.Setup != nil {
+		if err := s.Setup(ctx); err != nil {
+			return nil, fmt.Errorf("setting up %s: %w", s.Name, err)
+		}
+	}
+
+	n := cfg.Requests()
+	latencies := make([]time.Duration, n)
+	statuses := make([]int, n)
+	interval := time.Second / time.Duration(cfg.Rate)
+	slots := make(chan struct{}, cfg.Workers)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := range n {
+		due := start.Add(time.Duration(i) * interval)
+		if wait := time.Until(due); wait > 0 {
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+				wg.Wait()
+				return nil, ctx.Err()
+			}
+		}
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return nil, ctx.Err()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			statuses[i] = send(ctx, s, i)
+			latencies[i] = time.Since(due)
+		}()
+	}
+	wg.Wait()
+
+	result := &Result{Scenario: s.Name, Requests: n, Statuses: map[int]int{}, Duration: time.Since(start)}
+	for _, status := range statuses {
+		result.Statuses[status]++
+		if status != s.Expect {
+			result.Errors++
+		}
+	}
+	slices.Sort(latencies)
+	result.P50 = percentile(latencies, 50)
+	result.P95 = percentile(latencies, 95)
+	result.P99 = percentile(latencies, 99)
+	result.Max = percentile(latencies, 100)
+	return result, nil
+}
+
+// send sends the i-th request of s and returns its status, or 0 if it
+// failed
+func send(ctx context.Context, s Scenario, i int) int {
+	req, err := s.Request(ctx, i)
+	if err != nil {
+		return 0
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0
+	}
+	defer resp.Body.Close()
+	// Reading the body is part of the request, and lets the connection
+	// be reused
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
+// percentile returns the nearest-rank p-th percentile of sorted
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[max(rank, 1)-1]
+}
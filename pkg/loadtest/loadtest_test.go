@@ -0,0 +1,139 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	var served atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every tenth request fails
+		if served.Add(1)%10 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	setUp := false
+	scenario := Scenario{
+		Name:   "test",
+		Expect: http.StatusOK,
+		Setup: func(ctx context.Context) error {
+			setUp = true
+			return nil
+		},
+		Request: func(ctx context.Context, i int) (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		},
+	}
+	result, err := Run(context.Background(), Config{Rate: 200, Duration: 500 * time.Millisecond}, scenario)
+	require.NoError(t, err)
+
+	assert.True(t, setUp)
+	assert.Equal(t, 100, result.Requests)
+	assert.EqualValues(t, 100, served.Load())
+	assert.Equal(t, 10, result.Errors)
+	assert.Equal(t, map[int]int{http.StatusOK: 90, http.StatusServiceUnavailable: 10}, result.Statuses)
+	assert.InDelta(t, 0.1, result.ErrorRate(), 1e-9)
+	assert.GreaterOrEqual(t, result.P95, 5*time.Millisecond)
+	assert.LessOrEqual(t, result.P50, result.P95)
+	assert.LessOrEqual(t, result.P95, result.P99)
+	assert.LessOrEqual(t, result.P99, result.Max)
+	assert.GreaterOrEqual(t, result.Duration, 495*time.Millisecond, "requests are paced at the rate")
+}
+
+func TestRunCountsFailedRequests(t *testing.T) {
+	scenario := Scenario{
+		Name:   "unreachable",
+		Expect: http.StatusOK,
+		Request: func(ctx context.Context, i int) (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, http.MethodGet, "http://127.0.0.1:1", nil)
+		},
+	}
+	result, err := Run(context.Background(), Config{Rate: 100, Duration: 100 * time.Millisecond}, scenario)
+	require.NoError(t, err)
+	assert.Equal(t, 10, result.Errors)
+	assert.Equal(t, map[int]int{0: 10}, result.Statuses)
+}
+
+func TestRunInvalidConfig(t *testing.T) {
+	_, err := Run(context.Background(), Config{Rate: 0, Duration: time.Second}, Scenario{})
+	assert.Error(t, err)
+}
+
+func TestPercentile(t *testing.T) {
+	var sorted []time.Duration
+	for i := 1; i <= 100; i++ {
+		sorted = append(sorted, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, 50*time.Millisecond, percentile(sorted, 50))
+	assert.Equal(t, 99*time.Millisecond, percentile(sorted, 99))
+	assert.Equal(t, 100*time.Millisecond, percentile(sorted, 100))
+	assert.Equal(t, time.Millisecond, percentile(sorted, 0))
+	assert.Equal(t, time.Duration(0), percentile(nil, 50))
+}
+
+func TestBudgets(t *testing.T) {
+	budgets, err := ParseBudgets([]byte(`{"redirect_hot": {"p50": "2ms", "p99": "20ms", "max_error_rate": 0.01}}`))
+	require.NoError(t, err)
+	assert.Equal(t, Budgets{"redirect_hot": {P50: 2 * time.Millisecond, P99: 20 * time.Millisecond, MaxErrorRate: 0.01}}, budgets)
+
+	_, err = ParseBudgets([]byte(`{"create": {"p50": "fast"}}`))
+	assert.ErrorContains(t, err, "p50")
+
+	within := &Result{Scenario: "redirect_hot", Requests: 100, Errors: 1, P50: time.Millisecond, P95: time.Hour, P99: 20 * time.Millisecond}
+	assert.Empty(t, budgets.Check([]*Result{within}), "p95 has no budget")
+
+	over := &Result{Scenario: "redirect_hot", Requests: 100, Errors: 2, P50: 3 * time.Millisecond, P99: 20 * time.Millisecond}
+	unbudgeted := &Result{Scenario: "other", Requests: 1, Errors: 1, P50: time.Hour}
+	violations := budgets.Check([]*Result{over, unbudgeted})
+	require.Len(t, violations, 2)
+	assert.Contains(t, violations[0], "redirect_hot: p50 3ms over the budget of 2ms")
+	assert.Contains(t, violations[1], "error rate 2.00%")
+}
+
+func TestDefaultBudgets(t *testing.T) {
+	budgets := DefaultBudgets()
+	target := &Target{}
+	for _, scenario := range Scenarios(target, Config{Rate: 1, Duration: time.Second}) {
+		assert.Contains(t, budgets, scenario.Name, "every scenario has a budget")
+	}
+}
+
+func TestReport(t *testing.T) {
+	results := []*Result{{Scenario: "stats", Requests: 10, Duration: time.Second, P50: 12 * time.Millisecond, P99: 40 * time.Millisecond}}
+	report := NewReport(results, Budgets{"stats": {P99: 30 * time.Millisecond}})
+	assert.False(t, report.OK())
+
+	var text bytes.Buffer
+	require.NoError(t, report.WriteText(&text))
+	assert.Contains(t, text.String(), "stats")
+	assert.Contains(t, text.String(), "FAIL stats: p99 40ms over the budget of 30ms")
+
+	var out bytes.Buffer
+	require.NoError(t, report.WriteJSON(&out))
+	var decoded struct {
+		Results []map[string]any `json:"results"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+	assert.Equal(t, 40.0, decoded.Results[0]["p99_ms"])
+	assert.Equal(t, 10.0, decoded.Results[0]["throughput"])
+
+	assert.True(t, NewReport(results, Budgets{}).OK())
+	out.Reset()
+	require.NoError(t, NewReport(results, Budgets{}).WriteJSON(&out))
+	assert.Contains(t, out.String(), `"violations": []`)
+}
@@ -0,0 +1,60 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// Report is the results of a run and how they exceed their budgets
+type Report struct {
+	Results    []*Result `json:"results"`
+	Violations []string  `json:"violations"`
+}
+
+// NewReport checks results against budgets
+func NewReport(results []*Result, budgets Budgets) *Report {
+	report := &Report{Results: results, Violations: budgets.Check(results)}
+	if report.Violations == nil {
+		report.Violations = []string{}
+	}
+	return report
+}
+
+// OK is whether every result is within its budget
+func (r *Report) OK() bool {
+	return len(r.Violations) == 0
+}
+
+// WriteText writes r as a table followed by its violations
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "scenario\trequests\terrors\treq/s\tp50\tp95\tp99\tmax\t")
+	for _, result := range r.Results {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\t\n",
+			result.Scenario, result.Requests, result.Errors, result.Throughput(),
+			round(result.P50), round(result.P95), round(result.P99), round(result.Max))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, violation := range r.Violations {
+		if _, err := fmt.Fprintln(w, "FAIL", violation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteJSON writes r as JSON, for keeping as a CI artifact
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func round(d time.Duration) time.Duration {
+	return d.Round(10 * time.Microsecond)
+}
@@ -0,0 +1,295 @@
+package loadtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Target is a deployment the scenarios run against
+type Target struct {
+	APIURL string
+	// API calls the API server as a signed-in client; Visitor follows
+	// short links like a browser, without following their redirects
+	API     *http.Client
+	Visitor *http.Client
+	// PurgeCache empties the link cache before RedirectCold
+	PurgeCache func(ctx context.Context) error
+}
+
+// NewTarget returns a Target of the API server at apiURL, sending token as
+// a bearer token if set. Short links are followed where the API says they
+// are, its SHORT_URL_BASE. The cache is purged with POST
+// /admin/cache/purge, which needs the admin scope.
+func NewTarget(ctx context.Context, apiURL, token string, workers int) (*Target, error) {
+	apiURL = strings.TrimSuffix(apiURL, "/")
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = max(workers, 100)
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	api := &http.Client{Jar: jar, Transport: transport, Timeout: 30 * time.Second}
+
+	// The CSRF token is bound to the session cookie set with it
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL+"/v1/csrf-token", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := api.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("requesting CSRF token: %w", err)
+	}
+	defer resp.Body.Close()
+	var body struct {
+		CSRFToken string `json:"csrf_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding CSRF token: %w", err)
+	}
+	headers := http.Header{"X-CSRF-Token": {body.CSRFToken}}
+	if token != "" {
+		headers.Set("Authorization", "Bearer "+token)
+	}
+	api.Transport = headerTransport{headers: headers, next: transport}
+
+	t := &Target{
+		APIURL: apiURL,
+		API:    api,
+		Visitor: &http.Client{
+			Transport: transport,
+			Timeout:   30 * time.Second,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+	t.PurgeCache = func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiURL+"/admin/cache/purge", strings.NewReader(`{"all": true}`))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := t.API.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("purging the cache: %s", resp.Status)
+		}
+		return nil
+	}
+	return t, nil
+}
+
+// headerTransport adds headers to every request
+type headerTransport struct {
+	headers http.Header
+	next    http.RoundTripper
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range t.headers {
+		req.Header[name] = values
+	}
+	return t.next.RoundTrip(req)
+}
+
+// Scenarios returns the scenarios of cfg's load against t, in the order to
+// run them: Create, RedirectHot, RedirectCold and Stats
+func Scenarios(t *Target, cfg Config) []Scenario {
+	return []Scenario{
+		Create(t),
+		RedirectHot(t, 10),
+		RedirectCold(t, cfg.Requests()),
+		Stats(t, 10),
+	}
+}
+
+// Create times creating links, each to a destination of its own
+func Create(t *Target) Scenario {
+	run := time.Now().UnixNano()
+	return Scenario{
+		Name:   "create",
+		Client: t.API,
+		Expect: http.StatusCreated,
+		Request: func(ctx context.Context, i int) (*http.Request, error) {
+			return t.createRequest(ctx, fmt.Sprintf("https://example.com/loadtest/%d/create/%d", run, i))
+		},
+	}
+}
+
+// RedirectHot times redirects of links links, each followed once before, so
+// served from the cache
+func RedirectHot(t *Target, links int) Scenario {
+	var created []createdLink
+	return Scenario{
+		Name:   "redirect_hot",
+		Client: t.Visitor,
+		Expect: http.StatusFound,
+		Setup: func(ctx context.Context) error {
+			var err error
+			if created, err = t.createLinks(ctx, "hot", links); err != nil {
+				return err
+			}
+			for _, link := range created {
+				if err := t.visit(ctx, link.ShortURL); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Request: func(ctx context.Context, i int) (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, http.MethodGet, created[i%len(created)].ShortURL, nil)
+		},
+	}
+}
+
+// RedirectCold times redirects of links links, as many as the requests, each
+// followed once with the cache purged, so read from the database
+func RedirectCold(t *Target, links int) Scenario {
+	var created []createdLink
+	return Scenario{
+		Name:   "redirect_cold",
+		Client: t.Visitor,
+		Expect: http.StatusFound,
+		Setup: func(ctx context.Context) error {
+			var err error
+			if created, err = t.createLinks(ctx, "cold", links); err != nil {
+				return err
+			}
+			return t.PurgeCache(ctx)
+		},
+		Request: func(ctx context.Context, i int) (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, http.MethodGet, created[i%len(created)].ShortURL, nil)
+		},
+	}
+}
+
+// Stats times reading the click stats of links links
+func Stats(t *Target, links int) Scenario {
+	var created []createdLink
+	return Scenario{
+		Name:   "stats",
+		Client: t.API,
+		Expect: http.StatusOK,
+		Setup: func(ctx context.Context) error {
+			var err error
+			created, err = t.createLinks(ctx, "stats", links)
+			return err
+		},
+		Request: func(ctx context.Context, i int) (*http.Request, error) {
+			url := t.APIURL + "/v1/links/" + created[i%len(created)].Code + "/stats"
+			return http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		},
+	}
+}
+
+type createdLink struct {
+	Code     string `json:"code"`
+	ShortURL string `json:"short_url"`
+}
+
+func (t *Target) createRequest(ctx context.Context, longURL string) (*http.Request, error) {
+	body, err := json.Marshal(map[string]any{"long_url": longURL, "redirect_type": http.StatusFound})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.APIURL+"/v1/links", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// setupWorkers is how many links are created at once for a scenario
+const setupWorkers = 16
+
+// createLinks creates n links for scenario
+func (t *Target) createLinks(ctx context.Context, scenario string, n int) ([]createdLink, error) {
+	run := time.Now().UnixNano()
+	created := make([]createdLink, n)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	indexes := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	for range min(setupWorkers, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				err := t.createLink(ctx, fmt.Sprintf("https://example.com/loadtest/%d/%s/%d", run, scenario, i), &created[i])
+				if err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+						cancel()
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range n {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	close(indexes)
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return created, ctx.Err()
+}
+
+// createLink creates a link to longURL into created
+func (t *Target) createLink(ctx context.Context, longURL string, created *createdLink) error {
+	req, err := t.createRequest(ctx, longURL)
+	if err != nil {
+		return err
+	}
+	resp, err := t.API.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("creating a link: %s", resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(created)
+}
+
+// visit follows shortURL once
+func (t *Target) visit(ctx context.Context, shortURL string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, shortURL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.Visitor.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		return fmt.Errorf("following %s: %s", shortURL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAPI serves the endpoints the scenarios use, checking they are called
+// as the API server requires
+type fakeAPI struct {
+	mu      sync.Mutex
+	links   map[string]bool
+	purges  int
+	denied  int
+	visited map[string]int
+}
+
+func newFakeAPI(t *testing.T) (*fakeAPI, *httptest.Server) {
+	api := &fakeAPI{links: map[string]bool{}, visited: map[string]int{}}
+	mux := http.NewServeMux()
+	var server *httptest.Server
+	authorized := func(r *http.Request) bool {
+		cookie, err := r.Cookie("session_id")
+		ok := err == nil && cookie.Value == "session" &&
+			r.Header.Get("X-CSRF-Token") == "csrf" && r.Header.Get("Authorization") == "Bearer token"
+		if !ok {
+			api.mu.Lock()
+			api.denied++
+			api.mu.Unlock()
+		}
+		return ok
+	}
+	mux.HandleFunc("GET /v1/csrf-token", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session_id", Value: "session", Path: "/"})
+		json.NewEncoder(w).Encode(map[string]string{"csrf_token": "csrf"})
+	})
+	mux.HandleFunc("POST /v1/links", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		api.mu.Lock()
+		code := fmt.Sprintf("c%d", len(api.links))
+		api.links[code] = true
+		api.mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]string{"code": code, "short_url": server.URL + "/r/" + code})
+	})
+	mux.HandleFunc("GET /v1/links/{code}/stats", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{}`))
+	})
+	mux.HandleFunc("GET /r/{code}", func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		api.visited[r.PathValue("code")]++
+		api.mu.Unlock()
+		http.Redirect(w, r, "https://example.com", http.StatusFound)
+	})
+	mux.HandleFunc("POST /admin/cache/purge", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(r) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		api.mu.Lock()
+		api.purges++
+		api.mu.Unlock()
+		w.Write([]byte(`{"purged": 1}`))
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return api, server
+}
+
+func TestScenarios(t *testing.T) {
+	api, server := newFakeAPI(t)
+	ctx := context.Background()
+	target, err := NewTarget(ctx, server.URL+"/", "token", 10)
+	require.NoError(t, err)
+
+	cfg := Config{Rate: 100, Duration: 200 * time.Millisecond}
+	var results []*Result
+	for _, scenario := range Scenarios(target, cfg) {
+		result, err := Run(ctx, cfg, scenario)
+		require.NoError(t, err)
+		results = append(results, result)
+	}
+
+	for _, result := range results {
+		assert.Equal(t, 20, result.Requests, result.Scenario)
+		assert.Zero(t, result.Errors, "%s: %v", result.Scenario, result.Statuses)
+	}
+	assert.Zero(t, api.denied)
+	assert.Equal(t, 1, api.purges)
+	// Create makes 20 links, RedirectHot 10, RedirectCold one per request
+	// and Stats 10
+	assert.Len(t, api.links, 60)
+	for code, visits := range api.visited {
+		assert.LessOrEqual(t, visits, 3, code)
+	}
+}
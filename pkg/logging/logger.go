@@ -2,14 +2,18 @@ package logging
 
 import (
 	"context"
+	"io"
 	"log/slog"
 	"os"
 
+	"url-shortener/pkg/tenant"
+
 	"github.com/google/uuid"
 )
 
 type Logger struct {
 	*slog.Logger
+	level *slog.LevelVar
 }
 
 type LogLevel string
@@ -27,28 +31,65 @@ type contextKey string
 const correlationIDKey contextKey = "correlation_id"
 
 func NewLogger(level LogLevel) *Logger {
-	var slogLevel slog.Level
+	return NewLoggerTo(level, os.Stdout)
+}
+
+// NewLoggerTo writes JSON lines to w, such as the outputs from OpenOutputs
+func NewLoggerTo(level LogLevel, w io.Writer) *Logger {
+	levelVar := new(slog.LevelVar)
+	levelVar.Set(toSlogLevel(level))
+
+	opts := &slog.HandlerOptions{
+		Level: levelVar,
+	}
+
+	handler := slog.NewJSONHandler(w, opts)
+	logger := slog.New(handler)
+
+	return &Logger{Logger: logger, level: levelVar}
+}
+
+// SetLevel changes the minimum level at runtime
+func (l *Logger) SetLevel(level LogLevel) {
+	l.level.Set(toSlogLevel(level))
+}
+
+// Level is the current minimum level
+func (l *Logger) Level() LogLevel {
+	switch l.level.Level() {
+	case slog.LevelDebug:
+		return LevelDebug
+	case slog.LevelWarn:
+		return LevelWarn
+	case slog.LevelError:
+		return LevelError
+	default:
+		return LevelInfo
+	}
+}
+
+// Valid reports whether level is one of the known levels
+func (level LogLevel) Valid() bool {
+	switch level {
+	case LevelDebug, LevelInfo, LevelWarn, LevelError:
+		return true
+	}
+	return false
+}
+
+func toSlogLevel(level LogLevel) slog.Level {
 	switch level {
 	case LevelDebug:
-		slogLevel = slog.LevelDebug
+		return slog.LevelDebug
 	case LevelInfo:
-		slogLevel = slog.LevelInfo
+		return slog.LevelInfo
 	case LevelWarn:
-		slogLevel = slog.LevelWarn
+		return slog.LevelWarn
 	case LevelError:
-		slogLevel = slog.LevelError
+		return slog.LevelError
 	default:
-		slogLevel = slog.LevelInfo
-	}
-
-	opts := &slog.HandlerOptions{
-		Level: slogLevel,
+		return slog.LevelInfo
 	}
-
-	handler := slog.NewJSONHandler(os.Stdout, opts)
-	logger := slog.New(handler)
-
-	return &Logger{Logger: logger}
 }
 
 // WithCorrelationID adds a correlation ID to the context
@@ -68,36 +109,35 @@ func GetCorrelationID(ctx context.Context) string {
 	return ""
 }
 
-// Debug logs debug level messages with correlation ID
-func (l *Logger) Debug(ctx context.Context, msg string, args ...any) {
+// contextArgs adds the correlation ID and tenant of ctx, when set, to args
+func contextArgs(ctx context.Context, args []any) []any {
 	if correlationID := GetCorrelationID(ctx); correlationID != "" {
 		args = append(args, "correlation_id", correlationID)
 	}
-	l.Logger.Debug(msg, args...)
+	if tenantID := tenant.FromContext(ctx); tenantID != "" {
+		args = append(args, "tenant_id", tenantID)
+	}
+	return args
+}
+
+// Debug logs debug level messages with correlation ID
+func (l *Logger) Debug(ctx context.Context, msg string, args ...any) {
+	l.Logger.Debug(msg, contextArgs(ctx, args)...)
 }
 
 // Info logs info level messages with correlation ID
 func (l *Logger) Info(ctx context.Context, msg string, args ...any) {
-	if correlationID := GetCorrelationID(ctx); correlationID != "" {
-		args = append(args, "correlation_id", correlationID)
-	}
-	l.Logger.Info(msg, args...)
+	l.Logger.Info(msg, contextArgs(ctx, args)...)
 }
 
 // Warn logs warn level messages with correlation ID
 func (l *Logger) Warn(ctx context.Context, msg string, args ...any) {
-	if correlationID := GetCorrelationID(ctx); correlationID != "" {
-		args = append(args, "correlation_id", correlationID)
-	}
-	l.Logger.Warn(msg, args...)
+	l.Logger.Warn(msg, contextArgs(ctx, args)...)
 }
 
 // Error logs error level messages with correlation ID
 func (l *Logger) Error(ctx context.Context, msg string, args ...any) {
-	if correlationID := GetCorrelationID(ctx); correlationID != "" {
-		args = append(args, "correlation_id", correlationID)
-	}
-	l.Logger.Error(msg, args...)
+	l.Logger.Error(msg, contextArgs(ctx, args)...)
 }
 
 // LogLinkOperation logs link operations without sensitive data
@@ -111,6 +151,21 @@ func (l *Logger) LogLinkOperation(ctx context.Context, operation, code string, s
 	)
 }
 
+// LogAdminAction records an operator action on target for the audit
+// trail; actor is the operator's sub
+func (l *Logger) LogAdminAction(ctx context.Context, action, actor, target string, args ...any) {
+	args = append([]any{
+		"action", action,
+		"actor", actor,
+		"target", target,
+		"correlation_id", GetCorrelationID(ctx),
+	}, args...)
+	if tenantID := tenant.FromContext(ctx); tenantID != "" {
+		args = append(args, "tenant_id", tenantID)
+	}
+	l.Logger.Info("admin action", args...)
+}
+
 // LogURLValidation logs URL validation without the actual URL
 func (l *Logger) LogURLValidation(ctx context.Context, valid bool, scheme string) {
 	correlationID := GetCorrelationID(ctx)
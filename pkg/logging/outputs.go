@@ -0,0 +1,86 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// OpenOutputs opens the log destinations named in specs, each one of:
+//
+//	stdout, stderr
+//	file:PATH            a file rotated as set by file
+//	syslog               the local syslog daemon
+//	syslog+udp://ADDR    a remote syslog server, or syslog+tcp://ADDR
+//
+// Records go to all of them; tag names the process to syslog. With no specs,
+// logs go to stdout.
+func OpenOutputs(specs []string, tag string, file FileOptions) (io.WriteCloser, error) {
+	if len(specs) == 0 {
+		specs = []string{"stdout"}
+	}
+	outputs := &multiOutput{}
+	for _, spec := range specs {
+		w, err := openOutput(spec, tag, file)
+		if err != nil {
+			outputs.Close()
+			return nil, fmt.Errorf("%s: %w", spec, err)
+		}
+		outputs.writers = append(outputs.writers, w)
+	}
+	if len(outputs.writers) == 1 {
+		return outputs.writers[0], nil
+	}
+	return outputs, nil
+}
+
+func openOutput(spec, tag string, file FileOptions) (io.WriteCloser, error) {
+	switch {
+	case spec == "stdout":
+		return nopCloser{os.Stdout}, nil
+	case spec == "stderr":
+		return nopCloser{os.Stderr}, nil
+	case strings.HasPrefix(spec, "file:"):
+		return openRotatingFile(strings.TrimPrefix(spec, "file:"), file)
+	case spec == "syslog":
+		return openSyslog("", "", tag)
+	case strings.HasPrefix(spec, "syslog+udp://"):
+		return openSyslog("udp", strings.TrimPrefix(spec, "syslog+udp://"), tag)
+	case strings.HasPrefix(spec, "syslog+tcp://"):
+		return openSyslog("tcp", strings.TrimPrefix(spec, "syslog+tcp://"), tag)
+	}
+	return nil, errors.New("must be stdout, stderr, file:PATH, syslog, syslog+udp://ADDR or syslog+tcp://ADDR")
+}
+
+// multiOutput writes each record to every writer, so that one failing
+// doesn't keep records from the others
+type multiOutput struct {
+	writers []io.WriteCloser
+}
+
+func (m *multiOutput) Write(p []byte) (int, error) {
+	var errs []error
+	for _, w := range m.writers {
+		if _, err := w.Write(p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return len(p), errors.Join(errs...)
+}
+
+func (m *multiOutput) Close() error {
+	var errs []error
+	for _, w := range m.writers {
+		errs = append(errs, w.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// nopCloser leaves the standard streams open
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,159 @@
+package logging
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redaction rules rewrite log attributes before they are written, so that
+// what lands in the logs can be tuned by configuration. A rule names an
+// attribute key, or * for every key, an action, and optionally the lowest
+// level it applies at, as key:action[:level]. The first rule matching an
+// attribute applies, so specific keys go before *. Attributes inside groups
+// are matched by their own key, and those added with With get the first
+// matching rule whatever the level.
+
+// RedactAction is what a redaction rule does to an attribute
+type RedactAction string
+
+const (
+	// RedactDrop leaves the attribute out
+	RedactDrop RedactAction = "drop"
+	// RedactHash replaces the value with a keyed hash, so that records can
+	// still be matched up without showing it
+	RedactHash RedactAction = "hash"
+	// RedactMask masks the email addresses in the value
+	RedactMask RedactAction = "mask"
+)
+
+// Redaction is one redaction rule
+type Redaction struct {
+	Key    string
+	Action RedactAction
+	// MinLevel is the lowest level the rule applies at; empty for all
+	MinLevel LogLevel
+}
+
+// ParseRedactions parses rules written as key:action[:level]
+func ParseRedactions(specs []string) ([]Redaction, error) {
+	rules := make([]Redaction, 0, len(specs))
+	for _, spec := range specs {
+		parts := strings.Split(spec, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+			return nil, fmt.Errorf("%q is not key:action[:level]", spec)
+		}
+		rule := Redaction{Key: parts[0], Action: RedactAction(parts[1])}
+		switch rule.Action {
+		case RedactDrop, RedactHash, RedactMask:
+		default:
+			return nil, fmt.Errorf("%q: action must be drop, hash or mask", spec)
+		}
+		if len(parts) == 3 {
+			rule.MinLevel = LogLevel(parts[2])
+			if !rule.MinLevel.Valid() {
+				return nil, fmt.Errorf("%q: level must be debug, info, warn or error", spec)
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// UseRedaction applies rules to everything l logs from now on. Hashes are
+// keyed with key; without one a random key is used, so hashes only match up
+// within this process's logs.
+func (l *Logger) UseRedaction(rules []Redaction, key []byte) {
+	if len(rules) == 0 {
+		return
+	}
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		rand.Read(key)
+	}
+	l.Logger = slog.New(&redactHandler{next: l.Logger.Handler(), rules: rules, key: key})
+}
+
+// redactHandler applies redaction rules to the records it passes on to next
+type redactHandler struct {
+	next  slog.Handler
+	rules []Redaction
+	key   []byte
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(h.redact(a, r.Level))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = h.redact(a, anyLevel)
+	}
+	return &redactHandler{next: h.next.WithAttrs(redacted), rules: h.rules, key: h.key}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name), rules: h.rules, key: h.key}
+}
+
+// anyLevel stands for the level of attributes that go on every record
+const anyLevel = slog.LevelDebug - 1
+
+// redact applies the first rule matching a at level
+func (h *redactHandler) redact(a slog.Attr, level slog.Level) slog.Attr {
+	a.Value = a.Value.Resolve()
+	if a.Value.Kind() == slog.KindGroup {
+		group := a.Value.Group()
+		redacted := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			redacted[i] = h.redact(ga, level)
+		}
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(redacted...)}
+	}
+	for _, rule := range h.rules {
+		if rule.Key != "*" && rule.Key != a.Key {
+			continue
+		}
+		if rule.MinLevel != "" && level != anyLevel && level < toSlogLevel(rule.MinLevel) {
+			continue
+		}
+		switch rule.Action {
+		case RedactDrop:
+			return slog.Attr{}
+		case RedactHash:
+			mac := hmac.New(sha256.New, h.key)
+			mac.Write([]byte(a.Value.String()))
+			return slog.String(a.Key, hex.EncodeToString(mac.Sum(nil))[:16])
+		case RedactMask:
+			if s := a.Value.String(); emailPattern.MatchString(s) {
+				return slog.String(a.Key, maskEmails(s))
+			}
+		}
+		return a
+	}
+	return a
+}
+
+var emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+-])[A-Za-z0-9._%+-]*@([A-Za-z0-9.-]+\.[A-Za-z]{2,})`)
+
+// maskEmails keeps the first character and the domain of each email address
+// in s, as in j***@example.com
+func maskEmails(s string) string {
+	return emailPattern.ReplaceAllString(s, "$1***@$2")
+}
@@ -0,0 +1,69 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRedactions(t *testing.T) {
+	rules, err := ParseRedactions([]string{"long_url:drop", "code:hash:info", "*:mask"})
+	require.NoError(t, err)
+	assert.Equal(t, []Redaction{
+		{Key: "long_url", Action: RedactDrop},
+		{Key: "code", Action: RedactHash, MinLevel: LevelInfo},
+		{Key: "*", Action: RedactMask},
+	}, rules)
+
+	for _, spec := range []string{"long_url", "code:encrypt", "code:hash:trace", ":drop"} {
+		_, err := ParseRedactions([]string{spec})
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	var buf bytes.Buffer
+	level := new(slog.LevelVar)
+	level.Set(slog.LevelDebug)
+	l := &Logger{Logger: slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: level})), level: level}
+	rules, err := ParseRedactions([]string{"long_url:drop", "code:hash:info", "*:mask"})
+	require.NoError(t, err)
+	l.UseRedaction(rules, []byte("secret"))
+	ctx := context.Background()
+
+	entry := func() map[string]any {
+		var m map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+		buf.Reset()
+		return m
+	}
+
+	l.Info(ctx, "link created", "code", "launch", "long_url", "https://example.com/private", "owner", "jane.doe@example.com")
+	m := entry()
+	assert.NotContains(t, m, "long_url")
+	assert.Len(t, m["code"], 16)
+	assert.NotEqual(t, "launch", m["code"])
+	assert.Equal(t, "j***@example.com", m["owner"])
+	hashed := m["code"]
+
+	l.Warn(ctx, "link disabled", "code", "launch", "reason", "reported by ops@example.org and x@example.net")
+	m = entry()
+	assert.Equal(t, hashed, m["code"], "hashes match up across records")
+	assert.Equal(t, "reported by o***@example.org and x***@example.net", m["reason"])
+
+	// Below the rule's level codes are logged as they are
+	l.Debug(ctx, "cache miss", "code", "launch", "count", 3)
+	m = entry()
+	assert.Equal(t, "launch", m["code"])
+	assert.EqualValues(t, 3, m["count"])
+
+	l.Logger.With("long_url", "https://example.com").Debug("grouped", slog.Group("link", "long_url", "https://example.com", "code", "launch"))
+	m = entry()
+	assert.NotContains(t, m, "long_url")
+	assert.Equal(t, map[string]any{"code": "launch"}, m["link"])
+}
@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileOptions bounds the log files written by file outputs
+type FileOptions struct {
+	// MaxSize is how large a file may grow, in bytes, before it is rotated
+	MaxSize int64
+	// MaxAge is how long rotated files are kept; 0 keeps them whatever
+	// their age
+	MaxAge time.Duration
+	// MaxBackups is how many rotated files are kept; 0 keeps them all
+	MaxBackups int
+}
+
+// rotatedTimeFormat suffixes rotated files, so that they sort by age
+const rotatedTimeFormat = "20060102T150405.000"
+
+// rotatingFile appends to path until a record would take it past MaxSize,
+// then renames it to path.TIME and starts a new one
+type rotatingFile struct {
+	path string
+	opts FileOptions
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func openRotatingFile(path string, opts FileOptions) (*rotatingFile, error) {
+	if path == "" {
+		return nil, os.ErrInvalid
+	}
+	f := &rotatingFile{path: path, opts: opts}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	f.prune(time.Now())
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.opts.MaxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.opts.MaxSize {
+		if err := f.rotate(time.Now()); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate moves the current file aside and opens a new one; the caller must
+// hold f.mu
+func (f *rotatingFile) rotate(now time.Time) error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.path, f.path+"."+now.UTC().Format(rotatedTimeFormat)); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.prune(now)
+	return nil
+}
+
+// prune removes the rotated files beyond MaxBackups or older than MaxAge
+func (f *rotatingFile) prune(now time.Time) {
+	rotated, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return
+	}
+	rotated = slices.DeleteFunc(rotated, func(name string) bool {
+		_, err := time.Parse(rotatedTimeFormat, strings.TrimPrefix(name, f.path+"."))
+		return err != nil
+	})
+	// Newest first
+	slices.Sort(rotated)
+	slices.Reverse(rotated)
+	for i, name := range rotated {
+		stamp, _ := time.Parse(rotatedTimeFormat, strings.TrimPrefix(name, f.path+"."))
+		if (f.opts.MaxBackups > 0 && i >= f.opts.MaxBackups) || (f.opts.MaxAge > 0 && now.Sub(stamp) > f.opts.MaxAge) {
+			os.Remove(name)
+		}
+	}
+}
+
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
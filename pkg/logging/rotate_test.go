@@ -0,0 +1,57 @@
+package logging
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.log")
+	old := path + "." + time.Now().Add(-48*time.Hour).UTC().Format(rotatedTimeFormat)
+	require.NoError(t, os.WriteFile(old, []byte("old\n"), 0o644))
+
+	f, err := openRotatingFile(path, FileOptions{MaxSize: 10, MaxAge: 24 * time.Hour, MaxBackups: 2})
+	require.NoError(t, err)
+	defer f.Close()
+	assert.NoFileExists(t, old, "rotated files past MaxAge are removed")
+
+	line := []byte("record\n")
+	for i := 0; i < 4; i++ {
+		_, err := f.Write(line)
+		require.NoError(t, err)
+		time.Sleep(2 * time.Millisecond)
+	}
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "record\n", string(data), "a record that doesn't fit starts a new file")
+
+	rotated, err := filepath.Glob(path + ".*")
+	require.NoError(t, err)
+	assert.Len(t, rotated, 2, "only MaxBackups rotated files are kept")
+	for _, name := range rotated {
+		data, err := os.ReadFile(name)
+		require.NoError(t, err)
+		assert.Equal(t, 1, strings.Count(string(data), "record"))
+	}
+}
+
+func TestOpenOutputs(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api.log")
+	w, err := OpenOutputs([]string{"stderr", "file:" + path}, "test", FileOptions{})
+	require.NoError(t, err)
+	logger := NewLoggerTo(LevelInfo, w)
+	logger.Logger.Info("hello")
+	require.NoError(t, w.Close())
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"msg":"hello"`)
+
+	_, err = OpenOutputs([]string{"kafka://logs"}, "test", FileOptions{})
+	assert.ErrorContains(t, err, "kafka://logs")
+}
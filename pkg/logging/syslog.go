@@ -0,0 +1,45 @@
+//go:build !windows && !plan9
+
+package logging
+
+import (
+	"bytes"
+	"io"
+	"log/syslog"
+)
+
+// syslogWriter sends each JSON record to syslog at the severity of its level
+type syslogWriter struct {
+	w *syslog.Writer
+}
+
+func openSyslog(network, addr, tag string) (io.WriteCloser, error) {
+	w, err := syslog.Dial(network, addr, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	if err != nil {
+		return nil, err
+	}
+	return &syslogWriter{w: w}, nil
+}
+
+func (s *syslogWriter) Write(p []byte) (int, error) {
+	msg := string(bytes.TrimSuffix(p, []byte("\n")))
+	var err error
+	switch {
+	case bytes.Contains(p, []byte(`"level":"ERROR"`)):
+		err = s.w.Err(msg)
+	case bytes.Contains(p, []byte(`"level":"WARN"`)):
+		err = s.w.Warning(msg)
+	case bytes.Contains(p, []byte(`"level":"DEBUG"`)):
+		err = s.w.Debug(msg)
+	default:
+		err = s.w.Info(msg)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *syslogWriter) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,144 @@
+// Package metrics serves runtime measurements in the Prometheus text format.
+// Values are sampled on a schedule rather than when scraped, so a scrape
+// costs a map read and never waits on what it reports on, such as a
+// saturated connection pool.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Metric types
+const (
+	Gauge   = "gauge"
+	Counter = "counter"
+)
+
+// Registry holds the last sampled value of each metric
+type Registry struct {
+	mu      sync.RWMutex
+	metrics map[string]*metric
+	sources []func(*Registry)
+}
+
+type metric struct {
+	kind string
+	help string
+	// series are the values by their rendered labels, "" for none
+	series map[string]float64
+}
+
+// Labels name one series of a metric, e.g. {"tenant": "acme"}
+type Labels map[string]string
+
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]*metric)}
+}
+
+// Set records value for name. kind and help describe the metric.
+func (r *Registry) Set(name, kind, help string, value float64) {
+	r.SetLabeled(name, kind, help, nil, value)
+}
+
+// SetLabeled records value for the series of name with labels, keeping the
+// metric's other series
+func (r *Registry) SetLabeled(name, kind, help string, labels Labels, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	m := r.metrics[name]
+	if m == nil {
+		m = &metric{series: make(map[string]float64)}
+		r.metrics[name] = m
+	}
+	m.kind, m.help = kind, help
+	m.series[labels.String()] = value
+}
+
+// String renders labels as in the text format, sorted by name, or "" when
+// there are none
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", name, labelEscaper.Replace(l[name]))
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Add samples source on every Sample
+func (r *Registry) Add(source func(*Registry)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sources = append(r.sources, source)
+}
+
+// Sample has every source record its current values
+func (r *Registry) Sample() {
+	r.mu.RLock()
+	sources := r.sources
+	r.mu.RUnlock()
+	for _, source := range sources {
+		source(r)
+	}
+}
+
+// Run samples every interval until ctx is done
+func (r *Registry) Run(ctx context.Context, interval time.Duration) {
+	r.Sample()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Sample()
+		}
+	}
+}
+
+// ServeHTTP writes the last sampled values in the Prometheus text format
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	for _, name := range names {
+		m := r.metrics[name]
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, m.help, name, m.kind)
+		series := make([]string, 0, len(m.series))
+		for labels := range m.series {
+			series = append(series, labels)
+		}
+		sort.Strings(series)
+		for _, labels := range series {
+			fmt.Fprintf(w, "%s%s %s\n", name, labels, strconv.FormatFloat(m.series[labels], 'g', -1, 64))
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryServesSampledValues(t *testing.T) {
+	r := NewRegistry()
+	acquired := 3.0
+	r.Add(func(r *Registry) {
+		r.Set("db_pool_acquired_conns", Gauge, "Postgres connections in use.", acquired)
+		r.Set("db_pool_acquire_wait_seconds_total", Counter, "Time spent waiting for a Postgres connection.", 0.25)
+	})
+
+	scrape := func() string {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return rec.Body.String()
+	}
+	assert.Empty(t, scrape())
+
+	r.Sample()
+	assert.Equal(t, `# HELP db_pool_acquire_wait_seconds_total Time spent waiting for a Postgres connection.
+# TYPE db_pool_acquire_wait_seconds_total counter
+db_pool_acquire_wait_seconds_total 0.25
+# HELP db_pool_acquired_conns Postgres connections in use.
+# TYPE db_pool_acquired_conns gauge
+db_pool_acquired_conns 3
+`, scrape())
+
+	// Scrapes show the last sample, not the current value
+	acquired = 7
+	assert.Contains(t, scrape(), "db_pool_acquired_conns 3\n")
+	r.Sample()
+	assert.Contains(t, scrape(), "db_pool_acquired_conns 7\n")
+}
+
+func TestRegistryServesLabeledSeries(t *testing.T) {
+	r := NewRegistry()
+	r.SetLabeled("link_clicks_total", Counter, "Clicks counted.", Labels{"tenant": "globex"}, 2)
+	r.SetLabeled("link_clicks_total", Counter, "Clicks counted.", Labels{"tenant": "acme"}, 5)
+	r.SetLabeled("link_clicks_total", Counter, "Clicks counted.", Labels{"tenant": `a"b`}, 1)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, `# HELP link_clicks_total Clicks counted.
+# TYPE link_clicks_total counter
+link_clicks_total{tenant="a\"b"} 1
+link_clicks_total{tenant="acme"} 5
+link_clicks_total{tenant="globex"} 2
+`, rec.Body.String())
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+)
+
+// PostgresPool samples pool's connections and how long acquiring one waits.
+// Acquires that found no idle connection had to wait for one to be opened
+// or released; when they grow with acquired_conns at max_conns, the pool is
+// saturated.
+func PostgresPool(pool *pgxpool.Pool) func(*Registry) {
+	return func(r *Registry) {
+		stat := pool.Stat()
+		r.Set("db_pool_acquired_conns", Gauge, "Postgres connections in use.", float64(stat.AcquiredConns()))
+		r.Set("db_pool_idle_conns", Gauge, "Idle Postgres connections.", float64(stat.IdleConns()))
+		r.Set("db_pool_constructing_conns", Gauge, "Postgres connections being opened.", float64(stat.ConstructingConns()))
+		r.Set("db_pool_total_conns", Gauge, "Open Postgres connections.", float64(stat.TotalConns()))
+		r.Set("db_pool_max_conns", Gauge, "Most Postgres connections the pool opens.", float64(stat.MaxConns()))
+		r.Set("db_pool_acquires_total", Counter, "Postgres connections acquired.", float64(stat.AcquireCount()))
+		r.Set("db_pool_empty_acquires_total", Counter, "Postgres connection acquires that found no idle connection and waited.", float64(stat.EmptyAcquireCount()))
+		r.Set("db_pool_canceled_acquires_total", Counter, "Postgres connection acquires canceled while waiting.", float64(stat.CanceledAcquireCount()))
+		r.Set("db_pool_acquire_wait_seconds_total", Counter, "Time spent waiting for a Postgres connection.", stat.EmptyAcquireWaitTime().Seconds())
+	}
+}
+
+// RedisPool samples client's connections and how often getting one waited
+// or timed out
+func RedisPool(client *redis.Client) func(*Registry) {
+	return func(r *Registry) {
+		stats := client.PoolStats()
+		r.Set("redis_pool_total_conns", Gauge, "Open Redis connections.", float64(stats.TotalConns))
+		r.Set("redis_pool_idle_conns", Gauge, "Idle Redis connections.", float64(stats.IdleConns))
+		r.Set("redis_pool_max_conns", Gauge, "Most Redis connections the pool opens.", float64(client.Options().PoolSize))
+		r.Set("redis_pool_hits_total", Counter, "Redis connections taken from the idle ones.", float64(stats.Hits))
+		r.Set("redis_pool_misses_total", Counter, "Redis connections that had to be opened or waited for.", float64(stats.Misses))
+		r.Set("redis_pool_waits_total", Counter, "Redis connection gets that waited for a connection.", float64(stats.WaitCount))
+		r.Set("redis_pool_wait_seconds_total", Counter, "Time spent waiting for a Redis connection.", float64(stats.WaitDurationNs)/1e9)
+		r.Set("redis_pool_timeouts_total", Counter, "Redis connection gets that timed out waiting.", float64(stats.Timeouts))
+		r.Set("redis_pool_stale_conns_total", Counter, "Stale Redis connections closed.", float64(stats.StaleConns))
+	}
+}
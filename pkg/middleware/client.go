@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+)
+
+type clientInfoKey struct{}
+
+// ClientInfo is where a request came from. Links record it with the
+// credentials used when they are created, for abuse investigations.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// RecordClient notes each request's client address and user agent in its
+// context for ClientFromContext. Install it after RealClient, so that the
+// address is the client's rather than a proxy's.
+func RecordClient(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := ClientInfo{UserAgent: r.UserAgent()}
+		if addr, ok := remoteAddr(r); ok {
+			info.IP = addr.String()
+		}
+		next.ServeHTTP(w, r.WithContext(WithClient(r.Context(), info)))
+	})
+}
+
+// WithClient returns a context carrying info, for requests that don't pass
+// through RecordClient (e.g. internal gRPC calls)
+func WithClient(ctx context.Context, info ClientInfo) context.Context {
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+// ClientFromContext returns the client set by RecordClient or WithClient,
+// empty if there is none
+func ClientFromContext(ctx context.Context) ClientInfo {
+	info, _ := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info
+}
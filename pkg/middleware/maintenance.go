@@ -0,0 +1,22 @@
+package middleware
+
+import "net/http"
+
+// maintenanceRetryAfter is the Retry-After, in seconds, of writes refused
+// for maintenance
+const maintenanceRetryAfter = "60"
+
+// Maintenance refuses the requests writes reports as writing, with 503 and
+// the message refusal returns, while refusal reports maintenance mode on
+func Maintenance(writes func(*http.Request) bool, refusal func() (string, bool)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if message, on := refusal(); on && writes(r) {
+				w.Header().Set("Retry-After", maintenanceRetryAfter)
+				http.Error(w, message, http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
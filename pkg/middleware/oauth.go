@@ -7,6 +7,10 @@ import (
 	"net/http"
 	"strings"
 
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/session"
+	"url-shortener/pkg/tenant"
+
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/google/uuid"
 )
@@ -18,6 +22,13 @@ type OAuthConfig struct {
 
 type OAuthMiddleware struct {
 	verifier *oidc.IDTokenVerifier
+	sessions session.Store
+	apiKeys  APIKeyVerifier
+	// logger is set with sessions and apiKeys
+	logger *logging.Logger
+
+	ownerLimiter *RateLimiter
+	ownerLimit   func(ctx context.Context, ownerID uuid.UUID) int
 }
 
 type AuthClaims struct {
@@ -25,6 +36,11 @@ type AuthClaims struct {
 	Email  string   `json:"email"`
 	Scope  string   `json:"scope"`
 	Groups []string `json:"groups,omitempty"`
+	// Tenant is the tenant the token was issued for, if the deployment
+	// serves several
+	Tenant string `json:"tenant,omitempty"`
+	// AuthorizedParty is the OAuth client the token was issued to
+	AuthorizedParty string `json:"azp,omitempty"`
 }
 
 func NewOAuthMiddleware(config OAuthConfig) (*OAuthMiddleware, error) {
@@ -44,10 +60,29 @@ func NewOAuthMiddleware(config OAuthConfig) (*OAuthMiddleware, error) {
 	}, nil
 }
 
+// UseSessions lets requests without an Authorization header authenticate
+// with a browser session cookie established by the login flow, logging
+// sessions that can't be looked up to logger
+func (m *OAuthMiddleware) UseSessions(store session.Store, logger *logging.Logger) {
+	m.sessions = store
+	m.logger = logger
+}
+
+// UseOwnerRateLimit limits each authenticated owner to limit(ownerID)
+// requests per minute, 0 meaning unlimited, on top of the per-client limit
+func (m *OAuthMiddleware) UseOwnerRateLimit(limit func(ctx context.Context, ownerID uuid.UUID) int) {
+	m.ownerLimiter = NewRateLimiter(0)
+	m.ownerLimit = limit
+}
+
 func (m *OAuthMiddleware) Authenticate(requiredScopes ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" && m.sessions != nil {
+				m.authenticateSession(w, r, next, requiredScopes)
+				return
+			}
 			if authHeader == "" {
 				http.Error(w, "missing authorization header", http.StatusUnauthorized)
 				return
@@ -88,22 +123,103 @@ func (m *OAuthMiddleware) Authenticate(requiredScopes ...string) func(http.Handl
 				}
 			}
 
-			// Add claims to context
-			ctx := r.Context()
-			ctx = context.WithValue(ctx, "sub", claims.Sub)
-			ctx = context.WithValue(ctx, "email", claims.Email)
-			ctx = context.WithValue(ctx, "scope", claims.Scope)
-
-			// Convert sub to UUID for owner_id
-			if subUUID, err := uuid.Parse(claims.Sub); err == nil {
-				ctx = context.WithValue(ctx, "owner_id", subUUID)
+			if !allowTenant(w, r, claims.Tenant, "token") {
+				return
 			}
 
+			// Add claims to context
+			ctx := withClaims(r.Context(), claims.Sub, claims.Email, claims.Scope)
+			ctx = withTenant(ctx, claims.Tenant)
+			if claims.AuthorizedParty != "" {
+				ctx = WithClientID(ctx, claims.AuthorizedParty)
+			}
+			if !m.allowOwner(w, ctx) {
+				return
+			}
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
+func (m *OAuthMiddleware) authenticateSession(w http.ResponseWriter, r *http.Request, next http.Handler, requiredScopes []string) {
+	cookie, err := r.Cookie(session.CookieName)
+	if err != nil || cookie.Value == "" {
+		http.Error(w, "missing authorization header", http.StatusUnauthorized)
+		return
+	}
+
+	sess, err := m.sessions.Get(r.Context(), cookie.Value)
+	if err != nil {
+		m.logger.Error(r.Context(), "failed to look up session", "error", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if sess == nil {
+		http.Error(w, "session expired", http.StatusUnauthorized)
+		return
+	}
+
+	if len(requiredScopes) > 0 && !m.checkScopes(sess.Scope, requiredScopes) {
+		http.Error(w, "insufficient scope", http.StatusForbidden)
+		return
+	}
+	if !allowTenant(w, r, sess.Tenant, "session") {
+		return
+	}
+
+	ctx := withClaims(r.Context(), sess.Sub, sess.Email, sess.Scope)
+	ctx = withTenant(ctx, sess.Tenant)
+	if !m.allowOwner(w, ctx) {
+		return
+	}
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// allowTenant checks that credentials issued in tenantID are used on their
+// own tenant's domains, answering 403 when the request's host belongs to
+// another tenant. what names the credentials in the error.
+func allowTenant(w http.ResponseWriter, r *http.Request, tenantID, what string) bool {
+	if hostTenant := tenant.FromContext(r.Context()); hostTenant != "" && hostTenant != tenantID {
+		http.Error(w, what+" is for another tenant", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// withTenant scopes ctx to the tenant the credentials were issued in, if any
+func withTenant(ctx context.Context, tenantID string) context.Context {
+	if tenantID == "" {
+		return ctx
+	}
+	return tenant.WithTenant(ctx, tenantID)
+}
+
+// allowOwner applies the per-owner rate limit, answering 429 when it is
+// exceeded
+func (m *OAuthMiddleware) allowOwner(w http.ResponseWriter, ctx context.Context) bool {
+	ownerID := GetOwnerIDFromContext(ctx)
+	if m.ownerLimiter == nil || ownerID == uuid.Nil {
+		return true
+	}
+	allowed, retryAfter := m.ownerLimiter.AllowLimit(ownerID.String(), m.ownerLimit(ctx, ownerID))
+	if !allowed {
+		rateLimited(w, retryAfter)
+	}
+	return allowed
+}
+
+func withClaims(ctx context.Context, sub, email, scope string) context.Context {
+	ctx = context.WithValue(ctx, "sub", sub)
+	ctx = context.WithValue(ctx, "email", email)
+	ctx = context.WithValue(ctx, "scope", scope)
+
+	// Convert sub to UUID for owner_id
+	if subUUID, err := uuid.Parse(sub); err == nil {
+		ctx = context.WithValue(ctx, "owner_id", subUUID)
+	}
+	return ctx
+}
+
 func (m *OAuthMiddleware) parseAndValidateToken(tokenString string) (*oidc.IDToken, error) {
 	return m.verifier.Verify(context.Background(), tokenString)
 }
@@ -177,6 +293,40 @@ func GetScopeFromContext(ctx context.Context) string {
 	return ""
 }
 
+type apiKeyIDContextKey struct{}
+
+// GetAPIKeyIDFromContext returns the API key the request was authenticated
+// with, or uuid.Nil
+func GetAPIKeyIDFromContext(ctx context.Context) uuid.UUID {
+	if keyID, ok := ctx.Value(apiKeyIDContextKey{}).(uuid.UUID); ok {
+		return keyID
+	}
+	return uuid.Nil
+}
+
+type clientIDContextKey struct{}
+
+// WithClientID returns a context carrying the client a request was made
+// with, for callers that authenticate outside of this middleware
+func WithClientID(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, clientIDContextKey{}, clientID)
+}
+
+// GetClientIDFromContext returns the OAuth client the request's token was
+// issued to, or ""
+func GetClientIDFromContext(ctx context.Context) string {
+	if clientID, ok := ctx.Value(clientIDContextKey{}).(string); ok {
+		return clientID
+	}
+	return ""
+}
+
+// WithOwnerID returns a context carrying ownerID, for callers that
+// authenticate outside of this middleware (e.g. internal gRPC clients)
+func WithOwnerID(ctx context.Context, ownerID uuid.UUID) context.Context {
+	return context.WithValue(ctx, "owner_id", ownerID)
+}
+
 func GetOwnerIDFromContext(ctx context.Context) uuid.UUID {
 	if ownerID, ok := ctx.Value("owner_id").(uuid.UUID); ok {
 		return ownerID
@@ -1,10 +1,18 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/session"
+	"url-shortener/pkg/tenant"
+
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -101,3 +109,217 @@ func TestOAuthMiddleware_InvalidAuthHeaderFormat(t *testing.T) {
 	// Should return 401 for invalid auth header format
 	assert.Equal(t, http.StatusUnauthorized, w.Code)
 }
+
+type fakeSessionStore struct {
+	sessions map[string]*session.Session
+	// down fails lookups of this session ID
+	down string
+}
+
+func (f *fakeSessionStore) Create(ctx context.Context, s *session.Session) error {
+	f.sessions[s.ID] = s
+	return nil
+}
+
+func (f *fakeSessionStore) Get(ctx context.Context, id string) (*session.Session, error) {
+	if f.down != "" && id == f.down {
+		return nil, errors.New("redis unavailable")
+	}
+	return f.sessions[id], nil
+}
+
+func (f *fakeSessionStore) Delete(ctx context.Context, id string) error {
+	delete(f.sessions, id)
+	return nil
+}
+
+func (f *fakeSessionStore) SaveLoginState(ctx context.Context, state string, ls *session.LoginState, ttl time.Duration) error {
+	return nil
+}
+
+func (f *fakeSessionStore) TakeLoginState(ctx context.Context, state string) (*session.LoginState, error) {
+	return nil, nil
+}
+
+func TestOAuthMiddleware_SessionCookie(t *testing.T) {
+	ownerID := uuid.New()
+	store := &fakeSessionStore{sessions: map[string]*session.Session{
+		"valid": {
+			ID:        "valid",
+			OwnerID:   ownerID,
+			Sub:       ownerID.String(),
+			Scope:     "links:read",
+			ExpiresAt: time.Now().Add(time.Hour),
+		},
+	}, down: "unreachable"}
+
+	middleware := &OAuthMiddleware{}
+	middleware.UseSessions(store, logging.NewLogger(logging.LevelError))
+
+	var gotOwner interface{}
+	handler := func(scope string) http.Handler {
+		return middleware.Authenticate(scope)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotOwner = r.Context().Value("owner_id")
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+
+	tests := []struct {
+		name   string
+		cookie string
+		scope  string
+		want   int
+	}{
+		{"valid session", "valid", "links:read", http.StatusOK},
+		{"missing scope", "valid", "links:write", http.StatusForbidden},
+		{"unknown session", "expired", "links:read", http.StatusUnauthorized},
+		{"no cookie", "", "links:read", http.StatusUnauthorized},
+		{"store down", "unreachable", "links:read", http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: session.CookieName, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+
+			handler(tt.scope).ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+
+	assert.Equal(t, ownerID, gotOwner)
+}
+
+func TestOAuthMiddleware_SessionTenant(t *testing.T) {
+	ownerID := uuid.New()
+	store := &fakeSessionStore{sessions: map[string]*session.Session{
+		"acme":    {ID: "acme", OwnerID: ownerID, Sub: ownerID.String(), Tenant: "acme", ExpiresAt: time.Now().Add(time.Hour)},
+		"default": {ID: "default", OwnerID: ownerID, Sub: ownerID.String(), ExpiresAt: time.Now().Add(time.Hour)},
+	}}
+	middleware := &OAuthMiddleware{}
+	middleware.UseSessions(store, logging.NewLogger(logging.LevelError))
+
+	var gotTenant string
+	handler := middleware.Authenticate()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTenant = tenant.FromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name       string
+		cookie     string
+		hostTenant string
+		want       int
+		tenant     string
+	}{
+		{"own tenant's domain", "acme", "acme", http.StatusOK, "acme"},
+		{"default domain", "acme", "", http.StatusOK, "acme"},
+		{"another tenant's domain", "acme", "globex", http.StatusForbidden, ""},
+		{"default tenant on a tenant's domain", "default", "acme", http.StatusForbidden, ""},
+		{"default tenant", "default", "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTenant = ""
+			req := httptest.NewRequest("GET", "/test", nil)
+			if tt.hostTenant != "" {
+				req = req.WithContext(tenant.WithTenant(req.Context(), tt.hostTenant))
+			}
+			req.AddCookie(&http.Cookie{Name: session.CookieName, Value: tt.cookie})
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, tt.want, w.Code)
+			assert.Equal(t, tt.tenant, gotTenant)
+		})
+	}
+}
+
+type fakeAPIKeys map[string]string
+
+func (f fakeAPIKeys) VerifyAPIKey(ctx context.Context, key string) (ownerID, keyID uuid.UUID, tenantID string, err error) {
+	tenantID, ok := f[key]
+	if !ok {
+		return uuid.Nil, uuid.Nil, "", nil
+	}
+	return uuid.New(), uuid.New(), tenantID, nil
+}
+
+func TestOAuthMiddleware_APIKeyTenant(t *testing.T) {
+	middleware := &OAuthMiddleware{}
+	middleware.UseAPIKeys(fakeAPIKeys{"acme-key": "acme", "default-key": ""}, logging.NewLogger(logging.LevelError))
+
+	var gotTenant string
+	handler := middleware.AuthenticateAPIKey("links:write")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotTenant = tenant.FromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name       string
+		key        string
+		hostTenant string
+		want       int
+		tenant     string
+	}{
+		{"own tenant's domain", "acme-key", "acme", http.StatusOK, "acme"},
+		{"default domain", "acme-key", "", http.StatusOK, "acme"},
+		{"another tenant's domain", "acme-key", "globex", http.StatusForbidden, ""},
+		{"default tenant on a tenant's domain", "default-key", "acme", http.StatusForbidden, ""},
+		{"unknown key", "other", "", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotTenant = ""
+			req := httptest.NewRequest("POST", "/test", nil)
+			if tt.hostTenant != "" {
+				req = req.WithContext(tenant.WithTenant(req.Context(), tt.hostTenant))
+			}
+			req.Header.Set(APIKeyHeader, tt.key)
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, tt.want, w.Code)
+			assert.Equal(t, tt.tenant, gotTenant)
+		})
+	}
+}
+
+func TestOAuthMiddleware_OwnerRateLimit(t *testing.T) {
+	limited, unlimited := uuid.New(), uuid.New()
+	store := &fakeSessionStore{sessions: map[string]*session.Session{}}
+	for _, ownerID := range []uuid.UUID{limited, unlimited} {
+		store.sessions[ownerID.String()] = &session.Session{ID: ownerID.String(), OwnerID: ownerID, Sub: ownerID.String(), ExpiresAt: time.Now().Add(time.Hour)}
+	}
+
+	middleware := &OAuthMiddleware{}
+	middleware.UseSessions(store, logging.NewLogger(logging.LevelError))
+	middleware.UseOwnerRateLimit(func(ctx context.Context, ownerID uuid.UUID) int {
+		if ownerID == limited {
+			return 2
+		}
+		return 0
+	})
+	handler := middleware.Authenticate()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(ownerID uuid.UUID) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.AddCookie(&http.Cookie{Name: session.CookieName, Value: ownerID.String()})
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, request(limited).Code)
+	assert.Equal(t, http.StatusOK, request(limited).Code)
+	w := request(limited)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.NotEmpty(t, w.Header().Get("Retry-After"))
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusOK, request(unlimited).Code)
+	}
+}
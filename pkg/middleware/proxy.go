@@ -0,0 +1,112 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+type httpsKey struct{}
+
+// RealClient returns middleware that, for requests arriving from one of the
+// trusted proxy ranges, replaces r.RemoteAddr with the client address from
+// X-Forwarded-For and notes the scheme from X-Forwarded-Proto, so that rate
+// limiting, click analytics and logging see the client rather than the load
+// balancer. X-Forwarded-For is read from the right, skipping trusted
+// proxies, so addresses a client puts in it itself are never used. Requests
+// from other peers are left alone, and with no trusted ranges the middleware
+// does nothing.
+func RealClient(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if len(trusted) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			peer, ok := remoteAddr(r)
+			if !ok || !isTrusted(trusted, peer) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			client := forwardedClient(trusted, peer, r.Header.Values("X-Forwarded-For"))
+			r = r.Clone(r.Context())
+			r.RemoteAddr = netip.AddrPortFrom(client, 0).String()
+			if proto := lastValue(r.Header.Values("X-Forwarded-Proto")); strings.EqualFold(proto, "https") {
+				r = r.WithContext(context.WithValue(r.Context(), httpsKey{}, true))
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IsHTTPS reports whether the client connected over HTTPS, to this server or
+// to a trusted proxy in front of it. Cookies are marked Secure when it holds.
+func IsHTTPS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	https, _ := r.Context().Value(httpsKey{}).(bool)
+	return https
+}
+
+// forwardedClient walks X-Forwarded-For from the nearest hop back and
+// returns the first address that isn't a trusted proxy. If every hop is
+// trusted the furthest one is the client; if a hop can't be parsed, the
+// last trusted proxy before it is.
+func forwardedClient(trusted []netip.Prefix, peer netip.Addr, headers []string) netip.Addr {
+	var hops []string
+	for _, header := range headers {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := parseHop(hops[i])
+		if err != nil {
+			break
+		}
+		client = addr
+		if !isTrusted(trusted, addr) {
+			break
+		}
+	}
+	return client
+}
+
+// parseHop parses an X-Forwarded-For entry, which some proxies write with a
+// port
+func parseHop(hop string) (netip.Addr, error) {
+	hop = strings.TrimSpace(hop)
+	if addrPort, err := netip.ParseAddrPort(hop); err == nil {
+		return addrPort.Addr().Unmap(), nil
+	}
+	addr, err := netip.ParseAddr(hop)
+	return addr.Unmap(), err
+}
+
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+	if addrPort, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	addr, err := netip.ParseAddr(r.RemoteAddr)
+	return addr.Unmap(), err == nil
+}
+
+func isTrusted(trusted []netip.Prefix, addr netip.Addr) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// lastValue is the rightmost entry of a list header, the one added by the
+// nearest proxy
+func lastValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	entries := strings.Split(values[len(values)-1], ",")
+	return strings.TrimSpace(entries[len(entries)-1])
+}
@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealClient(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")}
+
+	var remoteAddr string
+	var https bool
+	handler := RealClient(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteAddr = r.RemoteAddr
+		https = IsHTTPS(r)
+	}))
+	serve := func(peer, forwardedFor, proto string) {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = peer
+		if forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		if proto != "" {
+			req.Header.Set("X-Forwarded-Proto", proto)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	tests := []struct {
+		name         string
+		peer, xff    string
+		proto        string
+		remoteAddr   string
+		expectsHTTPS bool
+	}{
+		{"direct client", "198.51.100.1:1234", "", "", "198.51.100.1:1234", false},
+		{"untrusted peer can't claim an address", "198.51.100.1:1234", "203.0.113.5", "https", "198.51.100.1:1234", false},
+		{"load balancer", "10.0.0.2:5555", "203.0.113.5", "https", "203.0.113.5:0", true},
+		{"spoofed entries before the client are ignored", "10.0.0.2:5555", "192.0.2.66, 203.0.113.5", "http", "203.0.113.5:0", false},
+		{"chain of proxies", "10.0.0.2:5555", "203.0.113.5, 10.1.1.1, 10.2.2.2", "", "203.0.113.5:0", false},
+		{"entry with a port", "[fd00::1]:443", "203.0.113.5:61000", "", "203.0.113.5:0", false},
+		{"garbage stops at the last proxy", "10.0.0.2:5555", "203.0.113.5, bogus, 10.1.1.1", "", "10.1.1.1:0", false},
+		{"no header", "10.0.0.2:5555", "", "", "10.0.0.2:0", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serve(tt.peer, tt.xff, tt.proto)
+			assert.Equal(t, tt.remoteAddr, remoteAddr)
+			assert.Equal(t, tt.expectsHTTPS, https)
+		})
+	}
+
+	// Without trusted proxies the headers are never read
+	RealClient(nil)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "10.0.0.2:5555", r.RemoteAddr)
+	})).ServeHTTP(httptest.NewRecorder(), func() *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = "10.0.0.2:5555"
+		req.Header.Set("X-Forwarded-For", "203.0.113.5")
+		return req
+	}())
+}
+
+func TestRecordClient(t *testing.T) {
+	var info ClientInfo
+	handler := RecordClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info = ClientFromContext(r.Context())
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "[::ffff:198.51.100.7]:4321"
+	req.Header.Set("User-Agent", "curl/8.5.0")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, ClientInfo{IP: "198.51.100.7", UserAgent: "curl/8.5.0"}, info)
+}
@@ -0,0 +1,129 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimiter is a fixed-window, per-client request limiter. A limit of
+// zero disables it. The limit can be changed at runtime with SetLimit.
+type RateLimiter struct {
+	limit     atomic.Int64
+	window    time.Duration
+	mu        sync.Mutex
+	windows   map[string]*rateWindow
+	lastSweep time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int64
+}
+
+func NewRateLimiter(perMinute int) *RateLimiter {
+	return NewWindowLimiter(perMinute, time.Minute)
+}
+
+// NewWindowLimiter allows limit requests per client per window
+func NewWindowLimiter(limit int, window time.Duration) *RateLimiter {
+	rl := &RateLimiter{
+		window:  window,
+		windows: make(map[string]*rateWindow),
+	}
+	rl.SetLimit(limit)
+	return rl
+}
+
+// SetLimit changes the number of requests allowed per client per window
+func (rl *RateLimiter) SetLimit(limit int) {
+	rl.limit.Store(int64(limit))
+}
+
+// Limit is the number of requests allowed per client per window; 0 means
+// unlimited
+func (rl *RateLimiter) Limit() int {
+	return int(rl.limit.Load())
+}
+
+// Allow records a request for key and reports whether it is within the limit,
+// along with the time until the current window resets.
+func (rl *RateLimiter) Allow(key string) (bool, time.Duration) {
+	return rl.AllowLimit(key, int(rl.limit.Load()))
+}
+
+// AllowLimit is Allow with a limit of its own for key, for limiters whose
+// clients don't all get the same limit
+func (rl *RateLimiter) AllowLimit(key string, limit int) (bool, time.Duration) {
+	if limit <= 0 {
+		return true, 0
+	}
+
+	now := time.Now()
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	w, ok := rl.windows[key]
+	if !ok || now.Sub(w.start) >= rl.window {
+		rl.evictExpired(now)
+		w = &rateWindow{start: now}
+		rl.windows[key] = w
+	}
+	w.count++
+	return w.count <= int64(limit), rl.window - now.Sub(w.start)
+}
+
+// Exhausted reports whether key has used up the requests allowed in its
+// current window, so that Allow would refuse the next one, without recording
+// a request
+func (rl *RateLimiter) Exhausted(key string) bool {
+	limit := rl.limit.Load()
+	if limit <= 0 {
+		return false
+	}
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	w, ok := rl.windows[key]
+	return ok && time.Since(w.start) < rl.window && w.count >= limit
+}
+
+// evictExpired drops finished windows at most once per window; the caller
+// must hold rl.mu
+func (rl *RateLimiter) evictExpired(now time.Time) {
+	if now.Sub(rl.lastSweep) < rl.window {
+		return
+	}
+	rl.lastSweep = now
+	for key, w := range rl.windows {
+		if now.Sub(w.start) >= rl.window {
+			delete(rl.windows, key)
+		}
+	}
+}
+
+func (rl *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		allowed, retryAfter := rl.Allow(clientKey(r))
+		if !allowed {
+			rateLimited(w, retryAfter)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func rateLimited(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+	http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+}
+
+func clientKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,18 @@
+package middleware
+
+import "net/http"
+
+// ReadOnly refuses the requests writes reports as writing, with 503 and a
+// message saying why, for servers started read-only on a database schema
+// newer than they know
+func ReadOnly(writes func(*http.Request) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if writes(r) {
+				http.Error(w, "read only: the server is older than the database schema", http.StatusServiceUnavailable)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
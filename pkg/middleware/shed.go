@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// LoadShedder caps how many requests of each route class are served at
+// once, so that a traffic spike, such as a link going viral, can't queue up
+// more work than Postgres takes. A request over its class's limit waits up
+// to the queue timeout for a slot, with at most as many others waiting as
+// the limit; requests that don't get one are refused with 503 and
+// Retry-After. Classes without a limit, and requests classified "", aren't
+// limited.
+type LoadShedder struct {
+	classify func(*http.Request) string
+	classes  map[string]*shedClass
+	wait     time.Duration
+}
+
+type shedClass struct {
+	slots   chan struct{}
+	waiting atomic.Int64
+}
+
+// NewLoadShedder limits each class, named by classify, to the in-flight
+// requests given in limits. Requests wait up to wait for a slot.
+func NewLoadShedder(classify func(*http.Request) string, limits map[string]int, wait time.Duration) *LoadShedder {
+	s := &LoadShedder{classify: classify, classes: make(map[string]*shedClass), wait: wait}
+	for class, limit := range limits {
+		if limit > 0 {
+			s.classes[class] = &shedClass{slots: make(chan struct{}, limit)}
+		}
+	}
+	return s
+}
+
+func (s *LoadShedder) Middleware(next http.Handler) http.Handler {
+	if len(s.classes) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		class := s.classes[s.classify(r)]
+		if class == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !s.acquire(r, class) {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "server overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		defer func() { <-class.slots }()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acquire takes a slot of class for r, waiting for one if there is room in
+// the queue
+func (s *LoadShedder) acquire(r *http.Request, class *shedClass) bool {
+	select {
+	case class.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if class.waiting.Add(1) > int64(cap(class.slots)) || s.wait <= 0 {
+		class.waiting.Add(-1)
+		return false
+	}
+	defer class.waiting.Add(-1)
+
+	timer := time.NewTimer(s.wait)
+	defer timer.Stop()
+	select {
+	case class.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
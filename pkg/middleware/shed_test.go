@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadShedder(t *testing.T) {
+	classify := func(r *http.Request) string { return r.URL.Path[1:] }
+	shedder := NewLoadShedder(classify, map[string]int{"slow": 1}, 200*time.Millisecond)
+	started, release := make(chan struct{}), make(chan struct{})
+	handler := shedder.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			started <- struct{}{}
+			<-release
+		}
+	}))
+	serve := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	done := make(chan int)
+	go func() { done <- serve("/slow").Code }()
+	<-started
+
+	// The only slot is taken, and waiting for it times out
+	rec := serve("/slow")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	// Other classes aren't held up
+	assert.Equal(t, http.StatusOK, serve("/fast").Code)
+
+	// A waiting request gets the slot once it is released
+	go func() { done <- serve("/slow").Code }()
+	time.Sleep(10 * time.Millisecond)
+	release <- struct{}{}
+	assert.Equal(t, http.StatusOK, <-done)
+	<-started
+	release <- struct{}{}
+	assert.Equal(t, http.StatusOK, <-done)
+}
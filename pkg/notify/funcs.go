@@ -0,0 +1,21 @@
+package notify
+
+import "time"
+
+var funcs = map[string]any{
+	"date": func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC1123)
+	},
+	"day": func(t time.Time) string {
+		return t.UTC().Format("Jan 2, 2006")
+	},
+	"deref": func(n *int) int {
+		if n == nil {
+			return 0
+		}
+		return *n
+	},
+}
@@ -0,0 +1,141 @@
+// Package notify sends templated email through a pluggable Provider (SMTP,
+// Amazon SES or SendGrid). Callers pick a template by name and pass the data
+// it renders; whether a user wants a given Category of mail is decided by
+// the caller from the user's notification settings.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"url-shortener/pkg/storage"
+)
+
+// Category groups messages so users can opt out of each kind separately
+type Category string
+
+const (
+	CategoryExpiry Category = "expiry"
+	CategoryAbuse  Category = "abuse"
+	CategoryDigest Category = "digest"
+)
+
+// Message is a fully rendered email
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Provider delivers a rendered message
+type Provider interface {
+	Send(ctx context.Context, msg *Message) error
+}
+
+var ErrNotConfigured = errors.New("notify: no email provider configured")
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+// Mailer renders named templates and hands them to a Provider. Each template
+// file defines "subject", "text" and "html" blocks.
+type Mailer struct {
+	provider Provider
+	from     string
+	text     *texttemplate.Template
+	html     *htmltemplate.Template
+}
+
+func NewMailer(provider Provider, from string) (*Mailer, error) {
+	text, err := texttemplate.New("").Funcs(funcs).ParseFS(templateFiles, "templates/*.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse text templates: %w", err)
+	}
+	html, err := htmltemplate.New("").Funcs(funcs).ParseFS(templateFiles, "templates/*.tmpl")
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse html templates: %w", err)
+	}
+	return &Mailer{provider: provider, from: from, text: text, html: html}, nil
+}
+
+// Send renders template (the file name without .tmpl) with data and sends it
+func (m *Mailer) Send(ctx context.Context, to, template string, data any) error {
+	if m.provider == nil {
+		return ErrNotConfigured
+	}
+	msg, err := m.Render(to, template, data)
+	if err != nil {
+		return err
+	}
+	return m.provider.Send(ctx, msg)
+}
+
+// Render builds the message without sending it
+func (m *Mailer) Render(to, template string, data any) (*Message, error) {
+	subject, err := m.executeText(template+".subject", data)
+	if err != nil {
+		return nil, err
+	}
+	text, err := m.executeText(template+".text", data)
+	if err != nil {
+		return nil, err
+	}
+	var html bytes.Buffer
+	if err := m.html.ExecuteTemplate(&html, template+".html", data); err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", template, err)
+	}
+
+	return &Message{
+		From:    m.from,
+		To:      to,
+		Subject: strings.TrimSpace(subject),
+		Text:    text,
+		HTML:    html.String(),
+	}, nil
+}
+
+func (m *Mailer) executeText(name string, data any) (string, error) {
+	var buf bytes.Buffer
+	if err := m.text.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", fmt.Errorf("failed to render %s: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// Recipient returns the address to email for category, or false if the user
+// has email turned off or opted out of that category
+func Recipient(ns *storage.NotificationSettings, category Category) (string, bool) {
+	if ns == nil || !ns.EmailEnabled || ns.Email == nil || *ns.Email == "" {
+		return "", false
+	}
+	switch category {
+	case CategoryExpiry:
+		if !ns.ExpiryReminders {
+			return "", false
+		}
+	case CategoryAbuse:
+		if !ns.AbuseNotices {
+			return "", false
+		}
+	case CategoryDigest:
+		if ns.Digest == "" || ns.Digest == "off" {
+			return "", false
+		}
+	}
+	return *ns.Email, true
+}
+
+// AbuseNotice is the data for the abuse_notice template
+type AbuseNotice struct {
+	ShortURL string
+	LongURL  string
+	Reason   string
+}
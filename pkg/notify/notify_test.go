@@ -0,0 +1,132 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTemplates(t *testing.T) {
+	mailer, err := NewMailer(nil, "noreply@example.com")
+	require.NoError(t, err)
+
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	maxClicks := 100
+	data := map[string]any{
+		"ShortURL":   "https://sho.rt/r/abc",
+		"LongURL":    "https://example.com/<script>",
+		"ExpiresAt":  &expires,
+		"ClickCount": 95,
+		"MaxClicks":  &maxClicks,
+		"Reason":     "it pointed to malware",
+		"Status":     "not_found",
+	}
+
+	for _, name := range []string{"expiry_reminder", "clicks_reminder", "abuse_notice", "dead_destination"} {
+		msg, err := mailer.Render("owner@example.com", name, data)
+		require.NoError(t, err, name)
+		assert.NotEmpty(t, msg.Subject, name)
+		assert.Contains(t, msg.Text, "https://sho.rt/r/abc", name)
+		assert.Contains(t, msg.HTML, "&lt;script&gt;", name)
+	}
+
+	digest := map[string]any{
+		"Period":      "week",
+		"From":        expires,
+		"TotalClicks": 42,
+		"NewLinks":    1,
+		"Daily":       make([]struct{}, 7),
+		"TopLinks":    []map[string]any{{"ShortURL": "https://sho.rt/r/abc", "LongURL": "https://example.com/<script>", "Clicks": 40}},
+	}
+	msg, err := mailer.Render("owner@example.com", "digest", digest)
+	require.NoError(t, err)
+	assert.Equal(t, "Your weekly link digest: 42 clicks", msg.Subject)
+	assert.Contains(t, msg.Text, "7 days from Jan 2, 2030")
+	assert.Contains(t, msg.HTML, "&lt;script&gt;")
+
+	links := map[string]any{
+		"Subject": "Re: Links for the newsletter",
+		"Links": []map[string]any{
+			{"LongURL": "https://example.com/<script>", "ShortURL": "https://sho.rt/r/abc"},
+			{"LongURL": "https://example.com/blocked", "Error": "destination blocked"},
+		},
+	}
+	msg, err = mailer.Render("staff@example.com", "email_links", links)
+	require.NoError(t, err)
+	assert.Equal(t, "Re: Links for the newsletter", msg.Subject)
+	assert.Contains(t, msg.Text, "https://sho.rt/r/abc")
+	assert.Contains(t, msg.Text, "Couldn't shorten: destination blocked")
+	assert.Contains(t, msg.HTML, "&lt;script&gt;")
+
+	assert.ErrorIs(t, mailer.Send(context.Background(), "a@b.c", "abuse_notice", data), ErrNotConfigured)
+}
+
+func TestRecipient(t *testing.T) {
+	email := "owner@example.com"
+	ns := &storage.NotificationSettings{Email: &email, EmailEnabled: true, ExpiryReminders: true, Digest: "off"}
+
+	to, ok := Recipient(ns, CategoryExpiry)
+	assert.True(t, ok)
+	assert.Equal(t, email, to)
+
+	_, ok = Recipient(ns, CategoryAbuse)
+	assert.False(t, ok)
+	_, ok = Recipient(ns, CategoryDigest)
+	assert.False(t, ok)
+
+	ns.EmailEnabled = false
+	_, ok = Recipient(ns, CategoryExpiry)
+	assert.False(t, ok)
+}
+
+func TestSMTPProvider(t *testing.T) {
+	p := NewSMTPProvider("smtp.example.com:25", "", "")
+	var got []byte
+	p.send = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		got = msg
+		return nil
+	}
+
+	require.NoError(t, p.Send(context.Background(), &Message{From: "a@example.com", To: "b@example.com", Subject: "Hi", Text: "plain", HTML: "<b>rich</b>"}))
+	assert.Contains(t, string(got), "Subject: Hi\r\n")
+	assert.Contains(t, string(got), "multipart/alternative")
+	assert.Contains(t, string(got), "plain")
+	assert.Contains(t, string(got), "<b>rich</b>")
+}
+
+func TestSendGridProvider(t *testing.T) {
+	var body map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+		json.NewDecoder(r.Body).Decode(&body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	p := NewSendGridProvider("key")
+	p.endpoint = server.URL
+	require.NoError(t, p.Send(context.Background(), &Message{From: "a@example.com", To: "b@example.com", Subject: "Hi"}))
+	assert.Equal(t, "Hi", body["subject"])
+}
+
+func TestNewProvider(t *testing.T) {
+	p, err := NewProvider(Config{})
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+
+	p, err = NewProvider(Config{Provider: "ses", SESRegion: "eu-west-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "email-smtp.eu-west-1.amazonaws.com:587", p.(*SMTPProvider).addr)
+
+	_, err = NewProvider(Config{Provider: "pigeon"})
+	assert.Error(t, err)
+}
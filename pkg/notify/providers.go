@@ -0,0 +1,160 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Config selects and configures a Provider
+type Config struct {
+	// Provider is "smtp", "ses", "sendgrid" or "" (disabled)
+	Provider     string
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	// SESRegion selects the SES SMTP endpoint; SMTP credentials are the SES
+	// SMTP username and password
+	SESRegion      string
+	SendGridAPIKey string
+}
+
+// NewProvider returns the configured provider, or nil if none is configured
+func NewProvider(cfg Config) (Provider, error) {
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "smtp":
+		if cfg.SMTPAddr == "" {
+			return nil, fmt.Errorf("notify: smtp provider needs an address")
+		}
+		return NewSMTPProvider(cfg.SMTPAddr, cfg.SMTPUsername, cfg.SMTPPassword), nil
+	case "ses":
+		if cfg.SESRegion == "" {
+			return nil, fmt.Errorf("notify: ses provider needs a region")
+		}
+		return NewSESProvider(cfg.SESRegion, cfg.SMTPUsername, cfg.SMTPPassword), nil
+	case "sendgrid":
+		if cfg.SendGridAPIKey == "" {
+			return nil, fmt.Errorf("notify: sendgrid provider needs an API key")
+		}
+		return NewSendGridProvider(cfg.SendGridAPIKey), nil
+	}
+	return nil, fmt.Errorf("notify: unknown provider %q", cfg.Provider)
+}
+
+// SMTPProvider sends multipart text/HTML mail through an SMTP relay
+type SMTPProvider struct {
+	addr string
+	auth smtp.Auth
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+func NewSMTPProvider(addr, username, password string) *SMTPProvider {
+	var auth smtp.Auth
+	if username != "" {
+		host, _, _ := strings.Cut(addr, ":")
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &SMTPProvider{addr: addr, auth: auth, send: smtp.SendMail}
+}
+
+// NewSESProvider sends through the Amazon SES SMTP interface for region
+func NewSESProvider(region, username, password string) *SMTPProvider {
+	return NewSMTPProvider("email-smtp."+region+".amazonaws.com:587", username, password)
+}
+
+func (p *SMTPProvider) Send(ctx context.Context, msg *Message) error {
+	data, err := mimeMessage(msg)
+	if err != nil {
+		return err
+	}
+	return p.send(p.addr, p.auth, msg.From, []string{msg.To}, data)
+}
+
+func mimeMessage(msg *Message) ([]byte, error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", msg.Text},
+		{"text/html; charset=UTF-8", msg.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {part.contentType}})
+		if err != nil {
+			return nil, err
+		}
+		io.WriteString(w, part.content)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "From: %s\r\nTo: %s\r\nSubject: %s\r\n", msg.From, msg.To, msg.Subject)
+	fmt.Fprintf(&out, "MIME-Version: 1.0\r\nContent-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+// SendGridProvider uses the SendGrid v3 mail API
+type SendGridProvider struct {
+	apiKey   string
+	endpoint string
+	client   *http.Client
+}
+
+func NewSendGridProvider(apiKey string) *SendGridProvider {
+	return &SendGridProvider{
+		apiKey:   apiKey,
+		endpoint: "https://api.sendgrid.com/v3/mail/send",
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *SendGridProvider) Send(ctx context.Context, msg *Message) error {
+	type address struct {
+		Email string `json:"email"`
+	}
+	type content struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	}
+	payload, err := json.Marshal(map[string]any{
+		"personalizations": []map[string]any{{"to": []address{{Email: msg.To}}}},
+		"from":             address{Email: msg.From},
+		"subject":          msg.Subject,
+		"content": []content{
+			{Type: "text/plain", Value: msg.Text},
+			{Type: "text/html", Value: msg.HTML},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("sendgrid returned %s: %s", resp.Status, body)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+// Package outbox publishes the events that link mutations record in the
+// outbox table. Because an event is written in the same transaction as the
+// change it describes, and only marked published once every Publisher has
+// accepted it, no committed change goes unannounced: events are delivered at
+// least once, and consumers deduplicate on the event ID.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+)
+
+const (
+	batchSize = 100
+	// lease must comfortably cover publishing a batch
+	lease      = 2 * time.Minute
+	maxBackoff = time.Hour
+	retention  = 7 * 24 * time.Hour
+)
+
+// Publisher delivers one event to a downstream system
+type Publisher interface {
+	PublishEvent(ctx context.Context, event *storage.OutboxEvent) error
+}
+
+type Relay struct {
+	store      storage.OutboxStorage
+	logger     *logging.Logger
+	publishers []Publisher
+	lastPrune  time.Time
+}
+
+func NewRelay(store storage.OutboxStorage, logger *logging.Logger, publishers ...Publisher) *Relay {
+	return &Relay{
+		store:      store,
+		logger:     logger,
+		publishers: publishers,
+	}
+}
+
+// Run polls for events every interval until ctx is done
+func (r *Relay) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.RunOnce(ctx); err != nil {
+			r.logger.Error(ctx, "outbox relay failed", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce publishes one batch of pending events and returns how many were
+// published. Failed events are retried with exponential backoff.
+func (r *Relay) RunOnce(ctx context.Context) (int, error) {
+	if time.Since(r.lastPrune) > time.Hour {
+		if _, err := r.store.DeletePublished(ctx, time.Now().Add(-retention)); err != nil {
+			r.logger.Warn(ctx, "failed to prune outbox", "error", err)
+		}
+		r.lastPrune = time.Now()
+	}
+
+	events, err := r.store.ClaimEvents(ctx, batchSize, lease)
+	if err != nil {
+		return 0, err
+	}
+
+	published := 0
+	for _, event := range events {
+		if err := r.publish(ctx, event); err != nil {
+			retryAt := time.Now().Add(backoff(event.Attempts))
+			r.logger.Warn(ctx, "outbox publish failed", "event_id", event.EventID, "type", event.Type, "attempts", event.Attempts, "error", err)
+			if err := r.store.MarkFailed(ctx, event.ID, retryAt, err.Error()); err != nil {
+				return published, err
+			}
+			continue
+		}
+		if err := r.store.MarkPublished(ctx, event.ID); err != nil {
+			return published, err
+		}
+		published++
+	}
+	return published, nil
+}
+
+func (r *Relay) publish(ctx context.Context, event *storage.OutboxEvent) error {
+	var errs []error
+	for _, p := range r.publishers {
+		if err := p.PublishEvent(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// backoff doubles from 1s per attempt, capped at an hour
+func backoff(attempts int) time.Duration {
+	if attempts > 12 {
+		return maxBackoff
+	}
+	return min(time.Duration(1<<max(attempts-1, 0))*time.Second, maxBackoff)
+}
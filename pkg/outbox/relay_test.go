@@ -0,0 +1,95 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStore struct {
+	events    []*storage.OutboxEvent
+	published map[int64]bool
+	retryAt   map[int64]time.Time
+}
+
+func (f *fakeStore) AddEventTx(ctx context.Context, tx pgx.Tx, event *storage.OutboxEvent) error {
+	return nil
+}
+
+func (f *fakeStore) ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]*storage.OutboxEvent, error) {
+	var claimed []*storage.OutboxEvent
+	for _, e := range f.events {
+		if !f.published[e.ID] && time.Now().After(f.retryAt[e.ID]) {
+			e.Attempts++
+			claimed = append(claimed, e)
+		}
+	}
+	return claimed, nil
+}
+
+func (f *fakeStore) MarkPublished(ctx context.Context, id int64) error {
+	f.published[id] = true
+	return nil
+}
+
+func (f *fakeStore) MarkFailed(ctx context.Context, id int64, retryAt time.Time, lastErr string) error {
+	f.retryAt[id] = retryAt
+	return nil
+}
+
+func (f *fakeStore) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+type fakePublisher struct {
+	fail map[uuid.UUID]bool
+	seen []uuid.UUID
+}
+
+func (f *fakePublisher) PublishEvent(ctx context.Context, event *storage.OutboxEvent) error {
+	f.seen = append(f.seen, event.EventID)
+	if f.fail[event.EventID] {
+		return errors.New("endpoint down")
+	}
+	return nil
+}
+
+func TestRunOncePublishesAndRetries(t *testing.T) {
+	ok := &storage.OutboxEvent{ID: 1, EventID: uuid.New(), Type: "link.created"}
+	failing := &storage.OutboxEvent{ID: 2, EventID: uuid.New(), Type: "link.deleted"}
+	store := &fakeStore{events: []*storage.OutboxEvent{ok, failing}, published: map[int64]bool{}, retryAt: map[int64]time.Time{}}
+	healthy := &fakePublisher{}
+	flaky := &fakePublisher{fail: map[uuid.UUID]bool{failing.EventID: true}}
+	relay := NewRelay(store, logging.NewLogger(logging.LevelError), healthy, flaky)
+
+	published, err := relay.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, published)
+	assert.True(t, store.published[ok.ID])
+	// One failing publisher keeps the event pending for every publisher
+	assert.False(t, store.published[failing.ID])
+	assert.Equal(t, []uuid.UUID{ok.EventID, failing.EventID}, healthy.seen)
+	assert.WithinDuration(t, time.Now().Add(time.Second), store.retryAt[failing.ID], 100*time.Millisecond)
+
+	// Backing off: nothing is claimed until retryAt
+	published, err = relay.RunOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, published)
+	assert.Len(t, healthy.seen, 2)
+}
+
+func TestBackoff(t *testing.T) {
+	assert.Equal(t, time.Second, backoff(1))
+	assert.Equal(t, 8*time.Second, backoff(4))
+	assert.Equal(t, time.Hour, backoff(13))
+	assert.Equal(t, time.Hour, backoff(100))
+}
@@ -0,0 +1,151 @@
+// Package preflight checks that a server could start and serve: that its
+// configuration is valid, its dependencies answer and the database schema is
+// current. The servers run it for --check, as a deploy preflight or an init
+// container, and print the report as JSON.
+package preflight
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"url-shortener/pkg/config"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/redis/go-redis/v9"
+)
+
+// checkTimeout bounds each check, so that an unreachable dependency fails
+// the check rather than hanging it
+const checkTimeout = 10 * time.Second
+
+// Check is one thing a server needs
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Result is the outcome of a check
+type Result struct {
+	Name       string `json:"name"`
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Report is the outcome of every check, OK only if all of them passed
+type Report struct {
+	OK     bool     `json:"ok"`
+	Checks []Result `json:"checks"`
+}
+
+// errSkipped fails checks that need one that failed before them
+var errSkipped = errors.New("skipped: an earlier check it needs failed")
+
+// Run runs checks in order, each for at most checkTimeout
+func Run(ctx context.Context, checks []Check) Report {
+	report := Report{OK: true, Checks: make([]Result, 0, len(checks))}
+	for _, check := range checks {
+		start := time.Now()
+		checkCtx, cancel := context.WithTimeout(ctx, checkTimeout)
+		err := check.Run(checkCtx)
+		cancel()
+		result := Result{Name: check.Name, OK: err == nil, DurationMS: time.Since(start).Milliseconds()}
+		if err != nil {
+			result.Error = err.Error()
+			report.OK = false
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	return report
+}
+
+// Write prints the report as indented JSON
+func (r Report) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// Server is the checks both servers need: the configuration, with loadErr
+// the error config.Load returned, and then, if it loaded, Postgres and every
+// Redis instance
+func Server(cfg *config.Config, loadErr error) []Check {
+	checks := []Check{Config(cfg, loadErr)}
+	if loadErr != nil {
+		return checks
+	}
+	checks = append(checks, Postgres(cfg.DatabaseURL)...)
+	checks = append(checks, Redis("redis", cfg.RedisURL))
+	for i, url := range cfg.RedisShardURLs {
+		checks = append(checks, Redis("redis_shard_"+strconv.Itoa(i+1), url))
+	}
+	return checks
+}
+
+// Config checks that the configuration loaded, with loadErr the error
+// config.Load returned, and that the settings parsed at startup are valid
+func Config(cfg *config.Config, loadErr error) Check {
+	return Check{Name: "config", Run: func(ctx context.Context) error {
+		if loadErr != nil {
+			return loadErr
+		}
+		_, err := logging.ParseRedactions(cfg.LogRedact)
+		return err
+	}}
+}
+
+// Postgres checks that the database at databaseURL answers and has the
+// schema this version needs
+func Postgres(databaseURL string) []Check {
+	var pool *pgxpool.Pool
+	return []Check{
+		{Name: "postgres", Run: func(ctx context.Context) error {
+			p, err := pgxpool.New(ctx, databaseURL)
+			if err != nil {
+				return err
+			}
+			if err := p.Ping(ctx); err != nil {
+				p.Close()
+				return err
+			}
+			pool = p
+			return nil
+		}},
+		{Name: "schema", Run: func(ctx context.Context) error {
+			if pool == nil {
+				return errSkipped
+			}
+			defer pool.Close()
+			return storage.CheckSchema(ctx, pool)
+		}},
+	}
+}
+
+// Redis checks that the Redis instance at url answers
+func Redis(name, url string) Check {
+	return Check{Name: name, Run: func(ctx context.Context) error {
+		opt, err := redis.ParseURL(url)
+		if err != nil {
+			return err
+		}
+		client := redis.NewClient(opt)
+		defer client.Close()
+		return client.Ping(ctx).Err()
+	}}
+}
+
+// OIDC checks that issuer serves its discovery document, which tokens are
+// verified with
+func OIDC(issuer string) Check {
+	return Check{Name: "oidc", Run: func(ctx context.Context) error {
+		_, err := oidc.NewProvider(ctx, issuer)
+		return err
+	}}
+}
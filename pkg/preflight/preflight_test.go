@@ -0,0 +1,55 @@
+package preflight
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"url-shortener/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	report := Run(context.Background(), []Check{
+		{Name: "config", Run: func(ctx context.Context) error { return nil }},
+		{Name: "postgres", Run: func(ctx context.Context) error {
+			_, ok := ctx.Deadline()
+			assert.True(t, ok, "checks are bounded")
+			return errors.New("connection refused")
+		}},
+	})
+	assert.False(t, report.OK)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.Write(&buf))
+	var decoded map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, false, decoded["ok"])
+	checks := decoded["checks"].([]any)
+	require.Len(t, checks, 2)
+	assert.Equal(t, true, checks[0].(map[string]any)["ok"])
+	assert.Equal(t, "connection refused", checks[1].(map[string]any)["error"])
+}
+
+func TestServer(t *testing.T) {
+	// Without a configuration there is nothing to connect to
+	checks := Server(nil, errors.New("REDIRECT_HTTP3 needs REDIRECT_TLS_CERT"))
+	require.Len(t, checks, 1)
+	assert.EqualError(t, checks[0].Run(context.Background()), "REDIRECT_HTTP3 needs REDIRECT_TLS_CERT")
+
+	cfg := &config.Config{DatabaseURL: "postgres://db/links", RedisURL: "redis://cache:6379", RedisShardURLs: []string{"redis://a:6379", "redis://b:6379"}}
+	cfg.LogRedact = []string{"code:encrypt"}
+	var names []string
+	for _, check := range Server(cfg, nil) {
+		names = append(names, check.Name)
+	}
+	assert.Equal(t, []string{"config", "postgres", "schema", "redis", "redis_shard_1", "redis_shard_2"}, names)
+	assert.ErrorContains(t, Config(cfg, nil).Run(context.Background()), "code:encrypt")
+
+	// The schema isn't checked without a connection
+	assert.ErrorIs(t, Postgres(cfg.DatabaseURL)[1].Run(context.Background()), errSkipped)
+}
@@ -0,0 +1,77 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"url-shortener/pkg/notify"
+	"url-shortener/pkg/security"
+	"url-shortener/pkg/storage"
+)
+
+// EmailNotifier emails reminders through the notify package
+type EmailNotifier struct {
+	mailer *notify.Mailer
+}
+
+func NewEmailNotifier(mailer *notify.Mailer) *EmailNotifier {
+	return &EmailNotifier{mailer: mailer}
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, settings *storage.NotificationSettings, r *Reminder) error {
+	to, ok := notify.Recipient(settings, notify.CategoryExpiry)
+	if !ok {
+		return ErrNotApplicable
+	}
+	template := "expiry_reminder"
+	if r.Kind == storage.ReminderClicks {
+		template = "clicks_reminder"
+	}
+	return n.mailer.Send(ctx, to, template, r)
+}
+
+// WebhookNotifier POSTs reminders as JSON to the owner's webhook URL
+type WebhookNotifier struct {
+	client *http.Client
+}
+
+func NewWebhookNotifier() *WebhookNotifier {
+	// Saving the URL only checked the host it resolved to then; the
+	// outbound client checks the address again at dial time and doesn't
+	// follow redirects
+	return &WebhookNotifier{client: security.NewOutboundClient(10*time.Second, 0)}
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, settings *storage.NotificationSettings, r *Reminder) error {
+	if settings.WebhookURL == nil || *settings.WebhookURL == "" {
+		return ErrNotApplicable
+	}
+
+	payload, err := json.Marshal(struct {
+		Event string `json:"event"`
+		*Reminder
+	}{Event: "link.expiring", Reminder: r})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, *settings.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+// Package reminder warns link owners before their links stop working, either
+// because expires_at is near or because most of max_clicks has been used.
+// A Scanner periodically looks for such links among owners who opted in and
+// hands each one to the configured Notifiers.
+package reminder
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+)
+
+// scanBatchSize bounds the number of reminders sent per scan
+const scanBatchSize = 500
+
+// Reminder is what notifiers receive for a single link
+type Reminder struct {
+	Kind       string     `json:"kind"`
+	Code       string     `json:"code"`
+	ShortURL   string     `json:"short_url"`
+	LongURL    string     `json:"long_url"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	ClickCount int        `json:"click_count"`
+	MaxClicks  *int       `json:"max_clicks,omitempty"`
+}
+
+// Notifier delivers a reminder over one channel. It returns ErrNotApplicable
+// if the owner's settings don't enable that channel.
+type Notifier interface {
+	Notify(ctx context.Context, settings *storage.NotificationSettings, r *Reminder) error
+}
+
+var ErrNotApplicable = errors.New("channel not enabled")
+
+type Scanner struct {
+	store     storage.ReminderStorage
+	shortURL  func(*storage.Link) string
+	logger    *logging.Logger
+	notifiers []Notifier
+}
+
+// NewScanner builds a scanner; shortURL renders the public URL of a link
+func NewScanner(store storage.ReminderStorage, shortURL func(*storage.Link) string, logger *logging.Logger, notifiers ...Notifier) *Scanner {
+	return &Scanner{
+		store:     store,
+		shortURL:  shortURL,
+		logger:    logger,
+		notifiers: notifiers,
+	}
+}
+
+// Run scans every interval until ctx is done
+func (s *Scanner) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if sent, err := s.ScanOnce(ctx); err != nil {
+			s.logger.Error(ctx, "reminder scan failed", "error", err)
+		} else if sent > 0 {
+			s.logger.Info(ctx, "expiry reminders sent", "count", sent)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ScanOnce sends reminders for every pending candidate and returns how many
+// were delivered on at least one channel
+func (s *Scanner) ScanOnce(ctx context.Context) (int, error) {
+	candidates, err := s.store.FindReminderCandidates(ctx, time.Now(), scanBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, c := range candidates {
+		// Claim first so that concurrent API replicas don't both send
+		claimed, err := s.store.ClaimReminder(ctx, c.Link.Key(), c.Kind)
+		if err != nil {
+			return sent, err
+		}
+		if !claimed {
+			continue
+		}
+
+		if s.deliver(ctx, c) {
+			sent++
+			continue
+		}
+		// Nothing went out; let the next scan retry
+		if err := s.store.ReleaseReminder(ctx, c.Link.Key(), c.Kind); err != nil {
+			s.logger.Warn(ctx, "failed to release reminder", "code", c.Link.Code, "error", err)
+		}
+	}
+	return sent, nil
+}
+
+// deliver reports whether at least one channel accepted the reminder
+func (s *Scanner) deliver(ctx context.Context, c *storage.ReminderCandidate) bool {
+	r := &Reminder{
+		Kind:       c.Kind,
+		Code:       c.Link.Code,
+		ShortURL:   s.shortURL(c.Link),
+		LongURL:    c.Link.LongURL,
+		ExpiresAt:  c.Link.ExpiresAt,
+		ClickCount: c.Link.ClickCount,
+		MaxClicks:  c.Link.MaxClicks,
+	}
+
+	delivered := false
+	for _, n := range s.notifiers {
+		err := n.Notify(ctx, c.Settings, r)
+		switch {
+		case err == nil:
+			delivered = true
+		case errors.Is(err, ErrNotApplicable):
+		default:
+			s.logger.Warn(ctx, "reminder delivery failed", "code", r.Code, "kind", r.Kind, "error", err)
+		}
+	}
+	return delivered
+}
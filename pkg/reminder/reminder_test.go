@@ -0,0 +1,135 @@
+package reminder
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/notify"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStore struct {
+	candidates []*storage.ReminderCandidate
+	claimed    map[string]bool
+}
+
+func (f *fakeStore) GetNotificationSettings(ctx context.Context, ownerID uuid.UUID) (*storage.NotificationSettings, error) {
+	return nil, nil
+}
+
+func (f *fakeStore) SaveNotificationSettings(ctx context.Context, ns *storage.NotificationSettings) error {
+	return nil
+}
+
+func (f *fakeStore) FindReminderCandidates(ctx context.Context, now time.Time, limit int) ([]*storage.ReminderCandidate, error) {
+	var pending []*storage.ReminderCandidate
+	for _, c := range f.candidates {
+		if !f.claimed[c.Link.Code+c.Kind] {
+			pending = append(pending, c)
+		}
+	}
+	return pending, nil
+}
+
+func (f *fakeStore) ClaimReminder(ctx context.Context, code, kind string) (bool, error) {
+	if f.claimed[code+kind] {
+		return false, nil
+	}
+	f.claimed[code+kind] = true
+	return true, nil
+}
+
+func (f *fakeStore) ReleaseReminder(ctx context.Context, code, kind string) error {
+	delete(f.claimed, code+kind)
+	return nil
+}
+
+type fakeNotifier struct {
+	err  error
+	sent []*Reminder
+}
+
+func (f *fakeNotifier) Notify(ctx context.Context, settings *storage.NotificationSettings, r *Reminder) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.sent = append(f.sent, r)
+	return nil
+}
+
+func newCandidate(code string) *storage.ReminderCandidate {
+	expires := time.Now().Add(24 * time.Hour)
+	return &storage.ReminderCandidate{
+		Kind:     storage.ReminderExpiry,
+		Link:     &storage.Link{Code: code, LongURL: "https://example.com", ExpiresAt: &expires},
+		Settings: &storage.NotificationSettings{},
+	}
+}
+
+func shortURL(link *storage.Link) string { return "https://sho.rt/r/" + link.Code }
+
+func TestScanOnceSendsEachReminderOnce(t *testing.T) {
+	store := &fakeStore{candidates: []*storage.ReminderCandidate{newCandidate("a"), newCandidate("b")}, claimed: map[string]bool{}}
+	notifier := &fakeNotifier{}
+	scanner := NewScanner(store, shortURL, logging.NewLogger(logging.LevelError), notifier)
+
+	sent, err := scanner.ScanOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, sent)
+	assert.Equal(t, "https://sho.rt/r/a", notifier.sent[0].ShortURL)
+
+	sent, err = scanner.ScanOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+}
+
+func TestScanOnceReleasesUndelivered(t *testing.T) {
+	store := &fakeStore{candidates: []*storage.ReminderCandidate{newCandidate("a")}, claimed: map[string]bool{}}
+	failing := &fakeNotifier{err: errors.New("smtp down")}
+	skipped := &fakeNotifier{err: ErrNotApplicable}
+	scanner := NewScanner(store, shortURL, logging.NewLogger(logging.LevelError), failing, skipped)
+
+	sent, err := scanner.ScanOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+	assert.False(t, store.claimed["a"+storage.ReminderExpiry], "failed reminder should be retried")
+}
+
+type fakeProvider struct {
+	sent []*notify.Message
+}
+
+func (f *fakeProvider) Send(ctx context.Context, msg *notify.Message) error {
+	f.sent = append(f.sent, msg)
+	return nil
+}
+
+func TestEmailNotifier(t *testing.T) {
+	provider := &fakeProvider{}
+	mailer, err := notify.NewMailer(provider, "noreply@example.com")
+	require.NoError(t, err)
+	n := NewEmailNotifier(mailer)
+
+	c := newCandidate("abc")
+	r := &Reminder{Kind: c.Kind, Code: "abc", ShortURL: "https://sho.rt/r/abc", ExpiresAt: c.Link.ExpiresAt}
+
+	assert.ErrorIs(t, n.Notify(context.Background(), &storage.NotificationSettings{}, r), ErrNotApplicable)
+
+	email := "owner@example.com"
+	settings := &storage.NotificationSettings{Email: &email, EmailEnabled: true, ExpiryReminders: true}
+	require.NoError(t, n.Notify(context.Background(), settings, r))
+	require.Len(t, provider.sent, 1)
+	assert.Equal(t, email, provider.sent[0].To)
+	assert.Equal(t, "Your short link expires soon", provider.sent[0].Subject)
+	assert.Contains(t, provider.sent[0].Text, "https://sho.rt/r/abc")
+
+	settings.ExpiryReminders = false
+	assert.ErrorIs(t, n.Notify(context.Background(), settings, r), ErrNotApplicable)
+}
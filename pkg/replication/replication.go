@@ -0,0 +1,186 @@
+// Package replication copies link writes between the regions of an
+// active-active deployment. Each region serves redirects from its own
+// Postgres and generates codes from its own share of the code space, so
+// regions never wait on each other. Link writes reach the other regions
+// asynchronously: the outbox relay hands every link event to a Publisher,
+// which sends the link as last written to each peer's Receiver, and peers
+// apply it last-writer-wins (see storage/replication.go). Delivery is at
+// least once and in any order, which last-writer-wins makes safe.
+package replication
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/storage"
+)
+
+// Change operations
+const (
+	ChangeUpsert = "upsert"
+	ChangeDelete = "delete"
+)
+
+// SignatureHeader carries the HMAC-SHA256 of a change, as sha256=<hex>
+const SignatureHeader = "X-Replication-Signature"
+
+// maxChangeSize bounds a change a Receiver accepts
+const maxChangeSize = 1 << 20
+
+// Change is a link write made in Region at At
+type Change struct {
+	Op     string    `json:"op"`
+	Key    string    `json:"key"`
+	Region string    `json:"region"`
+	At     time.Time `json:"at"`
+	// Link is the link as written, with its tags, for upserts. The
+	// password hash isn't part of a link's JSON and is sent beside it.
+	Link         *storage.Link `json:"link,omitempty"`
+	PasswordHash *string       `json:"password_hash,omitempty"`
+}
+
+// Sign returns the hex HMAC-SHA256 of body with secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Publisher sends the link writes of this region to its peers. It is an
+// outbox publisher: the relay retries an event until every peer has it.
+type Publisher struct {
+	links  storage.LinkStorage
+	region string
+	peers  []string
+	secret string
+	client *http.Client
+}
+
+// NewPublisher sends changes to the replication listeners at peers, base
+// URLs such as http://eu.internal:9091
+func NewPublisher(links storage.LinkStorage, region string, peers []string, secret string) *Publisher {
+	return &Publisher{
+		links:  links,
+		region: region,
+		peers:  peers,
+		secret: secret,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *Publisher) PublishEvent(ctx context.Context, event *storage.OutboxEvent) error {
+	change, err := p.change(ctx, event)
+	if err != nil || change == nil {
+		return err
+	}
+	body, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, peer := range p.peers {
+		if err := p.post(ctx, peer, body); err != nil {
+			errs = append(errs, fmt.Errorf("peer %s: %w", peer, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// change is what to send for event, or nil if nothing. Upserts carry the
+// link as it is now rather than the event's copy, which lacks the password
+// hash; if another region has written it since, that region sends it.
+func (p *Publisher) change(ctx context.Context, event *storage.OutboxEvent) (*Change, error) {
+	var written storage.Link
+	if err := json.Unmarshal(event.Payload, &written); err != nil {
+		return nil, err
+	}
+	key := written.Key()
+	switch event.Type {
+	case "link.deleted":
+		return &Change{Op: ChangeDelete, Key: key, Region: p.region, At: event.CreatedAt}, nil
+	case "link.created", "link.updated":
+		link, err := p.links.GetByCode(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		if link == nil || link.Region != p.region {
+			return nil, nil
+		}
+		tags, err := p.links.GetTags(ctx, []string{key})
+		if err != nil {
+			return nil, err
+		}
+		link.Tags = tags[key]
+		return &Change{Op: ChangeUpsert, Key: key, Region: link.Region, At: link.UpdatedAt, Link: link, PasswordHash: link.PasswordHash}, nil
+	}
+	return nil, nil
+}
+
+func (p *Publisher) post(ctx context.Context, peer string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(peer, "/")+"/replicate", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, "sha256="+Sign(p.secret, body))
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("replication returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Applier applies a change from another region; *service.LinkService
+// implements it
+type Applier interface {
+	ApplyChange(ctx context.Context, change *Change) error
+}
+
+// Receiver takes changes sent by peers' Publishers
+type Receiver struct {
+	applier Applier
+	secret  string
+}
+
+func NewReceiver(applier Applier, secret string) *Receiver {
+	return &Receiver{applier: applier, secret: secret}
+}
+
+// ServeHTTP applies a signed change. Anything but a 2xx makes the sender
+// retry it.
+func (rc *Receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxChangeSize+1))
+	if err != nil || len(body) > maxChangeSize {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+	sig, _ := strings.CutPrefix(r.Header.Get(SignatureHeader), "sha256=")
+	if !hmac.Equal([]byte(sig), []byte(Sign(rc.secret, body))) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+	var change Change
+	if err := json.Unmarshal(body, &change); err != nil {
+		http.Error(w, "invalid change", http.StatusBadRequest)
+		return
+	}
+	if err := rc.applier.ApplyChange(r.Context(), &change); err != nil {
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,102 @@
+package replication
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type regionLinks struct {
+	storage.LinkStorage
+	links map[string]*storage.Link
+}
+
+func (l *regionLinks) GetByCode(ctx context.Context, key string) (*storage.Link, error) {
+	if link, ok := l.links[key]; ok {
+		copied := *link
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (l *regionLinks) GetTags(ctx context.Context, keys []string) (map[string][]string, error) {
+	return map[string][]string{"abc": {"launch"}}, nil
+}
+
+type changeLog struct {
+	changes []*Change
+}
+
+func (c *changeLog) ApplyChange(ctx context.Context, change *Change) error {
+	c.changes = append(c.changes, change)
+	return nil
+}
+
+func TestPublisherSendsChangesToPeers(t *testing.T) {
+	applied := &changeLog{}
+	peer := httptest.NewServer(NewReceiver(applied, "s3cret"))
+	defer peer.Close()
+
+	hash := "hash"
+	written := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	links := &regionLinks{links: map[string]*storage.Link{
+		"abc":  {Code: "abc", LongURL: "https://example.com", PasswordHash: &hash, UpdatedAt: written, Region: "us"},
+		"from": {Code: "from", LongURL: "https://example.com", UpdatedAt: written, Region: "eu"},
+	}}
+	p := NewPublisher(links, "us", []string{peer.URL + "/"}, "s3cret")
+	publish := func(eventType, code string) {
+		payload, _ := json.Marshal(&storage.Link{Code: code})
+		require.NoError(t, p.PublishEvent(context.Background(), &storage.OutboxEvent{EventID: uuid.New(), Type: eventType, Payload: payload, CreatedAt: written.Add(time.Hour)}))
+	}
+
+	publish("link.updated", "abc")
+	require.Len(t, applied.changes, 1)
+	change := applied.changes[0]
+	assert.Equal(t, ChangeUpsert, change.Op)
+	assert.Equal(t, "us", change.Region)
+	assert.True(t, written.Equal(change.At))
+	assert.Equal(t, "https://example.com", change.Link.LongURL)
+	assert.Equal(t, []string{"launch"}, change.Link.Tags)
+	require.NotNil(t, change.PasswordHash)
+	assert.Equal(t, "hash", *change.PasswordHash)
+
+	// Links last written elsewhere, or gone, are left to their writer
+	publish("link.updated", "from")
+	publish("link.created", "gone")
+	assert.Len(t, applied.changes, 1)
+
+	publish("link.deleted", "gone")
+	require.Len(t, applied.changes, 2)
+	assert.Equal(t, ChangeDelete, applied.changes[1].Op)
+	assert.Equal(t, "gone", applied.changes[1].Key)
+	assert.True(t, written.Add(time.Hour).Equal(applied.changes[1].At))
+}
+
+func TestReceiverChecksSignature(t *testing.T) {
+	applied := &changeLog{}
+	receiver := NewReceiver(applied, "s3cret")
+	body := `{"op":"delete","key":"abc","region":"eu","at":"2026-03-01T12:00:00Z"}`
+
+	send := func(signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/replicate", strings.NewReader(body))
+		req.Header.Set(SignatureHeader, signature)
+		rec := httptest.NewRecorder()
+		receiver.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusUnauthorized, send(""))
+	assert.Equal(t, http.StatusUnauthorized, send("sha256="+Sign("other", []byte(body))))
+	assert.Empty(t, applied.changes)
+	assert.Equal(t, http.StatusNoContent, send("sha256="+Sign("s3cret", []byte(body))))
+	require.Len(t, applied.changes, 1)
+}
@@ -0,0 +1,204 @@
+// Package reputation decides which link destinations visitors are warned
+// about before being sent there: domains registered recently, going by their
+// RDAP registration date, and domains under top-level domains a deployment
+// considers risky. Fresh domains and cheap TLDs are what phishing runs on,
+// so a warning in front of them makes the shortener less useful for it.
+package reputation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/security"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	rdapURL = "https://rdap.org/domain/"
+	// lookupTimeout bounds an RDAP lookup, which holds up the first visit to
+	// a domain
+	lookupTimeout = 3 * time.Second
+	maxRedirects  = 3
+	// registrationTTL is how long a registration date is cached. Domains
+	// only get older, so this mostly bounds how late a dropped and
+	// re-registered domain is noticed.
+	registrationTTL = 7 * 24 * time.Hour
+	// unknownTTL is how long a failed lookup, or a domain RDAP doesn't know,
+	// is cached
+	unknownTTL = time.Hour
+)
+
+// Reasons a destination is warned about
+const (
+	ReasonNewDomain = "new_domain"
+	ReasonRiskyTLD  = "risky_tld"
+)
+
+// Config decides which domains are untrusted
+type Config struct {
+	// MinAge is how long ago a domain must have been registered to be
+	// trusted; 0 skips registration lookups
+	MinAge time.Duration
+	// TLDs are top-level domains, such as "zip", whose domains are never
+	// trusted
+	TLDs []string
+	// Trusted are domains that, with their subdomains, are never warned
+	// about
+	Trusted []string
+}
+
+// Store caches registration dates; *cache.DomainCache implements it
+type Store interface {
+	// GetRegistration returns when domain was registered, the zero time if
+	// that is unknown, and false if it isn't cached
+	GetRegistration(ctx context.Context, domain string) (time.Time, bool, error)
+	SetRegistration(ctx context.Context, domain string, registered time.Time, ttl time.Duration) error
+}
+
+// Warning says why a destination is untrusted
+type Warning struct {
+	// Domain is the registrable domain of the destination
+	Domain string
+	Reason string
+	// RegisteredAt is set for ReasonNewDomain
+	RegisteredAt *time.Time
+}
+
+type Checker struct {
+	store   Store
+	config  Config
+	logger  *logging.Logger
+	client  *http.Client
+	rdapURL string
+	now     func() time.Time
+}
+
+func NewChecker(store Store, config Config, logger *logging.Logger) *Checker {
+	config.TLDs = normalize(config.TLDs)
+	config.Trusted = normalize(config.Trusted)
+	return &Checker{
+		store:   store,
+		config:  config,
+		logger:  logger,
+		client:  security.NewOutboundClient(lookupTimeout, maxRedirects),
+		rdapURL: rdapURL,
+		now:     time.Now,
+	}
+}
+
+// Check returns why visitors to host should be warned, or nil if they
+// needn't be. Hosts that aren't domain names, such as IP addresses, are
+// never warned about. A failed registration lookup counts as trusted, so
+// RDAP outages don't put a warning in front of every link.
+func (c *Checker) Check(ctx context.Context, host string) *Warning {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if _, err := netip.ParseAddr(host); err == nil {
+		return nil
+	}
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return nil
+	}
+	for _, trusted := range c.config.Trusted {
+		if host == trusted || strings.HasSuffix(host, "."+trusted) {
+			return nil
+		}
+	}
+	for _, tld := range c.config.TLDs {
+		if strings.HasSuffix(domain, "."+tld) {
+			return &Warning{Domain: domain, Reason: ReasonRiskyTLD}
+		}
+	}
+	if c.config.MinAge <= 0 {
+		return nil
+	}
+
+	registered := c.registration(ctx, domain)
+	if registered.IsZero() || c.now().Sub(registered) >= c.config.MinAge {
+		return nil
+	}
+	return &Warning{Domain: domain, Reason: ReasonNewDomain, RegisteredAt: &registered}
+}
+
+// registration returns when domain was registered, from the cache or RDAP,
+// or the zero time if that can't be found out
+func (c *Checker) registration(ctx context.Context, domain string) time.Time {
+	registered, ok, err := c.store.GetRegistration(ctx, domain)
+	if err != nil {
+		c.logger.Warn(ctx, "failed to read cached domain registration", "domain", domain, "error", err)
+	}
+	if ok {
+		return registered
+	}
+
+	registered, err = c.lookup(ctx, domain)
+	ttl := registrationTTL
+	if err != nil {
+		c.logger.Warn(ctx, "RDAP lookup failed", "domain", domain, "error", err)
+	}
+	if registered.IsZero() {
+		ttl = unknownTTL
+	}
+	if err := c.store.SetRegistration(ctx, domain, registered, ttl); err != nil {
+		c.logger.Warn(ctx, "failed to cache domain registration", "domain", domain, "error", err)
+	}
+	return registered
+}
+
+// rdapDomain is the part of an RDAP domain response that is used
+type rdapDomain struct {
+	Events []struct {
+		Action string    `json:"eventAction"`
+		Date   time.Time `json:"eventDate"`
+	} `json:"events"`
+}
+
+// lookup asks RDAP when domain was registered. Domains RDAP doesn't know,
+// such as those under TLDs without an RDAP server, have the zero time.
+func (c *Checker) lookup(ctx context.Context, domain string) (time.Time, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.rdapURL+url.PathEscape(domain), nil)
+	if err != nil {
+		return time.Time{}, err
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return time.Time{}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return time.Time{}, fmt.Errorf("RDAP answered %s", resp.Status)
+	}
+
+	var info rdapDomain
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&info); err != nil {
+		return time.Time{}, err
+	}
+	for _, event := range info.Events {
+		if event.Action == "registration" {
+			return event.Date, nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+// normalize lower cases domains and drops their leading and trailing dots
+func normalize(domains []string) []string {
+	out := make([]string, len(domains))
+	for i, domain := range domains {
+		out[i] = strings.ToLower(strings.Trim(domain, "."))
+	}
+	return out
+}
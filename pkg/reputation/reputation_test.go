@@ -0,0 +1,83 @@
+package reputation
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/logging"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeStore struct {
+	registered map[string]time.Time
+}
+
+func (f *fakeStore) GetRegistration(ctx context.Context, domain string) (time.Time, bool, error) {
+	registered, ok := f.registered[domain]
+	return registered, ok, nil
+}
+
+func (f *fakeStore) SetRegistration(ctx context.Context, domain string, registered time.Time, ttl time.Duration) error {
+	f.registered[domain] = registered
+	return nil
+}
+
+func TestCheck(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	registrations := map[string]time.Time{
+		"fresh.com":       now.Add(-3 * 24 * time.Hour),
+		"established.com": now.Add(-5 * 365 * 24 * time.Hour),
+	}
+	var lookups []string
+	rdap := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		domain := strings.TrimPrefix(r.URL.Path, "/domain/")
+		lookups = append(lookups, domain)
+		registered, ok := registrations[domain]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/rdap+json")
+		fmt.Fprintf(w, `{"ldhName": %q, "events": [{"eventAction": "last changed", "eventDate": "2024-05-30T00:00:00Z"}, {"eventAction": "registration", "eventDate": %q}]}`,
+			domain, registered.Format(time.RFC3339))
+	}))
+	defer rdap.Close()
+
+	store := &fakeStore{registered: make(map[string]time.Time)}
+	c := NewChecker(store, Config{
+		MinAge:  30 * 24 * time.Hour,
+		TLDs:    []string{".ZIP"},
+		Trusted: []string{"trusted.zip"},
+	}, logging.NewLogger(logging.LevelError))
+	c.client = http.DefaultClient
+	c.rdapURL = rdap.URL + "/domain/"
+	c.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	warning := c.Check(ctx, "login.Fresh.com")
+	require.NotNil(t, warning)
+	assert.Equal(t, "fresh.com", warning.Domain)
+	assert.Equal(t, ReasonNewDomain, warning.Reason)
+	assert.Equal(t, registrations["fresh.com"], *warning.RegisteredAt)
+
+	assert.Nil(t, c.Check(ctx, "www.established.com"))
+	assert.Nil(t, c.Check(ctx, "unknown.com"), "domains RDAP doesn't know are trusted")
+	assert.Nil(t, c.Check(ctx, "192.0.2.1"))
+
+	warning = c.Check(ctx, "invoice.zip")
+	require.NotNil(t, warning)
+	assert.Equal(t, ReasonRiskyTLD, warning.Reason)
+	assert.Nil(t, c.Check(ctx, "docs.trusted.zip"))
+
+	// Lookups are cached
+	c.Check(ctx, "fresh.com")
+	c.Check(ctx, "unknown.com")
+	assert.Equal(t, []string{"fresh.com", "established.com", "unknown.com"}, lookups)
+}
@@ -0,0 +1,132 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessCookies issues and checks the cookie that lets a browser through a
+// password-protected link once the password was entered. The value is
+// "<expiry>.<signature>", an HMAC over the link code, the expiry and the
+// visitor's session, so it can't be forged, extended or moved to another
+// link or browser.
+//
+// API clients get an access token of the same form instead, which isn't
+// bound to a session; the two are signed for different purposes so one
+// can't be used as the other. The password form carries a token of the same
+// form too, against cross-site posts, so that it needs no server-side state.
+// Passes for a solved CAPTCHA are signed the
+// same way, for a client address rather than a link, and so are the passes
+// past a destination warning, for both.
+//
+// The first key signs; every key verifies, so a new key can be put first
+// while cookies signed with the old one are still valid.
+type AccessCookies struct {
+	keys [][]byte
+}
+
+// NewAccessCookies uses keys, or a random key when none are given. A random
+// key doesn't survive restarts and isn't shared with other servers, so the
+// API and redirect servers need configured keys to accept each other's
+// cookies.
+func NewAccessCookies(keys ...[]byte) *AccessCookies {
+	if len(keys) == 0 {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			panic("security: no randomness for access cookie key: " + err.Error())
+		}
+		keys = [][]byte{key}
+	}
+	return &AccessCookies{keys: keys}
+}
+
+// Issue returns a cookie value for code and session valid until expires
+func (a *AccessCookies) Issue(code, session string, expires time.Time) string {
+	return a.sign("cookie\n"+code+"\n"+session, expires)
+}
+
+// Valid reports whether value was issued for code and session and hasn't
+// expired at now
+func (a *AccessCookies) Valid(value, code, session string, now time.Time) bool {
+	return a.verify(value, "cookie\n"+code+"\n"+session, now)
+}
+
+// IssueToken returns an access token for code valid until expires
+func (a *AccessCookies) IssueToken(code string, expires time.Time) string {
+	return a.sign("token\n"+code, expires)
+}
+
+// ValidToken reports whether token was issued for code and hasn't expired
+// at now
+func (a *AccessCookies) ValidToken(token, code string, now time.Time) bool {
+	return a.verify(token, "token\n"+code, now)
+}
+
+// IssueFormToken returns the CSRF token of the password form of code for
+// session, valid until expires
+func (a *AccessCookies) IssueFormToken(code, session string, expires time.Time) string {
+	return a.sign("form\n"+code+"\n"+session, expires)
+}
+
+// ValidFormToken reports whether token was issued for code's password form
+// and session and hasn't expired at now
+func (a *AccessCookies) ValidFormToken(token, code, session string, now time.Time) bool {
+	return a.verify(token, "form\n"+code+"\n"+session, now)
+}
+
+// IssuePass returns a cookie value showing that client solved a CAPTCHA,
+// valid until expires
+func (a *AccessCookies) IssuePass(client string, expires time.Time) string {
+	return a.sign("captcha\n"+client, expires)
+}
+
+// ValidPass reports whether value was issued to client by IssuePass and
+// hasn't expired at now
+func (a *AccessCookies) ValidPass(value, client string, now time.Time) bool {
+	return a.verify(value, "captcha\n"+client, now)
+}
+
+// IssueWarningPass returns a value showing that client was warned about
+// code's destination and chose to continue, valid until expires
+func (a *AccessCookies) IssueWarningPass(code, client string, expires time.Time) string {
+	return a.sign("warning\n"+code+"\n"+client, expires)
+}
+
+// ValidWarningPass reports whether value was issued for code and client by
+// IssueWarningPass and hasn't expired at now
+func (a *AccessCookies) ValidWarningPass(value, code, client string, now time.Time) bool {
+	return a.verify(value, "warning\n"+code+"\n"+client, now)
+}
+
+func (a *AccessCookies) sign(subject string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	return exp + "." + accessSignature(a.keys[0], subject, exp)
+}
+
+func (a *AccessCookies) verify(value, subject string, now time.Time) bool {
+	exp, sig, ok := strings.Cut(value, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || !now.Before(time.Unix(unix, 0)) {
+		return false
+	}
+	for _, key := range a.keys {
+		if hmac.Equal([]byte(sig), []byte(accessSignature(key, subject, exp))) {
+			return true
+		}
+	}
+	return false
+}
+
+func accessSignature(key []byte, subject, exp string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(subject + "\n" + exp))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
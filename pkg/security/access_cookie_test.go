@@ -0,0 +1,85 @@
+package security
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessCookies(t *testing.T) {
+	cookies := NewAccessCookies([]byte("secret"))
+	now := time.Now()
+	value := cookies.Issue("abc", "session", now.Add(5*time.Minute))
+
+	assert.True(t, cookies.Valid(value, "abc", "session", now))
+	assert.False(t, cookies.Valid(value, "abc", "session", now.Add(10*time.Minute)))
+	assert.False(t, cookies.Valid(value, "abd", "session", now))
+	assert.False(t, cookies.Valid(value, "abc", "other", now))
+	assert.False(t, cookies.Valid("true", "abc", "session", now))
+	assert.False(t, NewAccessCookies([]byte("other")).Valid(value, "abc", "session", now))
+
+	// Moving the expiry invalidates the signature
+	_, sig, _ := strings.Cut(value, ".")
+	assert.False(t, cookies.Valid("99999999999."+sig, "abc", "session", now))
+}
+
+func TestAccessCookiesKeyRotation(t *testing.T) {
+	now := time.Now()
+	old := NewAccessCookies([]byte("old")).Issue("abc", "session", now.Add(time.Minute))
+
+	rotated := NewAccessCookies([]byte("new"), []byte("old"))
+	assert.True(t, rotated.Valid(old, "abc", "session", now))
+	assert.True(t, NewAccessCookies([]byte("new")).Valid(rotated.Issue("abc", "session", now.Add(time.Minute)), "abc", "session", now))
+}
+
+func TestAccessTokens(t *testing.T) {
+	cookies := NewAccessCookies([]byte("secret"))
+	now := time.Now()
+	token := cookies.IssueToken("abc", now.Add(time.Minute))
+
+	assert.True(t, cookies.ValidToken(token, "abc", now))
+	assert.False(t, cookies.ValidToken(token, "abc", now.Add(2*time.Minute)))
+	assert.False(t, cookies.ValidToken(token, "abd", now))
+
+	// Tokens and cookies aren't interchangeable
+	assert.False(t, cookies.Valid(token, "abc", "", now))
+	assert.False(t, cookies.ValidToken(cookies.Issue("abc", "", now.Add(time.Minute)), "abc", now))
+}
+
+func TestFormTokens(t *testing.T) {
+	cookies := NewAccessCookies([]byte("secret"))
+	now := time.Now()
+	token := cookies.IssueFormToken("abc", "session", now.Add(time.Minute))
+
+	assert.True(t, cookies.ValidFormToken(token, "abc", "session", now))
+	assert.False(t, cookies.ValidFormToken(token, "abc", "other", now))
+	assert.False(t, cookies.ValidFormToken(token, "abd", "session", now))
+	assert.False(t, cookies.ValidFormToken(token, "abc", "session", now.Add(2*time.Minute)))
+	// A form token doesn't open the link
+	assert.False(t, cookies.Valid(token, "abc", "session", now))
+}
+
+func TestCaptchaPasses(t *testing.T) {
+	cookies := NewAccessCookies([]byte("secret"))
+	now := time.Now()
+	pass := cookies.IssuePass("198.51.100.1", now.Add(time.Hour))
+
+	assert.True(t, cookies.ValidPass(pass, "198.51.100.1", now))
+	assert.False(t, cookies.ValidPass(pass, "198.51.100.2", now))
+	assert.False(t, cookies.ValidPass(pass, "198.51.100.1", now.Add(2*time.Hour)))
+	assert.False(t, cookies.ValidToken(pass, "198.51.100.1", now))
+}
+
+func TestWarningPasses(t *testing.T) {
+	cookies := NewAccessCookies([]byte("secret"))
+	now := time.Now()
+	pass := cookies.IssueWarningPass("abc", "198.51.100.1", now.Add(time.Minute))
+
+	assert.True(t, cookies.ValidWarningPass(pass, "abc", "198.51.100.1", now))
+	assert.False(t, cookies.ValidWarningPass(pass, "abd", "198.51.100.1", now))
+	assert.False(t, cookies.ValidWarningPass(pass, "abc", "198.51.100.2", now))
+	assert.False(t, cookies.ValidWarningPass(pass, "abc", "198.51.100.1", now.Add(2*time.Minute)))
+	assert.False(t, cookies.ValidPass(pass, "198.51.100.1", now))
+}
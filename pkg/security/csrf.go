@@ -6,12 +6,16 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
+	"url-shortener/pkg/middleware"
+
 	"github.com/google/uuid"
 )
 
 type CSRFTokenManager struct {
+	mu     sync.Mutex
 	tokens map[string]csrfToken
 }
 
@@ -37,11 +41,13 @@ func (c *CSRFTokenManager) GenerateToken(sessionID string) (string, error) {
 	token := base64.URLEncoding.EncodeToString(tokenBytes)
 
 	// Store with expiration
+	c.mu.Lock()
 	c.tokens[sessionID] = csrfToken{
 		value:     token,
 		createdAt: time.Now(),
 		expires:   time.Now().Add(15 * time.Minute),
 	}
+	c.mu.Unlock()
 
 	// Cleanup expired tokens
 	go c.cleanupExpired()
@@ -50,6 +56,9 @@ func (c *CSRFTokenManager) GenerateToken(sessionID string) (string, error) {
 }
 
 func (c *CSRFTokenManager) ValidateToken(sessionID, providedToken string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	storedToken, exists := c.tokens[sessionID]
 	if !exists {
 		return false
@@ -66,10 +75,15 @@ func (c *CSRFTokenManager) ValidateToken(sessionID, providedToken string) bool {
 }
 
 func (c *CSRFTokenManager) InvalidateToken(sessionID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	delete(c.tokens, sessionID)
 }
 
 func (c *CSRFTokenManager) cleanupExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	now := time.Now()
 	for sessionID, token := range c.tokens {
 		if now.After(token.expires) {
@@ -78,6 +92,12 @@ func (c *CSRFTokenManager) cleanupExpired() {
 	}
 }
 
+// IssueToken generates a token for the caller's session, creating the
+// session cookie first if the request doesn't carry one
+func (c *CSRFTokenManager) IssueToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	return c.GenerateToken(getOrCreateSessionID(w, r))
+}
+
 // CSRF Middleware
 func CSRFMiddleware(tokenManager *CSRFTokenManager) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
@@ -103,6 +123,12 @@ func CSRFMiddleware(tokenManager *CSRFTokenManager) func(http.Handler) http.Hand
 	}
 }
 
+// SessionID returns the caller's session ID, creating the session cookie
+// first if the request doesn't carry one
+func SessionID(w http.ResponseWriter, r *http.Request) string {
+	return getOrCreateSessionID(w, r)
+}
+
 func getOrCreateSessionID(w http.ResponseWriter, r *http.Request) string {
 	cookie, err := r.Cookie("session_id")
 	if err != nil || cookie.Value == "" {
@@ -113,7 +139,7 @@ func getOrCreateSessionID(w http.ResponseWriter, r *http.Request) string {
 			Value:    sessionID,
 			Path:     "/",
 			HttpOnly: true,
-			Secure:   r.TLS != nil,
+			Secure:   middleware.IsHTTPS(r),
 			SameSite: http.SameSiteStrictMode,
 			MaxAge:   86400, // 24 hours
 		})
@@ -0,0 +1,74 @@
+package security
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+var ErrUnknownDeepLinkKey = errors.New("unknown deep link key")
+
+// DeepLinkClaims is what a deep link token vouches for: the destination it
+// was minted for and the context the owner put in Data, such as a user or
+// campaign ID
+type DeepLinkClaims struct {
+	// KeyID names the key the token is signed with
+	KeyID string `json:"kid"`
+	// URL is the destination without the token
+	URL       string         `json:"url"`
+	Data      map[string]any `json:"data,omitempty"`
+	IssuedAt  int64          `json:"iat"`
+	ExpiresAt int64          `json:"exp,omitempty"`
+}
+
+// SignDeepLink returns a token for claims, "<payload>.<signature>": the
+// claims as base64url JSON, and the base64url HMAC-SHA256 of the payload
+// with secret. Tokens are signed, not encrypted, so anyone holding the link
+// can read the claims but not change them.
+func SignDeepLink(secret []byte, claims *DeepLinkClaims) (string, error) {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + deepLinkSignature(secret, payload), nil
+}
+
+// VerifyDeepLink checks token with the secret of its key ID, as returned by
+// secrets, and returns its claims. Tokens past their exp are rejected with
+// ErrSignatureExpired.
+func VerifyDeepLink(token string, secrets func(keyID string) []byte, now time.Time) (*DeepLinkClaims, error) {
+	payload, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidSignature
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	var claims DeepLinkClaims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, ErrInvalidSignature
+	}
+	secret := secrets(claims.KeyID)
+	if secret == nil {
+		return nil, ErrUnknownDeepLinkKey
+	}
+	if !hmac.Equal([]byte(sig), []byte(deepLinkSignature(secret, payload))) {
+		return nil, ErrInvalidSignature
+	}
+	if claims.ExpiresAt != 0 && !now.Before(time.Unix(claims.ExpiresAt, 0)) {
+		return nil, ErrSignatureExpired
+	}
+	return &claims, nil
+}
+
+func deepLinkSignature(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,49 @@
+package security
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeepLinks(t *testing.T) {
+	secrets := func(keyID string) []byte {
+		if keyID == "k1" {
+			return []byte("secret")
+		}
+		return nil
+	}
+	now := time.Now()
+	token, err := SignDeepLink([]byte("secret"), &DeepLinkClaims{
+		KeyID:     "k1",
+		URL:       "https://app.example.com/invite",
+		Data:      map[string]any{"user": "42"},
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Hour).Unix(),
+	})
+	require.NoError(t, err)
+
+	claims, err := VerifyDeepLink(token, secrets, now)
+	require.NoError(t, err)
+	assert.Equal(t, "https://app.example.com/invite", claims.URL)
+	assert.Equal(t, "42", claims.Data["user"])
+
+	_, err = VerifyDeepLink(token, secrets, now.Add(2*time.Hour))
+	assert.ErrorIs(t, err, ErrSignatureExpired)
+
+	// Changing the claims breaks the signature
+	payload, sig, _ := strings.Cut(token, ".")
+	data, _ := base64.RawURLEncoding.DecodeString(payload)
+	forged := base64.RawURLEncoding.EncodeToString([]byte(strings.Replace(string(data), `"42"`, `"43"`, 1)))
+	_, err = VerifyDeepLink(forged+"."+sig, secrets, now)
+	assert.ErrorIs(t, err, ErrInvalidSignature)
+
+	other, err := SignDeepLink([]byte("secret"), &DeepLinkClaims{KeyID: "k2"})
+	require.NoError(t, err)
+	_, err = VerifyDeepLink(other, secrets, now)
+	assert.ErrorIs(t, err, ErrUnknownDeepLinkKey)
+}
@@ -0,0 +1,62 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrDisallowedAddress is returned when an outbound request would reach a
+// private, loopback or otherwise internal address
+var ErrDisallowedAddress = errors.New("destination address not allowed")
+
+// NewOutboundClient returns a client for requests to user-supplied URLs. It
+// refuses to connect to internal addresses, checked on the resolved IP at
+// dial time so that DNS names pointing inside can't get around it, and gives
+// up on any request after timeout. Redirects are followed up to maxRedirects
+// times; 0 returns redirect responses as they are.
+func NewOutboundClient(timeout time.Duration, maxRedirects int) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !PublicAddr(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s", ErrDisallowedAddress, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConnsPerHost:   2,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return http.ErrUseLastResponse
+			}
+			return nil
+		},
+	}
+}
+
+// PublicAddr reports whether addr is a globally routable unicast address
+func PublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !cgnat.Contains(addr)
+}
+
+// cgnat is the carrier-grade NAT range, which IsPrivate doesn't cover
+var cgnat = netip.MustParsePrefix("100.64.0.0/10")
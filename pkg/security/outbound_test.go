@@ -0,0 +1,39 @@
+package security
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboundClientRefusesInternalAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	_, err := NewOutboundClient(time.Second, 0).Get(srv.URL)
+	assert.ErrorIs(t, err, ErrDisallowedAddress)
+}
+
+func TestPublicAddr(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"93.184.216.34":   true,
+		"2606:4700::1111": true,
+		"127.0.0.1":       false,
+		"10.1.2.3":        false,
+		"192.168.0.1":     false,
+		"169.254.169.254": false,
+		"100.64.0.1":      false,
+		"0.0.0.0":         false,
+		"::1":             false,
+		"fd00::1":         false,
+		"::ffff:10.0.0.1": false,
+		"::ffff:8.8.8.8":  true,
+		"224.0.0.1":       false,
+	} {
+		assert.Equal(t, public, PublicAddr(netip.MustParseAddr(addr)), addr)
+	}
+}
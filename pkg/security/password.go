@@ -0,0 +1,138 @@
+package security
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hash algorithms
+const (
+	Argon2id = "argon2id"
+	Bcrypt   = "bcrypt"
+)
+
+var ErrUnknownHash = errors.New("unknown password hash format")
+
+// Argon2Params are the Argon2id cost settings. Memory is in KiB.
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params follow the RFC 9106 second recommended option with
+// fewer lanes: 64 MiB, 3 passes
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// PasswordHasher hashes passwords with one algorithm and verifies hashes
+// made with any supported one, so that existing hashes keep working after
+// the algorithm or its cost changes. Verify reports when a hash should be
+// replaced with one from Hash.
+type PasswordHasher struct {
+	algorithm  string
+	argon2     Argon2Params
+	bcryptCost int
+}
+
+func NewPasswordHasher(algorithm string) (*PasswordHasher, error) {
+	switch algorithm {
+	case Argon2id, Bcrypt:
+	default:
+		return nil, fmt.Errorf("unsupported password hash algorithm %q", algorithm)
+	}
+	return &PasswordHasher{
+		algorithm:  algorithm,
+		argon2:     DefaultArgon2Params,
+		bcryptCost: bcrypt.DefaultCost,
+	}, nil
+}
+
+// Hash returns an encoded hash of password. Argon2id hashes use the PHC
+// string format, e.g. $argon2id$v=19$m=65536,t=3,p=2$<salt>$<key>.
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	if h.algorithm == Bcrypt {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		return string(hash), err
+	}
+
+	p := h.argon2
+	salt := make([]byte, p.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, p.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify reports whether password matches hash, and if so whether hash was
+// made with a different algorithm or cost than Hash would use now
+func (h *PasswordHasher) Verify(hash, password string) (ok, rehash bool, err error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		p, salt, key, err := decodeArgon2(hash)
+		if err != nil {
+			return false, false, err
+		}
+		actual := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(actual, key) != 1 {
+			return false, false, nil
+		}
+		current := h.argon2
+		current.SaltLength, current.KeyLength = uint32(len(salt)), uint32(len(key))
+		return true, h.algorithm != Argon2id || p != current, nil
+
+	case strings.HasPrefix(hash, "$2"):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, false, nil
+		}
+		if err != nil {
+			return false, false, err
+		}
+		cost, err := bcrypt.Cost([]byte(hash))
+		if err != nil {
+			return false, false, err
+		}
+		return true, h.algorithm != Bcrypt || cost != h.bcryptCost, nil
+	}
+	return false, false, ErrUnknownHash
+}
+
+func decodeArgon2(hash string) (p Argon2Params, salt, key []byte, err error) {
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrUnknownHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return p, nil, nil, ErrUnknownHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return p, nil, nil, ErrUnknownHash
+	}
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return p, nil, nil, ErrUnknownHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return p, nil, nil, ErrUnknownHash
+	}
+	p.SaltLength, p.KeyLength = uint32(len(salt)), uint32(len(key))
+	return p, salt, key, nil
+}
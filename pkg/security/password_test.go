@@ -0,0 +1,74 @@
+package security
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheapHasher keeps the tests fast
+func cheapHasher(t *testing.T, algorithm string) *PasswordHasher {
+	t.Helper()
+	h, err := NewPasswordHasher(algorithm)
+	require.NoError(t, err)
+	h.argon2.Memory, h.argon2.Iterations = 1024, 1
+	h.bcryptCost = bcrypt.MinCost
+	return h
+}
+
+func TestArgon2idRoundTrip(t *testing.T) {
+	h := cheapHasher(t, Argon2id)
+	hash, err := h.Hash("hunter2")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=2$"), hash)
+
+	ok, rehash, err := h.Verify(hash, "hunter2")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, rehash)
+
+	ok, _, err = h.Verify(hash, "hunter3")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// Raising the cost asks for a rehash
+	h.argon2.Iterations = 2
+	ok, rehash, err = h.Verify(hash, "hunter2")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash)
+}
+
+func TestBcryptHashesAreUpgraded(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	ok, rehash, err := cheapHasher(t, Argon2id).Verify(string(legacy), "hunter2")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash)
+
+	ok, rehash, err = cheapHasher(t, Bcrypt).Verify(string(legacy), "hunter2")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, rehash)
+
+	ok, rehash, err = cheapHasher(t, Argon2id).Verify(string(legacy), "wrong")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, rehash)
+}
+
+func TestVerifyRejectsUnknownHashes(t *testing.T) {
+	h := cheapHasher(t, Argon2id)
+	for _, hash := range []string{"plaintext", "$argon2id$v=19$m=1024$bad", "$argon2i$v=19$m=1024,t=1,p=1$c2FsdA$a2V5"} {
+		_, _, err := h.Verify(hash, "x")
+		assert.ErrorIs(t, err, ErrUnknownHash, hash)
+	}
+
+	_, err := NewPasswordHasher("md5")
+	assert.Error(t, err)
+}
@@ -0,0 +1,72 @@
+package security
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLSigner(t *testing.T) {
+	signer := NewURLSigner([]byte("secret"))
+	now := time.Now()
+	signed, err := url.Parse(signer.Sign("/v1/links/abc/stats", now.Add(time.Hour)))
+	require.NoError(t, err)
+	query := signed.Query()
+
+	assert.NoError(t, signer.Verify("/v1/links/abc/stats", query, now))
+	assert.ErrorIs(t, signer.Verify("/v1/links/abd/stats", query, now), ErrInvalidSignature)
+	assert.ErrorIs(t, signer.Verify("/v1/links/abc/stats", query, now.Add(2*time.Hour)), ErrSignatureExpired)
+	assert.ErrorIs(t, NewURLSigner([]byte("other")).Verify("/v1/links/abc/stats", query, now), ErrInvalidSignature)
+
+	extended := url.Values{"exp": {"99999999999"}, "sig": {query.Get("sig")}}
+	assert.ErrorIs(t, signer.Verify("/v1/links/abc/stats", extended, now), ErrInvalidSignature)
+
+	// Other query parameters are signed too, and can't be added or changed
+	added := url.Values{"exp": query["exp"], "sig": query["sig"], "domain": {"other.example"}}
+	assert.ErrorIs(t, signer.Verify("/v1/links/abc/stats", added, now), ErrInvalidSignature)
+	signed, err = url.Parse(signer.Sign("/v1/links/abc/stats?domain=go.example", now.Add(time.Hour)))
+	require.NoError(t, err)
+	query = signed.Query()
+	assert.Equal(t, "go.example", query.Get("domain"))
+	assert.NoError(t, signer.Verify("/v1/links/abc/stats", query, now))
+	query.Set("domain", "other.example")
+	assert.ErrorIs(t, signer.Verify("/v1/links/abc/stats", query, now), ErrInvalidSignature)
+	query.Del("domain")
+	assert.ErrorIs(t, signer.Verify("/v1/links/abc/stats", query, now), ErrInvalidSignature)
+}
+
+func TestSignedURLMiddleware(t *testing.T) {
+	signer := NewURLSigner([]byte("secret"))
+	denyAll := func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+		})
+	}
+	handler := SignedURLMiddleware(signer, denyAll)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.True(t, IsSignedRequest(r.Context()))
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+	}{
+		{"valid signature", signer.Sign("/stats", time.Now().Add(time.Hour)), http.StatusOK},
+		{"expired", signer.Sign("/stats", time.Now().Add(-time.Hour)), http.StatusForbidden},
+		{"tampered", "/stats?exp=1&sig=nope", http.StatusForbidden},
+		{"unsigned falls back", "/stats", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.target, nil))
+			assert.Equal(t, tt.status, rec.Code)
+		})
+	}
+}
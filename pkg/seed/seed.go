@@ -0,0 +1,419 @@
+// Package seed fills a development database and Redis with demo data:
+// owners with preferences, campaigns and bundles, links with a spread of
+// settings, and click histories, so that the dashboard, stats and
+// leaderboards have something to show as soon as a server starts. See
+// cmd/seed.
+//
+// Seeding is idempotent. Everything is created under codes, slugs and IDs
+// derived from the owner, and what already exists is left alone, so running
+// it again only fills in what is missing, and clicks are only added to links
+// it created.
+package seed
+
+import (
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/security"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+)
+
+// Owner is a user data is seeded for
+type Owner struct {
+	ID   uuid.UUID
+	Name string
+}
+
+// DemoOwners are the users of the integration tests' Keycloak realm,
+// internal/testutil/testdata/realm.json, so that with the realm imported
+// one can sign in as them
+var DemoOwners = []Owner{
+	{ID: uuid.MustParse("6f1c2a9e-3b4d-4e5f-8a7b-1c2d3e4f5a6b"), Name: "alice"},
+	{ID: uuid.MustParse("0b9a8c7d-6e5f-4a3b-9c2d-7e6f5a4b3c2d"), Name: "bob"},
+}
+
+// DemoPassword opens the password-protected demo links
+const DemoPassword = "demo-password"
+
+// Stores are where seeded data is written
+type Stores struct {
+	Links       storage.LinkStorage
+	Campaigns   storage.CampaignStorage
+	Bundles     storage.BundleStorage
+	Preferences storage.PreferencesStorage
+	Cache       cache.LinkCacheInterface
+}
+
+// Options shape the seeded data
+type Options struct {
+	Owners []Owner
+	// Domain is a custom short domain some links are created on; none are
+	// when it is empty
+	Domain string
+	// Days of click history, ending today
+	Days int
+	// Seed makes the click histories reproducible
+	Seed int64
+	Now  time.Time
+	// Passwords hashes the password of protected links
+	Passwords *security.PasswordHasher
+}
+
+// Summary counts what Run created
+type Summary struct {
+	Links     int
+	Existing  int
+	Clicks    int64
+	Campaigns int
+	Bundles   int
+}
+
+// linkSpec describes one demo link; each owner gets one of each
+type linkSpec struct {
+	name    string
+	longURL string
+	tags    []string
+	// popularity scales the daily clicks
+	popularity float64
+	configure  func(link *storage.Link, opts Options)
+	// campaign links join the owner's demo campaign
+	campaign bool
+	// custom links are created on Options.Domain
+	custom bool
+	// protected links open with DemoPassword
+	protected bool
+}
+
+var linkSpecs = []linkSpec{
+	{
+		name:       "docs",
+		longURL:    "https://go.dev/doc/",
+		tags:       []string{"docs"},
+		popularity: 1,
+		configure: func(link *storage.Link, opts Options) {
+			link.PublicStats = true
+			link.Description = ptr("Go documentation, with public stats at /r/{code}/stats")
+		},
+	},
+	{
+		name:       "launch",
+		longURL:    "https://example.com/launch?utm_source=newsletter&utm_campaign=spring",
+		tags:       []string{"marketing", "launch"},
+		popularity: 3,
+		campaign:   true,
+		configure: func(link *storage.Link, opts Options) {
+			link.Metadata = map[string]any{"utm_campaign": "spring", "channel": "newsletter"}
+		},
+	},
+	{
+		name:       "social",
+		longURL:    "https://example.com/launch?utm_source=social&utm_campaign=spring",
+		tags:       []string{"marketing", "social"},
+		popularity: 2,
+		campaign:   true,
+	},
+	{
+		name:       "permanent",
+		longURL:    "https://example.com/about",
+		popularity: 0.5,
+		configure: func(link *storage.Link, opts Options) {
+			link.RedirectType = 301
+		},
+	},
+	{
+		name:       "private",
+		longURL:    "https://example.com/internal/roadmap",
+		tags:       []string{"internal"},
+		popularity: 0.2,
+		protected:  true,
+		configure: func(link *storage.Link, opts Options) {
+			link.NoIndex = true
+		},
+	},
+	{
+		name:       "expiring",
+		longURL:    "https://example.com/webinar",
+		tags:       []string{"events"},
+		popularity: 1.5,
+		configure: func(link *storage.Link, opts Options) {
+			expires := opts.Now.AddDate(0, 0, 3)
+			link.ExpiresAt = &expires
+		},
+	},
+	{
+		name:       "expired",
+		longURL:    "https://example.com/winter-sale",
+		tags:       []string{"marketing"},
+		popularity: 1,
+		configure: func(link *storage.Link, opts Options) {
+			expires := opts.Now.AddDate(0, 0, -5)
+			link.ExpiresAt = &expires
+		},
+	},
+	{
+		name:       "limited",
+		longURL:    "https://example.com/beta-invite",
+		popularity: 0.3,
+		configure: func(link *storage.Link, opts Options) {
+			link.MaxClicks = ptr(250)
+		},
+	},
+	{
+		name:       "office",
+		longURL:    "https://example.com/intranet",
+		tags:       []string{"internal"},
+		popularity: 0.2,
+		configure: func(link *storage.Link, opts Options) {
+			link.AllowCIDRs = []string{"10.0.0.0/8", "192.168.0.0/16"}
+		},
+	},
+	{
+		name:       "throttled",
+		longURL:    "https://example.com/download",
+		popularity: 0.8,
+		configure: func(link *storage.Link, opts Options) {
+			link.RateLimit = ptr(60)
+			link.RateLimitWindow = "minute"
+		},
+	},
+	{
+		name:       "branded",
+		longURL:    "https://example.com/",
+		tags:       []string{"brand"},
+		popularity: 2.5,
+		custom:     true,
+	},
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// Run seeds opts.Owners with stores
+func Run(ctx context.Context, stores Stores, opts Options) (*Summary, error) {
+	summary := &Summary{}
+	random := rand.New(rand.NewSource(opts.Seed))
+	for _, owner := range opts.Owners {
+		if err := seedOwner(ctx, stores, opts, owner, random, summary); err != nil {
+			return summary, fmt.Errorf("seeding %s: %w", owner.Name, err)
+		}
+	}
+	return summary, nil
+}
+
+func seedOwner(ctx context.Context, stores Stores, opts Options, owner Owner, random *rand.Rand, summary *Summary) error {
+	prefs := &storage.Preferences{
+		OwnerID:             owner.ID,
+		DefaultRedirectType: ptr(302),
+		DefaultTags:         []string{"demo"},
+		UpdatedAt:           opts.Now,
+	}
+	if opts.Domain != "" {
+		prefs.DefaultDomain = &opts.Domain
+	}
+	if err := stores.Preferences.SavePreferences(ctx, prefs); err != nil {
+		return err
+	}
+
+	campaignID := derivedID(owner, "campaign")
+	campaign, err := stores.Campaigns.GetCampaign(ctx, campaignID)
+	if err != nil {
+		return err
+	}
+	if campaign == nil {
+		err := stores.Campaigns.CreateCampaign(ctx, &storage.Campaign{
+			ID:          campaignID,
+			OwnerID:     owner.ID,
+			Name:        "Spring launch",
+			Description: ptr("Demo campaign of the launch links"),
+			CreatedAt:   opts.Now,
+			UpdatedAt:   opts.Now,
+		})
+		if err != nil {
+			return err
+		}
+		summary.Campaigns++
+	}
+
+	for _, spec := range linkSpecs {
+		if spec.custom && opts.Domain == "" {
+			continue
+		}
+		link := &storage.Link{
+			Code:         derivedCode(owner, spec.name),
+			LongURL:      spec.longURL,
+			OwnerID:      &owner.ID,
+			RedirectType: 302,
+			CreatedAt:    opts.Now,
+			UpdatedAt:    opts.Now,
+		}
+		if spec.custom {
+			link.Domain = &opts.Domain
+		}
+		if spec.campaign {
+			link.CampaignID = &campaignID
+		}
+		if spec.protected {
+			hash, err := opts.Passwords.Hash(DemoPassword)
+			if err != nil {
+				return err
+			}
+			link.PasswordHash = &hash
+		}
+		if spec.configure != nil {
+			spec.configure(link, opts)
+		}
+
+		created, err := createLink(ctx, stores, link, spec.tags)
+		if err != nil {
+			return fmt.Errorf("link %s: %w", spec.name, err)
+		}
+		if !created {
+			summary.Existing++
+			continue
+		}
+		summary.Links++
+		clicks, err := seedClicks(ctx, stores, opts, link, spec.popularity, random)
+		if err != nil {
+			return fmt.Errorf("clicks of %s: %w", spec.name, err)
+		}
+		summary.Clicks += clicks
+	}
+
+	slug := owner.Name + "-links"
+	bundle, err := stores.Bundles.GetBundle(ctx, slug)
+	if err != nil {
+		return err
+	}
+	if bundle == nil {
+		err := stores.Bundles.CreateBundle(ctx, &storage.Bundle{
+			Slug:    slug,
+			Title:   "Links of " + owner.Name,
+			OwnerID: owner.ID,
+			Entries: []*storage.BundleEntry{
+				{Title: "Blog", URL: "https://example.com/blog"},
+				{Title: "Spring launch", URL: "https://example.com/launch"},
+				{Title: "Docs", URL: "https://go.dev/doc/"},
+			},
+			CreatedAt: opts.Now,
+			UpdatedAt: opts.Now,
+		})
+		if err != nil {
+			return err
+		}
+		summary.Bundles++
+	}
+	return nil
+}
+
+// createLink creates link with tags unless its key is taken, and reports
+// whether it did
+func createLink(ctx context.Context, stores Stores, link *storage.Link, tags []string) (bool, error) {
+	existing, err := stores.Links.GetByCode(ctx, link.Key())
+	if err != nil || existing != nil {
+		return false, err
+	}
+	if err := stores.Links.Create(ctx, link); err != nil {
+		return false, err
+	}
+	if len(tags) > 0 {
+		if err := stores.Links.SetTags(ctx, link.Key(), tags); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// Visitors are drawn from these
+var (
+	referrers  = []string{"", "https://www.google.com/", "https://news.ycombinator.com/", "https://twitter.com/", "https://mail.example.com/"}
+	userAgents = []string{
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_5) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Safari/605.1.15",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148",
+		"Mozilla/5.0 (Linux; Android 14) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Mobile Safari/537.36",
+	}
+)
+
+// seedClicks records a click history for link in the cache's daily counts
+// and leaderboards, and adds its total to the link's count
+func seedClicks(ctx context.Context, stores Stores, opts Options, link *storage.Link, popularity float64, random *rand.Rand) (int64, error) {
+	counts := dailyClicks(opts.Days, popularity, random)
+	today := opts.Now.UTC().Truncate(24 * time.Hour)
+	var clicks []*cache.StreamedClick
+	for i, n := range counts {
+		day := today.AddDate(0, 0, i+1-len(counts))
+		if link.ExpiresAt != nil && !day.Before(*link.ExpiresAt) {
+			break
+		}
+		for range n {
+			if link.MaxClicks != nil && len(clicks) >= *link.MaxClicks {
+				break
+			}
+			at := day.Add(time.Duration(random.Int63n(int64(24 * time.Hour))))
+			if at.After(opts.Now) {
+				// Today's clicks happened so far
+				at = opts.Now.Add(-time.Duration(random.Int63n(int64(opts.Now.Sub(day) + 1))))
+			}
+			if link.ExpiresAt != nil && !at.Before(*link.ExpiresAt) {
+				continue
+			}
+			clicks = append(clicks, &cache.StreamedClick{
+				Key:       link.Key(),
+				OwnerID:   link.OwnerID,
+				At:        at,
+				Referrer:  referrers[random.Intn(len(referrers))],
+				UserAgent: userAgents[random.Intn(len(userAgents))],
+			})
+		}
+	}
+	if len(clicks) == 0 {
+		return 0, nil
+	}
+	if err := stores.Cache.RecordClicks(ctx, clicks); err != nil {
+		return 0, err
+	}
+	return int64(len(clicks)), stores.Links.AddClickCount(ctx, link.Key(), int64(len(clicks)))
+}
+
+// dailyClicks returns the clicks of each of days days, oldest first: a
+// level set by popularity that grows over the period, dips at weekends and
+// varies from day to day
+func dailyClicks(days int, popularity float64, random *rand.Rand) []int {
+	counts := make([]int, days)
+	for i := range counts {
+		level := 20 * popularity * (0.5 + float64(i+1)/float64(days))
+		if i%7 >= 5 {
+			level *= 0.6
+		}
+		level *= 0.75 + 0.5*random.Float64()
+		counts[i] = int(math.Round(level))
+	}
+	return counts
+}
+
+// derivedID is the ID of owner's seeded object name
+func derivedID(owner Owner, name string) uuid.UUID {
+	return uuid.NewSHA1(owner.ID, []byte(name))
+}
+
+// codeAlphabet is that of generated codes
+const codeAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// derivedCode is the code of owner's seeded link name, so that seeding
+// again finds it
+func derivedCode(owner Owner, name string) string {
+	sum := sha1.Sum(append(owner.ID[:], name...))
+	code := make([]byte, 7)
+	for i := range code {
+		code[i] = codeAlphabet[int(sum[i])%len(codeAlphabet)]
+	}
+	return string(code)
+}
@@ -0,0 +1,192 @@
+package seed
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/security"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeLinks struct {
+	storage.LinkStorage
+	links  map[string]*storage.Link
+	tags   map[string][]string
+	clicks map[string]int64
+}
+
+func (f *fakeLinks) GetByCode(ctx context.Context, key string) (*storage.Link, error) {
+	return f.links[key], nil
+}
+
+func (f *fakeLinks) Create(ctx context.Context, link *storage.Link) error {
+	f.links[link.Key()] = link
+	return nil
+}
+
+func (f *fakeLinks) SetTags(ctx context.Context, key string, tags []string) error {
+	f.tags[key] = tags
+	return nil
+}
+
+func (f *fakeLinks) AddClickCount(ctx context.Context, key string, n int64) error {
+	f.clicks[key] += n
+	return nil
+}
+
+type fakeCampaigns struct {
+	storage.CampaignStorage
+	campaigns map[uuid.UUID]*storage.Campaign
+}
+
+func (f *fakeCampaigns) GetCampaign(ctx context.Context, id uuid.UUID) (*storage.Campaign, error) {
+	return f.campaigns[id], nil
+}
+
+func (f *fakeCampaigns) CreateCampaign(ctx context.Context, campaign *storage.Campaign) error {
+	f.campaigns[campaign.ID] = campaign
+	return nil
+}
+
+type fakeBundles struct {
+	storage.BundleStorage
+	bundles map[string]*storage.Bundle
+}
+
+func (f *fakeBundles) GetBundle(ctx context.Context, slug string) (*storage.Bundle, error) {
+	return f.bundles[slug], nil
+}
+
+func (f *fakeBundles) CreateBundle(ctx context.Context, bundle *storage.Bundle) error {
+	f.bundles[bundle.Slug] = bundle
+	return nil
+}
+
+type fakePreferences struct {
+	storage.PreferencesStorage
+	saved map[uuid.UUID]*storage.Preferences
+}
+
+func (f *fakePreferences) SavePreferences(ctx context.Context, prefs *storage.Preferences) error {
+	f.saved[prefs.OwnerID] = prefs
+	return nil
+}
+
+type fakeCache struct {
+	cache.LinkCacheInterface
+	clicks []*cache.StreamedClick
+}
+
+func (f *fakeCache) RecordClicks(ctx context.Context, clicks []*cache.StreamedClick) error {
+	f.clicks = append(f.clicks, clicks...)
+	return nil
+}
+
+type fakes struct {
+	links       *fakeLinks
+	campaigns   *fakeCampaigns
+	bundles     *fakeBundles
+	preferences *fakePreferences
+	cache       *fakeCache
+}
+
+func newFakes() *fakes {
+	return &fakes{
+		links:       &fakeLinks{links: map[string]*storage.Link{}, tags: map[string][]string{}, clicks: map[string]int64{}},
+		campaigns:   &fakeCampaigns{campaigns: map[uuid.UUID]*storage.Campaign{}},
+		bundles:     &fakeBundles{bundles: map[string]*storage.Bundle{}},
+		preferences: &fakePreferences{saved: map[uuid.UUID]*storage.Preferences{}},
+		cache:       &fakeCache{},
+	}
+}
+
+func (f *fakes) stores() Stores {
+	return Stores{Links: f.links, Campaigns: f.campaigns, Bundles: f.bundles, Preferences: f.preferences, Cache: f.cache}
+}
+
+func testOptions(t *testing.T) Options {
+	passwords, err := security.NewPasswordHasher("bcrypt")
+	require.NoError(t, err)
+	return Options{
+		Owners:    DemoOwners,
+		Domain:    "go.example.com",
+		Days:      30,
+		Seed:      1,
+		Now:       time.Date(2026, 3, 18, 15, 0, 0, 0, time.UTC),
+		Passwords: passwords,
+	}
+}
+
+func TestRun(t *testing.T) {
+	f := newFakes()
+	opts := testOptions(t)
+
+	summary, err := Run(context.Background(), f.stores(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, len(linkSpecs)*len(DemoOwners), summary.Links)
+	assert.Equal(t, len(DemoOwners), summary.Campaigns)
+	assert.Equal(t, len(DemoOwners), summary.Bundles)
+	assert.Len(t, f.preferences.saved, len(DemoOwners))
+	assert.Equal(t, int64(len(f.cache.clicks)), summary.Clicks)
+
+	// Link counts match the clicks recorded for them, which happened in
+	// the period and while the link worked
+	recorded := map[string]int64{}
+	for _, click := range f.cache.clicks {
+		recorded[click.Key]++
+		link := f.links.links[click.Key]
+		require.NotNil(t, link)
+		assert.Equal(t, link.OwnerID, click.OwnerID)
+		assert.False(t, click.At.After(opts.Now), "clicks are in the past")
+		assert.True(t, click.At.After(opts.Now.AddDate(0, 0, -opts.Days)))
+		if link.ExpiresAt != nil {
+			assert.True(t, click.At.Before(*link.ExpiresAt), "clicks are before expiry")
+		}
+	}
+	assert.Equal(t, recorded, f.links.clicks)
+	for key, link := range f.links.links {
+		if link.MaxClicks != nil {
+			assert.LessOrEqual(t, f.links.clicks[key], int64(*link.MaxClicks))
+		}
+		if link.PasswordHash != nil {
+			ok, _, err := opts.Passwords.Verify(*link.PasswordHash, DemoPassword)
+			require.NoError(t, err)
+			assert.True(t, ok)
+		}
+	}
+
+	t.Run("Idempotent", func(t *testing.T) {
+		clicks := len(f.cache.clicks)
+		again, err := Run(context.Background(), f.stores(), opts)
+		require.NoError(t, err)
+		assert.Equal(t, &Summary{Existing: summary.Links}, again)
+		assert.Len(t, f.cache.clicks, clicks, "existing links get no more clicks")
+	})
+}
+
+func TestRunWithoutDomain(t *testing.T) {
+	f := newFakes()
+	opts := testOptions(t)
+	opts.Domain = ""
+
+	summary, err := Run(context.Background(), f.stores(), opts)
+	require.NoError(t, err)
+	assert.Equal(t, (len(linkSpecs)-1)*len(DemoOwners), summary.Links)
+	for _, link := range f.links.links {
+		assert.Nil(t, link.Domain)
+	}
+}
+
+func TestDerivedCode(t *testing.T) {
+	alice, bob := DemoOwners[0], DemoOwners[1]
+	assert.Equal(t, derivedCode(alice, "docs"), derivedCode(alice, "docs"))
+	assert.NotEqual(t, derivedCode(alice, "docs"), derivedCode(bob, "docs"))
+	assert.NotEqual(t, derivedCode(alice, "docs"), derivedCode(alice, "launch"))
+	assert.Len(t, derivedCode(alice, "docs"), 7)
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+)
+
+// AccountService describes the caller's account: who the token resolved to,
+// what it may do and how much of its limits it has used
+type AccountService struct {
+	users       storage.UserStorage
+	terms       *TermsService
+	plans       *PlanService
+	rateLimiter *middleware.RateLimiter
+}
+
+func NewAccountService(users storage.UserStorage) *AccountService {
+	return &AccountService{users: users}
+}
+
+// UseTerms reports the caller's terms of service acceptance
+func (s *AccountService) UseTerms(terms *TermsService) {
+	s.terms = terms
+}
+
+// UsePlans reports the caller's plan and its limits
+func (s *AccountService) UsePlans(plans *PlanService) {
+	s.plans = plans
+}
+
+// UseRateLimit reports the request limit enforced by rl
+func (s *AccountService) UseRateLimit(rl *middleware.RateLimiter) {
+	s.rateLimiter = rl
+}
+
+// Me is the caller's account
+type Me struct {
+	OwnerID   uuid.UUID     `json:"owner_id"`
+	Email     *string       `json:"email,omitempty"`
+	Scopes    []string      `json:"scopes"`
+	Plan      string        `json:"plan,omitempty"`
+	Limits    Limits        `json:"limits"`
+	Usage     storage.Usage `json:"usage"`
+	Suspended bool          `json:"suspended"`
+	Terms     *TermsStatus  `json:"terms,omitempty"`
+}
+
+// Me returns the caller's account. Creates are counted from midnight UTC.
+func (s *AccountService) Me(ctx context.Context) (*Me, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	usage, err := s.users.GetUsage(ctx, ownerID, today)
+	if err != nil {
+		return nil, err
+	}
+	suspension, err := s.users.GetSuspension(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	me := &Me{
+		OwnerID:   ownerID,
+		Scopes:    strings.Fields(middleware.GetScopeFromContext(ctx)),
+		Usage:     *usage,
+		Suspended: suspension != nil,
+	}
+	if email := middleware.GetEmailFromContext(ctx); email != "" {
+		me.Email = &email
+	}
+	if s.plans != nil {
+		plan, err := s.plans.PlanOf(ctx, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		me.Plan = plan.Name
+		me.Limits = plan.Limits
+	}
+	// Without a per-owner limit, the per-client one is what applies
+	if me.Limits.RequestsPerMinute == nil && s.rateLimiter != nil && s.rateLimiter.Limit() > 0 {
+		me.Limits.RequestsPerMinute = limit(s.rateLimiter.Limit())
+	}
+	if s.terms != nil {
+		if me.Terms, err = s.terms.StatusOf(ctx, ownerID); err != nil {
+			return nil, err
+		}
+	}
+	return me, nil
+}
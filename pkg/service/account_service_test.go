@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMe(t *testing.T) {
+	ownerID := uuid.New()
+	users := &fakeUserStorage{
+		suspensions: map[uuid.UUID]*storage.Suspension{},
+		usage:       storage.Usage{Links: 12, CreatesToday: 3},
+	}
+	svc := NewAccountService(users)
+	svc.UseRateLimit(middleware.NewRateLimiter(120))
+
+	ctx := context.WithValue(context.Background(), "owner_id", ownerID)
+	ctx = context.WithValue(ctx, "email", "user@example.com")
+	ctx = context.WithValue(ctx, "scope", "links:read links:write")
+
+	me, err := svc.Me(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ownerID, me.OwnerID)
+	require.NotNil(t, me.Email)
+	assert.Equal(t, "user@example.com", *me.Email)
+	assert.Equal(t, []string{"links:read", "links:write"}, me.Scopes)
+	require.NotNil(t, me.Limits.RequestsPerMinute)
+	assert.Equal(t, 120, *me.Limits.RequestsPerMinute)
+	assert.Nil(t, me.Limits.MaxLinks)
+	assert.Equal(t, storage.Usage{Links: 12, CreatesToday: 3}, me.Usage)
+	assert.False(t, me.Suspended)
+	assert.Nil(t, me.Terms)
+
+	users.suspensions[ownerID] = &storage.Suspension{OwnerID: ownerID, Reason: "spam"}
+	me, err = svc.Me(ctx)
+	require.NoError(t, err)
+	assert.True(t, me.Suspended)
+
+	_, err = svc.Me(context.Background())
+	assert.Error(t, err)
+}
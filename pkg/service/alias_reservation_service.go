@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// maxAliasReservationsPerOwner caps reservations even on plans without a
+	// limit
+	maxAliasReservationsPerOwner = 50
+	// minReservedPrefix is the shortest prefix a wildcard pattern may have,
+	// so that nobody reserves most of the alias space
+	minReservedPrefix = 3
+)
+
+var (
+	ErrAliasReservationNotFound = errors.New("alias reservation not found")
+	ErrTooManyAliasReservations = fmt.Errorf("at most %d alias reservations per owner", maxAliasReservationsPerOwner)
+	// ErrAliasReservationConflict is returned for a pattern that overlaps an
+	// existing reservation
+	ErrAliasReservationConflict = errors.New("pattern overlaps an existing reservation")
+)
+
+// errAliasReserved rejects an alias another owner reserved
+var errAliasReserved = validation.Errors{{Field: "alias", Rule: "reserved", Message: "is reserved by another account"}}
+
+func init() {
+	validation.Register("alias_pattern", func(v reflect.Value, _ string) (bool, string) {
+		return validAliasPattern(v.String()), "must be an alias, or an alias prefix of at least 3 characters followed by '*'"
+	})
+}
+
+// AliasReservationService lets owners reserve aliases, or every alias with a
+// prefix, so that only they can create links with them
+type AliasReservationService struct {
+	storage storage.AliasReservationStorage
+	plans   *PlanService
+}
+
+func NewAliasReservationService(storage storage.AliasReservationStorage) *AliasReservationService {
+	return &AliasReservationService{storage: storage}
+}
+
+// UsePlans only lets owners whose plan allows it reserve aliases. Without
+// plans, every owner can.
+func (s *AliasReservationService) UsePlans(plans *PlanService) {
+	s.plans = plans
+}
+
+type CreateAliasReservationRequest struct {
+	// Pattern is an alias, such as "launch", or a prefix and "*", such as
+	// "promo-*"
+	Pattern string `json:"pattern" validate:"required,alias_pattern"`
+}
+
+type AliasReservation struct {
+	ID        uuid.UUID `json:"id"`
+	Pattern   string    `json:"pattern"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (s *AliasReservationService) CreateReservation(ctx context.Context, req *CreateAliasReservationRequest) (*AliasReservation, error) {
+	req.Pattern = normalizePattern(req.Pattern)
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	existing, err := s.storage.ListAliasReservationsByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxAliasReservationsPerOwner {
+		return nil, ErrTooManyAliasReservations
+	}
+	if s.plans != nil {
+		if err := s.plans.checkAliasReservation(ctx, ownerID, len(existing)); err != nil {
+			return nil, err
+		}
+	}
+
+	all, err := s.storage.ListAliasReservations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range all {
+		if patternsOverlap(req.Pattern, other.Pattern) {
+			return nil, ErrAliasReservationConflict
+		}
+	}
+
+	reservation := &storage.AliasReservation{
+		ID:        uuid.New(),
+		OwnerID:   ownerID,
+		Pattern:   req.Pattern,
+		CreatedAt: time.Now(),
+	}
+	if err := s.storage.CreateAliasReservation(ctx, reservation); err != nil {
+		return nil, err
+	}
+	return toAliasReservation(reservation), nil
+}
+
+func (s *AliasReservationService) ListReservations(ctx context.Context) ([]*AliasReservation, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	reservations, err := s.storage.ListAliasReservationsByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*AliasReservation, len(reservations))
+	for i, r := range reservations {
+		out[i] = toAliasReservation(r)
+	}
+	return out, nil
+}
+
+func (s *AliasReservationService) DeleteReservation(ctx context.Context, id uuid.UUID) error {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return errors.New("owner_id not found in context")
+	}
+
+	reservation, err := s.storage.GetAliasReservation(ctx, id)
+	if err != nil {
+		return err
+	}
+	if reservation == nil {
+		return ErrAliasReservationNotFound
+	}
+	if reservation.OwnerID != ownerID {
+		return ErrNotOwner
+	}
+	return s.storage.DeleteAliasReservation(ctx, id)
+}
+
+func toAliasReservation(r *storage.AliasReservation) *AliasReservation {
+	return &AliasReservation{
+		ID:        r.ID,
+		Pattern:   r.Pattern,
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+// normalizePattern puts a pattern in the form aliases are compared in: NFC,
+// lower case
+func normalizePattern(pattern string) string {
+	return strings.ToLower(NormalizeAlias(strings.TrimSpace(pattern)))
+}
+
+func validAliasPattern(pattern string) bool {
+	prefix, wildcard := strings.CutSuffix(pattern, "*")
+	if prefix == "" || !ValidateAlias(prefix) {
+		return false
+	}
+	return !wildcard || len([]rune(prefix)) >= minReservedPrefix
+}
+
+// matchesPattern reports whether alias, in lower case, falls under pattern
+func matchesPattern(pattern, alias string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(alias, prefix)
+	}
+	return alias == pattern
+}
+
+// patternsOverlap reports whether some alias falls under both patterns
+func patternsOverlap(a, b string) bool {
+	aPrefix, aWildcard := strings.CutSuffix(a, "*")
+	bPrefix, bWildcard := strings.CutSuffix(b, "*")
+	switch {
+	case aWildcard && bWildcard:
+		return strings.HasPrefix(aPrefix, bPrefix) || strings.HasPrefix(bPrefix, aPrefix)
+	case aWildcard:
+		return strings.HasPrefix(bPrefix, aPrefix)
+	case bWildcard:
+		return strings.HasPrefix(aPrefix, bPrefix)
+	}
+	return a == b
+}
+
+// checkAliasReservation returns errAliasReserved if another owner than
+// ownerID reserved alias. It runs in the create transaction.
+func (s *LinkService) checkAliasReservation(ctx context.Context, tx pgx.Tx, ownerID uuid.UUID, alias string) error {
+	if s.reservations == nil {
+		return nil
+	}
+	reservation, err := s.reservations.MatchAliasReservationTx(ctx, tx, strings.ToLower(alias))
+	if err != nil {
+		return err
+	}
+	if reservation != nil && reservation.OwnerID != ownerID {
+		return errAliasReserved
+	}
+	return nil
+}
+
+// unreserved drops the aliases another owner than ownerID reserved, so that
+// suggestions can be used
+func (s *LinkService) unreserved(ctx context.Context, ownerID uuid.UUID, aliases []string) ([]string, error) {
+	if s.reservations == nil || len(aliases) == 0 {
+		return aliases, nil
+	}
+	reservations, err := s.reservations.ListAliasReservations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	free := aliases[:0]
+	for _, alias := range aliases {
+		reserved := false
+		for _, r := range reservations {
+			if r.OwnerID != ownerID && matchesPattern(r.Pattern, strings.ToLower(alias)) {
+				reserved = true
+				break
+			}
+		}
+		if !reserved {
+			free = append(free, alias)
+		}
+	}
+	return free, nil
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAliasReservationStorage struct {
+	storage.AliasReservationStorage
+	reservations map[uuid.UUID]*storage.AliasReservation
+}
+
+func (f *fakeAliasReservationStorage) CreateAliasReservation(ctx context.Context, r *storage.AliasReservation) error {
+	f.reservations[r.ID] = r
+	return nil
+}
+
+func (f *fakeAliasReservationStorage) GetAliasReservation(ctx context.Context, id uuid.UUID) (*storage.AliasReservation, error) {
+	return f.reservations[id], nil
+}
+
+func (f *fakeAliasReservationStorage) ListAliasReservationsByOwner(ctx context.Context, ownerID uuid.UUID) ([]*storage.AliasReservation, error) {
+	var reservations []*storage.AliasReservation
+	for _, r := range f.reservations {
+		if r.OwnerID == ownerID {
+			reservations = append(reservations, r)
+		}
+	}
+	return reservations, nil
+}
+
+func (f *fakeAliasReservationStorage) ListAliasReservations(ctx context.Context) ([]*storage.AliasReservation, error) {
+	var reservations []*storage.AliasReservation
+	for _, r := range f.reservations {
+		reservations = append(reservations, r)
+	}
+	return reservations, nil
+}
+
+func (f *fakeAliasReservationStorage) DeleteAliasReservation(ctx context.Context, id uuid.UUID) error {
+	delete(f.reservations, id)
+	return nil
+}
+
+func TestAliasPatterns(t *testing.T) {
+	assert.True(t, validAliasPattern("launch"))
+	assert.True(t, validAliasPattern("promo-*"))
+	assert.False(t, validAliasPattern("ab*"), "wildcard prefixes need 3 characters")
+	assert.False(t, validAliasPattern("*"))
+	assert.False(t, validAliasPattern("pro*mo"))
+	assert.False(t, validAliasPattern("api"), "reserved aliases can't be reserved again")
+
+	assert.True(t, matchesPattern("promo-*", "promo-summer"))
+	assert.True(t, matchesPattern("promo-*", "promo-"))
+	assert.False(t, matchesPattern("promo-*", "promo"))
+	assert.True(t, matchesPattern("launch", "launch"))
+	assert.False(t, matchesPattern("launch", "launch-2"))
+
+	assert.True(t, patternsOverlap("promo-*", "promo-summer-*"))
+	assert.True(t, patternsOverlap("promo-summer", "promo-*"))
+	assert.True(t, patternsOverlap("promo-*", "promo-summer"))
+	assert.False(t, patternsOverlap("promo-*", "promotion"))
+	assert.False(t, patternsOverlap("launch", "launch-2"))
+}
+
+func TestAliasReservations(t *testing.T) {
+	fake := &fakeAliasReservationStorage{reservations: make(map[uuid.UUID]*storage.AliasReservation)}
+	s := NewAliasReservationService(fake)
+	ownerID, otherID := uuid.New(), uuid.New()
+	owner := middleware.WithOwnerID(context.Background(), ownerID)
+	other := middleware.WithOwnerID(context.Background(), otherID)
+
+	reservation, err := s.CreateReservation(owner, &CreateAliasReservationRequest{Pattern: " Promo-* "})
+	require.NoError(t, err)
+	assert.Equal(t, "promo-*", reservation.Pattern)
+
+	_, err = s.CreateReservation(other, &CreateAliasReservationRequest{Pattern: "promo-summer"})
+	assert.ErrorIs(t, err, ErrAliasReservationConflict)
+	_, err = s.CreateReservation(other, &CreateAliasReservationRequest{Pattern: "pr*"})
+	var verrs validation.Errors
+	assert.ErrorAs(t, err, &verrs)
+
+	// Suggestions leave out aliases reserved for someone else
+	links := NewLinkService(nil, nil, nil, logging.NewLogger(logging.LevelError))
+	links.UseAliasReservations(fake)
+	free, err := links.unreserved(other, otherID, []string{"promo-2", "promos", "Promo-x"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"promos"}, free)
+	free, err = links.unreserved(owner, ownerID, []string{"promo-2"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"promo-2"}, free)
+
+	list, err := s.ListReservations(other)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	assert.ErrorIs(t, s.DeleteReservation(other, reservation.ID), ErrNotOwner)
+	assert.ErrorIs(t, s.DeleteReservation(owner, uuid.New()), ErrAliasReservationNotFound)
+	require.NoError(t, s.DeleteReservation(owner, reservation.ID))
+	assert.Empty(t, fake.reservations)
+}
+
+func TestAliasReservationsFollowPlans(t *testing.T) {
+	fake := &fakeAliasReservationStorage{reservations: make(map[uuid.UUID]*storage.AliasReservation)}
+	plans, err := NewPlanService(&fakePlanStorage{plans: map[uuid.UUID]*storage.OwnerPlan{}}, &fakeUserStorage{}, "pro", logging.NewLogger(logging.LevelError))
+	require.NoError(t, err)
+	s := NewAliasReservationService(fake)
+	s.UsePlans(plans)
+	ownerID := uuid.New()
+	ctx := middleware.WithOwnerID(context.Background(), ownerID)
+
+	_, err = s.CreateReservation(ctx, &CreateAliasReservationRequest{Pattern: "launch"})
+	assert.ErrorIs(t, err, ErrQuotaExceeded)
+
+	_, err = plans.SetPlan(ctx, ownerID, "enterprise")
+	require.NoError(t, err)
+	_, err = s.CreateReservation(ctx, &CreateAliasReservationRequest{Pattern: "launch"})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+
+	"url-shortener/pkg/middleware"
+)
+
+// maxAliasSuggestions is how many alternatives a taken alias comes back with
+const maxAliasSuggestions = 5
+
+// AliasTakenError is returned by CreateLink when the requested alias is in
+// use. It matches ErrCodeExists with errors.Is.
+type AliasTakenError struct {
+	Alias string
+	// Suggestions are similar aliases that were free when checked
+	Suggestions []string
+}
+
+func (e *AliasTakenError) Error() string {
+	return ErrCodeExists.Error()
+}
+
+func (e *AliasTakenError) Is(target error) bool {
+	return target == ErrCodeExists
+}
+
+// SuggestAliases returns up to maxAliasSuggestions aliases similar to alias
+// that are free on ctx's domain, checking them all in one query.
+// Availability isn't reserved, so a suggestion can still be taken by the time
+// it is used.
+func (s *LinkService) SuggestAliases(ctx context.Context, alias string) ([]string, error) {
+	candidates, err := s.unreserved(ctx, middleware.GetOwnerIDFromContext(ctx), aliasCandidates(alias))
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		keys[i] = linkKey(ctx, candidate)
+	}
+	taken, err := s.storage.GetByCodes(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[string]bool, len(taken))
+	for _, link := range taken {
+		used[link.Code] = true
+	}
+
+	suggestions := make([]string, 0, maxAliasSuggestions)
+	for _, candidate := range candidates {
+		if !used[candidate] {
+			suggestions = append(suggestions, candidate)
+			if len(suggestions) == maxAliasSuggestions {
+				break
+			}
+		}
+	}
+	return suggestions, nil
+}
+
+// aliasCandidates lists valid variations of alias, the most natural first:
+// numbered ones, then random suffixes
+func aliasCandidates(alias string) []string {
+	base := strings.TrimRight(alias, "-_")
+	if base == "" {
+		base = "link"
+	}
+	// Leave room for the longest suffix within the 50 character limit
+	if runes := []rune(base); len(runes) > 45 {
+		base = string(runes[:45])
+	}
+
+	var candidates []string
+	seen := map[string]bool{alias: true}
+	add := func(candidate string) {
+		if !seen[candidate] && ValidateAlias(candidate) {
+			seen[candidate] = true
+			candidates = append(candidates, candidate)
+		}
+	}
+	for n := 2; n <= 4; n++ {
+		add(base + "-" + strconv.Itoa(n))
+	}
+	for i := 0; i < 7; i++ {
+		add(base + "-" + strconv.Itoa(100+rand.IntN(900)))
+	}
+	return candidates
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/session"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/tenant"
+	"url-shortener/pkg/validation"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxAPIKeysPerOwner = 10
+	// apiKeyPrefix marks keys so that secret scanners and people can spot
+	// them
+	apiKeyPrefix = "usk_"
+	// apiKeyTouchInterval is how stale last_used_at may get before a use of
+	// the key updates it
+	apiKeyTouchInterval = time.Minute
+)
+
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrTooManyAPIKeys = fmt.Errorf("at most %d API keys per owner", maxAPIKeysPerOwner)
+)
+
+// APIKeyService manages the caller's API keys and verifies keys for
+// middleware.OAuthMiddleware
+type APIKeyService struct {
+	storage storage.APIKeyStorage
+	logger  *logging.Logger
+	now     func() time.Time
+}
+
+func NewAPIKeyService(storage storage.APIKeyStorage, logger *logging.Logger) *APIKeyService {
+	return &APIKeyService{
+		storage: storage,
+		logger:  logger,
+		now:     time.Now,
+	}
+}
+
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// APIKey is the API representation of a key. Key is only returned when the
+// key is created.
+type APIKey struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Key        string     `json:"key,omitempty"`
+	Prefix     string     `json:"prefix"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, req *CreateAPIKeyRequest) (*APIKey, error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	existing, err := s.storage.ListAPIKeysByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxAPIKeysPerOwner {
+		return nil, ErrTooManyAPIKeys
+	}
+
+	token, err := session.RandomToken()
+	if err != nil {
+		return nil, err
+	}
+	secret := apiKeyPrefix + token
+	key := &storage.APIKey{
+		ID:        uuid.New(),
+		OwnerID:   ownerID,
+		TenantID:  tenant.FromContext(ctx),
+		Name:      req.Name,
+		Prefix:    secret[:len(apiKeyPrefix)+6],
+		Hash:      hashAPIKey(secret),
+		CreatedAt: s.now(),
+	}
+	if err := s.storage.CreateAPIKey(ctx, key); err != nil {
+		return nil, err
+	}
+	apiKey := toAPIKey(key)
+	apiKey.Key = secret
+	return apiKey, nil
+}
+
+func (s *APIKeyService) ListAPIKeys(ctx context.Context) ([]*APIKey, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	keys, err := s.storage.ListAPIKeysByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	apiKeys := make([]*APIKey, len(keys))
+	for i, key := range keys {
+		apiKeys[i] = toAPIKey(key)
+	}
+	return apiKeys, nil
+}
+
+func (s *APIKeyService) DeleteAPIKey(ctx context.Context, id uuid.UUID) error {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return errors.New("owner_id not found in context")
+	}
+
+	key, err := s.storage.GetAPIKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return ErrAPIKeyNotFound
+	}
+	if key.OwnerID != ownerID {
+		return ErrNotOwner
+	}
+	return s.storage.DeleteAPIKey(ctx, id)
+}
+
+// VerifyAPIKey returns the owner, ID and tenant of secret, or uuid.Nil if it
+// isn't a key
+func (s *APIKeyService) VerifyAPIKey(ctx context.Context, secret string) (ownerID, keyID uuid.UUID, tenantID string, err error) {
+	key, err := s.storage.GetAPIKeyByHash(ctx, hashAPIKey(secret))
+	if err != nil || key == nil {
+		return uuid.Nil, uuid.Nil, "", err
+	}
+	now := s.now()
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		// last_used_at is informational; failing to update it doesn't
+		// make the key any less valid
+		if err := s.storage.TouchAPIKey(ctx, key.ID, now); err != nil {
+			s.logger.Warn(ctx, "failed to record API key use", "key_id", key.ID, "error", err)
+		}
+	}
+	return key.OwnerID, key.ID, key.TenantID, nil
+}
+
+// hashAPIKey is the stored form of a key. Keys are random, so unlike
+// passwords a fast hash can't be brute forced.
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func toAPIKey(key *storage.APIKey) *APIKey {
+	return &APIKey{
+		ID:         key.ID,
+		Name:       key.Name,
+		Prefix:     key.Prefix,
+		CreatedAt:  key.CreatedAt,
+		LastUsedAt: key.LastUsedAt,
+	}
+}
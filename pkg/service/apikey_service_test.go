@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/tenant"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeAPIKeyStorage struct {
+	storage.APIKeyStorage
+	keys    map[uuid.UUID]*storage.APIKey
+	touches int
+	// touchErr fails TouchAPIKey
+	touchErr error
+}
+
+func (f *fakeAPIKeyStorage) CreateAPIKey(ctx context.Context, key *storage.APIKey) error {
+	f.keys[key.ID] = key
+	return nil
+}
+
+func (f *fakeAPIKeyStorage) GetAPIKey(ctx context.Context, id uuid.UUID) (*storage.APIKey, error) {
+	return f.keys[id], nil
+}
+
+func (f *fakeAPIKeyStorage) GetAPIKeyByHash(ctx context.Context, hash string) (*storage.APIKey, error) {
+	for _, key := range f.keys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeAPIKeyStorage) ListAPIKeysByOwner(ctx context.Context, ownerID uuid.UUID) ([]*storage.APIKey, error) {
+	var keys []*storage.APIKey
+	for _, key := range f.keys {
+		if key.OwnerID == ownerID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (f *fakeAPIKeyStorage) DeleteAPIKey(ctx context.Context, id uuid.UUID) error {
+	delete(f.keys, id)
+	return nil
+}
+
+func (f *fakeAPIKeyStorage) TouchAPIKey(ctx context.Context, id uuid.UUID, at time.Time) error {
+	f.touches++
+	if f.touchErr != nil {
+		return f.touchErr
+	}
+	f.keys[id].LastUsedAt = &at
+	return nil
+}
+
+func TestAPIKeys(t *testing.T) {
+	store := &fakeAPIKeyStorage{keys: make(map[uuid.UUID]*storage.APIKey)}
+	s := NewAPIKeyService(store, logging.NewLogger(logging.LevelError))
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return now }
+	owner := uuid.New()
+	ctx := middleware.WithOwnerID(context.Background(), owner)
+
+	key, err := s.CreateAPIKey(ctx, &CreateAPIKeyRequest{Name: "Browser extension"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key.Key, "usk_"))
+	assert.True(t, strings.HasPrefix(key.Key, key.Prefix))
+	assert.NotContains(t, store.keys[key.ID].Hash, key.Key, "only the hash is stored")
+
+	verified, keyID, tenantID, err := s.VerifyAPIKey(context.Background(), key.Key)
+	require.NoError(t, err)
+	assert.Equal(t, owner, verified)
+	assert.Equal(t, key.ID, keyID)
+	assert.Empty(t, tenantID)
+	assert.Equal(t, 1, store.touches)
+
+	// Uses within a minute don't write
+	now = now.Add(30 * time.Second)
+	_, _, _, err = s.VerifyAPIKey(context.Background(), key.Key)
+	require.NoError(t, err)
+	assert.Equal(t, 1, store.touches)
+
+	verified, _, _, err = s.VerifyAPIKey(context.Background(), key.Key+"x")
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Nil, verified)
+
+	keys, err := s.ListAPIKeys(ctx)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Empty(t, keys[0].Key, "keys are only shown when created")
+
+	err = s.DeleteAPIKey(middleware.WithOwnerID(context.Background(), uuid.New()), key.ID)
+	assert.ErrorIs(t, err, ErrNotOwner)
+	require.NoError(t, s.DeleteAPIKey(ctx, key.ID))
+	verified, _, _, err = s.VerifyAPIKey(context.Background(), key.Key)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.Nil, verified)
+
+	// Keys remember the tenant they were created in
+	key, err = s.CreateAPIKey(tenant.WithTenant(ctx, "acme"), &CreateAPIKeyRequest{Name: "Acme extension"})
+	require.NoError(t, err)
+	_, _, tenantID, err = s.VerifyAPIKey(context.Background(), key.Key)
+	require.NoError(t, err)
+	assert.Equal(t, "acme", tenantID)
+}
+
+func TestVerifyAPIKeyTouchFailure(t *testing.T) {
+	store := &fakeAPIKeyStorage{keys: make(map[uuid.UUID]*storage.APIKey), touchErr: errors.New("connection reset")}
+	s := NewAPIKeyService(store, logging.NewLogger(logging.LevelError))
+	owner := uuid.New()
+	key, err := s.CreateAPIKey(middleware.WithOwnerID(context.Background(), owner), &CreateAPIKeyRequest{Name: "CLI"})
+	require.NoError(t, err)
+
+	// The key still authenticates when its last use can't be recorded
+	verified, keyID, _, err := s.VerifyAPIKey(context.Background(), key.Key)
+	require.NoError(t, err)
+	assert.Equal(t, owner, verified)
+	assert.Equal(t, key.ID, keyID)
+	assert.Equal(t, 1, store.touches)
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"url-shortener/pkg/billing"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+)
+
+// billingActor is recorded as who assigned plans that subscriptions pay for
+const billingActor = "billing"
+
+// BillingService keeps owners' plans in step with their Stripe
+// subscriptions. An active subscription puts its owner on the plan of its
+// price; one that ends puts them back on the default plan.
+type BillingService struct {
+	storage    storage.BillingStorage
+	plans      *PlanService
+	pricePlans map[string]string
+	logger     *logging.Logger
+}
+
+// NewBillingService maps Stripe price IDs to the plans they pay for
+func NewBillingService(storage storage.BillingStorage, plans *PlanService, pricePlans map[string]string, logger *logging.Logger) (*BillingService, error) {
+	for price, plan := range pricePlans {
+		if LookupPlan(plan) == nil {
+			return nil, fmt.Errorf("price %s: %w %q", price, ErrUnknownPlan, plan)
+		}
+	}
+	return &BillingService{
+		storage:    storage,
+		plans:      plans,
+		pricePlans: pricePlans,
+		logger:     logger,
+	}, nil
+}
+
+// Billing is the caller's plan and the subscription paying for it
+type Billing struct {
+	Plan              string     `json:"plan"`
+	Status            *string    `json:"status,omitempty"`
+	CurrentPeriodEnd  *time.Time `json:"current_period_end,omitempty"`
+	CancelAtPeriodEnd bool       `json:"cancel_at_period_end"`
+}
+
+// GetBilling returns the caller's plan and subscription
+func (s *BillingService) GetBilling(ctx context.Context) (*Billing, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+	plan, err := s.plans.PlanOf(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := s.storage.GetSubscription(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	out := &Billing{Plan: plan.Name}
+	if sub != nil {
+		out.Status = &sub.Status
+		out.CurrentPeriodEnd = sub.CurrentPeriodEnd
+		out.CancelAtPeriodEnd = sub.CancelAtPeriodEnd
+	}
+	return out, nil
+}
+
+// HandleEvent applies a verified webhook event. Events that can't be applied,
+// such as ones for an unknown customer, are logged and dropped rather than
+// failed, since Stripe redelivering them wouldn't help.
+func (s *BillingService) HandleEvent(ctx context.Context, event *billing.Event) error {
+	sub := event.Subscription
+	if sub == nil {
+		return nil
+	}
+
+	ownerID := sub.OwnerID
+	if ownerID == uuid.Nil {
+		known, err := s.storage.GetSubscriptionByCustomer(ctx, sub.CustomerID)
+		if err != nil {
+			return err
+		}
+		if known == nil {
+			s.logger.Warn(ctx, "billing event for an unknown customer", "event_id", event.ID, "customer_id", sub.CustomerID)
+			return nil
+		}
+		ownerID = known.OwnerID
+	}
+
+	// An owner who switched subscriptions keeps the plan of the new one when
+	// the old one ends
+	current, err := s.storage.GetSubscription(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	if current != nil && current.SubscriptionID != sub.ID && !sub.Active() {
+		return nil
+	}
+
+	saved, err := s.storage.SaveSubscription(ctx, &storage.BillingSubscription{
+		OwnerID:           ownerID,
+		CustomerID:        sub.CustomerID,
+		SubscriptionID:    sub.ID,
+		Status:            sub.Status,
+		PriceID:           sub.PriceID,
+		CurrentPeriodEnd:  sub.CurrentPeriodEnd,
+		CancelAtPeriodEnd: sub.CancelAtPeriodEnd,
+		EventCreatedAt:    event.Created,
+		UpdatedAt:         time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	if !saved {
+		// A newer event already set the subscription's state
+		return nil
+	}
+
+	if !sub.Active() {
+		if err := s.plans.unassign(ctx, ownerID); err != nil {
+			return err
+		}
+		s.logger.Info(ctx, "subscription ended", "owner_id", ownerID, "status", sub.Status)
+		return nil
+	}
+	plan, ok := s.pricePlans[sub.PriceID]
+	if !ok {
+		s.logger.Warn(ctx, "subscription to an unknown price", "owner_id", ownerID, "price_id", sub.PriceID)
+		return nil
+	}
+	if _, err := s.plans.assign(ctx, ownerID, plan, billingActor); err != nil {
+		return err
+	}
+	s.logger.Info(ctx, "subscription updated", "owner_id", ownerID, "plan", plan, "status", sub.Status)
+	return nil
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/billing"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeBillingStorage struct {
+	subs map[uuid.UUID]*storage.BillingSubscription
+}
+
+func (f *fakeBillingStorage) GetSubscription(ctx context.Context, ownerID uuid.UUID) (*storage.BillingSubscription, error) {
+	return f.subs[ownerID], nil
+}
+
+func (f *fakeBillingStorage) GetSubscriptionByCustomer(ctx context.Context, customerID string) (*storage.BillingSubscription, error) {
+	for _, sub := range f.subs {
+		if sub.CustomerID == customerID {
+			return sub, nil
+		}
+	}
+	return nil, nil
+}
+
+func (f *fakeBillingStorage) SaveSubscription(ctx context.Context, sub *storage.BillingSubscription) (bool, error) {
+	if current := f.subs[sub.OwnerID]; current != nil && current.EventCreatedAt.After(sub.EventCreatedAt) {
+		return false, nil
+	}
+	f.subs[sub.OwnerID] = sub
+	return true, nil
+}
+
+func TestHandleBillingEvent(t *testing.T) {
+	ownerID := uuid.New()
+	logger := logging.NewLogger(logging.LevelError)
+	planStore := &fakePlanStorage{plans: map[uuid.UUID]*storage.OwnerPlan{}}
+	plans, err := NewPlanService(planStore, &fakeUserStorage{}, "free", logger)
+	require.NoError(t, err)
+	svc, err := NewBillingService(&fakeBillingStorage{subs: map[uuid.UUID]*storage.BillingSubscription{}}, plans, map[string]string{"price_pro": "pro"}, logger)
+	require.NoError(t, err)
+	ctx := context.Background()
+	start := time.Now().Add(-time.Hour)
+
+	event := func(id string, created time.Time, sub *billing.Subscription) *billing.Event {
+		return &billing.Event{ID: id, Type: billing.SubscriptionUpdated, Created: created, Subscription: sub}
+	}
+
+	// Subscribing puts the owner on the price's plan
+	require.NoError(t, svc.HandleEvent(ctx, event("evt_1", start, &billing.Subscription{
+		ID: "sub_1", CustomerID: "cus_1", OwnerID: ownerID, Status: "active", PriceID: "price_pro",
+	})))
+	assert.Equal(t, "pro", planStore.plans[ownerID].Plan)
+	assert.Equal(t, "billing", planStore.plans[ownerID].UpdatedBy)
+
+	// An older event delivered late changes nothing
+	require.NoError(t, svc.HandleEvent(ctx, event("evt_0", start.Add(-time.Minute), &billing.Subscription{
+		ID: "sub_1", CustomerID: "cus_1", OwnerID: ownerID, Status: "incomplete_expired", PriceID: "price_pro",
+	})))
+	assert.Equal(t, "pro", planStore.plans[ownerID].Plan)
+
+	// Events without owner metadata are matched by customer
+	require.NoError(t, svc.HandleEvent(ctx, &billing.Event{ID: "evt_2", Type: billing.SubscriptionDeleted, Created: start.Add(time.Minute), Subscription: &billing.Subscription{
+		ID: "sub_1", CustomerID: "cus_1", Status: "canceled", PriceID: "price_pro",
+	}}))
+	assert.Nil(t, planStore.plans[ownerID])
+
+	// Unknown customers are dropped
+	assert.NoError(t, svc.HandleEvent(ctx, event("evt_3", start, &billing.Subscription{ID: "sub_9", CustomerID: "cus_9", Status: "active"})))
+
+	_, err = NewBillingService(&fakeBillingStorage{}, plans, map[string]string{"price_x": "platinum"}, logger)
+	assert.ErrorIs(t, err, ErrUnknownPlan)
+}
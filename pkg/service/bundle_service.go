@@ -0,0 +1,238 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrBundleNotFound = errors.New("bundle not found")
+	ErrSlugExists     = errors.New("slug already exists")
+)
+
+// BundleService manages link-in-bio pages. Entry destinations go through the
+// same checks as link destinations.
+type BundleService struct {
+	storage storage.BundleStorage
+	links   *LinkService
+	logger  *logging.Logger
+}
+
+func NewBundleService(storage storage.BundleStorage, links *LinkService, logger *logging.Logger) *BundleService {
+	return &BundleService{
+		storage: storage,
+		links:   links,
+		logger:  logger,
+	}
+}
+
+type BundleEntryRequest struct {
+	// ID of an existing entry to keep its click count on update
+	ID    int64  `json:"id,omitempty"`
+	Title string `json:"title" validate:"required,max=200"`
+	URL   string `json:"url" validate:"required,url,max=2048"`
+}
+
+type CreateBundleRequest struct {
+	Slug    string                `json:"slug" validate:"required,alias"`
+	Title   string                `json:"title" validate:"required,max=200"`
+	Entries []*BundleEntryRequest `json:"entries" validate:"max=50"`
+}
+
+type UpdateBundleRequest struct {
+	Title   string                `json:"title" validate:"required,max=200"`
+	Entries []*BundleEntryRequest `json:"entries" validate:"max=50"`
+}
+
+func (s *BundleService) CreateBundle(ctx context.Context, req *CreateBundleRequest) (*storage.Bundle, error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+	entries, err := s.buildEntries(req.Entries)
+	if err != nil {
+		return nil, err
+	}
+
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	existing, err := s.storage.GetBundle(ctx, req.Slug)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrSlugExists
+	}
+
+	now := time.Now()
+	bundle := &storage.Bundle{
+		Slug:      req.Slug,
+		Title:     req.Title,
+		OwnerID:   ownerID,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Entries:   entries,
+	}
+	if err := s.storage.CreateBundle(ctx, bundle); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info(ctx, "bundle created", "slug", bundle.Slug, "entries", len(entries))
+	return bundle, nil
+}
+
+// GetBundle returns a bundle with per-entry click counts if the caller owns it
+func (s *BundleService) GetBundle(ctx context.Context, slug string) (*storage.Bundle, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	bundle, err := s.storage.GetBundle(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if bundle == nil {
+		return nil, ErrBundleNotFound
+	}
+	if bundle.OwnerID != ownerID {
+		return nil, ErrNotOwner
+	}
+	return bundle, nil
+}
+
+// GetPublicBundle returns a bundle for rendering its public page
+func (s *BundleService) GetPublicBundle(ctx context.Context, slug string) (*storage.Bundle, error) {
+	bundle, err := s.storage.GetBundle(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if bundle == nil {
+		return nil, ErrBundleNotFound
+	}
+	return bundle, nil
+}
+
+func (s *BundleService) ListBundles(ctx context.Context, limit, offset int) ([]*storage.Bundle, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+	return s.storage.ListBundlesByOwner(ctx, ownerID, limit, offset)
+}
+
+func (s *BundleService) UpdateBundle(ctx context.Context, slug string, req *UpdateBundleRequest) (*storage.Bundle, error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+	entries, err := s.buildEntries(req.Entries)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle, err := s.GetBundle(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[int64]bool, len(bundle.Entries))
+	for _, entry := range bundle.Entries {
+		known[entry.ID] = true
+	}
+	for _, entry := range entries {
+		if entry.ID != 0 && !known[entry.ID] {
+			return nil, fmt.Errorf("unknown entry id %d", entry.ID)
+		}
+	}
+
+	bundle.Title = req.Title
+	bundle.Entries = entries
+	bundle.UpdatedAt = time.Now()
+	if err := s.storage.UpdateBundle(ctx, bundle); err != nil {
+		return nil, err
+	}
+	return s.storage.GetBundle(ctx, slug)
+}
+
+func (s *BundleService) DeleteBundle(ctx context.Context, slug string) error {
+	if _, err := s.GetBundle(ctx, slug); err != nil {
+		return err
+	}
+	return s.storage.DeleteBundle(ctx, slug)
+}
+
+// RecordClick counts a click on a bundle entry and returns its destination
+func (s *BundleService) RecordClick(ctx context.Context, slug string, entryID int64) (string, error) {
+	url, err := s.storage.RecordEntryClick(ctx, slug, entryID)
+	if err != nil {
+		return "", err
+	}
+	if url == "" {
+		return "", ErrBundleNotFound
+	}
+	return url, nil
+}
+
+// EntryURL returns the destination of a bundle entry without counting a click
+func (s *BundleService) EntryURL(ctx context.Context, slug string, entryID int64) (string, error) {
+	bundle, err := s.GetPublicBundle(ctx, slug)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range bundle.Entries {
+		if entry.ID == entryID {
+			return entry.URL, nil
+		}
+	}
+	return "", ErrBundleNotFound
+}
+
+// buildEntries validates each entry and its destination. Field names in
+// validation errors are prefixed with the entry index, e.g. entries[2].url.
+func (s *BundleService) buildEntries(reqs []*BundleEntryRequest) ([]*storage.BundleEntry, error) {
+	var errs validation.Errors
+	entries := make([]*storage.BundleEntry, 0, len(reqs))
+	for i, req := range reqs {
+		prefix := fmt.Sprintf("entries[%d].", i)
+		if err := validation.Struct(req); err != nil {
+			var fieldErrs validation.Errors
+			if !errors.As(err, &fieldErrs) {
+				return nil, err
+			}
+			for _, fe := range fieldErrs {
+				fe.Field = prefix + fe.Field
+				errs = append(errs, fe)
+			}
+			continue
+		}
+		if err := s.links.ValidateDestination(req.URL); err != nil {
+			errs = append(errs, validation.FieldError{
+				Field:   prefix + "url",
+				Rule:    "destination",
+				Message: strings.TrimPrefix(err.Error(), "invalid URL: "),
+			})
+			continue
+		}
+		entries = append(entries, &storage.BundleEntry{
+			ID:    req.ID,
+			Title: req.Title,
+			URL:   req.URL,
+		})
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return entries, nil
+}
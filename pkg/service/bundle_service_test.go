@@ -0,0 +1,33 @@
+package service
+
+import (
+	"testing"
+
+	"url-shortener/pkg/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildEntries(t *testing.T) {
+	svc := &BundleService{links: &LinkService{}}
+
+	entries, err := svc.buildEntries([]*BundleEntryRequest{
+		{Title: "Blog", URL: "https://example.com/blog"},
+		{ID: 7, Title: "Shop", URL: "https://shop.example.com"},
+	})
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, int64(7), entries[1].ID)
+
+	_, err = svc.buildEntries([]*BundleEntryRequest{
+		{Title: "", URL: "https://example.com"},
+		{Title: "Internal", URL: "http://127.0.0.1/admin"},
+	})
+	var errs validation.Errors
+	require.ErrorAs(t, err, &errs)
+	require.Len(t, errs, 2)
+	assert.Equal(t, "entries[0].title", errs[0].Field)
+	assert.Equal(t, "entries[1].url", errs[1].Field)
+	assert.Equal(t, "destination", errs[1].Rule)
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+
+	"github.com/google/uuid"
+)
+
+// campaignTopLinks is how many member links campaign stats break down
+const campaignTopLinks = 10
+
+var ErrCampaignNotFound = errors.New("campaign not found")
+
+// CampaignService manages the caller's campaigns. Links join a campaign via
+// campaign_id on link create and update.
+type CampaignService struct {
+	storage storage.CampaignStorage
+	links   *LinkService
+}
+
+func NewCampaignService(storage storage.CampaignStorage, links *LinkService) *CampaignService {
+	return &CampaignService{
+		storage: storage,
+		links:   links,
+	}
+}
+
+type CampaignRequest struct {
+	Name        string  `json:"name" validate:"required,max=200"`
+	Description *string `json:"description,omitempty" validate:"max=2000"`
+}
+
+// CampaignLinkStats is one member link in a campaign's stats
+type CampaignLinkStats struct {
+	Code     string `json:"code"`
+	ShortURL string `json:"short_url"`
+	Clicks   int    `json:"clicks"`
+}
+
+// CampaignStats aggregates clicks across a campaign's links. Clicks reach
+// the totals once they are synced to the DB.
+type CampaignStats struct {
+	CampaignID  uuid.UUID            `json:"campaign_id"`
+	Links       int                  `json:"links"`
+	TotalClicks int64                `json:"total_clicks"`
+	TopLinks    []*CampaignLinkStats `json:"top_links"`
+}
+
+func (s *CampaignService) CreateCampaign(ctx context.Context, req *CampaignRequest) (*storage.Campaign, error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	now := time.Now()
+	campaign := &storage.Campaign{
+		ID:          uuid.New(),
+		OwnerID:     ownerID,
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.storage.CreateCampaign(ctx, campaign); err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+// GetCampaign returns a campaign if the caller owns it
+func (s *CampaignService) GetCampaign(ctx context.Context, id uuid.UUID) (*storage.Campaign, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	campaign, err := s.storage.GetCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign == nil {
+		return nil, ErrCampaignNotFound
+	}
+	if campaign.OwnerID != ownerID {
+		return nil, ErrNotOwner
+	}
+	return campaign, nil
+}
+
+func (s *CampaignService) ListCampaigns(ctx context.Context, limit, offset int) ([]*storage.Campaign, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+	return s.storage.ListCampaignsByOwner(ctx, ownerID, limit, offset)
+}
+
+func (s *CampaignService) UpdateCampaign(ctx context.Context, id uuid.UUID, req *CampaignRequest) (*storage.Campaign, error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+	campaign, err := s.GetCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	campaign.Name = req.Name
+	campaign.Description = req.Description
+	campaign.UpdatedAt = time.Now()
+	if err := s.storage.UpdateCampaign(ctx, campaign); err != nil {
+		return nil, err
+	}
+	return campaign, nil
+}
+
+// DeleteCampaign deletes the campaign; its links are kept and detached
+func (s *CampaignService) DeleteCampaign(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.GetCampaign(ctx, id); err != nil {
+		return err
+	}
+	return s.storage.DeleteCampaign(ctx, id)
+}
+
+func (s *CampaignService) Stats(ctx context.Context, id uuid.UUID) (*CampaignStats, error) {
+	if _, err := s.GetCampaign(ctx, id); err != nil {
+		return nil, err
+	}
+	return cachedStats(ctx, s.links.stats, "campaign:"+id.String(), func(ctx context.Context) (*CampaignStats, error) {
+		return s.computeStats(ctx, id)
+	})
+}
+
+func (s *CampaignService) computeStats(ctx context.Context, id uuid.UUID) (*CampaignStats, error) {
+	totals, err := s.storage.CampaignTotals(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	top, err := s.storage.TopCampaignLinks(ctx, id, campaignTopLinks)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &CampaignStats{
+		CampaignID:  id,
+		Links:       totals.Links,
+		TotalClicks: totals.TotalClicks,
+		TopLinks:    make([]*CampaignLinkStats, len(top)),
+	}
+	for i, link := range top {
+		stats.TopLinks[i] = &CampaignLinkStats{
+			Code:     link.Code,
+			ShortURL: s.links.LinkShortURL(link),
+			Clicks:   link.ClickCount,
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeCampaignStorage struct {
+	storage.CampaignStorage
+	campaigns map[uuid.UUID]*storage.Campaign
+	links     []*storage.Link
+}
+
+func (f *fakeCampaignStorage) CreateCampaign(ctx context.Context, c *storage.Campaign) error {
+	f.campaigns[c.ID] = c
+	return nil
+}
+
+func (f *fakeCampaignStorage) GetCampaign(ctx context.Context, id uuid.UUID) (*storage.Campaign, error) {
+	return f.campaigns[id], nil
+}
+
+func (f *fakeCampaignStorage) CampaignTotals(ctx context.Context, id uuid.UUID) (*storage.CampaignTotals, error) {
+	totals := &storage.CampaignTotals{}
+	for _, link := range f.links {
+		if link.CampaignID != nil && *link.CampaignID == id {
+			totals.Links++
+			totals.TotalClicks += int64(link.ClickCount)
+		}
+	}
+	return totals, nil
+}
+
+func (f *fakeCampaignStorage) TopCampaignLinks(ctx context.Context, id uuid.UUID, limit int) ([]*storage.Link, error) {
+	var links []*storage.Link
+	for _, link := range f.links {
+		if link.CampaignID != nil && *link.CampaignID == id {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+func TestCampaignStats(t *testing.T) {
+	ownerID := uuid.New()
+	campaigns := &fakeCampaignStorage{campaigns: map[uuid.UUID]*storage.Campaign{}}
+	svc := NewCampaignService(campaigns, NewLinkService(nil, nil, nil, logging.NewLogger(logging.LevelError)))
+
+	ctx := middleware.WithOwnerID(context.Background(), ownerID)
+	campaign, err := svc.CreateCampaign(ctx, &CampaignRequest{Name: "Spring launch"})
+	require.NoError(t, err)
+	campaigns.links = []*storage.Link{
+		{Code: "a", ClickCount: 7, CampaignID: &campaign.ID},
+		{Code: "b", ClickCount: 3, CampaignID: &campaign.ID},
+		{Code: "c", ClickCount: 100},
+	}
+
+	stats, err := svc.Stats(ctx, campaign.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Links)
+	assert.Equal(t, int64(10), stats.TotalClicks)
+	require.Len(t, stats.TopLinks, 2)
+	assert.Equal(t, "a", stats.TopLinks[0].Code)
+	assert.Equal(t, "http://localhost:8081/r/a", stats.TopLinks[0].ShortURL)
+
+	// Other owners can't see it
+	_, err = svc.Stats(middleware.WithOwnerID(context.Background(), uuid.New()), campaign.ID)
+	assert.ErrorIs(t, err, ErrNotOwner)
+	_, err = svc.Stats(ctx, uuid.New())
+	assert.ErrorIs(t, err, ErrCampaignNotFound)
+}
+
+func TestUpdateLinkCampaign(t *testing.T) {
+	ownerID := uuid.New()
+	mine := &storage.Campaign{ID: uuid.New(), OwnerID: ownerID}
+	theirs := &storage.Campaign{ID: uuid.New(), OwnerID: uuid.New()}
+	links := &updatableLinks{link: &storage.Link{Code: "abc", LongURL: "https://example.com", OwnerID: &ownerID}}
+	s := NewLinkService(links, &batchCache{entries: map[string]*cache.CachedLink{}}, nil, logging.NewLogger(logging.LevelError))
+	ctx := middleware.WithOwnerID(context.Background(), ownerID)
+
+	id := mine.ID.String()
+	assert.EqualError(t, s.UpdateLink(ctx, "abc", &UpdateLinkRequest{CampaignID: &id}), "campaigns are not enabled")
+
+	s.UseCampaigns(&fakeCampaignStorage{campaigns: map[uuid.UUID]*storage.Campaign{mine.ID: mine, theirs.ID: theirs}})
+	require.NoError(t, s.UpdateLink(ctx, "abc", &UpdateLinkRequest{CampaignID: &id}))
+	assert.Equal(t, &mine.ID, links.link.CampaignID)
+
+	other := theirs.ID.String()
+	assert.ErrorIs(t, s.UpdateLink(ctx, "abc", &UpdateLinkRequest{CampaignID: &other}), ErrCampaignNotFound)
+
+	none := ""
+	require.NoError(t, s.UpdateLink(ctx, "abc", &UpdateLinkRequest{CampaignID: &none}))
+	assert.Nil(t, links.link.CampaignID)
+}
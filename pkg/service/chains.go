@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/security"
+)
+
+// A destination on one of this service's own domains would redirect back
+// here, possibly in a loop, so it is always refused. Destinations on other
+// link shorteners hide where a link really goes, which is how blocklists get
+// evaded, so at most ShortenerChainDepth of them may be chained. Without
+// expansion a known shortener counts as one hop. With it, their redirects are
+// followed by HEAD requests, only ever to hosts on the shortener list, to
+// find loops and longer chains behind them.
+
+// FlagShortenerChain is reported for a destination that chains through too
+// many shorteners when the chain action is "flag"
+const FlagShortenerChain = "shortener_chain"
+
+// chainHopTimeout bounds each HEAD request made to expand a shortener link
+const chainHopTimeout = 3 * time.Second
+
+// ErrRedirectLoop is returned for destinations on this service's domains
+var ErrRedirectLoop = errors.New("invalid URL: destination points back at this shortener")
+
+// chainClient fetches one hop at a time
+var chainClient = security.NewOutboundClient(chainHopTimeout, 0)
+
+// isOwnHost reports whether short links are served on host
+func (s *LinkService) isOwnHost(host string) bool {
+	if base, err := url.Parse(s.currentSettings().ShortURLBase); err == nil && strings.EqualFold(base.Hostname(), host) {
+		return true
+	}
+	return s.ValidateDomain(host) == nil
+}
+
+// isShortenerHost reports whether host is a known link shortener or a
+// subdomain of one
+func (s *LinkService) isShortenerHost(host string) bool {
+	return hostIn(host, s.currentSettings().ShortenerDomains)
+}
+
+// checkChain refuses destinations that loop back here or chain through more
+// shorteners than allowed. When the chain action is "flag", a long chain is
+// allowed and reported as FlagShortenerChain instead.
+func (s *LinkService) checkChain(ctx context.Context, dest *url.URL) (string, error) {
+	settings := s.currentSettings()
+	hops := 0
+	for next := dest; next != nil; next = s.expandHop(ctx, next) {
+		if s.isOwnHost(next.Hostname()) {
+			return "", ErrRedirectLoop
+		}
+		if !s.isShortenerHost(next.Hostname()) {
+			break
+		}
+		hops++
+		if hops > settings.ShortenerChainDepth {
+			if settings.ShortenerChainAction == "flag" {
+				s.logger.Warn(ctx, "destination chains through too many link shorteners", "host", dest.Hostname(), "hops", hops)
+				return FlagShortenerChain, nil
+			}
+			return "", fmt.Errorf("invalid URL: destination goes through more than %d link shorteners", settings.ShortenerChainDepth)
+		}
+		if !settings.ShortenerExpand {
+			break
+		}
+	}
+	return "", nil
+}
+
+// expandHop returns where the shortener link u redirects to, or nil if it
+// doesn't redirect or can't be reached
+func (s *LinkService) expandHop(ctx context.Context, u *url.URL) *url.URL {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.String(), nil)
+	if err != nil {
+		return nil
+	}
+	resp, err := chainClient.Do(req)
+	if err != nil {
+		s.logger.Warn(ctx, "failed to expand shortener link", "host", u.Hostname(), "error", err)
+		return nil
+	}
+	resp.Body.Close()
+	location, err := resp.Location()
+	if err != nil || resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return nil
+	}
+	return location
+}
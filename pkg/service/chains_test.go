@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"url-shortener/pkg/logging"
+)
+
+func TestCheckChain(t *testing.T) {
+	s := NewLinkService(nil, nil, nil, logging.NewLogger(logging.LevelError))
+	settings := DefaultSettings()
+	settings.ShortDomains = []string{"go.acme.io"}
+	settings.ShortenerDomains = []string{"bit.ly", "tinyurl.com"}
+	s.ApplySettings(settings)
+	ctx := context.Background()
+
+	check := func(rawURL string) (string, error) {
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+		return s.checkChain(ctx, u)
+	}
+
+	_, err := check("https://example.com/page")
+	assert.NoError(t, err)
+	_, err = check("http://localhost:8081/r/abc")
+	assert.ErrorIs(t, err, ErrRedirectLoop)
+	_, err = check("https://GO.acme.io/r/abc")
+	assert.ErrorIs(t, err, ErrRedirectLoop)
+	_, err = check("https://bit.ly/abc")
+	assert.NoError(t, err, "one shortener is within the default depth")
+
+	settings.ShortenerChainDepth = 0
+	s.ApplySettings(settings)
+	_, err = check("https://www.bit.ly/abc")
+	assert.ErrorContains(t, err, "more than 0 link shorteners")
+
+	settings.ShortenerChainAction = "flag"
+	s.ApplySettings(settings)
+	flag, err := check("https://bit.ly/abc")
+	assert.NoError(t, err)
+	assert.Equal(t, FlagShortenerChain, flag)
+}
+
+func TestCheckChainExpands(t *testing.T) {
+	var target string
+	shortener := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		http.Redirect(w, r, target, http.StatusMovedPermanently)
+	}))
+	defer shortener.Close()
+	// The outbound client won't connect to the test server on loopback
+	defer func(client *http.Client) { chainClient = client }(chainClient)
+	chainClient = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	s := NewLinkService(nil, nil, nil, logging.NewLogger(logging.LevelError))
+	settings := DefaultSettings()
+	settings.ShortenerDomains = []string{"127.0.0.1", "bit.ly"}
+	settings.ShortenerExpand = true
+	s.ApplySettings(settings)
+	dest, err := url.Parse(shortener.URL + "/abc")
+	require.NoError(t, err)
+
+	target = "https://example.com/page"
+	_, err = s.checkChain(context.Background(), dest)
+	assert.NoError(t, err)
+
+	target = "http://localhost:8081/r/abc"
+	_, err = s.checkChain(context.Background(), dest)
+	assert.ErrorIs(t, err, ErrRedirectLoop)
+
+	// bit.ly is a second shortener, over the depth before it is followed
+	target = "https://bit.ly/abc"
+	_, err = s.checkChain(context.Background(), dest)
+	assert.ErrorContains(t, err, "more than 1 link shorteners")
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"strings"
+
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+)
+
+func init() {
+	validation.Register("cidrs", func(v reflect.Value, _ string) (bool, string) {
+		for i := 0; i < v.Len(); i++ {
+			if _, err := ParsePrefix(v.Index(i).String()); err != nil {
+				return false, "must be CIDR ranges or IP addresses"
+			}
+		}
+		return true, ""
+	})
+	validation.Register("substrings", func(v reflect.Value, _ string) (bool, string) {
+		for i := 0; i < v.Len(); i++ {
+			if n := len(strings.TrimSpace(v.Index(i).String())); n == 0 || n > 200 {
+				return false, "entries must be 1-200 characters"
+			}
+		}
+		return true, ""
+	})
+}
+
+// Visit is a redirect request, as far as click counting cares
+type Visit struct {
+	IP        netip.Addr
+	UserAgent string
+	Referrer  string
+	Query     url.Values
+	// VisitorID is the visitor cookie; NewVisitor is set when the cookie
+	// was issued by this request
+	VisitorID  string
+	NewVisitor bool
+}
+
+// CountsClick reports whether visit counts as a click on link. Visits
+// matching the global or the link's exclusions still redirect, but don't
+// show up in click counts, stats or click webhooks.
+func (s *Resolver) CountsClick(link *storage.Link, visit Visit) bool {
+	settings := s.currentSettings()
+	if settings.ClickPreviewParam != "" && visit.Query.Has(settings.ClickPreviewParam) {
+		return false
+	}
+	ip := visit.IP.Unmap()
+	for _, prefix := range settings.ClickExcludeCIDRs {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	for _, prefix := range networksOf(link).Exclude {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	if len(settings.ClickExcludeUserAgents) == 0 && len(link.ExcludeUserAgents) == 0 {
+		return true
+	}
+	ua := strings.ToLower(visit.UserAgent)
+	for _, patterns := range [][]string{settings.ClickExcludeUserAgents, link.ExcludeUserAgents} {
+		for _, pattern := range patterns {
+			if pattern != "" && strings.Contains(ua, strings.ToLower(pattern)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// AllowsRequester reports whether link redirects for a request from ip: it
+// must be in one of the link's AllowCIDRs, if it has any, and in none of its
+// DenyCIDRs. An unknown address is only allowed by links without rules.
+func (s *Resolver) AllowsRequester(link *storage.Link, ip netip.Addr) bool {
+	if len(link.AllowCIDRs) == 0 && len(link.DenyCIDRs) == 0 {
+		return true
+	}
+	if !ip.IsValid() {
+		return false
+	}
+	ip = ip.Unmap()
+	networks := networksOf(link)
+	for _, prefix := range networks.Deny {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	if len(link.AllowCIDRs) == 0 {
+		return true
+	}
+	for _, prefix := range networks.Allow {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseNetworks parses link's CIDR lists, for GetLink to do once per load.
+// The cidrs rule rejects malformed entries when links are written, so any
+// left can only be in links saved before it and are skipped; a link whose
+// AllowCIDRs are all malformed still allows no one.
+func parseNetworks(link *storage.Link) *storage.LinkNetworks {
+	parse := func(cidrs []string) []netip.Prefix {
+		var prefixes []netip.Prefix
+		for _, cidr := range cidrs {
+			if prefix, err := ParsePrefix(cidr); err == nil {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+		return prefixes
+	}
+	return &storage.LinkNetworks{
+		Exclude: parse(link.ExcludeCIDRs),
+		Allow:   parse(link.AllowCIDRs),
+		Deny:    parse(link.DenyCIDRs),
+	}
+}
+
+// networksOf returns link's parsed CIDR lists, parsing them now for links
+// that didn't come from GetLink
+func networksOf(link *storage.Link) *storage.LinkNetworks {
+	if link.Networks != nil {
+		return link.Networks
+	}
+	return parseNetworks(link)
+}
+
+// DedupsClicks reports whether repeat visits within ClickDedupWindow are
+// counted once
+func (s *Resolver) DedupsClicks() bool {
+	return s.currentSettings().ClickDedupWindow > 0
+}
+
+// FirstVisit reports whether visit is the visitor's first to the link with
+// key within the dedup window. Visitors are known by their cookie, or by a hash of IP
+// and user agent until they have one. If Redis is unavailable the visit is
+// counted.
+func (s *Resolver) FirstVisit(ctx context.Context, key string, visit Visit) bool {
+	window := s.currentSettings().ClickDedupWindow
+	if window <= 0 {
+		return true
+	}
+
+	var keys []string
+	if visit.VisitorID == "" || visit.NewVisitor {
+		sum := sha256.Sum256([]byte(visit.IP.Unmap().String() + "\n" + visit.UserAgent))
+		keys = append(keys, "h:"+hex.EncodeToString(sum[:16]))
+	}
+	if visit.VisitorID != "" {
+		keys = append(keys, "c:"+visit.VisitorID)
+	}
+	first, err := s.cache.MarkVisit(ctx, key, keys, window)
+	if err != nil {
+		s.logger.Warn(ctx, "failed to check click dedup window", "code", key, "error", err)
+		return true
+	}
+	return first
+}
+
+// ParsePrefix parses a CIDR range, treating a bare address as a range of one
+func ParsePrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if prefix, err := netip.ParsePrefix(s); err == nil {
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// normalizeList trims entries and drops empty ones
+func normalizeList(entries []string) []string {
+	var out []string
+	for _, e := range entries {
+		if e = strings.TrimSpace(e); e != "" {
+			out = append(out, e)
+		}
+	}
+	return out
+}
@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountsClick(t *testing.T) {
+	s := NewLinkService(nil, nil, nil, nil)
+	settings := DefaultSettings()
+	settings.ClickExcludeCIDRs = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	settings.ClickExcludeUserAgents = []string{"UptimeRobot"}
+	s.ApplySettings(settings)
+
+	link := &storage.Link{
+		ExcludeCIDRs:      []string{"203.0.113.0/24", "2001:db8::1"},
+		ExcludeUserAgents: []string{"qa-suite"},
+	}
+	visit := func(ip, ua, query string) Visit {
+		q, _ := url.ParseQuery(query)
+		return Visit{IP: netip.MustParseAddr(ip), UserAgent: ua, Query: q}
+	}
+
+	tests := []struct {
+		name  string
+		visit Visit
+		count bool
+	}{
+		{"ordinary visit", visit("198.51.100.1", "Mozilla/5.0", ""), true},
+		{"global range", visit("10.20.30.40", "Mozilla/5.0", ""), false},
+		{"IPv4-mapped global range", visit("::ffff:10.0.0.1", "Mozilla/5.0", ""), false},
+		{"link range", visit("203.0.113.9", "Mozilla/5.0", ""), false},
+		{"link address", visit("2001:db8::1", "Mozilla/5.0", ""), false},
+		{"global user agent, any case", visit("198.51.100.1", "Mozilla/5.0 (compatible; uptimerobot/2.0)", ""), false},
+		{"link user agent", visit("198.51.100.1", "qa-suite/1.4", ""), false},
+		{"preview param", visit("198.51.100.1", "Mozilla/5.0", "preview=1"), false},
+		{"other param", visit("198.51.100.1", "Mozilla/5.0", "utm_source=x"), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.count, s.CountsClick(link, tt.visit))
+		})
+	}
+
+	// Other links only get the global exclusions
+	assert.True(t, s.CountsClick(&storage.Link{}, visit("203.0.113.9", "qa-suite/1.4", "")))
+}
+
+func TestExclusionValidation(t *testing.T) {
+	assert.NoError(t, validation.Struct(&CreateLinkRequest{
+		LongURL:           "https://example.com",
+		ExcludeCIDRs:      []string{"192.0.2.0/24", "::1"},
+		ExcludeUserAgents: []string{"bot"},
+	}))
+	assert.Error(t, validation.Struct(&CreateLinkRequest{LongURL: "https://example.com", ExcludeCIDRs: []string{"office"}}))
+	assert.Error(t, validation.Struct(&CreateLinkRequest{LongURL: "https://example.com", ExcludeUserAgents: []string{" "}}))
+
+	office := []string{"10.0.0.0/8", "office"}
+	assert.Error(t, validation.Struct(&UpdateLinkRequest{AllowCIDRs: &office}))
+	assert.Error(t, validation.Struct(&UpdateLinkRequest{DenyCIDRs: &office}))
+	assert.Error(t, validation.Struct(&UpdateLinkRequest{ExcludeCIDRs: &office}))
+}
+
+func TestAllowsRequester(t *testing.T) {
+	s := NewLinkService(nil, nil, nil, nil)
+	internal := &storage.Link{AllowCIDRs: []string{"10.0.0.0/8", "2001:db8::/32"}, DenyCIDRs: []string{"10.66.0.0/16"}}
+	blocked := &storage.Link{DenyCIDRs: []string{"203.0.113.0/24"}}
+
+	assert.True(t, s.AllowsRequester(internal, netip.MustParseAddr("10.1.2.3")))
+	assert.True(t, s.AllowsRequester(internal, netip.MustParseAddr("::ffff:10.1.2.3")))
+	assert.True(t, s.AllowsRequester(internal, netip.MustParseAddr("2001:db8::5")))
+	assert.False(t, s.AllowsRequester(internal, netip.MustParseAddr("10.66.0.1")), "deny wins")
+	assert.False(t, s.AllowsRequester(internal, netip.MustParseAddr("198.51.100.1")))
+	assert.False(t, s.AllowsRequester(internal, netip.Addr{}))
+
+	assert.True(t, s.AllowsRequester(blocked, netip.MustParseAddr("198.51.100.1")))
+	assert.False(t, s.AllowsRequester(blocked, netip.MustParseAddr("203.0.113.7")))
+	assert.True(t, s.AllowsRequester(&storage.Link{}, netip.Addr{}))
+}
+
+// entryCache serves entry, nil for a miss, for every code
+type entryCache struct {
+	cache.LinkCacheInterface
+	entry *cache.CachedLink
+}
+
+func (c *entryCache) Get(ctx context.Context, code string) (*cache.CachedLink, error) {
+	return c.entry, nil
+}
+
+func (c *entryCache) Set(ctx context.Context, code string, link *cache.CachedLink, ttl time.Duration) error {
+	return nil
+}
+
+func TestNetworksAreParsedOnLoad(t *testing.T) {
+	ctx := context.Background()
+	logger := logging.NewLogger(logging.LevelError)
+	cached := &cache.CachedLink{LongURL: "https://example.com", AllowCIDRs: []string{"10.0.0.0/8"}, DenyCIDRs: []string{"office"}}
+	s := NewResolver(nil, &entryCache{entry: cached}, logger)
+	first, err := s.GetLink(ctx, "abc")
+	require.NoError(t, err)
+	second, err := s.GetLink(ctx, "abc")
+	require.NoError(t, err)
+	require.NotNil(t, first.Networks)
+	assert.Same(t, first.Networks, second.Networks, "cache hits reuse the parsed lists")
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}, first.Networks.Allow)
+	assert.Empty(t, first.Networks.Deny, "malformed entries saved before validation are skipped")
+	assert.True(t, s.AllowsRequester(first, netip.MustParseAddr("10.1.2.3")))
+
+	// Links read from the database are parsed too
+	links := &updatableLinks{link: &storage.Link{Code: "abc", LongURL: "https://example.com", ExcludeCIDRs: []string{"203.0.113.0/24"}}}
+	s = NewResolver(links, &entryCache{}, logger)
+	link, err := s.GetLink(ctx, "abc")
+	require.NoError(t, err)
+	require.NotNil(t, link.Networks)
+	assert.Equal(t, []netip.Prefix{netip.MustParsePrefix("203.0.113.0/24")}, link.Networks.Exclude)
+	assert.False(t, s.CountsClick(link, Visit{IP: netip.MustParseAddr("203.0.113.9")}))
+
+	// An allow list with only malformed entries still allows no one
+	assert.False(t, s.AllowsRequester(&storage.Link{AllowCIDRs: []string{"office"}}, netip.MustParseAddr("10.1.2.3")))
+}
+
+type visitCache struct {
+	cache.LinkCacheInterface
+	seen map[string]bool
+}
+
+func (c *visitCache) MarkVisit(ctx context.Context, code string, keys []string, window time.Duration) (bool, error) {
+	first := true
+	for _, key := range keys {
+		if c.seen[code+":"+key] {
+			first = false
+		}
+		c.seen[code+":"+key] = true
+	}
+	return first, nil
+}
+
+func TestFirstVisit(t *testing.T) {
+	s := NewLinkService(nil, &visitCache{seen: map[string]bool{}}, nil, nil)
+	ctx := context.Background()
+	anon := Visit{IP: netip.MustParseAddr("198.51.100.1"), UserAgent: "Mozilla/5.0"}
+	assert.True(t, s.FirstVisit(ctx, "abc", anon), "dedup is off by default")
+	assert.True(t, s.FirstVisit(ctx, "abc", anon))
+
+	settings := DefaultSettings()
+	settings.ClickDedupWindow = time.Hour
+	s.ApplySettings(settings)
+
+	// First visit issues a cookie; later ones are known by it
+	first := anon
+	first.VisitorID, first.NewVisitor = "cookie-1", true
+	assert.True(t, s.FirstVisit(ctx, "abc", first))
+	returning := anon
+	returning.VisitorID = "cookie-1"
+	assert.False(t, s.FirstVisit(ctx, "abc", returning))
+	// Clearing cookies doesn't help from the same IP and browser
+	cleared := anon
+	cleared.VisitorID, cleared.NewVisitor = "cookie-2", true
+	assert.False(t, s.FirstVisit(ctx, "abc", cleared))
+
+	// Another visitor behind the same NAT with their own cookie still counts
+	neighbour := anon
+	neighbour.VisitorID = "cookie-3"
+	assert.True(t, s.FirstVisit(ctx, "abc", neighbour))
+	// Windows are per link
+	assert.True(t, s.FirstVisit(ctx, "other", returning))
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"url-shortener/pkg/cache"
+)
+
+// With a click queue, RecordClick only puts the click on an in-process
+// queue and returns, and RunClickQueue applies queued clicks in batches in
+// the background, so a slow or unreachable Redis never delays a redirect.
+// When the queue is full a click is either still counted, in the memory
+// buffer the click sync saves, but left out of day counts, leaderboards and
+// analytics (ClickQueueCount), or dropped entirely (ClickQueueDrop).
+
+// What RecordClick does with a click when the click queue is full
+const (
+	ClickQueueCount = "count"
+	ClickQueueDrop  = "drop"
+)
+
+const (
+	// clickQueueBatch is how many queued clicks are applied at once
+	clickQueueBatch = 500
+	// clickQueueReport is how often clicks that didn't fit are logged
+	clickQueueReport = 10 * time.Second
+)
+
+type clickQueue struct {
+	clicks chan *cache.StreamedClick
+	// whenFull is ClickQueueCount or ClickQueueDrop
+	whenFull string
+	// Clicks that didn't fit since the last report
+	degraded atomic.Int64
+	dropped  atomic.Int64
+}
+
+// UseClickQueue makes RecordClick queue up to size clicks for
+// RunClickQueue instead of applying them before returning. whenFull is
+// ClickQueueCount or ClickQueueDrop.
+func (s *Resolver) UseClickQueue(size int, whenFull string) {
+	s.queue = &clickQueue{clicks: make(chan *cache.StreamedClick, size), whenFull: whenFull}
+}
+
+// enqueue puts click on the queue without blocking
+func (q *clickQueue) enqueue(click *cache.StreamedClick, buffer *clickBuffer) {
+	select {
+	case q.clicks <- click:
+	default:
+		if q.whenFull == ClickQueueDrop {
+			q.dropped.Add(1)
+			return
+		}
+		buffer.add(click.Key, 1)
+		q.degraded.Add(1)
+	}
+}
+
+// RunClickQueue applies queued clicks until ctx is done, then applies what
+// is still queued before returning. Callers stopping the process should
+// wait for it after the server has stopped taking requests, and before
+// stopping the click sync and analytics sinks.
+func (s *Resolver) RunClickQueue(ctx context.Context) {
+	report := time.NewTicker(clickQueueReport)
+	defer report.Stop()
+	batch := make([]*cache.StreamedClick, 0, clickQueueBatch)
+
+	for {
+		select {
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.Background(), finalSyncTimeout)
+			defer cancel()
+			for len(s.queue.clicks) > 0 {
+				batch = append(batch, <-s.queue.clicks)
+				if len(batch) == clickQueueBatch {
+					s.applyQueued(final, batch)
+					batch = batch[:0]
+				}
+			}
+			s.applyQueued(final, batch)
+			return
+		case click := <-s.queue.clicks:
+			// Take whatever else is already waiting, up to a batch
+			batch = append(batch, click)
+			for len(batch) < clickQueueBatch && len(s.queue.clicks) > 0 {
+				batch = append(batch, <-s.queue.clicks)
+			}
+			s.applyQueued(ctx, batch)
+			batch = batch[:0]
+		case <-report.C:
+			if n := s.queue.degraded.Swap(0); n > 0 {
+				s.logger.Warn(ctx, "click queue full, clicks only counted", "count", n)
+			}
+			if n := s.queue.dropped.Swap(0); n > 0 {
+				s.logger.Warn(ctx, "click queue full, clicks dropped", "count", n)
+			}
+		}
+	}
+}
+
+// applyQueued applies a batch as RecordClick would have applied each click,
+// in a round trip or two
+func (s *Resolver) applyQueued(ctx context.Context, clicks []*cache.StreamedClick) {
+	if len(clicks) == 0 {
+		return
+	}
+	if s.stream != nil {
+		if err := s.stream.Publish(ctx, clicks...); err != nil {
+			s.logger.Warn(ctx, "failed to publish clicks, counting them in memory", "count", len(clicks), "error", err)
+			for _, click := range clicks {
+				s.clicks.add(click.Key, 1)
+			}
+		}
+		return
+	}
+
+	deltas := make(map[string]int64)
+	for _, click := range clicks {
+		deltas[click.Key]++
+	}
+	if err := s.cache.ReturnClickDeltas(ctx, deltas); err != nil {
+		// Kept in memory and saved by this server's next sync
+		for key, n := range deltas {
+			s.clicks.add(key, n)
+		}
+		s.logger.Warn(ctx, "failed to count clicks", "count", len(clicks), "error", err)
+	} else if err := s.cache.RecordClicks(ctx, clicks); err != nil {
+		s.logger.Warn(ctx, "failed to record daily and top clicks", "count", len(clicks), "error", err)
+	}
+	for _, click := range clicks {
+		s.recordAnalytics(click)
+	}
+}
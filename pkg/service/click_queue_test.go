@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClickQueueAppliesInBackground(t *testing.T) {
+	ctx := context.Background()
+	rollups := &streamCache{clickCache: clickCache{deltas: map[string]int64{}}}
+	sink := &eventLog{}
+	s := NewLinkService(&clickCounts{counts: map[string]int64{}}, rollups, nil, logging.NewLogger(logging.LevelError))
+	s.UseClickQueue(10, ClickQueueCount)
+	s.UseAnalytics(sink)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.RecordClick(ctx, &storage.Link{Code: "abc"}, Visit{}))
+	}
+	// Nothing is applied on the redirect path
+	assert.Empty(t, rollups.deltas)
+	assert.Empty(t, sink.events)
+
+	queue, stop := context.WithCancel(ctx)
+	stop()
+	s.RunClickQueue(queue)
+
+	assert.Equal(t, map[string]int64{"abc": 3}, rollups.deltas)
+	assert.Equal(t, 3, rollups.recorded)
+	assert.Len(t, sink.events, 3)
+}
+
+func TestClickQueueFull(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range []struct {
+		whenFull string
+		saved    int64
+	}{
+		{ClickQueueCount, 3},
+		{ClickQueueDrop, 2},
+	} {
+		t.Run(tt.whenFull, func(t *testing.T) {
+			counts := &clickCounts{counts: map[string]int64{}}
+			rollups := &streamCache{clickCache: clickCache{deltas: map[string]int64{}}}
+			s := NewLinkService(counts, rollups, nil, logging.NewLogger(logging.LevelError))
+			s.UseClickQueue(2, tt.whenFull)
+
+			for i := 0; i < 3; i++ {
+				require.NoError(t, s.RecordClick(ctx, &storage.Link{Code: "abc"}, Visit{}))
+			}
+			queue, stop := context.WithCancel(ctx)
+			stop()
+			s.RunClickQueue(queue)
+			require.NoError(t, s.FlushClicks(ctx))
+
+			assert.Equal(t, tt.saved, counts.counts["abc"])
+			// Only queued clicks reach day counts and leaderboards
+			assert.Equal(t, 2, rollups.recorded)
+		})
+	}
+}
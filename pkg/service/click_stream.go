@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"url-shortener/pkg/analytics"
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/storage"
+)
+
+// By default a counted click is applied where it happens: the redirect
+// updates the Redis delta, day counts and leaderboards, and hands the click
+// to the analytics sinks. With a click stream the redirect only publishes
+// the click, in one Redis command, and click workers apply clicks in
+// batches: per-link counts straight to Postgres, day counts and
+// leaderboards in one pipeline, and every click to the workers' analytics
+// sinks. Redirect latency then no longer depends on how many stores a click
+// feeds, and a click published is applied even if the server that took it
+// dies. A click is acknowledged once applied, so a worker dying mid-batch
+// means its batch is applied again by another worker.
+
+const (
+	// clickStreamBatch is how many clicks a worker applies at once
+	clickStreamBatch = 500
+	// clickStreamBlock is how long a worker waits for new clicks
+	clickStreamBlock = 5 * time.Second
+	// clickStreamRetry is the pause after the stream can't be read
+	clickStreamRetry = time.Second
+)
+
+// ClickStream carries clicks from RecordClick to RunClickWorker;
+// *cache.ClickStream implements it
+type ClickStream interface {
+	Publish(ctx context.Context, clicks ...*cache.StreamedClick) error
+	Read(ctx context.Context, consumer string, count int, block time.Duration) ([]*cache.StreamedClick, error)
+	Ack(ctx context.Context, ids ...string) error
+}
+
+// UseClickStream publishes clicks to stream instead of applying them in
+// RecordClick; run click workers on it with RunClickWorker
+func (s *Resolver) UseClickStream(stream ClickStream) {
+	s.stream = stream
+}
+
+// UseAnalytics hands every click this resolver applies to sink, in addition
+// to any sinks added before
+func (s *Resolver) UseAnalytics(sink analytics.Sink) {
+	s.sinks = append(s.sinks, sink)
+}
+
+// RecordClick counts visit as a click on link, publishing it when there is
+// a click stream. A click that can't be published is counted in memory and
+// saved by the next click sync, without reaching analytics. With a click
+// queue the click is only queued; see click_queue.go.
+func (s *Resolver) RecordClick(ctx context.Context, link *storage.Link, visit Visit) error {
+	s.tenantClicks.add(link.TenantID)
+	click := &cache.StreamedClick{
+		Key:       link.Key(),
+		OwnerID:   link.OwnerID,
+		TenantID:  link.TenantID,
+		At:        time.Now(),
+		Referrer:  visit.Referrer,
+		UserAgent: visit.UserAgent,
+	}
+	if s.queue != nil {
+		s.queue.enqueue(click, &s.clicks)
+		return nil
+	}
+	if s.stream != nil {
+		if err := s.stream.Publish(ctx, click); err != nil {
+			s.clicks.add(click.Key, 1)
+			return err
+		}
+		return nil
+	}
+	err := s.IncrementClickCount(ctx, link)
+	s.recordAnalytics(click)
+	return err
+}
+
+func (s *Resolver) recordAnalytics(click *cache.StreamedClick) {
+	if len(s.sinks) == 0 {
+		return
+	}
+	domain, code := storage.SplitLinkKey(click.Key)
+	event := &analytics.Event{
+		Code:      code,
+		Domain:    domain,
+		TenantID:  click.TenantID,
+		ClickedAt: click.At,
+		Referrer:  click.Referrer,
+		UserAgent: click.UserAgent,
+	}
+	if click.OwnerID != nil {
+		event.OwnerID = click.OwnerID.String()
+	}
+	for _, sink := range s.sinks {
+		sink.Record(event)
+	}
+}
+
+// RunClickWorker applies clicks from stream as consumer until ctx is done.
+// Any number of workers, in one process or several, can share a stream as
+// long as each has its own consumer name. Clicks that can't be saved to
+// Postgres are kept for this process's click sync, so it should keep
+// running until the workers have returned.
+func (s *Resolver) RunClickWorker(ctx context.Context, stream ClickStream, consumer string) {
+	for ctx.Err() == nil {
+		clicks, err := stream.Read(ctx, consumer, clickStreamBatch, clickStreamBlock)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.logger.Warn(ctx, "failed to read click stream", "consumer", consumer, "error", err)
+				select {
+				case <-ctx.Done():
+				case <-time.After(clickStreamRetry):
+				}
+			}
+			continue
+		}
+		if len(clicks) == 0 {
+			continue
+		}
+
+		// A batch that was read is applied in full, even when stopping
+		apply, cancel := context.WithTimeout(context.WithoutCancel(ctx), finalSyncTimeout)
+		s.applyClicks(apply, clicks)
+		ids := make([]string, len(clicks))
+		for i, click := range clicks {
+			ids[i] = click.ID
+		}
+		if err := stream.Ack(apply, ids...); err != nil {
+			s.logger.Warn(apply, "failed to acknowledge clicks, they will be applied again", "count", len(ids), "error", err)
+		}
+		cancel()
+	}
+}
+
+func (s *Resolver) applyClicks(ctx context.Context, clicks []*cache.StreamedClick) {
+	counts := make(map[string]int64)
+	for _, click := range clicks {
+		counts[click.Key]++
+	}
+	for key, n := range counts {
+		if err := s.storage.AddClickCount(ctx, key, n); err != nil {
+			s.logger.Warn(ctx, "failed to save clicks, will retry", "code", key, "error", err)
+			s.clicks.add(key, n)
+		}
+	}
+
+	// As on the redirect path, day counts and leaderboards may lose clicks
+	if err := s.cache.RecordClicks(ctx, clicks); err != nil {
+		s.logger.Warn(ctx, "failed to record daily and top clicks", "count", len(clicks), "error", err)
+	}
+	for _, click := range clicks {
+		s.recordAnalytics(click)
+	}
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/analytics"
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memStream hands out what was published once, then reports no clicks
+type memStream struct {
+	mu        sync.Mutex
+	published []*cache.StreamedClick
+	acked     []string
+	down      bool
+}
+
+func (m *memStream) Publish(ctx context.Context, clicks ...*cache.StreamedClick) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.down {
+		return errors.New("connection refused")
+	}
+	for _, click := range clicks {
+		click.ID = strconv.Itoa(len(m.published))
+		m.published = append(m.published, click)
+	}
+	return nil
+}
+
+func (m *memStream) Read(ctx context.Context, consumer string, count int, block time.Duration) ([]*cache.StreamedClick, error) {
+	m.mu.Lock()
+	clicks := m.published
+	m.published = nil
+	m.mu.Unlock()
+	if len(clicks) == 0 {
+		<-ctx.Done()
+	}
+	return clicks, nil
+}
+
+func (m *memStream) Ack(ctx context.Context, ids ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.acked = append(m.acked, ids...)
+	return nil
+}
+
+type streamCache struct {
+	clickCache
+	recorded int
+}
+
+func (c *streamCache) RecordClicks(ctx context.Context, clicks []*cache.StreamedClick) error {
+	c.recorded += len(clicks)
+	return nil
+}
+
+type eventLog struct {
+	analytics.Sink
+	events []*analytics.Event
+}
+
+func (l *eventLog) Record(event *analytics.Event) { l.events = append(l.events, event) }
+
+func TestClickWorkerAppliesStream(t *testing.T) {
+	ctx := context.Background()
+	counts := &clickCounts{counts: map[string]int64{}}
+	rollups := &streamCache{clickCache: clickCache{deltas: map[string]int64{}}}
+	stream := &memStream{}
+	sink := &eventLog{}
+	s := NewLinkService(counts, rollups, nil, logging.NewLogger(logging.LevelError))
+	s.UseClickStream(stream)
+	s.UseAnalytics(sink)
+
+	domain := "go.example"
+	for i := 0; i < 2; i++ {
+		require.NoError(t, s.RecordClick(ctx, &storage.Link{Code: "abc"}, Visit{Referrer: "https://news.example/"}))
+	}
+	require.NoError(t, s.RecordClick(ctx, &storage.Link{Code: "xyz", Domain: &domain}, Visit{}))
+	// Nothing is applied on the redirect path
+	assert.Empty(t, counts.counts)
+	assert.Empty(t, rollups.deltas)
+	assert.Empty(t, sink.events)
+
+	worker, stop := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		s.RunClickWorker(worker, stream, "test-0")
+		close(done)
+	}()
+	require.Eventually(t, func() bool {
+		stream.mu.Lock()
+		defer stream.mu.Unlock()
+		return len(stream.acked) == 3
+	}, time.Second, time.Millisecond)
+	stop()
+	<-done
+
+	assert.Equal(t, map[string]int64{"abc": 2, "go.example/xyz": 1}, counts.counts)
+	assert.Equal(t, 3, rollups.recorded)
+	require.Len(t, sink.events, 3)
+	assert.Equal(t, "https://news.example/", sink.events[0].Referrer)
+	assert.Equal(t, "go.example", sink.events[2].Domain)
+	assert.Equal(t, "xyz", sink.events[2].Code)
+}
+
+func TestClickStreamDownCountsInMemory(t *testing.T) {
+	ctx := context.Background()
+	counts := &clickCounts{counts: map[string]int64{}}
+	s := NewLinkService(counts, &clickCache{deltas: map[string]int64{}}, nil, logging.NewLogger(logging.LevelError))
+	s.UseClickStream(&memStream{down: true})
+
+	assert.Error(t, s.RecordClick(ctx, &storage.Link{Code: "abc"}, Visit{}))
+	assert.NoError(t, s.FlushClicks(ctx))
+	assert.Equal(t, int64(1), counts.counts["abc"])
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Counted clicks accumulate as per-link deltas in Redis, shared by every
+// server, and RunClickSync moves them into Postgres as one click_count + n
+// update per link. Taking a delta resets it in the same step, so each click
+// is added once however many servers sync. Clicks that can't reach Redis are
+// buffered in memory and saved directly; a delta that fails to save is put
+// back for the next sync.
+
+const (
+	// clickSyncBatch is how many links' deltas are taken from Redis at once
+	clickSyncBatch = 500
+	// finalSyncTimeout bounds the flush when RunClickSync stops
+	finalSyncTimeout = 10 * time.Second
+)
+
+type clickBuffer struct {
+	mu      sync.Mutex
+	pending map[string]int64
+}
+
+func (b *clickBuffer) add(code string, n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.pending == nil {
+		b.pending = make(map[string]int64)
+	}
+	b.pending[code] += n
+}
+
+// take empties the buffer and returns what it held
+func (b *clickBuffer) take() map[string]int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	pending := b.pending
+	b.pending = nil
+	return pending
+}
+
+// FlushClicks adds pending clicks to Postgres: those buffered in memory,
+// then the Redis deltas, a batch at a time until none are left or a save
+// fails.
+func (s *Resolver) FlushClicks(ctx context.Context) error {
+	var errs []error
+	for code, n := range s.clicks.take() {
+		if err := s.storage.AddClickCount(ctx, code, n); err != nil {
+			s.clicks.add(code, n)
+			errs = append(errs, fmt.Errorf("%s: %w", code, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	for {
+		deltas, err := s.cache.TakeClickDeltas(ctx, clickSyncBatch)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		failed := make(map[string]int64)
+		for code, n := range deltas {
+			if err := s.storage.AddClickCount(ctx, code, n); err != nil {
+				failed[code] = n
+				errs = append(errs, fmt.Errorf("%s: %w", code, err))
+			}
+		}
+		if len(failed) > 0 {
+			if err := s.cache.ReturnClickDeltas(ctx, failed); err != nil {
+				for code, n := range failed {
+					s.clicks.add(code, n)
+				}
+			}
+		}
+		if len(errs) > 0 || len(deltas) == 0 {
+			return errors.Join(errs...)
+		}
+	}
+}
+
+// RunClickSync flushes pending clicks every interval until ctx is done, then
+// flushes one last time before returning. Callers stopping the process
+// should wait for it to return after the server has stopped taking
+// requests.
+func (s *Resolver) RunClickSync(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			final, cancel := context.WithTimeout(context.Background(), finalSyncTimeout)
+			defer cancel()
+			if err := s.FlushClicks(final); err != nil {
+				s.logger.Error(final, "failed to save clicks on shutdown", "error", err)
+			}
+			return
+		case <-ticker.C:
+			if err := s.FlushClicks(ctx); err != nil {
+				s.logger.Warn(ctx, "failed to save clicks, will retry", "error", err)
+			}
+		}
+	}
+}
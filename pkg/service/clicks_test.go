@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type clickCounts struct {
+	storage.LinkStorage
+	counts map[string]int64
+	down   bool
+}
+
+func (c *clickCounts) AddClickCount(ctx context.Context, code string, n int64) error {
+	if c.down {
+		return errors.New("connection refused")
+	}
+	c.counts[code] += n
+	return nil
+}
+
+// clickCache keeps pending deltas like the Redis cache does
+type clickCache struct {
+	cache.LinkCacheInterface
+	deltas map[string]int64
+	down   bool
+}
+
+func (c *clickCache) IncrementClick(ctx context.Context, code string) error {
+	if c.down {
+		return errors.New("connection refused")
+	}
+	c.deltas[code]++
+	return nil
+}
+
+func (c *clickCache) IncrementDailyClick(ctx context.Context, code string, at time.Time) error {
+	return nil
+}
+
+func (c *clickCache) RecordTopClick(ctx context.Context, code string, ownerID *uuid.UUID, at time.Time) error {
+	return nil
+}
+
+func (c *clickCache) TakeClickDeltas(ctx context.Context, limit int) (map[string]int64, error) {
+	taken := make(map[string]int64)
+	for code, n := range c.deltas {
+		if len(taken) == limit {
+			break
+		}
+		taken[code] = n
+		delete(c.deltas, code)
+	}
+	return taken, nil
+}
+
+func (c *clickCache) ReturnClickDeltas(ctx context.Context, deltas map[string]int64) error {
+	for code, n := range deltas {
+		c.deltas[code] += n
+	}
+	return nil
+}
+
+func TestFlushClicksMovesDeltas(t *testing.T) {
+	ctx := context.Background()
+	counts := &clickCounts{counts: map[string]int64{}}
+	pending := &clickCache{deltas: map[string]int64{}}
+	s := NewLinkService(counts, pending, nil, logging.NewLogger(logging.LevelError))
+
+	for i := 0; i < 3; i++ {
+		s.IncrementClickCount(ctx, &storage.Link{Code: "abc"})
+	}
+	s.IncrementClickCount(ctx, &storage.Link{Code: "xyz"})
+	assert.Empty(t, counts.counts)
+
+	assert.NoError(t, s.FlushClicks(ctx))
+	assert.Equal(t, map[string]int64{"abc": 3, "xyz": 1}, counts.counts)
+	assert.Empty(t, pending.deltas)
+
+	// A failed save puts the delta back for the next flush
+	counts.down = true
+	s.IncrementClickCount(ctx, &storage.Link{Code: "abc"})
+	assert.Error(t, s.FlushClicks(ctx))
+	assert.Equal(t, int64(1), pending.deltas["abc"])
+	counts.down = false
+	s.IncrementClickCount(ctx, &storage.Link{Code: "abc"})
+	assert.NoError(t, s.FlushClicks(ctx))
+	assert.Equal(t, int64(5), counts.counts["abc"])
+}
+
+func TestClicksBufferedWhileRedisIsDown(t *testing.T) {
+	ctx := context.Background()
+	counts := &clickCounts{counts: map[string]int64{}}
+	pending := &clickCache{deltas: map[string]int64{}, down: true}
+	s := NewLinkService(counts, pending, nil, logging.NewLogger(logging.LevelError))
+
+	s.IncrementClickCount(ctx, &storage.Link{Code: "abc"})
+	s.IncrementClickCount(ctx, &storage.Link{Code: "abc"})
+
+	// Stopping saves what was buffered in memory
+	stopped, cancel := context.WithCancel(ctx)
+	cancel()
+	s.RunClickSync(stopped, time.Hour)
+	assert.Equal(t, int64(2), counts.counts["abc"])
+}
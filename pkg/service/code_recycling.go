@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"url-shortener/pkg/jobs"
+	"url-shortener/pkg/validation"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Deleting a link leaves a tombstone for its code (see
+// storage/tombstones.go), and by default a code with a tombstone is never
+// used again: a short URL printed or shared for the old link must not start
+// leading somewhere else, and clicks in analytics backends, which are
+// recorded by code, must not mix two links. With recycling on, a code can be
+// reused once the quarantine has passed since its link was deleted, and
+// links that expired longer ago than the quarantine are retired daily so
+// their codes come free too. Tombstones are kept either way, so a recycled
+// code's earlier lifetimes can be told apart by time.
+
+// RetireExpiredKind is the job that runs RetireExpired daily
+const RetireExpiredKind = "links.retire_expired"
+
+// retireBatch is how many expired links are retired per statement
+const retireBatch = 500
+
+// UseCodeRecycling lets codes be reused once quarantine has passed since
+// their link was deleted or expired. Without it they never are.
+func (s *LinkService) UseCodeRecycling(quarantine time.Duration) {
+	s.quarantine = quarantine
+}
+
+// UseExpiredRetirement runs RetireExpired daily from a job on q. It does
+// nothing unless codes are recycled.
+func (s *LinkService) UseExpiredRetirement(q *jobs.Queue) {
+	if s.quarantine <= 0 {
+		return
+	}
+	q.Register(RetireExpiredKind, sweepPolicy, s.retireExpired)
+	q.Every(RetireExpiredKind, 24*time.Hour)
+}
+
+func (s *LinkService) retireExpired(ctx context.Context, _ json.RawMessage) error {
+	n, err := s.RetireExpired(ctx)
+	if n > 0 {
+		s.logger.Info(ctx, "retired expired links", "links", n)
+	}
+	return err
+}
+
+// RetireExpired deletes the links that expired more than the quarantine ago,
+// so that their codes can be recycled, and returns how many it deleted.
+// Their Redis click state is left to the orphan sweep.
+func (s *LinkService) RetireExpired(ctx context.Context) (int64, error) {
+	before := time.Now().Add(-s.quarantine)
+	var total int64
+	for {
+		n, err := s.storage.RetireExpired(ctx, before, retireBatch)
+		total += n
+		if err != nil || n < retireBatch {
+			return total, err
+		}
+	}
+}
+
+// checkRetired refuses key if an earlier link under it was deleted or
+// retired within the quarantine, or at all when codes aren't recycled. It
+// runs in the create transaction.
+func (s *LinkService) checkRetired(ctx context.Context, tx pgx.Tx, key string, alias *string) error {
+	retiredAt, err := s.storage.LastRetiredTx(ctx, tx, key)
+	if err != nil || retiredAt == nil {
+		return err
+	}
+	if s.quarantine > 0 && !time.Now().Before(retiredAt.Add(s.quarantine)) {
+		return nil
+	}
+	if alias == nil {
+		return ErrCodeExists
+	}
+	message := "was used by a deleted link and can't be reused"
+	if s.quarantine > 0 {
+		message += " before " + retiredAt.Add(s.quarantine).UTC().Format(time.RFC3339)
+	}
+	return validation.Errors{{Field: "alias", Rule: "retired", Message: message}}
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type retiredLinks struct {
+	storage.LinkStorage
+	retired map[string]time.Time
+	expired int
+	before  time.Time
+}
+
+func (l *retiredLinks) LastRetiredTx(ctx context.Context, tx pgx.Tx, key string) (*time.Time, error) {
+	if at, ok := l.retired[key]; ok {
+		return &at, nil
+	}
+	return nil, nil
+}
+
+func (l *retiredLinks) RetireExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	l.before = before
+	n := min(l.expired, limit)
+	l.expired -= n
+	return int64(n), nil
+}
+
+func TestCheckRetired(t *testing.T) {
+	links := &retiredLinks{retired: map[string]time.Time{
+		"launch":        time.Now().Add(-48 * time.Hour),
+		"go.example/ad": time.Now().Add(-time.Hour),
+	}}
+	s := NewLinkService(links, nil, nil, logging.NewLogger(logging.LevelError))
+	ctx := context.Background()
+	alias := "launch"
+
+	// Codes are never reused by default
+	err := s.checkRetired(ctx, nil, "launch", &alias)
+	var verrs validation.Errors
+	require.ErrorAs(t, err, &verrs)
+	assert.Equal(t, "retired", verrs[0].Rule)
+	assert.ErrorIs(t, s.checkRetired(ctx, nil, "launch", nil), ErrCodeExists)
+	assert.NoError(t, s.checkRetired(ctx, nil, "fresh", &alias))
+
+	// With recycling, once the quarantine is over
+	s.UseCodeRecycling(24 * time.Hour)
+	assert.NoError(t, s.checkRetired(ctx, nil, "launch", &alias))
+	err = s.checkRetired(ctx, nil, "go.example/ad", &alias)
+	require.ErrorAs(t, err, &verrs)
+	assert.Contains(t, verrs[0].Message, "before")
+}
+
+func TestRetireExpired(t *testing.T) {
+	links := &retiredLinks{expired: 1234}
+	s := NewLinkService(links, nil, nil, logging.NewLogger(logging.LevelError))
+	s.UseCodeRecycling(24 * time.Hour)
+
+	n, err := s.RetireExpired(context.Background())
+	require.NoError(t, err)
+	assert.EqualValues(t, 1234, n)
+	assert.Zero(t, links.expired)
+	assert.WithinDuration(t, time.Now().Add(-24*time.Hour), links.before, time.Minute)
+}
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+)
+
+// maxCreationUserAgent is how much of a user agent is kept with a link
+const maxCreationUserAgent = 512
+
+// creationContext is what ctx tells of who is creating a link: the client
+// noted by middleware.RecordClient and the API key or OAuth client the
+// request was authenticated with. It is nil when ctx tells nothing, as for
+// links created by jobs.
+func creationContext(ctx context.Context) *storage.CreationContext {
+	var c storage.CreationContext
+	client := middleware.ClientFromContext(ctx)
+	if client.IP != "" {
+		c.IP = &client.IP
+	}
+	if ua := client.UserAgent; ua != "" {
+		if len(ua) > maxCreationUserAgent {
+			ua = strings.ToValidUTF8(ua[:maxCreationUserAgent], "")
+		}
+		c.UserAgent = &ua
+	}
+	if keyID := middleware.GetAPIKeyIDFromContext(ctx); keyID != uuid.Nil {
+		c.APIKeyID = &keyID
+	}
+	if clientID := middleware.GetClientIDFromContext(ctx); clientID != "" {
+		c.ClientID = &clientID
+	}
+	if c == (storage.CreationContext{}) {
+		return nil
+	}
+	return &c
+}
+
+// GetCreation returns how the caller's link was created. Links created
+// without a creation context have an empty one.
+func (s *LinkService) GetCreation(ctx context.Context, link *storage.Link) (*storage.CreationContext, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if link.OwnerID == nil || *link.OwnerID != ownerID {
+		return nil, ErrNotOwner
+	}
+	return s.creation(ctx, link)
+}
+
+func (s *LinkService) creation(ctx context.Context, link *storage.Link) (*storage.CreationContext, error) {
+	creation, err := s.storage.GetCreation(ctx, link.Key())
+	if err != nil {
+		return nil, err
+	}
+	if creation == nil {
+		creation = &storage.CreationContext{}
+	}
+	return creation, nil
+}
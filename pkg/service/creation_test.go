@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type creationLinks struct {
+	storage.LinkStorage
+	creations map[string]*storage.CreationContext
+}
+
+func (l *creationLinks) GetCreation(ctx context.Context, key string) (*storage.CreationContext, error) {
+	return l.creations[key], nil
+}
+
+func TestCreationContext(t *testing.T) {
+	assert.Nil(t, creationContext(context.Background()), "jobs create links without one")
+
+	ctx := middleware.WithClient(context.Background(), middleware.ClientInfo{IP: "198.51.100.7", UserAgent: strings.Repeat("é", 300)})
+	ctx = middleware.WithClientID(ctx, "dashboard")
+	c := creationContext(ctx)
+	require.NotNil(t, c)
+	assert.Equal(t, "198.51.100.7", *c.IP)
+	assert.Equal(t, strings.Repeat("é", 256), *c.UserAgent, "user agents are cut to 512 bytes of valid UTF-8")
+	assert.Equal(t, "dashboard", *c.ClientID)
+	assert.Nil(t, c.APIKeyID)
+}
+
+func TestGetCreation(t *testing.T) {
+	ip := "198.51.100.7"
+	links := &creationLinks{creations: map[string]*storage.CreationContext{"abc": {IP: &ip}}}
+	s := NewLinkService(links, nil, nil, logging.NewLogger(logging.LevelError))
+	ownerID := uuid.New()
+	owner := middleware.WithOwnerID(context.Background(), ownerID)
+
+	creation, err := s.GetCreation(owner, &storage.Link{Code: "abc", OwnerID: &ownerID})
+	require.NoError(t, err)
+	assert.Equal(t, "198.51.100.7", *creation.IP)
+
+	creation, err = s.GetCreation(owner, &storage.Link{Code: "old", OwnerID: &ownerID})
+	require.NoError(t, err)
+	assert.Equal(t, &storage.CreationContext{}, creation)
+
+	_, err = s.GetCreation(middleware.WithOwnerID(context.Background(), uuid.New()), &storage.Link{Code: "abc", OwnerID: &ownerID})
+	assert.ErrorIs(t, err, ErrNotOwner)
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"time"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/security"
+	"url-shortener/pkg/session"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxDeepLinkKeysPerOwner = 10
+	// deepLinkSecretPrefix marks secrets so that secret scanners and people
+	// can spot them
+	deepLinkSecretPrefix = "dls_"
+	// defaultDeepLinkParam is the query parameter the token is sent in
+	defaultDeepLinkParam = "dl"
+)
+
+var (
+	ErrDeepLinkKeyNotFound = errors.New("deep link key not found")
+	ErrTooManyDeepLinkKeys = fmt.Errorf("at most %d deep link keys per owner", maxDeepLinkKeysPerOwner)
+)
+
+var deepLinkParamPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// DeepLinkService mints short links whose destination carries a token
+// signed with one of the owner's keys, so the app at the destination can
+// trust the context in it, and manages those keys
+type DeepLinkService struct {
+	storage storage.DeepLinkKeyStorage
+	links   *LinkService
+	now     func() time.Time
+}
+
+func NewDeepLinkService(storage storage.DeepLinkKeyStorage, links *LinkService) *DeepLinkService {
+	return &DeepLinkService{
+		storage: storage,
+		links:   links,
+		now:     time.Now,
+	}
+}
+
+type CreateDeepLinkKeyRequest struct {
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// DeepLinkKey is the API representation of a key. Secret is only returned
+// when the key is created.
+type DeepLinkKey struct {
+	ID        uuid.UUID `json:"id"`
+	Name      string    `json:"name"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateDeepLinkRequest struct {
+	// URL is the destination, such as an app's universal link
+	URL   string    `json:"url" validate:"required,url,max=1024"`
+	KeyID uuid.UUID `json:"key_id" validate:"required"`
+	// Data is the context the app gets, such as a user or campaign ID
+	Data map[string]any `json:"data,omitempty" validate:"max=20,metadata"`
+	// Param is the query parameter the token goes in, default dl
+	Param *string `json:"param,omitempty"`
+	// ExpiresAt ends both the token and the short link
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" validate:"future"`
+	Alias      *string    `json:"alias,omitempty" validate:"omitempty,alias"`
+	Domain     *string    `json:"domain,omitempty" validate:"max=255"`
+	Tags       []string   `json:"tags,omitempty" validate:"max=20,tags"`
+	CampaignID *uuid.UUID `json:"campaign_id,omitempty"`
+}
+
+// DeepLink is a minted deep link: the short link and the destination it
+// redirects to, token included
+type DeepLink struct {
+	Code     string    `json:"code"`
+	ShortURL string    `json:"short_url"`
+	LongURL  string    `json:"long_url"`
+	Token    string    `json:"token"`
+	KeyID    uuid.UUID `json:"key_id"`
+}
+
+type VerifyDeepLinkRequest struct {
+	Token string `json:"token" validate:"required,max=4096"`
+}
+
+func (s *DeepLinkService) CreateKey(ctx context.Context, req *CreateDeepLinkKeyRequest) (*DeepLinkKey, error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	existing, err := s.storage.ListDeepLinkKeysByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxDeepLinkKeysPerOwner {
+		return nil, ErrTooManyDeepLinkKeys
+	}
+
+	token, err := session.RandomToken()
+	if err != nil {
+		return nil, err
+	}
+	key := &storage.DeepLinkKey{
+		ID:        uuid.New(),
+		OwnerID:   ownerID,
+		Name:      req.Name,
+		Secret:    deepLinkSecretPrefix + token,
+		CreatedAt: s.now(),
+	}
+	if err := s.storage.CreateDeepLinkKey(ctx, key); err != nil {
+		return nil, err
+	}
+	deepLinkKey := toDeepLinkKey(key)
+	deepLinkKey.Secret = key.Secret
+	return deepLinkKey, nil
+}
+
+func (s *DeepLinkService) ListKeys(ctx context.Context) ([]*DeepLinkKey, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	keys, err := s.storage.ListDeepLinkKeysByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	deepLinkKeys := make([]*DeepLinkKey, len(keys))
+	for i, key := range keys {
+		deepLinkKeys[i] = toDeepLinkKey(key)
+	}
+	return deepLinkKeys, nil
+}
+
+// DeleteKey revokes a key. Links already minted with it keep redirecting,
+// but their tokens no longer verify.
+func (s *DeepLinkService) DeleteKey(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.ownKey(ctx, id); err != nil {
+		return err
+	}
+	return s.storage.DeleteDeepLinkKey(ctx, id)
+}
+
+// CreateDeepLink signs req's data with the key and creates a short link to
+// the destination with the token added as a query parameter
+func (s *DeepLinkService) CreateDeepLink(ctx context.Context, req *CreateDeepLinkRequest) (*DeepLink, error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+	param := defaultDeepLinkParam
+	if req.Param != nil {
+		param = *req.Param
+	}
+	if !deepLinkParamPattern.MatchString(param) {
+		return nil, validation.Errors{{Field: "param", Rule: "param", Message: "must be 1-32 letters, digits, _ or -"}}
+	}
+	dest, err := url.Parse(req.URL)
+	if err != nil || dest.Query().Has(param) {
+		return nil, validation.Errors{{Field: "url", Rule: "param", Message: "must not have a " + param + " parameter"}}
+	}
+
+	key, err := s.ownKey(ctx, req.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	claims := &security.DeepLinkClaims{
+		KeyID:    key.ID.String(),
+		URL:      req.URL,
+		Data:     req.Data,
+		IssuedAt: s.now().Unix(),
+	}
+	if req.ExpiresAt != nil {
+		claims.ExpiresAt = req.ExpiresAt.Unix()
+	}
+	token, err := security.SignDeepLink([]byte(key.Secret), claims)
+	if err != nil {
+		return nil, err
+	}
+
+	// The token is appended rather than the query re-encoded, so the rest of
+	// the destination stays exactly as given
+	if dest.RawQuery != "" {
+		dest.RawQuery += "&"
+	}
+	dest.RawQuery += param + "=" + token
+	longURL := dest.String()
+
+	resp, err := s.links.CreateLink(ctx, &CreateLinkRequest{
+		LongURL:    longURL,
+		Alias:      req.Alias,
+		ExpiresAt:  req.ExpiresAt,
+		Domain:     req.Domain,
+		Tags:       req.Tags,
+		CampaignID: req.CampaignID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &DeepLink{
+		Code:     resp.Code,
+		ShortURL: resp.ShortURL,
+		LongURL:  longURL,
+		Token:    token,
+		KeyID:    key.ID,
+	}, nil
+}
+
+// VerifyDeepLink checks a token against the caller's keys and returns its
+// claims, for apps that would rather not verify tokens themselves
+func (s *DeepLinkService) VerifyDeepLink(ctx context.Context, req *VerifyDeepLinkRequest) (*security.DeepLinkClaims, error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	keys, err := s.storage.ListDeepLinkKeysByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	return security.VerifyDeepLink(req.Token, func(keyID string) []byte {
+		for _, key := range keys {
+			if key.ID.String() == keyID {
+				return []byte(key.Secret)
+			}
+		}
+		return nil
+	}, s.now())
+}
+
+// ownKey returns the caller's key id
+func (s *DeepLinkService) ownKey(ctx context.Context, id uuid.UUID) (*storage.DeepLinkKey, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	key, err := s.storage.GetDeepLinkKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrDeepLinkKeyNotFound
+	}
+	if key.OwnerID != ownerID {
+		return nil, ErrNotOwner
+	}
+	return key, nil
+}
+
+func toDeepLinkKey(key *storage.DeepLinkKey) *DeepLinkKey {
+	return &DeepLinkKey{
+		ID:        key.ID,
+		Name:      key.Name,
+		CreatedAt: key.CreatedAt,
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/security"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeDeepLinkKeyStorage struct {
+	storage.DeepLinkKeyStorage
+	keys map[uuid.UUID]*storage.DeepLinkKey
+}
+
+func (f *fakeDeepLinkKeyStorage) CreateDeepLinkKey(ctx context.Context, key *storage.DeepLinkKey) error {
+	f.keys[key.ID] = key
+	return nil
+}
+
+func (f *fakeDeepLinkKeyStorage) GetDeepLinkKey(ctx context.Context, id uuid.UUID) (*storage.DeepLinkKey, error) {
+	return f.keys[id], nil
+}
+
+func (f *fakeDeepLinkKeyStorage) ListDeepLinkKeysByOwner(ctx context.Context, ownerID uuid.UUID) ([]*storage.DeepLinkKey, error) {
+	var keys []*storage.DeepLinkKey
+	for _, key := range f.keys {
+		if key.OwnerID == ownerID {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (f *fakeDeepLinkKeyStorage) DeleteDeepLinkKey(ctx context.Context, id uuid.UUID) error {
+	delete(f.keys, id)
+	return nil
+}
+
+func TestDeepLinkKeys(t *testing.T) {
+	fake := &fakeDeepLinkKeyStorage{keys: make(map[uuid.UUID]*storage.DeepLinkKey)}
+	s := NewDeepLinkService(fake, nil)
+	owner := middleware.WithOwnerID(context.Background(), uuid.New())
+	other := middleware.WithOwnerID(context.Background(), uuid.New())
+
+	key, err := s.CreateKey(owner, &CreateDeepLinkKeyRequest{Name: "iOS app"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key.Secret, "dls_"))
+
+	keys, err := s.ListKeys(owner)
+	require.NoError(t, err)
+	require.Len(t, keys, 1)
+	assert.Empty(t, keys[0].Secret, "secrets are only shown on create")
+
+	assert.ErrorIs(t, s.DeleteKey(other, key.ID), ErrNotOwner)
+	assert.ErrorIs(t, s.DeleteKey(owner, uuid.New()), ErrDeepLinkKeyNotFound)
+
+	for i := 1; i < maxDeepLinkKeysPerOwner; i++ {
+		_, err := s.CreateKey(owner, &CreateDeepLinkKeyRequest{Name: "key"})
+		require.NoError(t, err)
+	}
+	_, err = s.CreateKey(owner, &CreateDeepLinkKeyRequest{Name: "key"})
+	assert.ErrorIs(t, err, ErrTooManyDeepLinkKeys)
+
+	require.NoError(t, s.DeleteKey(owner, key.ID))
+	assert.NotContains(t, fake.keys, key.ID)
+}
+
+func TestCreateDeepLinkChecksKeyAndParam(t *testing.T) {
+	fake := &fakeDeepLinkKeyStorage{keys: make(map[uuid.UUID]*storage.DeepLinkKey)}
+	s := NewDeepLinkService(fake, nil)
+	owner := middleware.WithOwnerID(context.Background(), uuid.New())
+	other := middleware.WithOwnerID(context.Background(), uuid.New())
+	key, err := s.CreateKey(owner, &CreateDeepLinkKeyRequest{Name: "app"})
+	require.NoError(t, err)
+
+	_, err = s.CreateDeepLink(other, &CreateDeepLinkRequest{URL: "https://app.example.com/open", KeyID: key.ID})
+	assert.ErrorIs(t, err, ErrNotOwner)
+
+	bad := "d l"
+	_, err = s.CreateDeepLink(owner, &CreateDeepLinkRequest{URL: "https://app.example.com/open", KeyID: key.ID, Param: &bad})
+	var verrs validation.Errors
+	require.ErrorAs(t, err, &verrs)
+	assert.Equal(t, "param", verrs[0].Field)
+
+	_, err = s.CreateDeepLink(owner, &CreateDeepLinkRequest{URL: "https://app.example.com/open?dl=x", KeyID: key.ID})
+	require.ErrorAs(t, err, &verrs)
+	assert.Equal(t, "url", verrs[0].Field)
+}
+
+func TestVerifyDeepLink(t *testing.T) {
+	fake := &fakeDeepLinkKeyStorage{keys: make(map[uuid.UUID]*storage.DeepLinkKey)}
+	s := NewDeepLinkService(fake, nil)
+	owner := middleware.WithOwnerID(context.Background(), uuid.New())
+	other := middleware.WithOwnerID(context.Background(), uuid.New())
+	key, err := s.CreateKey(owner, &CreateDeepLinkKeyRequest{Name: "app"})
+	require.NoError(t, err)
+
+	token, err := security.SignDeepLink([]byte(key.Secret), &security.DeepLinkClaims{
+		KeyID:    key.ID.String(),
+		URL:      "https://app.example.com/open",
+		Data:     map[string]any{"user": "42"},
+		IssuedAt: time.Now().Unix(),
+	})
+	require.NoError(t, err)
+
+	claims, err := s.VerifyDeepLink(owner, &VerifyDeepLinkRequest{Token: token})
+	require.NoError(t, err)
+	assert.Equal(t, "42", claims.Data["user"])
+
+	// Another owner's keys don't verify the token
+	_, err = s.VerifyDeepLink(other, &VerifyDeepLinkRequest{Token: token})
+	assert.ErrorIs(t, err, security.ErrUnknownDeepLinkKey)
+
+	require.NoError(t, s.DeleteKey(owner, key.ID))
+	_, err = s.VerifyDeepLink(owner, &VerifyDeepLinkRequest{Token: token})
+	assert.ErrorIs(t, err, security.ErrUnknownDeepLinkKey)
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+)
+
+const (
+	digestPageSize = 500
+	digestTopLinks = 5
+)
+
+// DigestService aggregates an owner's click activity over a period
+type DigestService struct {
+	storage storage.LinkStorage
+	cache   cache.LinkCacheInterface
+	links   *LinkService
+}
+
+func NewDigestService(storage storage.LinkStorage, cache cache.LinkCacheInterface, links *LinkService) *DigestService {
+	return &DigestService{
+		storage: storage,
+		cache:   cache,
+		links:   links,
+	}
+}
+
+type Digest struct {
+	Period      string        `json:"period"`
+	From        time.Time     `json:"from"`
+	To          time.Time     `json:"to"`
+	TotalClicks int64         `json:"total_clicks"`
+	NewLinks    int           `json:"new_links"`
+	TopLinks    []*DigestLink `json:"top_links"`
+	Daily       []*DayClicks  `json:"daily"`
+}
+
+type DigestLink struct {
+	Code     string `json:"code"`
+	ShortURL string `json:"short_url"`
+	LongURL  string `json:"long_url"`
+	Clicks   int64  `json:"clicks"`
+}
+
+type DayClicks struct {
+	Date   string `json:"date"`
+	Clicks int64  `json:"clicks"`
+}
+
+// PeriodDays returns the length of a digest period, or 0 if unknown
+func PeriodDays(period string) int {
+	switch period {
+	case "week":
+		return 7
+	case "month":
+		return 30
+	}
+	return 0
+}
+
+// GetDigest previews the caller's digest for the completed days of period
+func (s *DigestService) GetDigest(ctx context.Context, period string) (*Digest, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+	now := time.Now()
+	key := "digest:" + ownerID.String() + ":" + period + ":" + now.UTC().Format("2006-01-02")
+	return cachedStats(ctx, s.links.stats, key, func(ctx context.Context) (*Digest, error) {
+		return s.BuildDigest(ctx, ownerID, period, now)
+	})
+}
+
+// BuildDigest covers the whole UTC days of period that ended before now, or
+// as many of them as the owner's plan keeps click history for
+func (s *DigestService) BuildDigest(ctx context.Context, ownerID uuid.UUID, period string, now time.Time) (*Digest, error) {
+	days := PeriodDays(period)
+	if days == 0 {
+		return nil, fmt.Errorf("unknown period %q", period)
+	}
+	days, err := s.links.AnalyticsDays(ctx, ownerID, days)
+	if err != nil {
+		return nil, err
+	}
+	to := now.UTC().Truncate(24 * time.Hour)
+	from := to.AddDate(0, 0, -days)
+
+	var links []*storage.Link
+	for offset := 0; ; offset += digestPageSize {
+		page, err := s.storage.ListByOwner(ctx, ownerID, storage.LinkQuery{}, digestPageSize, offset)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, page...)
+		if len(page) < digestPageSize {
+			break
+		}
+	}
+
+	digest := &Digest{
+		Period:   period,
+		From:     from,
+		To:       to,
+		TopLinks: []*DigestLink{},
+		Daily:    make([]*DayClicks, 0, days),
+	}
+	codes := make([]string, len(links))
+	for i, link := range links {
+		codes[i] = link.Key()
+		if !link.CreatedAt.Before(from) && link.CreatedAt.Before(to) {
+			digest.NewLinks++
+		}
+	}
+
+	perLink := make(map[string]int64)
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		counts, err := s.cache.GetDailyClicks(ctx, codes, day)
+		if err != nil {
+			return nil, err
+		}
+		var total int64
+		for code, n := range counts {
+			perLink[code] += n
+			total += n
+		}
+		digest.TotalClicks += total
+		digest.Daily = append(digest.Daily, &DayClicks{Date: day.Format("2006-01-02"), Clicks: total})
+	}
+
+	for _, link := range links {
+		if clicks := perLink[link.Key()]; clicks > 0 {
+			digest.TopLinks = append(digest.TopLinks, &DigestLink{
+				Code:     link.Code,
+				ShortURL: s.links.LinkShortURL(link),
+				LongURL:  DisplayURL(link.LongURL),
+				Clicks:   clicks,
+			})
+		}
+	}
+	sort.SliceStable(digest.TopLinks, func(i, j int) bool {
+		return digest.TopLinks[i].Clicks > digest.TopLinks[j].Clicks
+	})
+	if len(digest.TopLinks) > digestTopLinks {
+		digest.TopLinks = digest.TopLinks[:digestTopLinks]
+	}
+	return digest, nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Only the methods BuildDigest uses are implemented; the embedded
+// interfaces panic on anything else
+type fakeDigestLinks struct {
+	storage.LinkStorage
+	links []*storage.Link
+}
+
+func (f *fakeDigestLinks) ListByOwner(ctx context.Context, ownerID uuid.UUID, query storage.LinkQuery, limit, offset int) ([]*storage.Link, error) {
+	if offset >= len(f.links) {
+		return nil, nil
+	}
+	return f.links[offset:min(offset+limit, len(f.links))], nil
+}
+
+type fakeDailyClicks struct {
+	cache.LinkCacheInterface
+	days map[string]map[string]int64
+}
+
+func (f *fakeDailyClicks) GetDailyClicks(ctx context.Context, codes []string, day time.Time) (map[string]int64, error) {
+	counts := map[string]int64{}
+	for _, code := range codes {
+		if n, ok := f.days[day.Format("2006-01-02")][code]; ok {
+			counts[code] = n
+		}
+	}
+	return counts, nil
+}
+
+func TestBuildDigest(t *testing.T) {
+	now := time.Date(2026, 3, 11, 15, 0, 0, 0, time.UTC)
+	links := &fakeDigestLinks{links: []*storage.Link{
+		{Code: "old", LongURL: "https://example.com/old", CreatedAt: now.AddDate(0, -2, 0)},
+		{Code: "new", LongURL: "https://example.com/new", CreatedAt: now.AddDate(0, 0, -2)},
+		{Code: "today", LongURL: "https://example.com/today", CreatedAt: now},
+	}}
+	clicks := &fakeDailyClicks{days: map[string]map[string]int64{
+		"2026-03-03": {"old": 100}, // before the period
+		"2026-03-04": {"old": 2},
+		"2026-03-09": {"old": 1, "new": 5},
+		"2026-03-11": {"today": 50}, // today isn't complete yet
+	}}
+	svc := NewDigestService(links, clicks, &LinkService{})
+
+	d, err := svc.BuildDigest(context.Background(), uuid.New(), "week", now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC), d.From)
+	assert.Equal(t, time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC), d.To)
+	assert.Equal(t, int64(8), d.TotalClicks)
+	assert.Equal(t, 1, d.NewLinks)
+	require.Len(t, d.Daily, 7)
+	assert.Equal(t, "2026-03-04", d.Daily[0].Date)
+	assert.Equal(t, int64(6), d.Daily[5].Clicks)
+	require.Len(t, d.TopLinks, 2)
+	assert.Equal(t, "new", d.TopLinks[0].Code)
+	assert.Equal(t, int64(5), d.TopLinks[0].Clicks)
+	assert.Equal(t, int64(3), d.TopLinks[1].Clicks)
+
+	_, err = svc.BuildDigest(context.Background(), uuid.New(), "year", now)
+	assert.Error(t, err)
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"strings"
+
+	"url-shortener/pkg/storage"
+)
+
+// Codes and aliases are unique per short domain, so a code only names a link
+// together with the domain it was requested on. The HTTP layer puts that
+// domain in the request context; lookups by code made with a context without
+// one are on the default domain.
+
+type domainContextKey struct{}
+
+// WithDomain scopes code lookups made with ctx to a short domain, "" being
+// the default domain
+func WithDomain(ctx context.Context, domain string) context.Context {
+	return context.WithValue(ctx, domainContextKey{}, strings.ToLower(domain))
+}
+
+// DomainFromContext returns the short domain set by WithDomain, or ""
+func DomainFromContext(ctx context.Context) string {
+	domain, _ := ctx.Value(domainContextKey{}).(string)
+	return domain
+}
+
+// ShortDomainFor returns the configured short domain that host, with or
+// without a port, names, or "" if it is not one
+func (s *Resolver) ShortDomainFor(host string) string {
+	if i := strings.LastIndexByte(host, ':'); i >= 0 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	host = strings.ToLower(host)
+	if s.ValidateDomain(host) != nil {
+		return ""
+	}
+	return host
+}
+
+// linkKey is the storage and cache key for code on ctx's domain
+func linkKey(ctx context.Context, code string) string {
+	return storage.LinkKey(DomainFromContext(ctx), NormalizeAlias(code))
+}
+
+// domainOf is the stored form of a link's domain
+func domainOf(domain *string) string {
+	if domain == nil {
+		return ""
+	}
+	return *domain
+}
@@ -2,28 +2,50 @@ package service
 
 import (
 	"context"
+	"reflect"
 	"regexp"
 	"strings"
+	"sync"
+
+	"url-shortener/pkg/validation"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
-var reservedAliases = map[string]bool{
-	"api":   true,
-	"admin": true,
-	"r":     true,
-	"v1":    true,
-}
+var (
+	reservedMu sync.RWMutex
+	// reservedAliases can't be used as aliases. Besides "api", kept free for
+	// a future prefix, they are the servers' top-level route prefixes, which
+	// ReserveAliases adds at startup.
+	reservedAliases = map[string]bool{"api": true}
+)
 
 var aliasRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,50}$`)
 
-func GenerateCode(ctx context.Context, pool *pgxpool.Pool) (string, error) {
+var tagRegex = regexp.MustCompile(`^[a-zA-Z0-9_.:-]{1,50}$`)
+
+func init() {
+	validation.Register("alias", func(v reflect.Value, _ string) (bool, string) {
+		return ValidateAlias(v.String()), "must be 1-50 letters, digits, '-' or '_' and not reserved"
+	})
+	validation.Register("tags", func(v reflect.Value, _ string) (bool, string) {
+		for i := 0; i < v.Len(); i++ {
+			if !tagRegex.MatchString(strings.TrimSpace(v.Index(i).String())) {
+				return false, "tags must be 1-50 letters, digits, '.', ':', '-' or '_'"
+			}
+		}
+		return true, ""
+	})
+}
+
+// GenerateCode returns the next code of region's share of the code space
+func GenerateCode(ctx context.Context, pool *pgxpool.Pool, region Region) (string, error) {
 	var id int64
 	err := pool.QueryRow(ctx, "SELECT nextval('link_code_seq')").Scan(&id)
 	if err != nil {
 		return "", err
 	}
-	return toBase62(id), nil
+	return toBase62(region.codeID(id)), nil
 }
 
 func toBase62(n int64) string {
@@ -47,12 +69,27 @@ func reverse(s string) string {
 	return string(runes)
 }
 
+// ReserveAliases stops names from being used as aliases, case-insensitively
+func ReserveAliases(names ...string) {
+	reservedMu.Lock()
+	defer reservedMu.Unlock()
+	for _, name := range names {
+		reservedAliases[strings.ToLower(name)] = true
+	}
+}
+
 func ValidateAlias(alias string) bool {
 	if alias == "" {
 		return true
 	}
-	if reservedAliases[strings.ToLower(alias)] {
+	reservedMu.RLock()
+	reserved := reservedAliases[strings.ToLower(alias)]
+	reservedMu.RUnlock()
+	if reserved {
 		return false
 	}
-	return aliasRegex.MatchString(alias)
+	if aliasRegex.MatchString(alias) {
+		return true
+	}
+	return unicodeAliases.Load() && validUnicodeAlias(alias)
 }
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -28,6 +29,46 @@ func TestValidateAlias(t *testing.T) {
 	}
 }
 
+func TestReserveAliases(t *testing.T) {
+	assert.True(t, ValidateAlias("healthz"))
+	ReserveAliases("healthz")
+	assert.False(t, ValidateAlias("healthz"))
+	assert.False(t, ValidateAlias("HealthZ"))
+}
+
+func TestValidateUnicodeAlias(t *testing.T) {
+	assert.False(t, ValidateAlias("café"))
+	AllowUnicodeAliases(true)
+	t.Cleanup(func() { AllowUnicodeAliases(false) })
+
+	tests := []struct {
+		alias    string
+		expected bool
+	}{
+		{"café", true},
+		{"cafe\u0301", false}, // not NFC
+		{"日本語リンク", true},
+		{"한국어", true},
+		{"ラーメン", true},
+		{"🚀-launch", true},
+		{"👍🏽", true},
+		{"привет", true},
+		{"pаypal", false}, // Cyrillic а among Latin letters
+		{"рае", false},    // all Cyrillic, but reads as Latin "pae"
+		{"\u0301a", false},
+		{"a b", false},
+		{"link/two", false},
+		{strings.Repeat("é", 50), true},
+		{strings.Repeat("é", 51), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alias, func(t *testing.T) {
+			assert.Equal(t, tt.expected, ValidateAlias(tt.alias))
+		})
+	}
+	assert.Equal(t, "café", NormalizeAlias("cafe\u0301"))
+}
+
 func TestToBase62(t *testing.T) {
 	tests := []struct {
 		n        int64
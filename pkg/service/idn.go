@@ -0,0 +1,106 @@
+package service
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"url-shortener/pkg/validation"
+
+	"golang.org/x/net/idna"
+)
+
+// Destinations on internationalized domains are stored with their host in
+// punycode, the name browsers actually look up, so the private address and
+// blocked domain checks see the real host: "ⓛocalhost" and
+// "ｅｘａｍｐｌｅ．com" are localhost and example.com once mapped. People are
+// shown the Unicode form again, except for hosts mixing scripts, which stay
+// in punycode as browsers keep them.
+
+// errInvalidIDN rejects a long_url whose host isn't a valid domain name
+var errInvalidIDN = validation.Errors{{Field: "long_url", Rule: "url", Message: "must have a valid internationalized domain name"}}
+
+// ASCIIURL returns rawURL with a non-ASCII host converted to punycode. Other
+// URLs, including ones that don't parse, are returned unchanged for the URL
+// checks to report.
+func ASCIIURL(rawURL string) (string, error) {
+	before, host, after, ok := splitHost(rawURL)
+	if !ok || isASCII(host) {
+		return rawURL, nil
+	}
+	ascii, err := idna.Lookup.ToASCII(host)
+	if err != nil {
+		return "", err
+	}
+	return before + ascii + after, nil
+}
+
+// DisplayURL returns rawURL with a punycode host shown in Unicode, for
+// people to read
+func DisplayURL(rawURL string) string {
+	before, host, after, ok := splitHost(rawURL)
+	if !ok || !strings.Contains(strings.ToLower(host), "xn--") {
+		return rawURL
+	}
+	display, err := idna.Display.ToUnicode(host)
+	if err != nil {
+		return rawURL
+	}
+	for _, label := range strings.Split(display, ".") {
+		if mixesScripts(label) {
+			return rawURL
+		}
+	}
+	return before + display + after
+}
+
+// splitHost splits an absolute URL around the host name of its authority,
+// leaving the scheme, userinfo and port in before and after
+func splitHost(rawURL string) (before, host, after string, ok bool) {
+	scheme, rest, found := strings.Cut(rawURL, "://")
+	if !found || scheme == "" {
+		return "", "", "", false
+	}
+	end := strings.IndexAny(rest, "/?#")
+	if end < 0 {
+		end = len(rest)
+	}
+	start := strings.LastIndex(rest[:end], "@") + 1
+	hostEnd := end
+	if i := strings.LastIndex(rest[start:end], ":"); i >= 0 {
+		hostEnd = start + i
+	}
+	offset := len(scheme) + len("://")
+	host = rest[start:hostEnd]
+	if host == "" || strings.HasPrefix(host, "[") {
+		return "", "", "", false
+	}
+	return rawURL[:offset+start], host, rawURL[offset+hostEnd:], true
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// mixesScripts reports whether s has letters of more than one script, as
+// scriptOf groups them
+func mixesScripts(s string) bool {
+	script := ""
+	for _, r := range s {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		if sc := scriptOf(r); sc != "" {
+			if script != "" && sc != script {
+				return true
+			}
+			script = sc
+		}
+	}
+	return false
+}
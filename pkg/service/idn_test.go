@@ -0,0 +1,45 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestASCIIURL(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"https://bücher.example/path?q=ü", "https://xn--bcher-kva.example/path?q=ü"},
+		{"https://user@bücher.example:8443/", "https://user@xn--bcher-kva.example:8443/"},
+		{"http://ⓛocalhost/", "http://localhost/"},
+		{"https://ｅｘａｍｐｌｅ．com", "https://example.com"},
+		{"https://example.com/{id}", "https://example.com/{id}"},
+		{"not a url", "not a url"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			out, err := ASCIIURL(tt.in)
+			require.NoError(t, err)
+			assert.Equal(t, tt.out, out)
+		})
+	}
+
+	_, err := ASCIIURL("https://bücher_shop.example/")
+	assert.Error(t, err)
+}
+
+func TestDisplayURL(t *testing.T) {
+	assert.Equal(t, "https://bücher.example:8443/a", DisplayURL("https://xn--bcher-kva.example:8443/a"))
+	assert.Equal(t, "https://example.com/", DisplayURL("https://example.com/"))
+	// "pаypal" with a Cyrillic а stays in punycode
+	assert.Equal(t, "https://xn--pypal-4ve.com/", DisplayURL("https://xn--pypal-4ve.com/"))
+}
+
+func TestValidateDestinationChecksIDNHost(t *testing.T) {
+	s := &LinkService{}
+	s.ApplySettings(DefaultSettings())
+	assert.Error(t, s.ValidateDestination("http://ⓛocalhost/"))
+	assert.NoError(t, s.ValidateDestination("https://bücher.example/"))
+}
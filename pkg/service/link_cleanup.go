@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"url-shortener/pkg/jobs"
+	"url-shortener/pkg/storage"
+)
+
+// Deleting a link removes what belongs to it. In Postgres its tags,
+// expiry reminders and destination health rows are deleted with it by
+// ON DELETE CASCADE, and campaigns and fraud alerts are left alone: links
+// only belong to campaigns, and alerts are kept as a record. Redis keeps the
+// link's cached entry, pending click delta, daily counts and leaderboard
+// entries, which DeleteLink removes once the row is gone. Whatever that
+// misses, because Redis was unavailable or a queued click landed after it,
+// is removed by the orphan sweep.
+
+// SweepOrphansKind is the job that runs SweepOrphans daily
+const SweepOrphansKind = "links.sweep_orphans"
+
+// orphanBatch is how many keys the sweep looks up in Postgres at once
+const orphanBatch = 500
+
+var sweepPolicy = jobs.Policy{
+	MaxAttempts: 3,
+	Backoff:     jobs.Exponential(time.Minute, time.Hour),
+	Timeout:     5 * time.Minute,
+}
+
+// forgetLink drops the Redis click state of link, which has been deleted.
+// A failure is left to the orphan sweep.
+func (s *LinkService) forgetLink(ctx context.Context, link *storage.Link) {
+	if err := s.cache.ForgetLink(ctx, link.Key(), link.OwnerID, time.Now()); err != nil {
+		s.logger.Warn(ctx, "failed to remove click state of deleted link", "code", link.Key(), "error", err)
+	}
+}
+
+// UseOrphanSweeper runs SweepOrphans daily from a job on q
+func (s *LinkService) UseOrphanSweeper(q *jobs.Queue) {
+	q.Register(SweepOrphansKind, sweepPolicy, s.sweepOrphans)
+	q.Every(SweepOrphansKind, 24*time.Hour)
+}
+
+func (s *LinkService) sweepOrphans(ctx context.Context, _ json.RawMessage) error {
+	n, err := s.SweepOrphans(ctx)
+	if n > 0 {
+		s.logger.Info(ctx, "removed click state of deleted links", "links", n)
+	}
+	return err
+}
+
+// SweepOrphans removes the Redis click state of links that no longer exist
+// and returns how many links it was kept for
+func (s *LinkService) SweepOrphans(ctx context.Context) (int, error) {
+	now := time.Now()
+	keys, err := s.cache.ClickedLinks(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	var orphans []string
+	for start := 0; start < len(keys); start += orphanBatch {
+		batch := keys[start:min(start+orphanBatch, len(keys))]
+		links, err := s.storage.GetByCodes(ctx, batch)
+		if err != nil {
+			return 0, err
+		}
+		exists := make(map[string]bool, len(links))
+		for _, link := range links {
+			exists[link.Key()] = true
+		}
+		for _, key := range batch {
+			if !exists[key] {
+				orphans = append(orphans, key)
+			}
+		}
+	}
+	if err := s.cache.ForgetLinks(ctx, orphans, now); err != nil {
+		return 0, err
+	}
+	return len(orphans), nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sweptLinks struct {
+	storage.LinkStorage
+	keys []string
+}
+
+func (l *sweptLinks) GetByCodes(ctx context.Context, keys []string) ([]*storage.Link, error) {
+	var links []*storage.Link
+	for _, key := range keys {
+		for _, existing := range l.keys {
+			if key == existing {
+				domain, code := storage.SplitLinkKey(key)
+				link := &storage.Link{Code: code}
+				if domain != "" {
+					link.Domain = &domain
+				}
+				links = append(links, link)
+			}
+		}
+	}
+	return links, nil
+}
+
+type sweptCache struct {
+	cache.LinkCacheInterface
+	clicked   []string
+	forgotten []string
+}
+
+func (c *sweptCache) ClickedLinks(ctx context.Context, now time.Time) ([]string, error) {
+	return c.clicked, nil
+}
+
+func (c *sweptCache) ForgetLinks(ctx context.Context, codes []string, now time.Time) error {
+	c.forgotten = append(c.forgotten, codes...)
+	return nil
+}
+
+func TestSweepOrphans(t *testing.T) {
+	links := &sweptLinks{keys: []string{"abc", "go.example/abc"}}
+	clicks := &sweptCache{clicked: []string{"abc", "gone", "go.example/abc", "go.example/gone"}}
+	s := NewLinkService(links, clicks, nil, logging.NewLogger(logging.LevelError))
+
+	n, err := s.SweepOrphans(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"gone", "go.example/gone"}, clicks.forgotten)
+}
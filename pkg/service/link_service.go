@@ -2,99 +2,363 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
+	"net/http"
+	"net/netip"
 	"net/url"
+	"slices"
 	"strings"
 	"time"
 
 	"url-shortener/pkg/cache"
 	"url-shortener/pkg/logging"
 	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/security"
 	"url-shortener/pkg/storage"
+	"url-shortener/pkg/tenant"
+	"url-shortener/pkg/validation"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
-	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/net/idna"
 )
 
+var (
+	ErrLinkNotFound = errors.New("link not found")
+	ErrLinkExpired  = errors.New("link expired")
+	// ErrLinkDisabled is returned for links of a suspended account
+	ErrLinkDisabled     = errors.New("link disabled")
+	ErrPasswordRequired = errors.New("password required")
+	// ErrRequesterDenied is returned for a link whose IP rules exclude the
+	// requester
+	ErrRequesterDenied = errors.New("requester not allowed")
+	ErrWrongPassword   = errors.New("wrong password")
+	ErrCodeExists      = errors.New("code already exists")
+	ErrNotOwner        = errors.New("access denied: not the owner of this link")
+	// ErrAccountSuspended is returned when a suspended owner creates a link
+	ErrAccountSuspended = errors.New("account suspended")
+)
+
+// uniqueViolation is the Postgres error code for a duplicate key
+const uniqueViolation = "23505"
+
+// LinkService manages links on top of the Resolver that serves them
 type LinkService struct {
-	storage storage.LinkStorage
-	cache   cache.LinkCacheInterface
-	pool    *pgxpool.Pool
-	logger  *logging.Logger
+	Resolver
+	pool        *pgxpool.Pool
+	preferences storage.PreferencesStorage
+	outbox      storage.OutboxStorage
+	campaigns   storage.CampaignStorage
+	health      storage.HealthStorage
+	users       storage.UserStorage
+	terms       *TermsService
+	// reservations keeps aliases other owners reserved from being used
+	reservations storage.AliasReservationStorage
+	// quarantine is how long after their link is deleted codes may be
+	// reused, 0 being never; see code_recycling.go
+	quarantine time.Duration
+	// region and replicas are set in multi-region deployments; see
+	// regions.go
+	region   Region
+	replicas storage.ReplicaStorage
+}
+
+// Settings are the service knobs that can be changed without a restart
+type Settings struct {
+	ShortURLBase     string
+	LinkCacheTTL     time.Duration
+	NegativeCacheTTL time.Duration
+	BlockedDomains   []string
+	// ShortDomains are the custom domains links may be created on
+	ShortDomains []string
+	// Global click counting exclusions; see CountsClick
+	ClickExcludeCIDRs      []netip.Prefix
+	ClickExcludeUserAgents []string
+	ClickPreviewParam      string
+	// ClickDedupWindow counts one click per visitor and link per window;
+	// 0 counts every visit
+	ClickDedupWindow time.Duration
+	// Other link shorteners and how many a destination may chain through;
+	// see checkChain
+	ShortenerDomains     []string
+	ShortenerChainDepth  int
+	ShortenerChainAction string
+	ShortenerExpand      bool
+}
+
+func DefaultSettings() Settings {
+	return Settings{
+		ShortURLBase:         "http://localhost:8081/r/",
+		LinkCacheTTL:         24 * time.Hour,
+		NegativeCacheTTL:     5 * time.Minute,
+		ClickPreviewParam:    "preview",
+		ShortenerChainDepth:  1,
+		ShortenerChainAction: "reject",
+	}
 }
 
 func NewLinkService(storage storage.LinkStorage, cache cache.LinkCacheInterface, pool *pgxpool.Pool, logger *logging.Logger) *LinkService {
-	return &LinkService{
-		storage: storage,
-		cache:   cache,
-		pool:    pool,
-		logger:  logger,
+	s := &LinkService{
+		Resolver: Resolver{storage: storage, cache: cache, logger: logger},
+		pool:     pool,
 	}
+	s.passwords, _ = security.NewPasswordHasher(security.Argon2id)
+	s.ApplySettings(DefaultSettings())
+	return s
 }
 
-type CreateLinkRequest struct {
-	LongURL   string     `json:"long_url"`
-	Alias     *string    `json:"alias,omitempty"`
-	Password  *string    `json:"password,omitempty"`
-	ExpiresAt *time.Time `json:"expires_at,omitempty"`
-	MaxClicks *int       `json:"max_clicks,omitempty"`
+// UsePreferences makes CreateLink fill omitted fields from the owner's saved
+// preferences
+func (s *LinkService) UsePreferences(preferences storage.PreferencesStorage) {
+	s.preferences = preferences
 }
 
-type CreateLinkResponse struct {
-	Code     string                 `json:"code"`
-	ShortURL string                 `json:"short_url"`
-	Metadata map[string]interface{} `json:"metadata,omitempty"`
+// UseOutbox records link.created, link.updated and link.deleted events in
+// the outbox, in the same transaction as the change
+func (s *LinkService) UseOutbox(outbox storage.OutboxStorage) {
+	s.outbox = outbox
 }
 
-func (s *LinkService) CreateLink(ctx context.Context, req *CreateLinkRequest) (*CreateLinkResponse, error) {
-	// Validate URL
-	parsedURL, err := url.ParseRequestURI(req.LongURL)
+// UseCampaigns lets links join the caller's campaigns via campaign_id
+func (s *LinkService) UseCampaigns(campaigns storage.CampaignStorage) {
+	s.campaigns = campaigns
+}
+
+// UseHealth makes ListLinks include the last liveness check of each link
+func (s *LinkService) UseHealth(health storage.HealthStorage) {
+	s.health = health
+}
+
+// UseSuspensions stops suspended owners from creating links
+func (s *LinkService) UseSuspensions(users storage.UserStorage) {
+	s.users = users
+}
+
+// UseTerms stops owners who haven't accepted the current terms of service
+// from creating links
+func (s *LinkService) UseTerms(terms *TermsService) {
+	s.terms = terms
+}
+
+// UseAliasReservations stops owners from creating links with aliases that
+// other owners reserved
+func (s *LinkService) UseAliasReservations(reservations storage.AliasReservationStorage) {
+	s.reservations = reservations
+}
+
+// UsePlans enforces the limits of owners' plans
+func (s *LinkService) UsePlans(plans *PlanService) {
+	s.plans = plans
+}
+
+// TenantOf returns the tenant that owns a short domain, "" being the default
+// domain and the default tenant
+func (s *Resolver) TenantOf(domain string) string {
+	if s.tenants == nil {
+		return ""
+	}
+	return s.tenants.ForDomain(domain)
+}
+
+// checkTenantDomain rejects short domains, "" being the default domain, that
+// ctx's tenant doesn't own, as if they weren't configured
+func (s *LinkService) checkTenantDomain(ctx context.Context, domain string) error {
+	if s.tenants != nil && s.TenantOf(domain) != tenant.FromContext(ctx) {
+		return fmt.Errorf("domain %q is not available", domain)
+	}
+	return nil
+}
+
+// ShortURL returns the public short URL for code on the default domain
+func (s *Resolver) ShortURL(code string) string {
+	return s.currentSettings().ShortURLBase + url.PathEscape(code)
+}
+
+// LinkShortURL returns the public short URL for link, honouring its domain
+func (s *Resolver) LinkShortURL(link *storage.Link) string {
+	return s.shortURLFor(link.Code, link.Domain)
+}
+
+func (s *Resolver) shortURLFor(code string, domain *string) string {
+	if domain == nil {
+		return s.ShortURL(code)
+	}
+	return "https://" + asciiHost(*domain) + "/r/" + url.PathEscape(code)
+}
+
+// asciiHost renders a short domain in URLs. Unicode is rendered as punycode
+// and percent-escapes, which can't be mistaken for other text wherever the
+// URL is pasted.
+func asciiHost(domain string) string {
+	host, err := idna.Lookup.ToASCII(domain)
 	if err != nil {
-		return nil, errors.New("invalid URL")
+		return domain
 	}
+	return host
+}
 
-	// Log URL validation (safe to log scheme, not full URL)
-	s.logger.LogURLValidation(ctx, true, parsedURL.Scheme)
+// ValidateDomain checks that domain is one of the configured short domains
+func (s *Resolver) ValidateDomain(domain string) error {
+	domain = strings.ToLower(domain)
+	for _, d := range s.currentSettings().ShortDomains {
+		if d == domain {
+			return nil
+		}
+	}
+	return fmt.Errorf("domain %q is not available", domain)
+}
+
+// isBlockedHost reports whether host is a blocked domain or a subdomain of one
+func (s *LinkService) isBlockedHost(host string) bool {
+	return hostIn(host, s.currentSettings().BlockedDomains)
+}
+
+// hostIn reports whether host is one of domains or a subdomain of one
+func hostIn(host string, domains []string) bool {
+	host = strings.ToLower(host)
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
 
-	// SSRF prevention: Whitelist schemes
+// ValidateDestination applies the destination rules used for links (scheme,
+// private addresses, blocked domains) to rawURL
+func (s *LinkService) ValidateDestination(rawURL string) error {
+	rawURL, err := ASCIIURL(rawURL)
+	if err != nil {
+		return errors.New("invalid URL: bad internationalized domain name")
+	}
+	parsedURL, err := url.ParseRequestURI(rawURL)
+	if err != nil || parsedURL.Host == "" {
+		return errors.New("invalid URL")
+	}
 	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
-		return nil, errors.New("invalid URL scheme: only http and https allowed")
+		return errors.New("invalid URL: disallowed protocol or scheme")
 	}
+	return s.checkDestination(parsedURL, rawURL)
+}
 
+func (s *LinkService) checkDestination(parsedURL *url.URL, rawURL string) error {
 	// Block private/reserved IPs and localhost
-	host := strings.Split(parsedURL.Host, ":")[0] // Remove port
+	host := parsedURL.Hostname()
 	if ip := net.ParseIP(host); ip != nil {
 		// Check private ranges
 		if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
-			return nil, errors.New("invalid URL: private, loopback, or link-local addresses not allowed")
+			return errors.New("invalid URL: private, loopback, or link-local addresses not allowed")
 		}
 		// Block multicast, etc.
 		if ip.IsMulticast() || ip.IsUnspecified() {
-			return nil, errors.New("invalid URL: multicast or unspecified address")
+			return errors.New("invalid URL: multicast or unspecified address")
 		}
 	} else {
 		// For hostnames, block common locals
 		hostLower := strings.ToLower(host)
 		if strings.Contains(hostLower, "localhost") || strings.Contains(hostLower, "127.0.0.1") || strings.Contains(hostLower, "0.0.0.0") {
-			return nil, errors.New("invalid URL: localhost or zero address not allowed")
+			return errors.New("invalid URL: localhost or zero address not allowed")
 		}
 	}
 
+	if s.isBlockedHost(host) {
+		return errors.New("invalid URL: destination domain is blocked")
+	}
+
 	// Additional path checks (e.g., no file:// or javascript:)
-	if strings.HasPrefix(req.LongURL, "file://") || strings.Contains(req.LongURL, "javascript:") {
-		return nil, errors.New("invalid URL: disallowed protocol or scheme")
+	if strings.HasPrefix(rawURL, "file://") || strings.Contains(rawURL, "javascript:") {
+		return errors.New("invalid URL: disallowed protocol or scheme")
 	}
 
-	// Validate alias
-	if req.Alias != nil && !ValidateAlias(*req.Alias) {
-		return nil, errors.New("invalid alias")
+	return nil
+}
+
+type CreateLinkRequest struct {
+	LongURL   string     `json:"long_url" validate:"required,url,max=2048"`
+	Alias     *string    `json:"alias,omitempty" validate:"omitempty,alias"`
+	Password  *string    `json:"password,omitempty" validate:"min=1,max=72"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" validate:"future"`
+	MaxClicks *int       `json:"max_clicks,omitempty" validate:"min=1"`
+	Tags      []string   `json:"tags,omitempty" validate:"max=20,tags"`
+	// RedirectType is the HTTP status used by /r/{code} (default 302)
+	RedirectType *int    `json:"redirect_type,omitempty" validate:"oneof=301 302 307 308"`
+	Domain       *string `json:"domain,omitempty" validate:"max=255"`
+	// PublicStats publishes click stats at /r/{code}/stats
+	PublicStats bool `json:"public_stats,omitempty"`
+	// NoIndex keeps search engines from indexing the short URL, and keeps the
+	// link off public pages such as /r/{code}/stats
+	NoIndex bool `json:"noindex,omitempty"`
+	// Visits from these CIDRs or with these user agent substrings aren't
+	// counted as clicks
+	ExcludeCIDRs      []string `json:"exclude_cidrs,omitempty" validate:"max=20,cidrs"`
+	ExcludeUserAgents []string `json:"exclude_user_agents,omitempty" validate:"max=20,substrings"`
+	// Only requesters in AllowCIDRs (when given) and not in DenyCIDRs are
+	// redirected; others get 403
+	AllowCIDRs []string `json:"allow_cidrs,omitempty" validate:"max=50,cidrs"`
+	DenyCIDRs  []string `json:"deny_cidrs,omitempty" validate:"max=50,cidrs"`
+	// CampaignID adds the link to one of the caller's campaigns
+	CampaignID  *uuid.UUID     `json:"campaign_id,omitempty"`
+	Description *string        `json:"description,omitempty" validate:"max=2000"`
+	Metadata    map[string]any `json:"metadata,omitempty" validate:"max=50,metadata"`
+	// ParamRules maps {placeholders} in LongURL to the rule their values
+	// must match; see params.go
+	ParamRules map[string]string `json:"param_rules,omitempty"`
+	// RateLimit caps redirects per RateLimitWindow (default hour); visitors
+	// over it get a "try later" page
+	RateLimit       *int    `json:"rate_limit,omitempty" validate:"min=1"`
+	RateLimitWindow *string `json:"rate_limit_window,omitempty" validate:"oneof=minute hour day"`
+}
+
+type CreateLinkResponse struct {
+	Code     string `json:"code"`
+	ShortURL string `json:"short_url"`
+	// Domain is the short domain the link was created on, "" for the
+	// default one
+	Domain   string                 `json:"domain,omitempty"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+func (s *LinkService) CreateLink(ctx context.Context, req *CreateLinkRequest) (*CreateLinkResponse, error) {
+	// Aliases are stored in NFC, see unicode_aliases.go
+	if req.Alias != nil {
+		alias := NormalizeAlias(*req.Alias)
+		req.Alias = &alias
+	}
+	longURL, err := ASCIIURL(req.LongURL)
+	if err != nil {
+		return nil, errInvalidIDN
+	}
+	req.LongURL = longURL
+	// Validate request fields (URL format and scheme, alias, limits)
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+	parsedURL, err := url.ParseRequestURI(req.LongURL)
+	if err != nil {
+		return nil, errors.New("invalid URL")
+	}
+
+	// Log URL validation (safe to log scheme, not full URL)
+	s.logger.LogURLValidation(ctx, true, parsedURL.Scheme)
+
+	if err := s.checkDestination(parsedURL, req.LongURL); err != nil {
+		return nil, err
+	}
+	if err := checkParams(req.LongURL, req.ParamRules); err != nil {
+		return nil, err
+	}
+	chainFlag, err := s.checkChain(ctx, parsedURL)
+	if err != nil {
+		return nil, err
 	}
 
 	// Generate code
-	code, err := GenerateCode(ctx, s.pool)
+	code, err := GenerateCode(ctx, s.pool, s.region)
 	if err != nil {
 		return nil, err
 	}
@@ -110,18 +374,66 @@ func (s *LinkService) CreateLink(ctx context.Context, req *CreateLinkRequest) (*
 		return nil, errors.New("owner_id not found in context")
 	}
 
+	if s.users != nil {
+		suspension, err := s.users.GetSuspension(ctx, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		if suspension != nil {
+			return nil, ErrAccountSuspended
+		}
+	}
+
+	if s.terms != nil {
+		if err := s.terms.checkAccepted(ctx, ownerID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := s.applyPreferences(ctx, ownerID, req); err != nil {
+		return nil, err
+	}
+	if req.CampaignID != nil {
+		if err := s.checkCampaign(ctx, ownerID, *req.CampaignID); err != nil {
+			return nil, err
+		}
+	}
+	tenantID := tenant.FromContext(ctx)
+	if req.Domain == nil && s.tenants != nil && tenantID != "" {
+		domain := s.tenants.DefaultDomain(tenantID)
+		req.Domain = &domain
+	}
+	if req.Domain != nil {
+		if err := s.ValidateDomain(*req.Domain); err != nil {
+			return nil, err
+		}
+		domain := strings.ToLower(*req.Domain)
+		req.Domain = &domain
+	}
+	if err := s.checkTenantDomain(ctx, domainOf(req.Domain)); err != nil {
+		return nil, err
+	}
+	if s.plans != nil {
+		if err := s.plans.checkCreate(ctx, ownerID, domainOf(req.Domain)); err != nil {
+			return nil, err
+		}
+	}
+	redirectType := http.StatusFound
+	if req.RedirectType != nil {
+		redirectType = *req.RedirectType
+	}
+
 	// Log link creation without sensitive data
 	s.logger.LogLinkOperation(ctx, "create", code, false) // Will update to true on success
 
 	// Hash password
 	var passwordHash *string
 	if req.Password != nil {
-		hash, err := bcrypt.GenerateFromPassword([]byte(*req.Password), bcrypt.DefaultCost)
+		hash, err := s.passwords.Hash(*req.Password)
 		if err != nil {
 			return nil, err
 		}
-		hashStr := string(hash)
-		passwordHash = &hashStr
+		passwordHash = &hash
 	}
 
 	// Atomic check and insert using transaction
@@ -131,13 +443,21 @@ func (s *LinkService) CreateLink(ctx context.Context, req *CreateLinkRequest) (*
 	}
 	defer tx.Rollback(ctx) // Rollback if not committed
 
-	// Check if code exists within transaction
-	existing, err := s.storage.GetByCodeTx(ctx, tx, code)
+	// Check if code exists on the link's domain within transaction
+	existing, err := s.storage.GetByCodeTx(ctx, tx, storage.LinkKey(domainOf(req.Domain), code))
 	if err != nil {
 		return nil, err
 	}
 	if existing != nil {
-		return nil, errors.New("code already exists")
+		return nil, s.codeExists(ctx, req.Domain, req.Alias)
+	}
+	if err := s.checkRetired(ctx, tx, storage.LinkKey(domainOf(req.Domain), code), req.Alias); err != nil {
+		return nil, err
+	}
+	if req.Alias != nil {
+		if err := s.checkAliasReservation(ctx, tx, ownerID, *req.Alias); err != nil {
+			return nil, err
+		}
 	}
 
 	link := &storage.Link{
@@ -150,123 +470,178 @@ func (s *LinkService) CreateLink(ctx context.Context, req *CreateLinkRequest) (*
 		ClickCount:   0,
 		CreatedAt:    time.Now(),
 		OwnerID:      &ownerID,
+		RedirectType: redirectType,
+		Domain:       req.Domain,
+		PublicStats:  req.PublicStats,
+		NoIndex:      req.NoIndex,
+		Creation:     creationContext(ctx),
+
+		ExcludeCIDRs:      normalizeList(req.ExcludeCIDRs),
+		ExcludeUserAgents: normalizeList(req.ExcludeUserAgents),
+		AllowCIDRs:        normalizeList(req.AllowCIDRs),
+		DenyCIDRs:         normalizeList(req.DenyCIDRs),
+		CampaignID:        req.CampaignID,
+		Description:       normalizeDescription(req.Description),
+		Metadata:          normalizeMetadata(req.Metadata),
+		ParamRules:        normalizeParamRules(req.ParamRules),
+		TenantID:          tenantID,
+		Region:            s.region.Name,
+	}
+	if req.RateLimit != nil {
+		link.RateLimit = req.RateLimit
+		link.RateLimitWindow = DefaultRateLimitWindow
+		if req.RateLimitWindow != nil {
+			link.RateLimitWindow = *req.RateLimitWindow
+		}
 	}
 
 	err = s.storage.CreateTx(ctx, tx, link)
 	if err != nil {
+		// Lost a race with another request for the same alias
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return nil, s.codeExists(ctx, req.Domain, req.Alias)
+		}
 		return nil, err
 	}
+	if len(req.Tags) > 0 {
+		link.Tags = normalizeTags(req.Tags)
+		if err := s.storage.SetTagsTx(ctx, tx, link.Key(), link.Tags); err != nil {
+			return nil, fmt.Errorf("failed to save tags: %w", err)
+		}
+	}
+	if s.outbox != nil {
+		if err := s.addEvent(ctx, tx, "link.created", link); err != nil {
+			return nil, err
+		}
+	}
 
 	if err := tx.Commit(ctx); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	s.tenantLinks.add(tenantID)
+
+	// Write through so the first redirects, often a burst right after the
+	// link is shared, don't all go to the DB
+	s.cacheLink(ctx, link)
 
 	// Log successful creation
 	s.logger.LogLinkOperation(ctx, "create", code, true)
 
 	response := &CreateLinkResponse{
 		Code:     code,
-		ShortURL: "http://localhost:8080/r/" + code,
+		ShortURL: s.shortURLFor(code, req.Domain),
+		Domain:   domainOf(req.Domain),
 		Metadata: map[string]interface{}{
-			"has_password": passwordHash != nil,
-			"expires_at":   req.ExpiresAt,
-			"max_clicks":   req.MaxClicks,
+			"has_password":  passwordHash != nil,
+			"expires_at":    req.ExpiresAt,
+			"max_clicks":    req.MaxClicks,
+			"redirect_type": redirectType,
 		},
 	}
+	if chainFlag != "" {
+		response.Metadata["flags"] = []string{chainFlag}
+	}
 	return response, nil
 }
 
-func (s *LinkService) GetLink(ctx context.Context, code string) (*storage.Link, error) {
-	// Try cache first
-	cached, err := s.cache.Get(ctx, code)
-	if err == nil && cached != nil {
-		// Check if cached link is expired
-		if cached.ExpiresAt != nil && time.Now().After(*cached.ExpiresAt) {
-			// Expired in cache, delete and fall through to DB
-			s.cache.Delete(ctx, code)
-		} else {
-			// Valid cached link, convert to storage.Link
-			link := &storage.Link{
-				Code:         code,
-				LongURL:      cached.LongURL,
-				PasswordHash: nil, // Don't cache password hash for security
-				ExpiresAt:    cached.ExpiresAt,
-				MaxClicks:    cached.MaxClicks,
-			}
-			return link, nil
-		}
+// codeExists is the error for a create whose code is taken on domain: with
+// an alias, an AliasTakenError carrying alternatives free there
+func (s *LinkService) codeExists(ctx context.Context, domain, alias *string) error {
+	if alias == nil {
+		return ErrCodeExists
 	}
-
-	// Cache miss or expired, get from DB
-	link, err := s.storage.GetByCode(ctx, code)
+	suggestions, err := s.SuggestAliases(WithDomain(ctx, domainOf(domain)), *alias)
 	if err != nil {
-		return nil, err
+		s.logger.Warn(ctx, "failed to suggest aliases", "alias", *alias, "error", err)
 	}
-	if link == nil {
-		// Cache negative result briefly
-		s.cache.Set(ctx, code, &cache.CachedLink{
-			LongURL:     "",
-			HasPassword: false,
-			ExpiresAt:   nil,
-			MaxClicks:   nil,
-		}, 5*time.Minute)
-		return nil, nil
+	return &AliasTakenError{Alias: *alias, Suggestions: suggestions}
+}
+
+// PurgeCache drops cached links so that their next lookup reads the DB: the
+// link for code, or with prefix set every link whose code starts with code
+// (all of them for an empty code). Links on a custom domain are cached under
+// "domain/code". It returns the number of entries removed.
+func (s *LinkService) PurgeCache(ctx context.Context, code string, prefix bool) (int64, error) {
+	return s.cache.Purge(ctx, code, prefix)
+}
+
+// GetLinks looks up many codes at once: the cache is read in one round trip
+// and the misses in one query. Codes that don't exist are left out of the
+// result. Like GetLink, protected links always come from the DB.
+func (s *LinkService) GetLinks(ctx context.Context, codes []string) (map[string]*storage.Link, error) {
+	links := make(map[string]*storage.Link, len(codes))
+	keys := make([]string, len(codes))
+	for i, code := range codes {
+		keys[i] = linkKey(ctx, code)
+	}
+	cached, err := s.cache.GetMany(ctx, keys)
+	if err != nil {
+		s.logger.Warn(ctx, "failed to read links from cache", "error", err)
+		cached = nil
 	}
 
-	// Cache the result
-	ttl := 24 * time.Hour // Default TTL
-	if link.ExpiresAt != nil {
-		remaining := time.Until(*link.ExpiresAt)
-		if remaining > 0 && remaining < ttl {
-			ttl = remaining
+	var misses []string
+	seen := make(map[string]bool, len(codes))
+	for i, code := range codes {
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+		c := cached[keys[i]]
+		switch {
+		case c == nil || c.HasPassword || (c.ExpiresAt != nil && time.Now().After(*c.ExpiresAt)):
+			misses = append(misses, keys[i])
+		case c.LongURL != "":
+			links[code] = s.fromCache(keys[i], c)
 		}
 	}
-
-	cachedLink := &cache.CachedLink{
-		LongURL:     link.LongURL,
-		HasPassword: link.PasswordHash != nil,
-		ExpiresAt:   link.ExpiresAt,
-		MaxClicks:   link.MaxClicks,
+	if len(misses) == 0 {
+		return links, nil
 	}
-	s.cache.Set(ctx, code, cachedLink, ttl)
-
-	return link, nil
-}
 
-func (s *LinkService) VerifyPassword(ctx context.Context, code, password string) error {
-	link, err := s.storage.GetByCode(ctx, code)
+	found, err := s.storage.GetByCodes(ctx, misses)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if link == nil || link.PasswordHash == nil {
-		return errors.New("no password set")
+	for _, link := range found {
+		links[link.Code] = link
+		s.cacheLink(ctx, link)
 	}
-	return bcrypt.CompareHashAndPassword([]byte(*link.PasswordHash), []byte(password))
+	for _, key := range misses {
+		if _, code := storage.SplitLinkKey(key); links[code] == nil {
+			s.cache.Set(ctx, key, &cache.CachedLink{}, s.currentSettings().NegativeCacheTTL)
+		}
+	}
+	return links, nil
 }
 
-func (s *LinkService) IsExpired(link *storage.Link) bool {
-	if link.ExpiresAt != nil && time.Now().After(*link.ExpiresAt) {
-		return true
+// ErrInvalidPeriod is returned by TopLinks for an unsupported period
+var ErrInvalidPeriod = errors.New("period must be one of " + strings.Join(cache.TopPeriods, ", "))
+
+// TopLinks returns the caller's most clicked links over period, or across
+// all owners when global is set. It reads the Redis leaderboards only.
+func (s *LinkService) TopLinks(ctx context.Context, period string, limit int, global bool) ([]cache.TopLink, error) {
+	if !slices.Contains(cache.TopPeriods, period) {
+		return nil, ErrInvalidPeriod
 	}
-	if link.MaxClicks != nil && link.ClickCount >= *link.MaxClicks {
-		return true
+	var ownerID *uuid.UUID
+	if !global {
+		id := middleware.GetOwnerIDFromContext(ctx)
+		if id == uuid.Nil {
+			return nil, errors.New("owner_id not found in context")
+		}
+		ownerID = &id
 	}
-	return false
-}
-
-func (s *LinkService) IncrementClickCount(ctx context.Context, code string) error {
-	// Use Redis counter for performance
-	count, err := s.cache.IncrementClick(ctx, code)
+	top, err := s.cache.TopLinks(ctx, ownerID, period, time.Now(), limit)
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	// Update DB periodically (every 10 clicks)
-	if count%10 == 0 {
-		return s.storage.IncrementClickCount(ctx, code)
+	// The leaderboards rank link keys
+	for i := range top {
+		top[i].Domain, top[i].Code = storage.SplitLinkKey(top[i].Code)
 	}
-
-	return nil
+	return top, nil
 }
 
 func (s *LinkService) DeleteLink(ctx context.Context, code string) error {
@@ -277,33 +652,82 @@ func (s *LinkService) DeleteLink(ctx context.Context, code string) error {
 	}
 
 	// Get existing link to check ownership
-	link, err := s.storage.GetByCode(ctx, code)
+	key := linkKey(ctx, code)
+	link, err := s.storage.GetByCode(ctx, key)
 	if err != nil {
 		return err
 	}
 	if link == nil {
-		return errors.New("link not found")
+		return ErrLinkNotFound
 	}
 
 	// Enforce ownership
 	if link.OwnerID == nil || *link.OwnerID != ownerID {
-		return errors.New("access denied: not the owner of this link")
+		return ErrNotOwner
 	}
 
 	// Invalidate cache
-	s.cache.Delete(ctx, code)
+	s.cache.Delete(ctx, key)
 
-	return s.storage.Delete(ctx, code)
+	// Tags, reminders and health checks go with the row; see link_cleanup.go
+	if s.outbox == nil {
+		err = s.storage.Delete(ctx, key)
+	} else {
+		err = s.inTx(ctx, func(tx pgx.Tx) error {
+			if err := s.storage.DeleteTx(ctx, tx, key); err != nil {
+				return err
+			}
+			return s.addEvent(ctx, tx, "link.deleted", link)
+		})
+	}
+	if err != nil {
+		return err
+	}
+	s.forgetLink(ctx, link)
+	return nil
 }
 
 type UpdateLinkRequest struct {
-	LongURL   *string    `json:"long_url,omitempty"`
-	Password  *string    `json:"password,omitempty"`
-	ExpiresAt *time.Time `json:"expires_at,omitempty"`
-	MaxClicks *int       `json:"max_clicks,omitempty"`
+	LongURL      *string    `json:"long_url,omitempty" validate:"url,max=2048"`
+	Password     *string    `json:"password,omitempty" validate:"min=1,max=72"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty" validate:"future"`
+	MaxClicks    *int       `json:"max_clicks,omitempty" validate:"min=1"`
+	Tags         *[]string  `json:"tags,omitempty" validate:"max=20,tags"`
+	RedirectType *int       `json:"redirect_type,omitempty" validate:"oneof=301 302 307 308"`
+	PublicStats  *bool      `json:"public_stats,omitempty"`
+	NoIndex      *bool      `json:"noindex,omitempty"`
+	// Replace the link's click counting exclusions; [] clears them
+	ExcludeCIDRs      *[]string `json:"exclude_cidrs,omitempty" validate:"max=20,cidrs"`
+	ExcludeUserAgents *[]string `json:"exclude_user_agents,omitempty" validate:"max=20,substrings"`
+	// Replace the link's requester IP rules; [] clears them
+	AllowCIDRs *[]string `json:"allow_cidrs,omitempty" validate:"max=50,cidrs"`
+	DenyCIDRs  *[]string `json:"deny_cidrs,omitempty" validate:"max=50,cidrs"`
+	// CampaignID moves the link to one of the caller's campaigns; "" removes
+	// it from its campaign
+	CampaignID *string `json:"campaign_id,omitempty"`
+	// Description "" and metadata {} clear them; metadata is replaced, not
+	// merged
+	Description *string         `json:"description,omitempty" validate:"max=2000"`
+	Metadata    *map[string]any `json:"metadata,omitempty" validate:"max=50,metadata"`
+	// ParamRules replaces the link's parameter rules; {} clears them
+	ParamRules *map[string]string `json:"param_rules,omitempty"`
+	// RateLimit 0 removes the link's redirect rate limit
+	RateLimit       *int    `json:"rate_limit,omitempty" validate:"min=0"`
+	RateLimitWindow *string `json:"rate_limit_window,omitempty" validate:"oneof=minute hour day"`
 }
 
 func (s *LinkService) UpdateLink(ctx context.Context, code string, req *UpdateLinkRequest) error {
+	if req.LongURL != nil {
+		longURL, err := ASCIIURL(*req.LongURL)
+		if err != nil {
+			return errInvalidIDN
+		}
+		req.LongURL = &longURL
+	}
+	if err := validation.Struct(req); err != nil {
+		return err
+	}
+
 	// Get owner_id from context
 	ownerID := middleware.GetOwnerIDFromContext(ctx)
 	if ownerID == uuid.Nil {
@@ -311,34 +735,40 @@ func (s *LinkService) UpdateLink(ctx context.Context, code string, req *UpdateLi
 	}
 
 	// Get existing link
-	link, err := s.storage.GetByCode(ctx, code)
+	link, err := s.storage.GetByCode(ctx, linkKey(ctx, code))
 	if err != nil {
 		return err
 	}
 	if link == nil {
-		return errors.New("link not found")
+		return ErrLinkNotFound
 	}
 
 	// Enforce ownership
 	if link.OwnerID == nil || *link.OwnerID != ownerID {
-		return errors.New("access denied: not the owner of this link")
+		return ErrNotOwner
 	}
 
 	// Update fields
 	if req.LongURL != nil {
-		if _, err := url.ParseRequestURI(*req.LongURL); err != nil {
+		parsedURL, err := url.ParseRequestURI(*req.LongURL)
+		if err != nil {
 			return errors.New("invalid URL")
 		}
+		if err := s.checkDestination(parsedURL, *req.LongURL); err != nil {
+			return err
+		}
+		if _, err := s.checkChain(ctx, parsedURL); err != nil {
+			return err
+		}
 		link.LongURL = *req.LongURL
 	}
 
 	if req.Password != nil {
-		hash, err := bcrypt.GenerateFromPassword([]byte(*req.Password), bcrypt.DefaultCost)
+		hash, err := s.passwords.Hash(*req.Password)
 		if err != nil {
 			return err
 		}
-		hashStr := string(hash)
-		link.PasswordHash = &hashStr
+		link.PasswordHash = &hash
 	}
 
 	if req.ExpiresAt != nil {
@@ -349,14 +779,405 @@ func (s *LinkService) UpdateLink(ctx context.Context, code string, req *UpdateLi
 		link.MaxClicks = req.MaxClicks
 	}
 
+	if req.RedirectType != nil {
+		link.RedirectType = *req.RedirectType
+	}
+
+	if req.PublicStats != nil {
+		link.PublicStats = *req.PublicStats
+	}
+
+	if req.NoIndex != nil {
+		link.NoIndex = *req.NoIndex
+	}
+
+	if req.ExcludeCIDRs != nil {
+		link.ExcludeCIDRs = normalizeList(*req.ExcludeCIDRs)
+	}
+
+	if req.ExcludeUserAgents != nil {
+		link.ExcludeUserAgents = normalizeList(*req.ExcludeUserAgents)
+	}
+
+	if req.AllowCIDRs != nil {
+		link.AllowCIDRs = normalizeList(*req.AllowCIDRs)
+	}
+
+	if req.DenyCIDRs != nil {
+		link.DenyCIDRs = normalizeList(*req.DenyCIDRs)
+	}
+
+	if req.Description != nil {
+		link.Description = normalizeDescription(req.Description)
+	}
+
+	if req.Metadata != nil {
+		link.Metadata = normalizeMetadata(*req.Metadata)
+	}
+
+	if req.ParamRules != nil {
+		link.ParamRules = normalizeParamRules(*req.ParamRules)
+	}
+	if req.RateLimit != nil {
+		link.RateLimit = req.RateLimit
+		if *req.RateLimit == 0 {
+			link.RateLimit = nil
+		}
+	}
+	if req.RateLimitWindow != nil {
+		link.RateLimitWindow = *req.RateLimitWindow
+	}
+	switch {
+	case link.RateLimit == nil:
+		link.RateLimitWindow = ""
+	case link.RateLimitWindow == "":
+		link.RateLimitWindow = DefaultRateLimitWindow
+	}
+
+	if req.LongURL != nil || req.ParamRules != nil {
+		if err := checkParams(link.LongURL, link.ParamRules); err != nil {
+			return err
+		}
+	}
+
+	if req.CampaignID != nil {
+		if *req.CampaignID == "" {
+			link.CampaignID = nil
+		} else {
+			campaignID, err := uuid.Parse(*req.CampaignID)
+			if err != nil {
+				return errors.New("invalid campaign_id")
+			}
+			if err := s.checkCampaign(ctx, ownerID, campaignID); err != nil {
+				return err
+			}
+			link.CampaignID = &campaignID
+		}
+	}
+
 	// Update in DB
-	err = s.storage.Update(ctx, link)
+	link.Region = s.region.Name
+	if s.outbox == nil && req.Tags == nil {
+		err = s.storage.Update(ctx, link)
+	} else {
+		// Tags and the outbox event commit with the link or not at all
+		err = s.inTx(ctx, func(tx pgx.Tx) error {
+			if err := s.storage.UpdateTx(ctx, tx, link); err != nil {
+				return err
+			}
+			if req.Tags != nil {
+				link.Tags = normalizeTags(*req.Tags)
+				if err := s.storage.SetTagsTx(ctx, tx, link.Key(), link.Tags); err != nil {
+					return fmt.Errorf("failed to save tags: %w", err)
+				}
+			}
+			if s.outbox == nil {
+				return nil
+			}
+			return s.addEvent(ctx, tx, "link.updated", link)
+		})
+	}
 	if err != nil {
 		return err
 	}
 
-	// Invalidate cache
-	s.cache.Delete(ctx, code)
+	// Refresh the cached copy rather than dropping it, so the next redirect
+	// doesn't have to go to the DB
+	s.cacheLink(ctx, link)
+
+	return nil
+}
+
+// ListLinks returns the caller's links matching query, with tags and, when
+// checked, destination health loaded
+func (s *LinkService) ListLinks(ctx context.Context, query storage.LinkQuery, limit, offset int) ([]*storage.Link, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	return s.ListOwnerLinks(ctx, ownerID, query, limit, offset)
+}
+
+// ListOwnerLinks is ListLinks for any owner, for operators
+func (s *LinkService) ListOwnerLinks(ctx context.Context, ownerID uuid.UUID, query storage.LinkQuery, limit, offset int) ([]*storage.Link, error) {
+	links, err := s.storage.ListByOwner(ctx, ownerID, query, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.LoadTags(ctx, links); err != nil {
+		return nil, err
+	}
+	if err := s.loadHealth(ctx, links); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+func (s *LinkService) loadHealth(ctx context.Context, links []*storage.Link) error {
+	if s.health == nil || len(links) == 0 {
+		return nil
+	}
+	keys := make([]string, len(links))
+	for i, link := range links {
+		keys[i] = link.Key()
+	}
+	health, err := s.health.GetHealth(ctx, keys)
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		link.Health = health[link.Key()]
+	}
+	return nil
+}
+
+// GetOwnedLink returns a link with its tags if it belongs to the caller
+func (s *LinkService) GetOwnedLink(ctx context.Context, code string) (*storage.Link, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	link, err := s.storage.GetByCode(ctx, linkKey(ctx, code))
+	if err != nil {
+		return nil, err
+	}
+	if link == nil {
+		return nil, ErrLinkNotFound
+	}
+	if link.OwnerID == nil || *link.OwnerID != ownerID {
+		return nil, ErrNotOwner
+	}
+	if err := s.LoadTags(ctx, []*storage.Link{link}); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
 
+// GetSharedLink returns a link without an ownership check, for requests
+// admitted by a signed share URL
+func (s *LinkService) GetSharedLink(ctx context.Context, code string) (*storage.Link, error) {
+	link, err := s.storage.GetByCode(ctx, linkKey(ctx, code))
+	if err != nil {
+		return nil, err
+	}
+	if link == nil {
+		return nil, ErrLinkNotFound
+	}
+	return link, nil
+}
+
+// ListTags returns every tag used on the caller's links
+func (s *LinkService) ListTags(ctx context.Context) ([]string, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+	return s.storage.ListTagsByOwner(ctx, ownerID)
+}
+
+// LinkStats summarizes click activity for a link
+type LinkStats struct {
+	TotalClicks     int  `json:"total_clicks"`
+	MaxClicks       *int `json:"max_clicks,omitempty"`
+	RemainingClicks *int `json:"remaining_clicks,omitempty"`
+	Expired         bool `json:"expired"`
+	// LastClickedAt lags by up to the click sync interval
+	LastClickedAt *time.Time `json:"last_clicked_at,omitempty"`
+}
+
+func (s *LinkService) Stats(link *storage.Link) *LinkStats {
+	stats := &LinkStats{
+		TotalClicks:   link.ClickCount,
+		MaxClicks:     link.MaxClicks,
+		Expired:       s.IsExpired(link),
+		LastClickedAt: link.LastClickedAt,
+	}
+	if link.MaxClicks != nil {
+		remaining := *link.MaxClicks - link.ClickCount
+		if remaining < 0 {
+			remaining = 0
+		}
+		stats.RemainingClicks = &remaining
+	}
+	return stats
+}
+
+// checkCampaign reports ErrCampaignNotFound unless the campaign exists and
+// belongs to ownerID
+func (s *LinkService) checkCampaign(ctx context.Context, ownerID, campaignID uuid.UUID) error {
+	if s.campaigns == nil {
+		return errors.New("campaigns are not enabled")
+	}
+	campaign, err := s.campaigns.GetCampaign(ctx, campaignID)
+	if err != nil {
+		return err
+	}
+	if campaign == nil || campaign.OwnerID != ownerID {
+		return ErrCampaignNotFound
+	}
+	return nil
+}
+
+func (s *LinkService) inTx(ctx context.Context, fn func(pgx.Tx) error) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// addEvent records a link event whose payload is the link as the API shows it
+func (s *LinkService) addEvent(ctx context.Context, tx pgx.Tx, eventType string, link *storage.Link) error {
+	payload, err := json.Marshal(link)
+	if err != nil {
+		return err
+	}
+	return s.outbox.AddEventTx(ctx, tx, &storage.OutboxEvent{
+		EventID:   uuid.New(),
+		Type:      eventType,
+		OwnerID:   link.OwnerID,
+		Payload:   payload,
+		CreatedAt: time.Now(),
+	})
+}
+
+// publicStatsDays is the length of the time series on public stats pages
+const publicStatsDays = 30
+
+// PublicStats is what /r/{code}/stats shows. The destination is left out so
+// that password-protected links don't leak it.
+type PublicStats struct {
+	Code        string       `json:"code"`
+	ShortURL    string       `json:"short_url"`
+	CreatedAt   time.Time    `json:"created_at"`
+	TotalClicks int          `json:"total_clicks"`
+	Daily       []*DayClicks `json:"daily"`
+}
+
+// GetPublicStats returns the stats of a link whose owner opted in with
+// public_stats and didn't set noindex; other links are reported as not
+// found. Daily covers the last 30 UTC days up to and including today, or fewer
+// if the owner's plan keeps less history. With UseStatsCache, the stats may be
+// cached, though whether a link shows them is always checked.
+func (s *Resolver) GetPublicStats(ctx context.Context, code string) (*PublicStats, error) {
+	key := linkKey(ctx, code)
+	link, err := s.storage.GetByCode(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if link == nil || !link.PublicStats || link.NoIndex {
+		return nil, ErrLinkNotFound
+	}
+	return cachedStats(ctx, s.stats, "public:"+key, func(ctx context.Context) (*PublicStats, error) {
+		return s.publicStats(ctx, link)
+	})
+}
+
+func (s *Resolver) publicStats(ctx context.Context, link *storage.Link) (*PublicStats, error) {
+	key := link.Key()
+	days := publicStatsDays
+	if link.OwnerID != nil {
+		var err error
+		if days, err = s.AnalyticsDays(ctx, *link.OwnerID, days); err != nil {
+			return nil, err
+		}
+	}
+	stats := &PublicStats{
+		Code:        link.Code,
+		ShortURL:    s.LinkShortURL(link),
+		CreatedAt:   link.CreatedAt,
+		TotalClicks: link.ClickCount,
+		Daily:       make([]*DayClicks, 0, days),
+	}
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	for day := today.AddDate(0, 0, 1-days); !day.After(today); day = day.AddDate(0, 0, 1) {
+		counts, err := s.cache.GetDailyClicks(ctx, []string{key}, day)
+		if err != nil {
+			return nil, err
+		}
+		stats.Daily = append(stats.Daily, &DayClicks{Date: day.Format("2006-01-02"), Clicks: counts[key]})
+	}
+	return stats, nil
+}
+
+// AnalyticsDays caps a daily click history of days to what the plan of
+// ownerID keeps
+func (s *Resolver) AnalyticsDays(ctx context.Context, ownerID uuid.UUID, days int) (int, error) {
+	if s.plans == nil {
+		return days, nil
+	}
+	return s.plans.analyticsDays(ctx, ownerID, days)
+}
+
+// LoadTags fills in the tags of links with one query
+func (s *LinkService) LoadTags(ctx context.Context, links []*storage.Link) error {
+	if len(links) == 0 {
+		return nil
+	}
+	keys := make([]string, len(links))
+	for i, link := range links {
+		keys[i] = link.Key()
+	}
+	tags, err := s.storage.GetTags(ctx, keys)
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		link.Tags = tags[link.Key()]
+	}
+	return nil
+}
+
+// normalizeTags lowercases and de-duplicates tags, keeping their order
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	out := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if !seen[tag] {
+			seen[tag] = true
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+// applyPreferences fills fields the request left out from the owner's saved
+// defaults. Explicit values always win.
+func (s *LinkService) applyPreferences(ctx context.Context, ownerID uuid.UUID, req *CreateLinkRequest) error {
+	if s.preferences == nil {
+		return nil
+	}
+	prefs, err := s.preferences.GetPreferences(ctx, ownerID)
+	if err != nil {
+		return fmt.Errorf("failed to load preferences: %w", err)
+	}
+	if prefs == nil {
+		return nil
+	}
+
+	if req.ExpiresAt == nil && prefs.DefaultExpiry != nil {
+		expiresAt := time.Now().Add(*prefs.DefaultExpiry)
+		req.ExpiresAt = &expiresAt
+	}
+	if req.RedirectType == nil {
+		req.RedirectType = prefs.DefaultRedirectType
+	}
+	if req.Tags == nil && len(prefs.DefaultTags) > 0 {
+		req.Tags = prefs.DefaultTags
+	}
+	if req.Domain == nil {
+		req.Domain = prefs.DefaultDomain
+	}
 	return nil
 }
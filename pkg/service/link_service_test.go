@@ -1,12 +1,22 @@
 package service
 
 import (
+	"context"
+	"strings"
 	"testing"
 	"time"
 
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
 	"url-shortener/pkg/storage"
+	"url-shortener/pkg/tenant"
+	"url-shortener/pkg/validation"
 
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestIsExpired(t *testing.T) {
@@ -75,3 +85,272 @@ func TestIsExpired(t *testing.T) {
 		})
 	}
 }
+
+type passwordLinks struct {
+	storage.LinkStorage
+	link *storage.Link
+}
+
+func (p *passwordLinks) GetByCode(ctx context.Context, code string) (*storage.Link, error) {
+	return p.link, nil
+}
+
+func (p *passwordLinks) ReplacePasswordHash(ctx context.Context, code, old, new string) error {
+	if *p.link.PasswordHash == old {
+		p.link.PasswordHash = &new
+	}
+	return nil
+}
+
+func TestVerifyPasswordUpgradesBcryptHashes(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	require.NoError(t, err)
+	hash := string(legacy)
+	links := &passwordLinks{link: &storage.Link{Code: "abc", PasswordHash: &hash}}
+	s := NewLinkService(links, nil, nil, logging.NewLogger(logging.LevelError))
+
+	_, err = s.VerifyPassword(context.Background(), "abc", "wrong")
+	assert.ErrorIs(t, err, ErrWrongPassword)
+	assert.Equal(t, hash, *links.link.PasswordHash)
+
+	link, err := s.VerifyPassword(context.Background(), "abc", "hunter2")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", link.Key())
+	assert.True(t, strings.HasPrefix(*links.link.PasswordHash, "$argon2id$"))
+	// The upgraded hash still accepts the password
+	_, err = s.VerifyPassword(context.Background(), "abc", "hunter2")
+	assert.NoError(t, err)
+}
+
+type batchLinks struct {
+	storage.LinkStorage
+	links   map[string]*storage.Link
+	queried []string
+}
+
+func (b *batchLinks) GetByCodes(ctx context.Context, codes []string) ([]*storage.Link, error) {
+	b.queried = append(b.queried, codes...)
+	var found []*storage.Link
+	for _, code := range codes {
+		if link := b.links[code]; link != nil {
+			found = append(found, link)
+		}
+	}
+	return found, nil
+}
+
+type batchCache struct {
+	cache.LinkCacheInterface
+	entries map[string]*cache.CachedLink
+}
+
+func (b *batchCache) GetMany(ctx context.Context, codes []string) (map[string]*cache.CachedLink, error) {
+	found := make(map[string]*cache.CachedLink)
+	for _, code := range codes {
+		if entry := b.entries[code]; entry != nil {
+			found[code] = entry
+		}
+	}
+	return found, nil
+}
+
+func (b *batchCache) Set(ctx context.Context, code string, link *cache.CachedLink, ttl time.Duration) error {
+	b.entries[code] = link
+	return nil
+}
+
+func TestGetLinks(t *testing.T) {
+	hash := "hash"
+	links := &batchLinks{links: map[string]*storage.Link{
+		"db":     {Code: "db", LongURL: "https://example.com/db"},
+		"locked": {Code: "locked", LongURL: "https://example.com/locked", PasswordHash: &hash},
+	}}
+	entries := &batchCache{entries: map[string]*cache.CachedLink{
+		"hot":    {LongURL: "https://example.com/hot"},
+		"gone":   {},
+		"locked": {LongURL: "https://example.com/locked", HasPassword: true},
+	}}
+	s := NewLinkService(links, entries, nil, logging.NewLogger(logging.LevelError))
+
+	found, err := s.GetLinks(context.Background(), []string{"hot", "db", "gone", "locked", "missing", "hot"})
+	require.NoError(t, err)
+	assert.Len(t, found, 3)
+	assert.Equal(t, "https://example.com/hot", found["hot"].LongURL)
+	assert.Equal(t, "https://example.com/db", found["db"].LongURL)
+	// Protected links are read from the DB so their hash is known
+	assert.NotNil(t, found["locked"].PasswordHash)
+	assert.ElementsMatch(t, []string{"db", "locked", "missing"}, links.queried)
+
+	// Misses are cached, including the negative one
+	assert.Equal(t, "https://example.com/db", entries.entries["db"].LongURL)
+	assert.Equal(t, "", entries.entries["missing"].LongURL)
+}
+
+type updatableLinks struct {
+	storage.LinkStorage
+	link *storage.Link
+}
+
+func (u *updatableLinks) GetByCode(ctx context.Context, code string) (*storage.Link, error) {
+	copied := *u.link
+	return &copied, nil
+}
+
+func (u *updatableLinks) Update(ctx context.Context, link *storage.Link) error {
+	u.link = link
+	return nil
+}
+
+func TestUpdateLinkRefreshesCache(t *testing.T) {
+	ownerID := uuid.New()
+	links := &updatableLinks{link: &storage.Link{Code: "abc", LongURL: "https://example.com/old", OwnerID: &ownerID}}
+	entries := &batchCache{entries: map[string]*cache.CachedLink{
+		"abc": {LongURL: "https://example.com/old"},
+	}}
+	s := NewLinkService(links, entries, nil, logging.NewLogger(logging.LevelError))
+
+	ctx := middleware.WithOwnerID(context.Background(), ownerID)
+	newURL := "https://example.com/new"
+	require.NoError(t, s.UpdateLink(ctx, "abc", &UpdateLinkRequest{LongURL: &newURL}))
+	require.NotNil(t, entries.entries["abc"])
+	assert.Equal(t, newURL, entries.entries["abc"].LongURL)
+}
+
+func TestUpdateLinkChecksDestination(t *testing.T) {
+	ownerID := uuid.New()
+	links := &updatableLinks{link: &storage.Link{Code: "abc", LongURL: "https://example.com", OwnerID: &ownerID}}
+	s := NewLinkService(links, &batchCache{entries: map[string]*cache.CachedLink{}}, nil, logging.NewLogger(logging.LevelError))
+	settings := DefaultSettings()
+	settings.BlockedDomains = []string{"evil.example"}
+	s.ApplySettings(settings)
+	ctx := middleware.WithOwnerID(context.Background(), ownerID)
+
+	for _, longURL := range []string{
+		"https://www.evil.example/landing",
+		"http://localhost:8080/admin",
+		"http://10.0.0.1/",
+		"http://[::1]:8080/",
+		"https://example.com/?next=javascript:alert(1)",
+	} {
+		err := s.UpdateLink(ctx, "abc", &UpdateLinkRequest{LongURL: &longURL})
+		assert.ErrorContains(t, err, "invalid URL", longURL)
+	}
+	assert.Equal(t, "https://example.com", links.link.LongURL, "blocked destinations aren't saved")
+}
+
+func TestUpdateLinkDescriptionAndMetadata(t *testing.T) {
+	ownerID := uuid.New()
+	links := &updatableLinks{link: &storage.Link{Code: "abc", LongURL: "https://example.com", OwnerID: &ownerID}}
+	entries := &batchCache{entries: map[string]*cache.CachedLink{}}
+	s := NewLinkService(links, entries, nil, logging.NewLogger(logging.LevelError))
+	ctx := middleware.WithOwnerID(context.Background(), ownerID)
+
+	description := "  Q3 newsletter  "
+	metadata := map[string]any{"ticket": "OPS-42", "priority": 2.0}
+	require.NoError(t, s.UpdateLink(ctx, "abc", &UpdateLinkRequest{Description: &description, Metadata: &metadata}))
+	assert.Equal(t, "Q3 newsletter", *links.link.Description)
+	assert.Equal(t, metadata, links.link.Metadata)
+	assert.Equal(t, metadata, entries.entries["abc"].Metadata)
+
+	// Empty values clear
+	blank, empty := "", map[string]any{}
+	require.NoError(t, s.UpdateLink(ctx, "abc", &UpdateLinkRequest{Description: &blank, Metadata: &empty}))
+	assert.Nil(t, links.link.Description)
+	assert.Nil(t, links.link.Metadata)
+
+	huge := map[string]any{"blob": strings.Repeat("x", maxMetadataBytes)}
+	var fieldErrs validation.Errors
+	require.ErrorAs(t, s.UpdateLink(ctx, "abc", &UpdateLinkRequest{Metadata: &huge}), &fieldErrs)
+	assert.Equal(t, "metadata", fieldErrs[0].Field)
+
+	badKey := map[string]any{"": 1}
+	require.ErrorAs(t, s.UpdateLink(ctx, "abc", &UpdateLinkRequest{Metadata: &badKey}), &fieldErrs)
+}
+
+func TestSuggestAliases(t *testing.T) {
+	links := &batchLinks{links: map[string]*storage.Link{
+		"launch-2": {Code: "launch-2"},
+	}}
+	s := NewLinkService(links, nil, nil, logging.NewLogger(logging.LevelError))
+
+	suggestions, err := s.SuggestAliases(context.Background(), "launch")
+	require.NoError(t, err)
+	require.Len(t, suggestions, maxAliasSuggestions)
+	assert.Equal(t, []string{"launch-3", "launch-4"}, suggestions[:2])
+	for _, alias := range suggestions {
+		assert.True(t, ValidateAlias(alias), alias)
+		assert.NotEqual(t, "launch-2", alias)
+	}
+
+	// Long aliases still give valid suggestions
+	suggestions, err = s.SuggestAliases(context.Background(), strings.Repeat("a", 50))
+	require.NoError(t, err)
+	for _, alias := range suggestions {
+		assert.LessOrEqual(t, len(alias), 50)
+	}
+
+	alias := "launch"
+	err = s.codeExists(context.Background(), nil, &alias)
+	assert.ErrorIs(t, err, ErrCodeExists)
+	var taken *AliasTakenError
+	require.ErrorAs(t, err, &taken)
+	assert.Equal(t, "launch", taken.Alias)
+	assert.NotEmpty(t, taken.Suggestions)
+
+	// Aliases are only taken on their own domain
+	domain := "go.example.com"
+	links.links["go.example.com/launch-3"] = &storage.Link{Code: "launch-3", Domain: &domain}
+	err = s.codeExists(context.Background(), &domain, &alias)
+	require.ErrorAs(t, err, &taken)
+	assert.Equal(t, []string{"launch-2", "launch-4"}, taken.Suggestions[:2])
+}
+
+func TestGetLinksOnDomain(t *testing.T) {
+	domain := "go.example.com"
+	links := &batchLinks{links: map[string]*storage.Link{
+		"db":                {Code: "db", LongURL: "https://example.com/default"},
+		"go.example.com/db": {Code: "db", Domain: &domain, LongURL: "https://example.com/db"},
+	}}
+	entries := &batchCache{entries: map[string]*cache.CachedLink{
+		"go.example.com/hot": {LongURL: "https://example.com/hot"},
+	}}
+	s := NewLinkService(links, entries, nil, logging.NewLogger(logging.LevelError))
+
+	found, err := s.GetLinks(WithDomain(context.Background(), "Go.Example.com"), []string{"hot", "db", "missing"})
+	require.NoError(t, err)
+	require.Len(t, found, 2)
+	assert.Equal(t, "https://example.com/db", found["db"].LongURL)
+	assert.Equal(t, "hot", found["hot"].Code)
+	assert.Equal(t, &domain, found["hot"].Domain)
+	assert.Equal(t, "https://example.com/hot", found["hot"].LongURL)
+	assert.ElementsMatch(t, []string{"go.example.com/db", "go.example.com/missing"}, links.queried)
+	assert.Contains(t, entries.entries, "go.example.com/missing")
+}
+
+func TestShortDomainFor(t *testing.T) {
+	s := NewLinkService(nil, nil, nil, logging.NewLogger(logging.LevelError))
+	s.ApplySettings(Settings{ShortDomains: []string{"Go.Example.com"}})
+
+	assert.Equal(t, "go.example.com", s.ShortDomainFor("go.example.com"))
+	assert.Equal(t, "go.example.com", s.ShortDomainFor("GO.example.com:8080"))
+	assert.Equal(t, "", s.ShortDomainFor("api.example.com"))
+	assert.Equal(t, "", s.ShortDomainFor(""))
+}
+
+func TestTenantDomains(t *testing.T) {
+	s := NewLinkService(nil, nil, nil, logging.NewLogger(logging.LevelError))
+	tenants, err := tenant.NewRegistry(map[string][]string{"acme": {"go.acme.com"}})
+	require.NoError(t, err)
+	s.UseTenants(tenants)
+
+	acme := tenant.WithTenant(context.Background(), "acme")
+	assert.Equal(t, "acme", s.TenantOf("GO.acme.com"))
+	assert.NoError(t, s.checkTenantDomain(acme, "go.acme.com"))
+	assert.Error(t, s.checkTenantDomain(acme, ""))
+	assert.Error(t, s.checkTenantDomain(acme, "go.example.com"))
+
+	// The default tenant keeps the default domain and unclaimed ones
+	assert.NoError(t, s.checkTenantDomain(context.Background(), ""))
+	assert.NoError(t, s.checkTenantDomain(context.Background(), "go.example.com"))
+	assert.Error(t, s.checkTenantDomain(context.Background(), "go.acme.com"))
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"url-shortener/pkg/logging"
+)
+
+// MaintenanceStore keeps maintenance mode for every server sharing it
+type MaintenanceStore interface {
+	// GetMaintenance returns the message and start of maintenance mode, and
+	// whether it is on
+	GetMaintenance(ctx context.Context) (string, time.Time, bool, error)
+	SetMaintenance(ctx context.Context, message string, since time.Time) error
+	ClearMaintenance(ctx context.Context) error
+}
+
+// MaintenanceState is whether maintenance mode is on and what clients are
+// told while it is
+type MaintenanceState struct {
+	Enabled bool       `json:"enabled"`
+	Message string     `json:"message"`
+	Since   *time.Time `json:"since,omitempty"`
+	// Pinned is set when MAINTENANCE_MODE turned it on for this server,
+	// which operators can't turn off
+	Pinned bool `json:"pinned"`
+}
+
+// Maintenance is the API's maintenance mode, during which redirects and
+// reads are served and writes refused, for migrations and incidents.
+// Operators turn it on for every server through the store, which each server
+// polls; MAINTENANCE_MODE pins it on for one.
+type Maintenance struct {
+	store   MaintenanceStore
+	pinned  bool
+	message string
+	logger  *logging.Logger
+	state   atomic.Pointer[MaintenanceState]
+}
+
+// NewMaintenance starts maintenance mode off unless pinned; message is told
+// to clients when it is turned on without one
+func NewMaintenance(store MaintenanceStore, pinned bool, message string, logger *logging.Logger) *Maintenance {
+	m := &Maintenance{store: store, pinned: pinned, message: message, logger: logger}
+	m.state.Store(&MaintenanceState{Enabled: pinned, Message: message, Pinned: pinned})
+	return m
+}
+
+// State is maintenance mode as of the last refresh
+func (m *Maintenance) State() MaintenanceState {
+	return *m.state.Load()
+}
+
+// Refusal returns the message to refuse writes with, and whether to
+func (m *Maintenance) Refusal() (string, bool) {
+	state := m.state.Load()
+	return state.Message, state.Enabled
+}
+
+// Set turns maintenance mode on, with message or, if it is empty, the
+// default one, or off, for every server; others notice on their next refresh
+func (m *Maintenance) Set(ctx context.Context, enabled bool, message string) (MaintenanceState, error) {
+	if message == "" {
+		message = m.message
+	}
+	var err error
+	if enabled {
+		err = m.store.SetMaintenance(ctx, message, time.Now())
+	} else {
+		err = m.store.ClearMaintenance(ctx)
+	}
+	if err != nil {
+		return MaintenanceState{}, err
+	}
+	if err := m.Refresh(ctx); err != nil {
+		return MaintenanceState{}, err
+	}
+	return m.State(), nil
+}
+
+// Refresh reads maintenance mode from the store
+func (m *Maintenance) Refresh(ctx context.Context) error {
+	message, since, on, err := m.store.GetMaintenance(ctx)
+	if err != nil {
+		return err
+	}
+	state := &MaintenanceState{Enabled: m.pinned || on, Message: m.message, Pinned: m.pinned}
+	if on {
+		state.Message, state.Since = message, &since
+	}
+	m.state.Store(state)
+	return nil
+}
+
+// Run refreshes maintenance mode every interval until ctx is done. A failed
+// refresh keeps the mode as it was.
+func (m *Maintenance) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Refresh(ctx); err != nil && ctx.Err() == nil {
+			m.logger.Error(ctx, "failed to refresh maintenance mode", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
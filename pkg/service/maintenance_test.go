@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/logging"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryMaintenance struct {
+	message string
+	since   time.Time
+	on      bool
+	err     error
+}
+
+func (m *memoryMaintenance) GetMaintenance(ctx context.Context) (string, time.Time, bool, error) {
+	return m.message, m.since, m.on, m.err
+}
+
+func (m *memoryMaintenance) SetMaintenance(ctx context.Context, message string, since time.Time) error {
+	m.message, m.since, m.on = message, since, true
+	return nil
+}
+
+func (m *memoryMaintenance) ClearMaintenance(ctx context.Context) error {
+	m.message, m.since, m.on = "", time.Time{}, false
+	return nil
+}
+
+func TestMaintenance(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryMaintenance{}
+	m := NewMaintenance(store, false, "under maintenance", logging.NewLogger(logging.LevelError))
+	_, on := m.Refusal()
+	assert.False(t, on)
+
+	state, err := m.Set(ctx, true, "")
+	require.NoError(t, err)
+	assert.True(t, state.Enabled)
+	assert.Equal(t, "under maintenance", state.Message)
+	require.NotNil(t, state.Since)
+
+	// Another server turned it off
+	store.on = false
+	require.NoError(t, m.Refresh(ctx))
+	assert.False(t, m.State().Enabled)
+
+	// A failed refresh keeps the mode as it was
+	store.on, store.message, store.err = true, "migrating", errors.New("redis down")
+	assert.Error(t, m.Refresh(ctx))
+	assert.False(t, m.State().Enabled)
+	store.err = nil
+	require.NoError(t, m.Refresh(ctx))
+	message, on := m.Refusal()
+	assert.True(t, on)
+	assert.Equal(t, "migrating", message)
+}
+
+func TestMaintenancePinned(t *testing.T) {
+	m := NewMaintenance(&memoryMaintenance{}, true, "under maintenance", logging.NewLogger(logging.LevelError))
+	_, on := m.Refusal()
+	assert.True(t, on)
+
+	state, err := m.Set(context.Background(), false, "")
+	require.NoError(t, err)
+	assert.True(t, state.Enabled)
+	assert.True(t, state.Pinned)
+}
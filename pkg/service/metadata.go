@@ -0,0 +1,50 @@
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"url-shortener/pkg/validation"
+)
+
+// maxMetadataBytes bounds a link's metadata as JSON. Metadata is cached with
+// the link, so it has to stay small.
+const maxMetadataBytes = 4096
+
+func init() {
+	validation.Register("metadata", func(v reflect.Value, _ string) (bool, string) {
+		for _, key := range v.MapKeys() {
+			if n := utf8.RuneCountInString(key.String()); n == 0 || n > 100 {
+				return false, "keys must be 1-100 characters"
+			}
+		}
+		encoded, err := json.Marshal(v.Interface())
+		if err != nil || len(encoded) > maxMetadataBytes {
+			return false, "must be at most " + strconv.Itoa(maxMetadataBytes) + " bytes as JSON"
+		}
+		return true, ""
+	})
+}
+
+// normalizeDescription trims the description; a blank one is dropped
+func normalizeDescription(description *string) *string {
+	if description == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*description)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+// normalizeMetadata stores empty metadata as NULL
+func normalizeMetadata(metadata map[string]any) map[string]any {
+	if len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+
+	"github.com/google/uuid"
+)
+
+// NotificationService manages the caller's notification channels and
+// per-category opt-ins
+type NotificationService struct {
+	storage storage.ReminderStorage
+	links   *LinkService
+}
+
+func NewNotificationService(storage storage.ReminderStorage, links *LinkService) *NotificationService {
+	return &NotificationService{
+		storage: storage,
+		links:   links,
+	}
+}
+
+// NotificationSettings is the API representation of
+// storage.NotificationSettings
+type NotificationSettings struct {
+	// Email defaults to the address in the caller's token
+	Email         *string `json:"email,omitempty" validate:"omitempty,email,max=320"`
+	EmailEnabled  bool    `json:"email_enabled"`
+	WebhookURL    *string `json:"webhook_url,omitempty" validate:"omitempty,url,max=2048"`
+	DaysBefore    *int    `json:"days_before,omitempty" validate:"min=1,max=90"`
+	ClicksPercent *int    `json:"clicks_percent,omitempty" validate:"min=1,max=100"`
+
+	// Categories; omitted booleans stay enabled
+	ExpiryReminders *bool  `json:"expiry_reminders,omitempty"`
+	AbuseNotices    *bool  `json:"abuse_notices,omitempty"`
+	Digest          string `json:"digest,omitempty" validate:"omitempty,oneof=off week month"`
+
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+func (s *NotificationService) GetSettings(ctx context.Context) (*NotificationSettings, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	ns, err := s.storage.GetNotificationSettings(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if ns == nil {
+		ns = defaultNotificationSettings(ownerID)
+	}
+	return toNotificationSettings(ns), nil
+}
+
+// UpdateSettings replaces the caller's settings
+func (s *NotificationService) UpdateSettings(ctx context.Context, req *NotificationSettings) (*NotificationSettings, error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	ns := defaultNotificationSettings(ownerID)
+	ns.EmailEnabled = req.EmailEnabled
+	ns.Email = req.Email
+	if ns.Email == nil {
+		if email := middleware.GetEmailFromContext(ctx); email != "" {
+			ns.Email = &email
+		}
+	}
+	if ns.EmailEnabled && ns.Email == nil {
+		return nil, validation.Errors{{Field: "email", Rule: "required", Message: "is required to enable email reminders"}}
+	}
+	if req.WebhookURL != nil {
+		if err := s.links.ValidateDestination(*req.WebhookURL); err != nil {
+			return nil, validation.Errors{{Field: "webhook_url", Rule: "destination", Message: strings.TrimPrefix(err.Error(), "invalid URL: ")}}
+		}
+		ns.WebhookURL = req.WebhookURL
+	}
+	if req.DaysBefore != nil {
+		ns.DaysBefore = *req.DaysBefore
+	}
+	if req.ClicksPercent != nil {
+		ns.ClicksPercent = *req.ClicksPercent
+	}
+	if req.ExpiryReminders != nil {
+		ns.ExpiryReminders = *req.ExpiryReminders
+	}
+	if req.AbuseNotices != nil {
+		ns.AbuseNotices = *req.AbuseNotices
+	}
+	if req.Digest != "" {
+		ns.Digest = req.Digest
+	}
+	ns.UpdatedAt = time.Now()
+
+	if err := s.storage.SaveNotificationSettings(ctx, ns); err != nil {
+		return nil, err
+	}
+	return toNotificationSettings(ns), nil
+}
+
+func defaultNotificationSettings(ownerID uuid.UUID) *storage.NotificationSettings {
+	return &storage.NotificationSettings{
+		OwnerID:         ownerID,
+		DaysBefore:      3,
+		ClicksPercent:   90,
+		ExpiryReminders: true,
+		AbuseNotices:    true,
+		Digest:          "off",
+	}
+}
+
+func toNotificationSettings(ns *storage.NotificationSettings) *NotificationSettings {
+	out := &NotificationSettings{
+		Email:           ns.Email,
+		EmailEnabled:    ns.EmailEnabled,
+		WebhookURL:      ns.WebhookURL,
+		DaysBefore:      &ns.DaysBefore,
+		ClicksPercent:   &ns.ClicksPercent,
+		ExpiryReminders: &ns.ExpiryReminders,
+		AbuseNotices:    &ns.AbuseNotices,
+		Digest:          ns.Digest,
+	}
+	if !ns.UpdatedAt.IsZero() {
+		out.UpdatedAt = &ns.UpdatedAt
+	}
+	return out
+}
@@ -0,0 +1,140 @@
+package service
+
+import (
+	"bytes"
+	"errors"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+)
+
+// Parameterized links have {name} placeholders in their destination, such as
+// https://example.com/order/{id}, and are visited as /r/{code}/{id}: the path
+// segments after the code fill the placeholders in the order they first
+// appear. Each value has to match its parameter's rule, "slug" unless the
+// link's param_rules name another.
+
+const (
+	maxLinkParams  = 5
+	maxParamLength = 200
+	// defaultParamRule applies to parameters without a rule
+	defaultParamRule = "slug"
+)
+
+var placeholderPattern = regexp.MustCompile(`\{([a-z][a-z0-9_]{0,31})\}`)
+
+// paramRules are the rules a parameter can be held to, by name
+var paramRules = map[string]*regexp.Regexp{
+	"any":   regexp.MustCompile(`^[^/]+$`),
+	"alnum": regexp.MustCompile(`^[A-Za-z0-9]+$`),
+	"int":   regexp.MustCompile(`^[0-9]+$`),
+	"slug":  regexp.MustCompile(`^[A-Za-z0-9_-]+$`),
+	"uuid":  regexp.MustCompile(`^[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}$`),
+}
+
+// destinations holds buffers for building destinations, which otherwise
+// grow a new one on every visit to a parameterized link
+var destinations = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// ErrInvalidParams is returned by Destination when the values given don't
+// fit the link's parameters
+var ErrInvalidParams = errors.New("invalid link parameters")
+
+// LinkParams returns the names of the placeholders in longURL, in the order
+// they first appear
+func LinkParams(longURL string) []string {
+	// Most links have none, and are redirected without running the pattern
+	if !strings.Contains(longURL, "{") {
+		return nil
+	}
+	var names []string
+	for _, m := range placeholderPattern.FindAllStringSubmatch(longURL, -1) {
+		if !slices.Contains(names, m[1]) {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// checkParams checks the placeholders in longURL and the rules given for them
+func checkParams(longURL string, rules map[string]string) error {
+	names := LinkParams(longURL)
+	if len(names) > maxLinkParams {
+		return validation.Errors{{Field: "long_url", Rule: "params", Message: "must have at most " + strconv.Itoa(maxLinkParams) + " parameters"}}
+	}
+	for name, rule := range rules {
+		if !slices.Contains(names, name) {
+			return validation.Errors{{Field: "param_rules", Rule: "params", Message: "has a rule for " + name + ", which long_url doesn't use"}}
+		}
+		if paramRules[rule] == nil {
+			return validation.Errors{{Field: "param_rules", Rule: "params", Message: "rule for " + name + " must be one of any, alnum, int, slug, uuid"}}
+		}
+	}
+	return nil
+}
+
+// normalizeParamRules stores empty rules as NULL
+func normalizeParamRules(rules map[string]string) map[string]string {
+	if len(rules) == 0 {
+		return nil
+	}
+	return rules
+}
+
+// Destination returns where a visit to link with the given parameter values
+// goes. Values are escaped for the part of the URL they land in. A link
+// without parameters only takes none, and is reported as not found
+// otherwise.
+func (s *Resolver) Destination(link *storage.Link, values []string) (string, error) {
+	names := LinkParams(link.LongURL)
+	if len(names) == 0 {
+		if len(values) > 0 {
+			return "", ErrLinkNotFound
+		}
+		return link.LongURL, nil
+	}
+	if len(values) != len(names) {
+		return "", ErrInvalidParams
+	}
+
+	byName := make(map[string]string, len(names))
+	for i, name := range names {
+		rule := link.ParamRules[name]
+		if rule == "" {
+			rule = defaultParamRule
+		}
+		pattern := paramRules[rule]
+		if pattern == nil || len(values[i]) > maxParamLength || !pattern.MatchString(values[i]) {
+			return "", ErrInvalidParams
+		}
+		byName[name] = values[i]
+	}
+
+	// Placeholders before the query are path segments
+	pathEnd := strings.IndexAny(link.LongURL, "?#")
+	if pathEnd < 0 {
+		pathEnd = len(link.LongURL)
+	}
+	b := destinations.Get().(*bytes.Buffer)
+	b.Reset()
+	defer destinations.Put(b)
+	last := 0
+	for _, m := range placeholderPattern.FindAllStringSubmatchIndex(link.LongURL, -1) {
+		b.WriteString(link.LongURL[last:m[0]])
+		value := byName[link.LongURL[m[2]:m[3]]]
+		if m[0] < pathEnd {
+			b.WriteString(url.PathEscape(value))
+		} else {
+			b.WriteString(url.QueryEscape(value))
+		}
+		last = m[1]
+	}
+	b.WriteString(link.LongURL[last:])
+	return b.String(), nil
+}
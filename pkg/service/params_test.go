@@ -0,0 +1,57 @@
+package service
+
+import (
+	"testing"
+
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDestination(t *testing.T) {
+	s := NewLinkService(nil, nil, nil, nil)
+	link := &storage.Link{
+		LongURL:    "https://example.com/orders/{id}?ref={source}&again={id}",
+		ParamRules: map[string]string{"id": "int", "source": "any"},
+	}
+	assert.Equal(t, []string{"id", "source"}, LinkParams(link.LongURL))
+
+	dest, err := s.Destination(link, []string{"42", "news letter&x=1"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/orders/42?ref=news+letter%26x%3D1&again=42", dest)
+
+	_, err = s.Destination(link, []string{"abc", "x"})
+	assert.ErrorIs(t, err, ErrInvalidParams)
+	_, err = s.Destination(link, []string{"42"})
+	assert.ErrorIs(t, err, ErrInvalidParams)
+
+	// Parameters default to the slug rule
+	slugged := &storage.Link{LongURL: "https://example.com/p/{name}"}
+	dest, err = s.Destination(slugged, []string{"blue-shoes_2"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/p/blue-shoes_2", dest)
+	_, err = s.Destination(slugged, []string{"blue shoes"})
+	assert.ErrorIs(t, err, ErrInvalidParams)
+
+	// Plain links take no parameters
+	plain := &storage.Link{LongURL: "https://example.com/"}
+	dest, err = s.Destination(plain, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/", dest)
+	_, err = s.Destination(plain, []string{"extra"})
+	assert.ErrorIs(t, err, ErrLinkNotFound)
+}
+
+func TestCheckParams(t *testing.T) {
+	var fieldErrs validation.Errors
+	assert.NoError(t, checkParams("https://example.com/{a}/{b}", map[string]string{"a": "uuid"}))
+
+	require.ErrorAs(t, checkParams("https://example.com/{a}", map[string]string{"b": "int"}), &fieldErrs)
+	assert.Equal(t, "param_rules", fieldErrs[0].Field)
+	require.ErrorAs(t, checkParams("https://example.com/{a}", map[string]string{"a": "email"}), &fieldErrs)
+	assert.Equal(t, "param_rules", fieldErrs[0].Field)
+	require.ErrorAs(t, checkParams("https://example.com/{a}/{b}/{c}/{d}/{e}/{f}", nil), &fieldErrs)
+	assert.Equal(t, "long_url", fieldErrs[0].Field)
+}
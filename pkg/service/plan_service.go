@@ -0,0 +1,274 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownPlan = errors.New("unknown plan")
+	// ErrQuotaExceeded is returned when creating a link would go over a
+	// limit of the owner's plan
+	ErrQuotaExceeded = errors.New("plan quota exceeded")
+)
+
+// planCacheTTL is how long an owner's plan is remembered, so that API
+// requests don't each look it up
+const planCacheTTL = time.Minute
+
+// Limits are what a plan allows. A nil limit is unlimited.
+type Limits struct {
+	MaxLinks     *int `json:"max_links"`
+	DailyCreates *int `json:"daily_creates"`
+	// ShortDomains is how many custom short domains links may be spread over
+	ShortDomains *int `json:"short_domains"`
+	// AnalyticsDays is how many days of daily click history are shown
+	AnalyticsDays     *int `json:"analytics_days"`
+	RequestsPerMinute *int `json:"requests_per_minute"`
+	// AliasReservations is how many alias patterns may be reserved
+	AliasReservations *int `json:"alias_reservations"`
+}
+
+type Plan struct {
+	Name   string `json:"name"`
+	Limits Limits `json:"limits"`
+}
+
+func limit(n int) *int {
+	return &n
+}
+
+// Plans are the plans owners can be put on, from the smallest
+var Plans = []*Plan{
+	{Name: "free", Limits: Limits{
+		MaxLinks:          limit(500),
+		DailyCreates:      limit(50),
+		ShortDomains:      limit(0),
+		AnalyticsDays:     limit(7),
+		RequestsPerMinute: limit(60),
+		AliasReservations: limit(0),
+	}},
+	{Name: "pro", Limits: Limits{
+		MaxLinks:          limit(10000),
+		DailyCreates:      limit(1000),
+		ShortDomains:      limit(3),
+		AnalyticsDays:     limit(30),
+		RequestsPerMinute: limit(600),
+		AliasReservations: limit(0),
+	}},
+	{Name: "enterprise"},
+}
+
+// LookupPlan returns the plan called name, or nil
+func LookupPlan(name string) *Plan {
+	for _, plan := range Plans {
+		if plan.Name == name {
+			return plan
+		}
+	}
+	return nil
+}
+
+// OwnerPlan is an owner's plan and whether an operator assigned it
+type OwnerPlan struct {
+	*Plan
+	Assigned  bool       `json:"assigned"`
+	UpdatedBy string     `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// PlanService puts owners on plans and enforces their limits. Owners nobody
+// assigned a plan to are on the default plan.
+type PlanService struct {
+	storage     storage.PlanStorage
+	users       storage.UserStorage
+	defaultPlan *Plan
+	logger      *logging.Logger
+
+	mu     sync.Mutex
+	cached map[uuid.UUID]cachedPlan
+}
+
+type cachedPlan struct {
+	plan    *Plan
+	expires time.Time
+}
+
+func NewPlanService(storage storage.PlanStorage, users storage.UserStorage, defaultPlan string, logger *logging.Logger) (*PlanService, error) {
+	plan := LookupPlan(defaultPlan)
+	if plan == nil {
+		return nil, fmt.Errorf("%w %q", ErrUnknownPlan, defaultPlan)
+	}
+	return &PlanService{
+		storage:     storage,
+		users:       users,
+		defaultPlan: plan,
+		logger:      logger,
+		cached:      make(map[uuid.UUID]cachedPlan),
+	}, nil
+}
+
+// PlanOf returns the plan ownerID is on
+func (s *PlanService) PlanOf(ctx context.Context, ownerID uuid.UUID) (*Plan, error) {
+	s.mu.Lock()
+	c, ok := s.cached[ownerID]
+	s.mu.Unlock()
+	if ok && time.Now().Before(c.expires) {
+		return c.plan, nil
+	}
+
+	assigned, err := s.GetOwnerPlan(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	s.remember(ownerID, assigned.Plan)
+	return assigned.Plan, nil
+}
+
+// GetOwnerPlan returns ownerID's plan along with who assigned it. A plan
+// that is no longer offered falls back to the default plan.
+func (s *PlanService) GetOwnerPlan(ctx context.Context, ownerID uuid.UUID) (*OwnerPlan, error) {
+	p, err := s.storage.GetOwnerPlan(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return &OwnerPlan{Plan: s.defaultPlan}, nil
+	}
+	plan := LookupPlan(p.Plan)
+	if plan == nil {
+		s.logger.Warn(ctx, "owner is on an unknown plan", "owner_id", ownerID, "plan", p.Plan)
+		plan = s.defaultPlan
+	}
+	return &OwnerPlan{Plan: plan, Assigned: true, UpdatedBy: p.UpdatedBy, UpdatedAt: &p.UpdatedAt}, nil
+}
+
+// SetPlan puts ownerID on the plan called name
+func (s *PlanService) SetPlan(ctx context.Context, ownerID uuid.UUID, name string) (*OwnerPlan, error) {
+	actor := middleware.GetSubFromContext(ctx)
+	plan, err := s.assign(ctx, ownerID, name, actor)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.LogAdminAction(ctx, "user.set_plan", actor, ownerID.String(), "plan", plan.Name)
+	return plan, nil
+}
+
+// assign puts ownerID on the plan called name on behalf of actor
+func (s *PlanService) assign(ctx context.Context, ownerID uuid.UUID, name, actor string) (*OwnerPlan, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	plan := LookupPlan(name)
+	if plan == nil {
+		return nil, fmt.Errorf("%w %q", ErrUnknownPlan, name)
+	}
+	p := &storage.OwnerPlan{
+		OwnerID:   ownerID,
+		Plan:      plan.Name,
+		UpdatedBy: actor,
+		UpdatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	if err := s.storage.SetOwnerPlan(ctx, p); err != nil {
+		return nil, err
+	}
+	s.remember(ownerID, plan)
+	return &OwnerPlan{Plan: plan, Assigned: true, UpdatedBy: p.UpdatedBy, UpdatedAt: &p.UpdatedAt}, nil
+}
+
+// unassign puts ownerID back on the default plan
+func (s *PlanService) unassign(ctx context.Context, ownerID uuid.UUID) error {
+	if err := s.storage.DeleteOwnerPlan(ctx, ownerID); err != nil {
+		return err
+	}
+	s.remember(ownerID, s.defaultPlan)
+	return nil
+}
+
+func (s *PlanService) remember(ownerID uuid.UUID, plan *Plan) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, c := range s.cached {
+		if now.After(c.expires) {
+			delete(s.cached, id)
+		}
+	}
+	s.cached[ownerID] = cachedPlan{plan: plan, expires: now.Add(planCacheTTL)}
+}
+
+// RequestsPerMinute is ownerID's API rate limit, 0 meaning unlimited. A
+// failed lookup doesn't limit the owner.
+func (s *PlanService) RequestsPerMinute(ctx context.Context, ownerID uuid.UUID) int {
+	plan, err := s.PlanOf(ctx, ownerID)
+	if err != nil {
+		s.logger.Warn(ctx, "failed to look up plan", "owner_id", ownerID, "error", err)
+		return 0
+	}
+	if plan.Limits.RequestsPerMinute == nil {
+		return 0
+	}
+	return *plan.Limits.RequestsPerMinute
+}
+
+// analyticsDays caps a click history of days to ownerID's plan
+func (s *PlanService) analyticsDays(ctx context.Context, ownerID uuid.UUID, days int) (int, error) {
+	plan, err := s.PlanOf(ctx, ownerID)
+	if err != nil {
+		return 0, err
+	}
+	if plan.Limits.AnalyticsDays != nil {
+		days = min(days, *plan.Limits.AnalyticsDays)
+	}
+	return days, nil
+}
+
+// checkCreate reports ErrQuotaExceeded if ownerID creating a link on domain,
+// "" being the default domain, would go over a limit of their plan
+func (s *PlanService) checkCreate(ctx context.Context, ownerID uuid.UUID, domain string) error {
+	plan, err := s.PlanOf(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	limits := plan.Limits
+	if limits.MaxLinks == nil && limits.DailyCreates == nil && (domain == "" || limits.ShortDomains == nil) {
+		return nil
+	}
+
+	usage, err := s.users.GetUsage(ctx, ownerID, time.Now().UTC().Truncate(24*time.Hour))
+	if err != nil {
+		return err
+	}
+	if limits.MaxLinks != nil && usage.Links >= *limits.MaxLinks {
+		return fmt.Errorf("%w: the %s plan allows %d links", ErrQuotaExceeded, plan.Name, *limits.MaxLinks)
+	}
+	if limits.DailyCreates != nil && usage.CreatesToday >= *limits.DailyCreates {
+		return fmt.Errorf("%w: the %s plan allows %d new links a day", ErrQuotaExceeded, plan.Name, *limits.DailyCreates)
+	}
+	if domain != "" && limits.ShortDomains != nil && !slices.Contains(usage.ShortDomains, domain) && len(usage.ShortDomains) >= *limits.ShortDomains {
+		return fmt.Errorf("%w: the %s plan allows %d custom short domains", ErrQuotaExceeded, plan.Name, *limits.ShortDomains)
+	}
+	return nil
+}
+
+// checkAliasReservation reports ErrQuotaExceeded if ownerID, who has
+// existing alias reservations, may not reserve another
+func (s *PlanService) checkAliasReservation(ctx context.Context, ownerID uuid.UUID, existing int) error {
+	plan, err := s.PlanOf(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	if plan.Limits.AliasReservations != nil && existing >= *plan.Limits.AliasReservations {
+		return fmt.Errorf("%w: the %s plan allows %d alias reservations", ErrQuotaExceeded, plan.Name, *plan.Limits.AliasReservations)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePlanStorage struct {
+	plans map[uuid.UUID]*storage.OwnerPlan
+}
+
+func (f *fakePlanStorage) GetOwnerPlan(ctx context.Context, ownerID uuid.UUID) (*storage.OwnerPlan, error) {
+	return f.plans[ownerID], nil
+}
+
+func (f *fakePlanStorage) DeleteOwnerPlan(ctx context.Context, ownerID uuid.UUID) error {
+	delete(f.plans, ownerID)
+	return nil
+}
+
+func (f *fakePlanStorage) SetOwnerPlan(ctx context.Context, p *storage.OwnerPlan) error {
+	f.plans[p.OwnerID] = p
+	return nil
+}
+
+func TestPlanLimits(t *testing.T) {
+	ownerID := uuid.New()
+	users := &fakeUserStorage{usage: storage.Usage{Links: 10, CreatesToday: 50}}
+	plans, err := NewPlanService(&fakePlanStorage{plans: map[uuid.UUID]*storage.OwnerPlan{}}, users, "free", logging.NewLogger(logging.LevelError))
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// The free plan allows 50 new links a day and no custom domains
+	assert.ErrorIs(t, plans.checkCreate(ctx, ownerID, ""), ErrQuotaExceeded)
+	users.usage.CreatesToday = 3
+	assert.NoError(t, plans.checkCreate(ctx, ownerID, ""))
+	assert.ErrorIs(t, plans.checkCreate(ctx, ownerID, "go.example.com"), ErrQuotaExceeded)
+	assert.Equal(t, 60, plans.RequestsPerMinute(ctx, ownerID))
+	days, err := plans.analyticsDays(ctx, ownerID, 30)
+	require.NoError(t, err)
+	assert.Equal(t, 7, days)
+
+	owner, err := plans.SetPlan(ctx, ownerID, "Pro")
+	require.NoError(t, err)
+	assert.Equal(t, "pro", owner.Name)
+	assert.True(t, owner.Assigned)
+
+	// Pro allows three domains; ones already in use don't count again
+	users.usage.ShortDomains = []string{"a.example.com", "b.example.com", "c.example.com"}
+	assert.NoError(t, plans.checkCreate(ctx, ownerID, "a.example.com"))
+	assert.ErrorIs(t, plans.checkCreate(ctx, ownerID, "d.example.com"), ErrQuotaExceeded)
+
+	_, err = plans.SetPlan(ctx, ownerID, "enterprise")
+	require.NoError(t, err)
+	users.usage = storage.Usage{Links: 1e6, CreatesToday: 1e5}
+	assert.NoError(t, plans.checkCreate(ctx, ownerID, "d.example.com"))
+	assert.Equal(t, 0, plans.RequestsPerMinute(ctx, ownerID))
+
+	_, err = plans.SetPlan(ctx, ownerID, "platinum")
+	assert.ErrorIs(t, err, ErrUnknownPlan)
+	_, err = NewPlanService(&fakePlanStorage{}, users, "platinum", logging.NewLogger(logging.LevelError))
+	assert.ErrorIs(t, err, ErrUnknownPlan)
+}
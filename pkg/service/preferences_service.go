@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+
+	"github.com/google/uuid"
+)
+
+// PreferencesService manages the caller's link creation defaults
+type PreferencesService struct {
+	storage storage.PreferencesStorage
+	links   *LinkService
+}
+
+func NewPreferencesService(storage storage.PreferencesStorage, links *LinkService) *PreferencesService {
+	return &PreferencesService{
+		storage: storage,
+		links:   links,
+	}
+}
+
+// Preferences is the API representation of storage.Preferences. Omitted
+// fields have no default.
+type Preferences struct {
+	DefaultExpiry       *string    `json:"default_expiry,omitempty" validate:"duration"`
+	DefaultRedirectType *int       `json:"default_redirect_type,omitempty" validate:"oneof=301 302 307 308"`
+	DefaultTags         []string   `json:"default_tags" validate:"max=20,tags"`
+	DefaultDomain       *string    `json:"default_domain,omitempty" validate:"max=255"`
+	UpdatedAt           *time.Time `json:"updated_at,omitempty"`
+}
+
+func (s *PreferencesService) GetPreferences(ctx context.Context) (*Preferences, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	prefs, err := s.storage.GetPreferences(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if prefs == nil {
+		return &Preferences{DefaultTags: []string{}}, nil
+	}
+	return toPreferences(prefs), nil
+}
+
+// UpdatePreferences replaces the caller's preferences
+func (s *PreferencesService) UpdatePreferences(ctx context.Context, req *Preferences) (*Preferences, error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	prefs := &storage.Preferences{
+		OwnerID:             ownerID,
+		DefaultRedirectType: req.DefaultRedirectType,
+		DefaultTags:         normalizeTags(req.DefaultTags),
+		UpdatedAt:           time.Now(),
+	}
+	if req.DefaultExpiry != nil {
+		// Already checked by the duration rule
+		expiry, _ := time.ParseDuration(*req.DefaultExpiry)
+		prefs.DefaultExpiry = &expiry
+	}
+	if req.DefaultDomain != nil {
+		if err := s.links.ValidateDomain(*req.DefaultDomain); err != nil || s.links.checkTenantDomain(ctx, *req.DefaultDomain) != nil {
+			return nil, validation.Errors{{Field: "default_domain", Rule: "domain", Message: "is not an available short domain"}}
+		}
+		domain := strings.ToLower(*req.DefaultDomain)
+		prefs.DefaultDomain = &domain
+	}
+
+	if err := s.storage.SavePreferences(ctx, prefs); err != nil {
+		return nil, err
+	}
+	return toPreferences(prefs), nil
+}
+
+func toPreferences(prefs *storage.Preferences) *Preferences {
+	out := &Preferences{
+		DefaultRedirectType: prefs.DefaultRedirectType,
+		DefaultTags:         prefs.DefaultTags,
+		DefaultDomain:       prefs.DefaultDomain,
+		UpdatedAt:           &prefs.UpdatedAt,
+	}
+	if out.DefaultTags == nil {
+		out.DefaultTags = []string{}
+	}
+	if prefs.DefaultExpiry != nil {
+		expiry := prefs.DefaultExpiry.String()
+		out.DefaultExpiry = &expiry
+	}
+	return out
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePreferencesStorage struct {
+	prefs map[uuid.UUID]*storage.Preferences
+}
+
+func (f *fakePreferencesStorage) GetPreferences(ctx context.Context, ownerID uuid.UUID) (*storage.Preferences, error) {
+	return f.prefs[ownerID], nil
+}
+
+func (f *fakePreferencesStorage) SavePreferences(ctx context.Context, prefs *storage.Preferences) error {
+	f.prefs[prefs.OwnerID] = prefs
+	return nil
+}
+
+func TestApplyPreferences(t *testing.T) {
+	ownerID := uuid.New()
+	expiry := 30 * 24 * time.Hour
+	permanent := 301
+	domain := "go.example.com"
+
+	svc := &LinkService{}
+	svc.UsePreferences(&fakePreferencesStorage{prefs: map[uuid.UUID]*storage.Preferences{
+		ownerID: {
+			OwnerID:             ownerID,
+			DefaultExpiry:       &expiry,
+			DefaultRedirectType: &permanent,
+			DefaultTags:         []string{"marketing"},
+			DefaultDomain:       &domain,
+		},
+	}})
+
+	req := &CreateLinkRequest{LongURL: "https://example.com"}
+	require.NoError(t, svc.applyPreferences(context.Background(), ownerID, req))
+	require.NotNil(t, req.ExpiresAt)
+	assert.WithinDuration(t, time.Now().Add(expiry), *req.ExpiresAt, time.Minute)
+	assert.Equal(t, 301, *req.RedirectType)
+	assert.Equal(t, []string{"marketing"}, req.Tags)
+	assert.Equal(t, "go.example.com", *req.Domain)
+
+	// Explicit values win
+	temporary := 307
+	req = &CreateLinkRequest{LongURL: "https://example.com", RedirectType: &temporary, Tags: []string{}}
+	require.NoError(t, svc.applyPreferences(context.Background(), ownerID, req))
+	assert.Equal(t, 307, *req.RedirectType)
+	assert.Empty(t, req.Tags)
+
+	// Owners without preferences are left alone
+	req = &CreateLinkRequest{LongURL: "https://example.com"}
+	require.NoError(t, svc.applyPreferences(context.Background(), uuid.New(), req))
+	assert.Nil(t, req.ExpiresAt)
+	assert.Nil(t, req.Domain)
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"url-shortener/pkg/storage"
+)
+
+// Windows a link's redirect rate limit can be set per
+var RateLimitWindows = map[string]time.Duration{
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+}
+
+// DefaultRateLimitWindow is used when a rate limit is set without a window
+const DefaultRateLimitWindow = "hour"
+
+// By default every redirect of a rate-limited link is counted in Redis, which
+// costs a round trip per redirect. With leases, a server takes a batch of
+// redirects from a link's window at once and serves them from memory,
+// going back to Redis only when the batch runs out. Redirects leased but not
+// served before the window ends are lost, so the limit can be undershot by
+// up to one lease per server; it is never exceeded. Leases are at most a
+// tenth of the limit, so that low limits, which see little traffic anyway,
+// stay close to exact.
+
+// leaseShare is the most of a link's limit that one lease may take
+const leaseShare = 10
+
+// redirectLeases are the redirects this server has leased for each
+// rate-limited link's current window
+type redirectLeases struct {
+	size      int64
+	mu        sync.Mutex
+	leases    map[string]*redirectLease
+	lastSweep time.Time
+}
+
+type redirectLease struct {
+	mu    sync.Mutex
+	start time.Time
+	end   time.Time
+	left  int64
+	// spent is set once Redis grants less than asked: the window is used up
+	// on every server
+	spent bool
+}
+
+// UseRedirectLeases counts redirects against rate limits in leases of up to
+// size at a time rather than one by one; see above
+func (s *Resolver) UseRedirectLeases(size int) {
+	if size > 1 {
+		s.leases = &redirectLeases{size: int64(size), leases: make(map[string]*redirectLease)}
+	}
+}
+
+// AllowRedirect counts a redirect of link against its rate limit and
+// reports whether it is within it. When it isn't, the returned duration is
+// how long until the window ends. Links without a limit aren't counted, and
+// if Redis is unavailable the redirect is allowed.
+func (s *Resolver) AllowRedirect(ctx context.Context, link *storage.Link) (bool, time.Duration) {
+	if link.RateLimit == nil {
+		return true, 0
+	}
+	window, ok := RateLimitWindows[link.RateLimitWindow]
+	if !ok {
+		window = RateLimitWindows[DefaultRateLimitWindow]
+	}
+	now := time.Now()
+	limit := int64(*link.RateLimit)
+	retryAfter := now.Truncate(window).Add(window).Sub(now)
+	if s.leases != nil {
+		if s.allowLeased(ctx, link.Key(), limit, window, now) {
+			return true, 0
+		}
+		return false, retryAfter
+	}
+	count, err := s.cache.CountRedirects(ctx, link.Key(), window, now, 1)
+	if err != nil {
+		s.logger.Warn(ctx, "failed to check redirect rate limit", "code", link.Key(), "error", err)
+		return true, 0
+	}
+	if count <= limit {
+		return true, 0
+	}
+	return false, retryAfter
+}
+
+// allowLeased serves a redirect of key from this server's lease on its
+// window, taking a new lease from Redis when it has run out
+func (s *Resolver) allowLeased(ctx context.Context, key string, limit int64, window time.Duration, now time.Time) bool {
+	start := now.Truncate(window)
+	l := s.leases.get(key, start, start.Add(window), now)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.start.Equal(start) {
+		l.start, l.end, l.left, l.spent = start, start.Add(window), 0, false
+	}
+	if l.left > 0 {
+		l.left--
+		return true
+	}
+	if l.spent {
+		return false
+	}
+
+	n := min(s.leases.size, max(1, limit/leaseShare))
+	count, err := s.cache.CountRedirects(ctx, key, window, now, n)
+	if err != nil {
+		s.logger.Warn(ctx, "failed to check redirect rate limit", "code", key, "error", err)
+		return true
+	}
+	granted := min(n, max(0, limit-(count-n)))
+	if granted < n {
+		l.spent = true
+	}
+	if granted == 0 {
+		return false
+	}
+	l.left = granted - 1
+	return true
+}
+
+// get returns key's lease, dropping the leases of windows that have ended at
+// most once a minute
+func (r *redirectLeases) get(key string, start, end, now time.Time) *redirectLease {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now.Sub(r.lastSweep) >= time.Minute {
+		r.lastSweep = now
+		for k, l := range r.leases {
+			if l.mu.TryLock() {
+				if !now.Before(l.end) {
+					delete(r.leases, k)
+				}
+				l.mu.Unlock()
+			}
+		}
+	}
+	l, ok := r.leases[key]
+	if !ok {
+		l = &redirectLease{start: start, end: end}
+		r.leases[key] = l
+	}
+	return l
+}
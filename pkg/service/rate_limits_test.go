@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type redirectCountCache struct {
+	cache.LinkCacheInterface
+	counts  map[string]int64
+	windows []time.Duration
+	err     error
+}
+
+func (c *redirectCountCache) CountRedirects(ctx context.Context, code string, window time.Duration, now time.Time, n int64) (int64, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	c.windows = append(c.windows, window)
+	c.counts[code] += n
+	return c.counts[code], nil
+}
+
+func TestAllowRedirect(t *testing.T) {
+	counts := &redirectCountCache{counts: map[string]int64{}}
+	s := NewLinkService(nil, counts, nil, logging.NewLogger(logging.LevelError))
+	ctx := context.Background()
+
+	allowed, _ := s.AllowRedirect(ctx, &storage.Link{Code: "free"})
+	assert.True(t, allowed)
+	assert.Empty(t, counts.counts, "links without a limit aren't counted")
+
+	limit := 2
+	link := &storage.Link{Code: "abc", RateLimit: &limit, RateLimitWindow: "minute"}
+	for i := 0; i < limit; i++ {
+		allowed, _ = s.AllowRedirect(ctx, link)
+		assert.True(t, allowed)
+	}
+	allowed, retryAfter := s.AllowRedirect(ctx, link)
+	assert.False(t, allowed)
+	assert.True(t, retryAfter > 0 && retryAfter <= time.Minute, retryAfter)
+	assert.Equal(t, []time.Duration{time.Minute, time.Minute, time.Minute}, counts.windows)
+
+	// Redis being down doesn't take the link down with it
+	counts.err = errors.New("connection refused")
+	allowed, _ = s.AllowRedirect(ctx, link)
+	assert.True(t, allowed)
+}
+
+func TestAllowRedirectLeased(t *testing.T) {
+	counts := &redirectCountCache{counts: map[string]int64{}}
+	logger := logging.NewLogger(logging.LevelError)
+	a := NewResolver(nil, counts, logger)
+	a.UseRedirectLeases(5)
+	b := NewResolver(nil, counts, logger)
+	b.UseRedirectLeases(5)
+	ctx := context.Background()
+	limit := 100
+	link := &storage.Link{Code: "abc", RateLimit: &limit, RateLimitWindow: "day"}
+
+	allowed := 0
+	for i := 0; i < 3; i++ {
+		if ok, _ := a.AllowRedirect(ctx, link); ok {
+			allowed++
+		}
+	}
+	for i := 0; i < 100; i++ {
+		if ok, _ := b.AllowRedirect(ctx, link); ok {
+			allowed++
+		}
+	}
+	assert.Equal(t, 98, allowed)
+	assert.Len(t, counts.windows, 21, "redirects are counted five at a time")
+
+	// a still has what's left of its lease, and then the window is spent
+	for i := 0; i < 3; i++ {
+		if ok, _ := a.AllowRedirect(ctx, link); ok {
+			allowed++
+		}
+	}
+	assert.Equal(t, limit, allowed)
+	ok, retryAfter := a.AllowRedirect(ctx, link)
+	assert.False(t, ok)
+	assert.True(t, retryAfter > 0 && retryAfter <= 24*time.Hour, retryAfter)
+	assert.Len(t, counts.windows, 22, "a spent window isn't asked about again")
+
+	// Low limits lease one redirect at a time
+	low := 5
+	counts.windows = nil
+	a.AllowRedirect(ctx, &storage.Link{Code: "low", RateLimit: &low})
+	assert.EqualValues(t, 1, counts.counts["low"])
+}
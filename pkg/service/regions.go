@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"url-shortener/pkg/replication"
+	"url-shortener/pkg/storage"
+)
+
+// Region is where this deployment runs when links are served from several
+// regions at once. Regions share the code space by interleaving: region
+// Index of Count generates the codes whose IDs are Index modulo Count, so
+// two regions never generate the same code. Codes generated before
+// interleaving was turned on are below every interleaved ID.
+type Region struct {
+	Name  string
+	Index int
+	Count int
+}
+
+// codeID is the ID the region generates for the seq'th code
+func (r Region) codeID(seq int64) int64 {
+	if r.Count <= 1 {
+		return seq
+	}
+	return seq*int64(r.Count) + int64(r.Index)
+}
+
+// UseRegion generates codes from region's share of the code space and marks
+// links written here as written in region
+func (s *LinkService) UseRegion(region Region) {
+	s.region = region
+}
+
+// UseReplicas applies link writes from other regions with replicas; see
+// ApplyChange
+func (s *LinkService) UseReplicas(replicas storage.ReplicaStorage) {
+	s.replicas = replicas
+}
+
+// ApplyChange applies a link write replicated from another region, unless
+// the link has been written since. Aliases are only checked within a region,
+// so the same alias created in two regions before either hears of the other
+// ends up as the later of the two links everywhere.
+func (s *LinkService) ApplyChange(ctx context.Context, change *replication.Change) error {
+	if s.replicas == nil {
+		return fmt.Errorf("replication is not enabled")
+	}
+	key := change.Key
+	var applied bool
+	var err error
+	switch change.Op {
+	case replication.ChangeUpsert:
+		if change.Link == nil {
+			return fmt.Errorf("upsert of %s without a link", change.Key)
+		}
+		link := change.Link
+		link.PasswordHash = change.PasswordHash
+		link.UpdatedAt = change.At
+		link.Region = change.Region
+		key = link.Key()
+		applied, err = s.replicas.ApplyLink(ctx, link)
+		if err == nil && applied {
+			err = s.storage.SetTags(ctx, key, link.Tags)
+		}
+	case replication.ChangeDelete:
+		applied, err = s.replicas.ApplyDelete(ctx, key, change.At, change.Region)
+	default:
+		return fmt.Errorf("unknown change %q", change.Op)
+	}
+	if err != nil {
+		return err
+	}
+	if applied {
+		s.cache.Delete(ctx, key)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/replication"
+	"url-shortener/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegionCodeSpace(t *testing.T) {
+	assert.Equal(t, int64(7), Region{}.codeID(7))
+	us, eu := Region{Name: "us", Index: 0, Count: 2}, Region{Name: "eu", Index: 1, Count: 2}
+	seen := map[int64]bool{}
+	for seq := int64(100); seq < 200; seq++ {
+		for _, id := range []int64{us.codeID(seq), eu.codeID(seq)} {
+			assert.False(t, seen[id], "id %d generated twice", id)
+			assert.Greater(t, id, int64(99), "below codes generated before interleaving")
+			seen[id] = true
+		}
+	}
+}
+
+type replicaLinks struct {
+	storage.LinkStorage
+	applied []*storage.Link
+	deleted []string
+	tags    map[string][]string
+	stale   bool
+}
+
+func (l *replicaLinks) ApplyLink(ctx context.Context, link *storage.Link) (bool, error) {
+	if l.stale {
+		return false, nil
+	}
+	l.applied = append(l.applied, link)
+	return true, nil
+}
+
+func (l *replicaLinks) ApplyDelete(ctx context.Context, key string, deletedAt time.Time, region string) (bool, error) {
+	l.deleted = append(l.deleted, key)
+	return true, nil
+}
+
+func (l *replicaLinks) SetTags(ctx context.Context, key string, tags []string) error {
+	l.tags[key] = tags
+	return nil
+}
+
+type replicaCache struct {
+	cache.LinkCacheInterface
+	deleted []string
+}
+
+func (c *replicaCache) Delete(ctx context.Context, code string) error {
+	c.deleted = append(c.deleted, code)
+	return nil
+}
+
+func TestApplyChange(t *testing.T) {
+	links := &replicaLinks{tags: map[string][]string{}}
+	cached := &replicaCache{}
+	s := NewLinkService(links, cached, nil, logging.NewLogger(logging.LevelError))
+	ctx := context.Background()
+	at := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	hash := "hash"
+	domain := "go.example"
+	upsert := &replication.Change{
+		Op: replication.ChangeUpsert, Key: "go.example/abc", Region: "eu", At: at,
+		Link:         &storage.Link{Code: "abc", Domain: &domain, LongURL: "https://example.com", Tags: []string{"launch"}},
+		PasswordHash: &hash,
+	}
+	assert.Error(t, s.ApplyChange(ctx, upsert), "replication is off")
+
+	s.UseReplicas(links)
+	require.NoError(t, s.ApplyChange(ctx, upsert))
+	require.Len(t, links.applied, 1)
+	assert.Equal(t, "eu", links.applied[0].Region)
+	assert.Equal(t, at, links.applied[0].UpdatedAt)
+	assert.Equal(t, &hash, links.applied[0].PasswordHash)
+	assert.Equal(t, []string{"launch"}, links.tags["go.example/abc"])
+	assert.Equal(t, []string{"go.example/abc"}, cached.deleted)
+
+	// A link written here since is kept, cache and all
+	links.stale = true
+	require.NoError(t, s.ApplyChange(ctx, upsert))
+	assert.Len(t, cached.deleted, 1)
+
+	require.NoError(t, s.ApplyChange(ctx, &replication.Change{Op: replication.ChangeDelete, Key: "xyz", Region: "eu", At: at}))
+	assert.Equal(t, []string{"xyz"}, links.deleted)
+	assert.Equal(t, []string{"go.example/abc", "xyz"}, cached.deleted)
+
+	assert.Error(t, s.ApplyChange(ctx, &replication.Change{Op: "merge", Key: "xyz"}))
+}
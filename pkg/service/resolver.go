@@ -0,0 +1,341 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"url-shortener/pkg/analytics"
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/security"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/tenant"
+)
+
+// Resolver serves existing links: it looks them up through the cache,
+// decides whether a visit goes through and counts clicks. It is all the
+// redirect server needs, and LinkService builds link management on top of
+// it.
+type Resolver struct {
+	storage storage.LinkStorage
+	cache   cache.LinkCacheInterface
+	logger  *logging.Logger
+	plans   *PlanService
+	tenants *tenant.Registry
+	// passwords checks the passwords of protected links
+	passwords *security.PasswordHasher
+	settings  atomic.Pointer[Settings]
+	clicks    clickBuffer
+	links     convertedLinks
+	// queue, stream and sinks, when set, take clicks; see click_queue.go
+	// and click_stream.go
+	queue  *clickQueue
+	stream ClickStream
+	sinks  []analytics.Sink
+	// stats, when set, caches computed stats; see stats_cache.go
+	stats *StatsCaching
+	// leases, when set, count redirects against rate limits in batches;
+	// see rate_limits.go
+	leases *redirectLeases
+	// tenantClicks and tenantLinks feed Metrics; see tenant_metrics.go
+	tenantClicks tenantCounter
+	tenantLinks  tenantCounter
+}
+
+func NewResolver(storage storage.LinkStorage, cache cache.LinkCacheInterface, logger *logging.Logger) *Resolver {
+	s := &Resolver{storage: storage, cache: cache, logger: logger}
+	s.passwords, _ = security.NewPasswordHasher(security.Argon2id)
+	s.ApplySettings(DefaultSettings())
+	return s
+}
+
+// UsePasswordHasher replaces the default Argon2id password hasher
+func (s *Resolver) UsePasswordHasher(hasher *security.PasswordHasher) {
+	s.passwords = hasher
+}
+
+// VerifyPassword checks password against the protected link code and
+// returns the link it unlocks, moving its hash to the current algorithm
+// when it matches
+func (s *Resolver) VerifyPassword(ctx context.Context, code, password string) (*storage.Link, error) {
+	link, err := s.storage.GetByCode(ctx, linkKey(ctx, code))
+	if err != nil {
+		return nil, err
+	}
+	if link == nil || link.PasswordHash == nil {
+		return nil, errors.New("no password set")
+	}
+	ok, rehash, err := s.passwords.Verify(*link.PasswordHash, password)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrWrongPassword
+	}
+
+	// Move hashes from older algorithms or costs forward while we have the
+	// plaintext; a failure only delays the upgrade to the next visit
+	if rehash {
+		if hash, err := s.passwords.Hash(password); err == nil {
+			err = s.storage.ReplacePasswordHash(ctx, link.Key(), *link.PasswordHash, hash)
+			if err != nil {
+				s.logger.Warn(ctx, "failed to upgrade password hash", "code", code, "error", err)
+			}
+		}
+	}
+	return link, nil
+}
+
+// ApplySettings swaps in new reloadable settings; safe for concurrent use
+func (s *Resolver) ApplySettings(settings Settings) {
+	blocked := make([]string, 0, len(settings.BlockedDomains))
+	for _, d := range settings.BlockedDomains {
+		blocked = append(blocked, strings.ToLower(strings.TrimPrefix(d, ".")))
+	}
+	settings.BlockedDomains = blocked
+	domains := make([]string, 0, len(settings.ShortDomains))
+	for _, d := range settings.ShortDomains {
+		domains = append(domains, strings.ToLower(d))
+	}
+	settings.ShortDomains = domains
+	shorteners := make([]string, 0, len(settings.ShortenerDomains))
+	for _, d := range settings.ShortenerDomains {
+		shorteners = append(shorteners, strings.ToLower(strings.TrimPrefix(d, ".")))
+	}
+	settings.ShortenerDomains = shorteners
+	s.settings.Store(&settings)
+}
+
+// UseTenants confines each tenant's links to its own short domains
+func (s *Resolver) UseTenants(tenants *tenant.Registry) {
+	s.tenants = tenants
+}
+
+// UseStatsCache serves public stats, and the digests and campaign stats of
+// services built on this one, through stats
+func (s *Resolver) UseStatsCache(stats *StatsCaching) {
+	s.stats = stats
+}
+
+func (s *Resolver) currentSettings() Settings {
+	if settings := s.settings.Load(); settings != nil {
+		return *settings
+	}
+	return DefaultSettings()
+}
+
+func (s *Resolver) GetLink(ctx context.Context, code string) (*storage.Link, error) {
+	key := linkKey(ctx, code)
+
+	// Try cache first
+	cached, err := s.cache.Get(ctx, key)
+	// The password hash isn't cached, so protected links are read from the
+	// DB; returning them without it would skip the password check
+	if err == nil && cached != nil && !cached.HasPassword {
+		if cached.LongURL == "" {
+			// Negative entry for a code that doesn't exist
+			return nil, nil
+		}
+		// Check if cached link is expired
+		if cached.ExpiresAt != nil && time.Now().After(*cached.ExpiresAt) {
+			// Expired in cache, delete and fall through to DB
+			s.cache.Delete(ctx, key)
+		} else {
+			// Valid cached link; the password hash is never cached
+			return s.fromCache(key, cached), nil
+		}
+	}
+
+	// Cache miss or expired, get from DB
+	link, err := s.storage.GetByCode(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if link == nil {
+		// Cache negative result briefly
+		s.cache.Set(ctx, key, &cache.CachedLink{
+			LongURL:     "",
+			HasPassword: false,
+			ExpiresAt:   nil,
+			MaxClicks:   nil,
+		}, s.currentSettings().NegativeCacheTTL)
+		return nil, nil
+	}
+
+	link.Networks = parseNetworks(link)
+	s.cacheLink(ctx, link)
+	return link, nil
+}
+
+// cacheLink stores link in the cache until it expires or the cache TTL ends.
+// If that fails the entry is deleted instead, so that an older version or a
+// negative entry isn't left behind.
+func (s *Resolver) cacheLink(ctx context.Context, link *storage.Link) {
+	ttl := s.currentSettings().LinkCacheTTL
+	if link.ExpiresAt != nil {
+		remaining := time.Until(*link.ExpiresAt)
+		if remaining > 0 && remaining < ttl {
+			ttl = remaining
+		}
+	}
+
+	cachedLink := &cache.CachedLink{
+		LongURL:      link.LongURL,
+		HasPassword:  link.PasswordHash != nil,
+		ExpiresAt:    link.ExpiresAt,
+		MaxClicks:    link.MaxClicks,
+		RedirectType: link.RedirectType,
+		OwnerID:      link.OwnerID,
+
+		ExcludeCIDRs:      link.ExcludeCIDRs,
+		ExcludeUserAgents: link.ExcludeUserAgents,
+		AllowCIDRs:        link.AllowCIDRs,
+		DenyCIDRs:         link.DenyCIDRs,
+		Disabled:          link.Disabled,
+		TenantID:          link.TenantID,
+		Description:       link.Description,
+		Metadata:          link.Metadata,
+		ParamRules:        link.ParamRules,
+		RateLimit:         link.RateLimit,
+		RateLimitWindow:   link.RateLimitWindow,
+		NoIndex:           link.NoIndex,
+	}
+	if err := s.cache.Set(ctx, link.Key(), cachedLink, ttl); err != nil {
+		s.cache.Delete(ctx, link.Key())
+	}
+}
+
+// maxConvertedLinks bounds the links a Resolver keeps converted; when full it
+// starts over
+const maxConvertedLinks = 10000
+
+// convertedLinks remembers the link built from each CachedLink, keyed by
+// link key, so that a cache handing out the same CachedLink for an
+// unchanged entry, as *cache.LinkCache does, doesn't have it converted on
+// every hit
+type convertedLinks struct {
+	mu    sync.RWMutex
+	links map[string]convertedLink
+}
+
+type convertedLink struct {
+	cached *cache.CachedLink
+	link   *storage.Link
+}
+
+// fromCache returns the link cached under key. Each call returns a copy, so
+// callers may set its fields, but slices and maps are shared and must not be
+// modified in place.
+func (s *Resolver) fromCache(key string, cached *cache.CachedLink) *storage.Link {
+	s.links.mu.RLock()
+	hit, ok := s.links.links[key]
+	s.links.mu.RUnlock()
+	if !ok || hit.cached != cached {
+		hit = convertedLink{cached: cached, link: convertCached(key, cached)}
+		s.links.mu.Lock()
+		if s.links.links == nil || len(s.links.links) >= maxConvertedLinks {
+			s.links.links = make(map[string]convertedLink)
+		}
+		s.links.links[key] = hit
+		s.links.mu.Unlock()
+	}
+	link := *hit.link
+	return &link
+}
+
+// convertCached rebuilds the link cached under key, with its CIDR lists
+// parsed
+func convertCached(key string, cached *cache.CachedLink) *storage.Link {
+	link := &storage.Link{
+		LongURL:      cached.LongURL,
+		ExpiresAt:    cached.ExpiresAt,
+		MaxClicks:    cached.MaxClicks,
+		RedirectType: cached.RedirectType,
+		OwnerID:      cached.OwnerID,
+
+		ExcludeCIDRs:      cached.ExcludeCIDRs,
+		ExcludeUserAgents: cached.ExcludeUserAgents,
+		AllowCIDRs:        cached.AllowCIDRs,
+		DenyCIDRs:         cached.DenyCIDRs,
+		Disabled:          cached.Disabled,
+		TenantID:          cached.TenantID,
+		Description:       cached.Description,
+		Metadata:          cached.Metadata,
+		ParamRules:        cached.ParamRules,
+		RateLimit:         cached.RateLimit,
+		RateLimitWindow:   cached.RateLimitWindow,
+		NoIndex:           cached.NoIndex,
+	}
+	link.Networks = parseNetworks(link)
+	domain, code := storage.SplitLinkKey(key)
+	link.Code = code
+	if domain != "" {
+		link.Domain = &domain
+	}
+	return link
+}
+
+// ResolveLink returns the destination for code, enforcing the same expiry and
+// password rules as the redirect endpoint. When countClick is set the
+// resolution is recorded as a click.
+func (s *Resolver) ResolveLink(ctx context.Context, code string, countClick bool) (*storage.Link, error) {
+	link, err := s.GetLink(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	if link == nil {
+		return nil, ErrLinkNotFound
+	}
+	if s.IsExpired(link) {
+		return nil, ErrLinkExpired
+	}
+	if link.Disabled {
+		return nil, ErrLinkDisabled
+	}
+	if link.PasswordHash != nil {
+		return nil, ErrPasswordRequired
+	}
+
+	if countClick {
+		if err := s.RecordClick(ctx, link, Visit{}); err != nil {
+			s.logger.Warn(ctx, "failed to count click", "code", code, "error", err)
+		}
+	}
+	return link, nil
+}
+
+func (s *Resolver) IsExpired(link *storage.Link) bool {
+	if link.ExpiresAt != nil && time.Now().After(*link.ExpiresAt) {
+		return true
+	}
+	if link.MaxClicks != nil && link.ClickCount >= *link.MaxClicks {
+		return true
+	}
+	return false
+}
+
+// IncrementClickCount counts a click on link in place, for RecordClick. The
+// stored count is updated in batches by RunClickSync; see clicks.go.
+func (s *Resolver) IncrementClickCount(ctx context.Context, link *storage.Link) error {
+	code := link.Key()
+	if err := s.cache.IncrementClick(ctx, code); err != nil {
+		// Kept in memory and saved by this server's next sync
+		s.clicks.add(code, 1)
+		return err
+	}
+
+	// Per-day counts feed digests and the leaderboards rank links; losing a
+	// click from either is not worth failing it
+	now := time.Now()
+	if err := s.cache.IncrementDailyClick(ctx, code, now); err != nil {
+		s.logger.Warn(ctx, "failed to count daily click", "code", code, "error", err)
+	}
+	if err := s.cache.RecordTopClick(ctx, code, link.OwnerID, now); err != nil {
+		s.logger.Warn(ctx, "failed to rank click", "code", code, "error", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type resolverLinks struct {
+	clickCounts
+}
+
+func (l *resolverLinks) GetByCode(ctx context.Context, code string) (*storage.Link, error) {
+	if code != "abc" {
+		return nil, nil
+	}
+	return &storage.Link{Code: code, LongURL: "https://example.com/abc"}, nil
+}
+
+type resolverCache struct {
+	clickCache
+	sets int
+}
+
+func (c *resolverCache) Get(ctx context.Context, code string) (*cache.CachedLink, error) {
+	return nil, nil
+}
+
+func (c *resolverCache) Set(ctx context.Context, code string, link *cache.CachedLink, ttl time.Duration) error {
+	c.sets++
+	return nil
+}
+
+func TestResolverWithoutLinkService(t *testing.T) {
+	ctx := context.Background()
+	links := &resolverLinks{clickCounts{counts: map[string]int64{}}}
+	linkCache := &resolverCache{clickCache: clickCache{deltas: map[string]int64{}}}
+	r := NewResolver(links, linkCache, logging.NewLogger(logging.LevelError))
+
+	link, err := r.ResolveLink(ctx, "abc", true)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/abc", link.LongURL)
+	assert.Equal(t, 1, linkCache.sets)
+
+	_, err = r.ResolveLink(ctx, "xyz", true)
+	assert.ErrorIs(t, err, ErrLinkNotFound)
+
+	assert.NoError(t, r.FlushClicks(ctx))
+	assert.Equal(t, map[string]int64{"abc": 1}, links.counts)
+}
+
+func TestResolverUnicodeShortURL(t *testing.T) {
+	resolver := NewResolver(&resolverLinks{}, &resolverCache{}, logging.NewLogger(logging.LevelError))
+	assert.Equal(t, "http://localhost:8081/r/caf%C3%A9", resolver.ShortURL("café"))
+
+	domain := "bücher.example"
+	assert.Equal(t, "https://xn--bcher-kva.example/r/%F0%9F%9A%80", resolver.shortURLFor("🚀", &domain))
+}
+
+// sharedCache hands out the same CachedLink until it is replaced, as
+// *cache.LinkCache does for an unchanged entry
+type sharedCache struct {
+	clickCache
+	link *cache.CachedLink
+}
+
+func (c *sharedCache) Get(ctx context.Context, code string) (*cache.CachedLink, error) {
+	return c.link, nil
+}
+
+func TestResolverReusesConvertedLinks(t *testing.T) {
+	ctx := context.Background()
+	linkCache := &sharedCache{link: &cache.CachedLink{LongURL: "https://example.com/a"}}
+	r := NewResolver(nil, linkCache, logging.NewLogger(logging.LevelError))
+
+	first, err := r.GetLink(ctx, "abc")
+	require.NoError(t, err)
+	first.LongURL = "https://changed.example/"
+	second, err := r.GetLink(ctx, "abc")
+	require.NoError(t, err)
+	// Each caller gets its own copy
+	assert.Equal(t, "https://example.com/a", second.LongURL)
+	assert.Equal(t, "abc", second.Code)
+
+	linkCache.link = &cache.CachedLink{LongURL: "https://example.com/b"}
+	third, err := r.GetLink(ctx, "abc")
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/b", third.LongURL)
+}
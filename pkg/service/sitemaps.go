@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+)
+
+// Short domains can opt into a sitemap listing their public links, for
+// brands that use permanent redirects to pass search ranking on to their
+// destinations. Each redirect server keeps the sitemaps in memory. Every TTL
+// it reads the links written and the codes retired since its last refresh,
+// and once a day it rebuilds them from scratch, which also catches links
+// disabled with their account, as that isn't a write to the link.
+
+const (
+	// sitemapPageSize is the most URLs a sitemap may list; larger ones are
+	// split into pages behind a sitemap index
+	sitemapPageSize = 50000
+	// sitemapOverlap is how far before the last refresh changes are read
+	// again, so that writes committed late or on servers with clock skew
+	// aren't missed
+	sitemapOverlap = 5 * time.Minute
+	// sitemapRebuildInterval is how often sitemaps are read in full
+	sitemapRebuildInterval = 24 * time.Hour
+	sitemapXMLNS           = "http://www.sitemaps.org/schemas/sitemap/0.9"
+)
+
+var ErrSitemapNotFound = errors.New("sitemap not found")
+
+// Sitemaps serves the sitemaps of the short domains that have one
+type Sitemaps struct {
+	storage  storage.SitemapStorage
+	ttl      time.Duration
+	pageSize int
+	logger   *logging.Logger
+	// domains is only read after NewSitemaps
+	domains map[string]*sitemap
+}
+
+// sitemap is one domain's listed links, by code, and its rendered pages
+type sitemap struct {
+	mu        sync.Mutex
+	links     map[string]sitemapLink
+	refreshed time.Time
+	rebuilt   time.Time
+	pages     [][]byte
+	index     []byte
+}
+
+type sitemapLink struct {
+	lastMod   time.Time
+	expiresAt *time.Time
+}
+
+func NewSitemaps(storage storage.SitemapStorage, domains []string, ttl time.Duration, logger *logging.Logger) *Sitemaps {
+	s := &Sitemaps{storage: storage, ttl: ttl, pageSize: sitemapPageSize, logger: logger, domains: make(map[string]*sitemap)}
+	for _, domain := range domains {
+		s.domains[strings.ToLower(domain)] = &sitemap{}
+	}
+	return s
+}
+
+// Has reports whether domain has a sitemap
+func (s *Sitemaps) Has(domain string) bool {
+	_, ok := s.domains[domain]
+	return ok
+}
+
+// URL returns the address of domain's sitemap
+func (s *Sitemaps) URL(domain string) string {
+	return "https://" + asciiHost(domain) + "/sitemap.xml"
+}
+
+// Sitemap returns domain's sitemap as XML. Page 0 is the sitemap itself,
+// which is an index of the numbered pages when the links don't fit in one.
+// If a refresh fails, the sitemap last built is served.
+func (s *Sitemaps) Sitemap(ctx context.Context, domain string, page int) ([]byte, error) {
+	m, ok := s.domains[domain]
+	if !ok {
+		return nil, ErrSitemapNotFound
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now := time.Now(); m.pages == nil || now.Sub(m.refreshed) >= s.ttl {
+		if err := s.refresh(ctx, domain, m, now); err != nil {
+			if m.pages == nil {
+				return nil, err
+			}
+			s.logger.Warn(ctx, "failed to refresh sitemap", "domain", domain, "error", err)
+		}
+	}
+	switch {
+	case page == 0 && m.index != nil:
+		return m.index, nil
+	case page == 0:
+		return m.pages[0], nil
+	case m.index != nil && page <= len(m.pages):
+		return m.pages[page-1], nil
+	}
+	return nil, ErrSitemapNotFound
+}
+
+// refresh applies the changes to m's links since its last refresh, or all of
+// them when a rebuild is due, and renders it again
+func (s *Sitemaps) refresh(ctx context.Context, domain string, m *sitemap, now time.Time) error {
+	full := now.Sub(m.rebuilt) >= sitemapRebuildInterval
+	var since time.Time
+	var retired []string
+	if !full {
+		since = m.refreshed.Add(-sitemapOverlap)
+		var err error
+		if retired, err = s.storage.ListRetiredCodes(ctx, domain, since); err != nil {
+			return err
+		}
+	}
+	changed, err := s.storage.ListSitemapLinks(ctx, domain, since)
+	if err != nil {
+		return err
+	}
+
+	links := m.links
+	if full || links == nil {
+		links = make(map[string]sitemapLink)
+	}
+	// A retired code may have a new link since, which comes after
+	for _, code := range retired {
+		delete(links, code)
+	}
+	for _, link := range changed {
+		if link.Listed {
+			links[link.Code] = sitemapLink{lastMod: link.UpdatedAt, expiresAt: link.ExpiresAt}
+		} else {
+			delete(links, link.Code)
+		}
+	}
+
+	pages, index, err := s.render(domain, links, now)
+	if err != nil {
+		return err
+	}
+	m.links, m.pages, m.index = links, pages, index
+	m.refreshed = now
+	if full {
+		m.rebuilt = now
+	}
+	return nil
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name         `xml:"urlset"`
+	XMLNS   string           `xml:"xmlns,attr"`
+	URLs    []sitemapElement `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name         `xml:"sitemapindex"`
+	XMLNS    string           `xml:"xmlns,attr"`
+	Sitemaps []sitemapElement `xml:"sitemap"`
+}
+
+type sitemapElement struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// render lists the unexpired links by code, in pages of at most pageSize,
+// with an index of the pages if there is more than one
+func (s *Sitemaps) render(domain string, links map[string]sitemapLink, now time.Time) ([][]byte, []byte, error) {
+	codes := make([]string, 0, len(links))
+	for code, link := range links {
+		if link.expiresAt == nil || link.expiresAt.After(now) {
+			codes = append(codes, code)
+		}
+	}
+	slices.Sort(codes)
+
+	base := "https://" + asciiHost(domain) + "/r/"
+	var pages [][]byte
+	var index []sitemapElement
+	for start := 0; start == 0 || start < len(codes); start += s.pageSize {
+		set := sitemapURLSet{XMLNS: sitemapXMLNS}
+		var lastMod time.Time
+		for _, code := range codes[start:min(start+s.pageSize, len(codes))] {
+			link := links[code]
+			set.URLs = append(set.URLs, sitemapElement{Loc: base + url.PathEscape(code), LastMod: link.lastMod.UTC().Format(time.RFC3339)})
+			if link.lastMod.After(lastMod) {
+				lastMod = link.lastMod
+			}
+		}
+		page, err := marshalSitemap(set)
+		if err != nil {
+			return nil, nil, err
+		}
+		pages = append(pages, page)
+		index = append(index, sitemapElement{Loc: s.URL(domain) + "?page=" + strconv.Itoa(len(pages)), LastMod: lastMod.UTC().Format(time.RFC3339)})
+	}
+	if len(pages) == 1 {
+		return pages, nil, nil
+	}
+	page, err := marshalSitemap(sitemapIndex{XMLNS: sitemapXMLNS, Sitemaps: index})
+	return pages, page, err
+}
+
+func marshalSitemap(v any) ([]byte, error) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), data...), nil
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSitemapStorage struct {
+	links   []*storage.SitemapLink
+	retired []string
+	since   []time.Time
+}
+
+func (f *fakeSitemapStorage) ListSitemapLinks(ctx context.Context, domain string, since time.Time) ([]*storage.SitemapLink, error) {
+	f.since = append(f.since, since)
+	var links []*storage.SitemapLink
+	for _, link := range f.links {
+		if link.UpdatedAt.After(since) {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+func (f *fakeSitemapStorage) ListRetiredCodes(ctx context.Context, domain string, since time.Time) ([]string, error) {
+	return f.retired, nil
+}
+
+func TestSitemap(t *testing.T) {
+	written := time.Now().Add(-time.Hour)
+	past := time.Now().Add(-time.Minute)
+	fake := &fakeSitemapStorage{links: []*storage.SitemapLink{
+		{Code: "spring", UpdatedAt: written, Listed: true},
+		{Code: "launch", UpdatedAt: written, Listed: true},
+		{Code: "secret", UpdatedAt: written},
+		{Code: "gone", UpdatedAt: written, ExpiresAt: &past, Listed: true},
+	}}
+	s := NewSitemaps(fake, []string{"Go.Example.com"}, time.Hour, logging.NewLogger(logging.LevelError))
+	ctx := context.Background()
+
+	data, err := s.Sitemap(ctx, "go.example.com", 0)
+	require.NoError(t, err)
+	body := string(data)
+	assert.Contains(t, body, `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`)
+	assert.Less(t, strings.Index(body, "/r/launch"), strings.Index(body, "/r/spring"))
+	assert.NotContains(t, body, "secret")
+	assert.NotContains(t, body, "gone")
+
+	_, err = s.Sitemap(ctx, "other.example.com", 0)
+	assert.ErrorIs(t, err, ErrSitemapNotFound)
+	_, err = s.Sitemap(ctx, "go.example.com", 1)
+	assert.ErrorIs(t, err, ErrSitemapNotFound, "a sitemap of one page has no numbered pages")
+
+	// Refreshes only read changes, from a little before the last one
+	m := s.domains["go.example.com"]
+	m.refreshed = m.refreshed.Add(-2 * time.Hour)
+	last := m.refreshed
+	// launch was set to noindex, and spring deleted
+	fake.links = []*storage.SitemapLink{{Code: "launch", UpdatedAt: time.Now()}}
+	fake.retired = []string{"spring"}
+	data, err = s.Sitemap(ctx, "go.example.com", 0)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "/r/launch")
+	assert.NotContains(t, string(data), "/r/spring")
+	require.Len(t, fake.since, 2)
+	assert.True(t, fake.since[0].IsZero())
+	assert.Equal(t, last.Add(-sitemapOverlap), fake.since[1])
+}
+
+func TestSitemapPages(t *testing.T) {
+	fake := &fakeSitemapStorage{}
+	for _, code := range []string{"a", "b", "c"} {
+		fake.links = append(fake.links, &storage.SitemapLink{Code: code, UpdatedAt: time.Now(), Listed: true})
+	}
+	s := NewSitemaps(fake, []string{"go.example.com"}, time.Hour, logging.NewLogger(logging.LevelError))
+	s.pageSize = 2
+	ctx := context.Background()
+
+	data, err := s.Sitemap(ctx, "go.example.com", 0)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "<sitemapindex")
+	assert.Contains(t, string(data), "<loc>https://go.example.com/sitemap.xml?page=2</loc>")
+
+	data, err = s.Sitemap(ctx, "go.example.com", 2)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "<loc>https://go.example.com/r/c</loc>")
+	_, err = s.Sitemap(ctx, "go.example.com", 3)
+	assert.ErrorIs(t, err, ErrSitemapNotFound)
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/logging"
+)
+
+// statsRefreshTimeout bounds recomputing stale stats in the background
+const statsRefreshTimeout = 30 * time.Second
+
+// StatsCaching serves computed stats from a cache. Stats computed less than
+// fresh ago are served as they are. For stale ago after that they are still
+// served, while one request, across all servers, recomputes them in the
+// background; later ones are computed on the spot. Cache errors are logged
+// and the stats computed as if nothing was cached.
+type StatsCaching struct {
+	cache  cache.StatsCacheInterface
+	fresh  time.Duration
+	stale  time.Duration
+	logger *logging.Logger
+}
+
+func NewStatsCaching(cache cache.StatsCacheInterface, fresh, stale time.Duration, logger *logging.Logger) *StatsCaching {
+	return &StatsCaching{cache: cache, fresh: fresh, stale: stale, logger: logger}
+}
+
+// cachedStats returns the stats cached under key, computing them with
+// compute when they aren't cached or, in the background, once they are
+// stale. Without caching, stats are always computed.
+func cachedStats[T any](ctx context.Context, c *StatsCaching, key string, compute func(context.Context) (*T, error)) (*T, error) {
+	if c == nil {
+		return compute(ctx)
+	}
+	entry, err := c.cache.GetStats(ctx, key)
+	if err != nil {
+		c.logger.Warn(ctx, "failed to read cached stats", "key", key, "error", err)
+	}
+	if entry != nil {
+		var stats T
+		if err := json.Unmarshal(entry.Data, &stats); err == nil {
+			if time.Since(entry.ComputedAt) >= c.fresh && c.claim(ctx, key) {
+				go func() {
+					ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), statsRefreshTimeout)
+					defer cancel()
+					if stats, err := compute(ctx); err != nil {
+						c.logger.Warn(ctx, "failed to refresh cached stats", "key", key, "error", err)
+					} else {
+						c.store(ctx, key, stats)
+					}
+				}()
+			}
+			return &stats, nil
+		}
+	}
+
+	stats, err := compute(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.store(ctx, key, stats)
+	return stats, nil
+}
+
+// claim reports whether this request is the one to refresh key
+func (c *StatsCaching) claim(ctx context.Context, key string) bool {
+	ok, err := c.cache.ClaimStatsRefresh(ctx, key, statsRefreshTimeout)
+	if err != nil {
+		c.logger.Warn(ctx, "failed to claim stats refresh", "key", key, "error", err)
+	}
+	return ok
+}
+
+func (c *StatsCaching) store(ctx context.Context, key string, stats any) {
+	data, err := json.Marshal(stats)
+	if err == nil {
+		err = c.cache.SetStats(ctx, key, &cache.StatsEntry{Data: data, ComputedAt: time.Now()}, c.fresh+c.stale)
+	}
+	if err != nil {
+		c.logger.Warn(ctx, "failed to cache stats", "key", key, "error", err)
+	}
+}
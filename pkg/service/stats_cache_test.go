@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/logging"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type memoryStatsCache struct {
+	mu      sync.Mutex
+	entries map[string]*cache.StatsEntry
+	claimed map[string]bool
+	stored  chan string
+}
+
+func newMemoryStatsCache() *memoryStatsCache {
+	return &memoryStatsCache{
+		entries: map[string]*cache.StatsEntry{},
+		claimed: map[string]bool{},
+		stored:  make(chan string, 10),
+	}
+}
+
+func (c *memoryStatsCache) GetStats(ctx context.Context, key string) (*cache.StatsEntry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[key], nil
+}
+
+func (c *memoryStatsCache) SetStats(ctx context.Context, key string, entry *cache.StatsEntry, ttl time.Duration) error {
+	c.mu.Lock()
+	c.entries[key] = entry
+	delete(c.claimed, key)
+	c.mu.Unlock()
+	c.stored <- key
+	return nil
+}
+
+func (c *memoryStatsCache) ClaimStatsRefresh(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.claimed[key] {
+		return false, nil
+	}
+	c.claimed[key] = true
+	return true, nil
+}
+
+type countedStats struct {
+	N int `json:"n"`
+}
+
+func TestCachedStats(t *testing.T) {
+	ctx := context.Background()
+	statsCache := newMemoryStatsCache()
+	caching := NewStatsCaching(statsCache, time.Minute, 10*time.Minute, logging.NewLogger(logging.LevelError))
+	var computed int
+	compute := func(ctx context.Context) (*countedStats, error) {
+		computed++
+		return &countedStats{N: computed}, nil
+	}
+
+	stats, err := cachedStats(ctx, caching, "k", compute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.N)
+	<-statsCache.stored
+
+	stats, err = cachedStats(ctx, caching, "k", compute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.N, "fresh stats are served from the cache")
+	assert.Equal(t, 1, computed)
+
+	// Stale stats are served while one request recomputes them
+	statsCache.entries["k"].ComputedAt = time.Now().Add(-2 * time.Minute)
+	stats, err = cachedStats(ctx, caching, "k", compute)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.N)
+	<-statsCache.stored
+	assert.Equal(t, 2, computed)
+
+	stats, err = cachedStats(ctx, caching, "k", compute)
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.N)
+}
+
+func TestCachedStatsSingleRefresh(t *testing.T) {
+	ctx := context.Background()
+	statsCache := newMemoryStatsCache()
+	statsCache.entries["k"] = &cache.StatsEntry{Data: []byte(`{"n":1}`), ComputedAt: time.Now().Add(-2 * time.Minute)}
+	statsCache.claimed["k"] = true
+	caching := NewStatsCaching(statsCache, time.Minute, 10*time.Minute, logging.NewLogger(logging.LevelError))
+
+	stats, err := cachedStats(ctx, caching, "k", func(ctx context.Context) (*countedStats, error) {
+		t.Error("stats being refreshed elsewhere are not recomputed")
+		return nil, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.N)
+}
+
+func TestCachedStatsErrors(t *testing.T) {
+	ctx := context.Background()
+	statsCache := newMemoryStatsCache()
+	caching := NewStatsCaching(statsCache, time.Minute, 10*time.Minute, logging.NewLogger(logging.LevelError))
+	failed := errors.New("rollup failed")
+
+	_, err := cachedStats(ctx, caching, "k", func(ctx context.Context) (*countedStats, error) {
+		return nil, failed
+	})
+	assert.ErrorIs(t, err, failed)
+	assert.Empty(t, statsCache.entries, "errors aren't cached")
+
+	stats, err := cachedStats(ctx, nil, "k", func(ctx context.Context) (*countedStats, error) {
+		return &countedStats{N: 3}, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, stats.N)
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"sync"
+
+	"url-shortener/pkg/metrics"
+)
+
+// tenantCounter counts events by tenant for metrics
+type tenantCounter struct {
+	mu     sync.Mutex
+	counts map[string]int64
+}
+
+func (c *tenantCounter) add(tenantID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.counts == nil {
+		c.counts = make(map[string]int64)
+	}
+	c.counts[tenantID]++
+}
+
+// set records each tenant's count as a series of name labelled with it
+func (c *tenantCounter) set(r *metrics.Registry, name, help string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for tenantID, n := range c.counts {
+		r.SetLabeled(name, metrics.Counter, help, metrics.Labels{"tenant": tenantID}, float64(n))
+	}
+}
+
+// Metrics samples the clicks counted and links created by this process,
+// labelled with the tenant of the link, "" for the default tenant
+func (s *Resolver) Metrics(r *metrics.Registry) {
+	s.tenantClicks.set(r, "link_clicks_total", "Clicks counted on links, by the link's tenant.")
+	s.tenantLinks.set(r, "links_created_total", "Links created, by the tenant they were created in.")
+}
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/metrics"
+	"url-shortener/pkg/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsCountClicksByTenant(t *testing.T) {
+	s := NewResolver(nil, nil, logging.NewLogger(logging.LevelError))
+	s.UseClickStream(&memStream{})
+	ctx := context.Background()
+	for _, tenantID := range []string{"acme", "acme", ""} {
+		require.NoError(t, s.RecordClick(ctx, &storage.Link{Code: "abc", TenantID: tenantID}, Visit{}))
+	}
+
+	registry := metrics.NewRegistry()
+	registry.Add(s.Metrics)
+	registry.Sample()
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, rec.Body.String(), "link_clicks_total{tenant=\"\"} 1\nlink_clicks_total{tenant=\"acme\"} 2\n")
+	assert.NotContains(t, rec.Body.String(), "links_created_total", "no links were created")
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrTermsNotAccepted is returned when an owner who hasn't accepted the
+	// current terms of service creates a link
+	ErrTermsNotAccepted = errors.New("the current terms of service must be accepted first")
+	ErrTermsVersion     = errors.New("version is not the current terms of service")
+)
+
+// TermsService tracks which terms of service version each owner accepted.
+// Publishing a new version makes everyone accept it again before they can
+// create links.
+type TermsService struct {
+	storage storage.TermsStorage
+	version string
+	url     string
+}
+
+// NewTermsService requires acceptance of version, whose text is at url
+func NewTermsService(storage storage.TermsStorage, version, url string) *TermsService {
+	return &TermsService{
+		storage: storage,
+		version: version,
+		url:     url,
+	}
+}
+
+// TermsStatus is an owner's acceptance of the terms of service
+type TermsStatus struct {
+	CurrentVersion  string     `json:"current_version"`
+	URL             string     `json:"url,omitempty"`
+	AcceptedVersion *string    `json:"accepted_version,omitempty"`
+	AcceptedAt      *time.Time `json:"accepted_at,omitempty"`
+	// Required is set until the current version is accepted
+	Required bool `json:"acceptance_required"`
+}
+
+type AcceptTermsRequest struct {
+	Version string `json:"version"`
+}
+
+// GetStatus returns the caller's acceptance state
+func (s *TermsService) GetStatus(ctx context.Context) (*TermsStatus, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+	return s.StatusOf(ctx, ownerID)
+}
+
+// StatusOf returns ownerID's acceptance state
+func (s *TermsService) StatusOf(ctx context.Context, ownerID uuid.UUID) (*TermsStatus, error) {
+	acceptance, err := s.storage.GetTermsAcceptance(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	status := &TermsStatus{CurrentVersion: s.version, URL: s.url, Required: true}
+	if acceptance != nil {
+		status.AcceptedVersion = &acceptance.Version
+		status.AcceptedAt = &acceptance.AcceptedAt
+		status.Required = acceptance.Version != s.version
+	}
+	return status, nil
+}
+
+// Accept records that the caller accepted the terms. Only the current
+// version can be accepted, so clients send the version they showed.
+func (s *TermsService) Accept(ctx context.Context, req *AcceptTermsRequest) (*TermsStatus, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+	if req.Version != s.version {
+		return nil, ErrTermsVersion
+	}
+	err := s.storage.AcceptTerms(ctx, &storage.TermsAcceptance{
+		OwnerID:    ownerID,
+		Version:    s.version,
+		AcceptedAt: time.Now().UTC().Truncate(time.Second),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.StatusOf(ctx, ownerID)
+}
+
+// checkAccepted reports ErrTermsNotAccepted unless ownerID accepted the
+// current version
+func (s *TermsService) checkAccepted(ctx context.Context, ownerID uuid.UUID) error {
+	status, err := s.StatusOf(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	if status.Required {
+		return ErrTermsNotAccepted
+	}
+	return nil
+}
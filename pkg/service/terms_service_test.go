@@ -0,0 +1,56 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeTermsStorage struct {
+	accepted map[uuid.UUID]*storage.TermsAcceptance
+}
+
+func (f *fakeTermsStorage) AcceptTerms(ctx context.Context, a *storage.TermsAcceptance) error {
+	f.accepted[a.OwnerID] = a
+	return nil
+}
+
+func (f *fakeTermsStorage) GetTermsAcceptance(ctx context.Context, ownerID uuid.UUID) (*storage.TermsAcceptance, error) {
+	return f.accepted[ownerID], nil
+}
+
+func TestTermsAcceptance(t *testing.T) {
+	ownerID := uuid.New()
+	terms := &fakeTermsStorage{accepted: map[uuid.UUID]*storage.TermsAcceptance{}}
+	svc := NewTermsService(terms, "2026-01", "https://example.com/terms")
+	ctx := middleware.WithOwnerID(context.Background(), ownerID)
+
+	status, err := svc.GetStatus(ctx)
+	require.NoError(t, err)
+	assert.True(t, status.Required)
+	assert.Nil(t, status.AcceptedVersion)
+	assert.ErrorIs(t, svc.checkAccepted(ctx, ownerID), ErrTermsNotAccepted)
+
+	// Clients accept the version they showed, not whatever is current
+	_, err = svc.Accept(ctx, &AcceptTermsRequest{Version: "2025-06"})
+	assert.ErrorIs(t, err, ErrTermsVersion)
+
+	status, err = svc.Accept(ctx, &AcceptTermsRequest{Version: "2026-01"})
+	require.NoError(t, err)
+	assert.False(t, status.Required)
+	assert.Equal(t, "2026-01", *status.AcceptedVersion)
+	assert.NoError(t, svc.checkAccepted(ctx, ownerID))
+
+	// Publishing a new version asks again
+	svc = NewTermsService(terms, "2026-07", "")
+	status, err = svc.GetStatus(ctx)
+	require.NoError(t, err)
+	assert.True(t, status.Required)
+	assert.Equal(t, "2026-01", *status.AcceptedVersion)
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Unicode aliases may use letters and digits of any script, and emoji, as
+// well as the ASCII ones. Aliases are stored and looked up in NFC, so the
+// same text typed on different systems finds the same link. Aliases that
+// could pass for another are refused: letters of different scripts can't be
+// mixed, so "pаypal" with a Cyrillic "а" is rejected, and Cyrillic or Greek
+// aliases made only of letters that look Latin are too.
+
+// maxAliasRunes is the most characters an alias may have, as for ASCII ones
+const maxAliasRunes = 50
+
+var unicodeAliases atomic.Bool
+
+// AllowUnicodeAliases lets aliases use letters and digits of any script and
+// emoji instead of only ASCII ones
+func AllowUnicodeAliases(allow bool) {
+	unicodeAliases.Store(allow)
+}
+
+// NormalizeAlias returns alias, or any code, in the NFC form links are
+// stored under
+func NormalizeAlias(alias string) string {
+	return norm.NFC.String(alias)
+}
+
+// latinLookalikes are Cyrillic and Greek letters that render like Latin ones
+var latinLookalikes = map[rune]bool{}
+
+func init() {
+	for _, r := range "аеорсухіјѕԁӏһԛԝүвкмнтАВЕКМНОРСТХІЈЅ" + "αικνορτυχΑΒΕΖΗΙΚΜΝΟΡΤΥΧ" {
+		latinLookalikes[r] = true
+	}
+}
+
+// cjkScripts are written together, so aliases may mix them
+var cjkScripts = map[string]bool{"Han": true, "Hiragana": true, "Katakana": true, "Hangul": true}
+
+// scriptOf returns the script of letter r, with the CJK scripts as one, or
+// "" for letters shared by scripts such as the Japanese long vowel mark
+func scriptOf(r rune) string {
+	for name, table := range unicode.Scripts {
+		if !unicode.Is(table, r) {
+			continue
+		}
+		switch {
+		case name == "Common" || name == "Inherited":
+			return ""
+		case cjkScripts[name]:
+			return "CJK"
+		}
+		return name
+	}
+	return ""
+}
+
+// isEmojiPart reports whether r can be part of an emoji: a symbol, or one
+// of the joiners, variation selectors, skin tones and keycaps that combine
+// them
+func isEmojiPart(r rune) bool {
+	switch {
+	case r == '\u200d', r == '\ufe0f', r == '\u20e3':
+		return true
+	case r >= 0x1f3fb && r <= 0x1f3ff:
+		return true
+	}
+	return unicode.Is(unicode.So, r)
+}
+
+// validUnicodeAlias reports whether alias is an acceptable Unicode alias
+func validUnicodeAlias(alias string) bool {
+	if !utf8.ValidString(alias) || !norm.NFC.IsNormalString(alias) {
+		return false
+	}
+	if n := utf8.RuneCountInString(alias); n == 0 || n > maxAliasRunes {
+		return false
+	}
+	if mixesScripts(alias) {
+		return false
+	}
+
+	letters, lookalikes := 0, 0
+	for i, r := range alias {
+		switch {
+		case r == '-' || r == '_' || isEmojiPart(r):
+		case unicode.IsDigit(r):
+		case unicode.IsMark(r):
+			// Combining marks only follow what they combine with
+			if i == 0 {
+				return false
+			}
+		case unicode.IsLetter(r):
+			letters++
+			if latinLookalikes[r] {
+				lookalikes++
+			}
+		default:
+			return false
+		}
+	}
+	// A whole alias of Latin lookalikes passes for the Latin one
+	return letters == 0 || lookalikes < letters
+}
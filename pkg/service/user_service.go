@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUserNotFound     = errors.New("user not found")
+	ErrAlreadySuspended = errors.New("account is already suspended")
+	ErrNotSuspended     = errors.New("account is not suspended")
+	ErrReasonRequired   = errors.New("reason is required")
+)
+
+// UserService lets operators look accounts up and suspend them for abuse
+// and support. Every call is written to the audit log with the operator's
+// sub.
+type UserService struct {
+	storage storage.UserStorage
+	links   *LinkService
+	logger  *logging.Logger
+}
+
+func NewUserService(storage storage.UserStorage, links *LinkService, logger *logging.Logger) *UserService {
+	return &UserService{
+		storage: storage,
+		links:   links,
+		logger:  logger,
+	}
+}
+
+func (s *UserService) GetUser(ctx context.Context, ownerID uuid.UUID) (*storage.User, error) {
+	s.logger.LogAdminAction(ctx, "user.get", middleware.GetSubFromContext(ctx), ownerID.String())
+	user, err := s.storage.GetUser(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// FindUsers returns the accounts whose notification email is email
+func (s *UserService) FindUsers(ctx context.Context, email string) ([]*storage.User, error) {
+	email = strings.TrimSpace(email)
+	s.logger.LogAdminAction(ctx, "user.find", middleware.GetSubFromContext(ctx), email)
+	return s.storage.FindUsersByEmail(ctx, email)
+}
+
+// ListUserLinks returns the owner's links in order, as ListLinks
+func (s *UserService) ListUserLinks(ctx context.Context, ownerID uuid.UUID, order string, limit, offset int) ([]*storage.Link, error) {
+	s.logger.LogAdminAction(ctx, "user.list_links", middleware.GetSubFromContext(ctx), ownerID.String())
+	return s.links.ListOwnerLinks(ctx, ownerID, storage.LinkQuery{Order: order}, limit, offset)
+}
+
+// GetLink returns a link of any account, including a disabled one, and how
+// it was created, for abuse investigations
+func (s *UserService) GetLink(ctx context.Context, code string) (*storage.Link, *storage.CreationContext, error) {
+	key := linkKey(ctx, code)
+	s.logger.LogAdminAction(ctx, "link.get", middleware.GetSubFromContext(ctx), key)
+	link, err := s.links.storage.GetByCode(ctx, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if link == nil {
+		return nil, nil, ErrLinkNotFound
+	}
+	creation, err := s.links.creation(ctx, link)
+	if err != nil {
+		return nil, nil, err
+	}
+	return link, creation, nil
+}
+
+// Suspend disables every link of the account at once and stops it from
+// creating new ones until it is reinstated
+func (s *UserService) Suspend(ctx context.Context, ownerID uuid.UUID, reason string) (*storage.Suspension, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	actor := middleware.GetSubFromContext(ctx)
+	suspension := &storage.Suspension{
+		OwnerID:     ownerID,
+		Reason:      reason,
+		SuspendedBy: actor,
+		SuspendedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	keys, ok, err := s.storage.SuspendUser(ctx, suspension)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrAlreadySuspended
+	}
+	s.forgetLinks(ctx, keys)
+	s.logger.LogAdminAction(ctx, "user.suspend", actor, ownerID.String(), "reason", reason, "links", len(keys))
+	return suspension, nil
+}
+
+// Reinstate lifts a suspension and enables the account's links again
+func (s *UserService) Reinstate(ctx context.Context, ownerID uuid.UUID) error {
+	keys, ok, err := s.storage.ReinstateUser(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotSuspended
+	}
+	s.forgetLinks(ctx, keys)
+	s.logger.LogAdminAction(ctx, "user.reinstate", middleware.GetSubFromContext(ctx), ownerID.String(), "links", len(keys))
+	return nil
+}
+
+// forgetLinks drops the cached copies of links whose disabled flag changed,
+// so every replica reads the new state on the next redirect
+func (s *UserService) forgetLinks(ctx context.Context, keys []string) {
+	for _, key := range keys {
+		if _, err := s.links.PurgeCache(ctx, key, false); err != nil {
+			s.logger.Warn(ctx, "failed to drop cached link", "key", key, "error", err)
+		}
+	}
+}
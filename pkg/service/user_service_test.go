@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/cache"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeUserStorage struct {
+	storage.UserStorage
+	suspensions map[uuid.UUID]*storage.Suspension
+	links       map[uuid.UUID][]string
+	usage       storage.Usage
+}
+
+func (f *fakeUserStorage) GetUsage(ctx context.Context, ownerID uuid.UUID, since time.Time) (*storage.Usage, error) {
+	usage := f.usage
+	return &usage, nil
+}
+
+func (f *fakeUserStorage) GetSuspension(ctx context.Context, ownerID uuid.UUID) (*storage.Suspension, error) {
+	return f.suspensions[ownerID], nil
+}
+
+func (f *fakeUserStorage) SuspendUser(ctx context.Context, sus *storage.Suspension) ([]string, bool, error) {
+	if f.suspensions[sus.OwnerID] != nil {
+		return nil, false, nil
+	}
+	f.suspensions[sus.OwnerID] = sus
+	return f.links[sus.OwnerID], true, nil
+}
+
+func (f *fakeUserStorage) ReinstateUser(ctx context.Context, ownerID uuid.UUID) ([]string, bool, error) {
+	if f.suspensions[ownerID] == nil {
+		return nil, false, nil
+	}
+	delete(f.suspensions, ownerID)
+	return f.links[ownerID], true, nil
+}
+
+type purgedCache struct {
+	cache.LinkCacheInterface
+	purged []string
+}
+
+func (p *purgedCache) Purge(ctx context.Context, code string, prefix bool) (int64, error) {
+	p.purged = append(p.purged, code)
+	return 1, nil
+}
+
+func TestSuspendUser(t *testing.T) {
+	ownerID := uuid.New()
+	users := &fakeUserStorage{
+		suspensions: map[uuid.UUID]*storage.Suspension{},
+		links:       map[uuid.UUID][]string{ownerID: {"abc", "go.example.com/promo"}},
+	}
+	purged := &purgedCache{}
+	logger := logging.NewLogger(logging.LevelError)
+	links := NewLinkService(nil, purged, nil, logger)
+	links.UseSuspensions(users)
+	svc := NewUserService(users, links, logger)
+	ctx := context.WithValue(context.Background(), "sub", "operator-1")
+
+	_, err := svc.Suspend(ctx, ownerID, "  ")
+	assert.ErrorIs(t, err, ErrReasonRequired)
+
+	suspension, err := svc.Suspend(ctx, ownerID, "phishing")
+	require.NoError(t, err)
+	assert.Equal(t, "operator-1", suspension.SuspendedBy)
+	// The cached copies go so replicas stop redirecting right away
+	assert.Equal(t, []string{"abc", "go.example.com/promo"}, purged.purged)
+
+	_, err = svc.Suspend(ctx, ownerID, "phishing")
+	assert.ErrorIs(t, err, ErrAlreadySuspended)
+
+	require.NoError(t, svc.Reinstate(ctx, ownerID))
+	assert.Len(t, purged.purged, 4)
+	assert.ErrorIs(t, svc.Reinstate(ctx, ownerID), ErrNotSuspended)
+}
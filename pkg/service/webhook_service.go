@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/middleware"
+	"url-shortener/pkg/session"
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/validation"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxWebhooksPerOwner  = 10
+	maxWebhookBatchDelay = 5 * time.Minute
+)
+
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrTooManyWebhooks = fmt.Errorf("at most %d webhooks per owner", maxWebhooksPerOwner)
+)
+
+// WebhookService manages the caller's click webhook subscriptions
+type WebhookService struct {
+	storage storage.WebhookStorage
+	links   *LinkService
+}
+
+func NewWebhookService(storage storage.WebhookStorage, links *LinkService) *WebhookService {
+	return &WebhookService{
+		storage: storage,
+		links:   links,
+	}
+}
+
+type CreateWebhookRequest struct {
+	URL string `json:"url" validate:"required,url,max=2048"`
+	// SampleRate is the fraction of clicks delivered, default 1
+	SampleRate *float64 `json:"sample_rate,omitempty" validate:"min=0.0001,max=1"`
+	// BatchSize is the most events per POST, default 1
+	BatchSize *int `json:"batch_size,omitempty" validate:"min=1,max=500"`
+	// BatchInterval is the longest an event waits for its batch to fill,
+	// default 10s
+	BatchInterval *string `json:"batch_interval,omitempty" validate:"duration"`
+}
+
+// Webhook is the API representation of a subscription. Secret is only
+// returned when the subscription is created.
+type Webhook struct {
+	ID            uuid.UUID `json:"id"`
+	URL           string    `json:"url"`
+	Secret        string    `json:"secret,omitempty"`
+	SampleRate    float64   `json:"sample_rate"`
+	BatchSize     int       `json:"batch_size"`
+	BatchInterval string    `json:"batch_interval"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (s *WebhookService) CreateWebhook(ctx context.Context, req *CreateWebhookRequest) (*Webhook, error) {
+	if err := validation.Struct(req); err != nil {
+		return nil, err
+	}
+	if err := s.links.ValidateDestination(req.URL); err != nil {
+		return nil, validation.Errors{{Field: "url", Rule: "destination", Message: strings.TrimPrefix(err.Error(), "invalid URL: ")}}
+	}
+
+	sub := &storage.WebhookSubscription{
+		ID:            uuid.New(),
+		URL:           req.URL,
+		SampleRate:    1,
+		BatchSize:     1,
+		BatchInterval: 10 * time.Second,
+		CreatedAt:     time.Now(),
+	}
+	if req.SampleRate != nil {
+		sub.SampleRate = *req.SampleRate
+	}
+	if req.BatchSize != nil {
+		sub.BatchSize = *req.BatchSize
+	}
+	if req.BatchInterval != nil {
+		interval, _ := time.ParseDuration(*req.BatchInterval)
+		if interval < time.Second || interval > maxWebhookBatchDelay {
+			return nil, validation.Errors{{Field: "batch_interval", Rule: "duration", Message: "must be between 1s and 5m"}}
+		}
+		sub.BatchInterval = interval.Truncate(time.Second)
+	}
+
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+	sub.OwnerID = ownerID
+
+	existing, err := s.storage.ListWebhooksByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxWebhooksPerOwner {
+		return nil, ErrTooManyWebhooks
+	}
+
+	secret, err := session.RandomToken()
+	if err != nil {
+		return nil, err
+	}
+	sub.Secret = secret
+
+	if err := s.storage.CreateWebhook(ctx, sub); err != nil {
+		return nil, err
+	}
+	webhook := toWebhook(sub)
+	webhook.Secret = sub.Secret
+	return webhook, nil
+}
+
+func (s *WebhookService) ListWebhooks(ctx context.Context) ([]*Webhook, error) {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return nil, errors.New("owner_id not found in context")
+	}
+
+	subs, err := s.storage.ListWebhooksByOwner(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	webhooks := make([]*Webhook, len(subs))
+	for i, sub := range subs {
+		webhooks[i] = toWebhook(sub)
+	}
+	return webhooks, nil
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	ownerID := middleware.GetOwnerIDFromContext(ctx)
+	if ownerID == uuid.Nil {
+		return errors.New("owner_id not found in context")
+	}
+
+	sub, err := s.storage.GetWebhook(ctx, id)
+	if err != nil {
+		return err
+	}
+	if sub == nil {
+		return ErrWebhookNotFound
+	}
+	if sub.OwnerID != ownerID {
+		return ErrNotOwner
+	}
+	return s.storage.DeleteWebhook(ctx, id)
+}
+
+func toWebhook(sub *storage.WebhookSubscription) *Webhook {
+	return &Webhook{
+		ID:            sub.ID,
+		URL:           sub.URL,
+		SampleRate:    sub.SampleRate,
+		BatchSize:     sub.BatchSize,
+		BatchInterval: sub.BatchInterval.String(),
+		CreatedAt:     sub.CreatedAt,
+	}
+}
@@ -0,0 +1,129 @@
+// Package session stores browser login sessions established through the
+// OIDC authorization-code flow. Sessions are referenced by the session_id
+// cookie, the same cookie the CSRF token manager binds tokens to.
+package session
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// CookieName is shared with the CSRF machinery in pkg/security
+const CookieName = "session_id"
+
+type Session struct {
+	ID      string    `json:"-"`
+	OwnerID uuid.UUID `json:"owner_id"`
+	Sub     string    `json:"sub"`
+	Email   string    `json:"email"`
+	Scope   string    `json:"scope"`
+	// Tenant is the tenant the user signed in to, from the ID token's
+	// tenant claim
+	Tenant    string    `json:"tenant,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// LoginState is kept between the redirect to the IdP and the callback
+type LoginState struct {
+	Verifier string `json:"verifier"`
+	Nonce    string `json:"nonce"`
+	ReturnTo string `json:"return_to"`
+}
+
+type Store interface {
+	// Create assigns a new random ID to s and persists it until s.ExpiresAt
+	Create(ctx context.Context, s *Session) error
+	// Get returns nil, nil for unknown or expired sessions
+	Get(ctx context.Context, id string) (*Session, error)
+	Delete(ctx context.Context, id string) error
+	SaveLoginState(ctx context.Context, state string, ls *LoginState, ttl time.Duration) error
+	// TakeLoginState returns and removes the state so it can only be used once
+	TakeLoginState(ctx context.Context, state string) (*LoginState, error)
+}
+
+type RedisStore struct {
+	client *redis.Client
+}
+
+func NewRedisStore(client *redis.Client) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Create(ctx context.Context, sess *Session) error {
+	id, err := RandomToken()
+	if err != nil {
+		return err
+	}
+	sess.ID = id
+
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, "session:"+id, data, time.Until(sess.ExpiresAt)).Err()
+}
+
+func (s *RedisStore) Get(ctx context.Context, id string) (*Session, error) {
+	val, err := s.client.Get(ctx, "session:"+id).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sess Session
+	if err := json.Unmarshal([]byte(val), &sess); err != nil {
+		return nil, err
+	}
+	if time.Now().After(sess.ExpiresAt) {
+		return nil, nil
+	}
+	sess.ID = id
+	return &sess, nil
+}
+
+func (s *RedisStore) Delete(ctx context.Context, id string) error {
+	return s.client.Del(ctx, "session:"+id).Err()
+}
+
+func (s *RedisStore) SaveLoginState(ctx context.Context, state string, ls *LoginState, ttl time.Duration) error {
+	data, err := json.Marshal(ls)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, "login_state:"+state, data, ttl).Err()
+}
+
+func (s *RedisStore) TakeLoginState(ctx context.Context, state string) (*LoginState, error) {
+	val, err := s.client.GetDel(ctx, "login_state:"+state).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var ls LoginState
+	if err := json.Unmarshal([]byte(val), &ls); err != nil {
+		return nil, err
+	}
+	return &ls, nil
+}
+
+// RandomToken returns 32 random bytes, URL-safe base64 encoded
+func RandomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresAliasReservationStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresAliasReservationStorage(pool *pgxpool.Pool) *PostgresAliasReservationStorage {
+	return &PostgresAliasReservationStorage{pool: pool}
+}
+
+const aliasReservationColumns = `id, owner_id, pattern, created_at`
+
+func scanAliasReservation(row pgx.Row) (*AliasReservation, error) {
+	var r AliasReservation
+	if err := row.Scan(&r.ID, &r.OwnerID, &r.Pattern, &r.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (s *PostgresAliasReservationStorage) CreateAliasReservation(ctx context.Context, r *AliasReservation) error {
+	query := `INSERT INTO alias_reservations (` + aliasReservationColumns + `) VALUES ($1, $2, $3, $4)`
+	_, err := s.pool.Exec(ctx, query, r.ID, r.OwnerID, r.Pattern, r.CreatedAt)
+	return err
+}
+
+func (s *PostgresAliasReservationStorage) GetAliasReservation(ctx context.Context, id uuid.UUID) (*AliasReservation, error) {
+	r, err := scanAliasReservation(s.pool.QueryRow(ctx, `SELECT `+aliasReservationColumns+` FROM alias_reservations WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return r, err
+}
+
+func (s *PostgresAliasReservationStorage) ListAliasReservationsByOwner(ctx context.Context, ownerID uuid.UUID) ([]*AliasReservation, error) {
+	return s.list(ctx, `SELECT `+aliasReservationColumns+` FROM alias_reservations WHERE owner_id = $1 ORDER BY pattern`, ownerID)
+}
+
+func (s *PostgresAliasReservationStorage) ListAliasReservations(ctx context.Context) ([]*AliasReservation, error) {
+	return s.list(ctx, `SELECT `+aliasReservationColumns+` FROM alias_reservations ORDER BY pattern`)
+}
+
+func (s *PostgresAliasReservationStorage) list(ctx context.Context, query string, args ...any) ([]*AliasReservation, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reservations []*AliasReservation
+	for rows.Next() {
+		r, err := scanAliasReservation(rows)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, r)
+	}
+	return reservations, rows.Err()
+}
+
+func (s *PostgresAliasReservationStorage) DeleteAliasReservation(ctx context.Context, id uuid.UUID) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM alias_reservations WHERE id = $1`, id)
+	return err
+}
+
+func (s *PostgresAliasReservationStorage) MatchAliasReservationTx(ctx context.Context, tx pgx.Tx, alias string) (*AliasReservation, error) {
+	query := `SELECT ` + aliasReservationColumns + ` FROM alias_reservations
+		WHERE pattern = $1 OR (right(pattern, 1) = '*' AND starts_with($1, left(pattern, -1)))
+		LIMIT 1`
+	r, err := scanAliasReservation(tx.QueryRow(ctx, query, alias))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return r, err
+}
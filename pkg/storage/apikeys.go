@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresAPIKeyStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresAPIKeyStorage(pool *pgxpool.Pool) *PostgresAPIKeyStorage {
+	return &PostgresAPIKeyStorage{pool: pool}
+}
+
+const apiKeyColumns = `id, owner_id, tenant_id, name, prefix, key_hash, created_at, last_used_at`
+
+func scanAPIKey(row pgx.Row) (*APIKey, error) {
+	var key APIKey
+	if err := row.Scan(&key.ID, &key.OwnerID, &key.TenantID, &key.Name, &key.Prefix, &key.Hash, &key.CreatedAt, &key.LastUsedAt); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (s *PostgresAPIKeyStorage) CreateAPIKey(ctx context.Context, key *APIKey) error {
+	query := `INSERT INTO api_keys (` + apiKeyColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := s.pool.Exec(ctx, query, key.ID, key.OwnerID, key.TenantID, key.Name, key.Prefix, key.Hash, key.CreatedAt, key.LastUsedAt)
+	return err
+}
+
+func (s *PostgresAPIKeyStorage) GetAPIKey(ctx context.Context, id uuid.UUID) (*APIKey, error) {
+	return s.get(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE id = $1`, id)
+}
+
+func (s *PostgresAPIKeyStorage) GetAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error) {
+	return s.get(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE key_hash = $1`, hash)
+}
+
+func (s *PostgresAPIKeyStorage) get(ctx context.Context, query string, arg any) (*APIKey, error) {
+	key, err := scanAPIKey(s.pool.QueryRow(ctx, query, arg))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return key, err
+}
+
+func (s *PostgresAPIKeyStorage) ListAPIKeysByOwner(ctx context.Context, ownerID uuid.UUID) ([]*APIKey, error) {
+	rows, err := s.pool.Query(ctx, `SELECT `+apiKeyColumns+` FROM api_keys WHERE owner_id = $1 ORDER BY created_at`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *PostgresAPIKeyStorage) DeleteAPIKey(ctx context.Context, id uuid.UUID) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM api_keys WHERE id = $1`, id)
+	return err
+}
+
+func (s *PostgresAPIKeyStorage) TouchAPIKey(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	_, err := s.pool.Exec(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, usedAt)
+	return err
+}
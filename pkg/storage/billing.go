@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresBillingStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresBillingStorage(pool *pgxpool.Pool) *PostgresBillingStorage {
+	return &PostgresBillingStorage{pool: pool}
+}
+
+const subscriptionColumns = `owner_id, customer_id, subscription_id, status, price_id, current_period_end, cancel_at_period_end, event_created_at, updated_at`
+
+func scanSubscription(row pgx.Row) (*BillingSubscription, error) {
+	var s BillingSubscription
+	err := row.Scan(&s.OwnerID, &s.CustomerID, &s.SubscriptionID, &s.Status, &s.PriceID,
+		&s.CurrentPeriodEnd, &s.CancelAtPeriodEnd, &s.EventCreatedAt, &s.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (s *PostgresBillingStorage) GetSubscription(ctx context.Context, ownerID uuid.UUID) (*BillingSubscription, error) {
+	return scanSubscription(s.pool.QueryRow(ctx, `SELECT `+subscriptionColumns+` FROM billing_subscriptions WHERE owner_id = $1`, ownerID))
+}
+
+func (s *PostgresBillingStorage) GetSubscriptionByCustomer(ctx context.Context, customerID string) (*BillingSubscription, error) {
+	query := `SELECT ` + subscriptionColumns + ` FROM billing_subscriptions WHERE customer_id = $1 ORDER BY updated_at DESC LIMIT 1`
+	return scanSubscription(s.pool.QueryRow(ctx, query, customerID))
+}
+
+func (s *PostgresBillingStorage) SaveSubscription(ctx context.Context, sub *BillingSubscription) (bool, error) {
+	query := `INSERT INTO billing_subscriptions (` + subscriptionColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (owner_id) DO UPDATE SET customer_id = EXCLUDED.customer_id,
+			subscription_id = EXCLUDED.subscription_id, status = EXCLUDED.status,
+			price_id = EXCLUDED.price_id, current_period_end = EXCLUDED.current_period_end,
+			cancel_at_period_end = EXCLUDED.cancel_at_period_end,
+			event_created_at = EXCLUDED.event_created_at, updated_at = EXCLUDED.updated_at
+		WHERE billing_subscriptions.event_created_at <= EXCLUDED.event_created_at`
+	tag, err := s.pool.Exec(ctx, query, sub.OwnerID, sub.CustomerID, sub.SubscriptionID, sub.Status, sub.PriceID,
+		sub.CurrentPeriodEnd, sub.CancelAtPeriodEnd, sub.EventCreatedAt, sub.UpdatedAt)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
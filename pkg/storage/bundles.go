@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresBundleStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresBundleStorage(pool *pgxpool.Pool) *PostgresBundleStorage {
+	return &PostgresBundleStorage{pool: pool}
+}
+
+func (s *PostgresBundleStorage) CreateBundle(ctx context.Context, bundle *Bundle) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO bundles (slug, title, owner_id, created_at, updated_at) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := tx.Exec(ctx, query, bundle.Slug, bundle.Title, bundle.OwnerID, bundle.CreatedAt, bundle.UpdatedAt); err != nil {
+		return err
+	}
+	for i, entry := range bundle.Entries {
+		if err := insertEntry(ctx, tx, bundle.Slug, i, entry); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *PostgresBundleStorage) GetBundle(ctx context.Context, slug string) (*Bundle, error) {
+	query := `SELECT slug, title, owner_id, created_at, updated_at FROM bundles WHERE slug = $1`
+	var bundle Bundle
+	err := s.pool.QueryRow(ctx, query, slug).Scan(&bundle.Slug, &bundle.Title, &bundle.OwnerID, &bundle.CreatedAt, &bundle.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	rows, err := s.pool.Query(ctx, `SELECT id, position, title, url, click_count FROM bundle_entries WHERE slug = $1 ORDER BY position`, slug)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bundle.Entries = []*BundleEntry{}
+	for rows.Next() {
+		var entry BundleEntry
+		if err := rows.Scan(&entry.ID, &entry.Position, &entry.Title, &entry.URL, &entry.ClickCount); err != nil {
+			return nil, err
+		}
+		bundle.Entries = append(bundle.Entries, &entry)
+	}
+	return &bundle, rows.Err()
+}
+
+func (s *PostgresBundleStorage) ListBundlesByOwner(ctx context.Context, ownerID uuid.UUID, limit, offset int) ([]*Bundle, error) {
+	query := `SELECT slug, title, owner_id, created_at, updated_at FROM bundles WHERE owner_id = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3`
+	rows, err := s.pool.Query(ctx, query, ownerID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bundles []*Bundle
+	for rows.Next() {
+		var bundle Bundle
+		if err := rows.Scan(&bundle.Slug, &bundle.Title, &bundle.OwnerID, &bundle.CreatedAt, &bundle.UpdatedAt); err != nil {
+			return nil, err
+		}
+		bundles = append(bundles, &bundle)
+	}
+	return bundles, rows.Err()
+}
+
+func (s *PostgresBundleStorage) UpdateBundle(ctx context.Context, bundle *Bundle) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `UPDATE bundles SET title = $2, updated_at = $3 WHERE slug = $1`, bundle.Slug, bundle.Title, bundle.UpdatedAt); err != nil {
+		return err
+	}
+
+	keep := make([]int64, 0, len(bundle.Entries))
+	for _, entry := range bundle.Entries {
+		if entry.ID != 0 {
+			keep = append(keep, entry.ID)
+		}
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM bundle_entries WHERE slug = $1 AND NOT (id = ANY($2))`, bundle.Slug, keep); err != nil {
+		return err
+	}
+
+	for i, entry := range bundle.Entries {
+		if entry.ID == 0 {
+			if err := insertEntry(ctx, tx, bundle.Slug, i, entry); err != nil {
+				return err
+			}
+			continue
+		}
+		query := `UPDATE bundle_entries SET position = $3, title = $4, url = $5 WHERE slug = $1 AND id = $2`
+		if _, err := tx.Exec(ctx, query, bundle.Slug, entry.ID, i, entry.Title, entry.URL); err != nil {
+			return err
+		}
+		entry.Position = i
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *PostgresBundleStorage) DeleteBundle(ctx context.Context, slug string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM bundles WHERE slug = $1`, slug)
+	return err
+}
+
+func (s *PostgresBundleStorage) RecordEntryClick(ctx context.Context, slug string, entryID int64) (string, error) {
+	query := `UPDATE bundle_entries SET click_count = click_count + 1 WHERE slug = $1 AND id = $2 RETURNING url`
+	var url string
+	err := s.pool.QueryRow(ctx, query, slug, entryID).Scan(&url)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil
+	}
+	return url, err
+}
+
+func insertEntry(ctx context.Context, tx pgx.Tx, slug string, position int, entry *BundleEntry) error {
+	query := `INSERT INTO bundle_entries (slug, position, title, url) VALUES ($1, $2, $3, $4) RETURNING id`
+	entry.Position = position
+	return tx.QueryRow(ctx, query, slug, position, entry.Title, entry.URL).Scan(&entry.ID)
+}
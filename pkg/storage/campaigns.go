@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresCampaignStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresCampaignStorage(pool *pgxpool.Pool) *PostgresCampaignStorage {
+	return &PostgresCampaignStorage{pool: pool}
+}
+
+const campaignColumns = `id, owner_id, name, description, created_at, updated_at`
+
+func scanCampaign(row pgx.Row) (*Campaign, error) {
+	var c Campaign
+	if err := row.Scan(&c.ID, &c.OwnerID, &c.Name, &c.Description, &c.CreatedAt, &c.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *PostgresCampaignStorage) CreateCampaign(ctx context.Context, c *Campaign) error {
+	query := `INSERT INTO campaigns (` + campaignColumns + `) VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := s.pool.Exec(ctx, query, c.ID, c.OwnerID, c.Name, c.Description, c.CreatedAt, c.UpdatedAt)
+	return err
+}
+
+func (s *PostgresCampaignStorage) GetCampaign(ctx context.Context, id uuid.UUID) (*Campaign, error) {
+	query := `SELECT ` + campaignColumns + ` FROM campaigns WHERE id = $1`
+	c, err := scanCampaign(s.pool.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return c, err
+}
+
+func (s *PostgresCampaignStorage) ListCampaignsByOwner(ctx context.Context, ownerID uuid.UUID, limit, offset int) ([]*Campaign, error) {
+	query := `SELECT ` + campaignColumns + ` FROM campaigns WHERE owner_id = $1 ORDER BY created_at DESC LIMIT $2 OFFSET $3`
+	rows, err := s.pool.Query(ctx, query, ownerID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var campaigns []*Campaign
+	for rows.Next() {
+		c, err := scanCampaign(rows)
+		if err != nil {
+			return nil, err
+		}
+		campaigns = append(campaigns, c)
+	}
+	return campaigns, rows.Err()
+}
+
+func (s *PostgresCampaignStorage) UpdateCampaign(ctx context.Context, c *Campaign) error {
+	query := `UPDATE campaigns SET name = $2, description = $3, updated_at = $4 WHERE id = $1`
+	_, err := s.pool.Exec(ctx, query, c.ID, c.Name, c.Description, c.UpdatedAt)
+	return err
+}
+
+func (s *PostgresCampaignStorage) DeleteCampaign(ctx context.Context, id uuid.UUID) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM campaigns WHERE id = $1`, id)
+	return err
+}
+
+func (s *PostgresCampaignStorage) CampaignTotals(ctx context.Context, id uuid.UUID) (*CampaignTotals, error) {
+	var totals CampaignTotals
+	query := `SELECT COUNT(*), COALESCE(SUM(click_count), 0) FROM links WHERE campaign_id = $1`
+	if err := s.pool.QueryRow(ctx, query, id).Scan(&totals.Links, &totals.TotalClicks); err != nil {
+		return nil, err
+	}
+	return &totals, nil
+}
+
+func (s *PostgresCampaignStorage) TopCampaignLinks(ctx context.Context, id uuid.UUID, limit int) ([]*Link, error) {
+	query := `SELECT ` + linkColumns + ` FROM links WHERE campaign_id = $1 ORDER BY click_count DESC, code LIMIT $2`
+	rows, err := s.pool.Query(ctx, query, id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []*Link
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
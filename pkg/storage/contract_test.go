@@ -0,0 +1,32 @@
+package storage_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"url-shortener/pkg/storage"
+	"url-shortener/pkg/storage/storagetest"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestPostgresLinkStorage runs the contract against the migrated database
+// at TEST_DATABASE_URL
+func TestPostgresLinkStorage(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	pool, err := storage.NewPool(context.Background(), databaseURL, storage.PoolConfig{})
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+	require.NoError(t, storage.CheckSchema(context.Background(), pool))
+
+	storagetest.TestLinkStorage(t, storagetest.LinkHarness{
+		New: func(t *testing.T) storage.LinkStorage {
+			return storage.NewPostgresLinkStorage(pool)
+		},
+		Begin: pool.Begin,
+	})
+}
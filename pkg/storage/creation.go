@@ -0,0 +1,34 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// creationColumns are the links columns only written on create and only read
+// on request
+const creationColumns = "created_ip, created_user_agent, created_api_key_id, created_client_id"
+
+// creationOf is link's creation context, empty if it has none
+func creationOf(link *Link) *CreationContext {
+	if link.Creation == nil {
+		return &CreationContext{}
+	}
+	return link.Creation
+}
+
+func (s *PostgresLinkStorage) GetCreation(ctx context.Context, key string) (*CreationContext, error) {
+	domain, code := SplitLinkKey(key)
+	var c CreationContext
+	query := `SELECT ` + creationColumns + ` FROM links WHERE domain = $1 AND code = $2`
+	err := s.pool.QueryRow(ctx, query, domain, code).Scan(&c.IP, &c.UserAgent, &c.APIKeyID, &c.ClientID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresDeepLinkKeyStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresDeepLinkKeyStorage(pool *pgxpool.Pool) *PostgresDeepLinkKeyStorage {
+	return &PostgresDeepLinkKeyStorage{pool: pool}
+}
+
+const deepLinkKeyColumns = `id, owner_id, name, secret, created_at`
+
+func scanDeepLinkKey(row pgx.Row) (*DeepLinkKey, error) {
+	var key DeepLinkKey
+	if err := row.Scan(&key.ID, &key.OwnerID, &key.Name, &key.Secret, &key.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+func (s *PostgresDeepLinkKeyStorage) CreateDeepLinkKey(ctx context.Context, key *DeepLinkKey) error {
+	query := `INSERT INTO deep_link_keys (` + deepLinkKeyColumns + `) VALUES ($1, $2, $3, $4, $5)`
+	_, err := s.pool.Exec(ctx, query, key.ID, key.OwnerID, key.Name, key.Secret, key.CreatedAt)
+	return err
+}
+
+func (s *PostgresDeepLinkKeyStorage) GetDeepLinkKey(ctx context.Context, id uuid.UUID) (*DeepLinkKey, error) {
+	key, err := scanDeepLinkKey(s.pool.QueryRow(ctx, `SELECT `+deepLinkKeyColumns+` FROM deep_link_keys WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return key, err
+}
+
+func (s *PostgresDeepLinkKeyStorage) ListDeepLinkKeysByOwner(ctx context.Context, ownerID uuid.UUID) ([]*DeepLinkKey, error) {
+	rows, err := s.pool.Query(ctx, `SELECT `+deepLinkKeyColumns+` FROM deep_link_keys WHERE owner_id = $1 ORDER BY created_at`, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*DeepLinkKey
+	for rows.Next() {
+		key, err := scanDeepLinkKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (s *PostgresDeepLinkKeyStorage) DeleteDeepLinkKey(ctx context.Context, id uuid.UUID) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM deep_link_keys WHERE id = $1`, id)
+	return err
+}
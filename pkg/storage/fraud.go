@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// FraudAlertEvent is the outbox event type for a new fraud alert
+const FraudAlertEvent = "link.fraud_alert"
+
+type PostgresFraudStorage struct {
+	pool   *pgxpool.Pool
+	outbox *PostgresOutboxStorage
+}
+
+func NewPostgresFraudStorage(pool *pgxpool.Pool) *PostgresFraudStorage {
+	return &PostgresFraudStorage{pool: pool, outbox: NewPostgresOutboxStorage(pool)}
+}
+
+func (s *PostgresFraudStorage) CreateFraudAlert(ctx context.Context, alert *FraudAlert) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	domain := ""
+	if alert.Domain != nil {
+		domain = *alert.Domain
+	}
+	query := `INSERT INTO fraud_alerts (domain, code, owner_id, kind, detail, created_at) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	if err := tx.QueryRow(ctx, query, domain, alert.Code, alert.OwnerID, alert.Kind, alert.Detail, alert.CreatedAt).Scan(&alert.ID); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	event := &OutboxEvent{EventID: uuid.New(), Type: FraudAlertEvent, OwnerID: alert.OwnerID, Payload: payload, CreatedAt: alert.CreatedAt}
+	if err := s.outbox.AddEventTx(ctx, tx, event); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *PostgresFraudStorage) ListFraudAlerts(ctx context.Context, kind string, limit, offset int) ([]*FraudAlert, error) {
+	query := `SELECT id, domain, code, owner_id, kind, detail, created_at FROM fraud_alerts
+		WHERE $1 = '' OR kind = $1 ORDER BY created_at DESC, id DESC LIMIT $2 OFFSET $3`
+	rows, err := s.pool.Query(ctx, query, kind, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []*FraudAlert
+	for rows.Next() {
+		var a FraudAlert
+		if err := rows.Scan(&a.ID, &a.Domain, &a.Code, &a.OwnerID, &a.Kind, &a.Detail, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		if a.Domain != nil && *a.Domain == "" {
+			a.Domain = nil
+		}
+		alerts = append(alerts, &a)
+	}
+	return alerts, rows.Err()
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresHealthStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresHealthStorage(pool *pgxpool.Pool) *PostgresHealthStorage {
+	return &PostgresHealthStorage{pool: pool}
+}
+
+func (s *PostgresHealthStorage) FindHealthCandidates(ctx context.Context, checkedBefore time.Time, limit int) ([]*HealthCandidate, error) {
+	// Only the email opt-ins are read, as the notice is only emailed
+	query := `
+		SELECT ` + prefixed("l.", linkColumns) + `, h.status, h.http_status, h.checked_at,
+			n.owner_id IS NOT NULL, n.email, COALESCE(n.email_enabled, FALSE), COALESCE(n.expiry_reminders, FALSE)
+		FROM links l
+		LEFT JOIN link_health h ON h.domain = l.domain AND h.code = l.code
+		LEFT JOIN notification_settings n ON n.owner_id = l.owner_id
+		WHERE (h.checked_at IS NULL OR h.checked_at < $1)
+			AND (l.expires_at IS NULL OR l.expires_at > NOW())
+		ORDER BY h.checked_at NULLS FIRST
+		LIMIT $2`
+	rows, err := s.pool.Query(ctx, query, checkedBefore, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []*HealthCandidate
+	for rows.Next() {
+		var link Link
+		var status *string
+		var httpStatus *int
+		var checkedAt *time.Time
+		var hasSettings bool
+		var ns NotificationSettings
+		dest := append(linkFields(&link), &status, &httpStatus, &checkedAt, &hasSettings, &ns.Email, &ns.EmailEnabled, &ns.ExpiryReminders)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		c := &HealthCandidate{Link: scanned(&link)}
+		if status != nil {
+			c.Health = &LinkHealth{Status: *status, HTTPStatus: httpStatus, CheckedAt: *checkedAt}
+		}
+		if hasSettings {
+			ns.OwnerID = *link.OwnerID
+			c.Settings = &ns
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+func (s *PostgresHealthStorage) SaveHealth(ctx context.Context, key string, health *LinkHealth) error {
+	domain, code := SplitLinkKey(key)
+	query := `INSERT INTO link_health (domain, code, status, http_status, checked_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (domain, code) DO UPDATE SET
+			status = EXCLUDED.status,
+			http_status = EXCLUDED.http_status,
+			checked_at = EXCLUDED.checked_at,
+			notified = link_health.notified AND link_health.status = EXCLUDED.status`
+	_, err := s.pool.Exec(ctx, query, domain, code, health.Status, health.HTTPStatus, health.CheckedAt)
+	return err
+}
+
+func (s *PostgresHealthStorage) GetHealth(ctx context.Context, keys []string) (map[string]*LinkHealth, error) {
+	domains, codes := splitLinkKeys(keys)
+	query := `SELECT domain, code, status, http_status, checked_at FROM link_health WHERE (domain, code) IN (SELECT * FROM unnest($1::text[], $2::text[]))`
+	rows, err := s.pool.Query(ctx, query, domains, codes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	health := make(map[string]*LinkHealth)
+	for rows.Next() {
+		var domain, code string
+		var h LinkHealth
+		if err := rows.Scan(&domain, &code, &h.Status, &h.HTTPStatus, &h.CheckedAt); err != nil {
+			return nil, err
+		}
+		health[LinkKey(domain, code)] = &h
+	}
+	return health, rows.Err()
+}
+
+func (s *PostgresHealthStorage) ClaimHealthNotice(ctx context.Context, key string) (bool, error) {
+	domain, code := SplitLinkKey(key)
+	tag, err := s.pool.Exec(ctx, `UPDATE link_health SET notified = TRUE WHERE domain = $1 AND code = $2 AND NOT notified`, domain, code)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (s *PostgresHealthStorage) ReleaseHealthNotice(ctx context.Context, key string) error {
+	domain, code := SplitLinkKey(key)
+	_, err := s.pool.Exec(ctx, `UPDATE link_health SET notified = FALSE WHERE domain = $1 AND code = $2`, domain, code)
+	return err
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresIntegrationStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresIntegrationStorage(pool *pgxpool.Pool) *PostgresIntegrationStorage {
+	return &PostgresIntegrationStorage{pool: pool}
+}
+
+const hookColumns = `id, owner_id, trigger, target_url, threshold, created_at`
+
+func scanHook(row pgx.Row) (*IntegrationHook, error) {
+	var hook IntegrationHook
+	if err := row.Scan(&hook.ID, &hook.OwnerID, &hook.Trigger, &hook.TargetURL, &hook.Threshold, &hook.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+func (s *PostgresIntegrationStorage) CreateHook(ctx context.Context, hook *IntegrationHook) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO integration_hooks (` + hookColumns + `) VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := tx.Exec(ctx, query, hook.ID, hook.OwnerID, hook.Trigger, hook.TargetURL, hook.Threshold, hook.CreatedAt); err != nil {
+		return err
+	}
+	if hook.Threshold > 0 {
+		_, err := tx.Exec(ctx, `
+			INSERT INTO integration_hook_firings (hook_id, domain, code)
+			SELECT $1, domain, code FROM links WHERE owner_id = $2 AND click_count >= $3`,
+			hook.ID, hook.OwnerID, hook.Threshold)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *PostgresIntegrationStorage) GetHook(ctx context.Context, id uuid.UUID) (*IntegrationHook, error) {
+	query := `SELECT ` + hookColumns + ` FROM integration_hooks WHERE id = $1`
+	hook, err := scanHook(s.pool.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return hook, err
+}
+
+func (s *PostgresIntegrationStorage) ListHooksByOwner(ctx context.Context, ownerID uuid.UUID) ([]*IntegrationHook, error) {
+	return s.list(ctx, `SELECT `+hookColumns+` FROM integration_hooks WHERE owner_id = $1 ORDER BY created_at`, ownerID)
+}
+
+func (s *PostgresIntegrationStorage) ListHooks(ctx context.Context, trigger string, ownerID *uuid.UUID) ([]*IntegrationHook, error) {
+	if ownerID != nil {
+		return s.list(ctx, `SELECT `+hookColumns+` FROM integration_hooks WHERE trigger = $1 AND owner_id = $2`, trigger, *ownerID)
+	}
+	return s.list(ctx, `SELECT `+hookColumns+` FROM integration_hooks WHERE trigger = $1`, trigger)
+}
+
+func (s *PostgresIntegrationStorage) list(ctx context.Context, query string, args ...any) ([]*IntegrationHook, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []*IntegrationHook
+	for rows.Next() {
+		hook, err := scanHook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, hook)
+	}
+	return hooks, rows.Err()
+}
+
+func (s *PostgresIntegrationStorage) DeleteHook(ctx context.Context, id uuid.UUID) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM integration_hooks WHERE id = $1`, id)
+	return err
+}
+
+func (s *PostgresIntegrationStorage) FindThresholdLinks(ctx context.Context, hook *IntegrationHook, limit int) ([]*Link, error) {
+	query := `
+		SELECT ` + prefixed("l.", linkColumns) + `
+		FROM links l
+		WHERE l.owner_id = $1 AND l.click_count >= $2
+			AND NOT EXISTS (
+				SELECT 1 FROM integration_hook_firings f
+				WHERE f.hook_id = $3 AND f.domain = l.domain AND f.code = l.code)
+		ORDER BY l.last_clicked_at DESC NULLS LAST
+		LIMIT $4`
+	rows, err := s.pool.Query(ctx, query, hook.OwnerID, hook.Threshold, hook.ID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []*Link
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+func (s *PostgresIntegrationStorage) ClaimFiring(ctx context.Context, hookID uuid.UUID, key string) (bool, error) {
+	domain, code := SplitLinkKey(key)
+	tag, err := s.pool.Exec(ctx, `
+		INSERT INTO integration_hook_firings (hook_id, domain, code) VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING`, hookID, domain, code)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (s *PostgresIntegrationStorage) ReleaseFiring(ctx context.Context, hookID uuid.UUID, key string) error {
+	domain, code := SplitLinkKey(key)
+	_, err := s.pool.Exec(ctx, `DELETE FROM integration_hook_firings WHERE hook_id = $1 AND domain = $2 AND code = $3`, hookID, domain, code)
+	return err
+}
@@ -2,16 +2,297 @@ package storage
 
 import (
 	"context"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 )
 
+// LinkStorage addresses links by their key (see LinkKey), which is just the
+// code on the default domain
 type LinkStorage interface {
 	Create(ctx context.Context, link *Link) error
 	CreateTx(ctx context.Context, tx pgx.Tx, link *Link) error
-	GetByCode(ctx context.Context, code string) (*Link, error)
-	GetByCodeTx(ctx context.Context, tx pgx.Tx, code string) (*Link, error)
+	GetByCode(ctx context.Context, key string) (*Link, error)
+	GetByCodeTx(ctx context.Context, tx pgx.Tx, key string) (*Link, error)
+	// GetByCodes returns the links among keys that exist, in no order
+	GetByCodes(ctx context.Context, keys []string) ([]*Link, error)
 	Update(ctx context.Context, link *Link) error
-	Delete(ctx context.Context, code string) error
-	IncrementClickCount(ctx context.Context, code string) error
+	UpdateTx(ctx context.Context, tx pgx.Tx, link *Link) error
+	// Delete and DeleteTx leave a tombstone for the link's code; see
+	// tombstones.go
+	Delete(ctx context.Context, key string) error
+	DeleteTx(ctx context.Context, tx pgx.Tx, key string) error
+	// LastRetiredTx returns when the latest link under key was deleted or
+	// retired, or nil if none was
+	LastRetiredTx(ctx context.Context, tx pgx.Tx, key string) (*time.Time, error)
+	// RetireExpired deletes up to limit links that expired before before,
+	// leaving tombstones, and returns how many it deleted
+	RetireExpired(ctx context.Context, before time.Time, limit int) (int64, error)
+	// GetCreation returns how the link under key was created, or nil if
+	// there is no such link
+	GetCreation(ctx context.Context, key string) (*CreationContext, error)
+	// AddClickCount adds n clicks to the link's stored count and marks it
+	// clicked now
+	AddClickCount(ctx context.Context, key string, n int64) error
+	// ReplacePasswordHash swaps the link's password hash, unless it no longer
+	// equals old because the password was changed meanwhile
+	ReplacePasswordHash(ctx context.Context, key, old, new string) error
+	// ListByOwner returns a page of the owner's links matching query, in
+	// its order
+	ListByOwner(ctx context.Context, ownerID uuid.UUID, query LinkQuery, limit, offset int) ([]*Link, error)
+	// GetTags returns the tags of the links with these keys, by key
+	GetTags(ctx context.Context, keys []string) (map[string][]string, error)
+	// SetTags and SetTagsTx replace the link's tags
+	SetTags(ctx context.Context, key string, tags []string) error
+	SetTagsTx(ctx context.Context, tx pgx.Tx, key string, tags []string) error
+	ListTagsByOwner(ctx context.Context, ownerID uuid.UUID) ([]string, error)
+}
+
+// ReplicaStorage applies link writes replicated from other regions; see
+// replication.go. Each reports whether it changed anything.
+type ReplicaStorage interface {
+	// ApplyLink inserts link, or replaces the stored one if link was
+	// written later
+	ApplyLink(ctx context.Context, link *Link) (bool, error)
+	// ApplyDelete deletes the link under key unless it was written after
+	// deletedAt
+	ApplyDelete(ctx context.Context, key string, deletedAt time.Time, region string) (bool, error)
+}
+
+// SitemapStorage reads the links of a short domain for its sitemap; see
+// sitemaps.go
+type SitemapStorage interface {
+	// ListSitemapLinks returns the links on domain written after since,
+	// whether or not they may be listed, oldest write first
+	ListSitemapLinks(ctx context.Context, domain string, since time.Time) ([]*SitemapLink, error)
+	// ListRetiredCodes returns the codes on domain whose links were deleted
+	// or retired after since
+	ListRetiredCodes(ctx context.Context, domain string, since time.Time) ([]string, error)
+}
+
+type BundleStorage interface {
+	// CreateBundle inserts the bundle and its entries, assigning entry IDs
+	CreateBundle(ctx context.Context, bundle *Bundle) error
+	// GetBundle returns the bundle with entries in display order, or nil
+	GetBundle(ctx context.Context, slug string) (*Bundle, error)
+	ListBundlesByOwner(ctx context.Context, ownerID uuid.UUID, limit, offset int) ([]*Bundle, error)
+	// UpdateBundle replaces the title and entries. Entries with a known ID
+	// keep their click count; entries missing from bundle.Entries are removed.
+	UpdateBundle(ctx context.Context, bundle *Bundle) error
+	DeleteBundle(ctx context.Context, slug string) error
+	// RecordEntryClick counts a click and returns the entry URL, or "" if the
+	// entry doesn't exist
+	RecordEntryClick(ctx context.Context, slug string, entryID int64) (string, error)
+}
+
+type CampaignStorage interface {
+	CreateCampaign(ctx context.Context, campaign *Campaign) error
+	// GetCampaign returns nil, nil if the campaign doesn't exist
+	GetCampaign(ctx context.Context, id uuid.UUID) (*Campaign, error)
+	ListCampaignsByOwner(ctx context.Context, ownerID uuid.UUID, limit, offset int) ([]*Campaign, error)
+	UpdateCampaign(ctx context.Context, campaign *Campaign) error
+	// DeleteCampaign removes the campaign; its links stay but are detached
+	DeleteCampaign(ctx context.Context, id uuid.UUID) error
+	CampaignTotals(ctx context.Context, id uuid.UUID) (*CampaignTotals, error)
+	// TopCampaignLinks returns up to limit member links, most clicked first
+	TopCampaignLinks(ctx context.Context, id uuid.UUID, limit int) ([]*Link, error)
+}
+
+type PreferencesStorage interface {
+	// GetPreferences returns nil, nil if the owner hasn't saved any
+	GetPreferences(ctx context.Context, ownerID uuid.UUID) (*Preferences, error)
+	SavePreferences(ctx context.Context, prefs *Preferences) error
+}
+
+type ReminderStorage interface {
+	// GetNotificationSettings returns nil, nil if the owner hasn't opted in
+	GetNotificationSettings(ctx context.Context, ownerID uuid.UUID) (*NotificationSettings, error)
+	SaveNotificationSettings(ctx context.Context, settings *NotificationSettings) error
+	// FindReminderCandidates returns unexpired links of opted-in owners that
+	// are within their reminder window and haven't been reminded yet
+	FindReminderCandidates(ctx context.Context, now time.Time, limit int) ([]*ReminderCandidate, error)
+	// ClaimReminder records that a reminder is being sent and reports false
+	// if another worker already claimed it
+	ClaimReminder(ctx context.Context, key, kind string) (bool, error)
+	ReleaseReminder(ctx context.Context, key, kind string) error
+}
+
+type HealthStorage interface {
+	// FindHealthCandidates returns unexpired links never checked or last
+	// checked before checkedBefore, least recently checked first
+	FindHealthCandidates(ctx context.Context, checkedBefore time.Time, limit int) ([]*HealthCandidate, error)
+	SaveHealth(ctx context.Context, key string, health *LinkHealth) error
+	// GetHealth returns the last check of each link that has one, by key
+	GetHealth(ctx context.Context, keys []string) (map[string]*LinkHealth, error)
+	// ClaimHealthNotice records that the owner is being told about the
+	// link's current status and reports false if they already were
+	ClaimHealthNotice(ctx context.Context, key string) (bool, error)
+	ReleaseHealthNotice(ctx context.Context, key string) error
+}
+
+type FraudStorage interface {
+	// CreateFraudAlert saves alert and, in the same transaction, a
+	// link.fraud_alert outbox event for the owner's webhooks
+	CreateFraudAlert(ctx context.Context, alert *FraudAlert) error
+	// ListFraudAlerts returns alerts, newest first, optionally only those of
+	// one kind
+	ListFraudAlerts(ctx context.Context, kind string, limit, offset int) ([]*FraudAlert, error)
+}
+
+type DigestStorage interface {
+	// FindDigestRecipients returns owners with email digests enabled whose
+	// last digest ended at least one period before periodEnd
+	FindDigestRecipients(ctx context.Context, periodEnd time.Time, limit int) ([]*DigestRecipient, error)
+	// ClaimDigest marks the digest ending at periodEnd as sent and reports
+	// false if another worker already claimed it
+	ClaimDigest(ctx context.Context, ownerID uuid.UUID, periodEnd time.Time, days int) (bool, error)
+	// ReleaseDigest restores the previous SentUntil after a failed send
+	ReleaseDigest(ctx context.Context, ownerID uuid.UUID, previous *time.Time) error
+}
+
+type WebhookStorage interface {
+	CreateWebhook(ctx context.Context, sub *WebhookSubscription) error
+	// GetWebhook returns nil, nil if the subscription doesn't exist
+	GetWebhook(ctx context.Context, id uuid.UUID) (*WebhookSubscription, error)
+	ListWebhooksByOwner(ctx context.Context, ownerID uuid.UUID) ([]*WebhookSubscription, error)
+	// ListAllWebhooks returns every subscription, for the dispatcher
+	ListAllWebhooks(ctx context.Context) ([]*WebhookSubscription, error)
+	DeleteWebhook(ctx context.Context, id uuid.UUID) error
+}
+
+type APIKeyStorage interface {
+	CreateAPIKey(ctx context.Context, key *APIKey) error
+	// GetAPIKey and GetAPIKeyByHash return nil, nil if the key doesn't exist
+	GetAPIKey(ctx context.Context, id uuid.UUID) (*APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (*APIKey, error)
+	ListAPIKeysByOwner(ctx context.Context, ownerID uuid.UUID) ([]*APIKey, error)
+	DeleteAPIKey(ctx context.Context, id uuid.UUID) error
+	TouchAPIKey(ctx context.Context, id uuid.UUID, usedAt time.Time) error
+}
+
+type DeepLinkKeyStorage interface {
+	CreateDeepLinkKey(ctx context.Context, key *DeepLinkKey) error
+	// GetDeepLinkKey returns nil, nil if the key doesn't exist
+	GetDeepLinkKey(ctx context.Context, id uuid.UUID) (*DeepLinkKey, error)
+	ListDeepLinkKeysByOwner(ctx context.Context, ownerID uuid.UUID) ([]*DeepLinkKey, error)
+	DeleteDeepLinkKey(ctx context.Context, id uuid.UUID) error
+}
+
+type AliasReservationStorage interface {
+	CreateAliasReservation(ctx context.Context, reservation *AliasReservation) error
+	// GetAliasReservation returns nil, nil if the reservation doesn't exist
+	GetAliasReservation(ctx context.Context, id uuid.UUID) (*AliasReservation, error)
+	ListAliasReservationsByOwner(ctx context.Context, ownerID uuid.UUID) ([]*AliasReservation, error)
+	// ListAliasReservations returns every owner's reservations
+	ListAliasReservations(ctx context.Context) ([]*AliasReservation, error)
+	DeleteAliasReservation(ctx context.Context, id uuid.UUID) error
+	// MatchAliasReservationTx returns the reservation alias, in lower case,
+	// falls under, or nil, nil
+	MatchAliasReservationTx(ctx context.Context, tx pgx.Tx, alias string) (*AliasReservation, error)
+}
+
+type IntegrationStorage interface {
+	// CreateHook saves hook. A link_clicks hook counts the owner's links
+	// already at its threshold as fired, so subscribing doesn't replay them.
+	CreateHook(ctx context.Context, hook *IntegrationHook) error
+	// GetHook returns nil, nil if the hook doesn't exist
+	GetHook(ctx context.Context, id uuid.UUID) (*IntegrationHook, error)
+	ListHooksByOwner(ctx context.Context, ownerID uuid.UUID) ([]*IntegrationHook, error)
+	// ListHooks returns the hooks of one trigger, of the owner if it is set,
+	// or of every owner for the dispatcher
+	ListHooks(ctx context.Context, trigger string, ownerID *uuid.UUID) ([]*IntegrationHook, error)
+	DeleteHook(ctx context.Context, id uuid.UUID) error
+	// FindThresholdLinks returns up to limit of the hook owner's links that
+	// reached its threshold and haven't fired it yet
+	FindThresholdLinks(ctx context.Context, hook *IntegrationHook, limit int) ([]*Link, error)
+	// ClaimFiring records that hook is firing for the link and reports false
+	// if another worker already claimed it
+	ClaimFiring(ctx context.Context, hookID uuid.UUID, key string) (bool, error)
+	ReleaseFiring(ctx context.Context, hookID uuid.UUID, key string) error
+}
+
+type OutboxStorage interface {
+	// AddEventTx records event in tx, so it exists only if tx commits
+	AddEventTx(ctx context.Context, tx pgx.Tx, event *OutboxEvent) error
+	// ClaimEvents leases up to limit unpublished events, oldest first, to the
+	// caller for lease. Events leased by another relay are skipped.
+	ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]*OutboxEvent, error)
+	MarkPublished(ctx context.Context, id int64) error
+	// MarkFailed records the error and leaves the event unclaimable until
+	// retryAt
+	MarkFailed(ctx context.Context, id int64, retryAt time.Time, lastErr string) error
+	// DeletePublished removes events published before t
+	DeletePublished(ctx context.Context, before time.Time) (int64, error)
+}
+
+type JobStorage interface {
+	// EnqueueJob inserts job and sets its ID. A job whose UniqueKey is
+	// already taken is not inserted and EnqueueJob reports false.
+	EnqueueJob(ctx context.Context, job *Job) (bool, error)
+	// ClaimJobs leases up to limit due jobs of the given kinds to the caller
+	// for lease, marking them running. Jobs whose lease ran out are claimed
+	// again, so a crashed worker's jobs are retried.
+	ClaimJobs(ctx context.Context, kinds []string, limit int, lease time.Duration) ([]*Job, error)
+	CompleteJob(ctx context.Context, id int64) error
+	// RetryJob records the error and makes the job pending again at runAt
+	RetryJob(ctx context.Context, id int64, runAt time.Time, lastErr string) error
+	// KillJob moves the job to the dead letter list
+	KillJob(ctx context.Context, id int64, lastErr string) error
+	// GetJob returns nil, nil if the job doesn't exist
+	GetJob(ctx context.Context, id int64) (*Job, error)
+	// ListJobs returns jobs, most recently updated first, optionally
+	// filtered by status and kind
+	ListJobs(ctx context.Context, status, kind string, limit, offset int) ([]*Job, error)
+	// RequeueJob makes a dead or done job pending with fresh attempts and
+	// reports false if it doesn't exist or is still pending or running
+	RequeueJob(ctx context.Context, id int64) (bool, error)
+	// DeleteJobs removes jobs with the given status last updated before t
+	DeleteJobs(ctx context.Context, status string, before time.Time) (int64, error)
+}
+
+type UserStorage interface {
+	// GetUser returns nil, nil if nothing is stored for ownerID
+	GetUser(ctx context.Context, ownerID uuid.UUID) (*User, error)
+	// FindUsersByEmail matches notification emails, ignoring case
+	FindUsersByEmail(ctx context.Context, email string) ([]*User, error)
+	// GetUsage counts the owner's links, and those created at or after since,
+	// and lists the short domains they are on
+	GetUsage(ctx context.Context, ownerID uuid.UUID, since time.Time) (*Usage, error)
+	// GetSuspension returns nil, nil if the account isn't suspended
+	GetSuspension(ctx context.Context, ownerID uuid.UUID) (*Suspension, error)
+	// SuspendUser records the suspension and disables the owner's links in
+	// one transaction, returning the keys of the links it disabled. It
+	// reports false if the account was already suspended.
+	SuspendUser(ctx context.Context, suspension *Suspension) ([]string, bool, error)
+	// ReinstateUser lifts the suspension and enables the owner's links in
+	// one transaction, returning the keys of the links it enabled. It
+	// reports false if the account wasn't suspended.
+	ReinstateUser(ctx context.Context, ownerID uuid.UUID) ([]string, bool, error)
+}
+
+type PlanStorage interface {
+	// GetOwnerPlan returns nil, nil for owners on the default plan
+	GetOwnerPlan(ctx context.Context, ownerID uuid.UUID) (*OwnerPlan, error)
+	SetOwnerPlan(ctx context.Context, plan *OwnerPlan) error
+	// DeleteOwnerPlan puts the owner back on the default plan
+	DeleteOwnerPlan(ctx context.Context, ownerID uuid.UUID) error
+}
+
+type BillingStorage interface {
+	// GetSubscription returns nil, nil for owners who never subscribed
+	GetSubscription(ctx context.Context, ownerID uuid.UUID) (*BillingSubscription, error)
+	GetSubscriptionByCustomer(ctx context.Context, customerID string) (*BillingSubscription, error)
+	// SaveSubscription reports false, and keeps the stored state, if it is
+	// from a newer event than sub
+	SaveSubscription(ctx context.Context, sub *BillingSubscription) (bool, error)
+}
+
+type TermsStorage interface {
+	// AcceptTerms records the acceptance; accepting a version again keeps
+	// the first time it was accepted
+	AcceptTerms(ctx context.Context, acceptance *TermsAcceptance) error
+	// GetTermsAcceptance returns the owner's most recent acceptance, or nil,
+	// nil if they never accepted any version
+	GetTermsAcceptance(ctx context.Context, ownerID uuid.UUID) (*TermsAcceptance, error)
 }
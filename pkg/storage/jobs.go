@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const jobColumns = `id, kind, payload, status, attempts, max_attempts, run_at, last_error, unique_key, created_at, updated_at`
+
+type PostgresJobStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresJobStorage(pool *pgxpool.Pool) *PostgresJobStorage {
+	return &PostgresJobStorage{pool: pool}
+}
+
+func scanJob(row pgx.Row) (*Job, error) {
+	var job Job
+	err := row.Scan(&job.ID, &job.Kind, &job.Payload, &job.Status, &job.Attempts, &job.MaxAttempts,
+		&job.RunAt, &job.LastError, &job.UniqueKey, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func collectJobs(rows pgx.Rows) ([]*Job, error) {
+	defer rows.Close()
+	var jobs []*Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+func (s *PostgresJobStorage) EnqueueJob(ctx context.Context, job *Job) (bool, error) {
+	query := `
+		INSERT INTO jobs (kind, payload, max_attempts, run_at, unique_key)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (unique_key) DO NOTHING
+		RETURNING id, status, created_at, updated_at`
+	err := s.pool.QueryRow(ctx, query, job.Kind, job.Payload, job.MaxAttempts, job.RunAt, job.UniqueKey).
+		Scan(&job.ID, &job.Status, &job.CreatedAt, &job.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *PostgresJobStorage) ClaimJobs(ctx context.Context, kinds []string, limit int, lease time.Duration) ([]*Job, error) {
+	query := `
+		UPDATE jobs SET status = 'running', attempts = attempts + 1,
+			locked_until = NOW() + make_interval(secs => $3), updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM jobs
+			WHERE kind = ANY($1) AND (
+				(status = 'pending' AND run_at <= NOW()) OR
+				(status = 'running' AND locked_until < NOW())
+			)
+			ORDER BY run_at
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + jobColumns
+	rows, err := s.pool.Query(ctx, query, kinds, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := collectJobs(rows)
+	if err != nil {
+		return nil, err
+	}
+	// RETURNING doesn't preserve the subquery's order
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].RunAt.Before(jobs[j].RunAt) })
+	return jobs, nil
+}
+
+func (s *PostgresJobStorage) CompleteJob(ctx context.Context, id int64) error {
+	_, err := s.pool.Exec(ctx, `UPDATE jobs SET status = 'done', locked_until = NULL, updated_at = NOW() WHERE id = $1`, id)
+	return err
+}
+
+func (s *PostgresJobStorage) RetryJob(ctx context.Context, id int64, runAt time.Time, lastErr string) error {
+	query := `UPDATE jobs SET status = 'pending', run_at = $2, last_error = $3, locked_until = NULL, updated_at = NOW() WHERE id = $1`
+	_, err := s.pool.Exec(ctx, query, id, runAt, lastErr)
+	return err
+}
+
+func (s *PostgresJobStorage) KillJob(ctx context.Context, id int64, lastErr string) error {
+	query := `UPDATE jobs SET status = 'dead', last_error = $2, locked_until = NULL, updated_at = NOW() WHERE id = $1`
+	_, err := s.pool.Exec(ctx, query, id, lastErr)
+	return err
+}
+
+func (s *PostgresJobStorage) GetJob(ctx context.Context, id int64) (*Job, error) {
+	job, err := scanJob(s.pool.QueryRow(ctx, `SELECT `+jobColumns+` FROM jobs WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return job, err
+}
+
+func (s *PostgresJobStorage) ListJobs(ctx context.Context, status, kind string, limit, offset int) ([]*Job, error) {
+	query := `SELECT ` + jobColumns + ` FROM jobs WHERE TRUE`
+	args := []any{}
+	if status != "" {
+		args = append(args, status)
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+	if kind != "" {
+		args = append(args, kind)
+		query += fmt.Sprintf(" AND kind = $%d", len(args))
+	}
+	args = append(args, limit, offset)
+	query += fmt.Sprintf(" ORDER BY updated_at DESC, id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return collectJobs(rows)
+}
+
+func (s *PostgresJobStorage) RequeueJob(ctx context.Context, id int64) (bool, error) {
+	query := `
+		UPDATE jobs SET status = 'pending', attempts = 0, run_at = NOW(), locked_until = NULL, updated_at = NOW()
+		WHERE id = $1 AND status IN ('dead', 'done')`
+	tag, err := s.pool.Exec(ctx, query, id)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (s *PostgresJobStorage) DeleteJobs(ctx context.Context, status string, before time.Time) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM jobs WHERE status = $1 AND updated_at < $2`, status, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
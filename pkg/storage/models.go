@@ -1,6 +1,9 @@
 package storage
 
 import (
+	"encoding/json"
+	"net/netip"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,4 +19,411 @@ type Link struct {
 	ClickCount   int        `json:"click_count" db:"click_count"`
 	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
 	OwnerID      *uuid.UUID `json:"owner_id,omitempty" db:"owner_id"`
+	RedirectType int        `json:"redirect_type" db:"redirect_type"`
+	Domain       *string    `json:"domain,omitempty" db:"domain"`
+	PublicStats  bool       `json:"public_stats" db:"public_stats"`
+	NoIndex      bool       `json:"noindex" db:"noindex"`
+	Tags         []string   `json:"tags,omitempty" db:"-"`
+	CampaignID   *uuid.UUID `json:"campaign_id,omitempty" db:"campaign_id"`
+	Description  *string    `json:"description,omitempty" db:"description"`
+	// Metadata is integrator-defined data, e.g. ticket IDs, stored as JSONB
+	Metadata map[string]any `json:"metadata,omitempty" db:"metadata"`
+	// ParamRules name the rule each {placeholder} in LongURL must match
+	ParamRules map[string]string `json:"param_rules,omitempty" db:"param_rules"`
+	// Health is the last liveness check of LongURL, when loaded
+	Health *LinkHealth `json:"health,omitempty" db:"-"`
+	// Visits matching these aren't counted as clicks
+	ExcludeCIDRs      []string `json:"exclude_cidrs,omitempty" db:"exclude_cidrs"`
+	ExcludeUserAgents []string `json:"exclude_user_agents,omitempty" db:"exclude_user_agents"`
+	// Only requesters in AllowCIDRs (when set) and not in DenyCIDRs are
+	// redirected
+	AllowCIDRs []string `json:"allow_cidrs,omitempty" db:"allow_cidrs"`
+	DenyCIDRs  []string `json:"deny_cidrs,omitempty" db:"deny_cidrs"`
+	// Networks are the CIDR lists above parsed, set when the resolver loads
+	// the link so that redirects don't parse them
+	Networks *LinkNetworks `json:"-" db:"-"`
+	// Disabled links belong to a suspended account and don't redirect. It
+	// is only changed by suspending and reinstating the account.
+	Disabled bool `json:"disabled,omitempty" db:"disabled"`
+	// TenantID is the tenant the link was created in, "" for the default
+	// one; it owns the link's domain
+	TenantID string `json:"tenant_id,omitempty" db:"tenant_id"`
+	// LastClickedAt is when the last counted click was saved, so it lags by
+	// up to the click sync interval; nil if never clicked
+	LastClickedAt *time.Time `json:"last_clicked_at,omitempty" db:"last_clicked_at"`
+	// RateLimit caps the redirects served per RateLimitWindow; nil for no
+	// limit
+	RateLimit       *int   `json:"rate_limit,omitempty" db:"rate_limit"`
+	RateLimitWindow string `json:"rate_limit_window,omitempty" db:"rate_limit_window"`
+	// UpdatedAt and Region are when and where the link was last written;
+	// see replication.go
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	Region    string    `json:"-" db:"region"`
+	// Creation is only set on links being created; it is read separately
+	// with GetCreation and isn't replicated
+	Creation *CreationContext `json:"-" db:"-"`
+}
+
+// LinkNetworks are a link's ExcludeCIDRs, AllowCIDRs and DenyCIDRs parsed
+type LinkNetworks struct {
+	Exclude []netip.Prefix
+	Allow   []netip.Prefix
+	Deny    []netip.Prefix
+}
+
+// CreationContext is how a link was created: the client's address and user
+// agent, and the API key or OAuth client it was created with. Any may be
+// nil.
+type CreationContext struct {
+	IP        *string    `json:"ip,omitempty" db:"created_ip"`
+	UserAgent *string    `json:"user_agent,omitempty" db:"created_user_agent"`
+	APIKeyID  *uuid.UUID `json:"api_key_id,omitempty" db:"created_api_key_id"`
+	ClientID  *string    `json:"client_id,omitempty" db:"created_client_id"`
+}
+
+// Key identifies the link among all domains; see LinkKey
+func (l *Link) Key() string {
+	if l.Domain == nil {
+		return l.Code
+	}
+	return LinkKey(*l.Domain, l.Code)
+}
+
+// LinkKey is how storage and the cache address a link: codes are only
+// unique per short domain, so links on a custom domain are keyed
+// "domain/code" and those on the default domain ("") by their code alone.
+// Codes never contain '/'.
+func LinkKey(domain, code string) string {
+	if domain == "" {
+		return code
+	}
+	return domain + "/" + code
+}
+
+// SplitLinkKey is the inverse of LinkKey
+func SplitLinkKey(key string) (domain, code string) {
+	if i := strings.LastIndexByte(key, '/'); i >= 0 {
+		return key[:i], key[i+1:]
+	}
+	return "", key
+}
+
+// Preferences are an owner's defaults for fields omitted on link creation
+type Preferences struct {
+	OwnerID             uuid.UUID      `db:"owner_id"`
+	DefaultExpiry       *time.Duration `db:"default_expiry_seconds"`
+	DefaultRedirectType *int           `db:"default_redirect_type"`
+	DefaultTags         []string       `db:"default_tags"`
+	DefaultDomain       *string        `db:"default_domain"`
+	UpdatedAt           time.Time      `db:"updated_at"`
+}
+
+// Bundle is a public link-in-bio page served at /b/{slug}
+type Bundle struct {
+	Slug      string         `json:"slug" db:"slug"`
+	Title     string         `json:"title" db:"title"`
+	OwnerID   uuid.UUID      `json:"owner_id" db:"owner_id"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at" db:"updated_at"`
+	Entries   []*BundleEntry `json:"entries" db:"-"`
+}
+
+type BundleEntry struct {
+	ID         int64  `json:"id" db:"id"`
+	Position   int    `json:"position" db:"position"`
+	Title      string `json:"title" db:"title"`
+	URL        string `json:"url" db:"url"`
+	ClickCount int64  `json:"click_count" db:"click_count"`
+}
+
+// Campaign groups an owner's links for aggregate reporting
+type Campaign struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	OwnerID     uuid.UUID `json:"owner_id" db:"owner_id"`
+	Name        string    `json:"name" db:"name"`
+	Description *string   `json:"description,omitempty" db:"description"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CampaignTotals aggregates the links of a campaign
+type CampaignTotals struct {
+	Links       int   `db:"links"`
+	TotalClicks int64 `db:"total_clicks"`
+}
+
+// NotificationSettings holds an owner's notification channels and opt-ins
+type NotificationSettings struct {
+	OwnerID       uuid.UUID `db:"owner_id"`
+	Email         *string   `db:"email"`
+	EmailEnabled  bool      `db:"email_enabled"`
+	WebhookURL    *string   `db:"webhook_url"`
+	DaysBefore    int       `db:"days_before"`
+	ClicksPercent int       `db:"clicks_percent"`
+	UpdatedAt     time.Time `db:"updated_at"`
+
+	// Per-category opt-ins; Digest is "off", "week" or "month"
+	ExpiryReminders bool   `db:"expiry_reminders"`
+	AbuseNotices    bool   `db:"abuse_notices"`
+	Digest          string `db:"digest"`
+}
+
+// Destination health statuses
+const (
+	HealthAlive    = "alive"
+	HealthNotFound = "not_found"
+	HealthParked   = "parked"
+	// HealthError is a server error or an unreachable destination, which
+	// may well be temporary
+	HealthError = "error"
+)
+
+// LinkHealth is the outcome of checking a link's destination
+type LinkHealth struct {
+	Status     string    `json:"status" db:"status"`
+	HTTPStatus *int      `json:"http_status,omitempty" db:"http_status"`
+	CheckedAt  time.Time `json:"checked_at" db:"checked_at"`
+}
+
+// Dead reports whether the destination is gone for good rather than
+// temporarily failing
+func (h *LinkHealth) Dead() bool {
+	return h.Status == HealthNotFound || h.Status == HealthParked
+}
+
+// HealthCandidate is a link whose destination is due a check, with its last
+// result and its owner's notification settings, either of which may be nil
+type HealthCandidate struct {
+	Link     *Link
+	Health   *LinkHealth
+	Settings *NotificationSettings
+}
+
+// SitemapLink is a link as its domain's sitemap sees it. Listed is whether
+// it may be in the sitemap at all.
+type SitemapLink struct {
+	Code      string
+	UpdatedAt time.Time
+	ExpiresAt *time.Time
+	Listed    bool
+}
+
+// FraudAlert records a link whose clicks looked manufactured; Detail
+// depends on Kind
+type FraudAlert struct {
+	ID        int64          `json:"id" db:"id"`
+	Code      string         `json:"code" db:"code"`
+	Domain    *string        `json:"domain,omitempty" db:"domain"`
+	OwnerID   *uuid.UUID     `json:"owner_id,omitempty" db:"owner_id"`
+	Kind      string         `json:"kind" db:"kind"`
+	Detail    map[string]any `json:"detail" db:"detail"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+}
+
+// User is what is known about an account, which exists only as the owner
+// ID (the OIDC sub) on links and settings. Email is the notification
+// address, if the owner gave one.
+type User struct {
+	OwnerID    uuid.UUID   `json:"owner_id"`
+	Email      *string     `json:"email,omitempty"`
+	Links      int         `json:"links"`
+	Suspension *Suspension `json:"suspension,omitempty"`
+}
+
+// Usage counts an owner's links, and how many of them were created in the
+// current day. ShortDomains are the custom domains the links are on.
+type Usage struct {
+	Links        int      `json:"links"`
+	CreatesToday int      `json:"creates_today"`
+	ShortDomains []string `json:"short_domains"`
+}
+
+// BillingSubscription is the Stripe subscription paying for an owner's plan
+type BillingSubscription struct {
+	OwnerID           uuid.UUID  `json:"owner_id"`
+	CustomerID        string     `json:"customer_id"`
+	SubscriptionID    string     `json:"subscription_id"`
+	Status            string     `json:"status"`
+	PriceID           string     `json:"price_id"`
+	CurrentPeriodEnd  *time.Time `json:"current_period_end,omitempty"`
+	CancelAtPeriodEnd bool       `json:"cancel_at_period_end"`
+	// EventCreatedAt is when Stripe created the event this state is from,
+	// so that deliveries arriving out of order don't roll it back
+	EventCreatedAt time.Time `json:"-"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// OwnerPlan is the plan an operator put an owner on
+type OwnerPlan struct {
+	OwnerID   uuid.UUID `json:"owner_id"`
+	Plan      string    `json:"plan"`
+	UpdatedBy string    `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Suspension records why and by whom an account was suspended
+type Suspension struct {
+	OwnerID     uuid.UUID `json:"-" db:"owner_id"`
+	Reason      string    `json:"reason" db:"reason"`
+	SuspendedBy string    `json:"suspended_by" db:"suspended_by"`
+	SuspendedAt time.Time `json:"suspended_at" db:"suspended_at"`
+}
+
+// TermsAcceptance records that an owner accepted a terms of service version
+type TermsAcceptance struct {
+	OwnerID    uuid.UUID `db:"owner_id"`
+	Version    string    `db:"version"`
+	AcceptedAt time.Time `db:"accepted_at"`
+}
+
+// ReminderCandidate is a link that is close to expiring, together with the
+// settings of the owner who opted in to hear about it
+type ReminderCandidate struct {
+	Link     *Link
+	Kind     string
+	Settings *NotificationSettings
+}
+
+// WebhookSubscription receives click events for all of its owner's links.
+// Only SampleRate of clicks are sent, in batches of up to BatchSize events
+// or BatchInterval, whichever comes first.
+type WebhookSubscription struct {
+	ID            uuid.UUID     `db:"id"`
+	OwnerID       uuid.UUID     `db:"owner_id"`
+	URL           string        `db:"url"`
+	Secret        string        `db:"secret"`
+	SampleRate    float64       `db:"sample_rate"`
+	BatchSize     int           `db:"batch_size"`
+	BatchInterval time.Duration `db:"batch_interval_seconds"`
+	CreatedAt     time.Time     `db:"created_at"`
+}
+
+// APIKey lets a client act for its owner without signing in. Prefix is
+// the start of the key, shown so owners can tell keys apart; only the hash
+// of the whole key is stored.
+type APIKey struct {
+	ID      uuid.UUID `db:"id"`
+	OwnerID uuid.UUID `db:"owner_id"`
+	// TenantID is the tenant the key was created in, "" for the default
+	TenantID   string     `db:"tenant_id"`
+	Name       string     `db:"name"`
+	Prefix     string     `db:"prefix"`
+	Hash       string     `db:"key_hash"`
+	CreatedAt  time.Time  `db:"created_at"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+}
+
+// DeepLinkKey signs the tokens of an owner's deep links. The owner's apps
+// verify tokens with the same secret.
+type DeepLinkKey struct {
+	ID        uuid.UUID `db:"id"`
+	OwnerID   uuid.UUID `db:"owner_id"`
+	Name      string    `db:"name"`
+	Secret    string    `db:"secret"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// AliasReservation keeps aliases matching Pattern for one owner. Pattern is
+// an alias, or a prefix followed by "*", in lower case.
+type AliasReservation struct {
+	ID        uuid.UUID `db:"id"`
+	OwnerID   uuid.UUID `db:"owner_id"`
+	Pattern   string    `db:"pattern"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// IntegrationHook is a REST hook an automation platform subscribed to one
+// of its owner's triggers; see pkg/integrations/triggers. Threshold is the
+// click count a link_clicks hook fires at.
+type IntegrationHook struct {
+	ID        uuid.UUID `db:"id"`
+	OwnerID   uuid.UUID `db:"owner_id"`
+	Trigger   string    `db:"trigger"`
+	TargetURL string    `db:"target_url"`
+	Threshold int       `db:"threshold"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// OutboxEvent is a pending notification about a change to a link
+type OutboxEvent struct {
+	ID        int64           `db:"id"`
+	EventID   uuid.UUID       `db:"event_id"`
+	Type      string          `db:"event_type"`
+	OwnerID   *uuid.UUID      `db:"owner_id"`
+	Payload   json.RawMessage `db:"payload"`
+	CreatedAt time.Time       `db:"created_at"`
+	Attempts  int             `db:"attempts"`
+}
+
+// DigestRecipient is an owner whose next digest is due. SentUntil is the end
+// of the last period a digest went out for, if any.
+type DigestRecipient struct {
+	Settings  *NotificationSettings
+	SentUntil *time.Time
+}
+
+// Orders ListByOwner can return links in
+const (
+	// LinkOrderNewest lists the most recently created links first
+	LinkOrderNewest = "newest"
+	// LinkOrderStalest lists links never clicked first, then those whose
+	// last click is longest ago, to find links nobody uses
+	LinkOrderStalest = "stalest"
+	// LinkOrderClicks lists the most clicked links first
+	LinkOrderClicks = "clicks"
+	// LinkOrderLastClicked lists the most recently clicked links first and
+	// links never clicked last
+	LinkOrderLastClicked = "last_clicked"
+	// LinkOrderExpiring lists links expiring soonest first and links
+	// without an expiry last
+	LinkOrderExpiring = "expiring"
+)
+
+// Link statuses ListByOwner can filter by. A disabled link is only
+// disabled, whether or not it has also expired.
+const (
+	LinkStatusActive   = "active"
+	LinkStatusExpired  = "expired"
+	LinkStatusDisabled = "disabled"
+)
+
+// LinkQuery orders and filters ListByOwner. The zero value lists every link,
+// newest first.
+type LinkQuery struct {
+	// Order is one of the LinkOrder constants
+	Order string
+	// Status is one of the LinkStatus constants
+	Status      string
+	HasPassword *bool
+	// Domain is the short domain links are on, "" for the default one
+	Domain *string
+	Tag    string
+	// CreatedBefore and CreatedAfter are exclusive
+	CreatedBefore *time.Time
+	CreatedAfter  *time.Time
+	// MinClicks only lists links clicked at least this often
+	MinClicks int
+}
+
+// Job statuses
+const (
+	JobPending = "pending"
+	JobRunning = "running"
+	JobDone    = "done"
+	JobDead    = "dead"
+)
+
+// Job is a unit of background work run by pkg/jobs
+type Job struct {
+	ID          int64           `json:"id" db:"id"`
+	Kind        string          `json:"kind" db:"kind"`
+	Payload     json.RawMessage `json:"payload" db:"payload"`
+	Status      string          `json:"status" db:"status"`
+	Attempts    int             `json:"attempts" db:"attempts"`
+	MaxAttempts int             `json:"max_attempts" db:"max_attempts"`
+	RunAt       time.Time       `json:"run_at" db:"run_at"`
+	LastError   *string         `json:"last_error,omitempty" db:"last_error"`
+	UniqueKey   *string         `json:"unique_key,omitempty" db:"unique_key"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
 }
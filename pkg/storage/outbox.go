@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresOutboxStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresOutboxStorage(pool *pgxpool.Pool) *PostgresOutboxStorage {
+	return &PostgresOutboxStorage{pool: pool}
+}
+
+func (s *PostgresOutboxStorage) AddEventTx(ctx context.Context, tx pgx.Tx, event *OutboxEvent) error {
+	query := `INSERT INTO outbox (event_id, event_type, owner_id, payload, created_at) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	return tx.QueryRow(ctx, query, event.EventID, event.Type, event.OwnerID, event.Payload, event.CreatedAt).Scan(&event.ID)
+}
+
+func (s *PostgresOutboxStorage) ClaimEvents(ctx context.Context, limit int, lease time.Duration) ([]*OutboxEvent, error) {
+	query := `
+		UPDATE outbox SET locked_until = NOW() + make_interval(secs => $2), attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE published_at IS NULL AND (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_id, event_type, owner_id, payload, created_at, attempts`
+	rows, err := s.pool.Query(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*OutboxEvent
+	for rows.Next() {
+		var e OutboxEvent
+		if err := rows.Scan(&e.ID, &e.EventID, &e.Type, &e.OwnerID, &e.Payload, &e.CreatedAt, &e.Attempts); err != nil {
+			return nil, err
+		}
+		events = append(events, &e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// RETURNING doesn't preserve the subquery's order
+	sort.Slice(events, func(i, j int) bool { return events[i].ID < events[j].ID })
+	return events, nil
+}
+
+func (s *PostgresOutboxStorage) MarkPublished(ctx context.Context, id int64) error {
+	_, err := s.pool.Exec(ctx, `UPDATE outbox SET published_at = NOW(), locked_until = NULL, last_error = NULL WHERE id = $1`, id)
+	return err
+}
+
+func (s *PostgresOutboxStorage) MarkFailed(ctx context.Context, id int64, retryAt time.Time, lastErr string) error {
+	_, err := s.pool.Exec(ctx, `UPDATE outbox SET locked_until = $2, last_error = $3 WHERE id = $1`, id, retryAt, lastErr)
+	return err
+}
+
+func (s *PostgresOutboxStorage) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM outbox WHERE published_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
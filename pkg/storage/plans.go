@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresPlanStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresPlanStorage(pool *pgxpool.Pool) *PostgresPlanStorage {
+	return &PostgresPlanStorage{pool: pool}
+}
+
+func (s *PostgresPlanStorage) GetOwnerPlan(ctx context.Context, ownerID uuid.UUID) (*OwnerPlan, error) {
+	query := `SELECT owner_id, plan, updated_by, updated_at FROM owner_plans WHERE owner_id = $1`
+	var p OwnerPlan
+	err := s.pool.QueryRow(ctx, query, ownerID).Scan(&p.OwnerID, &p.Plan, &p.UpdatedBy, &p.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (s *PostgresPlanStorage) DeleteOwnerPlan(ctx context.Context, ownerID uuid.UUID) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM owner_plans WHERE owner_id = $1`, ownerID)
+	return err
+}
+
+func (s *PostgresPlanStorage) SetOwnerPlan(ctx context.Context, p *OwnerPlan) error {
+	query := `INSERT INTO owner_plans (owner_id, plan, updated_by, updated_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (owner_id) DO UPDATE SET plan = EXCLUDED.plan, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at`
+	_, err := s.pool.Exec(ctx, query, p.OwnerID, p.Plan, p.UpdatedBy, p.UpdatedAt)
+	return err
+}
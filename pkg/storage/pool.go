@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"url-shortener/pkg/logging"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PoolConfig tunes the Postgres connection pool. Zero values keep pgx's
+// defaults, or what the database URL sets with pool_max_conns and the like.
+type PoolConfig struct {
+	MaxConns          int32
+	MinConns          int32
+	MaxConnIdleTime   time.Duration
+	MaxConnLifetime   time.Duration
+	HealthCheckPeriod time.Duration
+	// ExecMode is how pgx runs queries: cache_statement prepares each
+	// statement once per connection, cache_describe and describe_exec only
+	// describe them, and exec and simple_protocol prepare nothing, as
+	// transaction-mode poolers such as PgBouncer require
+	ExecMode string
+	// StatementCacheCapacity is how many statements, or descriptions, each
+	// connection keeps for cache_statement and cache_describe
+	StatementCacheCapacity int
+	// PrepareStatements prepares the redirect path's queries on each new
+	// connection, whatever ExecMode is, so the first redirect on a
+	// connection doesn't wait for it
+	PrepareStatements bool
+	// ReadOnly opens every transaction read-only, so that Postgres refuses
+	// writes; see GuardSchema
+	ReadOnly bool
+	// Logger, when set, logs failed queries and those taking SlowQuery or
+	// longer (0 logs only failures); see tracing.go
+	Logger    *logging.Logger
+	SlowQuery time.Duration
+}
+
+var execModes = map[string]pgx.QueryExecMode{
+	"cache_statement": pgx.QueryExecModeCacheStatement,
+	"cache_describe":  pgx.QueryExecModeCacheDescribe,
+	"describe_exec":   pgx.QueryExecModeDescribeExec,
+	"exec":            pgx.QueryExecModeExec,
+	"simple_protocol": pgx.QueryExecModeSimpleProtocol,
+}
+
+// hotStatements are the queries of every uncached redirect and every click
+// sync. Each is prepared under its own text, which pgx looks up before the
+// exec mode applies, so they run prepared on connections that have them and
+// as usual on others.
+var hotStatements = []string{getByCodeQuery, addClickCountQuery}
+
+// NewPool connects a pool to databaseURL tuned by cfg
+func NewPool(ctx context.Context, databaseURL string, cfg PoolConfig) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxConns > 0 {
+		poolConfig.MaxConns = cfg.MaxConns
+	}
+	if cfg.MinConns > 0 {
+		poolConfig.MinConns = cfg.MinConns
+	}
+	if cfg.MaxConnIdleTime > 0 {
+		poolConfig.MaxConnIdleTime = cfg.MaxConnIdleTime
+	}
+	if cfg.MaxConnLifetime > 0 {
+		poolConfig.MaxConnLifetime = cfg.MaxConnLifetime
+	}
+	if cfg.HealthCheckPeriod > 0 {
+		poolConfig.HealthCheckPeriod = cfg.HealthCheckPeriod
+	}
+	if cfg.ExecMode != "" {
+		mode, ok := execModes[cfg.ExecMode]
+		if !ok {
+			return nil, fmt.Errorf("unknown query exec mode %q", cfg.ExecMode)
+		}
+		poolConfig.ConnConfig.DefaultQueryExecMode = mode
+	}
+	if cfg.StatementCacheCapacity > 0 {
+		poolConfig.ConnConfig.StatementCacheCapacity = cfg.StatementCacheCapacity
+		poolConfig.ConnConfig.DescriptionCacheCapacity = cfg.StatementCacheCapacity
+	}
+	if cfg.PrepareStatements {
+		poolConfig.AfterConnect = prepareHotStatements
+	}
+	if cfg.ReadOnly {
+		poolConfig.ConnConfig.RuntimeParams["default_transaction_read_only"] = "on"
+	}
+	if cfg.Logger != nil {
+		poolConfig.ConnConfig.Tracer = newQueryTracer(cfg.Logger, cfg.SlowQuery)
+	}
+	return pgxpool.NewWithConfig(ctx, poolConfig)
+}
+
+func prepareHotStatements(ctx context.Context, conn *pgx.Conn) error {
+	for _, sql := range hotStatements {
+		if _, err := conn.Prepare(ctx, sql, sql); err != nil {
+			return fmt.Errorf("prepare statement: %w", err)
+		}
+	}
+	return nil
+}
@@ -3,11 +3,77 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// linkColumns is the column list read by scanLink, in linkFields order
+const linkColumns = `code, long_url, alias, password_hash, expires_at, max_clicks, click_count, created_at, owner_id, redirect_type, domain, public_stats, exclude_cidrs, exclude_user_agents, campaign_id, description, metadata, param_rules, allow_cidrs, deny_cidrs, disabled, tenant_id, last_clicked_at, rate_limit, rate_limit_window, updated_at, region, noindex`
+
+func linkFields(link *Link) []any {
+	return []any{&link.Code, &link.LongURL, &link.Alias, &link.PasswordHash, &link.ExpiresAt, &link.MaxClicks, &link.ClickCount, &link.CreatedAt, &link.OwnerID, &link.RedirectType, &link.Domain, &link.PublicStats, &link.ExcludeCIDRs, &link.ExcludeUserAgents, &link.CampaignID, &link.Description, &link.Metadata, &link.ParamRules, &link.AllowCIDRs, &link.DenyCIDRs, &link.Disabled, &link.TenantID, &link.LastClickedAt, &link.RateLimit, &link.RateLimitWindow, &link.UpdatedAt, &link.Region, &link.NoIndex}
+}
+
+// prefixed qualifies every column in a comma-separated list, e.g. for joins
+func prefixed(prefix, columns string) string {
+	parts := strings.Split(columns, ",")
+	for i, col := range parts {
+		parts[i] = prefix + strings.TrimSpace(col)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func scanLink(row pgx.Row) (*Link, error) {
+	var link Link
+	err := row.Scan(linkFields(&link)...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return scanned(&link), nil
+}
+
+// scanned finishes a link read with linkFields: the default domain is
+// stored as an empty string but is nil on Link
+func scanned(link *Link) *Link {
+	if link.Domain != nil && *link.Domain == "" {
+		link.Domain = nil
+	}
+	return link
+}
+
+// storedDomain is the domain column value for link
+func storedDomain(link *Link) string {
+	if link.Domain == nil {
+		return ""
+	}
+	return *link.Domain
+}
+
+// writeTime stamps link as written now, for Create and Update
+func writeTime(link *Link) time.Time {
+	link.UpdatedAt = time.Now().UTC()
+	return link.UpdatedAt
+}
+
+// splitLinkKeys splits keys into parallel domain and code arrays, to be
+// matched with (domain, code) IN (SELECT * FROM unnest($1::text[], $2::text[]))
+func splitLinkKeys(keys []string) (domains, codes []string) {
+	domains = make([]string, len(keys))
+	codes = make([]string, len(keys))
+	for i, key := range keys {
+		domains[i], codes[i] = SplitLinkKey(key)
+	}
+	return domains, codes
+}
+
 type PostgresLinkStorage struct {
 	pool *pgxpool.Pool
 }
@@ -17,59 +83,230 @@ func NewPostgresLinkStorage(pool *pgxpool.Pool) *PostgresLinkStorage {
 }
 
 func (s *PostgresLinkStorage) CreateTx(ctx context.Context, tx pgx.Tx, link *Link) error {
-	query := `INSERT INTO links (code, long_url, alias, password_hash, expires_at, max_clicks, owner_id) VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err := tx.Exec(ctx, query, link.Code, link.LongURL, link.Alias, link.PasswordHash, link.ExpiresAt, link.MaxClicks, link.OwnerID)
+	creation := creationOf(link)
+	query := `INSERT INTO links (code, long_url, alias, password_hash, expires_at, max_clicks, owner_id, redirect_type, domain, public_stats, exclude_cidrs, exclude_user_agents, campaign_id, description, metadata, param_rules, allow_cidrs, deny_cidrs, tenant_id, rate_limit, rate_limit_window, updated_at, region, noindex, created_ip, created_user_agent, created_api_key_id, created_client_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28)`
+	_, err := tx.Exec(ctx, query, link.Code, link.LongURL, link.Alias, link.PasswordHash, link.ExpiresAt, link.MaxClicks, link.OwnerID, link.RedirectType, storedDomain(link), link.PublicStats, link.ExcludeCIDRs, link.ExcludeUserAgents, link.CampaignID, link.Description, link.Metadata, link.ParamRules, link.AllowCIDRs, link.DenyCIDRs, link.TenantID, link.RateLimit, link.RateLimitWindow, writeTime(link), link.Region, link.NoIndex, creation.IP, creation.UserAgent, creation.APIKeyID, creation.ClientID)
 	return err
 }
 
 func (s *PostgresLinkStorage) Create(ctx context.Context, link *Link) error {
-	query := `INSERT INTO links (code, long_url, alias, password_hash, expires_at, max_clicks, owner_id) VALUES ($1, $2, $3, $4, $5, $6, $7)`
-	_, err := s.pool.Exec(ctx, query, link.Code, link.LongURL, link.Alias, link.PasswordHash, link.ExpiresAt, link.MaxClicks, link.OwnerID)
+	creation := creationOf(link)
+	query := `INSERT INTO links (code, long_url, alias, password_hash, expires_at, max_clicks, owner_id, redirect_type, domain, public_stats, exclude_cidrs, exclude_user_agents, campaign_id, description, metadata, param_rules, allow_cidrs, deny_cidrs, tenant_id, rate_limit, rate_limit_window, updated_at, region, noindex, created_ip, created_user_agent, created_api_key_id, created_client_id) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28)`
+	_, err := s.pool.Exec(ctx, query, link.Code, link.LongURL, link.Alias, link.PasswordHash, link.ExpiresAt, link.MaxClicks, link.OwnerID, link.RedirectType, storedDomain(link), link.PublicStats, link.ExcludeCIDRs, link.ExcludeUserAgents, link.CampaignID, link.Description, link.Metadata, link.ParamRules, link.AllowCIDRs, link.DenyCIDRs, link.TenantID, link.RateLimit, link.RateLimitWindow, writeTime(link), link.Region, link.NoIndex, creation.IP, creation.UserAgent, creation.APIKeyID, creation.ClientID)
 	return err
 }
 
-func (s *PostgresLinkStorage) GetByCodeTx(ctx context.Context, tx pgx.Tx, code string) (*Link, error) {
-	query := `SELECT code, long_url, alias, password_hash, expires_at, max_clicks, click_count, created_at, owner_id FROM links WHERE code = $1`
-	row := tx.QueryRow(ctx, query, code)
-	var link Link
-	err := row.Scan(&link.Code, &link.LongURL, &link.Alias, &link.PasswordHash, &link.ExpiresAt, &link.MaxClicks, &link.ClickCount, &link.CreatedAt, &link.OwnerID)
+func (s *PostgresLinkStorage) GetByCodeTx(ctx context.Context, tx pgx.Tx, key string) (*Link, error) {
+	domain, code := SplitLinkKey(key)
+	return scanLink(tx.QueryRow(ctx, getByCodeQuery, domain, code))
+}
+
+// getByCodeQuery is prepared on new connections; see hotStatements
+const getByCodeQuery = `SELECT ` + linkColumns + ` FROM links WHERE domain = $1 AND code = $2`
+
+func (s *PostgresLinkStorage) GetByCode(ctx context.Context, key string) (*Link, error) {
+	domain, code := SplitLinkKey(key)
+	return scanLink(s.pool.QueryRow(ctx, getByCodeQuery, domain, code))
+}
+
+func (s *PostgresLinkStorage) GetByCodes(ctx context.Context, keys []string) ([]*Link, error) {
+	domains, codes := splitLinkKeys(keys)
+	query := `SELECT ` + linkColumns + ` FROM links WHERE (domain, code) IN (SELECT * FROM unnest($1::text[], $2::text[]))`
+	rows, err := s.pool.Query(ctx, query, domains, codes)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, nil
-		}
 		return nil, err
 	}
-	return &link, nil
-}
+	defer rows.Close()
 
-func (s *PostgresLinkStorage) GetByCode(ctx context.Context, code string) (*Link, error) {
-	query := `SELECT code, long_url, alias, password_hash, expires_at, max_clicks, click_count, created_at, owner_id FROM links WHERE code = $1`
-	row := s.pool.QueryRow(ctx, query, code)
-	var link Link
-	err := row.Scan(&link.Code, &link.LongURL, &link.Alias, &link.PasswordHash, &link.ExpiresAt, &link.MaxClicks, &link.ClickCount, &link.CreatedAt, &link.OwnerID)
-	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return nil, nil
+	var links []*Link
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, err
 		}
-		return nil, err
+		links = append(links, link)
 	}
-	return &link, nil
+	return links, rows.Err()
 }
 
 func (s *PostgresLinkStorage) Update(ctx context.Context, link *Link) error {
-	query := `UPDATE links SET long_url = $2, alias = $3, password_hash = $4, expires_at = $5, max_clicks = $6, click_count = $7, owner_id = $8 WHERE code = $1`
-	_, err := s.pool.Exec(ctx, query, link.Code, link.LongURL, link.Alias, link.PasswordHash, link.ExpiresAt, link.MaxClicks, link.ClickCount, link.OwnerID)
+	query := `UPDATE links SET long_url = $2, alias = $3, password_hash = $4, expires_at = $5, max_clicks = $6, click_count = $7, owner_id = $8, redirect_type = $9, public_stats = $10, exclude_cidrs = $11, exclude_user_agents = $12, campaign_id = $13, description = $14, metadata = $15, param_rules = $16, allow_cidrs = $17, deny_cidrs = $18, rate_limit = $20, rate_limit_window = $21, updated_at = $22, region = $23, noindex = $24 WHERE code = $1 AND domain = $19`
+	_, err := s.pool.Exec(ctx, query, link.Code, link.LongURL, link.Alias, link.PasswordHash, link.ExpiresAt, link.MaxClicks, link.ClickCount, link.OwnerID, link.RedirectType, link.PublicStats, link.ExcludeCIDRs, link.ExcludeUserAgents, link.CampaignID, link.Description, link.Metadata, link.ParamRules, link.AllowCIDRs, link.DenyCIDRs, storedDomain(link), link.RateLimit, link.RateLimitWindow, writeTime(link), link.Region, link.NoIndex)
+	return err
+}
+
+func (s *PostgresLinkStorage) UpdateTx(ctx context.Context, tx pgx.Tx, link *Link) error {
+	query := `UPDATE links SET long_url = $2, alias = $3, password_hash = $4, expires_at = $5, max_clicks = $6, click_count = $7, owner_id = $8, redirect_type = $9, public_stats = $10, exclude_cidrs = $11, exclude_user_agents = $12, campaign_id = $13, description = $14, metadata = $15, param_rules = $16, allow_cidrs = $17, deny_cidrs = $18, rate_limit = $20, rate_limit_window = $21, updated_at = $22, region = $23, noindex = $24 WHERE code = $1 AND domain = $19`
+	_, err := tx.Exec(ctx, query, link.Code, link.LongURL, link.Alias, link.PasswordHash, link.ExpiresAt, link.MaxClicks, link.ClickCount, link.OwnerID, link.RedirectType, link.PublicStats, link.ExcludeCIDRs, link.ExcludeUserAgents, link.CampaignID, link.Description, link.Metadata, link.ParamRules, link.AllowCIDRs, link.DenyCIDRs, storedDomain(link), link.RateLimit, link.RateLimitWindow, writeTime(link), link.Region, link.NoIndex)
+	return err
+}
+
+func (s *PostgresLinkStorage) Delete(ctx context.Context, key string) error {
+	domain, code := SplitLinkKey(key)
+	_, err := s.pool.Exec(ctx, deleteLinkQuery, domain, code)
+	return err
+}
+
+func (s *PostgresLinkStorage) DeleteTx(ctx context.Context, tx pgx.Tx, key string) error {
+	domain, code := SplitLinkKey(key)
+	_, err := tx.Exec(ctx, deleteLinkQuery, domain, code)
 	return err
 }
 
-func (s *PostgresLinkStorage) Delete(ctx context.Context, code string) error {
-	query := `DELETE FROM links WHERE code = $1`
-	_, err := s.pool.Exec(ctx, query, code)
+// addClickCountQuery is prepared on new connections; see hotStatements
+const addClickCountQuery = `UPDATE links SET click_count = click_count + $3, last_clicked_at = NOW() WHERE domain = $1 AND code = $2`
+
+func (s *PostgresLinkStorage) AddClickCount(ctx context.Context, key string, n int64) error {
+	domain, code := SplitLinkKey(key)
+	_, err := s.pool.Exec(ctx, addClickCountQuery, domain, code, n)
 	return err
 }
 
-func (s *PostgresLinkStorage) IncrementClickCount(ctx context.Context, code string) error {
-	query := `UPDATE links SET click_count = click_count + 1 WHERE code = $1`
-	_, err := s.pool.Exec(ctx, query, code)
+func (s *PostgresLinkStorage) ReplacePasswordHash(ctx context.Context, key, old, new string) error {
+	domain, code := SplitLinkKey(key)
+	_, err := s.pool.Exec(ctx, `UPDATE links SET password_hash = $4 WHERE domain = $1 AND code = $2 AND password_hash = $3`, domain, code, old, new)
 	return err
 }
+
+// linkOrders are the ORDER BY clauses of the link orders. Each is served by
+// an index on owner_id and its first column, see migrations 0026 and 0027.
+var linkOrders = map[string]string{
+	LinkOrderNewest:      `created_at DESC`,
+	LinkOrderStalest:     `last_clicked_at ASC NULLS FIRST, created_at`,
+	LinkOrderClicks:      `click_count DESC, created_at DESC`,
+	LinkOrderLastClicked: `last_clicked_at DESC NULLS LAST, created_at DESC`,
+	LinkOrderExpiring:    `expires_at ASC NULLS LAST, created_at DESC`,
+}
+
+// linkStatuses are the conditions of the link statuses, matching
+// Resolver.IsExpired
+var linkStatuses = map[string]string{
+	LinkStatusActive:   `NOT disabled AND (expires_at IS NULL OR expires_at > NOW()) AND (max_clicks IS NULL OR click_count < max_clicks)`,
+	LinkStatusExpired:  `NOT disabled AND (expires_at <= NOW() OR click_count >= max_clicks)`,
+	LinkStatusDisabled: `disabled`,
+}
+
+// ListByOwner returns a page of the owner's links matching query. Unknown
+// orders list the newest first and unknown statuses don't filter.
+func (s *PostgresLinkStorage) ListByOwner(ctx context.Context, ownerID uuid.UUID, q LinkQuery, limit, offset int) ([]*Link, error) {
+	query := `SELECT ` + linkColumns + ` FROM links WHERE owner_id = $1`
+	args := []any{ownerID}
+	if cond, ok := linkStatuses[q.Status]; ok {
+		query += " AND " + cond
+	}
+	if q.HasPassword != nil {
+		if *q.HasPassword {
+			query += " AND password_hash IS NOT NULL"
+		} else {
+			query += " AND password_hash IS NULL"
+		}
+	}
+	if q.Domain != nil {
+		args = append(args, *q.Domain)
+		query += fmt.Sprintf(" AND domain = $%d", len(args))
+	}
+	if q.Tag != "" {
+		args = append(args, q.Tag)
+		query += fmt.Sprintf(" AND EXISTS (SELECT 1 FROM link_tags t WHERE t.domain = links.domain AND t.code = links.code AND t.tag = $%d)", len(args))
+	}
+	if q.CreatedBefore != nil {
+		args = append(args, *q.CreatedBefore)
+		query += fmt.Sprintf(" AND created_at < $%d", len(args))
+	}
+	if q.CreatedAfter != nil {
+		args = append(args, *q.CreatedAfter)
+		query += fmt.Sprintf(" AND created_at > $%d", len(args))
+	}
+	if q.MinClicks > 0 {
+		args = append(args, q.MinClicks)
+		query += fmt.Sprintf(" AND click_count >= $%d", len(args))
+	}
+	orderBy, ok := linkOrders[q.Order]
+	if !ok {
+		orderBy = linkOrders[LinkOrderNewest]
+	}
+	args = append(args, limit, offset)
+	query += fmt.Sprintf(" ORDER BY %s LIMIT $%d OFFSET $%d", orderBy, len(args)-1, len(args))
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []*Link
+	for rows.Next() {
+		link, err := scanLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, link)
+	}
+	return links, rows.Err()
+}
+
+func (s *PostgresLinkStorage) GetTags(ctx context.Context, keys []string) (map[string][]string, error) {
+	domains, codes := splitLinkKeys(keys)
+	query := `SELECT domain, code, tag FROM link_tags WHERE (domain, code) IN (SELECT * FROM unnest($1::text[], $2::text[])) ORDER BY tag`
+	rows, err := s.pool.Query(ctx, query, domains, codes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make(map[string][]string)
+	for rows.Next() {
+		var domain, code, tag string
+		if err := rows.Scan(&domain, &code, &tag); err != nil {
+			return nil, err
+		}
+		key := LinkKey(domain, code)
+		tags[key] = append(tags[key], tag)
+	}
+	return tags, rows.Err()
+}
+
+func (s *PostgresLinkStorage) SetTags(ctx context.Context, key string, tags []string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if err := s.SetTagsTx(ctx, tx, key, tags); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+func (s *PostgresLinkStorage) SetTagsTx(ctx context.Context, tx pgx.Tx, key string, tags []string) error {
+	domain, code := SplitLinkKey(key)
+	if _, err := tx.Exec(ctx, `DELETE FROM link_tags WHERE domain = $1 AND code = $2`, domain, code); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.Exec(ctx, `INSERT INTO link_tags (domain, code, tag) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`, domain, code, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *PostgresLinkStorage) ListTagsByOwner(ctx context.Context, ownerID uuid.UUID) ([]string, error) {
+	query := `SELECT DISTINCT t.tag FROM link_tags t JOIN links l ON l.domain = t.domain AND l.code = t.code WHERE l.owner_id = $1 ORDER BY t.tag`
+	rows, err := s.pool.Query(ctx, query, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresPreferencesStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresPreferencesStorage(pool *pgxpool.Pool) *PostgresPreferencesStorage {
+	return &PostgresPreferencesStorage{pool: pool}
+}
+
+func (s *PostgresPreferencesStorage) GetPreferences(ctx context.Context, ownerID uuid.UUID) (*Preferences, error) {
+	query := `SELECT owner_id, default_expiry_seconds, default_redirect_type, default_tags, default_domain, updated_at FROM owner_preferences WHERE owner_id = $1`
+	var prefs Preferences
+	var expirySeconds *int64
+	err := s.pool.QueryRow(ctx, query, ownerID).Scan(&prefs.OwnerID, &expirySeconds, &prefs.DefaultRedirectType, &prefs.DefaultTags, &prefs.DefaultDomain, &prefs.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if expirySeconds != nil {
+		expiry := time.Duration(*expirySeconds) * time.Second
+		prefs.DefaultExpiry = &expiry
+	}
+	return &prefs, nil
+}
+
+func (s *PostgresPreferencesStorage) SavePreferences(ctx context.Context, prefs *Preferences) error {
+	var expirySeconds *int64
+	if prefs.DefaultExpiry != nil {
+		seconds := int64(prefs.DefaultExpiry.Seconds())
+		expirySeconds = &seconds
+	}
+	tags := prefs.DefaultTags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	query := `INSERT INTO owner_preferences (owner_id, default_expiry_seconds, default_redirect_type, default_tags, default_domain, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (owner_id) DO UPDATE SET
+			default_expiry_seconds = EXCLUDED.default_expiry_seconds,
+			default_redirect_type = EXCLUDED.default_redirect_type,
+			default_tags = EXCLUDED.default_tags,
+			default_domain = EXCLUDED.default_domain,
+			updated_at = EXCLUDED.updated_at`
+	_, err := s.pool.Exec(ctx, query, prefs.OwnerID, expirySeconds, prefs.DefaultRedirectType, tags, prefs.DefaultDomain, prefs.UpdatedAt)
+	return err
+}
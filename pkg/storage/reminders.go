@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Reminder kinds
+const (
+	ReminderExpiry = "expiry"
+	ReminderClicks = "clicks"
+)
+
+type PostgresReminderStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresReminderStorage(pool *pgxpool.Pool) *PostgresReminderStorage {
+	return &PostgresReminderStorage{pool: pool}
+}
+
+const notificationSettingsColumns = `owner_id, email, email_enabled, webhook_url, days_before, clicks_percent, updated_at, expiry_reminders, abuse_notices, digest`
+
+func notificationSettingsFields(ns *NotificationSettings) []any {
+	return []any{&ns.OwnerID, &ns.Email, &ns.EmailEnabled, &ns.WebhookURL, &ns.DaysBefore, &ns.ClicksPercent, &ns.UpdatedAt, &ns.ExpiryReminders, &ns.AbuseNotices, &ns.Digest}
+}
+
+func (s *PostgresReminderStorage) GetNotificationSettings(ctx context.Context, ownerID uuid.UUID) (*NotificationSettings, error) {
+	query := `SELECT ` + notificationSettingsColumns + ` FROM notification_settings WHERE owner_id = $1`
+	var ns NotificationSettings
+	err := s.pool.QueryRow(ctx, query, ownerID).Scan(notificationSettingsFields(&ns)...)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &ns, nil
+}
+
+func (s *PostgresReminderStorage) SaveNotificationSettings(ctx context.Context, ns *NotificationSettings) error {
+	query := `INSERT INTO notification_settings (` + notificationSettingsColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (owner_id) DO UPDATE SET
+			email = EXCLUDED.email,
+			email_enabled = EXCLUDED.email_enabled,
+			webhook_url = EXCLUDED.webhook_url,
+			days_before = EXCLUDED.days_before,
+			clicks_percent = EXCLUDED.clicks_percent,
+			updated_at = EXCLUDED.updated_at,
+			expiry_reminders = EXCLUDED.expiry_reminders,
+			abuse_notices = EXCLUDED.abuse_notices,
+			digest = EXCLUDED.digest`
+	_, err := s.pool.Exec(ctx, query, ns.OwnerID, ns.Email, ns.EmailEnabled, ns.WebhookURL, ns.DaysBefore, ns.ClicksPercent, ns.UpdatedAt, ns.ExpiryReminders, ns.AbuseNotices, ns.Digest)
+	return err
+}
+
+func (s *PostgresReminderStorage) FindReminderCandidates(ctx context.Context, now time.Time, limit int) ([]*ReminderCandidate, error) {
+	// Two windows: time-based expiry within days_before, and click-based
+	// expiry once clicks_percent of max_clicks is used
+	query := `
+		SELECT c.kind, ` + prefixed("l.", linkColumns) + `, ` + prefixed("n.", notificationSettingsColumns) + `
+		FROM (
+			SELECT 'expiry' AS kind, l.domain, l.code FROM links l
+			JOIN notification_settings n ON n.owner_id = l.owner_id
+			WHERE l.expires_at > $1 AND l.expires_at <= $1 + make_interval(days => n.days_before)
+			UNION ALL
+			SELECT 'clicks' AS kind, l.domain, l.code FROM links l
+			JOIN notification_settings n ON n.owner_id = l.owner_id
+			WHERE l.max_clicks IS NOT NULL AND l.click_count < l.max_clicks
+				AND l.click_count * 100 >= l.max_clicks * n.clicks_percent
+				AND (l.expires_at IS NULL OR l.expires_at > $1)
+		) c
+		JOIN links l ON l.domain = c.domain AND l.code = c.code
+		JOIN notification_settings n ON n.owner_id = l.owner_id
+		WHERE n.expiry_reminders AND (n.email_enabled OR n.webhook_url IS NOT NULL)
+			AND NOT EXISTS (SELECT 1 FROM link_reminders r WHERE r.domain = c.domain AND r.code = c.code AND r.kind = c.kind)
+		LIMIT $2`
+	rows, err := s.pool.Query(ctx, query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []*ReminderCandidate
+	for rows.Next() {
+		var c ReminderCandidate
+		var link Link
+		var ns NotificationSettings
+		dest := append([]any{&c.Kind}, linkFields(&link)...)
+		dest = append(dest, notificationSettingsFields(&ns)...)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		c.Link = scanned(&link)
+		c.Settings = &ns
+		candidates = append(candidates, &c)
+	}
+	return candidates, rows.Err()
+}
+
+func (s *PostgresReminderStorage) ClaimReminder(ctx context.Context, key, kind string) (bool, error) {
+	domain, code := SplitLinkKey(key)
+	tag, err := s.pool.Exec(ctx, `INSERT INTO link_reminders (domain, code, kind) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`, domain, code, kind)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (s *PostgresReminderStorage) ReleaseReminder(ctx context.Context, key, kind string) error {
+	domain, code := SplitLinkKey(key)
+	_, err := s.pool.Exec(ctx, `DELETE FROM link_reminders WHERE domain = $1 AND code = $2 AND kind = $3`, domain, code, kind)
+	return err
+}
+
+func (s *PostgresReminderStorage) FindDigestRecipients(ctx context.Context, periodEnd time.Time, limit int) ([]*DigestRecipient, error) {
+	query := `SELECT ` + notificationSettingsColumns + `, digest_sent_until FROM notification_settings
+		WHERE email_enabled AND email IS NOT NULL AND digest IN ('week', 'month')
+			AND (digest_sent_until IS NULL
+				OR digest_sent_until <= $1 - make_interval(days => CASE digest WHEN 'week' THEN 7 ELSE 30 END))
+		LIMIT $2`
+	rows, err := s.pool.Query(ctx, query, periodEnd, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []*DigestRecipient
+	for rows.Next() {
+		var ns NotificationSettings
+		var r DigestRecipient
+		if err := rows.Scan(append(notificationSettingsFields(&ns), &r.SentUntil)...); err != nil {
+			return nil, err
+		}
+		r.Settings = &ns
+		recipients = append(recipients, &r)
+	}
+	return recipients, rows.Err()
+}
+
+func (s *PostgresReminderStorage) ClaimDigest(ctx context.Context, ownerID uuid.UUID, periodEnd time.Time, days int) (bool, error) {
+	query := `UPDATE notification_settings SET digest_sent_until = $2
+		WHERE owner_id = $1 AND (digest_sent_until IS NULL OR digest_sent_until <= $2 - make_interval(days => $3))`
+	tag, err := s.pool.Exec(ctx, query, ownerID, periodEnd, days)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+func (s *PostgresReminderStorage) ReleaseDigest(ctx context.Context, ownerID uuid.UUID, previous *time.Time) error {
+	_, err := s.pool.Exec(ctx, `UPDATE notification_settings SET digest_sent_until = $2 WHERE owner_id = $1`, ownerID, previous)
+	return err
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Links are replicated between regions last-writer-wins on (updated_at,
+// region): a replicated write replaces the stored link only if it is later,
+// so regions applying the same writes in any order end up with the same
+// links. Click counts, the suspension flag and the link's last click stay
+// as each region has them.
+
+// ApplyLink inserts link or replaces the stored one if link was written
+// later. A campaign that doesn't exist in this region is dropped.
+func (s *PostgresLinkStorage) ApplyLink(ctx context.Context, link *Link) (bool, error) {
+	query := `INSERT INTO links (code, long_url, alias, password_hash, expires_at, max_clicks, owner_id, redirect_type, domain, public_stats, exclude_cidrs, exclude_user_agents, campaign_id, description, metadata, param_rules, allow_cidrs, deny_cidrs, tenant_id, rate_limit, rate_limit_window, created_at, updated_at, region, noindex)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, (SELECT id FROM campaigns WHERE id = $13), $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25)
+		ON CONFLICT (domain, code) DO UPDATE SET long_url = EXCLUDED.long_url, alias = EXCLUDED.alias, password_hash = EXCLUDED.password_hash, expires_at = EXCLUDED.expires_at, max_clicks = EXCLUDED.max_clicks, owner_id = EXCLUDED.owner_id, redirect_type = EXCLUDED.redirect_type, public_stats = EXCLUDED.public_stats, exclude_cidrs = EXCLUDED.exclude_cidrs, exclude_user_agents = EXCLUDED.exclude_user_agents, campaign_id = EXCLUDED.campaign_id, description = EXCLUDED.description, metadata = EXCLUDED.metadata, param_rules = EXCLUDED.param_rules, allow_cidrs = EXCLUDED.allow_cidrs, deny_cidrs = EXCLUDED.deny_cidrs, tenant_id = EXCLUDED.tenant_id, rate_limit = EXCLUDED.rate_limit, rate_limit_window = EXCLUDED.rate_limit_window, created_at = EXCLUDED.created_at, updated_at = EXCLUDED.updated_at, region = EXCLUDED.region, noindex = EXCLUDED.noindex
+		WHERE (links.updated_at, links.region) < (EXCLUDED.updated_at, EXCLUDED.region)`
+	tag, err := s.pool.Exec(ctx, query, link.Code, link.LongURL, link.Alias, link.PasswordHash, link.ExpiresAt, link.MaxClicks, link.OwnerID, link.RedirectType, storedDomain(link), link.PublicStats, link.ExcludeCIDRs, link.ExcludeUserAgents, link.CampaignID, link.Description, link.Metadata, link.ParamRules, link.AllowCIDRs, link.DenyCIDRs, link.TenantID, link.RateLimit, link.RateLimitWindow, link.CreatedAt, link.UpdatedAt, link.Region, link.NoIndex)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ApplyDelete deletes the link under key unless it was written after the
+// delete, made at deletedAt in region
+func (s *PostgresLinkStorage) ApplyDelete(ctx context.Context, key string, deletedAt time.Time, region string) (bool, error) {
+	domain, code := SplitLinkKey(key)
+	tag, err := s.pool.Exec(ctx, applyDeleteQuery, domain, code, deletedAt, region)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// SchemaVersion is the number of the migration this version of the code is
+// written for. Migrations record theirs in schema_version, and servers
+// refuse to start on an older schema, whose tables lack what the code
+// reads, and by default on a newer one, whose tables may hold what the code
+// doesn't know to keep when it writes.
+const SchemaVersion = 39
+
+var (
+	ErrSchemaOlder = errors.New("database schema is older than this version needs")
+	ErrSchemaNewer = errors.New("database schema is newer than this version knows")
+)
+
+// schemaTables are the tables the code reads and writes
+var schemaTables = []string{
+	"links", "link_tags", "bundles", "bundle_entries", "owner_preferences",
+	"notification_settings", "link_reminders", "webhook_subscriptions",
+	"outbox", "jobs", "campaigns", "link_health", "fraud_alerts",
+	"user_suspensions", "terms_acceptances", "owner_plans",
+	"billing_subscriptions", "integration_hooks", "integration_hook_firings",
+	"api_keys", "deep_link_keys", "alias_reservations", "code_tombstones",
+	"schema_version",
+}
+
+// GuardSchema compares the schema version of the database at databaseURL
+// with SchemaVersion. An older schema is an error, and so is a newer one
+// unless allowNewer, when readOnly reports that the server should only read.
+func GuardSchema(ctx context.Context, databaseURL string, allowNewer bool) (readOnly bool, err error) {
+	conn, err := pgx.Connect(ctx, databaseURL)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close(ctx)
+	version, err := readSchemaVersion(ctx, conn)
+	if err != nil {
+		return false, err
+	}
+	err = compareSchemaVersion(version)
+	if errors.Is(err, ErrSchemaNewer) && allowNewer {
+		return true, nil
+	}
+	return false, err
+}
+
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// readSchemaVersion returns the version in schema_version, or 0 if the
+// migration creating it hasn't been applied
+func readSchemaVersion(ctx context.Context, db rowQuerier) (int, error) {
+	var exists bool
+	if err := db.QueryRow(ctx, `SELECT to_regclass('schema_version') IS NOT NULL`).Scan(&exists); err != nil || !exists {
+		return 0, err
+	}
+	var version int
+	err := db.QueryRow(ctx, `SELECT version FROM schema_version`).Scan(&version)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, nil
+	}
+	return version, err
+}
+
+func compareSchemaVersion(version int) error {
+	switch {
+	case version < SchemaVersion:
+		return fmt.Errorf("%w: at migration %04d, needs %04d", ErrSchemaOlder, version, SchemaVersion)
+	case version > SchemaVersion:
+		return fmt.Errorf("%w: at migration %04d, knows up to %04d", ErrSchemaNewer, version, SchemaVersion)
+	}
+	return nil
+}
+
+// CheckSchema compares the database's schema version with SchemaVersion,
+// and reports the tables and links columns missing from it, as a schema
+// changed by hand or restored from elsewhere may lack them whatever its
+// version says.
+func CheckSchema(ctx context.Context, pool *pgxpool.Pool) error {
+	version, err := readSchemaVersion(ctx, pool)
+	if err != nil {
+		return err
+	}
+	if err := compareSchemaVersion(version); err != nil {
+		return err
+	}
+
+	var missing []string
+	rows, err := pool.Query(ctx, `
+		SELECT t.name FROM unnest($1::text[]) AS t(name)
+		WHERE to_regclass(t.name) IS NULL`, schemaTables)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			rows.Close()
+			return err
+		}
+		missing = append(missing, "table "+table)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	columns := strings.Split(linkColumns+", "+creationColumns, ", ")
+	rows, err = pool.Query(ctx, `
+		SELECT c.name FROM unnest($1::text[]) AS c(name)
+		WHERE NOT EXISTS (
+			SELECT 1 FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'links' AND column_name = c.name
+		)`, columns)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			rows.Close()
+			return err
+		}
+		missing = append(missing, "column links."+column)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("schema at migration %04d is missing %s", version, strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// sitemapListed is whether a link may be listed in its domain's sitemap:
+// anyone can follow it, it doesn't run out of clicks, and its owner meant it
+// to be found, with a permanent redirect and without noindex. Expiry is
+// checked when the sitemap is rendered.
+const sitemapListed = `NOT noindex AND NOT disabled AND password_hash IS NULL AND redirect_type IN (301, 308)
+	AND max_clicks IS NULL AND param_rules IS NULL
+	AND cardinality(COALESCE(allow_cidrs, '{}')) = 0 AND cardinality(COALESCE(deny_cidrs, '{}')) = 0`
+
+func (s *PostgresLinkStorage) ListSitemapLinks(ctx context.Context, domain string, since time.Time) ([]*SitemapLink, error) {
+	query := `SELECT code, updated_at, expires_at, ` + sitemapListed + ` FROM links WHERE domain = $1 AND updated_at > $2 ORDER BY updated_at`
+	rows, err := s.pool.Query(ctx, query, domain, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []*SitemapLink
+	for rows.Next() {
+		var link SitemapLink
+		if err := rows.Scan(&link.Code, &link.UpdatedAt, &link.ExpiresAt, &link.Listed); err != nil {
+			return nil, err
+		}
+		links = append(links, &link)
+	}
+	return links, rows.Err()
+}
+
+func (s *PostgresLinkStorage) ListRetiredCodes(ctx context.Context, domain string, since time.Time) ([]string, error) {
+	rows, err := s.pool.Query(ctx, `SELECT DISTINCT code FROM code_tombstones WHERE domain = $1 AND retired_at > $2`, domain, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codes []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, rows.Err()
+}
@@ -0,0 +1,444 @@
+// Package storagetest is the contract every storage.LinkStorage
+// implementation must meet, so that a new backend can be checked against
+// what the services rely on rather than against Postgres's behavior as it
+// happens to be. A backend's tests call TestLinkStorage with a harness
+// opening it:
+//
+//	func TestMyLinkStorage(t *testing.T) {
+//		storagetest.TestLinkStorage(t, storagetest.LinkHarness{
+//			New:   func(t *testing.T) storage.LinkStorage { return openMyStorage(t) },
+//			Begin: myPool.Begin,
+//		})
+//	}
+//
+// The suite covers not-found semantics, duplicate keys, tombstones,
+// transactions and concurrent writes. It writes links under random codes
+// and owners of its own, so it may run against a database holding other
+// links, but RetireExpired retires every link that expired before 2000.
+package storagetest
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// uniqueViolation is the error code LinkService recognizes a taken code by
+const uniqueViolation = "23505"
+
+// LinkHarness opens the storage under test
+type LinkHarness struct {
+	// New returns the storage; it is called once per subtest
+	New func(t *testing.T) storage.LinkStorage
+	// Begin starts a transaction the storage's Tx methods can work in
+	Begin func(ctx context.Context) (pgx.Tx, error)
+}
+
+// TestLinkStorage runs the contract against the storage h opens
+func TestLinkStorage(t *testing.T, h LinkHarness) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, links storage.LinkStorage, h LinkHarness)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"NotFound", testNotFound},
+		{"DuplicateCode", testDuplicateCode},
+		{"ConcurrentCreates", testConcurrentCreates},
+		{"Update", testUpdate},
+		{"DeleteLeavesTombstone", testDeleteLeavesTombstone},
+		{"RetireExpired", testRetireExpired},
+		{"ConcurrentClickCounts", testConcurrentClickCounts},
+		{"ReplacePasswordHash", testReplacePasswordHash},
+		{"Tags", testTags},
+		{"ListByOwner", testListByOwner},
+		{"TransactionCommit", testTransactionCommit},
+		{"TransactionRollback", testTransactionRollback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, h.New(t), h)
+		})
+	}
+}
+
+// codeAlphabet makes codes that fit every code column
+const codeAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// newCode returns a random 10-character code
+func newCode() string {
+	b := make([]byte, 10)
+	rand.Read(b)
+	for i := range b {
+		b[i] = codeAlphabet[int(b[i])%len(codeAlphabet)]
+	}
+	return string(b)
+}
+
+// newLink returns a link under a new code, owned by owner
+func newLink(owner uuid.UUID) *storage.Link {
+	return &storage.Link{
+		Code:         newCode(),
+		LongURL:      "https://example.com/" + newCode(),
+		OwnerID:      &owner,
+		RedirectType: 302,
+	}
+}
+
+func create(t *testing.T, links storage.LinkStorage, link *storage.Link) {
+	t.Helper()
+	require.NoError(t, links.Create(context.Background(), link))
+}
+
+func testCreateAndGet(t *testing.T, links storage.LinkStorage, _ LinkHarness) {
+	ctx := context.Background()
+	expires := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	maxClicks := 10
+	description := "launch page"
+	ip := "203.0.113.7"
+	link := newLink(uuid.New())
+	link.ExpiresAt = &expires
+	link.MaxClicks = &maxClicks
+	link.RedirectType = 301
+	link.Description = &description
+	link.Metadata = map[string]any{"ticket": "OPS-1"}
+	link.Creation = &storage.CreationContext{IP: &ip}
+	create(t, links, link)
+
+	got, err := links.GetByCode(ctx, link.Key())
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, link.Code, got.Code)
+	assert.Equal(t, link.LongURL, got.LongURL)
+	assert.Equal(t, *link.OwnerID, *got.OwnerID)
+	assert.Equal(t, 301, got.RedirectType)
+	assert.Equal(t, &maxClicks, got.MaxClicks)
+	assert.Equal(t, &description, got.Description)
+	assert.Equal(t, link.Metadata, got.Metadata)
+	require.NotNil(t, got.ExpiresAt)
+	assert.True(t, expires.Equal(*got.ExpiresAt))
+	assert.Zero(t, got.ClickCount)
+	assert.False(t, got.CreatedAt.IsZero(), "the storage sets the creation time")
+
+	creation, err := links.GetCreation(ctx, link.Key())
+	require.NoError(t, err)
+	require.NotNil(t, creation)
+	assert.Equal(t, &ip, creation.IP)
+
+	many, err := links.GetByCodes(ctx, []string{link.Key()})
+	require.NoError(t, err)
+	require.Len(t, many, 1)
+	assert.Equal(t, link.Code, many[0].Code)
+}
+
+// testNotFound checks that reads of missing links return nothing rather
+// than errors, and that writes to them do nothing, as deleted links are
+// still counted, retagged and rehashed by requests that raced the delete
+func testNotFound(t *testing.T, links storage.LinkStorage, _ LinkHarness) {
+	ctx := context.Background()
+	present := newLink(uuid.New())
+	create(t, links, present)
+	missing := newCode()
+
+	link, err := links.GetByCode(ctx, missing)
+	require.NoError(t, err)
+	assert.Nil(t, link)
+
+	many, err := links.GetByCodes(ctx, []string{missing, present.Key()})
+	require.NoError(t, err)
+	require.Len(t, many, 1, "missing links are omitted")
+	assert.Equal(t, present.Code, many[0].Code)
+
+	creation, err := links.GetCreation(ctx, missing)
+	require.NoError(t, err)
+	assert.Nil(t, creation)
+
+	tags, err := links.GetTags(ctx, []string{missing})
+	require.NoError(t, err)
+	assert.Empty(t, tags)
+
+	assert.NoError(t, links.Update(ctx, &storage.Link{Code: missing, LongURL: "https://example.com", OwnerID: present.OwnerID}))
+	assert.NoError(t, links.AddClickCount(ctx, missing, 1))
+	assert.NoError(t, links.ReplacePasswordHash(ctx, missing, "old", "new"))
+	assert.NoError(t, links.Delete(ctx, missing))
+	link, err = links.GetByCode(ctx, missing)
+	require.NoError(t, err)
+	assert.Nil(t, link, "writes don't create missing links")
+}
+
+func testDuplicateCode(t *testing.T, links storage.LinkStorage, _ LinkHarness) {
+	ctx := context.Background()
+	link := newLink(uuid.New())
+	create(t, links, link)
+
+	again := newLink(uuid.New())
+	again.Code = link.Code
+	err := links.Create(ctx, again)
+	var pgErr *pgconn.PgError
+	require.True(t, errors.As(err, &pgErr), "a taken code fails with a unique violation, got %v", err)
+	assert.Equal(t, uniqueViolation, pgErr.Code)
+
+	// Codes are unique per domain
+	domain := "go.example.com"
+	other := newLink(uuid.New())
+	other.Code, other.Domain = link.Code, &domain
+	create(t, links, other)
+	got, err := links.GetByCode(ctx, other.Key())
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, other.LongURL, got.LongURL)
+	got, err = links.GetByCode(ctx, link.Key())
+	require.NoError(t, err)
+	assert.Equal(t, link.LongURL, got.LongURL)
+}
+
+func testConcurrentCreates(t *testing.T, links storage.LinkStorage, _ LinkHarness) {
+	const writers = 10
+	code := newCode()
+	errs := make([]error, writers)
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			link := newLink(uuid.New())
+			link.Code = code
+			errs[i] = links.Create(context.Background(), link)
+		}()
+	}
+	wg.Wait()
+
+	created := 0
+	for _, err := range errs {
+		if err == nil {
+			created++
+		}
+	}
+	assert.Equal(t, 1, created, "exactly one create of a code succeeds")
+}
+
+func testUpdate(t *testing.T, links storage.LinkStorage, _ LinkHarness) {
+	ctx := context.Background()
+	link := newLink(uuid.New())
+	create(t, links, link)
+	created := link.UpdatedAt
+
+	link.LongURL = "https://example.org/moved"
+	link.PublicStats = true
+	time.Sleep(time.Millisecond)
+	require.NoError(t, links.Update(ctx, link))
+	assert.True(t, link.UpdatedAt.After(created), "writes stamp the link's write time")
+
+	got, err := links.GetByCode(ctx, link.Key())
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.org/moved", got.LongURL)
+	assert.True(t, got.PublicStats)
+	assert.WithinDuration(t, link.UpdatedAt, got.UpdatedAt, time.Millisecond)
+}
+
+func testDeleteLeavesTombstone(t *testing.T, links storage.LinkStorage, h LinkHarness) {
+	ctx := context.Background()
+	link := newLink(uuid.New())
+	create(t, links, link)
+	require.NoError(t, links.Delete(ctx, link.Key()))
+
+	got, err := links.GetByCode(ctx, link.Key())
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	tx, err := h.Begin(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(ctx)
+	retired, err := links.LastRetiredTx(ctx, tx, link.Key())
+	require.NoError(t, err)
+	assert.NotNil(t, retired, "deleting a link records when its code was retired")
+	retired, err = links.LastRetiredTx(ctx, tx, newCode())
+	require.NoError(t, err)
+	assert.Nil(t, retired)
+}
+
+func testRetireExpired(t *testing.T, links storage.LinkStorage, _ LinkHarness) {
+	ctx := context.Background()
+	expired := time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC)
+	cutoff := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	old, current := newLink(uuid.New()), newLink(uuid.New())
+	old.ExpiresAt = &expired
+	create(t, links, old)
+	create(t, links, current)
+
+	n, err := links.RetireExpired(ctx, cutoff, 100)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, n, int64(1))
+
+	got, err := links.GetByCode(ctx, old.Key())
+	require.NoError(t, err)
+	assert.Nil(t, got)
+	got, err = links.GetByCode(ctx, current.Key())
+	require.NoError(t, err)
+	assert.NotNil(t, got, "links without an expiry aren't retired")
+}
+
+func testConcurrentClickCounts(t *testing.T, links storage.LinkStorage, _ LinkHarness) {
+	ctx := context.Background()
+	link := newLink(uuid.New())
+	create(t, links, link)
+
+	const writers, adds = 10, 5
+	var wg sync.WaitGroup
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range adds {
+				assert.NoError(t, links.AddClickCount(ctx, link.Key(), 2))
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := links.GetByCode(ctx, link.Key())
+	require.NoError(t, err)
+	assert.Equal(t, writers*adds*2, got.ClickCount, "no concurrent add is lost")
+	assert.NotNil(t, got.LastClickedAt)
+}
+
+func testReplacePasswordHash(t *testing.T, links storage.LinkStorage, _ LinkHarness) {
+	ctx := context.Background()
+	hash := "old-hash"
+	link := newLink(uuid.New())
+	link.PasswordHash = &hash
+	create(t, links, link)
+
+	// The password changed meanwhile
+	require.NoError(t, links.ReplacePasswordHash(ctx, link.Key(), "other-hash", "new-hash"))
+	got, err := links.GetByCode(ctx, link.Key())
+	require.NoError(t, err)
+	assert.Equal(t, "old-hash", *got.PasswordHash)
+
+	require.NoError(t, links.ReplacePasswordHash(ctx, link.Key(), "old-hash", "new-hash"))
+	got, err = links.GetByCode(ctx, link.Key())
+	require.NoError(t, err)
+	assert.Equal(t, "new-hash", *got.PasswordHash)
+}
+
+func testTags(t *testing.T, links storage.LinkStorage, _ LinkHarness) {
+	ctx := context.Background()
+	owner := uuid.New()
+	first, second := newLink(owner), newLink(owner)
+	create(t, links, first)
+	create(t, links, second)
+
+	require.NoError(t, links.SetTags(ctx, first.Key(), []string{"launch", "q3"}))
+	require.NoError(t, links.SetTags(ctx, second.Key(), []string{"q3", "docs"}))
+	tags, err := links.GetTags(ctx, []string{first.Key(), second.Key()})
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"launch", "q3"}, tags[first.Key()])
+	assert.ElementsMatch(t, []string{"q3", "docs"}, tags[second.Key()])
+
+	owned, err := links.ListTagsByOwner(ctx, owner)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"docs", "launch", "q3"}, owned, "an owner's tags are distinct and sorted")
+
+	// SetTags replaces the link's tags
+	require.NoError(t, links.SetTags(ctx, first.Key(), []string{"archived"}))
+	tags, err = links.GetTags(ctx, []string{first.Key()})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"archived"}, tags[first.Key()])
+	require.NoError(t, links.SetTags(ctx, first.Key(), nil))
+	tags, err = links.GetTags(ctx, []string{first.Key()})
+	require.NoError(t, err)
+	assert.Empty(t, tags[first.Key()])
+}
+
+func testListByOwner(t *testing.T, links storage.LinkStorage, _ LinkHarness) {
+	ctx := context.Background()
+	owner := uuid.New()
+	var created []*storage.Link
+	for range 3 {
+		link := newLink(owner)
+		create(t, links, link)
+		created = append(created, link)
+		time.Sleep(10 * time.Millisecond)
+	}
+	create(t, links, newLink(uuid.New()))
+
+	page, err := links.ListByOwner(ctx, owner, storage.LinkQuery{Order: storage.LinkOrderNewest}, 2, 0)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, created[2].Code, page[0].Code, "newest first")
+	assert.Equal(t, created[1].Code, page[1].Code)
+
+	page, err = links.ListByOwner(ctx, owner, storage.LinkQuery{Order: storage.LinkOrderNewest}, 2, 2)
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, created[0].Code, page[0].Code)
+
+	page, err = links.ListByOwner(ctx, uuid.New(), storage.LinkQuery{}, 10, 0)
+	require.NoError(t, err)
+	assert.Empty(t, page)
+}
+
+func testTransactionCommit(t *testing.T, links storage.LinkStorage, h LinkHarness) {
+	ctx := context.Background()
+	link := newLink(uuid.New())
+	tx, err := h.Begin(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(ctx)
+
+	require.NoError(t, links.CreateTx(ctx, tx, link))
+	inTx, err := links.GetByCodeTx(ctx, tx, link.Key())
+	require.NoError(t, err)
+	require.NotNil(t, inTx, "a transaction sees its own writes")
+	outside, err := links.GetByCode(ctx, link.Key())
+	require.NoError(t, err)
+	assert.Nil(t, outside, "others don't see uncommitted writes")
+
+	link.LongURL = "https://example.org/in-tx"
+	require.NoError(t, links.UpdateTx(ctx, tx, link))
+	require.NoError(t, tx.Commit(ctx))
+
+	got, err := links.GetByCode(ctx, link.Key())
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "https://example.org/in-tx", got.LongURL)
+}
+
+func testTransactionRollback(t *testing.T, links storage.LinkStorage, h LinkHarness) {
+	ctx := context.Background()
+	kept := newLink(uuid.New())
+	create(t, links, kept)
+	dropped := newLink(uuid.New())
+
+	tx, err := h.Begin(ctx)
+	require.NoError(t, err)
+	require.NoError(t, links.CreateTx(ctx, tx, dropped))
+	kept.LongURL = "https://example.org/rolled-back"
+	require.NoError(t, links.UpdateTx(ctx, tx, kept))
+	require.NoError(t, links.DeleteTx(ctx, tx, kept.Key()))
+	require.NoError(t, tx.Rollback(ctx))
+
+	got, err := links.GetByCode(ctx, dropped.Key())
+	require.NoError(t, err)
+	assert.Nil(t, got, "a rolled back create leaves no link")
+	got, err = links.GetByCode(ctx, kept.Key())
+	require.NoError(t, err)
+	require.NotNil(t, got, "a rolled back delete leaves the link")
+	assert.NotEqual(t, "https://example.org/rolled-back", got.LongURL)
+
+	tx, err = h.Begin(ctx)
+	require.NoError(t, err)
+	defer tx.Rollback(ctx)
+	retired, err := links.LastRetiredTx(ctx, tx, kept.Key())
+	require.NoError(t, err)
+	assert.Nil(t, retired, "nor a tombstone")
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresTermsStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresTermsStorage(pool *pgxpool.Pool) *PostgresTermsStorage {
+	return &PostgresTermsStorage{pool: pool}
+}
+
+func (s *PostgresTermsStorage) AcceptTerms(ctx context.Context, a *TermsAcceptance) error {
+	query := `INSERT INTO terms_acceptances (owner_id, version, accepted_at) VALUES ($1, $2, $3) ON CONFLICT (owner_id, version) DO NOTHING`
+	_, err := s.pool.Exec(ctx, query, a.OwnerID, a.Version, a.AcceptedAt)
+	return err
+}
+
+func (s *PostgresTermsStorage) GetTermsAcceptance(ctx context.Context, ownerID uuid.UUID) (*TermsAcceptance, error) {
+	query := `SELECT owner_id, version, accepted_at FROM terms_acceptances WHERE owner_id = $1 ORDER BY accepted_at DESC LIMIT 1`
+	var a TermsAcceptance
+	err := s.pool.QueryRow(ctx, query, ownerID).Scan(&a.OwnerID, &a.Version, &a.AcceptedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
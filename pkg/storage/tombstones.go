@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Reasons a link's code was tombstoned
+const (
+	RetiredDeleted = "deleted"
+	RetiredExpired = "expired"
+)
+
+// retireQuery deletes the links matching where and records a tombstone for
+// each, retired at retiredAt (an SQL expression over the deleted row) for
+// reason. Its rows affected are the links deleted.
+func retireQuery(where, retiredAt, reason string) string {
+	return `WITH gone AS (DELETE FROM links WHERE ` + where + ` RETURNING domain, code, owner_id, created_at, expires_at)
+		INSERT INTO code_tombstones (domain, code, owner_id, created_at, retired_at, reason)
+		SELECT domain, code, owner_id, created_at, ` + retiredAt + `, '` + reason + `' FROM gone`
+}
+
+var (
+	deleteLinkQuery = retireQuery(`domain = $1 AND code = $2`, `NOW()`, RetiredDeleted)
+	// applyDeleteQuery deletes a link unless it was written after the
+	// replicated delete; see replication.go
+	applyDeleteQuery   = retireQuery(`domain = $1 AND code = $2 AND (updated_at, region) <= ($3, $4)`, `NOW()`, RetiredDeleted)
+	retireExpiredQuery = retireQuery(`(domain, code) IN (SELECT domain, code FROM links WHERE expires_at < $1 LIMIT $2)`, `expires_at`, RetiredExpired)
+)
+
+func (s *PostgresLinkStorage) LastRetiredTx(ctx context.Context, tx pgx.Tx, key string) (*time.Time, error) {
+	domain, code := SplitLinkKey(key)
+	var retiredAt *time.Time
+	err := tx.QueryRow(ctx, `SELECT MAX(retired_at) FROM code_tombstones WHERE domain = $1 AND code = $2`, domain, code).Scan(&retiredAt)
+	return retiredAt, err
+}
+
+func (s *PostgresLinkStorage) RetireExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	tag, err := s.pool.Exec(ctx, retireExpiredQuery, before, limit)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"url-shortener/pkg/logging"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/tracelog"
+)
+
+// Pools given a logger trace their queries with pgx's tracelog, through
+// queryLogger, which logs the queries that fail or take SlowQuery or longer
+// with the correlation ID of the request they ran for. Arguments are never
+// logged, as they hold destinations and owners' data.
+
+// queryLogger adapts logging.Logger to tracelog
+type queryLogger struct {
+	logger *logging.Logger
+	slow   time.Duration
+}
+
+// newQueryTracer traces at info level, which tracelog uses for every
+// completed query, only when slow queries are wanted; otherwise only
+// failures are traced, which costs nothing for queries that succeed
+func newQueryTracer(logger *logging.Logger, slow time.Duration) *tracelog.TraceLog {
+	level := tracelog.LogLevelError
+	if slow > 0 {
+		level = tracelog.LogLevelInfo
+	}
+	return &tracelog.TraceLog{Logger: queryLogger{logger: logger, slow: slow}, LogLevel: level}
+}
+
+func (l queryLogger) Log(ctx context.Context, level tracelog.LogLevel, msg string, data map[string]any) {
+	elapsed, _ := data["time"].(time.Duration)
+	args := []any{"operation", strings.ToLower(msg), "duration_ms", elapsed.Milliseconds()}
+	if sql, ok := data["sql"].(string); ok {
+		args = append(args, "sql", compactSQL(sql))
+	}
+	if level <= tracelog.LogLevelError {
+		err, _ := data["err"].(error)
+		if expectedQueryError(err) {
+			return
+		}
+		l.logger.Warn(ctx, "database query failed", append(args, "error", err)...)
+		return
+	}
+	if l.slow > 0 && elapsed >= l.slow {
+		l.logger.Warn(ctx, "slow database query", args...)
+	}
+}
+
+// expectedQueryError reports whether err is one the caller handles, such as
+// a duplicate key when a code is taken, or a canceled request
+func expectedQueryError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return true
+	}
+	var pgErr *pgconn.PgError
+	// Class 23 is integrity constraint violations
+	return errors.As(err, &pgErr) && strings.HasPrefix(pgErr.Code, "23")
+}
+
+// compactSQL puts sql on one line
+func compactSQL(sql string) string {
+	return strings.Join(strings.Fields(sql), " ")
+}
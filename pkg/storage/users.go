@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresUserStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresUserStorage(pool *pgxpool.Pool) *PostgresUserStorage {
+	return &PostgresUserStorage{pool: pool}
+}
+
+func (s *PostgresUserStorage) GetUser(ctx context.Context, ownerID uuid.UUID) (*User, error) {
+	user := &User{OwnerID: ownerID}
+	query := `SELECT (SELECT COUNT(*) FROM links WHERE owner_id = $1),
+		(SELECT email FROM notification_settings WHERE owner_id = $1)`
+	if err := s.pool.QueryRow(ctx, query, ownerID).Scan(&user.Links, &user.Email); err != nil {
+		return nil, err
+	}
+	suspension, err := s.GetSuspension(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	user.Suspension = suspension
+	if user.Links == 0 && user.Email == nil && suspension == nil {
+		return nil, nil
+	}
+	return user, nil
+}
+
+func (s *PostgresUserStorage) FindUsersByEmail(ctx context.Context, email string) ([]*User, error) {
+	rows, err := s.pool.Query(ctx, `SELECT owner_id FROM notification_settings WHERE LOWER(email) = LOWER($1) ORDER BY owner_id`, email)
+	if err != nil {
+		return nil, err
+	}
+	var ownerIDs []uuid.UUID
+	for rows.Next() {
+		var ownerID uuid.UUID
+		if err := rows.Scan(&ownerID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ownerIDs = append(ownerIDs, ownerID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var users []*User
+	for _, ownerID := range ownerIDs {
+		user, err := s.GetUser(ctx, ownerID)
+		if err != nil {
+			return nil, err
+		}
+		if user != nil {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (s *PostgresUserStorage) GetUsage(ctx context.Context, ownerID uuid.UUID, since time.Time) (*Usage, error) {
+	var usage Usage
+	query := `SELECT COUNT(*), COUNT(*) FILTER (WHERE created_at >= $2),
+		ARRAY(SELECT DISTINCT domain FROM links WHERE owner_id = $1 AND domain <> '' ORDER BY domain)
+		FROM links WHERE owner_id = $1`
+	if err := s.pool.QueryRow(ctx, query, ownerID, since).Scan(&usage.Links, &usage.CreatesToday, &usage.ShortDomains); err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+func (s *PostgresUserStorage) GetSuspension(ctx context.Context, ownerID uuid.UUID) (*Suspension, error) {
+	var sus Suspension
+	query := `SELECT owner_id, reason, suspended_by, suspended_at FROM user_suspensions WHERE owner_id = $1`
+	err := s.pool.QueryRow(ctx, query, ownerID).Scan(&sus.OwnerID, &sus.Reason, &sus.SuspendedBy, &sus.SuspendedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sus, nil
+}
+
+func (s *PostgresUserStorage) SuspendUser(ctx context.Context, sus *Suspension) ([]string, bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `INSERT INTO user_suspensions (owner_id, reason, suspended_by, suspended_at) VALUES ($1, $2, $3, $4) ON CONFLICT (owner_id) DO NOTHING`
+	tag, err := tx.Exec(ctx, query, sus.OwnerID, sus.Reason, sus.SuspendedBy, sus.SuspendedAt)
+	if err != nil {
+		return nil, false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, false, nil
+	}
+	keys, err := setLinksDisabled(ctx, tx, sus.OwnerID, true)
+	if err != nil {
+		return nil, false, err
+	}
+	return keys, true, tx.Commit(ctx)
+}
+
+func (s *PostgresUserStorage) ReinstateUser(ctx context.Context, ownerID uuid.UUID) ([]string, bool, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `DELETE FROM user_suspensions WHERE owner_id = $1`, ownerID)
+	if err != nil {
+		return nil, false, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, false, nil
+	}
+	keys, err := setLinksDisabled(ctx, tx, ownerID, false)
+	if err != nil {
+		return nil, false, err
+	}
+	return keys, true, tx.Commit(ctx)
+}
+
+// setLinksDisabled flips the disabled flag on every link of ownerID that
+// doesn't have it yet and returns their keys
+func setLinksDisabled(ctx context.Context, tx pgx.Tx, ownerID uuid.UUID, disabled bool) ([]string, error) {
+	query := `UPDATE links SET disabled = $2 WHERE owner_id = $1 AND disabled <> $2 RETURNING domain, code`
+	rows, err := tx.Query(ctx, query, ownerID, disabled)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var domain, code string
+		if err := rows.Scan(&domain, &code); err != nil {
+			return nil, err
+		}
+		keys = append(keys, LinkKey(domain, code))
+	}
+	return keys, rows.Err()
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type PostgresWebhookStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresWebhookStorage(pool *pgxpool.Pool) *PostgresWebhookStorage {
+	return &PostgresWebhookStorage{pool: pool}
+}
+
+const webhookColumns = `id, owner_id, url, secret, sample_rate, batch_size, batch_interval_seconds, created_at`
+
+func scanWebhook(row pgx.Row) (*WebhookSubscription, error) {
+	var sub WebhookSubscription
+	var intervalSeconds int64
+	err := row.Scan(&sub.ID, &sub.OwnerID, &sub.URL, &sub.Secret, &sub.SampleRate, &sub.BatchSize, &intervalSeconds, &sub.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	sub.BatchInterval = time.Duration(intervalSeconds) * time.Second
+	return &sub, nil
+}
+
+func (s *PostgresWebhookStorage) CreateWebhook(ctx context.Context, sub *WebhookSubscription) error {
+	query := `INSERT INTO webhook_subscriptions (` + webhookColumns + `) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := s.pool.Exec(ctx, query, sub.ID, sub.OwnerID, sub.URL, sub.Secret, sub.SampleRate, sub.BatchSize, int64(sub.BatchInterval.Seconds()), sub.CreatedAt)
+	return err
+}
+
+func (s *PostgresWebhookStorage) GetWebhook(ctx context.Context, id uuid.UUID) (*WebhookSubscription, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhook_subscriptions WHERE id = $1`
+	sub, err := scanWebhook(s.pool.QueryRow(ctx, query, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	return sub, err
+}
+
+func (s *PostgresWebhookStorage) ListWebhooksByOwner(ctx context.Context, ownerID uuid.UUID) ([]*WebhookSubscription, error) {
+	return s.list(ctx, `SELECT `+webhookColumns+` FROM webhook_subscriptions WHERE owner_id = $1 ORDER BY created_at`, ownerID)
+}
+
+func (s *PostgresWebhookStorage) ListAllWebhooks(ctx context.Context) ([]*WebhookSubscription, error) {
+	return s.list(ctx, `SELECT `+webhookColumns+` FROM webhook_subscriptions`)
+}
+
+func (s *PostgresWebhookStorage) list(ctx context.Context, query string, args ...any) ([]*WebhookSubscription, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []*WebhookSubscription
+	for rows.Next() {
+		sub, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (s *PostgresWebhookStorage) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	return err
+}
@@ -0,0 +1,82 @@
+// Package tenant lets one deployment serve several isolated customers. A
+// tenant is a named set of short domains: its links live on those domains
+// and nowhere else, so codes, cache entries and redirects, which are all
+// keyed by domain, never cross tenants. Requests without a tenant belong to
+// the default tenant "", which owns the default domain and every short
+// domain no tenant claims.
+package tenant
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type contextKey struct{}
+
+// WithTenant scopes ctx to tenant id
+func WithTenant(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant set by WithTenant, or "" for the default
+// tenant
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
+
+// Registry maps tenants to their short domains
+type Registry struct {
+	domains  map[string][]string
+	byDomain map[string]string
+}
+
+// NewRegistry takes each tenant's short domains, the first being where its
+// links go by default. A domain can belong to one tenant only.
+func NewRegistry(tenants map[string][]string) (*Registry, error) {
+	r := &Registry{
+		domains:  make(map[string][]string),
+		byDomain: make(map[string]string),
+	}
+	for id, domains := range tenants {
+		if len(domains) == 0 {
+			return nil, fmt.Errorf("tenant %s has no short domains", id)
+		}
+		for _, domain := range domains {
+			domain = strings.ToLower(domain)
+			if other, ok := r.byDomain[domain]; ok {
+				return nil, fmt.Errorf("domain %s belongs to tenants %s and %s", domain, other, id)
+			}
+			r.byDomain[domain] = id
+			r.domains[id] = append(r.domains[id], domain)
+		}
+	}
+	return r, nil
+}
+
+// ForDomain returns the tenant that owns a short domain, "" being the
+// default domain
+func (r *Registry) ForDomain(domain string) string {
+	return r.byDomain[strings.ToLower(domain)]
+}
+
+// Exists reports whether id is a configured tenant or the default one
+func (r *Registry) Exists(id string) bool {
+	_, ok := r.domains[id]
+	return ok || id == ""
+}
+
+// DefaultDomain is where tenant id's links go when no domain is requested
+func (r *Registry) DefaultDomain(id string) string {
+	if domains := r.domains[id]; len(domains) > 0 {
+		return domains[0]
+	}
+	return ""
+}
+
+// Domains returns tenant id's short domains
+func (r *Registry) Domains(id string) []string {
+	return slices.Clone(r.domains[id])
+}
@@ -0,0 +1,38 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	r, err := NewRegistry(map[string][]string{
+		"acme":   {"go.acme.com", "Links.Acme.com"},
+		"globex": {"glx.io"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "acme", r.ForDomain("links.acme.com"))
+	assert.Equal(t, "globex", r.ForDomain("GLX.io"))
+	assert.Equal(t, "", r.ForDomain("sho.rt"))
+	assert.Equal(t, "", r.ForDomain(""))
+	assert.Equal(t, "go.acme.com", r.DefaultDomain("acme"))
+	assert.Equal(t, "", r.DefaultDomain(""))
+	assert.True(t, r.Exists("globex"))
+	assert.True(t, r.Exists(""))
+	assert.False(t, r.Exists("initech"))
+
+	_, err = NewRegistry(map[string][]string{"a": {"x.io"}, "b": {"X.io"}})
+	assert.Error(t, err)
+	_, err = NewRegistry(map[string][]string{"a": {}})
+	assert.Error(t, err)
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	assert.Equal(t, "", FromContext(ctx))
+	assert.Equal(t, "acme", FromContext(WithTenant(ctx, "acme")))
+}
@@ -0,0 +1,221 @@
+// Package validation checks request DTOs against rules declared in
+// `validate` struct tags, e.g.
+//
+//	LongURL string `json:"long_url" validate:"required,url,max=2048"`
+//
+// Rules run in order and stop at the first failure for a field. Pointer
+// fields are dereferenced; a nil pointer only fails "required".
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// FieldError describes a single failed rule
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Errors is returned by Struct when one or more fields are invalid
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Field + ": " + fe.Message
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+// RuleFunc reports whether v satisfies the rule. param is the text after
+// "=" in the tag, or "" if there is none. On failure it returns a message.
+type RuleFunc func(v reflect.Value, param string) (ok bool, message string)
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]RuleFunc{
+		"url":      urlRule,
+		"min":      minRule,
+		"max":      maxRule,
+		"future":   futureRule,
+		"oneof":    oneOfRule,
+		"duration": durationRule,
+		"email":    emailRule,
+	}
+)
+
+// Register adds or replaces a named rule
+func Register(name string, fn RuleFunc) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[name] = fn
+}
+
+// Struct validates the exported fields of the struct (or pointer to struct) s
+func Struct(s interface{}) error {
+	v := reflect.ValueOf(s)
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return Errors{{Field: "body", Rule: "required", Message: "is required"}}
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("validation: expected struct, got %s", v.Kind())
+	}
+
+	var errs Errors
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || !field.IsExported() {
+			continue
+		}
+		if fe := checkField(fieldName(field), v.Field(i), tag); fe != nil {
+			errs = append(errs, *fe)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func checkField(name string, v reflect.Value, tag string) *FieldError {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			if hasRule(tag, "required") {
+				return &FieldError{Field: name, Rule: "required", Message: "is required"}
+			}
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	for _, spec := range strings.Split(tag, ",") {
+		rule, param, _ := strings.Cut(spec, "=")
+		switch rule {
+		case "omitempty":
+			if v.IsZero() {
+				return nil
+			}
+			continue
+		case "required":
+			if v.IsZero() {
+				return &FieldError{Field: name, Rule: rule, Message: "is required"}
+			}
+			continue
+		}
+
+		rulesMu.RLock()
+		fn, ok := rules[rule]
+		rulesMu.RUnlock()
+		if !ok {
+			panic("validation: unknown rule " + rule)
+		}
+		if ok, msg := fn(v, param); !ok {
+			return &FieldError{Field: name, Rule: rule, Message: msg}
+		}
+	}
+	return nil
+}
+
+func hasRule(tag, rule string) bool {
+	for _, spec := range strings.Split(tag, ",") {
+		if spec == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldName uses the JSON name so errors match what the client sent
+func fieldName(f reflect.StructField) string {
+	if name, _, _ := strings.Cut(f.Tag.Get("json"), ","); name != "" && name != "-" {
+		return name
+	}
+	return f.Name
+}
+
+func urlRule(v reflect.Value, _ string) (bool, string) {
+	u, err := url.ParseRequestURI(v.String())
+	if err != nil || u.Host == "" {
+		return false, "must be an absolute URL"
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return false, "must use http or https"
+	}
+	return true, ""
+}
+
+func minRule(v reflect.Value, param string) (bool, string) {
+	n, _ := strconv.ParseFloat(param, 64)
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())) >= n, "must be at least " + param + " characters"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()) >= n, "must be at least " + param
+	case reflect.Float32, reflect.Float64:
+		return v.Float() >= n, "must be at least " + param
+	case reflect.Slice, reflect.Map:
+		return float64(v.Len()) >= n, "must contain at least " + param + " items"
+	}
+	return true, ""
+}
+
+func maxRule(v reflect.Value, param string) (bool, string) {
+	n, _ := strconv.ParseFloat(param, 64)
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())) <= n, "must be at most " + param + " characters"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()) <= n, "must be at most " + param
+	case reflect.Float32, reflect.Float64:
+		return v.Float() <= n, "must be at most " + param
+	case reflect.Slice, reflect.Map:
+		return float64(v.Len()) <= n, "must contain at most " + param + " items"
+	}
+	return true, ""
+}
+
+func futureRule(v reflect.Value, _ string) (bool, string) {
+	t, ok := v.Interface().(time.Time)
+	if !ok {
+		return true, ""
+	}
+	return t.After(time.Now()), "must be in the future"
+}
+
+func oneOfRule(v reflect.Value, param string) (bool, string) {
+	allowed := strings.Fields(param)
+	val := fmt.Sprint(v.Interface())
+	for _, a := range allowed {
+		if a == val {
+			return true, ""
+		}
+	}
+	return false, "must be one of: " + strings.Join(allowed, ", ")
+}
+
+// durationRule accepts a positive Go duration string such as "720h"
+func durationRule(v reflect.Value, _ string) (bool, string) {
+	d, err := time.ParseDuration(v.String())
+	return err == nil && d > 0, "must be a positive duration such as 24h"
+}
+
+// emailRule accepts a bare address such as user@example.com
+func emailRule(v reflect.Value, _ string) (bool, string) {
+	addr, err := mail.ParseAddress(v.String())
+	return err == nil && addr.Address == v.String(), "must be an email address"
+}
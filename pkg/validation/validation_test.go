@@ -0,0 +1,86 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sample struct {
+	URL      string     `json:"url" validate:"required,url,max=30"`
+	Name     *string    `json:"name,omitempty" validate:"omitempty,min=2"`
+	Count    *int       `json:"count,omitempty" validate:"min=1,max=10"`
+	When     *time.Time `json:"when,omitempty" validate:"future"`
+	Mode     string     `json:"mode" validate:"omitempty,oneof=a b"`
+	TTL      *string    `json:"ttl,omitempty" validate:"duration"`
+	Email    string     `json:"email" validate:"omitempty,email"`
+	internal string     `validate:"required"`
+}
+
+func TestStruct(t *testing.T) {
+	str := func(s string) *string { return &s }
+	num := func(n int) *int { return &n }
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		input  sample
+		fields []string
+	}{
+		{"valid", sample{URL: "https://example.com", Count: num(3), When: &future}, nil},
+		{"missing url", sample{}, []string{"url"}},
+		{"bad scheme", sample{URL: "ftp://example.com"}, []string{"url"}},
+		{"relative url", sample{URL: "/path"}, []string{"url"}},
+		{"url too long", sample{URL: "https://example.com/very/long/path"}, []string{"url"}},
+		{"empty optional skipped", sample{URL: "https://a.io", Name: str("")}, nil},
+		{"short name", sample{URL: "https://a.io", Name: str("x")}, []string{"name"}},
+		{"count out of range", sample{URL: "https://a.io", Count: num(0)}, []string{"count"}},
+		{"past time", sample{URL: "https://a.io", When: &past}, []string{"when"}},
+		{"oneof", sample{URL: "https://a.io", Mode: "c"}, []string{"mode"}},
+		{"duration", sample{URL: "https://a.io", TTL: str("720h")}, nil},
+		{"bad duration", sample{URL: "https://a.io", TTL: str("-1h")}, []string{"ttl"}},
+		{"email", sample{URL: "https://a.io", Email: "me@example.com"}, nil},
+		{"bad email", sample{URL: "https://a.io", Email: "Me <me@example.com>"}, []string{"email"}},
+		{"multiple", sample{Count: num(11), Mode: "z"}, []string{"url", "count", "mode"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Struct(&tt.input)
+			if tt.fields == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var errs Errors
+			require.ErrorAs(t, err, &errs)
+			var got []string
+			for _, fe := range errs {
+				got = append(got, fe.Field)
+			}
+			assert.Equal(t, tt.fields, got)
+		})
+	}
+}
+
+func TestStructNil(t *testing.T) {
+	var s *sample
+	assert.Error(t, Struct(s))
+}
+
+func TestRegister(t *testing.T) {
+	Register("even", func(v reflect.Value, _ string) (bool, string) {
+		return v.Int()%2 == 0, "must be even"
+	})
+	type evenOnly struct {
+		N int `json:"n" validate:"even"`
+	}
+
+	assert.NoError(t, Struct(evenOnly{N: 2}))
+	err := Struct(evenOnly{N: 3})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "n: must be even")
+}
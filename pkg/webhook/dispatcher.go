@@ -0,0 +1,358 @@
+// Package webhook delivers click events to owners' webhook subscriptions.
+// Each subscription samples a fraction of its clicks and receives them in
+// batches, so that high-traffic links don't produce one POST per click.
+// Link lifecycle events from the outbox are sent individually.
+//
+// Delivery is at least once: a batch is retried until the endpoint answers
+// 2xx or the attempts run out, and retries carry the same batch and event
+// IDs so receivers can deduplicate. Events are buffered in memory, so clicks
+// queued when the process exits are lost. With a retry queue, a batch whose
+// first POST fails is handed to the job queue, which keeps retrying it
+// across restarts and dead-letters it at the end.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"url-shortener/pkg/jobs"
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/security"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+)
+
+const (
+	queueSize       = 10000
+	workers         = 4
+	maxAttempts     = 6
+	firstRetryDelay = time.Second
+	refreshInterval = 30 * time.Second
+	flushInterval   = time.Second
+
+	// RetryKind is the job that redelivers a failed batch
+	RetryKind = "webhook.retry"
+)
+
+var retryPolicy = jobs.Policy{
+	MaxAttempts: 10,
+	Backoff:     jobs.Exponential(30*time.Second, 2*time.Hour),
+	Timeout:     30 * time.Second,
+}
+
+// ClickEvent is one redirect as seen by subscribers
+type ClickEvent struct {
+	ID        string    `json:"id"`
+	Code      string    `json:"code"`
+	OwnerID   uuid.UUID `json:"-"`
+	ClickedAt time.Time `json:"clicked_at"`
+	Referrer  string    `json:"referrer,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+// Batch is the body of one webhook POST. SampleRate lets receivers scale
+// counts back up.
+type Batch struct {
+	ID             string        `json:"id"`
+	Event          string        `json:"event"`
+	SubscriptionID uuid.UUID     `json:"subscription_id"`
+	SampleRate     float64       `json:"sample_rate"`
+	Events         []*ClickEvent `json:"events"`
+
+	sub     *storage.WebhookSubscription
+	started time.Time
+}
+
+type Dispatcher struct {
+	store   storage.WebhookStorage
+	logger  *logging.Logger
+	client  *http.Client
+	sample  func() float64
+	retries *jobs.Queue
+
+	events     chan *ClickEvent
+	deliveries chan *Batch
+	dropped    atomic.Int64
+
+	// Owned by the Run goroutine
+	subs    map[uuid.UUID][]*storage.WebhookSubscription
+	pending map[uuid.UUID]*Batch
+}
+
+func NewDispatcher(store storage.WebhookStorage, logger *logging.Logger) *Dispatcher {
+	return &Dispatcher{
+		store:  store,
+		logger: logger,
+		// The URL was checked when it was saved, but its host may resolve
+		// somewhere else by now; check the address at dial time and don't
+		// follow redirects
+		client:     security.NewOutboundClient(10*time.Second, 0),
+		sample:     rand.Float64,
+		events:     make(chan *ClickEvent, queueSize),
+		deliveries: make(chan *Batch, queueSize),
+		subs:       make(map[uuid.UUID][]*storage.WebhookSubscription),
+		pending:    make(map[uuid.UUID]*Batch),
+	}
+}
+
+// UseRetryQueue hands failed batches to q instead of retrying them in memory
+func (d *Dispatcher) UseRetryQueue(q *jobs.Queue) {
+	d.retries = q
+	q.Register(RetryKind, retryPolicy, d.retry)
+}
+
+// Publish queues a click without blocking; if the queue is full the event is
+// dropped so that redirects never wait on webhooks
+func (d *Dispatcher) Publish(event *ClickEvent) {
+	select {
+	case d.events <- event:
+	default:
+		d.dropped.Add(1)
+	}
+}
+
+// Run routes events into batches and delivers them until ctx is done.
+// Subscriptions are reloaded every 30 seconds.
+func (d *Dispatcher) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range d.deliveries {
+				d.deliver(ctx, batch)
+			}
+		}()
+	}
+	defer wg.Wait()
+	defer close(d.deliveries)
+
+	d.refresh(ctx)
+	refresh := time.NewTicker(refreshInterval)
+	defer refresh.Stop()
+	flush := time.NewTicker(flushInterval)
+	defer flush.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-d.events:
+			d.route(event, time.Now())
+		case now := <-flush.C:
+			d.flushDue(now)
+			if n := d.dropped.Swap(0); n > 0 {
+				d.logger.Warn(ctx, "webhook queue full, click events dropped", "count", n)
+			}
+		case <-refresh.C:
+			d.refresh(ctx)
+		}
+	}
+}
+
+func (d *Dispatcher) refresh(ctx context.Context) {
+	all, err := d.store.ListAllWebhooks(ctx)
+	if err != nil {
+		d.logger.Error(ctx, "failed to load webhook subscriptions", "error", err)
+		return
+	}
+	subs := make(map[uuid.UUID][]*storage.WebhookSubscription)
+	live := make(map[uuid.UUID]bool, len(all))
+	for _, sub := range all {
+		subs[sub.OwnerID] = append(subs[sub.OwnerID], sub)
+		live[sub.ID] = true
+	}
+	d.subs = subs
+	// Deleted subscriptions don't get their last partial batch
+	for id := range d.pending {
+		if !live[id] {
+			delete(d.pending, id)
+		}
+	}
+}
+
+// route samples event for each of its owner's subscriptions and adds it to
+// their pending batches, sending any batch that is full
+func (d *Dispatcher) route(event *ClickEvent, now time.Time) {
+	for _, sub := range d.subs[event.OwnerID] {
+		if sub.SampleRate < 1 && d.sample() >= sub.SampleRate {
+			continue
+		}
+		batch := d.pending[sub.ID]
+		if batch == nil {
+			batch = &Batch{
+				ID:             uuid.NewString(),
+				Event:          "link.clicked",
+				SubscriptionID: sub.ID,
+				SampleRate:     sub.SampleRate,
+				sub:            sub,
+				started:        now,
+			}
+			d.pending[sub.ID] = batch
+		}
+		batch.Events = append(batch.Events, event)
+		if len(batch.Events) >= max(sub.BatchSize, 1) {
+			d.send(batch)
+		}
+	}
+}
+
+// flushDue sends batches that have waited their subscription's interval
+func (d *Dispatcher) flushDue(now time.Time) {
+	for _, batch := range d.pending {
+		if now.Sub(batch.started) >= batch.sub.BatchInterval {
+			d.send(batch)
+		}
+	}
+}
+
+func (d *Dispatcher) send(batch *Batch) {
+	delete(d.pending, batch.SubscriptionID)
+	d.deliveries <- batch
+}
+
+// deliver POSTs batch, retrying with exponential backoff
+func (d *Dispatcher) deliver(ctx context.Context, batch *Batch) {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		d.logger.Error(ctx, "failed to encode webhook batch", "error", err)
+		return
+	}
+
+	delay := firstRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := d.post(ctx, batch.sub, batch.ID, body)
+		if err == nil {
+			return
+		}
+		if d.retries != nil {
+			job := &retryJob{SubscriptionID: batch.SubscriptionID, ID: batch.ID, Body: body}
+			if err := d.retries.Enqueue(ctx, RetryKind, job); err != nil {
+				d.logger.Warn(ctx, "failed to queue webhook retry, batch dropped",
+					"subscription_id", batch.SubscriptionID, "batch_id", batch.ID, "error", err)
+			}
+			return
+		}
+		if attempt == maxAttempts || ctx.Err() != nil {
+			d.logger.Warn(ctx, "webhook delivery failed, batch dropped",
+				"subscription_id", batch.SubscriptionID, "batch_id", batch.ID, "events", len(batch.Events), "error", err)
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+type retryJob struct {
+	SubscriptionID uuid.UUID       `json:"subscription_id"`
+	ID             string          `json:"id"`
+	Body           json.RawMessage `json:"body"`
+}
+
+// retry redelivers a batch from the job queue. Batches for deleted
+// subscriptions are dropped.
+func (d *Dispatcher) retry(ctx context.Context, payload json.RawMessage) error {
+	var job retryJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return jobs.Permanent(err)
+	}
+	sub, err := d.store.GetWebhook(ctx, job.SubscriptionID)
+	if err != nil {
+		return err
+	}
+	if sub == nil {
+		return nil
+	}
+	return d.post(ctx, sub, job.ID, job.Body)
+}
+
+// LinkEvent is the body of a link lifecycle webhook. link.fraud_alert
+// events carry the alert instead of the link.
+type LinkEvent struct {
+	ID             uuid.UUID       `json:"id"`
+	Event          string          `json:"event"`
+	SubscriptionID uuid.UUID       `json:"subscription_id"`
+	CreatedAt      time.Time       `json:"created_at"`
+	Link           json.RawMessage `json:"link,omitempty"`
+	Alert          json.RawMessage `json:"alert,omitempty"`
+}
+
+// PublishEvent sends an outbox event to every subscription of its owner,
+// unsampled and unbatched. It is called by the outbox relay, which retries
+// the whole event if any subscription fails.
+func (d *Dispatcher) PublishEvent(ctx context.Context, event *storage.OutboxEvent) error {
+	if event.OwnerID == nil {
+		return nil
+	}
+	subs, err := d.store.ListWebhooksByOwner(ctx, *event.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, sub := range subs {
+		linkEvent := &LinkEvent{
+			ID:             event.EventID,
+			Event:          event.Type,
+			SubscriptionID: sub.ID,
+			CreatedAt:      event.CreatedAt,
+		}
+		if event.Type == storage.FraudAlertEvent {
+			linkEvent.Alert = event.Payload
+		} else {
+			linkEvent.Link = event.Payload
+		}
+		body, err := json.Marshal(linkEvent)
+		if err != nil {
+			return err
+		}
+		if err := d.post(ctx, sub, event.EventID.String(), body); err != nil {
+			errs = append(errs, fmt.Errorf("subscription %s: %w", sub.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (d *Dispatcher) post(ctx context.Context, sub *storage.WebhookSubscription, id string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", id)
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(sub.Secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of body that receivers compare against
+// the X-Webhook-Signature header
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,143 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"url-shortener/pkg/logging"
+	"url-shortener/pkg/storage"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestDispatcher(subs ...*storage.WebhookSubscription) *Dispatcher {
+	d := NewDispatcher(nil, logging.NewLogger(logging.LevelError))
+	// Test endpoints listen on loopback
+	d.client = http.DefaultClient
+	for _, sub := range subs {
+		d.subs[sub.OwnerID] = append(d.subs[sub.OwnerID], sub)
+	}
+	return d
+}
+
+func click(owner uuid.UUID) *ClickEvent {
+	return &ClickEvent{ID: uuid.NewString(), Code: "abc", OwnerID: owner, ClickedAt: time.Now()}
+}
+
+func TestRouteBatchesBySizeAndInterval(t *testing.T) {
+	owner := uuid.New()
+	sub := &storage.WebhookSubscription{ID: uuid.New(), OwnerID: owner, SampleRate: 1, BatchSize: 3, BatchInterval: 10 * time.Second}
+	d := newTestDispatcher(sub)
+	now := time.Now()
+
+	for i := 0; i < 4; i++ {
+		d.route(click(owner), now)
+	}
+	d.route(click(uuid.New()), now) // someone else's link
+	require.Len(t, d.deliveries, 1)
+	batch := <-d.deliveries
+	assert.Len(t, batch.Events, 3)
+	assert.Equal(t, sub.ID, batch.SubscriptionID)
+
+	// The fourth event waits for the interval
+	d.flushDue(now.Add(5 * time.Second))
+	assert.Len(t, d.deliveries, 0)
+	d.flushDue(now.Add(10 * time.Second))
+	require.Len(t, d.deliveries, 1)
+	assert.Len(t, (<-d.deliveries).Events, 1)
+	assert.Empty(t, d.pending)
+}
+
+func TestRouteSamples(t *testing.T) {
+	owner := uuid.New()
+	sub := &storage.WebhookSubscription{ID: uuid.New(), OwnerID: owner, SampleRate: 0.25, BatchSize: 1000, BatchInterval: time.Minute}
+	d := newTestDispatcher(sub)
+	rolls := []float64{0.1, 0.5, 0.24, 0.25, 0.9}
+	d.sample = func() float64 {
+		r := rolls[0]
+		rolls = rolls[1:]
+		return r
+	}
+
+	for i := 0; i < 5; i++ {
+		d.route(click(owner), time.Now())
+	}
+	require.NotNil(t, d.pending[sub.ID])
+	assert.Len(t, d.pending[sub.ID].Events, 2)
+	assert.Equal(t, 0.25, d.pending[sub.ID].SampleRate)
+}
+
+func TestDeliverSignsAndRetries(t *testing.T) {
+	var attempts atomic.Int32
+	var ids []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "sha256="+Sign("secret", body), r.Header.Get("X-Webhook-Signature"))
+		ids = append(ids, r.Header.Get("X-Webhook-ID"))
+
+		var batch Batch
+		require.NoError(t, json.Unmarshal(body, &batch))
+		assert.Equal(t, "link.clicked", batch.Event)
+		assert.Len(t, batch.Events, 1)
+
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	owner := uuid.New()
+	sub := &storage.WebhookSubscription{ID: uuid.New(), OwnerID: owner, URL: server.URL, Secret: "secret", SampleRate: 1, BatchSize: 1}
+	d := newTestDispatcher(sub)
+	d.route(click(owner), time.Now())
+
+	d.deliver(context.Background(), <-d.deliveries)
+	assert.Equal(t, int32(2), attempts.Load())
+	require.Len(t, ids, 2)
+	assert.Equal(t, ids[0], ids[1], "retries reuse the batch ID")
+}
+
+type fakeStore struct {
+	storage.WebhookStorage
+	subs map[uuid.UUID]*storage.WebhookSubscription
+}
+
+func (f *fakeStore) GetWebhook(ctx context.Context, id uuid.UUID) (*storage.WebhookSubscription, error) {
+	return f.subs[id], nil
+}
+
+func TestRetryJobRedelivers(t *testing.T) {
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		assert.Equal(t, "sha256="+Sign("secret", body), r.Header.Get("X-Webhook-Signature"))
+		got = append(got, r.Header.Get("X-Webhook-ID"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	sub := &storage.WebhookSubscription{ID: uuid.New(), URL: server.URL, Secret: "secret"}
+	d := NewDispatcher(&fakeStore{subs: map[uuid.UUID]*storage.WebhookSubscription{sub.ID: sub}}, logging.NewLogger(logging.LevelError))
+	d.client = http.DefaultClient
+
+	payload, err := json.Marshal(&retryJob{SubscriptionID: sub.ID, ID: "batch-1", Body: json.RawMessage(`{"events":[]}`)})
+	require.NoError(t, err)
+	require.NoError(t, d.retry(context.Background(), payload))
+	assert.Equal(t, []string{"batch-1"}, got)
+
+	// Deleted subscriptions are dropped without a request
+	payload, err = json.Marshal(&retryJob{SubscriptionID: uuid.New(), ID: "batch-2", Body: json.RawMessage(`{}`)})
+	require.NoError(t, err)
+	require.NoError(t, d.retry(context.Background(), payload))
+	assert.Len(t, got, 1)
+}